@@ -0,0 +1,368 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package admin gathers the operational APIs of a TiKV cluster, which are
+// otherwise spread over KVStore and the PD clients, behind one Client: region
+// split and scatter, range deletion, compaction and GC safe point management.
+// All operations accept the same Options, are retried on transient PD errors,
+// and can be run in dry-run mode to see what they would do.
+//
+// Transferring region leaders is not provided, because PD exposes no client
+// API to create operators.
+package admin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/tikv"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/util/redact"
+)
+
+const (
+	defaultMaxBackoff  = 20000
+	defaultConcurrency = 8
+	compactTimeout     = 5 * time.Minute
+	scatterWaitBackoff = 120000
+)
+
+// Op is the kind of an operation.
+type Op string
+
+// The operations of Client.
+const (
+	OpSplit                    Op = "split"
+	OpScatter                  Op = "scatter"
+	OpDeleteRange              Op = "delete-range"
+	OpUnsafeDestroyRange       Op = "unsafe-destroy-range"
+	OpCompact                  Op = "compact"
+	OpUpdateGCSafePoint        Op = "update-gc-safe-point"
+	OpUpdateServiceGCSafePoint Op = "update-service-gc-safe-point"
+)
+
+// Action describes an operation that is done, or would be done in dry-run
+// mode. Only the fields related to the Op are set.
+type Action struct {
+	Op       Op
+	StartKey []byte
+	EndKey   []byte
+	Keys     [][]byte
+	// RegionIDs are the regions the operation affects. For a dry run, they
+	// are looked up from the region cache and may be stale.
+	RegionIDs []uint64
+	StoreAddr string
+	TableID   int64
+	ServiceID string
+	TTL       int64
+	SafePoint uint64
+}
+
+func (a Action) String() string {
+	switch a.Op {
+	case OpSplit:
+		return fmt.Sprintf("%s %d keys in regions %v", a.Op, len(a.Keys), a.RegionIDs)
+	case OpScatter:
+		return fmt.Sprintf("%s regions %v", a.Op, a.RegionIDs)
+	case OpDeleteRange, OpUnsafeDestroyRange:
+		return fmt.Sprintf("%s [%s, %s) in regions %v", a.Op, redact.Key(a.StartKey), redact.Key(a.EndKey), a.RegionIDs)
+	case OpCompact:
+		return fmt.Sprintf("%s table %d on store %s", a.Op, a.TableID, a.StoreAddr)
+	case OpUpdateGCSafePoint:
+		return fmt.Sprintf("%s to %d", a.Op, a.SafePoint)
+	case OpUpdateServiceGCSafePoint:
+		return fmt.Sprintf("%s of %s to %d with ttl %ds", a.Op, a.ServiceID, a.SafePoint, a.TTL)
+	}
+	return string(a.Op)
+}
+
+// Plan collects the actions of the operations run in dry-run mode.
+type Plan struct {
+	Actions []Action
+}
+
+type options struct {
+	plan        *Plan
+	maxBackoff  int
+	concurrency int
+	scatter     bool
+	waitScatter bool
+	tableID     *int64
+}
+
+// Option configures an operation.
+type Option func(*options)
+
+// WithDryRun makes the operation append its action to the plan instead of
+// executing it. Read-only lookups, e.g. locating regions, are still done.
+func WithDryRun(plan *Plan) Option {
+	return func(o *options) {
+		o.plan = plan
+	}
+}
+
+// WithMaxBackoff sets the total time in milliseconds the operation can back
+// off for retrying, the default is 20 seconds.
+func WithMaxBackoff(ms int) Option {
+	return func(o *options) {
+		o.maxBackoff = ms
+	}
+}
+
+// WithConcurrency sets the concurrency of the operations running on ranges,
+// the default is 8.
+func WithConcurrency(concurrency int) Option {
+	return func(o *options) {
+		o.concurrency = concurrency
+	}
+}
+
+// WithScatter makes SplitRegions scatter the new regions. If wait is true,
+// SplitRegions and ScatterRegions wait until the scatter finishes.
+func WithScatter(wait bool) Option {
+	return func(o *options) {
+		o.scatter = true
+		o.waitScatter = wait
+	}
+}
+
+// WithTableID sets the table of the split regions, which PD uses to scatter
+// the regions of a table evenly.
+func WithTableID(tableID int64) Option {
+	return func(o *options) {
+		o.tableID = &tableID
+	}
+}
+
+// Client runs administrative operations on a TiKV cluster.
+type Client struct {
+	store *tikv.KVStore
+}
+
+// NewClient creates a Client operating the cluster of the store.
+func NewClient(store *tikv.KVStore) *Client {
+	return &Client{store: store}
+}
+
+func (c *Client) options(opts []Option) *options {
+	o := &options{maxBackoff: defaultMaxBackoff, concurrency: defaultConcurrency}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// run appends the action to the plan in dry-run mode, otherwise it calls fn
+// and retries it on errors with the backoff config until the backoff budget
+// runs out. A nil cfg disables retrying.
+func (c *Client) run(ctx context.Context, o *options, action Action, cfg *tikv.BackoffConfig, fn func() error) error {
+	if o.plan != nil {
+		o.plan.Actions = append(o.plan.Actions, action)
+		return nil
+	}
+	bo := tikv.NewBackofferWithVars(ctx, o.maxBackoff, nil)
+	for {
+		err := fn()
+		if err == nil || cfg == nil {
+			return err
+		}
+		if err = bo.Backoff(cfg, err); err != nil {
+			return errors.WithMessagef(err, "admin: %s", action.Op)
+		}
+	}
+}
+
+func (c *Client) regionsInRange(ctx context.Context, o *options, startKey, endKey []byte) ([]uint64, error) {
+	bo := tikv.NewBackofferWithVars(ctx, o.maxBackoff, nil)
+	regions, err := c.store.GetRegionCache().LoadRegionsInKeyRange(bo, startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint64, 0, len(regions))
+	for _, r := range regions {
+		ids = append(ids, r.GetID())
+	}
+	return ids, nil
+}
+
+// SplitRegions splits regions by the keys and returns the IDs of the new
+// regions. It's a no-op for a key already being a region boundary.
+func (c *Client) SplitRegions(ctx context.Context, splitKeys [][]byte, opts ...Option) ([]uint64, error) {
+	o := c.options(opts)
+	action := Action{Op: OpSplit, Keys: splitKeys}
+	if o.plan != nil {
+		bo := tikv.NewBackofferWithVars(ctx, o.maxBackoff, nil)
+		for _, key := range splitKeys {
+			loc, err := c.store.GetRegionCache().LocateKey(bo, key)
+			if err != nil {
+				return nil, err
+			}
+			if n := len(action.RegionIDs); n == 0 || action.RegionIDs[n-1] != loc.Region.GetID() {
+				action.RegionIDs = append(action.RegionIDs, loc.Region.GetID())
+			}
+		}
+	}
+	// KVStore.SplitRegions backs off by itself.
+	var regionIDs []uint64
+	err := c.run(ctx, o, action, nil, func() (err error) {
+		regionIDs, err = c.store.SplitRegions(ctx, splitKeys, o.scatter, o.tableID)
+		return err
+	})
+	if err != nil || !o.waitScatter {
+		return regionIDs, err
+	}
+	return regionIDs, c.waitScatter(ctx, regionIDs)
+}
+
+// ScatterRegions asks PD to scatter the regions among the stores.
+func (c *Client) ScatterRegions(ctx context.Context, regionIDs []uint64, opts ...Option) error {
+	o := c.options(opts)
+	action := Action{Op: OpScatter, RegionIDs: regionIDs}
+	err := c.run(ctx, o, action, tikv.BoPDRPC(), func() error {
+		resp, err := c.store.GetPDClient().ScatterRegions(ctx, regionIDs)
+		if err != nil {
+			return err
+		}
+		if pdErr := resp.GetHeader().GetError(); pdErr != nil {
+			return errors.Errorf("admin: scatter regions: %s", pdErr.GetMessage())
+		}
+		if resp.GetFinishedPercentage() < 100 {
+			return errors.Errorf("admin: scatter regions: only %d%% are scattered", resp.GetFinishedPercentage())
+		}
+		return nil
+	})
+	if err != nil || o.plan != nil || !o.waitScatter {
+		return err
+	}
+	return c.waitScatter(ctx, regionIDs)
+}
+
+func (c *Client) waitScatter(ctx context.Context, regionIDs []uint64) error {
+	for _, id := range regionIDs {
+		if err := c.store.WaitScatterRegionFinish(ctx, id, scatterWaitBackoff); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteRange deletes all versions of all keys in [startKey, endKey) through
+// Raft and returns the number of regions deleted. Unlike UnsafeDestroyRange,
+// the range can still be written and read afterward.
+func (c *Client) DeleteRange(ctx context.Context, startKey, endKey []byte, opts ...Option) (completedRegions int, err error) {
+	o := c.options(opts)
+	action := Action{Op: OpDeleteRange, StartKey: startKey, EndKey: endKey}
+	if o.plan != nil {
+		if action.RegionIDs, err = c.regionsInRange(ctx, o, startKey, endKey); err != nil {
+			return 0, err
+		}
+	}
+	// The range task retries the regions by itself.
+	err = c.run(ctx, o, action, nil, func() (err error) {
+		completedRegions, err = c.store.DeleteRange(ctx, startKey, endKey, o.concurrency)
+		return err
+	})
+	return completedRegions, err
+}
+
+// UnsafeDestroyRange removes all keys in [startKey, endKey) from the disks of
+// all stores directly, bypassing Raft. The range must never be accessed again.
+func (c *Client) UnsafeDestroyRange(ctx context.Context, startKey, endKey []byte, opts ...Option) (err error) {
+	o := c.options(opts)
+	action := Action{Op: OpUnsafeDestroyRange, StartKey: startKey, EndKey: endKey}
+	if o.plan != nil {
+		if action.RegionIDs, err = c.regionsInRange(ctx, o, startKey, endKey); err != nil {
+			return err
+		}
+	}
+	return c.run(ctx, o, action, tikv.BoTiKVRPC(), func() error {
+		return c.store.UnsafeDestroyRange(ctx, startKey, endKey)
+	})
+}
+
+// Compact compacts the data of the table on the TiFlash store until nothing
+// remains to compact. It's not supported by TiKV stores.
+func (c *Client) Compact(ctx context.Context, storeAddr string, physicalTableID, logicalTableID int64, opts ...Option) error {
+	o := c.options(opts)
+	action := Action{Op: OpCompact, StoreAddr: storeAddr, TableID: physicalTableID}
+	var startKey []byte
+	return c.run(ctx, o, action, tikv.BoTiFlashRPC(), func() error {
+		for {
+			req := tikvrpc.NewRequest(tikvrpc.CmdCompact, &kvrpcpb.CompactRequest{
+				StartKey:        startKey,
+				PhysicalTableId: physicalTableID,
+				LogicalTableId:  logicalTableID,
+			})
+			resp, err := c.store.GetTiKVClient().SendRequest(ctx, storeAddr, req, compactTimeout)
+			if err != nil {
+				return err
+			}
+			if resp == nil || resp.Resp == nil {
+				return errors.Errorf("admin: compact returns nil response from store %s", storeAddr)
+			}
+			compactResp := resp.Resp.(*kvrpcpb.CompactResponse)
+			if compactErr := compactResp.GetError(); compactErr != nil {
+				return errors.Errorf("admin: compact table %d on store %s: %s", physicalTableID, storeAddr, compactErr.String())
+			}
+			if !compactResp.GetHasRemaining() {
+				return nil
+			}
+			// Continue from where the compaction stops, so that a retry
+			// doesn't start over.
+			startKey = compactResp.GetCompactedEndKey()
+		}
+	})
+}
+
+// UpdateGCSafePoint advances the GC safe point of the cluster and returns the
+// safe point after updating, which is never decreased.
+func (c *Client) UpdateGCSafePoint(ctx context.Context, safePoint uint64, opts ...Option) (newSafePoint uint64, err error) {
+	o := c.options(opts)
+	action := Action{Op: OpUpdateGCSafePoint, SafePoint: safePoint}
+	err = c.run(ctx, o, action, tikv.BoPDRPC(), func() (err error) {
+		newSafePoint, err = c.store.GetPDClient().UpdateGCSafePoint(ctx, safePoint)
+		return err
+	})
+	return newSafePoint, err
+}
+
+// GetGCSafePoint returns the GC safe point of the cluster.
+func (c *Client) GetGCSafePoint(ctx context.Context, opts ...Option) (safePoint uint64, err error) {
+	o := c.options(opts)
+	// Updating with 0 never changes the safe point. It's read-only, so it's
+	// done even in dry-run mode.
+	o.plan = nil
+	err = c.run(ctx, o, Action{Op: OpUpdateGCSafePoint}, tikv.BoPDRPC(), func() (err error) {
+		safePoint, err = c.store.GetPDClient().UpdateGCSafePoint(ctx, 0)
+		return err
+	})
+	return safePoint, err
+}
+
+// UpdateServiceGCSafePoint sets the service safe point of serviceID, which
+// keeps the GC safe point of the cluster from going beyond it for ttl seconds.
+// A ttl of 0 removes the service safe point. It returns the minimal service
+// safe point of all services.
+func (c *Client) UpdateServiceGCSafePoint(ctx context.Context, serviceID string, ttl int64, safePoint uint64, opts ...Option) (minSafePoint uint64, err error) {
+	o := c.options(opts)
+	action := Action{Op: OpUpdateServiceGCSafePoint, ServiceID: serviceID, TTL: ttl, SafePoint: safePoint}
+	err = c.run(ctx, o, action, tikv.BoPDRPC(), func() (err error) {
+		minSafePoint, err = c.store.GetPDClient().UpdateServiceGCSafePoint(ctx, serviceID, ttl, safePoint)
+		return err
+	})
+	return minSafePoint, err
+}
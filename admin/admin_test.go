@@ -0,0 +1,129 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/testutils"
+	"github.com/tikv/client-go/v2/tikv"
+)
+
+func newTestClient(t *testing.T) (*Client, *tikv.KVStore) {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	require.NoError(t, err)
+	testutils.BootstrapWithSingleStore(cluster)
+	store, err := tikv.NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return NewClient(store), store
+}
+
+func TestSplitRegions(t *testing.T) {
+	c, store := newTestClient(t)
+	ctx := context.Background()
+	bo := tikv.NewBackofferWithVars(ctx, 5000, nil)
+	keys := [][]byte{[]byte("b"), []byte("c")}
+
+	var plan Plan
+	ids, err := c.SplitRegions(ctx, keys, WithDryRun(&plan))
+	require.NoError(t, err)
+	require.Empty(t, ids)
+	require.Len(t, plan.Actions, 1)
+	require.Equal(t, OpSplit, plan.Actions[0].Op)
+	loc, err := store.GetRegionCache().LocateKey(bo, []byte("b"))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{loc.Region.GetID()}, plan.Actions[0].RegionIDs)
+
+	ids, err = c.SplitRegions(ctx, keys, WithScatter(true))
+	require.NoError(t, err)
+	require.Len(t, ids, 2)
+	store.GetRegionCache().InvalidateCachedRegion(loc.Region)
+	loc, err = store.GetRegionCache().LocateKey(bo, []byte("b"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("b"), loc.StartKey)
+	require.Equal(t, []byte("c"), loc.EndKey)
+
+	plan = Plan{}
+	_, err = c.DeleteRange(ctx, []byte("a"), []byte("d"), WithDryRun(&plan))
+	require.NoError(t, err)
+	require.Len(t, plan.Actions, 1)
+	require.Len(t, plan.Actions[0].RegionIDs, 3)
+}
+
+func TestDeleteRange(t *testing.T) {
+	c, store := newTestClient(t)
+	ctx := context.Background()
+
+	txn, err := store.Begin()
+	require.NoError(t, err)
+	for _, k := range []string{"a", "b", "c"} {
+		require.NoError(t, txn.Set([]byte(k), []byte(k)))
+	}
+	require.NoError(t, txn.Commit(ctx))
+
+	var plan Plan
+	_, err = c.DeleteRange(ctx, []byte("a"), []byte("c"), WithDryRun(&plan))
+	require.NoError(t, err)
+	require.Len(t, plan.Actions, 1)
+	ts, err := store.CurrentTimestamp(oracle.GlobalTxnScope)
+	require.NoError(t, err)
+	val, err := store.GetSnapshot(ts).Get(ctx, []byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("a"), val)
+
+	regions, err := c.DeleteRange(ctx, []byte("a"), []byte("c"), WithConcurrency(1))
+	require.NoError(t, err)
+	require.Equal(t, 1, regions)
+	ts, err = store.CurrentTimestamp(oracle.GlobalTxnScope)
+	require.NoError(t, err)
+	keys, err := store.GetSnapshot(ts).BatchGet(ctx, [][]byte{[]byte("a"), []byte("b"), []byte("c")})
+	require.NoError(t, err)
+	require.Equal(t, map[string][]byte{"c": []byte("c")}, keys)
+}
+
+func TestGCSafePoint(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+
+	var plan Plan
+	_, err := c.UpdateGCSafePoint(ctx, 100, WithDryRun(&plan))
+	require.NoError(t, err)
+	require.Equal(t, []Action{{Op: OpUpdateGCSafePoint, SafePoint: 100}}, plan.Actions)
+	sp, err := c.GetGCSafePoint(ctx, WithDryRun(&plan))
+	require.NoError(t, err)
+	require.Zero(t, sp)
+	require.Len(t, plan.Actions, 1)
+
+	sp, err = c.UpdateGCSafePoint(ctx, 100)
+	require.NoError(t, err)
+	require.Equal(t, uint64(100), sp)
+	sp, err = c.UpdateGCSafePoint(ctx, 50)
+	require.NoError(t, err)
+	require.Equal(t, uint64(100), sp)
+	sp, err = c.GetGCSafePoint(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(100), sp)
+
+	minSP, err := c.UpdateServiceGCSafePoint(ctx, "br", 60, 200)
+	require.NoError(t, err)
+	require.Equal(t, uint64(200), minSP)
+	minSP, err = c.UpdateServiceGCSafePoint(ctx, "cdc", 60, 300)
+	require.NoError(t, err)
+	require.Equal(t, uint64(200), minSP)
+}
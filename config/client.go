@@ -39,6 +39,7 @@ import (
 	"math"
 	"time"
 
+	"github.com/tikv/client-go/v2/util/redact"
 	"google.golang.org/grpc/encoding/gzip"
 )
 
@@ -73,8 +74,14 @@ type TiKVClient struct {
 	// After having pinged for keepalive check, the client waits for a duration of Timeout in seconds
 	// and if no activity is seen even after that the connection is closed.
 	GrpcKeepAliveTimeout float64 `toml:"grpc-keepalive-timeout" json:"grpc-keepalive-timeout"`
-	// GrpcCompressionType is the compression type for gRPC channel: none or gzip.
+	// GrpcCompressionType is the compression type for gRPC channel: none, gzip or zstd. The
+	// client falls back to gzip and then none for the stores rejecting the compressor.
 	GrpcCompressionType string `toml:"grpc-compression-type" json:"grpc-compression-type"`
+	// GrpcCompressionThreshold is the size in bytes the requests must exceed to be compressed,
+	// 0 compresses all the requests. As a batch commands stream compresses all or none of its
+	// messages, with a threshold the streams are not compressed, and the requests exceeding it
+	// are sent by unary calls instead.
+	GrpcCompressionThreshold uint64 `toml:"grpc-compression-threshold" json:"grpc-compression-threshold"`
 	// GrpcSharedBufferPool is the flag to control whether to share the buffer pool in the TiKV gRPC clients.
 	GrpcSharedBufferPool bool `toml:"grpc-shared-buffer-pool" json:"grpc-shared-buffer-pool"`
 	// GrpcInitialWindowSize is the value for initial window size on a stream.
@@ -84,6 +91,13 @@ type TiKVClient struct {
 	// CommitTimeout is the max time which command 'commit' will wait.
 	CommitTimeout string      `toml:"commit-timeout" json:"commit-timeout"`
 	AsyncCommit   AsyncCommit `toml:"async-commit" json:"async-commit"`
+	OnePC         OnePC       `toml:"one-pc" json:"one-pc"`
+	RedactLog     RedactLog   `toml:"redact-log" json:"redact-log"`
+	// StaleReadFallback is the config for routing the stale reads to the leaders
+	// instead of the followers lagging behind.
+	StaleReadFallback StaleReadFallback `toml:"stale-read-fallback" json:"stale-read-fallback"`
+	// CommitSizeLimit is the config for checking the size of the mutations before prewrite.
+	CommitSizeLimit CommitSizeLimit `toml:"commit-size-limit" json:"commit-size-limit"`
 
 	// BatchPolicy is the policy for batching requests.
 	BatchPolicy string `toml:"batch-policy" json:"batch-policy"`
@@ -134,6 +148,52 @@ type AsyncCommit struct {
 	AllowedClockDrift time.Duration `toml:"allowed-clock-drift" json:"allowed-clock-drift"`
 }
 
+// OnePC is the config for the 1PC feature. The switch to enable it is Config.Enable1PC
+// or KVTxn.SetEnable1PC. Besides the limits, 1PC is used only if all the keys are
+// prewritten by a single request, that is, they are in one region and fit in a batch.
+type OnePC struct {
+	// Use 1PC only if the number of keys does not exceed KeysLimit. Zero means no limit.
+	KeysLimit uint `toml:"keys-limit" json:"keys-limit"`
+	// Use 1PC only if the total size of the keys and values does not exceed
+	// TotalSizeLimit. Zero means no limit.
+	TotalSizeLimit uint64 `toml:"total-size-limit" json:"total-size-limit"`
+}
+
+// RedactLog is the config for redacting the keys and values in the errors, logs and
+// metrics labels emitted by the client.
+type RedactLog struct {
+	// Mode is one of off, mark, hash and truncate. See the util/redact package for the
+	// formats.
+	Mode string `toml:"mode" json:"mode"`
+	// TruncateLen is the number of the leading bytes kept in truncate mode.
+	TruncateLen uint `toml:"truncate-len" json:"truncate-len"`
+}
+
+// StaleReadFallback is the config for the stale reads falling back to the leaders.
+// When a store keeps returning DataIsNotReady to the stale reads, it's regarded
+// as lagging behind for a cooldown period, during which the stale reads choosing
+// it are sent to the leaders instead.
+type StaleReadFallback struct {
+	// ErrorBudget is the number of the consecutive DataIsNotReady errors a store may
+	// return before it's regarded as lagging behind. Zero disables the fallback.
+	ErrorBudget uint `toml:"error-budget" json:"error-budget"`
+	// Cooldown is how long the stale reads avoid a store lagging behind.
+	Cooldown time.Duration `toml:"cooldown" json:"cooldown"`
+}
+
+// CommitSizeLimit is the config for checking the size of the mutations of a transaction
+// before prewrite, so the transactions TiKV can't accept fail early with
+// tikverr.ErrCommitSizeLimit instead of failing in 2PC. Zero means no limit.
+type CommitSizeLimit struct {
+	// MaxKeySize limits the size of a key.
+	MaxKeySize uint64 `toml:"max-key-size" json:"max-key-size"`
+	// MaxEntrySize limits the size of a key and its value, which must fit in a raft entry.
+	MaxEntrySize uint64 `toml:"max-entry-size" json:"max-entry-size"`
+	// MaxTxnSize limits the total size of the keys and values, 1 TiB by default,
+	// the largest transaction TiDB allows.
+	MaxTxnSize uint64 `toml:"max-txn-size" json:"max-txn-size"`
+}
+
 // CoprocessorCache is the config for coprocessor cache.
 type CoprocessorCache struct {
 	// The capacity in MB of the cache. Zero means disable coprocessor cache.
@@ -167,6 +227,19 @@ func DefaultTiKVClient() TiKVClient {
 			SafeWindow:        2 * time.Second,
 			AllowedClockDrift: 500 * time.Millisecond,
 		},
+		RedactLog: RedactLog{
+			Mode:        redact.ModeOff,
+			TruncateLen: redact.DefTruncateLen,
+		},
+		StaleReadFallback: StaleReadFallback{
+			ErrorBudget: 3,
+			Cooldown:    10 * time.Second,
+		},
+		CommitSizeLimit: CommitSizeLimit{
+			// The default raft-entry-max-size of TiKV.
+			MaxEntrySize: 8 * 1024 * 1024,
+			MaxTxnSize:   1 << 40,
+		},
 
 		BatchPolicy:       DefBatchPolicy,
 		MaxBatchSize:      128,
@@ -201,12 +274,15 @@ func (config *TiKVClient) Valid() error {
 	if config.GrpcConnectionCount == 0 {
 		return fmt.Errorf("grpc-connection-count should be greater than 0")
 	}
-	if config.GrpcCompressionType != "none" && config.GrpcCompressionType != gzip.Name {
-		return fmt.Errorf("grpc-compression-type should be none or %s, but got %s", gzip.Name, config.GrpcCompressionType)
+	if config.GrpcCompressionType != "none" && config.GrpcCompressionType != gzip.Name && config.GrpcCompressionType != "zstd" {
+		return fmt.Errorf("grpc-compression-type should be none, %s or zstd, but got %s", gzip.Name, config.GrpcCompressionType)
 	}
 	if config.GetGrpcKeepAliveTimeout() < time.Millisecond*50 {
 		return fmt.Errorf("grpc-keepalive-timeout should be at least 0.05, but got %f", config.GrpcKeepAliveTimeout)
 	}
+	if !redact.ValidMode(config.RedactLog.Mode) {
+		return fmt.Errorf("redact-log.mode should be one of off, mark, hash and truncate, but got %s", config.RedactLog.Mode)
+	}
 	return nil
 }
 
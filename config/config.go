@@ -44,6 +44,7 @@ import (
 	"github.com/tikv/client-go/v2/internal/logutil"
 	"github.com/tikv/client-go/v2/oracle"
 	"github.com/tikv/client-go/v2/util"
+	"github.com/tikv/client-go/v2/util/redact"
 	"go.uber.org/zap"
 )
 
@@ -153,6 +154,7 @@ func GetGlobalConfig() *Config {
 // StoreGlobalConfig stores a new config to the globalConf. It mostly uses in the test to avoid some data races.
 func StoreGlobalConfig(config *Config) {
 	globalConf.Store(config)
+	redact.Set(config.TiKVClient.RedactLog.Mode, config.TiKVClient.RedactLog.TruncateLen)
 }
 
 // UpdateGlobal updates the global config, and provide a restore function that can be used to restore to the original.
@@ -72,6 +72,9 @@ type Backoffer struct {
 	backoffSleepMS map[string]int
 	backoffTimes   map[string]int
 	parent         *Backoffer
+
+	// switchReplica is set when the Policy decides to switch the replica.
+	switchReplica bool
 }
 
 type txnStartCtxKeyType struct{}
@@ -137,12 +140,16 @@ func (b *Backoffer) BackoffWithCfgAndMaxSleep(cfg *Config, maxSleepMs int, err e
 	}
 	select {
 	case <-b.ctx.Done():
-		return errors.WithStack(err)
+		return tikverr.AnnotateDeadlineExceeded(b.ctx, errors.WithStack(err))
 	default:
 	}
 	if b.noop {
 		return err
 	}
+	decision := b.decide(cfg, err)
+	if decision == DecisionFailFast {
+		return errors.WithStack(err)
+	}
 	maxBackoffTimeExceeded := (b.totalSleep - b.excludedSleep) >= b.maxSleep
 	maxExcludedTimeExceeded := false
 	if maxLimit, ok := isSleepExcluded[cfg.name]; ok {
@@ -192,9 +199,21 @@ func (b *Backoffer) BackoffWithCfgAndMaxSleep(cfg *Config, maxSleepMs int, err e
 		f = cfg.createBackoffFn(b.vars)
 		b.fn[cfg.name] = f
 	}
-	realSleep := f(b.ctx, maxSleepMs)
-	if cfg.metric != nil {
-		(*cfg.metric).Observe(float64(realSleep) / 1000)
+	realSleep := 0
+	switch decision {
+	case DecisionRetryNow:
+	case DecisionSwitchReplica:
+		b.switchReplica = true
+	default:
+		realSleep = f(b.ctx, maxSleepMs)
+		if q := globalRetryQueue.Load(); q != nil {
+			start := time.Now()
+			q.wait(b.ctx)
+			realSleep += int(time.Since(start) / time.Millisecond)
+		}
+		if cfg.metric != nil {
+			(*cfg.metric).Observe(float64(realSleep) / 1000)
+		}
 	}
 
 	b.totalSleep += realSleep
@@ -209,6 +228,7 @@ func (b *Backoffer) BackoffWithCfgAndMaxSleep(cfg *Config, maxSleepMs int, err e
 		b.backoffTimes = make(map[string]int)
 	}
 	b.backoffTimes[cfg.name]++
+	util.DeadlineAuditFromContext(b.ctx).Add(util.DeadlineStageBackoff, time.Duration(realSleep)*time.Millisecond)
 
 	stmtExec := b.ctx.Value(util.ExecDetailsKey)
 	if stmtExec != nil {
@@ -37,9 +37,13 @@ package retry
 import (
 	"context"
 	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/util"
 )
 
 func TestBackoffWithMax(t *testing.T) {
@@ -111,3 +115,115 @@ func TestBackoffWithMaxExcludedExceed(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Greater(t, b.excludedSleep, b.maxSleep)
 }
+
+func TestBackoffPolicy(t *testing.T) {
+	var infos []*RetryInfo
+	policy := PolicyFunc(func(ctx context.Context, info *RetryInfo) Decision {
+		infos = append(infos, info)
+		switch len(info.History) {
+		case 0:
+			return DecisionRetryNow
+		case 1:
+			return DecisionSwitchReplica
+		case 2:
+			return DecisionDefault
+		default:
+			return DecisionFailFast
+		}
+	})
+	b := NewBackofferWithVars(WithPolicy(context.Background(), policy), 2000, nil)
+	assert.Nil(t, b.Backoff(BoRegionMiss, errors.New("region miss")))
+	assert.Zero(t, b.GetTotalSleep())
+	assert.False(t, b.TakeReplicaSwitch())
+
+	assert.Nil(t, b.Backoff(BoTiKVServerBusy, errors.New("server is busy")))
+	assert.Zero(t, b.GetTotalSleep())
+	assert.True(t, b.TakeReplicaSwitch())
+	assert.False(t, b.TakeReplicaSwitch())
+
+	assert.Nil(t, b.BackoffWithMaxSleepTxnLockFast(5, errors.New("txn lock")))
+	assert.Equal(t, 5, b.GetTotalSleep())
+
+	err := b.Backoff(BoRegionMiss, errors.New("fail fast"))
+	assert.EqualError(t, err, "fail fast")
+	assert.Equal(t, 3, b.GetTotalBackoffTimes())
+
+	assert.Len(t, infos, 4)
+	last := infos[3]
+	assert.Equal(t, BoRegionMiss, last.Config)
+	assert.Equal(t, 5, last.TotalSleepMs)
+	assert.Equal(t, []string{"regionMiss", "tikvServerBusy", "txnLockFast"}, []string{last.History[0].Type, last.History[1].Type, last.History[2].Type})
+
+	// The policy set by SetPolicy is used without the one in the context.
+	SetPolicy(PolicyFunc(func(context.Context, *RetryInfo) Decision { return DecisionFailFast }))
+	defer SetPolicy(nil)
+	b = NewBackofferWithVars(context.Background(), 2000, nil)
+	assert.NotNil(t, b.Backoff(BoRegionMiss, errors.New("region miss")))
+}
+
+func TestRetryQueue(t *testing.T) {
+	q := &retryQueue{interval: 20 * time.Millisecond}
+	// The first retry is released without waiting.
+	start := time.Now()
+	q.wait(context.Background())
+	assert.Less(t, time.Since(start), 10*time.Millisecond)
+
+	var (
+		mu       sync.Mutex
+		released []string
+		wg       sync.WaitGroup
+	)
+	waitAs := func(name string, ctx context.Context) {
+		defer wg.Done()
+		q.wait(ctx)
+		mu.Lock()
+		released = append(released, name)
+		mu.Unlock()
+	}
+	deadlineCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	canceledCtx, cancelWaiter := context.WithCancel(context.Background())
+	wg.Add(4)
+	go waitAs("low", context.Background())
+	time.Sleep(5 * time.Millisecond)
+	go waitAs("canceled", canceledCtx)
+	go waitAs("deadline", deadlineCtx)
+	go waitAs("high", WithRetryPriority(context.Background(), 1))
+	time.Sleep(5 * time.Millisecond)
+	cancelWaiter()
+	wg.Wait()
+	assert.Equal(t, []string{"canceled", "high", "deadline", "low"}, released)
+	assert.GreaterOrEqual(t, time.Since(start), 60*time.Millisecond)
+
+	EnableRetryQueue(100)
+	defer EnableRetryQueue(0)
+	b := NewBackofferWithVars(context.Background(), 2000, nil)
+	assert.Nil(t, b.BackoffWithMaxSleepTxnLockFast(5, errors.New("txn lock")))
+}
+
+func TestBackoffDeadlineAudit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(util.ContextWithDeadlineAudit(context.Background()), 50*time.Millisecond)
+	defer cancel()
+	b := NewBackofferWithVars(ctx, 10000, nil)
+	var err error
+	for err == nil {
+		err = b.Backoff(BoRegionMiss, errors.New("region miss"))
+	}
+	var deadlineErr *tikverr.ErrDeadlineExceeded
+	assert.ErrorAs(t, err, &deadlineErr)
+	assert.Equal(t, "region miss", errors.Unwrap(errors.Unwrap(deadlineErr)).Error())
+	breakdown := deadlineErr.Breakdown
+	assert.InDelta(t, 50*time.Millisecond, breakdown.Budget, float64(5*time.Millisecond))
+	assert.Greater(t, breakdown.Backoff, time.Duration(0))
+	assert.Equal(t, time.Duration(0), breakdown.RPC)
+	assert.Contains(t, err.Error(), "deadline breakdown")
+
+	// The errors of the backoffers without the audit are kept as is.
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	b = NewBackofferWithVars(ctx, 10000, nil)
+	for err = nil; err == nil; {
+		err = b.Backoff(BoRegionMiss, errors.New("region miss"))
+	}
+	assert.False(t, errors.As(err, &deadlineErr))
+}
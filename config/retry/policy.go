@@ -0,0 +1,134 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Decision is the decision of a Policy on a retry.
+type Decision int
+
+const (
+	// DecisionDefault backs off by the Config and retries, until the max
+	// sleep of the Backoffer is exceeded.
+	DecisionDefault Decision = iota
+	// DecisionRetryNow retries without sleeping.
+	DecisionRetryNow
+	// DecisionSwitchReplica retries without sleeping and avoids the replica
+	// the request was sent to, if the request is sent to a region. If no other
+	// replica is available, the request fails with a region error.
+	DecisionSwitchReplica
+	// DecisionFailFast stops retrying and returns the error.
+	DecisionFailFast
+)
+
+// Attempt is a backoff done by the Backoffer.
+type Attempt struct {
+	// Type is the name of the Config of the backoff.
+	Type string
+	// Err is the error caused the backoff.
+	Err error
+}
+
+// RetryInfo describes a retry for a Policy.
+type RetryInfo struct {
+	// Config is the backoff type of the retry.
+	Config *Config
+	// Err is the error causes the retry.
+	Err error
+	// History is the previous backoffs of the Backoffer, oldest first.
+	History []Attempt
+	// TotalSleepMs is the time already slept by the Backoffer.
+	TotalSleepMs int
+	// MaxSleepMs is the max sleep of the Backoffer.
+	MaxSleepMs int
+}
+
+// Policy is consulted before every retry of the Backoffers and may override
+// the default decision. A policy returning DecisionRetryNow or
+// DecisionSwitchReplica must bound the retries itself, e.g. by the length of
+// History, as they don't consume the sleep budget of the Backoffer.
+type Policy interface {
+	Decide(ctx context.Context, info *RetryInfo) Decision
+}
+
+// PolicyFunc is a Policy of a function.
+type PolicyFunc func(ctx context.Context, info *RetryInfo) Decision
+
+// Decide implements Policy.
+func (f PolicyFunc) Decide(ctx context.Context, info *RetryInfo) Decision {
+	return f(ctx, info)
+}
+
+var globalPolicy atomic.Pointer[Policy]
+
+// SetPolicy sets the policy consulted by all the Backoffers whose contexts
+// don't carry one, see WithPolicy. Pass nil to remove it.
+func SetPolicy(p Policy) {
+	if p == nil {
+		globalPolicy.Store(nil)
+		return
+	}
+	globalPolicy.Store(&p)
+}
+
+type policyCtxKeyType struct{}
+
+var policyCtxKey = policyCtxKeyType{}
+
+// WithPolicy returns a context making the Backoffers created with it consult
+// p instead of the one set by SetPolicy.
+func WithPolicy(ctx context.Context, p Policy) context.Context {
+	return context.WithValue(ctx, policyCtxKey, p)
+}
+
+func getPolicy(ctx context.Context) Policy {
+	if p, ok := ctx.Value(policyCtxKey).(Policy); ok {
+		return p
+	}
+	if p := globalPolicy.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// decide consults the policy of the Backoffer on the retry.
+func (b *Backoffer) decide(cfg *Config, err error) Decision {
+	p := getPolicy(b.ctx)
+	if p == nil {
+		return DecisionDefault
+	}
+	history := make([]Attempt, 0, len(b.configs))
+	for i, c := range b.configs {
+		history = append(history, Attempt{Type: c.String(), Err: b.errors[i]})
+	}
+	return p.Decide(b.ctx, &RetryInfo{
+		Config:       cfg,
+		Err:          err,
+		History:      history,
+		TotalSleepMs: b.totalSleep,
+		MaxSleepMs:   b.maxSleep,
+	})
+}
+
+// TakeReplicaSwitch reports whether the policy has decided to switch the
+// replica on the last retry, and clears it.
+func (b *Backoffer) TakeReplicaSwitch() bool {
+	switchReplica := b.switchReplica
+	b.switchReplica = false
+	return switchReplica
+}
@@ -0,0 +1,164 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var globalRetryQueue atomic.Pointer[retryQueue]
+
+// EnableRetryQueue makes the Backoffers queue up after sleeping, and releases
+// at most rate retries per second from the queue, so that the requests backing
+// off at the same time, e.g. when a leader is down, don't retry all at once
+// against the recovering store. The retries of higher priorities are released
+// first, see WithRetryPriority, then the ones with earlier deadlines of their
+// contexts. The time spent in the queue counts towards the max sleep of the
+// Backoffers. A non-positive rate disables the queue.
+func EnableRetryQueue(rate int) {
+	if rate <= 0 {
+		globalRetryQueue.Store(nil)
+		return
+	}
+	globalRetryQueue.Store(&retryQueue{interval: time.Second / time.Duration(rate)})
+}
+
+type retryPriorityCtxKeyType struct{}
+
+var retryPriorityCtxKey = retryPriorityCtxKeyType{}
+
+// WithRetryPriority returns a context whose retries are released from the
+// retry queue before the ones of lower priorities, see EnableRetryQueue.
+func WithRetryPriority(ctx context.Context, priority uint64) context.Context {
+	return context.WithValue(ctx, retryPriorityCtxKey, priority)
+}
+
+type retryWaiter struct {
+	priority uint64
+	deadline time.Time
+	seq      uint64
+	ready    chan struct{}
+	// index is the index in the heap, or -1 if it's popped.
+	index int
+}
+
+// before reports whether w should be released before o.
+func (w *retryWaiter) before(o *retryWaiter) bool {
+	if w.priority != o.priority {
+		return w.priority > o.priority
+	}
+	if !w.deadline.Equal(o.deadline) {
+		if w.deadline.IsZero() || o.deadline.IsZero() {
+			return o.deadline.IsZero()
+		}
+		return w.deadline.Before(o.deadline)
+	}
+	return w.seq < o.seq
+}
+
+// retryHeap implements heap.Interface.
+type retryHeap []*retryWaiter
+
+func (h retryHeap) Len() int           { return len(h) }
+func (h retryHeap) Less(i, j int) bool { return h[i].before(h[j]) }
+
+func (h retryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+
+func (h *retryHeap) Push(x interface{}) {
+	w := x.(*retryWaiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *retryHeap) Pop() interface{} {
+	old := *h
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	w.index = -1
+	return w
+}
+
+type retryQueue struct {
+	interval time.Duration
+
+	mu      sync.Mutex
+	waiters retryHeap
+	seq     uint64
+	// next is the earliest time to release the next retry.
+	next time.Time
+	// dispatching is whether the goroutine releasing the waiters is running.
+	dispatching bool
+}
+
+// wait waits for the turn to retry, or until ctx is done.
+func (q *retryQueue) wait(ctx context.Context) {
+	q.mu.Lock()
+	now := time.Now()
+	if q.waiters.Len() == 0 && !now.Before(q.next) {
+		q.next = now.Add(q.interval)
+		q.mu.Unlock()
+		return
+	}
+	q.seq++
+	w := &retryWaiter{seq: q.seq, ready: make(chan struct{})}
+	w.priority, _ = ctx.Value(retryPriorityCtxKey).(uint64)
+	w.deadline, _ = ctx.Deadline()
+	heap.Push(&q.waiters, w)
+	if !q.dispatching {
+		q.dispatching = true
+		go q.dispatch()
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-w.ready:
+	case <-ctx.Done():
+		q.mu.Lock()
+		if w.index >= 0 {
+			heap.Remove(&q.waiters, w.index)
+		}
+		q.mu.Unlock()
+	}
+}
+
+// dispatch releases the waiters one per interval until the queue is empty.
+func (q *retryQueue) dispatch() {
+	for {
+		q.mu.Lock()
+		if q.waiters.Len() == 0 {
+			q.dispatching = false
+			q.mu.Unlock()
+			return
+		}
+		now := time.Now()
+		if wait := q.next.Sub(now); wait > 0 {
+			q.mu.Unlock()
+			time.Sleep(wait)
+			continue
+		}
+		w := heap.Pop(&q.waiters).(*retryWaiter)
+		q.next = now.Add(q.interval)
+		q.mu.Unlock()
+		close(w.ready)
+	}
+}
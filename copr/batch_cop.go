@@ -0,0 +1,301 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package copr
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/pingcap/kvproto/pkg/coprocessor"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/tikv"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/util/redact"
+	"go.uber.org/zap"
+)
+
+const sendBackoff = 40000
+
+// Result is a response of a coprocessor request.
+type Result struct {
+	Data        []byte
+	ExecDetails *kvrpcpb.ExecDetails
+	// StoreAddr is the address of the store returning the result.
+	StoreAddr string
+	// FromTiKV is true if the request falls back to TiKV.
+	FromTiKV bool
+}
+
+type resultOrErr struct {
+	res *Result
+	err error
+}
+
+// Iterator iterates over the results of a coprocessor request. The results of
+// different stores come in no particular order.
+type Iterator struct {
+	ch     chan resultOrErr
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	err    error
+}
+
+// Next returns the next result, or nil if all results have been returned.
+func (it *Iterator) Next(ctx context.Context) (*Result, error) {
+	if it.err != nil {
+		return nil, it.err
+	}
+	select {
+	case r, ok := <-it.ch:
+		if !ok {
+			return nil, nil
+		}
+		if r.err != nil {
+			it.err = r.err
+			it.Close()
+			return nil, r.err
+		}
+		return r.res, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close stops the request. It must be called if the results are not consumed
+// to the end.
+func (it *Iterator) Close() {
+	it.cancel()
+	// Drain the channel so that the workers can exit.
+	go func() {
+		for range it.ch {
+		}
+	}()
+}
+
+func (it *Iterator) send(ctx context.Context, r resultOrErr) bool {
+	select {
+	case it.ch <- r:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// SendBatchCop reads the ranges of the request from TiFlash by batch
+// coprocessor requests, one stream per store. If some regions have no TiFlash
+// peer available, it returns ErrNoTiFlashReplica, or reads all the ranges
+// from TiKV by normal coprocessor requests if req.FallbackToTiKV is set.
+func (c *Client) SendBatchCop(ctx context.Context, req *Request) (*Iterator, error) {
+	bo := tikv.NewBackofferWithVars(ctx, sendBackoff, nil)
+	tasks, err := c.BuildStoreTasks(bo, req.Ranges, req.labelFilter())
+	if errors.Is(err, ErrNoTiFlashReplica) && req.FallbackToTiKV {
+		logutil.Logger(ctx).Info("fall back to TiKV for batch cop", zap.Error(err))
+		return c.sendCop(ctx, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	it := &Iterator{ch: make(chan resultOrErr, req.concurrency()), cancel: cancel}
+	taskCh := make(chan *StoreTask, len(tasks))
+	for _, task := range tasks {
+		taskCh <- task
+	}
+	close(taskCh)
+	for i := 0; i < req.concurrency() && i < len(tasks); i++ {
+		it.wg.Add(1)
+		go func() {
+			defer it.wg.Done()
+			for task := range taskCh {
+				bo := tikv.NewBackofferWithVars(ctx, sendBackoff, nil)
+				if err := c.handleBatchCopTask(ctx, bo, it, req, task); err != nil {
+					it.send(ctx, resultOrErr{err: err})
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		it.wg.Wait()
+		close(it.ch)
+	}()
+	return it, nil
+}
+
+func (c *Client) handleBatchCopTask(ctx context.Context, bo *tikv.Backoffer, it *Iterator, req *Request, task *StoreTask) error {
+	rpcReq := tikvrpc.NewRequest(tikvrpc.CmdBatchCop, &coprocessor.BatchRequest{
+		Tp:        req.Tp,
+		Data:      req.Data,
+		Regions:   task.regionsPB(),
+		StartTs:   req.StartTS,
+		SchemaVer: req.SchemaVer,
+	}, kvrpcpb.Context{})
+	resp, err := c.store.GetTiKVClient().SendRequest(ctx, task.StoreAddr, rpcReq, req.timeout())
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// The store may be down, read its regions from other stores.
+		return c.retryRegions(ctx, bo, it, req, task, task.Regions, err)
+	}
+	stream, ok := resp.Resp.(*tikvrpc.BatchCopStreamResponse)
+	if !ok || stream == nil {
+		return errors.Errorf("copr: unexpected response %T of batch cop from store %s", resp.Resp, task.StoreAddr)
+	}
+	defer stream.Close()
+
+	var retry []RegionInfo
+	handle := func(batchResp *coprocessor.BatchResponse) error {
+		if otherErr := batchResp.GetOtherError(); otherErr != "" {
+			return errors.Errorf("copr: batch cop on store %s: %s", task.StoreAddr, otherErr)
+		}
+		for _, r := range batchResp.GetRetryRegions() {
+			for _, info := range task.Regions {
+				if info.Region.GetID() == r.GetId() {
+					retry = append(retry, info)
+				}
+			}
+		}
+		if len(batchResp.Data) == 0 && batchResp.GetExecDetails() == nil {
+			return nil
+		}
+		res := &Result{Data: batchResp.Data, ExecDetails: batchResp.GetExecDetails(), StoreAddr: task.StoreAddr}
+		if !it.send(ctx, resultOrErr{res: res}) {
+			return ctx.Err()
+		}
+		return nil
+	}
+	// The first response is received when the stream is created.
+	if stream.BatchResponse != nil {
+		if err = handle(stream.BatchResponse); err != nil {
+			return err
+		}
+	}
+	for stream.Tikv_BatchCoprocessorClient != nil {
+		batchResp, err := stream.Recv()
+		if errors.Cause(err) == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.WithMessagef(err, "copr: receive batch cop response from store %s", task.StoreAddr)
+		}
+		if err = handle(batchResp); err != nil {
+			return err
+		}
+	}
+	if len(retry) == 0 {
+		return nil
+	}
+	return c.retryRegions(ctx, bo, it, req, task, retry, errors.Errorf("store %s asks to retry %d regions", task.StoreAddr, len(retry)))
+}
+
+// retryRegions reads the ranges of the regions again after reloading them.
+func (c *Client) retryRegions(ctx context.Context, bo *tikv.Backoffer, it *Iterator, req *Request, task *StoreTask, regions []RegionInfo, cause error) error {
+	logutil.Logger(ctx).Info("retry batch cop regions",
+		zap.String("store", task.StoreAddr), zap.Int("regions", len(regions)), zap.Error(cause))
+	var ranges []kv.KeyRange
+	for _, r := range regions {
+		c.store.GetRegionCache().InvalidateCachedRegion(r.Region)
+		ranges = append(ranges, r.Ranges...)
+	}
+	if err := bo.Backoff(tikv.BoTiFlashRPC(), cause); err != nil {
+		return err
+	}
+	tasks, err := c.BuildStoreTasks(bo, ranges, req.labelFilter())
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		if err = c.handleBatchCopTask(ctx, bo, it, req, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendCop reads the ranges from the TiKV leaders by normal coprocessor
+// requests, one region at a time. Locks are not resolved, a locked key fails
+// the request.
+func (c *Client) sendCop(ctx context.Context, req *Request) (*Iterator, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	it := &Iterator{ch: make(chan resultOrErr, req.concurrency()), cancel: cancel}
+	it.wg.Add(1)
+	go func() {
+		defer it.wg.Done()
+		bo := tikv.NewBackofferWithVars(ctx, sendBackoff, nil)
+		if err := c.handleCopRanges(ctx, bo, it, req, req.Ranges); err != nil {
+			it.send(ctx, resultOrErr{err: err})
+		}
+	}()
+	go func() {
+		it.wg.Wait()
+		close(it.ch)
+	}()
+	return it, nil
+}
+
+func (c *Client) handleCopRanges(ctx context.Context, bo *tikv.Backoffer, it *Iterator, req *Request, ranges []kv.KeyRange) error {
+	regions, err := c.splitRegions(bo, ranges)
+	if err != nil {
+		return err
+	}
+	sender := tikv.NewRegionRequestSender(c.store.GetRegionCache(), c.store.GetTiKVClient(), c.store.GetOracle())
+	for _, r := range regions {
+		rpcReq := tikvrpc.NewRequest(tikvrpc.CmdCop, &coprocessor.Request{
+			Tp:        req.Tp,
+			Data:      req.Data,
+			StartTs:   req.StartTS,
+			SchemaVer: req.SchemaVer,
+			Ranges:    toPBRanges(r.Ranges),
+		}, kvrpcpb.Context{})
+		resp, _, err := sender.SendReq(bo, rpcReq, r.Region, req.timeout())
+		if err != nil {
+			return err
+		}
+		regionErr, err := resp.GetRegionError()
+		if err != nil {
+			return err
+		}
+		if regionErr != nil {
+			if err = bo.Backoff(tikv.BoRegionMiss(), errors.New(regionErr.String())); err != nil {
+				return err
+			}
+			if err = c.handleCopRanges(ctx, bo, it, req, r.Ranges); err != nil {
+				return err
+			}
+			continue
+		}
+		copResp, ok := resp.Resp.(*coprocessor.Response)
+		if !ok {
+			return errors.Errorf("copr: unexpected response %T of cop", resp.Resp)
+		}
+		if lock := copResp.GetLocked(); lock != nil {
+			return errors.Errorf("copr: key %s is locked by txn %d", redact.Key(lock.GetKey()), lock.GetLockVersion())
+		}
+		if otherErr := copResp.GetOtherError(); otherErr != "" {
+			return errors.Errorf("copr: cop on region %d: %s", r.Region.GetID(), otherErr)
+		}
+		res := &Result{Data: copResp.Data, ExecDetails: copResp.GetExecDetails(), StoreAddr: sender.GetStoreAddr(), FromTiKV: true}
+		if !it.send(ctx, resultOrErr{res: res}) {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package copr dispatches coprocessor requests to TiFlash: batch coprocessor
+// requests, which read the regions of a store in one stream, and MPP tasks.
+// The content of the requests, e.g. the DAG or MPP plan, is opaque to this
+// package.
+package copr
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/coprocessor"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/tikv"
+)
+
+const (
+	defaultConcurrency = 4
+	defaultTimeout     = time.Minute
+	// buildTasksRetry is the times to reload the regions without TiFlash
+	// peers before returning ErrNoTiFlashReplica.
+	buildTasksRetry = 3
+)
+
+// ErrNoTiFlashReplica is returned if a region of the request has no TiFlash
+// peer available, and the request does not fall back to TiKV.
+var ErrNoTiFlashReplica = errors.New("copr: no available TiFlash replica")
+
+// Request is a coprocessor request reading key ranges.
+type Request struct {
+	// Tp is the type of the request, e.g. DAG, and Data is the encoded
+	// request of the type.
+	Tp        int64
+	Data      []byte
+	StartTS   uint64
+	SchemaVer int64
+	// Ranges are the sorted and non-overlapping key ranges to read.
+	Ranges []kv.KeyRange
+	// LabelFilter selects the TiFlash stores by their labels, the default is
+	// tikv.LabelFilterNoTiFlashWriteNode.
+	LabelFilter tikv.LabelFilter
+	// FallbackToTiKV makes the request read the TiKV leaders by normal
+	// coprocessor requests if any region has no TiFlash peer available.
+	FallbackToTiKV bool
+	// Concurrency is the number of stores read at the same time, the default
+	// is 4.
+	Concurrency int
+	// Timeout is the timeout of every RPC, the default is one minute.
+	Timeout time.Duration
+}
+
+func (req *Request) labelFilter() tikv.LabelFilter {
+	if req.LabelFilter == nil {
+		return tikv.LabelFilterNoTiFlashWriteNode
+	}
+	return req.LabelFilter
+}
+
+func (req *Request) concurrency() int {
+	if req.Concurrency <= 0 {
+		return defaultConcurrency
+	}
+	return req.Concurrency
+}
+
+func (req *Request) timeout() time.Duration {
+	if req.Timeout <= 0 {
+		return defaultTimeout
+	}
+	return req.Timeout
+}
+
+// RegionInfo is a region and the ranges to read in it.
+type RegionInfo struct {
+	Region tikv.RegionVerID
+	Ranges []kv.KeyRange
+}
+
+func (r *RegionInfo) toPB() *coprocessor.RegionInfo {
+	return &coprocessor.RegionInfo{
+		RegionId:    r.Region.GetID(),
+		RegionEpoch: &metapb.RegionEpoch{ConfVer: r.Region.GetConfVer(), Version: r.Region.GetVer()},
+		Ranges:      toPBRanges(r.Ranges),
+	}
+}
+
+func toPBRanges(ranges []kv.KeyRange) []*coprocessor.KeyRange {
+	res := make([]*coprocessor.KeyRange, 0, len(ranges))
+	for _, r := range ranges {
+		res = append(res, &coprocessor.KeyRange{Start: r.StartKey, End: r.EndKey})
+	}
+	return res
+}
+
+// StoreTask is the regions read from one TiFlash store, by a batch
+// coprocessor request or an MPP task.
+type StoreTask struct {
+	StoreID   uint64
+	StoreAddr string
+	Regions   []RegionInfo
+}
+
+func (t *StoreTask) regionsPB() []*coprocessor.RegionInfo {
+	res := make([]*coprocessor.RegionInfo, 0, len(t.Regions))
+	for i := range t.Regions {
+		res = append(res, t.Regions[i].toPB())
+	}
+	return res
+}
+
+// Client sends coprocessor requests to the TiFlash stores of a KVStore.
+type Client struct {
+	store *tikv.KVStore
+}
+
+// NewClient creates a Client.
+func NewClient(store *tikv.KVStore) *Client {
+	return &Client{store: store}
+}
+
+// splitRegions splits the ranges by the regions they belong to.
+func (c *Client) splitRegions(bo *tikv.Backoffer, ranges []kv.KeyRange) ([]RegionInfo, error) {
+	var regions []RegionInfo
+	for _, r := range ranges {
+		locs, err := c.store.GetRegionCache().LocateKeyRange(bo, r.StartKey, r.EndKey)
+		if err != nil {
+			return nil, err
+		}
+		for _, loc := range locs {
+			sub := kv.KeyRange{StartKey: r.StartKey, EndKey: r.EndKey}
+			if bytes.Compare(loc.StartKey, sub.StartKey) > 0 {
+				sub.StartKey = loc.StartKey
+			}
+			if len(loc.EndKey) > 0 && (len(sub.EndKey) == 0 || bytes.Compare(loc.EndKey, sub.EndKey) < 0) {
+				sub.EndKey = loc.EndKey
+			}
+			if n := len(regions); n > 0 && regions[n-1].Region == loc.Region {
+				regions[n-1].Ranges = append(regions[n-1].Ranges, sub)
+			} else {
+				regions = append(regions, RegionInfo{Region: loc.Region, Ranges: []kv.KeyRange{sub}})
+			}
+		}
+	}
+	return regions, nil
+}
+
+// BuildStoreTasks groups the regions of the ranges by the TiFlash stores
+// serving them, which are selected by the label filter. It returns
+// ErrNoTiFlashReplica if a region has no TiFlash peer available.
+func (c *Client) BuildStoreTasks(bo *tikv.Backoffer, ranges []kv.KeyRange, labelFilter tikv.LabelFilter) ([]*StoreTask, error) {
+	if labelFilter == nil {
+		labelFilter = tikv.LabelFilterNoTiFlashWriteNode
+	}
+	for retry := 0; ; retry++ {
+		tasks, missing, err := c.buildStoreTasks(bo, ranges, labelFilter)
+		if err != nil || missing == nil {
+			return tasks, err
+		}
+		err = errors.Wrapf(ErrNoTiFlashReplica, "region %d", missing.GetID())
+		if retry >= buildTasksRetry {
+			return nil, err
+		}
+		// The region cache may be stale, the invalidated regions are
+		// reloaded in the next round.
+		if err = bo.Backoff(tikv.BoRegionMiss(), err); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) buildStoreTasks(bo *tikv.Backoffer, ranges []kv.KeyRange, labelFilter tikv.LabelFilter) ([]*StoreTask, *tikv.RegionVerID, error) {
+	regions, err := c.splitRegions(bo, ranges)
+	if err != nil {
+		return nil, nil, err
+	}
+	var tasks []*StoreTask
+	storeTasks := make(map[uint64]*StoreTask)
+	for _, r := range regions {
+		rpcCtx, err := c.store.GetRegionCache().GetTiFlashRPCContext(bo, r.Region, false, labelFilter)
+		if err != nil {
+			return nil, nil, err
+		}
+		if rpcCtx == nil {
+			return nil, &r.Region, nil
+		}
+		storeID := rpcCtx.Store.StoreID()
+		task, ok := storeTasks[storeID]
+		if !ok {
+			task = &StoreTask{StoreID: storeID, StoreAddr: rpcCtx.Addr}
+			storeTasks[storeID] = task
+			tasks = append(tasks, task)
+		}
+		task.Regions = append(task.Regions, r)
+	}
+	return tasks, nil, nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package copr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/coprocessor"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/testutils"
+	"github.com/tikv/client-go/v2/tikv"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+type mockCoprHandler struct{}
+
+func (h mockCoprHandler) HandleCmdCop(reqCtx *kvrpcpb.Context, session *testutils.RPCSession, r *coprocessor.Request) *coprocessor.Response {
+	return &coprocessor.Response{Data: []byte(fmt.Sprintf("cop %d", reqCtx.GetRegionId()))}
+}
+
+func (h mockCoprHandler) HandleBatchCop(ctx context.Context, reqCtx *kvrpcpb.Context, session *testutils.RPCSession, r *coprocessor.BatchRequest, timeout time.Duration) (*tikvrpc.BatchCopStreamResponse, error) {
+	return &tikvrpc.BatchCopStreamResponse{
+		BatchResponse: &coprocessor.BatchResponse{Data: []byte(fmt.Sprintf("batch cop %d regions", len(r.GetRegions())))},
+	}, nil
+}
+
+func (h mockCoprHandler) HandleCopStream(ctx context.Context, reqCtx *kvrpcpb.Context, session *testutils.RPCSession, r *coprocessor.Request, timeout time.Duration) (*tikvrpc.CopStreamResponse, error) {
+	return nil, fmt.Errorf("unimplemented")
+}
+
+func (h mockCoprHandler) Close() {}
+
+// newTestClient creates a cluster of two regions split at "m", whose peers
+// are on a TiKV store and a TiFlash store if withTiFlash is set.
+func newTestClient(t *testing.T, withTiFlash bool) (*Client, string) {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", mockCoprHandler{})
+	require.NoError(t, err)
+	storeIDs, _, regionID, _ := testutils.BootstrapWithMultiStores(cluster, 2)
+	newPeers := cluster.AllocIDs(2)
+	cluster.Split(regionID, cluster.AllocID(), []byte("m"), newPeers, newPeers[0])
+	tiflashAddr := cluster.GetStore(storeIDs[1]).GetAddress()
+	if withTiFlash {
+		cluster.UpdateStoreLabels(storeIDs[1], []*metapb.StoreLabel{{Key: tikvrpc.EngineLabelKey, Value: tikvrpc.EngineLabelTiFlash}})
+	}
+	store, err := tikv.NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return NewClient(store), tiflashAddr
+}
+
+func readAll(t *testing.T, it *Iterator) []*Result {
+	var results []*Result
+	for {
+		res, err := it.Next(context.Background())
+		require.NoError(t, err)
+		if res == nil {
+			return results
+		}
+		results = append(results, res)
+	}
+}
+
+func TestBatchCop(t *testing.T) {
+	c, tiflashAddr := newTestClient(t, true)
+	ranges := []kv.KeyRange{{StartKey: []byte("a"), EndKey: []byte("c")}, {StartKey: []byte("k"), EndKey: []byte("z")}}
+
+	bo := tikv.NewBackofferWithVars(context.Background(), 5000, nil)
+	tasks, err := c.BuildStoreTasks(bo, ranges, nil)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	require.Equal(t, tiflashAddr, tasks[0].StoreAddr)
+	require.Len(t, tasks[0].Regions, 2)
+	require.Equal(t, []kv.KeyRange{ranges[0], {StartKey: []byte("k"), EndKey: []byte("m")}}, tasks[0].Regions[0].Ranges)
+	require.Equal(t, []kv.KeyRange{{StartKey: []byte("m"), EndKey: []byte("z")}}, tasks[0].Regions[1].Ranges)
+
+	it, err := c.SendBatchCop(context.Background(), &Request{Ranges: ranges})
+	require.NoError(t, err)
+	results := readAll(t, it)
+	require.Len(t, results, 1)
+	require.Equal(t, []byte("batch cop 2 regions"), results[0].Data)
+	require.Equal(t, tiflashAddr, results[0].StoreAddr)
+	require.False(t, results[0].FromTiKV)
+
+	stores, err := c.MPPStores(bo, false, nil)
+	require.NoError(t, err)
+	require.Len(t, stores, 1)
+	require.Equal(t, tiflashAddr, stores[0].GetAddr())
+}
+
+func TestBatchCopFallback(t *testing.T) {
+	c, _ := newTestClient(t, false)
+	ranges := []kv.KeyRange{{StartKey: []byte("a"), EndKey: []byte("z")}}
+
+	_, err := c.SendBatchCop(context.Background(), &Request{Ranges: ranges})
+	require.ErrorIs(t, err, ErrNoTiFlashReplica)
+
+	it, err := c.SendBatchCop(context.Background(), &Request{Ranges: ranges, FallbackToTiKV: true})
+	require.NoError(t, err)
+	results := readAll(t, it)
+	require.Len(t, results, 2)
+	for _, res := range results {
+		require.True(t, res.FromTiKV)
+		require.Contains(t, string(res.Data), "cop ")
+	}
+}
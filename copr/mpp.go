@@ -0,0 +1,152 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package copr
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/mpp"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/tikv"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+// MPPStores returns the stores to run MPP tasks. If computeNodes is set, the
+// cluster runs in the disaggregated mode, and the TiFlash compute nodes, which
+// are labeled <engine, tiflash_compute>, are returned. Otherwise the TiFlash
+// stores, which are labeled <engine, tiflash>, selected by the label filter are
+// returned.
+func (c *Client) MPPStores(bo *tikv.Backoffer, computeNodes bool, labelFilter tikv.LabelFilter) ([]*tikv.Store, error) {
+	if computeNodes {
+		return c.store.GetRegionCache().GetTiFlashComputeStores(bo)
+	}
+	if labelFilter == nil {
+		labelFilter = tikv.LabelFilterNoTiFlashWriteNode
+	}
+	return c.store.GetRegionCache().GetTiFlashStores(labelFilter), nil
+}
+
+// MPPDispatchRequest is the request to dispatch an MPP task.
+type MPPDispatchRequest struct {
+	// Meta identifies the task, Meta.Address is the store to run it.
+	Meta *mpp.TaskMeta
+	// Plan is the encoded plan of the task.
+	Plan      []byte
+	SchemaVer int64
+	// Regions are the regions read by the task, e.g. from a StoreTask built
+	// by BuildStoreTasks. It's empty for a task only receiving data from
+	// other tasks.
+	Regions []RegionInfo
+	// Timeout is how long the task can run on the store.
+	Timeout time.Duration
+}
+
+// DispatchMPPTask dispatches an MPP task to the store of req.Meta.Address. It
+// returns the regions the store asks to retry, whose ranges should be
+// dispatched again after being rebuilt.
+func (c *Client) DispatchMPPTask(ctx context.Context, req *MPPDispatchRequest) (retryRegions []*metapb.Region, err error) {
+	task := &StoreTask{StoreAddr: req.Meta.GetAddress(), Regions: req.Regions}
+	rpcReq := tikvrpc.NewRequest(tikvrpc.CmdMPPTask, &mpp.DispatchTaskRequest{
+		Meta:        req.Meta,
+		EncodedPlan: req.Plan,
+		Timeout:     int64(req.Timeout / time.Second),
+		SchemaVer:   req.SchemaVer,
+		Regions:     task.regionsPB(),
+	}, kvrpcpb.Context{})
+	resp, err := c.store.GetTiKVClient().SendRequest(ctx, task.StoreAddr, rpcReq, defaultTimeout)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "copr: dispatch mpp task %d to %s", req.Meta.GetTaskId(), task.StoreAddr)
+	}
+	dispatchResp, ok := resp.Resp.(*mpp.DispatchTaskResponse)
+	if !ok {
+		return nil, errors.Errorf("copr: unexpected response %T of mpp dispatch from %s", resp.Resp, task.StoreAddr)
+	}
+	if mppErr := dispatchResp.GetError(); mppErr != nil {
+		return nil, errors.Errorf("copr: dispatch mpp task %d to %s: %s", req.Meta.GetTaskId(), task.StoreAddr, mppErr.GetMsg())
+	}
+	for _, r := range dispatchResp.GetRetryRegions() {
+		c.store.GetRegionCache().InvalidateCachedRegion(tikv.NewRegionVerID(r.GetId(), r.GetRegionEpoch().GetConfVer(), r.GetRegionEpoch().GetVersion()))
+	}
+	return dispatchResp.GetRetryRegions(), nil
+}
+
+// CancelMPPTask cancels the MPP tasks of the query of meta on the store of
+// meta.Address.
+func (c *Client) CancelMPPTask(ctx context.Context, meta *mpp.TaskMeta) error {
+	rpcReq := tikvrpc.NewRequest(tikvrpc.CmdMPPCancel, &mpp.CancelTaskRequest{Meta: meta}, kvrpcpb.Context{})
+	_, err := c.store.GetTiKVClient().SendRequest(ctx, meta.GetAddress(), rpcReq, defaultTimeout)
+	return errors.WithMessagef(err, "copr: cancel mpp task %d on %s", meta.GetTaskId(), meta.GetAddress())
+}
+
+// MPPIterator iterates over the data packets sent by an MPP task.
+type MPPIterator struct {
+	stream *tikvrpc.MPPStreamResponse
+	first  *mpp.MPPDataPacket
+	addr   string
+}
+
+// EstablishMPPConn connects to the sender task, which is usually the root task
+// of the query, to receive its data as the receiver. The timeout limits the
+// wait for every packet.
+func (c *Client) EstablishMPPConn(ctx context.Context, sender, receiver *mpp.TaskMeta, timeout time.Duration) (*MPPIterator, error) {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	rpcReq := tikvrpc.NewRequest(tikvrpc.CmdMPPConn, &mpp.EstablishMPPConnectionRequest{
+		SenderMeta:   sender,
+		ReceiverMeta: receiver,
+	}, kvrpcpb.Context{})
+	resp, err := c.store.GetTiKVClient().SendRequest(ctx, sender.GetAddress(), rpcReq, timeout)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "copr: establish mpp connection to task %d on %s", sender.GetTaskId(), sender.GetAddress())
+	}
+	stream, ok := resp.Resp.(*tikvrpc.MPPStreamResponse)
+	if !ok || stream == nil {
+		return nil, errors.Errorf("copr: unexpected response %T of mpp connection from %s", resp.Resp, sender.GetAddress())
+	}
+	return &MPPIterator{stream: stream, first: stream.MPPDataPacket, addr: sender.GetAddress()}, nil
+}
+
+// Next returns the next data packet, or nil if the task finishes.
+func (it *MPPIterator) Next() (*mpp.MPPDataPacket, error) {
+	packet := it.first
+	it.first = nil
+	if packet == nil {
+		if it.stream.Tikv_EstablishMPPConnectionClient == nil {
+			return nil, nil
+		}
+		var err error
+		packet, err = it.stream.Recv()
+		if errors.Cause(err) == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, errors.WithMessagef(err, "copr: receive mpp data from %s", it.addr)
+		}
+	}
+	if mppErr := packet.GetError(); mppErr != nil {
+		return nil, errors.Errorf("copr: mpp task on %s: %s", it.addr, mppErr.GetMsg())
+	}
+	return packet, nil
+}
+
+// Close closes the connection.
+func (it *MPPIterator) Close() {
+	it.stream.Close()
+}
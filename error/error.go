@@ -35,8 +35,9 @@
 package error
 
 import (
-	"encoding/hex"
+	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
@@ -45,7 +46,10 @@ import (
 	"github.com/pkg/errors"
 	"github.com/tikv/client-go/v2/internal/logutil"
 	"github.com/tikv/client-go/v2/util"
+	"github.com/tikv/client-go/v2/util/redact"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -100,6 +104,11 @@ var (
 	ErrUnknown = errors.New("unknown")
 	// ErrResultUndetermined is the error when execution result is unknown.
 	ErrResultUndetermined = errors.New("execution result undetermined")
+	// ErrCommitRolledBack is the error when the transaction is found rolled back after the result of its commit is
+	// undetermined.
+	ErrCommitRolledBack = errors.New("transaction rolled back after the commit result is undetermined")
+	// ErrMemQuotaExceeded is the error when the memory quota of the client is exceeded, see memquota.Quota.
+	ErrMemQuotaExceeded = errors.New("client memory quota exceeded")
 )
 
 type ErrQueryInterruptedWithSignal struct {
@@ -118,6 +127,16 @@ func IsErrNotFound(err error) bool {
 	return errors.Is(err, ErrNotExist)
 }
 
+// IsErrCommitRolledBack checks if err is caused by the transaction rolled back after its commit result is undetermined.
+func IsErrCommitRolledBack(err error) bool {
+	return errors.Is(err, ErrCommitRolledBack)
+}
+
+// IsErrMemQuotaExceeded checks if err is caused by exceeding the memory quota of the client.
+func IsErrMemQuotaExceeded(err error) bool {
+	return errors.Is(err, ErrMemQuotaExceeded)
+}
+
 // ErrDeadlock wraps *kvrpcpb.Deadlock to implement the error interface.
 // It also marks if the deadlock is retryable.
 type ErrDeadlock struct {
@@ -126,7 +145,99 @@ type ErrDeadlock struct {
 }
 
 func (d *ErrDeadlock) Error() string {
-	return d.Deadlock.String()
+	var b strings.Builder
+	fmt.Fprintf(&b, "deadlock detected, lock ts: %d, lock key: %s", d.GetLockTs(), redact.Key(d.GetLockKey()))
+	if entries := d.WaitForEntries(); len(entries) > 0 {
+		b.WriteString(", wait chain: ")
+		for i, e := range entries {
+			if i > 0 {
+				b.WriteString(" -> ")
+			}
+			fmt.Fprintf(&b, "{txn %d waits for txn %d on key %s for %v}", e.Txn, e.WaitForTxn, redact.Key(e.Key), e.WaitTime)
+		}
+	}
+	return b.String()
+}
+
+// DeadlockWaitForEntry is an edge of the wait-for graph that forms a deadlock.
+type DeadlockWaitForEntry struct {
+	// Txn is the start ts of the transaction waiting.
+	Txn uint64
+	// WaitForTxn is the start ts of the transaction being waited for.
+	WaitForTxn uint64
+	// Key is the key Txn is trying to lock.
+	Key     []byte
+	KeyHash uint64
+	// ResourceGroupTag is the tag of the lock request of Txn.
+	ResourceGroupTag []byte
+	// WaitTime is how long Txn has been waiting.
+	WaitTime time.Duration
+}
+
+// WaitForEntries returns the wait chain of the deadlock. The chain starts from
+// the transaction holding the lock that the current transaction waits for, and
+// ends at the current transaction.
+func (d *ErrDeadlock) WaitForEntries() []DeadlockWaitForEntry {
+	chain := d.GetWaitChain()
+	if len(chain) == 0 {
+		return nil
+	}
+	entries := make([]DeadlockWaitForEntry, len(chain))
+	for i, e := range chain {
+		entries[i] = DeadlockWaitForEntry{
+			Txn:              e.GetTxn(),
+			WaitForTxn:       e.GetWaitForTxn(),
+			Key:              e.GetKey(),
+			KeyHash:          e.GetKeyHash(),
+			ResourceGroupTag: e.GetResourceGroupTag(),
+			WaitTime:         time.Duration(e.GetWaitTime()) * time.Millisecond,
+		}
+	}
+	return entries
+}
+
+// IsErrDeadlock returns true if it is ErrDeadlock.
+func IsErrDeadlock(err error) bool {
+	var e *ErrDeadlock
+	return errors.As(err, &e)
+}
+
+// ErrLockWait is the error when a pessimistic lock request gives up waiting for the lock of another transaction by its
+// lock wait policy, see kv.LockNoWait and kv.LockWaitTimeout. Its message and cause are the ones of Err, so the checks
+// of ErrLockWaitTimeout and ErrLockAcquireFailAndNoWaitSet still work.
+type ErrLockWait struct {
+	// Err is ErrLockWaitTimeout, or ErrLockAcquireFailAndNoWaitSet if the request doesn't wait.
+	Err error
+	// Key is the key locked by the conflicting transaction.
+	Key []byte
+	// Primary is the primary key of the conflicting transaction.
+	Primary []byte
+	// LockTS is the start ts of the conflicting transaction.
+	LockTS uint64
+	// LockTTL is the ttl of the lock in milliseconds.
+	LockTTL uint64
+	// WaitTime is how long the request has waited for the lock.
+	WaitTime time.Duration
+}
+
+func (e *ErrLockWait) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns Err.
+func (e *ErrLockWait) Unwrap() error {
+	return e.Err
+}
+
+// Cause returns Err, for errors.Cause of github.com/pkg/errors.
+func (e *ErrLockWait) Cause() error {
+	return e.Err
+}
+
+// IsErrLockWait returns true if it is ErrLockWait.
+func IsErrLockWait(err error) bool {
+	var e *ErrLockWait
+	return errors.As(err, &e)
 }
 
 // PDError wraps *pdpb.Error to implement the error interface.
@@ -159,6 +270,9 @@ func IsErrKeyExist(err error) bool {
 }
 
 // ErrWriteConflict wraps *kvrpcpb.ErrWriteConflict to implement the error interface.
+// The key in conflict and the start ts and the commit ts of the transaction in conflict are reported by GetKey,
+// GetConflictTs and GetConflictCommitTs, the commit ts is 0 if the conflict is with a lock not committed yet. Kind
+// classifies the conflict.
 type ErrWriteConflict struct {
 	*kvrpcpb.WriteConflict
 }
@@ -173,6 +287,71 @@ func IsErrWriteConflict(err error) bool {
 	return errors.As(err, &e)
 }
 
+// WriteConflictKind classifies the write conflicts by how they are detected, so the retry policies of the applications
+// can be selective, see ErrWriteConflict.Kind.
+type WriteConflictKind int
+
+const (
+	// WriteConflictUnknown is the conflict whose reason isn't reported.
+	WriteConflictUnknown WriteConflictKind = iota
+	// WriteConflictOptimistic is the conflict of an optimistic write with a newer committed write of the key, found
+	// in prewrite.
+	WriteConflictOptimistic
+	// WriteConflictPessimistic is the conflict of a pessimistic lock with a newer committed write of the key, which is
+	// retried by locking again with a newer for update ts.
+	WriteConflictPessimistic
+	// WriteConflictLazyUniquenessCheck is the conflict found by the uniqueness check deferred to prewrite, i.e. the
+	// key presumed not existing is written by another transaction, so retrying may fail with the duplicated key.
+	WriteConflictLazyUniquenessCheck
+	// WriteConflictSelfRolledBack is the conflict with the rollback record of the transaction itself, i.e. the
+	// transaction is already rolled back by others.
+	WriteConflictSelfRolledBack
+	// WriteConflictRCCheckTS is the conflict of a read in the read committed isolation level checking the ts.
+	WriteConflictRCCheckTS
+)
+
+// String implements fmt.Stringer interface.
+func (k WriteConflictKind) String() string {
+	switch k {
+	case WriteConflictOptimistic:
+		return "optimistic"
+	case WriteConflictPessimistic:
+		return "pessimistic"
+	case WriteConflictLazyUniquenessCheck:
+		return "lazy_uniqueness_check"
+	case WriteConflictSelfRolledBack:
+		return "self_rolled_back"
+	case WriteConflictRCCheckTS:
+		return "rc_check_ts"
+	default:
+		return "unknown"
+	}
+}
+
+// Kind returns the kind of the conflict by the reason reported by TiKV.
+func (k *ErrWriteConflict) Kind() WriteConflictKind {
+	switch k.GetReason() {
+	case kvrpcpb.WriteConflict_Optimistic:
+		return WriteConflictOptimistic
+	case kvrpcpb.WriteConflict_PessimisticRetry:
+		return WriteConflictPessimistic
+	case kvrpcpb.WriteConflict_LazyUniquenessCheck:
+		return WriteConflictLazyUniquenessCheck
+	case kvrpcpb.WriteConflict_SelfRolledBack:
+		return WriteConflictSelfRolledBack
+	case kvrpcpb.WriteConflict_RcCheckTs:
+		return WriteConflictRCCheckTS
+	default:
+		return WriteConflictUnknown
+	}
+}
+
+// Retryable returns whether retrying the transaction with a newer timestamp may succeed. It's false for the conflicts
+// of the lazy uniqueness checks, since the retries are likely to fail with the duplicated keys.
+func (k *ErrWriteConflict) Retryable() bool {
+	return k.Kind() != WriteConflictLazyUniquenessCheck
+}
+
 // NewErrWriteConflictWithArgs generates an ErrWriteConflict with args.
 func NewErrWriteConflictWithArgs(startTs, conflictTs, conflictCommitTs uint64, key []byte, reason kvrpcpb.WriteConflict_Reason) *ErrWriteConflict {
 	conflict := kvrpcpb.WriteConflict{
@@ -254,6 +433,149 @@ func (e *ErrGCTooEarly) Error() string {
 	return fmt.Sprintf("GC life time is shorter than transaction duration, transaction starts at %v, GC safe point is %v", e.TxnStartTS, e.GCSafePoint)
 }
 
+// ErrSLOBudgetExceeded is the error when a read can't finish within the latency budget set by the caller.
+// The read stops retrying and returns the results collected so far together with this error.
+type ErrSLOBudgetExceeded struct {
+	Budget  time.Duration
+	Elapsed time.Duration
+	// ResumeKey is the key a scan should continue from to read the remaining data. It's nil for point reads.
+	ResumeKey []byte
+}
+
+func (e *ErrSLOBudgetExceeded) Error() string {
+	return fmt.Sprintf("read exceeds latency budget, budget: %v, elapsed: %v", e.Budget, e.Elapsed)
+}
+
+// IsErrSLOBudgetExceeded returns true if it is ErrSLOBudgetExceeded.
+func IsErrSLOBudgetExceeded(err error) bool {
+	var e *ErrSLOBudgetExceeded
+	return errors.As(err, &e)
+}
+
+// ErrChecksumMismatch is the error when the value read back doesn't match the checksum of the value written, which
+// indicates that the data is corrupted in transit, or it's overwritten concurrently.
+type ErrChecksumMismatch struct {
+	Key      []byte
+	Expected uint32
+	Actual   uint32
+}
+
+func (e *ErrChecksumMismatch) Error() string {
+	return fmt.Sprintf("checksum mismatch, key: %s, expected: %08x, actual: %08x", redact.RegionKey(e.Key), e.Expected, e.Actual)
+}
+
+// IsErrChecksumMismatch returns true if it is ErrChecksumMismatch.
+func IsErrChecksumMismatch(err error) bool {
+	var e *ErrChecksumMismatch
+	return errors.As(err, &e)
+}
+
+// ErrOnePCIneligible is the error when a transaction requiring 1PC can't be
+// committed by 1PC. Nothing is written when it's returned.
+type ErrOnePCIneligible struct {
+	// Reason is why 1PC can't be used, see the reasons of KVTxn.OnePCIneligibleReason.
+	Reason string
+}
+
+func (e *ErrOnePCIneligible) Error() string {
+	return fmt.Sprintf("txn requires 1PC but is ineligible: %s", e.Reason)
+}
+
+// IsErrOnePCIneligible returns true if it is ErrOnePCIneligible.
+func IsErrOnePCIneligible(err error) bool {
+	var e *ErrOnePCIneligible
+	return errors.As(err, &e)
+}
+
+// ErrNoEngineReplica is the error when a read restricted to a storage engine,
+// see KVSnapshot.SetReadEngine, reaches a region without replicas of the engine.
+type ErrNoEngineReplica struct {
+	Engine   string
+	RegionID uint64
+}
+
+func (e *ErrNoEngineReplica) Error() string {
+	return fmt.Sprintf("region %d has no %s replica", e.RegionID, e.Engine)
+}
+
+// IsErrNoEngineReplica returns true if it is ErrNoEngineReplica.
+func IsErrNoEngineReplica(err error) bool {
+	var e *ErrNoEngineReplica
+	return errors.As(err, &e)
+}
+
+// The limits of config.CommitSizeLimit reported by ErrCommitSizeLimit.
+const (
+	CommitSizeLimitKey   = "max-key-size"
+	CommitSizeLimitEntry = "max-entry-size"
+	CommitSizeLimitTxn   = "max-txn-size"
+)
+
+// ErrCommitSizeLimit is the error when the mutations of a transaction exceed a limit
+// of config.CommitSizeLimit. It's returned before prewrite, so nothing is written and
+// the caller may split the transaction.
+type ErrCommitSizeLimit struct {
+	// Limit is the limit exceeded, one of CommitSizeLimitKey, CommitSizeLimitEntry and
+	// CommitSizeLimitTxn.
+	Limit string
+	// Key is the key exceeding the limit, nil for CommitSizeLimitTxn.
+	Key  []byte
+	Size uint64
+	Max  uint64
+}
+
+func (e *ErrCommitSizeLimit) Error() string {
+	if e.Key == nil {
+		return fmt.Sprintf("txn exceeds %s, size: %d, limit: %d", e.Limit, e.Size, e.Max)
+	}
+	return fmt.Sprintf("txn exceeds %s, key: %s, size: %d, limit: %d", e.Limit, redact.Key(e.Key), e.Size, e.Max)
+}
+
+// IsErrCommitSizeLimit returns true if it is ErrCommitSizeLimit.
+func IsErrCommitSizeLimit(err error) bool {
+	var e *ErrCommitSizeLimit
+	return errors.As(err, &e)
+}
+
+// ErrDeadlineExceeded annotates the DeadlineExceeded error of a request whose context is audited by
+// util.ContextWithDeadlineAudit with how the deadline is consumed.
+type ErrDeadlineExceeded struct {
+	Breakdown util.DeadlineBreakdown
+	Err       error
+}
+
+func (e *ErrDeadlineExceeded) Error() string {
+	return fmt.Sprintf("%v, deadline breakdown: {%v}", e.Err, e.Breakdown)
+}
+
+// Unwrap returns the DeadlineExceeded error annotated.
+func (e *ErrDeadlineExceeded) Unwrap() error {
+	return e.Err
+}
+
+// Cause returns the DeadlineExceeded error annotated, so errors.Cause sees through the annotation.
+func (e *ErrDeadlineExceeded) Cause() error {
+	return e.Err
+}
+
+// AnnotateDeadlineExceeded annotates err with the deadline breakdown if the context is audited, and err is caused by
+// the deadline of the context, which is either err or the context of the request exceeding its deadline.
+func AnnotateDeadlineExceeded(ctx context.Context, err error) error {
+	audit := util.DeadlineAuditFromContext(ctx)
+	if audit == nil || err == nil {
+		return err
+	}
+	var annotated *ErrDeadlineExceeded
+	if errors.As(err, &annotated) {
+		return err
+	}
+	if !errors.Is(err, context.DeadlineExceeded) && status.Code(errors.Cause(err)) != codes.DeadlineExceeded &&
+		!errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return &ErrDeadlineExceeded{Breakdown: audit.Breakdown(ctx), Err: err}
+}
+
 // ErrTokenLimit is the error that token is up to the limit.
 type ErrTokenLimit struct {
 	StoreID uint64
@@ -289,13 +611,13 @@ func (e *ErrAssertionFailed) Error() string {
 func (e *ErrLockOnlyIfExistsNoReturnValue) Error() string {
 	return fmt.Sprintf("LockOnlyIfExists is set for Lock Context, but ReturnValues is not set, "+
 		"StartTs is {%d}, ForUpdateTs is {%d}, one of lock keys is {%v}.",
-		e.StartTS, e.ForUpdateTs, hex.EncodeToString(e.LockKey))
+		e.StartTS, e.ForUpdateTs, redact.Key(e.LockKey))
 }
 
 func (e *ErrLockOnlyIfExistsNoPrimaryKey) Error() string {
 	return fmt.Sprintf("LockOnlyIfExists is set for Lock Context, but primary key of current transaction is not set, "+
 		"StartTs is {%d}, ForUpdateTs is {%d}, one of lock keys is {%s}",
-		e.StartTS, e.ForUpdateTs, hex.EncodeToString(e.LockKey))
+		e.StartTS, e.ForUpdateTs, redact.Key(e.LockKey))
 }
 
 // ExtractKeyErr extracts a KeyError.
@@ -333,7 +655,7 @@ func ExtractKeyErr(keyErr *kvrpcpb.KeyError) error {
 		err := errors.Errorf("txn %d not found", keyErr.TxnNotFound.StartTs)
 		return err
 	}
-	return errors.Errorf("unexpected KeyError: %s", keyErr.String())
+	return errors.Errorf("unexpected KeyError: %s", redact.Message(keyErr))
 }
 
 // IsErrorUndetermined checks if the error is undetermined error.
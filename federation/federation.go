@@ -0,0 +1,278 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package federation reads from several independent TiKV clusters. A
+// Federation sends the same read to all clusters, e.g. a primary cluster and
+// its replicas kept in sync by an external tool, and returns one answer chosen
+// by a Policy. A Sharded splits the keys over the clusters by a ShardFunc, and
+// aggregates the reads of the keys owned by different clusters.
+package federation
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// Reader is the read interface of one cluster. See NewRawKVReader and
+// NewTxnKVReader.
+type Reader interface {
+	// Get returns the value of the key, or nil if the key does not exist.
+	Get(ctx context.Context, key []byte) ([]byte, error)
+	// BatchGet returns the values of the keys, the value of a key not
+	// existing is nil.
+	BatchGet(ctx context.Context, keys [][]byte) ([][]byte, error)
+	// Scan returns at most limit key-value pairs in [startKey, endKey).
+	Scan(ctx context.Context, startKey, endKey []byte, limit int) ([][]byte, [][]byte, error)
+}
+
+// Member is one cluster of the federation.
+type Member struct {
+	// Name identifies the cluster in errors.
+	Name   string
+	Reader Reader
+	// Freshness returns how up-to-date the cluster is, e.g. the checkpoint ts
+	// of the replication to it. It's only required by PolicyFreshest.
+	Freshness func(ctx context.Context) (uint64, error)
+}
+
+// Policy decides which answer is returned when the clusters are read.
+type Policy int
+
+const (
+	// PolicyFirst returns the first successful answer and cancels the others.
+	PolicyFirst Policy = iota
+	// PolicyQuorum returns the answer agreed by a majority of the clusters.
+	PolicyQuorum
+	// PolicyFreshest reads all clusters and returns the answer of the
+	// successful cluster with the largest Freshness.
+	PolicyFreshest
+)
+
+func (p Policy) String() string {
+	switch p {
+	case PolicyFirst:
+		return "first"
+	case PolicyQuorum:
+		return "quorum"
+	case PolicyFreshest:
+		return "freshest"
+	}
+	return "unknown"
+}
+
+var (
+	// ErrNoQuorum is returned by PolicyQuorum if no answer is agreed by a
+	// majority of the clusters.
+	ErrNoQuorum = errors.New("federation: no quorum")
+	// ErrNoMember is returned if the federation has no member.
+	ErrNoMember = errors.New("federation: no member")
+)
+
+// Federation reads from all its members with a policy.
+type Federation struct {
+	members []Member
+	policy  Policy
+}
+
+// New creates a Federation.
+func New(policy Policy, members ...Member) (*Federation, error) {
+	if len(members) == 0 {
+		return nil, errors.WithStack(ErrNoMember)
+	}
+	if policy == PolicyFreshest {
+		for _, m := range members {
+			if m.Freshness == nil {
+				return nil, errors.Errorf("federation: member %s has no Freshness, which is required by the %s policy", m.Name, policy)
+			}
+		}
+	}
+	return &Federation{members: members, policy: policy}, nil
+}
+
+// Get returns the value of the key, or nil if the key does not exist.
+func (f *Federation) Get(ctx context.Context, key []byte) ([]byte, error) {
+	res, err := f.read(ctx, func(ctx context.Context, r Reader) (result, error) {
+		val, err := r.Get(ctx, key)
+		return result{values: [][]byte{val}}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.values[0], nil
+}
+
+// BatchGet returns the values of the keys, the value of a key not existing is
+// nil.
+func (f *Federation) BatchGet(ctx context.Context, keys [][]byte) ([][]byte, error) {
+	res, err := f.read(ctx, func(ctx context.Context, r Reader) (result, error) {
+		vals, err := r.BatchGet(ctx, keys)
+		return result{values: vals}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.values, nil
+}
+
+// Scan returns at most limit key-value pairs in [startKey, endKey).
+func (f *Federation) Scan(ctx context.Context, startKey, endKey []byte, limit int) ([][]byte, [][]byte, error) {
+	res, err := f.read(ctx, func(ctx context.Context, r Reader) (result, error) {
+		keys, vals, err := r.Scan(ctx, startKey, endKey, limit)
+		return result{keys: keys, values: vals}, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return res.keys, res.values, nil
+}
+
+type result struct {
+	keys   [][]byte
+	values [][]byte
+}
+
+func (r result) equal(o result) bool {
+	return equalSlices(r.keys, o.keys) && equalSlices(r.values, o.values)
+}
+
+func equalSlices(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		// A nil value means the key does not exist, which is different
+		// from an empty value.
+		if (a[i] == nil) != (b[i] == nil) || !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+type memberResult struct {
+	idx       int
+	res       result
+	freshness uint64
+	err       error
+}
+
+func (f *Federation) read(ctx context.Context, fn func(context.Context, Reader) (result, error)) (result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ch := make(chan memberResult, len(f.members))
+	for i := range f.members {
+		go func(i int) {
+			m := &f.members[i]
+			r := memberResult{idx: i}
+			if f.policy == PolicyFreshest {
+				if r.freshness, r.err = m.Freshness(ctx); r.err != nil {
+					r.err = errors.WithMessagef(r.err, "federation: get freshness of %s", m.Name)
+					ch <- r
+					return
+				}
+			}
+			r.res, r.err = fn(ctx, m.Reader)
+			if r.err != nil {
+				r.err = errors.WithMessagef(r.err, "federation: read from %s", m.Name)
+			}
+			ch <- r
+		}(i)
+	}
+
+	switch f.policy {
+	case PolicyFirst:
+		return f.first(ch)
+	case PolicyQuorum:
+		return f.quorum(ch)
+	case PolicyFreshest:
+		return f.freshest(ch)
+	}
+	return result{}, errors.Errorf("federation: unknown policy %d", f.policy)
+}
+
+func (f *Federation) first(ch <-chan memberResult) (result, error) {
+	var lastErr error
+	for range f.members {
+		r := <-ch
+		if r.err == nil {
+			return r.res, nil
+		}
+		lastErr = r.err
+	}
+	return result{}, lastErr
+}
+
+func (f *Federation) quorum(ch <-chan memberResult) (result, error) {
+	need := len(f.members)/2 + 1
+	// group is a distinct answer and its votes.
+	type group struct {
+		res   result
+		votes int
+	}
+	var (
+		groups  []group
+		lastErr error
+		failed  int
+	)
+	for range f.members {
+		r := <-ch
+		if r.err != nil {
+			lastErr = r.err
+			failed++
+		} else {
+			i := 0
+			for i < len(groups) && !groups[i].res.equal(r.res) {
+				i++
+			}
+			if i == len(groups) {
+				groups = append(groups, group{res: r.res})
+			}
+			groups[i].votes++
+			if groups[i].votes >= need {
+				return groups[i].res, nil
+			}
+		}
+		if failed > len(f.members)-need {
+			return result{}, errors.WithMessage(ErrNoQuorum, lastErr.Error())
+		}
+	}
+	if lastErr != nil {
+		return result{}, errors.WithMessage(ErrNoQuorum, lastErr.Error())
+	}
+	return result{}, errors.WithStack(ErrNoQuorum)
+}
+
+func (f *Federation) freshest(ch <-chan memberResult) (result, error) {
+	var (
+		best    *memberResult
+		lastErr error
+	)
+	for range f.members {
+		r := <-ch
+		if r.err != nil {
+			lastErr = r.err
+			continue
+		}
+		if best == nil || r.freshness > best.freshness {
+			best = &r
+		}
+	}
+	if best == nil {
+		return result{}, lastErr
+	}
+	return best.res, nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package federation
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type mockReader struct {
+	data  map[string][]byte
+	delay time.Duration
+	err   error
+}
+
+func (r *mockReader) wait(ctx context.Context) error {
+	select {
+	case <-time.After(r.delay):
+		return r.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *mockReader) Get(ctx context.Context, key []byte) ([]byte, error) {
+	if err := r.wait(ctx); err != nil {
+		return nil, err
+	}
+	return r.data[string(key)], nil
+}
+
+func (r *mockReader) BatchGet(ctx context.Context, keys [][]byte) ([][]byte, error) {
+	if err := r.wait(ctx); err != nil {
+		return nil, err
+	}
+	vals := make([][]byte, len(keys))
+	for i, k := range keys {
+		vals[i] = r.data[string(k)]
+	}
+	return vals, nil
+}
+
+func (r *mockReader) Scan(ctx context.Context, startKey, endKey []byte, limit int) ([][]byte, [][]byte, error) {
+	if err := r.wait(ctx); err != nil {
+		return nil, nil, err
+	}
+	var keys, vals [][]byte
+	for k := range r.data {
+		if k >= string(startKey) && (len(endKey) == 0 || k < string(endKey)) {
+			keys = append(keys, []byte(k))
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+	for _, k := range keys {
+		vals = append(vals, r.data[string(k)])
+	}
+	return keys, vals, nil
+}
+
+func member(name string, r *mockReader, freshness uint64) Member {
+	return Member{
+		Name:   name,
+		Reader: r,
+		Freshness: func(context.Context) (uint64, error) {
+			return freshness, nil
+		},
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(PolicyFirst)
+	require.ErrorIs(t, err, ErrNoMember)
+	_, err = New(PolicyFreshest, Member{Name: "a", Reader: &mockReader{}})
+	require.Error(t, err)
+}
+
+func TestPolicyFirst(t *testing.T) {
+	f, err := New(PolicyFirst,
+		member("slow", &mockReader{data: map[string][]byte{"k": []byte("slow")}, delay: time.Hour}, 0),
+		member("failed", &mockReader{err: errors.New("unavailable")}, 0),
+		member("fast", &mockReader{data: map[string][]byte{"k": []byte("fast")}, delay: 10 * time.Millisecond}, 0),
+	)
+	require.NoError(t, err)
+	val, err := f.Get(context.Background(), []byte("k"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("fast"), val)
+
+	f, err = New(PolicyFirst, member("failed", &mockReader{err: errors.New("unavailable")}, 0))
+	require.NoError(t, err)
+	_, err = f.Get(context.Background(), []byte("k"))
+	require.ErrorContains(t, err, "unavailable")
+}
+
+func TestPolicyQuorum(t *testing.T) {
+	fresh := map[string][]byte{"k1": []byte("v2"), "k2": []byte("v2")}
+	stale := map[string][]byte{"k1": []byte("v1")}
+	f, err := New(PolicyQuorum,
+		member("a", &mockReader{data: stale}, 0),
+		member("b", &mockReader{data: fresh, delay: 10 * time.Millisecond}, 0),
+		member("c", &mockReader{data: fresh, delay: 20 * time.Millisecond}, 0),
+	)
+	require.NoError(t, err)
+	vals, err := f.BatchGet(context.Background(), [][]byte{[]byte("k1"), []byte("k2")})
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("v2"), []byte("v2")}, vals)
+
+	// A missing key and an empty value are different answers.
+	f, err = New(PolicyQuorum,
+		member("a", &mockReader{data: map[string][]byte{"k": {}}}, 0),
+		member("b", &mockReader{}, 0),
+	)
+	require.NoError(t, err)
+	_, err = f.Get(context.Background(), []byte("k"))
+	require.ErrorIs(t, err, ErrNoQuorum)
+
+	f, err = New(PolicyQuorum,
+		member("a", &mockReader{data: fresh}, 0),
+		member("b", &mockReader{err: errors.New("unavailable")}, 0),
+		member("c", &mockReader{err: errors.New("unavailable")}, 0),
+	)
+	require.NoError(t, err)
+	_, err = f.Get(context.Background(), []byte("k1"))
+	require.ErrorIs(t, err, ErrNoQuorum)
+	require.ErrorContains(t, err, "unavailable")
+}
+
+func TestPolicyFreshest(t *testing.T) {
+	f, err := New(PolicyFreshest,
+		member("primary", &mockReader{err: errors.New("unavailable")}, 300),
+		member("replica1", &mockReader{data: map[string][]byte{"k": []byte("v1")}}, 100),
+		member("replica2", &mockReader{data: map[string][]byte{"k": []byte("v2")}, delay: 10 * time.Millisecond}, 200),
+	)
+	require.NoError(t, err)
+	val, err := f.Get(context.Background(), []byte("k"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("v2"), val)
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package federation
+
+import (
+	"context"
+
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/rawkv"
+	"github.com/tikv/client-go/v2/txnkv"
+)
+
+type rawKVReader struct {
+	client *rawkv.Client
+}
+
+// NewRawKVReader returns a Reader of the cluster of the RawKV client.
+func NewRawKVReader(client *rawkv.Client) Reader {
+	return &rawKVReader{client: client}
+}
+
+func (r *rawKVReader) Get(ctx context.Context, key []byte) ([]byte, error) {
+	return r.client.Get(ctx, key)
+}
+
+func (r *rawKVReader) BatchGet(ctx context.Context, keys [][]byte) ([][]byte, error) {
+	return r.client.BatchGet(ctx, keys)
+}
+
+func (r *rawKVReader) Scan(ctx context.Context, startKey, endKey []byte, limit int) ([][]byte, [][]byte, error) {
+	return r.client.Scan(ctx, startKey, endKey, limit)
+}
+
+type txnKVReader struct {
+	client *txnkv.Client
+}
+
+// NewTxnKVReader returns a Reader of the cluster of the transactional client.
+// Every read is done in a snapshot at the latest timestamp of the cluster.
+func NewTxnKVReader(client *txnkv.Client) Reader {
+	return &txnKVReader{client: client}
+}
+
+func (r *txnKVReader) snapshot() (*txnkv.KVSnapshot, error) {
+	ts, err := r.client.CurrentTimestamp(oracle.GlobalTxnScope)
+	if err != nil {
+		return nil, err
+	}
+	return r.client.GetSnapshot(ts), nil
+}
+
+func (r *txnKVReader) Get(ctx context.Context, key []byte) ([]byte, error) {
+	snap, err := r.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	val, err := snap.Get(ctx, key)
+	if tikverr.IsErrNotFound(err) {
+		return nil, nil
+	}
+	return val, err
+}
+
+func (r *txnKVReader) BatchGet(ctx context.Context, keys [][]byte) ([][]byte, error) {
+	snap, err := r.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	m, err := snap.BatchGet(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	vals := make([][]byte, len(keys))
+	for i, k := range keys {
+		vals[i] = m[string(k)]
+	}
+	return vals, nil
+}
+
+func (r *txnKVReader) Scan(ctx context.Context, startKey, endKey []byte, limit int) ([][]byte, [][]byte, error) {
+	snap, err := r.snapshot()
+	if err != nil {
+		return nil, nil, err
+	}
+	it, err := snap.Iter(startKey, endKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer it.Close()
+	var keys, vals [][]byte
+	for it.Valid() && len(keys) < limit {
+		keys = append(keys, it.Key())
+		vals = append(vals, it.Value())
+		if err = it.Next(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return keys, vals, nil
+}
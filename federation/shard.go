@@ -0,0 +1,230 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package federation
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/twmb/murmur3"
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultVirtualNodes is the number of the virtual nodes per member used by
+// NewSharded if no ShardFunc is given.
+const DefaultVirtualNodes = 128
+
+// ShardFunc returns the index of the member owning the key.
+type ShardFunc func(key []byte) int
+
+// ConsistentHash returns a ShardFunc placing the members of the names on a
+// hash ring, each with vnodes virtual nodes, so adding or removing a member
+// only moves the keys owned by it. The members are identified by the names, so
+// the order of the names doesn't matter.
+func ConsistentHash(names []string, vnodes int) ShardFunc {
+	if vnodes <= 0 {
+		vnodes = DefaultVirtualNodes
+	}
+	type node struct {
+		hash   uint32
+		member int
+	}
+	ring := make([]node, 0, len(names)*vnodes)
+	for i, name := range names {
+		for v := 0; v < vnodes; v++ {
+			ring = append(ring, node{hash: murmur3.StringSum32(name + "#" + strconv.Itoa(v)), member: i})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool {
+		if ring[i].hash != ring[j].hash {
+			return ring[i].hash < ring[j].hash
+		}
+		// Break the ties by the names so the ring doesn't depend on the order of the names.
+		return names[ring[i].member] < names[ring[j].member]
+	})
+	return func(key []byte) int {
+		if len(ring) == 0 {
+			return -1
+		}
+		h := murmur3.Sum32(key)
+		i := sort.Search(len(ring), func(i int) bool { return ring[i].hash >= h })
+		if i == len(ring) {
+			i = 0
+		}
+		return ring[i].member
+	}
+}
+
+// Sharded splits the keys over its members by a ShardFunc, for the data
+// sharded above the limits of one cluster. Every key is read from the member
+// owning it only, and the reads of the keys owned by different members are
+// not consistent with each other, since each member reads at its own latest
+// timestamp.
+type Sharded struct {
+	members []Member
+	shard   ShardFunc
+}
+
+// NewSharded creates a Sharded. The keys are placed by ConsistentHash of the
+// names of the members if shard is nil.
+func NewSharded(shard ShardFunc, members ...Member) (*Sharded, error) {
+	if len(members) == 0 {
+		return nil, errors.WithStack(ErrNoMember)
+	}
+	if shard == nil {
+		names := make([]string, len(members))
+		for i, m := range members {
+			names[i] = m.Name
+		}
+		shard = ConsistentHash(names, DefaultVirtualNodes)
+	}
+	return &Sharded{members: members, shard: shard}, nil
+}
+
+// Owner returns the index of the member owning the key, e.g. to send the
+// writes of the key to its cluster.
+func (s *Sharded) Owner(key []byte) (int, error) {
+	i := s.shard(key)
+	if i < 0 || i >= len(s.members) {
+		return 0, errors.Errorf("federation: shard of key %q is %d, out of %d members", key, i, len(s.members))
+	}
+	return i, nil
+}
+
+// Get returns the value of the key from the member owning it, or nil if the
+// key does not exist.
+func (s *Sharded) Get(ctx context.Context, key []byte) ([]byte, error) {
+	i, err := s.Owner(key)
+	if err != nil {
+		return nil, err
+	}
+	val, err := s.members[i].Reader.Get(ctx, key)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "federation: read from %s", s.members[i].Name)
+	}
+	return val, nil
+}
+
+// BatchGet returns the values of the keys, the value of a key not existing is
+// nil. The keys are read from their members concurrently.
+func (s *Sharded) BatchGet(ctx context.Context, keys [][]byte) ([][]byte, error) {
+	// idxs are the positions of the keys owned by each member.
+	idxs := make([][]int, len(s.members))
+	for i, k := range keys {
+		owner, err := s.Owner(k)
+		if err != nil {
+			return nil, err
+		}
+		idxs[owner] = append(idxs[owner], i)
+	}
+	vals := make([][]byte, len(keys))
+	g, gctx := errgroup.WithContext(ctx)
+	for owner, pos := range idxs {
+		if len(pos) == 0 {
+			continue
+		}
+		g.Go(func() error {
+			batch := make([][]byte, len(pos))
+			for j, i := range pos {
+				batch[j] = keys[i]
+			}
+			batchVals, err := s.members[owner].Reader.BatchGet(gctx, batch)
+			if err != nil {
+				return errors.WithMessagef(err, "federation: read from %s", s.members[owner].Name)
+			}
+			for j, i := range pos {
+				vals[i] = batchVals[j]
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
+
+// Scan returns at most limit key-value pairs in [startKey, endKey) in the
+// order of the keys. Since the keys of the range may be owned by any member,
+// all members are scanned concurrently and the results are merged. The keys
+// not owned by the member they are read from, e.g. left by a resharding, are
+// skipped.
+func (s *Sharded) Scan(ctx context.Context, startKey, endKey []byte, limit int) ([][]byte, [][]byte, error) {
+	results := make([]result, len(s.members))
+	g, gctx := errgroup.WithContext(ctx)
+	for i := range s.members {
+		g.Go(func() error {
+			res, err := s.scanMember(gctx, i, startKey, endKey, limit)
+			if err != nil {
+				return errors.WithMessagef(err, "federation: read from %s", s.members[i].Name)
+			}
+			results[i] = res
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, nil, err
+	}
+
+	// Merge the sorted results. The number of the members is small, so the
+	// smallest key is picked by a linear search.
+	var keys, vals [][]byte
+	pos := make([]int, len(results))
+	for len(keys) < limit {
+		next := -1
+		for i, r := range results {
+			if pos[i] >= len(r.keys) {
+				continue
+			}
+			if next < 0 || bytes.Compare(r.keys[pos[i]], results[next].keys[pos[next]]) < 0 {
+				next = i
+			}
+		}
+		if next < 0 {
+			break
+		}
+		keys = append(keys, results[next].keys[pos[next]])
+		vals = append(vals, results[next].values[pos[next]])
+		pos[next]++
+	}
+	return keys, vals, nil
+}
+
+// scanMember scans at most limit pairs owned by the i-th member.
+func (s *Sharded) scanMember(ctx context.Context, i int, startKey, endKey []byte, limit int) (result, error) {
+	var res result
+	for len(res.keys) < limit {
+		want := limit - len(res.keys)
+		keys, vals, err := s.members[i].Reader.Scan(ctx, startKey, endKey, want)
+		if err != nil {
+			return result{}, err
+		}
+		for j, k := range keys {
+			if s.shard(k) == i {
+				res.keys = append(res.keys, k)
+				res.values = append(res.values, vals[j])
+			}
+		}
+		if len(keys) < want {
+			break
+		}
+		startKey = kv.NextKey(keys[len(keys)-1])
+	}
+	return res, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package federation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsistentHash(t *testing.T) {
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("key%d", i))
+	}
+	shard := ConsistentHash([]string{"a", "b", "c"}, 0)
+	counts := make([]int, 3)
+	for _, k := range keys {
+		counts[shard(k)]++
+	}
+	for _, c := range counts {
+		require.Greater(t, c, 200)
+	}
+
+	// The order of the names doesn't matter.
+	reordered := ConsistentHash([]string{"c", "a", "b"}, 0)
+	names := []string{"a", "b", "c"}
+	reorderedNames := []string{"c", "a", "b"}
+	for _, k := range keys {
+		require.Equal(t, names[shard(k)], reorderedNames[reordered(k)])
+	}
+
+	// Adding a member only moves the keys to it.
+	grown := ConsistentHash([]string{"a", "b", "c", "d"}, 0)
+	moved := 0
+	for _, k := range keys {
+		if i := grown(k); i != shard(k) {
+			require.Equal(t, 3, i)
+			moved++
+		}
+	}
+	require.Greater(t, moved, 100)
+	require.Less(t, moved, 400)
+
+	require.Equal(t, -1, ConsistentHash(nil, 0)([]byte("k")))
+}
+
+func TestSharded(t *testing.T) {
+	_, err := NewSharded(nil)
+	require.ErrorIs(t, err, ErrNoMember)
+
+	// Keys starting with "a" are owned by the first member, the others by the second.
+	shard := func(key []byte) int {
+		if key[0] == 'a' {
+			return 0
+		}
+		return 1
+	}
+	a := &mockReader{data: map[string][]byte{"a1": []byte("1"), "a3": []byte("3")}}
+	b := &mockReader{data: map[string][]byte{"b2": []byte("2"), "b4": []byte("4"), "a2": []byte("stale")}}
+	s, err := NewSharded(shard, member("a", a, 0), member("b", b, 0))
+	require.NoError(t, err)
+
+	val, err := s.Get(context.Background(), []byte("b2"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("2"), val)
+	val, err = s.Get(context.Background(), []byte("a2"))
+	require.NoError(t, err)
+	require.Nil(t, val)
+
+	vals, err := s.BatchGet(context.Background(), [][]byte{[]byte("b4"), []byte("a1"), []byte("a9"), []byte("b2")})
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("4"), []byte("1"), nil, []byte("2")}, vals)
+
+	// The key not owned by the member is skipped.
+	keys, vals, err := s.Scan(context.Background(), []byte("a2"), nil, 3)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("a3"), []byte("b2"), []byte("b4")}, keys)
+	require.Equal(t, [][]byte{[]byte("3"), []byte("2"), []byte("4")}, vals)
+	keys, _, err = s.Scan(context.Background(), nil, nil, 1)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("a1")}, keys)
+
+	b.err = errors.New("unavailable")
+	_, err = s.BatchGet(context.Background(), [][]byte{[]byte("a1"), []byte("b2")})
+	require.ErrorContains(t, err, "read from b")
+	_, _, err = s.Scan(context.Background(), nil, nil, 10)
+	require.ErrorContains(t, err, "unavailable")
+
+	s, err = NewSharded(func([]byte) int { return 2 }, member("a", a, 0))
+	require.NoError(t, err)
+	_, err = s.Get(context.Background(), []byte("a1"))
+	require.Error(t, err)
+}
@@ -0,0 +1,467 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ingest loads sorted key-value pairs into a cluster in bulk through
+// the ImportSST service of TiKV, the path taken by the import and restore
+// tools. For each region, the pairs are written as an SST file to every peer,
+// and then the files are ingested by the leader through raft, so the data
+// skips the transaction layer and becomes visible as committed at the given
+// commit ts.
+//
+// Only the API V1 transactional keyspace is supported: the keys are written
+// as they are, and the SST files are built by the stores.
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/pingcap/kvproto/pkg/errorpb"
+	"github.com/pingcap/kvproto/pkg/import_sstpb"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config"
+	"github.com/tikv/client-go/v2/tikv"
+	"github.com/tikv/client-go/v2/util/redact"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	defaultMaxBackoff  = 60000
+	defaultBatchSize   = 4096
+	defaultConcurrency = 4
+	writeCF            = "default"
+)
+
+// Pair is a key-value pair to ingest.
+type Pair struct {
+	Key   []byte
+	Value []byte
+}
+
+// Stats is the statistics of an ingest.
+type Stats struct {
+	// Regions is the number of the SST files ingested, one per region.
+	Regions int64
+	Pairs   int64
+	Bytes   int64
+	// Retries is the number of the times a region is retried because of
+	// region errors or RPC failures.
+	Retries int64
+}
+
+// ImporterDialer connects to the import service of a TiKV store.
+type ImporterDialer func(ctx context.Context, addr string) (import_sstpb.ImportSSTClient, error)
+
+// Client ingests pairs into the cluster of a KVStore.
+type Client struct {
+	store  *tikv.KVStore
+	dialer ImporterDialer
+
+	mu        sync.Mutex
+	conns     map[string]*grpc.ClientConn
+	importers map[string]import_sstpb.ImportSSTClient
+	addrs     map[uint64]string
+}
+
+// ClientOpt configures a Client.
+type ClientOpt func(*Client)
+
+// WithImporterDialer replaces the default dialer, which connects to the stores
+// with the security config of the global config. The connections made by the
+// dialer are not closed by the Client.
+func WithImporterDialer(dialer ImporterDialer) ClientOpt {
+	return func(c *Client) {
+		c.dialer = dialer
+	}
+}
+
+// NewClient creates a Client ingesting pairs into the cluster of the store.
+func NewClient(store *tikv.KVStore, opts ...ClientOpt) *Client {
+	c := &Client{
+		store:     store,
+		conns:     make(map[string]*grpc.ClientConn),
+		importers: make(map[string]import_sstpb.ImportSSTClient),
+		addrs:     make(map[uint64]string),
+	}
+	c.dialer = c.dial
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Close closes the connections made by the default dialer.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var firstErr error
+	for addr, conn := range c.conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = errors.WithStack(err)
+		}
+		delete(c.conns, addr)
+	}
+	c.importers = make(map[string]import_sstpb.ImportSSTClient)
+	return firstErr
+}
+
+type options struct {
+	maxBackoff  int
+	batchSize   int
+	concurrency int
+	splitEvery  int
+	scatter     bool
+}
+
+// Option configures an ingest.
+type Option func(*options)
+
+// WithMaxBackoff sets the total time in milliseconds a region can back off
+// for retrying, the default is 60 seconds.
+func WithMaxBackoff(ms int) Option {
+	return func(o *options) {
+		o.maxBackoff = ms
+	}
+}
+
+// WithBatchSize sets the number of the pairs sent in a message of the write
+// streams, the default is 4096.
+func WithBatchSize(size int) Option {
+	return func(o *options) {
+		o.batchSize = size
+	}
+}
+
+// WithConcurrency sets the number of the regions ingested concurrently, the
+// default is 4.
+func WithConcurrency(concurrency int) Option {
+	return func(o *options) {
+		o.concurrency = concurrency
+	}
+}
+
+// WithPreSplit splits the regions before ingesting, so that each region gets
+// about pairsPerRegion pairs, and scatters the new regions if scatter is true.
+// Pre-splitting an empty range avoids the large regions being split by the
+// stores after the ingest.
+func WithPreSplit(pairsPerRegion int, scatter bool) Option {
+	return func(o *options) {
+		o.splitEvery = pairsPerRegion
+		o.scatter = scatter
+	}
+}
+
+// Ingest ingests the pairs, which must be sorted by the keys without
+// duplicates, as committed at commitTS. The existing versions of the keys are
+// kept, so commitTS should be newer than them. It stops at the first error,
+// and the regions ingested before it stay ingested, so a failed ingest can be
+// retried as a whole.
+func (c *Client) Ingest(ctx context.Context, pairs []Pair, commitTS uint64, opts ...Option) (*Stats, error) {
+	o := &options{maxBackoff: defaultMaxBackoff, batchSize: defaultBatchSize, concurrency: defaultConcurrency}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.batchSize <= 0 {
+		o.batchSize = defaultBatchSize
+	}
+	if o.concurrency <= 0 {
+		o.concurrency = defaultConcurrency
+	}
+	if commitTS == 0 {
+		return nil, errors.New("commit ts of the ingest is not set")
+	}
+	for i := 1; i < len(pairs); i++ {
+		if bytes.Compare(pairs[i-1].Key, pairs[i].Key) >= 0 {
+			return nil, errors.Errorf("pairs are not sorted or have duplicates at key %s", redact.Key(pairs[i].Key))
+		}
+	}
+	stats := &Stats{}
+	if len(pairs) == 0 {
+		return stats, nil
+	}
+	if err := c.preSplit(ctx, pairs, o); err != nil {
+		return stats, err
+	}
+
+	// Group the pairs by the regions now, the groups are regrouped on their
+	// own if the regions change.
+	var groups [][]Pair
+	bo := tikv.NewBackofferWithVars(ctx, o.maxBackoff, nil)
+	for rest := pairs; len(rest) > 0; {
+		loc, err := c.store.GetRegionCache().LocateKey(bo, rest[0].Key)
+		if err != nil {
+			return stats, err
+		}
+		n := regionPairs(loc, rest)
+		groups = append(groups, rest[:n])
+		rest = rest[n:]
+	}
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(o.concurrency)
+	for i := range groups {
+		group := groups[i]
+		g.Go(func() error {
+			return c.ingestRange(ctx, group, commitTS, o, stats)
+		})
+	}
+	return stats, g.Wait()
+}
+
+func (c *Client) preSplit(ctx context.Context, pairs []Pair, o *options) error {
+	if o.splitEvery <= 0 || len(pairs) <= o.splitEvery {
+		return nil
+	}
+	var keys [][]byte
+	for i := o.splitEvery; i < len(pairs); i += o.splitEvery {
+		keys = append(keys, pairs[i].Key)
+	}
+	regionIDs, err := c.store.SplitRegions(ctx, keys, o.scatter, nil)
+	if err != nil {
+		return err
+	}
+	if o.scatter {
+		for _, id := range regionIDs {
+			if err = c.store.WaitScatterRegionFinish(ctx, id, 0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// regionPairs returns the number of the leading pairs in the region.
+func regionPairs(loc *tikv.KeyLocation, pairs []Pair) int {
+	if len(loc.EndKey) == 0 {
+		return len(pairs)
+	}
+	return sort.Search(len(pairs), func(i int) bool {
+		return bytes.Compare(pairs[i].Key, loc.EndKey) >= 0
+	})
+}
+
+// ingestRange ingests the pairs region by region, locating the regions again
+// after the retryable errors.
+func (c *Client) ingestRange(ctx context.Context, pairs []Pair, commitTS uint64, o *options, stats *Stats) error {
+	bo := tikv.NewBackofferWithVars(ctx, o.maxBackoff, nil)
+	for len(pairs) > 0 {
+		loc, err := c.store.GetRegionCache().LocateKey(bo, pairs[0].Key)
+		if err != nil {
+			return err
+		}
+		n := regionPairs(loc, pairs)
+		retry, err := c.ingestRegion(bo, loc, pairs[:n], commitTS, o)
+		if err != nil {
+			return err
+		}
+		if retry {
+			atomic.AddInt64(&stats.Retries, 1)
+			continue
+		}
+		var size int64
+		for _, pair := range pairs[:n] {
+			size += int64(len(pair.Key) + len(pair.Value))
+		}
+		atomic.AddInt64(&stats.Regions, 1)
+		atomic.AddInt64(&stats.Pairs, int64(n))
+		atomic.AddInt64(&stats.Bytes, size)
+		pairs = pairs[n:]
+	}
+	return nil
+}
+
+// ingestRegion writes the pairs to the peers of the region and ingests them
+// on the leader. It returns true if the region should be located and retried.
+func (c *Client) ingestRegion(bo *tikv.Backoffer, loc *tikv.KeyLocation, pairs []Pair, commitTS uint64, o *options) (bool, error) {
+	cache := c.store.GetRegionCache()
+	region := cache.GetCachedRegionWithRLock(loc.Region)
+	if region == nil {
+		return true, bo.Backoff(tikv.BoRegionMiss(), errors.Errorf("region %d is evicted", loc.Region.GetID()))
+	}
+	meta := region.GetMeta()
+	leaderID := region.GetLeaderPeerID()
+	id := uuid.New()
+	sst := &import_sstpb.SSTMeta{
+		Uuid:        id[:],
+		Range:       &import_sstpb.Range{Start: pairs[0].Key, End: pairs[len(pairs)-1].Key},
+		CfName:      writeCF,
+		RegionId:    meta.GetId(),
+		RegionEpoch: meta.GetRegionEpoch(),
+		ApiVersion:  kvrpcpb.APIVersion_V1,
+	}
+
+	var (
+		leader *metapb.Peer
+		metas  []*import_sstpb.SSTMeta
+	)
+	for _, peer := range meta.GetPeers() {
+		resp, err := c.write(bo.GetCtx(), meta, peer, sst, pairs, commitTS, o.batchSize)
+		if err != nil {
+			return true, bo.Backoff(tikv.BoTiKVRPC(), err)
+		}
+		if resp.GetError() != nil {
+			return true, c.onRegionError(bo, loc, resp.GetError().GetStoreError(), resp.GetError().GetMessage())
+		}
+		if peer.GetId() == leaderID {
+			leader, metas = peer, resp.GetMetas()
+		}
+	}
+	if leader == nil {
+		cache.InvalidateCachedRegion(loc.Region)
+		return true, bo.Backoff(tikv.BoRegionMiss(), errors.Errorf("leader of region %d is unknown", meta.GetId()))
+	}
+
+	importer, err := c.importer(bo.GetCtx(), leader.GetStoreId())
+	if err != nil {
+		return true, bo.Backoff(tikv.BoTiKVRPC(), err)
+	}
+	resp, err := importer.MultiIngest(bo.GetCtx(), &import_sstpb.MultiIngestRequest{
+		Context: &kvrpcpb.Context{RegionId: meta.GetId(), RegionEpoch: meta.GetRegionEpoch(), Peer: leader},
+		Ssts:    metas,
+	})
+	if err != nil {
+		return true, bo.Backoff(tikv.BoTiKVRPC(), errors.WithStack(err))
+	}
+	if resp.GetError() != nil {
+		return true, c.onRegionError(bo, loc, resp.GetError(), "")
+	}
+	return false, nil
+}
+
+func (c *Client) write(ctx context.Context, region *metapb.Region, peer *metapb.Peer, sst *import_sstpb.SSTMeta, pairs []Pair, commitTS uint64, batchSize int) (*import_sstpb.WriteResponse, error) {
+	importer, err := c.importer(ctx, peer.GetStoreId())
+	if err != nil {
+		return nil, err
+	}
+	stream, err := importer.Write(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	reqCtx := &kvrpcpb.Context{RegionId: region.GetId(), RegionEpoch: region.GetRegionEpoch(), Peer: peer}
+	err = stream.Send(&import_sstpb.WriteRequest{Chunk: &import_sstpb.WriteRequest_Meta{Meta: sst}, Context: reqCtx})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for start := 0; start < len(pairs); start += batchSize {
+		end := min(start+batchSize, len(pairs))
+		batch := &import_sstpb.WriteBatch{CommitTs: commitTS, Pairs: make([]*import_sstpb.Pair, 0, end-start)}
+		for _, pair := range pairs[start:end] {
+			batch.Pairs = append(batch.Pairs, &import_sstpb.Pair{Key: pair.Key, Value: pair.Value})
+		}
+		err = stream.Send(&import_sstpb.WriteRequest{Chunk: &import_sstpb.WriteRequest_Batch{Batch: batch}, Context: reqCtx})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	return resp, errors.WithStack(err)
+}
+
+// onRegionError updates the region cache by the error and backs off.
+func (c *Client) onRegionError(bo *tikv.Backoffer, loc *tikv.KeyLocation, regionErr *errorpb.Error, msg string) error {
+	cache := c.store.GetRegionCache()
+	if regionErr == nil {
+		return bo.Backoff(tikv.BoTiKVRPC(), errors.Errorf("ingest region %d: %s", loc.Region.GetID(), msg))
+	}
+	err := errors.Errorf("ingest region %d: %s", loc.Region.GetID(), regionErr.String())
+	switch {
+	case regionErr.GetNotLeader() != nil:
+		if leader := regionErr.GetNotLeader().GetLeader(); leader != nil {
+			cache.UpdateLeader(loc.Region, leader, 0)
+		} else {
+			cache.InvalidateCachedRegion(loc.Region)
+		}
+		return bo.Backoff(tikv.BoRegionMiss(), err)
+	case regionErr.GetServerIsBusy() != nil:
+		return bo.Backoff(tikv.BoTiKVRPC(), err)
+	default:
+		// The epoch changes, the region is missing or the keys are out of
+		// it, locate the region again.
+		cache.InvalidateCachedRegion(loc.Region)
+		return bo.Backoff(tikv.BoRegionMiss(), err)
+	}
+}
+
+// importer returns the client of the import service of the store.
+func (c *Client) importer(ctx context.Context, storeID uint64) (import_sstpb.ImportSSTClient, error) {
+	c.mu.Lock()
+	addr, ok := c.addrs[storeID]
+	c.mu.Unlock()
+	if !ok {
+		store, err := c.store.GetPDClient().GetStore(ctx, storeID)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if store == nil || store.GetState() == metapb.StoreState_Tombstone {
+			return nil, errors.Errorf("store %d is removed", storeID)
+		}
+		addr = store.GetAddress()
+		c.mu.Lock()
+		c.addrs[storeID] = addr
+		c.mu.Unlock()
+	}
+
+	c.mu.Lock()
+	importer, ok := c.importers[addr]
+	c.mu.Unlock()
+	if ok {
+		return importer, nil
+	}
+	importer, err := c.dialer(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if existing, ok := c.importers[addr]; ok {
+		return existing, nil
+	}
+	c.importers[addr] = importer
+	return importer, nil
+}
+
+// dial is the default ImporterDialer.
+func (c *Client) dial(ctx context.Context, addr string) (import_sstpb.ImportSSTClient, error) {
+	security := config.GetGlobalConfig().Security
+	tlsConfig, err := security.ToTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.DialContext(ctx, addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if existing, ok := c.conns[addr]; ok {
+		// Dialed concurrently, keep the connection in use.
+		conn.Close()
+		conn = existing
+	} else {
+		c.conns[addr] = conn
+	}
+	return import_sstpb.NewImportSSTClient(conn), nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/pingcap/kvproto/pkg/errorpb"
+	"github.com/pingcap/kvproto/pkg/import_sstpb"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/testutils"
+	"github.com/tikv/client-go/v2/tikv"
+	"google.golang.org/grpc"
+)
+
+// fakeImporter stages the written pairs by the SST uuid and records the
+// ingested ones by the region.
+type fakeImporter struct {
+	import_sstpb.ImportSSTClient
+
+	mu            sync.Mutex
+	staged        map[string][]*import_sstpb.Pair
+	ingested      map[uint64][]*import_sstpb.Pair
+	commitTS      uint64
+	failIngestion int
+}
+
+func (f *fakeImporter) Write(ctx context.Context, opts ...grpc.CallOption) (import_sstpb.ImportSST_WriteClient, error) {
+	return &fakeWriteStream{f: f}, nil
+}
+
+func (f *fakeImporter) MultiIngest(ctx context.Context, in *import_sstpb.MultiIngestRequest, opts ...grpc.CallOption) (*import_sstpb.IngestResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failIngestion > 0 {
+		f.failIngestion--
+		return &import_sstpb.IngestResponse{Error: &errorpb.Error{NotLeader: &errorpb.NotLeader{RegionId: in.Context.RegionId}}}, nil
+	}
+	for _, sst := range in.Ssts {
+		f.ingested[sst.RegionId] = append(f.ingested[sst.RegionId], f.staged[string(sst.Uuid)]...)
+	}
+	return &import_sstpb.IngestResponse{}, nil
+}
+
+type fakeWriteStream struct {
+	grpc.ClientStream
+	f     *fakeImporter
+	meta  *import_sstpb.SSTMeta
+	pairs []*import_sstpb.Pair
+}
+
+func (s *fakeWriteStream) Send(req *import_sstpb.WriteRequest) error {
+	if meta := req.GetMeta(); meta != nil {
+		s.meta = meta
+		return nil
+	}
+	s.f.mu.Lock()
+	s.f.commitTS = req.GetBatch().GetCommitTs()
+	s.f.mu.Unlock()
+	s.pairs = append(s.pairs, req.GetBatch().GetPairs()...)
+	return nil
+}
+
+func (s *fakeWriteStream) CloseAndRecv() (*import_sstpb.WriteResponse, error) {
+	s.f.mu.Lock()
+	s.f.staged[string(s.meta.Uuid)] = s.pairs
+	s.f.mu.Unlock()
+	return &import_sstpb.WriteResponse{Metas: []*import_sstpb.SSTMeta{s.meta}}, nil
+}
+
+func TestIngest(t *testing.T) {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	require.NoError(t, err)
+	testutils.BootstrapWithSingleStore(cluster)
+	store, err := tikv.NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	require.NoError(t, err)
+	defer store.Close()
+
+	importer := &fakeImporter{
+		staged:        make(map[string][]*import_sstpb.Pair),
+		ingested:      make(map[uint64][]*import_sstpb.Pair),
+		failIngestion: 1,
+	}
+	c := NewClient(store, WithImporterDialer(func(context.Context, string) (import_sstpb.ImportSSTClient, error) {
+		return importer, nil
+	}))
+	defer c.Close()
+
+	ctx := context.Background()
+	_, err = c.Ingest(ctx, []Pair{{Key: []byte("b")}, {Key: []byte("a")}}, 100)
+	require.Error(t, err)
+
+	var pairs []Pair
+	for i := 0; i < 30; i++ {
+		pairs = append(pairs, Pair{Key: []byte(fmt.Sprintf("k%02d", i)), Value: []byte("v")})
+	}
+	stats, err := c.Ingest(ctx, pairs, 100, WithPreSplit(10, false), WithBatchSize(4))
+	require.NoError(t, err)
+	require.Equal(t, int64(3), stats.Regions)
+	require.Equal(t, int64(30), stats.Pairs)
+	require.Equal(t, int64(30*4), stats.Bytes)
+	require.Equal(t, int64(1), stats.Retries)
+	require.Equal(t, uint64(100), importer.commitTS)
+
+	bo := tikv.NewBackofferWithVars(ctx, 1000, nil)
+	require.Len(t, importer.ingested, 3)
+	for regionID, ingested := range importer.ingested {
+		require.Len(t, ingested, 10)
+		loc, err := store.GetRegionCache().LocateKey(bo, ingested[0].Key)
+		require.NoError(t, err)
+		require.Equal(t, regionID, loc.Region.GetID())
+		require.True(t, loc.Contains(ingested[9].Key))
+	}
+}
@@ -39,6 +39,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/suite"
+	"github.com/tikv/client-go/v2/config"
+	tikverr "github.com/tikv/client-go/v2/error"
 	"github.com/tikv/client-go/v2/metrics"
 	"github.com/tikv/client-go/v2/oracle"
 	"github.com/tikv/client-go/v2/tikv"
@@ -225,6 +227,63 @@ func (s *testOnePCSuite) Test1PCDisallowMultiRegion() {
 	}
 }
 
+func (s *testOnePCSuite) Test1PCRequire() {
+	// This test doesn't support tikv mode because it needs setting the region
+	// split.
+	if *withTiKV {
+		return
+	}
+
+	ctx := context.Background()
+	txn := s.begin1PC()
+	txn.SetRequire1PC(true)
+	s.Nil(txn.Set([]byte("k0"), []byte("v0")))
+	s.Nil(txn.Commit(ctx))
+	s.True(txn.GetCommitter().IsOnePC())
+	s.Equal("", txn.OnePCIneligibleReason())
+
+	// The keys in different regions can't be committed by 1PC.
+	loc, err := s.store.GetRegionCache().LocateKey(s.bo, []byte("k2"))
+	s.Nil(err)
+	newRegionID := s.cluster.AllocID()
+	newPeerID := s.cluster.AllocID()
+	s.cluster.Split(loc.Region.GetID(), newRegionID, []byte("k2"), []uint64{newPeerID}, newPeerID)
+
+	txn = s.begin()
+	txn.SetRequire1PC(true)
+	s.Nil(txn.Set([]byte("k1"), []byte("v1")))
+	s.Nil(txn.Set([]byte("k3"), []byte("v3")))
+	err = txn.Commit(ctx)
+	s.True(tikverr.IsErrOnePCIneligible(err))
+	s.Equal("multiple-batches", txn.OnePCIneligibleReason())
+
+	ver, err := s.store.CurrentTimestamp(oracle.GlobalTxnScope)
+	s.Nil(err)
+	_, err = s.store.GetSnapshot(ver).Get(ctx, []byte("k1"))
+	s.True(tikverr.IsErrNotFound(err))
+
+	defer config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.OnePC.KeysLimit = 1
+	})()
+
+	// The transaction over the limits is rejected if 1PC is required.
+	txn = s.begin()
+	txn.SetRequire1PC(true)
+	s.Nil(txn.Set([]byte("k0"), []byte("v0")))
+	s.Nil(txn.Set([]byte("k1"), []byte("v1")))
+	err = txn.Commit(ctx)
+	s.True(tikverr.IsErrOnePCIneligible(err))
+	s.Equal("keys-limit", txn.OnePCIneligibleReason())
+
+	// Otherwise it falls back to 2PC.
+	txn = s.begin1PC()
+	s.Nil(txn.Set([]byte("k0"), []byte("v0")))
+	s.Nil(txn.Set([]byte("k1"), []byte("v1")))
+	s.Nil(txn.Commit(ctx))
+	s.False(txn.GetCommitter().IsOnePC())
+	s.Equal("keys-limit", txn.OnePCIneligibleReason())
+}
+
 // It's just a simple validation of linearizability.
 // Extra tests are needed to test this feature with the control of the TiKV cluster.
 func (s *testOnePCSuite) Test1PCLinearizability() {
@@ -1326,6 +1326,36 @@ func (s *testCommitterSuite) TestAggressiveLockingLockOnlyIfExists() {
 	s.NoError(txn.Rollback())
 }
 
+func (s *testCommitterSuite) TestFairLocking() {
+	txn := s.begin()
+	txn.SetPessimistic(true)
+	txn.SetFairLocking(true)
+	s.True(txn.IsFairLockingEnabled())
+
+	txn2 := s.begin()
+	s.NoError(txn2.Set([]byte("k1"), []byte("v1")))
+	s.NoError(txn2.Set([]byte("k2"), []byte("v2")))
+	s.NoError(txn2.Commit(context.Background()))
+
+	// Single key is locked with conflict, and the conflict is reported by lockCtx.
+	lockCtx := &kv.LockCtx{ForUpdateTS: txn.StartTS(), WaitStartTime: time.Now()}
+	s.NoError(txn.LockKeys(context.Background(), lockCtx, []byte("k1")))
+	s.Equal(txn2.CommitTS(), lockCtx.MaxLockedWithConflictTS)
+	s.Equal(txn2.CommitTS(), lockCtx.Values["k1"].LockedWithConflictTS)
+	s.False(txn.IsInAggressiveLockingMode())
+	flags, err := txn.GetMemBuffer().GetFlags([]byte("k1"))
+	s.NoError(err)
+	s.True(flags.HasLocked())
+
+	// Locking multiple keys still reports write conflict.
+	lockCtx = &kv.LockCtx{ForUpdateTS: txn.StartTS(), WaitStartTime: time.Now()}
+	err = txn.LockKeys(context.Background(), lockCtx, []byte("k2"), []byte("k3"))
+	s.IsType(errors.Cause(err), &tikverr.ErrWriteConflict{})
+	s.False(txn.IsInAggressiveLockingMode())
+
+	s.NoError(txn.Rollback())
+}
+
 func (s *testCommitterSuite) TestAggressiveLockingSwitchPrimary() {
 	txn := s.begin()
 	txn.SetPessimistic(true)
@@ -0,0 +1,93 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/txnkv/allocator"
+)
+
+func TestIDAllocator(t *testing.T) {
+	store := NewTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+	key := []byte("test_id_allocator")
+
+	alloc := allocator.NewAllocator(store, key, allocator.WithStep(10))
+	id, err := alloc.Alloc(ctx)
+	require.Nil(t, err)
+	require.Equal(t, uint64(1), id)
+	first, err := alloc.AllocN(ctx, 3)
+	require.Nil(t, err)
+	require.Equal(t, uint64(2), first)
+
+	// A new allocator, e.g. after a crash, never reuses the cached IDs.
+	alloc2 := allocator.NewAllocator(store, key, allocator.WithStep(10))
+	id, err = alloc2.Alloc(ctx)
+	require.Nil(t, err)
+	require.Equal(t, uint64(11), id)
+
+	// Allocating more IDs than the step.
+	first, err = alloc2.AllocN(ctx, 100)
+	require.Nil(t, err)
+	require.Equal(t, uint64(21), first)
+
+	require.Nil(t, alloc.Rebase(ctx, 1000))
+	id, err = alloc.Alloc(ctx)
+	require.Nil(t, err)
+	require.Equal(t, uint64(1001), id)
+
+	// Concurrent allocators never allocate the same ID.
+	var (
+		mu   sync.Mutex
+		ids  = make(map[uint64]struct{})
+		wg   sync.WaitGroup
+		errs = make(chan error, 4)
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a := allocator.NewAllocator(store, key, allocator.WithStep(5))
+			last := uint64(0)
+			for j := 0; j < 20; j++ {
+				id, err := a.Alloc(ctx)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if id <= last {
+					errs <- fmt.Errorf("ID %d is not greater than %d", id, last)
+					return
+				}
+				last = id
+				mu.Lock()
+				ids[id] = struct{}{}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.Nil(t, err)
+	}
+	require.Len(t, ids, 80)
+}
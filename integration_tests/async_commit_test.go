@@ -663,3 +663,110 @@ func (s *testAsyncCommitSuite) TestAsyncCommitLifecycleHooks() {
 	wg.Wait()
 	s.Equal(reachedPost.Load(), true)
 }
+
+func (s *testAsyncCommitSuite) TestCleanupOrphanAsyncCommitLocks() {
+	ctx := context.Background()
+	k1, k2 := []byte("orphan_1"), []byte("orphan_2")
+	startKey, endKey := []byte("orphan_"), []byte("orphan_~")
+	txn := s.beginAsyncCommit()
+	s.Nil(txn.Set(k1, k1))
+	s.Nil(txn.Set(k2, k2))
+	committer, err := txn.NewCommitter(1)
+	s.Nil(err)
+	committer.SetUseAsyncCommit()
+	committer.SetLockTTL(1)
+	s.Nil(committer.PrewriteAllMutations(ctx))
+	s.True(committer.IsAsyncCommit())
+	// Make sure the locks are expired and older than the threshold.
+	time.Sleep(10 * time.Millisecond)
+
+	// Dry-run only reports the locks.
+	stat, err := s.store.CleanupOrphanAsyncCommitLocks(ctx, startKey, endKey, 0, tikv.WithCleanupDryRun())
+	s.Nil(err)
+	s.Equal(2, stat.ScannedLocks)
+	s.Len(stat.Locks, 2)
+	s.Equal([]uint64{txn.StartTS()}, stat.ResolvedTxns)
+	s.Empty(stat.SkippedTxns)
+	s.Equal(txn.StartTS(), s.mustGetLock(k1).TxnID)
+	s.Equal(txn.StartTS(), s.mustGetLock(k2).TxnID)
+
+	// Dry-run checks the TTL like the cleanup does, so the alive locks are
+	// reported as skipped.
+	k3 := []byte("orphan_3")
+	aliveTxn := s.beginAsyncCommit()
+	s.Nil(aliveTxn.Set(k3, k3))
+	aliveCommitter, err := aliveTxn.NewCommitter(1)
+	s.Nil(err)
+	aliveCommitter.SetUseAsyncCommit()
+	aliveCommitter.SetLockTTL(uint64(time.Minute.Milliseconds()))
+	s.Nil(aliveCommitter.PrewriteAllMutations(ctx))
+	stat, err = s.store.CleanupOrphanAsyncCommitLocks(ctx, startKey, endKey, 0, tikv.WithCleanupDryRun())
+	s.Nil(err)
+	s.Equal(3, stat.ScannedLocks)
+	s.Equal([]uint64{txn.StartTS()}, stat.ResolvedTxns)
+	s.Equal([]uint64{aliveTxn.StartTS()}, stat.SkippedTxns)
+	s.Equal(aliveTxn.StartTS(), s.mustGetLock(k3).TxnID)
+	s.Nil(aliveCommitter.CleanupMutations(ctx))
+
+	// Locks newer than the threshold are not touched.
+	stat, err = s.store.CleanupOrphanAsyncCommitLocks(ctx, startKey, endKey, time.Hour)
+	s.Nil(err)
+	s.Equal(0, stat.ScannedLocks)
+	s.Equal(txn.StartTS(), s.mustGetLock(k1).TxnID)
+
+	// All secondaries are prewritten, so the transaction is committed.
+	stat, err = s.store.CleanupOrphanAsyncCommitLocks(ctx, startKey, endKey, 0, tikv.WithCleanupRateLimit(100))
+	s.Nil(err)
+	s.Equal(2, stat.ScannedLocks)
+	s.Empty(stat.Locks)
+	s.Equal([]uint64{txn.StartTS()}, stat.ResolvedTxns)
+	s.mustPointGet(k1, k1)
+	s.mustPointGet(k2, k2)
+
+	// Cleanup is idempotent.
+	stat, err = s.store.CleanupOrphanAsyncCommitLocks(ctx, startKey, endKey, 0)
+	s.Nil(err)
+	s.Equal(0, stat.ScannedLocks)
+}
+
+func (s *testAsyncCommitSuite) TestResolveAsyncCommitTxn() {
+	ctx := context.Background()
+	prewrite := func(ttl uint64, keys ...[]byte) (uint64, []byte) {
+		txn := s.beginAsyncCommit()
+		for _, k := range keys {
+			s.Nil(txn.Set(k, k))
+		}
+		committer, err := txn.NewCommitter(1)
+		s.Nil(err)
+		committer.SetUseAsyncCommit()
+		committer.SetLockTTL(ttl)
+		s.Nil(committer.PrewriteAllMutations(ctx))
+		s.True(committer.IsAsyncCommit())
+		return txn.StartTS(), committer.GetPrimaryKey()
+	}
+
+	// The primary lock is alive, so nothing is resolved.
+	k1, k2 := []byte("resolve_txn_1"), []byte("resolve_txn_2")
+	startTS, primary := prewrite(uint64(time.Minute.Milliseconds()), k1, k2)
+	status, err := s.store.GetLockResolver().ResolveAsyncCommitTxn(ctx, primary, startTS)
+	s.Nil(err)
+	s.NotZero(status.TTL())
+	s.Equal(startTS, s.mustGetLock(k1).TxnID)
+	s.Equal(startTS, s.mustGetLock(k2).TxnID)
+
+	// All secondaries are prewritten, so the transaction is committed.
+	k3, k4 := []byte("resolve_txn_3"), []byte("resolve_txn_4")
+	startTS, primary = prewrite(1, k3, k4)
+	time.Sleep(10 * time.Millisecond)
+	status, err = s.store.GetLockResolver().ResolveAsyncCommitTxn(ctx, primary, startTS)
+	s.Nil(err)
+	s.True(status.IsCommitted())
+	s.Greater(status.CommitTS(), startTS)
+	s.mustGetFromSnapshot(status.CommitTS(), k3, k3)
+	s.mustGetFromSnapshot(status.CommitTS(), k4, k4)
+
+	// The transaction is resolved already.
+	status, err = s.store.GetLockResolver().ResolveAsyncCommitTxn(ctx, primary, startTS)
+	s.Nil(err)
+	s.True(status.IsCommitted())
+}
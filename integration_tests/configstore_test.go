@@ -0,0 +1,90 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/txnkv/configstore"
+)
+
+func TestConfigStore(t *testing.T) {
+	store := NewTestStore(t)
+	defer store.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cs := configstore.NewStore(store, configstore.WithPrefix([]byte("test_config/")))
+	e, err := cs.Get(ctx, "batch-size")
+	require.Nil(t, err)
+	require.Equal(t, uint64(0), e.Version)
+
+	watch := cs.Watch(ctx, "batch-size", 10*time.Millisecond)
+	e = <-watch
+	require.Equal(t, uint64(0), e.Version)
+
+	version, err := cs.Set(ctx, "batch-size", []byte("128"))
+	require.Nil(t, err)
+	require.Equal(t, uint64(1), version)
+	e = <-watch
+	require.Equal(t, uint64(1), e.Version)
+	require.Equal(t, []byte("128"), e.Value)
+
+	_, err = cs.CompareAndSet(ctx, "batch-size", []byte("256"), 0)
+	require.Equal(t, configstore.ErrVersionMismatch, errors.Cause(err))
+	version, err = cs.CompareAndSet(ctx, "batch-size", []byte("256"), 1)
+	require.Nil(t, err)
+	require.Equal(t, uint64(2), version)
+	e = <-watch
+	require.Equal(t, []byte("256"), e.Value)
+
+	_, err = cs.Set(ctx, "timeout", []byte("1s"))
+	require.Nil(t, err)
+	entries, err := cs.List(ctx)
+	require.Nil(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "batch-size", entries[0].Name)
+	require.Equal(t, "timeout", entries[1].Name)
+
+	require.Nil(t, cs.Delete(ctx, "batch-size"))
+	e = <-watch
+	require.Equal(t, uint64(3), e.Version)
+	require.True(t, e.Deleted)
+	entries, err = cs.List(ctx)
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "timeout", entries[0].Name)
+	// Deleting again is a no-op.
+	require.Nil(t, cs.Delete(ctx, "batch-size"))
+
+	// The version keeps increasing after the setting is set again.
+	_, err = cs.CompareAndSet(ctx, "batch-size", []byte("64"), 0)
+	require.Equal(t, configstore.ErrVersionMismatch, errors.Cause(err))
+	version, err = cs.CompareAndSet(ctx, "batch-size", []byte("64"), 3)
+	require.Nil(t, err)
+	require.Equal(t, uint64(4), version)
+	e = <-watch
+	require.Equal(t, uint64(4), e.Version)
+	require.False(t, e.Deleted)
+	require.Equal(t, []byte("64"), e.Value)
+
+	cancel()
+	for range watch {
+	}
+}
@@ -782,6 +782,16 @@ func (s *testLockSuite) TestDeadlockReportWaitChain() {
 	s.Equal(len(waitChain), 2)
 	checkWaitChainEntry(txns, waitChain[0], 0, 1)
 	checkWaitChainEntry(txns, waitChain[1], 1, 0)
+	s.True(tikverr.IsErrDeadlock(err))
+	entries := dl.WaitForEntries()
+	s.Len(entries, 2)
+	for i, entry := range entries {
+		s.Equal(waitChain[i].Txn, entry.Txn)
+		s.Equal(waitChain[i].WaitForTxn, entry.WaitForTxn)
+		s.Equal(waitChain[i].Key, entry.Key)
+		s.Equal(time.Duration(waitChain[i].WaitTime)*time.Millisecond, entry.WaitTime)
+	}
+	s.Contains(err.Error(), fmt.Sprintf("txn %d waits for txn %d", txns[1].StartTS(), txns[0].StartTS()))
 
 	// Each transaction should be rolled back after its blocker being rolled back
 	waitAndRollback(txns, 1)
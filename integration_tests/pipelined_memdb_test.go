@@ -37,6 +37,7 @@ import (
 	"github.com/tikv/client-go/v2/txnkv/transaction"
 	"github.com/tikv/client-go/v2/txnkv/txnlock"
 	"github.com/tikv/client-go/v2/txnkv/txnsnapshot"
+	"github.com/tikv/client-go/v2/util/memquota"
 )
 
 const (
@@ -137,6 +138,37 @@ func (s *testPipelinedMemDBSuite) TestPipelinedAndFlush() {
 	}
 }
 
+func (s *testPipelinedMemDBSuite) TestPipelinedFlushReleasesMemQuota() {
+	quota := memquota.New(memquota.Config{Limit: 1 << 40, Policy: memquota.PolicyError})
+	memquota.SetGlobal(quota)
+	defer memquota.SetGlobal(nil)
+
+	txn, err := s.store.Begin(tikv.WithDefaultPipelinedTxn())
+	s.Nil(err)
+	write := func(prefix string) {
+		for i := 0; i < MinFlushKeys; i++ {
+			key := []byte(prefix + strconv.Itoa(i))
+			s.Nil(txn.Set(key, make([]byte, MinFlushSize/MinFlushKeys-len(key)+1)))
+		}
+	}
+	write("a")
+	flushed, err := txn.GetMemBuffer().Flush(false)
+	s.Nil(err)
+	s.True(flushed)
+	held := quota.Used()
+	s.Equal(int64(txn.Mem()), held)
+
+	// The footprint of the flushed mutations is released by the next flush.
+	write("b")
+	flushed, err = txn.GetMemBuffer().Flush(false)
+	s.Nil(err)
+	s.True(flushed)
+	s.Nil(txn.GetMemBuffer().FlushWait())
+	s.Less(quota.Used(), 2*held)
+	s.Nil(txn.Rollback())
+	s.Zero(quota.Used())
+}
+
 func (s *testPipelinedMemDBSuite) TestPipelinedMemDBBufferGet() {
 	ctx := context.Background()
 	txn, err := s.store.Begin(tikv.WithDefaultPipelinedTxn())
@@ -506,3 +538,60 @@ func (s *testPipelinedMemDBSuite) TestPipelinedDMLFailedByPKMaxTTLExceeded() {
 	s.NotNil(err)
 	s.ErrorContains(err, "ttl manager is closed")
 }
+
+func (s *testPipelinedMemDBSuite) TestPipelinedIter() {
+	ctx := context.Background()
+	// Committed data: a, b, c.
+	txn, err := s.store.Begin()
+	s.Nil(err)
+	for _, k := range []string{"a", "b", "c"} {
+		s.Nil(txn.Set([]byte(k), []byte("committed-"+k)))
+	}
+	s.Nil(txn.Commit(ctx))
+
+	txn, err = s.store.Begin(tikv.WithDefaultPipelinedTxn())
+	s.Nil(err)
+	// Flushed: overwrite b, delete c, put d and e.
+	s.Nil(txn.Set([]byte("b"), []byte("flushed-b")))
+	s.Nil(txn.Delete([]byte("c")))
+	s.Nil(txn.Set([]byte("d"), []byte("flushed-d")))
+	s.Nil(txn.Set([]byte("e"), []byte("flushed-e")))
+	flushed, err := txn.GetMemBuffer().Flush(true)
+	s.Nil(err)
+	s.True(flushed)
+	s.Nil(txn.GetMemBuffer().FlushWait())
+	// Local: delete d, overwrite e, put f.
+	s.Nil(txn.Delete([]byte("d")))
+	s.Nil(txn.Set([]byte("e"), []byte("local-e")))
+	s.Nil(txn.Set([]byte("f"), []byte("local-f")))
+
+	expected := [][2]string{
+		{"a", "committed-a"},
+		{"b", "flushed-b"},
+		{"e", "local-e"},
+		{"f", "local-f"},
+	}
+	var got [][2]string
+	it, err := txn.Iter([]byte("a"), []byte("z"))
+	s.Nil(err)
+	for it.Valid() {
+		got = append(got, [2]string{string(it.Key()), string(it.Value())})
+		s.Nil(it.Next())
+	}
+	it.Close()
+	s.Equal(expected, got)
+
+	got = got[:0]
+	it, err = txn.IterReverse([]byte("z"), []byte("a"))
+	s.Nil(err)
+	for it.Valid() {
+		got = append(got, [2]string{string(it.Key()), string(it.Value())})
+		s.Nil(it.Next())
+	}
+	it.Close()
+	for i, j := 0, len(got)-1; i < j; i, j = i+1, j-1 {
+		got[i], got[j] = got[j], got[i]
+	}
+	s.Equal(expected, got)
+	s.Nil(txn.Rollback())
+}
@@ -39,6 +39,7 @@ import (
 	"context"
 	"errors"
 	"sort"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/suite"
@@ -263,3 +264,67 @@ func (s *testRangeTaskSuite) TestRangeTaskError() {
 		s.testRangeTaskErrorImpl(concurrency)
 	}
 }
+
+type memCheckpointStore struct {
+	sync.Mutex
+	checkpoints map[string]rangetask.Checkpoint
+}
+
+func (m *memCheckpointStore) LoadCheckpoint(ctx context.Context, identifier string) (*rangetask.Checkpoint, error) {
+	m.Lock()
+	defer m.Unlock()
+	cp, ok := m.checkpoints[identifier]
+	if !ok {
+		return nil, nil
+	}
+	return &cp, nil
+}
+
+func (m *memCheckpointStore) SaveCheckpoint(ctx context.Context, identifier string, checkpoint *rangetask.Checkpoint) error {
+	m.Lock()
+	defer m.Unlock()
+	m.checkpoints[identifier] = *checkpoint
+	return nil
+}
+
+func (s *testRangeTaskSuite) TestRangeTaskCheckpoint() {
+	store := &memCheckpointStore{checkpoints: make(map[string]rangetask.Checkpoint)}
+	errKey := []byte("m")
+	ranges := make(chan *kv.KeyRange, 100)
+	handler := func(ctx context.Context, r kv.KeyRange) (rangetask.TaskStat, error) {
+		if bytes.Equal(r.StartKey, errKey) {
+			return rangetask.TaskStat{FailedRegions: 1}, errors.New("test error")
+		}
+		ranges <- &r
+		return rangetask.TaskStat{CompletedRegions: 1}, nil
+	}
+
+	for concurrency := 1; concurrency < 5; concurrency++ {
+		runner := rangetask.NewRangeTaskRunnerWithID("test-checkpoint-runner", "checkpoint", s.store, concurrency, handler)
+		runner.SetRegionsPerTask(1)
+		runner.SetCheckpointStore(store)
+		errKey = []byte("m")
+		delete(store.checkpoints, "checkpoint")
+
+		// The task fails at "m", and the checkpoint stops right before it.
+		s.NotNil(runner.RunOnRange(context.Background(), []byte("b"), []byte("x")))
+		collect(ranges)
+		cp := store.checkpoints["checkpoint"]
+		s.Equal([]byte("m"), cp.NextKey)
+		s.False(cp.Finished)
+
+		// The resumed task starts from the checkpoint.
+		errKey = nil
+		s.Nil(runner.RunOnRange(context.Background(), []byte("b"), []byte("x")))
+		s.checkRanges(collect(ranges), s.expectedRanges[3][11:])
+		s.True(store.checkpoints["checkpoint"].Finished)
+
+		// The finished task does nothing.
+		s.Nil(runner.RunOnRange(context.Background(), []byte("b"), []byte("x")))
+		s.Empty(collect(ranges))
+
+		// The checkpoint of a different range is ignored.
+		s.Nil(runner.RunOnRange(context.Background(), []byte("b"), []byte("d")))
+		s.checkRanges(collect(ranges), s.expectedRanges[3][:2])
+	}
+}
@@ -35,6 +35,7 @@
 package tikv_test
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"strings"
@@ -43,7 +44,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/suite"
-	"github.com/tikv/client-go/v2/error"
+	tikverr "github.com/tikv/client-go/v2/error"
 	"github.com/tikv/client-go/v2/tikv"
 	"github.com/tikv/client-go/v2/txnkv/transaction"
 )
@@ -117,7 +118,7 @@ func (s *testSafePointSuite) TestSafePoint() {
 	_, geterr2 := txn2.Get(context.TODO(), encodeKey(s.prefix, s08d("key", 0)))
 	s.NotNil(geterr2)
 
-	_, isFallBehind := errors.Cause(geterr2).(*error.ErrGCTooEarly)
+	_, isFallBehind := errors.Cause(geterr2).(*tikverr.ErrGCTooEarly)
 	isMayFallBehind := strings.Contains(geterr2.Error(), "start timestamp may fall behind safe point")
 	isBehind := isFallBehind || isMayFallBehind
 	s.True(isBehind)
@@ -129,7 +130,7 @@ func (s *testSafePointSuite) TestSafePoint() {
 
 	_, seekerr := txn3.Iter(encodeKey(s.prefix, ""), nil)
 	s.NotNil(seekerr)
-	_, isFallBehind = errors.Cause(geterr2).(*error.ErrGCTooEarly)
+	_, isFallBehind = errors.Cause(geterr2).(*tikverr.ErrGCTooEarly)
 	isMayFallBehind = strings.Contains(geterr2.Error(), "start timestamp may fall behind safe point")
 	isBehind = isFallBehind || isMayFallBehind
 	s.True(isBehind)
@@ -142,8 +143,86 @@ func (s *testSafePointSuite) TestSafePoint() {
 
 	_, batchgeterr := toTiDBTxn(&txn4).BatchGet(context.Background(), toTiDBKeys(keys))
 	s.NotNil(batchgeterr)
-	_, isFallBehind = errors.Cause(geterr2).(*error.ErrGCTooEarly)
+	_, isFallBehind = errors.Cause(geterr2).(*tikverr.ErrGCTooEarly)
 	isMayFallBehind = strings.Contains(geterr2.Error(), "start timestamp may fall behind safe point")
 	isBehind = isFallBehind || isMayFallBehind
 	s.True(isBehind)
 }
+
+// archiveReader serves the reads from the pairs in ascending order of keys.
+type archiveReader struct {
+	keys, values [][]byte
+}
+
+func (r *archiveReader) Get(ctx context.Context, key []byte, ts uint64) ([]byte, error) {
+	for i, k := range r.keys {
+		if bytes.Equal(k, key) {
+			return r.values[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *archiveReader) BatchGet(ctx context.Context, keys [][]byte, ts uint64) (map[string][]byte, error) {
+	m := make(map[string][]byte)
+	for _, key := range keys {
+		if v, _ := r.Get(ctx, key, ts); v != nil {
+			m[string(key)] = v
+		}
+	}
+	return m, nil
+}
+
+func (r *archiveReader) Scan(ctx context.Context, startKey, endKey []byte, limit int, ts uint64, reverse bool) (keys, values [][]byte, err error) {
+	for i := range r.keys {
+		if reverse {
+			i = len(r.keys) - 1 - i
+		}
+		k := r.keys[i]
+		if bytes.Compare(k, startKey) < 0 || (len(endKey) > 0 && bytes.Compare(k, endKey) >= 0) {
+			continue
+		}
+		if len(keys) == limit {
+			break
+		}
+		keys, values = append(keys, k), append(values, r.values[i])
+	}
+	return keys, values, nil
+}
+
+func (s *testSafePointSuite) TestArchiveReader() {
+	archive := &archiveReader{}
+	for i := 0; i < 10; i++ {
+		archive.keys = append(archive.keys, encodeKey(s.prefix, s08d("archive", i)))
+		archive.values = append(archive.values, valueBytes(i))
+	}
+
+	txn := s.beginTxn()
+	txn.GetSnapshot().SetArchiveReader(archive)
+	s.waitUntilErrorPlugIn(txn.StartTS())
+	// Move the safe point back for the other tests sharing the store.
+	defer func() {
+		s.Nil(s.store.SaveSafePoint(0))
+		s.store.UpdateSPCache(0, time.Now())
+	}()
+
+	val, err := txn.Get(context.TODO(), archive.keys[0])
+	s.Nil(err)
+	s.Equal(archive.values[0], val)
+	_, err = txn.Get(context.TODO(), encodeKey(s.prefix, s08d("archive", 10)))
+	s.True(tikverr.IsErrNotFound(err))
+
+	m, err := txn.GetSnapshot().BatchGet(context.TODO(), archive.keys[1:3])
+	s.Nil(err)
+	s.Equal(map[string][]byte{string(archive.keys[1]): archive.values[1], string(archive.keys[2]): archive.values[2]}, m)
+
+	txn.GetSnapshot().SetScanBatchSize(3)
+	it, err := txn.GetSnapshot().Iter(encodeKey(s.prefix, s08d("archive", 0)), encodeKey(s.prefix, s08d("archive", 9)))
+	s.Nil(err)
+	var keys [][]byte
+	for it.Valid() {
+		keys = append(keys, it.Key())
+		s.Nil(it.Next())
+	}
+	s.Equal(archive.keys[:9], keys)
+}
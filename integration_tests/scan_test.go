@@ -196,3 +196,97 @@ func (s *testScanSuite) TestScan() {
 		committer4.Cleanup(context.Background())
 	}
 }
+
+func (s *testScanSuite) TestScanChangedSince() {
+	prefix := []byte("changes")
+	key := func(i int) []byte { return append(append([]byte{}, prefix...), fmt.Sprintf("%10d", i)...) }
+
+	txn := s.beginTxn()
+	for i := 0; i < scanBatchSize+2; i++ {
+		s.Nil(txn.Set(key(i), s.makeValue(i)))
+	}
+	s.Nil(txn.Commit(context.Background()))
+	sinceTS := s.beginTxn().StartTS()
+
+	txn = s.beginTxn()
+	s.Nil(txn.Set(key(1), s.makeValue(1)))    // rewritten with the same value
+	s.Nil(txn.Set(key(2), []byte("updated"))) // updated
+	s.Nil(txn.Delete(key(3)))                 // deleted
+	s.Nil(txn.Set(key(scanBatchSize+5), s.makeValue(scanBatchSize+5)))
+	s.Nil(txn.Commit(context.Background()))
+
+	txn = s.beginTxn()
+	snapshot := txn.GetSnapshot()
+	_, err := snapshot.ScanChangedSince(prefix, nil, txn.StartTS())
+	s.NotNil(err)
+
+	it, err := snapshot.ScanChangedSince(prefix, kv.PrefixNextKey(prefix), sinceTS)
+	s.Nil(err)
+	defer it.Close()
+	type change struct {
+		key, value string
+		deleted    bool
+	}
+	var changes []change
+	for it.Valid() {
+		changes = append(changes, change{string(it.Key()), string(it.Value()), it.Deleted()})
+		s.Nil(it.Next())
+	}
+	s.Equal([]change{
+		{string(key(2)), "updated", false},
+		{string(key(3)), "", true},
+		{string(key(scanBatchSize + 5)), string(s.makeValue(scanBatchSize + 5)), false},
+	}, changes)
+
+	txn = s.beginTxn()
+	for i := 0; i < scanBatchSize+6; i++ {
+		s.Nil(txn.Delete(key(i)))
+	}
+	s.Nil(txn.Commit(context.Background()))
+}
+
+func (s *testScanSuite) TestIterWithLimit() {
+	prefix := []byte("limit")
+	key := func(i int) []byte { return append(append([]byte{}, prefix...), fmt.Sprintf("%10d", i)...) }
+	rowNum := scanBatchSize + 3
+
+	txn := s.beginTxn()
+	for i := 0; i < rowNum; i++ {
+		s.Nil(txn.Set(key(i), s.makeValue(i)))
+	}
+	s.Nil(txn.Commit(context.Background()))
+
+	snapshot := s.beginTxn().GetSnapshot()
+	for _, limit := range []int{1, 3, scanBatchSize + 1, rowNum + 1} {
+		expected := limit
+		if expected > rowNum {
+			expected = rowNum
+		}
+
+		scan, err := snapshot.IterWithLimit(prefix, kv.PrefixNextKey(prefix), limit)
+		s.Nil(err)
+		for i := 0; i < expected; i++ {
+			s.True(scan.Valid())
+			s.Equal(key(i), scan.Key())
+			s.Equal(s.makeValue(i), scan.Value())
+			s.Nil(scan.Next())
+		}
+		s.False(scan.Valid())
+
+		scan, err = snapshot.IterReverseWithLimit(kv.PrefixNextKey(prefix), prefix, limit, true)
+		s.Nil(err)
+		for i := rowNum - 1; i >= rowNum-expected; i-- {
+			s.True(scan.Valid())
+			s.Equal(key(i), scan.Key())
+			s.Empty(scan.Value())
+			s.Nil(scan.Next())
+		}
+		s.False(scan.Valid())
+	}
+
+	txn = s.beginTxn()
+	for i := 0; i < rowNum; i++ {
+		s.Nil(txn.Delete(key(i)))
+	}
+	s.Nil(txn.Commit(context.Background()))
+}
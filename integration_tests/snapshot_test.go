@@ -35,6 +35,7 @@
 package tikv_test
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"math"
@@ -185,6 +186,27 @@ func (s *testSnapshotSuite) TestSnapshotCache() {
 	s.Nil(failpoint.Disable("tikvclient/snapshot-get-cache-fail"))
 }
 
+func (s *testSnapshotSuite) TestGetInto() {
+	value := bytes.Repeat([]byte("v"), 4096)
+	txn := s.beginTxn()
+	s.Nil(txn.Set([]byte("large"), value))
+	s.Nil(txn.Commit(context.Background()))
+
+	snapshot := s.beginTxn().GetSnapshot()
+	buf := make([]byte, 0, 8192)
+	got, err := snapshot.GetInto(context.Background(), []byte("large"), buf)
+	s.Nil(err)
+	s.Equal(value, got)
+	s.Equal(&buf[:1][0], &got[0])
+	// The value read into the buffer isn't cached.
+	s.Empty(snapshot.SnapCache())
+
+	got, err = snapshot.GetInto(context.Background(), []byte("missing"), got)
+	s.True(error.IsErrNotFound(err))
+	s.Empty(got)
+	s.deleteKeys([][]byte{[]byte("large")})
+}
+
 func (s *testSnapshotSuite) TestBatchGetNotExist() {
 	for _, rowNum := range s.rowNums {
 		s.T().Logf("test BatchGetNotExist, length=%v", rowNum)
@@ -204,6 +226,32 @@ func (s *testSnapshotSuite) TestBatchGetNotExist() {
 	}
 }
 
+func (s *testSnapshotSuite) TestBatchExists() {
+	k1, k2, k3 := encodeKey(s.prefix, "k1"), encodeKey(s.prefix, "k10"), encodeKey(s.prefix, "k2")
+	txn := s.beginTxn()
+	s.Nil(txn.Set(k2, []byte("v")))
+	s.Nil(txn.Set(k3, []byte("v")))
+	s.Nil(txn.Commit(context.Background()))
+	defer s.deleteKeys([][]byte{k2, k3})
+
+	snapshot := s.beginTxn().GetSnapshot()
+	res, err := snapshot.BatchExists(context.Background(), [][]byte{k1, k2, k3, k1})
+	s.Nil(err)
+	s.Equal([]bool{false, true, true, false}, res)
+	exists, err := snapshot.Exists(context.Background(), k2)
+	s.Nil(err)
+	s.True(exists)
+
+	// Keys not existing are cached.
+	_, ok := snapshot.SnapCache()[string(k1)]
+	s.True(ok)
+	_, ok = snapshot.SnapCache()[string(k2)]
+	s.False(ok)
+	exists, err = snapshot.Exists(context.Background(), k1)
+	s.Nil(err)
+	s.False(exists)
+}
+
 func makeKeys(rowNum int, prefix string) [][]byte {
 	keys := make([][]byte, 0, rowNum)
 	for i := 0; i < rowNum; i++ {
@@ -427,3 +475,88 @@ func (s *testSnapshotSuite) TestSnapshotCacheBypassMaxUint64() {
 	snapshot.BatchGet(context.Background(), [][]byte{[]byte("y"), []byte("z")})
 	s.Empty(snapshot.SnapCache())
 }
+
+func (s *testSnapshotSuite) TestReadSLOBudget() {
+	x := []byte("x_key_TestReadSLOBudget")
+	y := []byte("y_key_TestReadSLOBudget")
+	txn := s.beginTxn()
+	s.Nil(txn.Set(x, []byte("x")))
+	s.Nil(txn.Commit(context.Background()))
+
+	txn = s.beginTxn()
+	s.Nil(txn.Set(y, []byte("y")))
+	ctx := context.Background()
+	committer, err := txn.NewCommitter(0)
+	s.Nil(err)
+	committer.SetLockTTL(3000)
+	// The lock without min commit ts can't be pushed, so it blocks the reads.
+	s.Nil(failpoint.Enable("tikvclient/mockZeroCommitTS", fmt.Sprintf(`return(%d)`, txn.StartTS())))
+	s.Nil(committer.PrewriteAllMutations(ctx))
+	s.Nil(failpoint.Disable("tikvclient/mockZeroCommitTS"))
+
+	ts, err := s.store.GetOracle().GetTimestamp(ctx, &oracle.Option{TxnScope: oracle.GlobalTxnScope})
+	s.Nil(err)
+	snapshot := s.store.GetSnapshot(ts)
+	snapshot.SetReadSLOBudget(200 * time.Millisecond)
+
+	// The lock on y can't be resolved within the budget, the value of x is returned as partial result.
+	start := time.Now()
+	res, err := snapshot.BatchGet(ctx, [][]byte{x, y})
+	s.True(error.IsErrSLOBudgetExceeded(err))
+	s.Less(time.Since(start), 2*time.Second)
+	s.Equal([]byte("x"), res[string(x)])
+	s.NotContains(res, string(y))
+
+	_, err = snapshot.Get(ctx, y)
+	s.True(error.IsErrSLOBudgetExceeded(err))
+
+	s.Nil(committer.CleanupMutations(ctx))
+}
+
+func (s *testSnapshotSuite) TestScanWithResumeToken() {
+	ctx := context.Background()
+	prefix := s.prefix + "_resume"
+	keys := makeKeys(10, prefix)
+	txn := s.beginTxn()
+	for _, k := range keys {
+		s.Nil(txn.Set(k, k))
+	}
+	s.Nil(txn.Commit(ctx))
+
+	ts, err := s.store.GetOracle().GetTimestamp(ctx, &oracle.Option{TxnScope: oracle.GlobalTxnScope})
+	s.Nil(err)
+	// Delete the first key after ts, it's still visible to the scan resumed at ts.
+	s.deleteKeys(keys[:1])
+
+	snapshot := s.store.GetSnapshot(ts)
+	start, end := encodeKey(prefix, ""), encodeKey(prefix, "~")
+	scanned, values, token, err := snapshot.ScanBatch(ctx, start, end, 4)
+	s.Nil(err)
+	s.Equal(keys[:4], scanned)
+	s.Equal(keys[:4], values)
+	s.NotNil(token)
+	tokenTS, err := token.StartTS()
+	s.Nil(err)
+	s.Equal(ts, tokenTS)
+
+	// Resume at the same ts with a new snapshot.
+	scanned, _, token, err = s.store.GetSnapshot(tokenTS).ResumeScanBatch(ctx, token, 4)
+	s.Nil(err)
+	s.Equal(keys[4:8], scanned)
+
+	// Resume at a fresher ts, the new write is visible.
+	txn = s.beginTxn()
+	newKey := encodeKey(prefix, s08d("key", 9)+"_new")
+	s.Nil(txn.Set(newKey, newKey))
+	s.Nil(txn.Commit(ctx))
+	freshTS, err := s.store.GetOracle().GetTimestamp(ctx, &oracle.Option{TxnScope: oracle.GlobalTxnScope})
+	s.Nil(err)
+	scanned, _, token, err = s.store.GetSnapshot(freshTS).ResumeScanBatch(ctx, token, 4)
+	s.Nil(err)
+	s.Equal([][]byte{keys[8], keys[9], newKey}, scanned)
+	s.Nil(token)
+
+	_, _, _, err = snapshot.ResumeScanBatch(ctx, txnkv.ScanResumeToken("invalid"), 4)
+	s.NotNil(err)
+	s.deleteKeys(append(keys[1:], newKey))
+}
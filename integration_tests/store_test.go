@@ -36,18 +36,26 @@ package tikv_test
 
 import (
 	"context"
+	"slices"
 	"sync"
 	"testing"
 	"time"
 
+	"bytes"
+	"fmt"
 	"github.com/pingcap/failpoint"
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/kv"
 	"github.com/tikv/client-go/v2/oracle"
 	"github.com/tikv/client-go/v2/oracle/oracles"
+	"github.com/tikv/client-go/v2/testutils"
 	"github.com/tikv/client-go/v2/tikv"
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/txnkv"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
 )
 
 func TestStore(t *testing.T) {
@@ -192,3 +200,173 @@ func (s *testStoreSuite) TestFailBusyServerKV() {
 	s.Nil(err)
 	s.Equal(val, []byte("value"))
 }
+
+type replicaReadRecordClient struct {
+	tikv.Client
+	mu           sync.Mutex
+	readTypes    []kv.ReplicaReadType
+	batchGetKeys map[kv.ReplicaReadType][][]byte
+}
+
+func (c *replicaReadRecordClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	switch req.Type {
+	case tikvrpc.CmdGet:
+		c.mu.Lock()
+		c.readTypes = append(c.readTypes, req.ReplicaReadType)
+		c.mu.Unlock()
+	case tikvrpc.CmdBatchGet:
+		c.mu.Lock()
+		if c.batchGetKeys == nil {
+			c.batchGetKeys = make(map[kv.ReplicaReadType][][]byte)
+		}
+		c.batchGetKeys[req.ReplicaReadType] = append(c.batchGetKeys[req.ReplicaReadType], req.BatchGet().Keys...)
+		c.mu.Unlock()
+	}
+	return c.Client.SendRequest(ctx, addr, req, timeout)
+}
+
+func TestWorkloadRouting(t *testing.T) {
+	re := require.New(t)
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	re.Nil(err)
+	testutils.BootstrapWithMultiStores(cluster, 3)
+	recorder := &replicaReadRecordClient{Client: client}
+	store, err := tikv.NewTestTiKVStore(recorder, pdClient, nil, nil, 0,
+		tikv.WithWorkloadRouting(kv.WorkloadRouting{kv.WorkloadOLAP: kv.ReplicaReadMixed}))
+	re.Nil(err)
+	defer store.Close()
+
+	for _, class := range []kv.WorkloadClass{kv.WorkloadOLTP, kv.WorkloadOLAP, kv.WorkloadBackup} {
+		txn, err := store.Begin(tikv.WithWorkloadClass(class))
+		re.Nil(err)
+		_, err = txn.Get(context.Background(), []byte("k"))
+		re.True(tikverr.IsErrNotFound(err))
+	}
+	// The snapshots without the workload class keep reading from the leaders.
+	ts, err := store.CurrentTimestamp(oracle.GlobalTxnScope)
+	re.Nil(err)
+	_, err = store.GetSnapshot(ts).Get(context.Background(), []byte("k"))
+	re.True(tikverr.IsErrNotFound(err))
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	// mocktikv serves the reads on the leaders only, so the replica reads sent
+	// to the followers are retried on the leaders with the same read type.
+	re.Equal([]kv.ReplicaReadType{kv.ReplicaReadLeader, kv.ReplicaReadMixed, kv.ReplicaReadFollower, kv.ReplicaReadLeader}, slices.Compact(recorder.readTypes))
+}
+
+func TestBatchGetWithReplicaRead(t *testing.T) {
+	re := require.New(t)
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	re.Nil(err)
+	testutils.BootstrapWithMultiStores(cluster, 3)
+	recorder := &replicaReadRecordClient{Client: client}
+	store, err := tikv.NewTestTiKVStore(recorder, pdClient, nil, nil, 0)
+	re.Nil(err)
+	defer store.Close()
+
+	txn, err := store.Begin()
+	re.Nil(err)
+	var keys, leaderKeys [][]byte
+	for i := 0; i < 10; i++ {
+		key := []byte(fmt.Sprintf("key%d", i))
+		re.Nil(txn.Set(key, key))
+		keys = append(keys, key)
+		if i%3 == 0 {
+			leaderKeys = append(leaderKeys, key)
+		}
+	}
+	re.Nil(txn.Commit(context.Background()))
+
+	ts, err := store.CurrentTimestamp(oracle.GlobalTxnScope)
+	re.Nil(err)
+	snapshot := store.GetSnapshot(ts)
+	snapshot.SetReplicaRead(kv.ReplicaReadFollower)
+	m, err := snapshot.BatchGetWithReplicaRead(context.Background(), keys, func(key []byte) kv.ReplicaReadType {
+		for _, k := range leaderKeys {
+			if bytes.Equal(k, key) {
+				return kv.ReplicaReadLeader
+			}
+		}
+		return kv.ReplicaReadMixed
+	})
+	re.Nil(err)
+	re.Len(m, len(keys))
+	for _, key := range keys {
+		re.Equal(key, m[string(key)])
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	re.ElementsMatch(leaderKeys, recorder.batchGetKeys[kv.ReplicaReadLeader])
+	// The follower reads may be retried.
+	mixedKeys := make(map[string]struct{})
+	for _, key := range recorder.batchGetKeys[kv.ReplicaReadMixed] {
+		mixedKeys[string(key)] = struct{}{}
+	}
+	re.Len(mixedKeys, len(keys)-len(leaderKeys))
+	for _, key := range leaderKeys {
+		re.NotContains(mixedKeys, string(key))
+	}
+	re.Empty(recorder.batchGetKeys[kv.ReplicaReadFollower])
+}
+
+type blockGetClient struct {
+	tikv.Client
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (c *blockGetClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	if req.Type == tikvrpc.CmdGet {
+		c.entered <- struct{}{}
+		<-c.release
+	}
+	return c.Client.SendRequest(ctx, addr, req, timeout)
+}
+
+func TestConcurrentUseAudit(t *testing.T) {
+	re := require.New(t)
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	re.Nil(err)
+	testutils.BootstrapWithSingleStore(cluster)
+	blocker := &blockGetClient{Client: client, entered: make(chan struct{}), release: make(chan struct{})}
+	store, err := tikv.NewTestTiKVStore(blocker, pdClient, nil, nil, 0)
+	re.Nil(err)
+	defer store.Close()
+
+	var reported []*transaction.ConcurrentUseError
+	transaction.EnableConcurrentUseAudit(true)
+	transaction.SetConcurrentUseAuditHandler(func(err *transaction.ConcurrentUseError) {
+		reported = append(reported, err)
+	})
+	defer func() {
+		transaction.EnableConcurrentUseAudit(false)
+		transaction.SetConcurrentUseAuditHandler(nil)
+	}()
+
+	txn, err := store.Begin()
+	re.Nil(err)
+	// Sequential use isn't reported.
+	re.Nil(txn.Set([]byte("a"), []byte("a")))
+	re.Nil(txn.Delete([]byte("a")))
+	re.Empty(reported)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := txn.Get(context.Background(), []byte("k"))
+		re.True(tikverr.IsErrNotFound(err))
+	}()
+	<-blocker.entered
+	re.Nil(txn.Set([]byte("b"), []byte("b")))
+	close(blocker.release)
+	<-done
+
+	re.Len(reported, 1)
+	re.Equal("Set", reported[0].Method)
+	re.Contains(reported[0].CallSite, "store_test.go")
+	re.Equal("Get", reported[0].ConflictMethod)
+	re.Contains(reported[0].ConflictCallSite, "store_test.go")
+	re.Equal(txn.StartTS(), reported[0].StartTS)
+}
@@ -0,0 +1,57 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apicodec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pingcap/kvproto/pkg/keyspacepb"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/stretchr/testify/require"
+)
+
+func FuzzCodecV2Key(f *testing.F) {
+	c, err := NewCodecV2(ModeTxn, &keyspacepb.KeyspaceMeta{Id: testKeyspaceID})
+	require.NoError(f, err)
+	f.Add([]byte("key"), []byte{})
+	f.Add([]byte{}, []byte{'x', 0, 16, 146, 1})
+	f.Add([]byte{0xff}, []byte{'x', 0, 16, 147})
+	f.Fuzz(func(t *testing.T, key, malformed []byte) {
+		encoded := c.EncodeKey(key)
+		// Encoding another key must not overwrite the previous result.
+		c.EncodeKey(malformed)
+		decoded, err := c.DecodeKey(encoded)
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(key, decoded))
+
+		regionKey := c.EncodeRegionKey(key)
+		decoded, err = c.DecodeRegionKey(regionKey)
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(key, decoded))
+
+		start, end := c.EncodeRegionRange(key, nil)
+		decodedStart, decodedEnd, err := c.DecodeRegionRange(start, end)
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(key, decodedStart))
+		require.Empty(t, decodedEnd)
+
+		// Malformed keys must be rejected instead of panicking.
+		_, _ = c.DecodeRegionKey(malformed)
+		_, _, _ = c.DecodeRegionRange(malformed, key)
+		_, _, _ = c.DecodeRange(malformed, key)
+		_, _, _ = DecodeKey(malformed, kvrpcpb.APIVersion_V2)
+	})
+}
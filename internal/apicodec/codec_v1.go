@@ -135,6 +135,9 @@ func (c *codecV1) DecodeResponse(req *tikvrpc.Request, resp *tikvrpc.Response) (
 	case tikvrpc.CmdRawScan:
 		r := resp.Resp.(*kvrpcpb.RawScanResponse)
 		r.RegionError = decodeRegionError
+	case tikvrpc.CmdRawBatchScan:
+		r := resp.Resp.(*kvrpcpb.RawBatchScanResponse)
+		r.RegionError = decodeRegionError
 	case tikvrpc.CmdGetKeyTTL:
 		r := resp.Resp.(*kvrpcpb.RawGetKeyTTLResponse)
 		r.RegionError = decodeRegionError
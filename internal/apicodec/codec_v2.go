@@ -14,6 +14,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/tikv/client-go/v2/internal/logutil"
 	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/util/redact"
 	"go.uber.org/zap"
 )
 
@@ -231,6 +232,15 @@ func (c *codecV2) EncodeRequest(req *tikvrpc.Request) (*tikvrpc.Request, error)
 		r := *req.RawScan()
 		r.StartKey, r.EndKey = c.encodeRange(r.StartKey, r.EndKey, r.Reverse)
 		req.Req = &r
+	case tikvrpc.CmdRawBatchScan:
+		r := *req.RawBatchScan()
+		ranges := make([]*kvrpcpb.KeyRange, 0, len(r.Ranges))
+		for _, kr := range r.Ranges {
+			start, end := c.encodeRange(kr.StartKey, kr.EndKey, r.Reverse)
+			ranges = append(ranges, &kvrpcpb.KeyRange{StartKey: start, EndKey: end})
+		}
+		r.Ranges = ranges
+		req.Req = &r
 	case tikvrpc.CmdGetKeyTTL:
 		r := *req.RawGetKeyTTL()
 		r.Key = c.EncodeKey(r.Key)
@@ -537,6 +547,16 @@ func (c *codecV2) DecodeResponse(req *tikvrpc.Request, resp *tikvrpc.Response) (
 		if err != nil {
 			return nil, err
 		}
+	case tikvrpc.CmdRawBatchScan:
+		r := resp.Resp.(*kvrpcpb.RawBatchScanResponse)
+		r.RegionError, err = c.decodeRegionError(r.RegionError)
+		if err != nil {
+			return nil, err
+		}
+		r.Kvs, err = c.decodePairs(r.Kvs)
+		if err != nil {
+			return nil, err
+		}
 	case tikvrpc.CmdGetKeyTTL:
 		r := resp.Resp.(*kvrpcpb.RawGetKeyTTLResponse)
 		r.RegionError, err = c.decodeRegionError(r.RegionError)
@@ -705,7 +725,7 @@ func (c *codecV2) DecodeKey(encodedKey []byte) ([]byte, error) {
 	if !bytes.HasPrefix(encodedKey, c.prefix) {
 		logutil.BgLogger().Warn("key not in keyspace",
 			zap.String("keyspacePrefix", hex.EncodeToString(c.prefix)),
-			zap.String("key", hex.EncodeToString(encodedKey)),
+			zap.String("key", redact.Key(encodedKey)),
 			zap.Stack("stack"))
 		return nil, errKeyOutOfBound
 	}
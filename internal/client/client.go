@@ -69,7 +69,6 @@ import (
 	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/grpc/encoding/gzip"
 	"google.golang.org/grpc/experimental"
 	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
@@ -114,6 +113,13 @@ type ClientEventListener interface {
 	OnHealthFeedback(feedback *kvrpcpb.HealthFeedback)
 }
 
+// ConnStateListener is an optional interface of ClientEventListener to be notified of the states of the connections.
+type ConnStateListener interface {
+	// OnConnTransientFailure is called when a connection to addr turns into TransientFailure, e.g. the store has moved
+	// to another address, so the address can be resolved again before the requests fail.
+	OnConnTransientFailure(addr string)
+}
+
 // ClientExt is a client has extended interfaces.
 type ClientExt interface {
 	// CloseAddrVer closes gRPC connections to the address with additional `ver` parameter.
@@ -166,6 +172,8 @@ type connArray struct {
 	// batchConn is not null when batch is enabled.
 	*batchConn
 	done chan struct{}
+	// compression is the gRPC compression negotiated with the target.
+	compression *storeCompression
 
 	monitor *connMonitor
 
@@ -202,6 +210,8 @@ type connMonitor struct {
 	loopOnce sync.Once
 	stopOnce sync.Once
 	stop     chan struct{}
+	// listener is notified of the connections turning into TransientFailure.
+	listener *atomic.Pointer[ClientEventListener]
 }
 
 func (c *connMonitor) AddConn(conn *monitoredConn) {
@@ -244,6 +254,10 @@ func (c *connMonitor) start() {
 			c.m.Range(func(_, value interface{}) bool {
 				conn := value.(*monitoredConn)
 				nowState := conn.GetState()
+				if nowState == connectivity.TransientFailure && conn.lastState != connectivity.TransientFailure {
+					c.onTransientFailure(conn.Target())
+				}
+				conn.lastState = nowState
 				for state := connectivity.Idle; state <= connectivity.Shutdown; state++ {
 					if state == nowState {
 						metrics.TiKVGrpcConnectionState.WithLabelValues(conn.Name, conn.Target(), nowState.String()).Set(1)
@@ -259,9 +273,22 @@ func (c *connMonitor) start() {
 	}
 }
 
+func (c *connMonitor) onTransientFailure(addr string) {
+	if c.listener == nil {
+		return
+	}
+	if l := c.listener.Load(); l != nil {
+		if sl, ok := (*l).(ConnStateListener); ok {
+			sl.OnConnTransientFailure(addr)
+		}
+	}
+}
+
 type monitoredConn struct {
 	*grpc.ClientConn
 	Name string
+	// lastState is the state seen by the connMonitor last time.
+	lastState connectivity.State
 }
 
 func (a *connArray) monitoredDial(ctx context.Context, connName, target string, opts ...grpc.DialOption) (conn *monitoredConn, err error) {
@@ -307,6 +334,7 @@ func (a *connArray) Init(addr string, security config.Security, idleNotify *uint
 		streamInterceptor = grpc_opentracing.StreamClientInterceptor()
 	}
 
+	a.compression = newStoreCompression(addr, &cfg.TiKVClient)
 	allowBatch := (cfg.TiKVClient.MaxBatchSize > 0) && enableBatch
 	if allowBatch {
 		a.batchConn = newBatchConn(uint(len(a.v)), cfg.TiKVClient.MaxBatchSize, idleNotify)
@@ -317,9 +345,6 @@ func (a *connArray) Init(addr string, security config.Security, idleNotify *uint
 		ctx, cancel := context.WithTimeout(context.Background(), a.dialTimeout)
 		var callOptions []grpc.CallOption
 		callOptions = append(callOptions, grpc.MaxCallRecvMsgSize(MaxRecvMsgSize))
-		if cfg.TiKVClient.GrpcCompressionType == gzip.Name {
-			callOptions = append(callOptions, grpc.UseCompressor(gzip.Name))
-		}
 
 		opts = append([]grpc.DialOption{
 			opt,
@@ -327,6 +352,8 @@ func (a *connArray) Init(addr string, security config.Security, idleNotify *uint
 			grpc.WithInitialConnWindowSize(cfg.TiKVClient.GrpcInitialConnWindowSize),
 			grpc.WithUnaryInterceptor(unaryInterceptor),
 			grpc.WithStreamInterceptor(streamInterceptor),
+			grpc.WithChainUnaryInterceptor(compressionUnaryInterceptor),
+			grpc.WithChainStreamInterceptor(compressionStreamInterceptor),
 			grpc.WithDefaultCallOptions(callOptions...),
 			grpc.WithConnectParams(grpc.ConnectParams{
 				Backoff: backoff.Config{
@@ -374,6 +401,7 @@ func (a *connArray) Init(addr string, security config.Security, idleNotify *uint
 				tryLock:          tryLock{sync.NewCond(new(sync.Mutex)), false},
 				eventListener:    eventListener,
 				metrics:          &a.batchConn.metrics,
+				compression:      a.compression,
 			}
 			batchClient.maxConcurrencyRequestLimit.Store(cfg.TiKVClient.MaxConcurrencyRequestLimit)
 			a.batchCommandsClients = append(a.batchCommandsClients, batchClient)
@@ -449,6 +477,8 @@ type option struct {
 	security        config.Security
 	dialTimeout     time.Duration
 	codec           apicodec.Codec
+	storeTLS        StoreTLSResolver
+	interceptors    interceptorChain
 }
 
 // Opt is the option for the client.
@@ -507,9 +537,9 @@ func NewRPCClient(opts ...Opt) *RPCClient {
 		option: &option{
 			dialTimeout: dialTimeout,
 		},
-		connMonitor:   &connMonitor{},
 		eventListener: new(atomic.Pointer[ClientEventListener]),
 	}
+	cli.connMonitor = &connMonitor{listener: cli.eventListener}
 	for _, opt := range opts {
 		opt(cli.option)
 	}
@@ -553,6 +583,10 @@ func (c *RPCClient) createConnArray(addr string, enableBatch bool, opts ...func(
 			opt(&client)
 		}
 		ver := c.vers[addr] + 1
+		dialOptions, err := c.option.dialOptions(addr)
+		if err != nil {
+			return nil, err
+		}
 		array, err = newConnArray(
 			client.GrpcConnectionCount,
 			addr,
@@ -563,7 +597,7 @@ func (c *RPCClient) createConnArray(addr string, enableBatch bool, opts ...func(
 			c.option.dialTimeout,
 			c.connMonitor,
 			c.eventListener,
-			c.option.gRPCDialOptions)
+			dialOptions)
 
 		if err != nil {
 			return nil, err
@@ -656,10 +690,11 @@ func (c *RPCClient) sendRequest(ctx context.Context, addr string, req *tikvrpc.R
 	// TiDB RPC server supports batch RPC, but batch connection will send heart beat, It's not necessary since
 	// request to TiDB is not high frequency.
 	pri := req.GetResourceControlContext().GetOverridePriority()
-	if config.GetGlobalConfig().TiKVClient.MaxBatchSize > 0 && enableBatch {
+	reqSize := requestSize(req)
+	if config.GetGlobalConfig().TiKVClient.MaxBatchSize > 0 && enableBatch && !connArray.compression.bypassBatch(reqSize) {
 		if batchReq := req.ToBatchCommandsRequest(); batchReq != nil {
 			defer trace.StartRegion(ctx, req.Type.String()).End()
-			return wrapErrConn(sendBatchRequest(ctx, addr, req.ForwardedHost, connArray.batchConn, batchReq, timeout, pri))
+			return wrapErrConn(sendBatchRequest(ctx, addr, req.ForwardedHost, connArray.batchConn, batchReq, timeout, pri, batchLaneOf(req)))
 		}
 	}
 
@@ -682,6 +717,8 @@ func (c *RPCClient) sendRequest(ctx context.Context, addr string, req *tikvrpc.R
 	if req.ForwardedHost != "" {
 		ctx = metadata.AppendToOutgoingContext(ctx, forwardMetadataKey, req.ForwardedHost)
 	}
+	compressor := connArray.compression.forRequest(reqSize)
+	ctx = withCompressor(ctx, compressor)
 	switch req.Type {
 	case tikvrpc.CmdBatchCop:
 		return wrapErrConn(c.getBatchCopStreamResponse(ctx, client, req, timeout, connArray))
@@ -693,17 +730,37 @@ func (c *RPCClient) sendRequest(ctx context.Context, addr string, req *tikvrpc.R
 	// Or else it's a unary call.
 	ctx1, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
-	return wrapErrConn(tikvrpc.CallRPC(ctx1, client, req))
+	resp, err = tikvrpc.CallRPC(ctx1, client, req)
+	if err != nil && connArray.compression.onError(compressor, err) {
+		// Retry with the compressor the store falls back to.
+		resp, err = tikvrpc.CallRPC(withCompressor(ctx1, connArray.compression.forRequest(reqSize)), client, req)
+	}
+	return wrapErrConn(resp, err)
 }
 
 // SendRequest sends a Request to server and receives Response.
 func (c *RPCClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	var codec apicodec.Codec
+	if c.option != nil {
+		codec = c.option.codec
+	}
+	return c.sendRequestWithCodec(ctx, codec, addr, req, timeout)
+}
+
+func (c *RPCClient) sendRequestWithCodec(ctx context.Context, codec apicodec.Codec, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	if c.option == nil || len(c.option.interceptors) == 0 {
+		return c.sendEncodedRequest(ctx, codec, addr, req, timeout)
+	}
+	return c.option.interceptors.wrap(func(target string, req *tikvrpc.Request) (*tikvrpc.Response, error) {
+		return c.sendEncodedRequest(ctx, codec, target, req, timeout)
+	})(addr, req)
+}
+
+func (c *RPCClient) sendEncodedRequest(ctx context.Context, codec apicodec.Codec, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
 	// In unit test, the option or codec may be nil. Here should skip the encode/decode process.
-	if c.option == nil || c.option.codec == nil {
+	if codec == nil {
 		return c.sendRequest(ctx, addr, req, timeout)
 	}
-
-	codec := c.option.codec
 	req, err := codec.EncodeRequest(req)
 	if err != nil {
 		return nil, err
@@ -715,6 +772,32 @@ func (c *RPCClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.R
 	return codec.DecodeResponse(req, resp)
 }
 
+// WithCodec returns a Client sharing the connections of c, which encodes the
+// requests and decodes the responses with the given codec instead. It allows
+// the clients of different keyspaces to share one RPCClient. The connections
+// are owned by c, closing the returned Client does nothing.
+func (c *RPCClient) WithCodec(codec apicodec.Codec) Client {
+	return &codecClient{RPCClient: c, codec: codec}
+}
+
+// codecClient is a view of RPCClient with another codec.
+type codecClient struct {
+	*RPCClient
+	codec apicodec.Codec
+}
+
+func (c *codecClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	return c.RPCClient.sendRequestWithCodec(ctx, c.codec, addr, req, timeout)
+}
+
+func (c *codecClient) Close() error {
+	return nil
+}
+
+// SetEventListener does nothing, the events of the shared connections are
+// only sent to the listener of the RPCClient.
+func (c *codecClient) SetEventListener(listener ClientEventListener) {}
+
 func (c *RPCClient) getCopStreamResponse(ctx context.Context, client tikvpb.TikvClient, req *tikvrpc.Request, timeout time.Duration, connArray *connArray) (*tikvrpc.Response, error) {
 	// Coprocessor streaming request.
 	// Use context to support timeout for grpc streaming client.
@@ -75,6 +75,10 @@ type batchCommandsEntry struct {
 	canceled int32
 	err      error
 	pri      uint64
+	lane     batchLane
+	// starved is set if the entry has waited in the low lane for longer than
+	// batchLaneLowMaxWait, then it's sent before the normal lane.
+	starved bool
 
 	// start indicates when the batch commands entry is generated and sent to the batch conn channel.
 	start   time.Time
@@ -87,7 +91,7 @@ func (b *batchCommandsEntry) isCanceled() bool {
 }
 
 func (b *batchCommandsEntry) priority() uint64 {
-	return b.pri
+	return entryPriority(b.lane, b.pri, b.starved)
 }
 
 func (b *batchCommandsEntry) error(err error) {
@@ -121,10 +125,66 @@ func (b *batchCommandsBuilder) push(entry *batchCommandsEntry) {
 	}
 }
 
+// batchLane is the priority lane of a request in the batch-commands sender.
+// The requests queued on a connection are sent lane by lane, so that the
+// control-plane requests and the high priority reads aren't stuck behind the
+// low priority ones queued before them. In a lane, the requests are ordered by
+// their priorities of resource control.
+type batchLane int8
+
+const (
+	batchLaneLow batchLane = iota - 1
+	batchLaneNormal
+	batchLaneHigh
+)
+
+// highTaskPriority is the priority from which the tasks don't consume the limit
+// of concurrency. The tasks in the high lane never consume it.
 const highTaskPriority = 10
 
+// batchLaneLowMaxWait is the max time a request waits in the low lane before
+// it's sent ahead of the normal lane, so a steady stream of the normal requests
+// can't starve the low ones.
+const batchLaneLowMaxWait = 100 * time.Millisecond
+
+// entryPriority combines the lane and the priority in the lane into the
+// priority of an entry in the PriorityQueue. The high priority tasks are
+// ordered first whatever their lanes are, so they keep bypassing the limit of
+// concurrency, then the starved tasks of the low lane, the normal lane and the
+// low lane.
+func entryPriority(lane batchLane, pri uint64, starved bool) uint64 {
+	if pri > math.MaxUint32 {
+		pri = math.MaxUint32
+	}
+	var tier uint64
+	switch {
+	case lane == batchLaneHigh || pri >= highTaskPriority:
+		tier = 3
+	case lane == batchLaneLow && starved:
+		tier = 2
+	case lane == batchLaneNormal:
+		tier = 1
+	}
+	return tier<<32 | pri
+}
+
+var highTaskEntryPriority = entryPriority(batchLaneHigh, 0, false)
+
 func (b *batchCommandsBuilder) hasHighPriorityTask() bool {
-	return b.entries.highestPriority() >= highTaskPriority
+	return b.entries.highestPriority() >= highTaskEntryPriority
+}
+
+// promoteStarved moves the requests having waited in the low lane for longer
+// than batchLaneLowMaxWait ahead of the normal lane.
+func (b *batchCommandsBuilder) promoteStarved(now time.Time) {
+	b.entries.promote(func(item Item) bool {
+		e := item.(*batchCommandsEntry)
+		if e.lane != batchLaneLow || e.starved || now.Sub(e.start) < batchLaneLowMaxWait {
+			return false
+		}
+		e.starved = true
+		return true
+	})
 }
 
 // buildWithLimit builds BatchCommandsRequests with the given limit.
@@ -134,6 +194,7 @@ func (b *batchCommandsBuilder) hasHighPriorityTask() bool {
 // The second is a map that maps forwarded hosts to requests.
 func (b *batchCommandsBuilder) buildWithLimit(limit int64, collect func(id uint64, e *batchCommandsEntry),
 ) (*tikvpb.BatchCommandsRequest, map[string]*tikvpb.BatchCommandsRequest) {
+	b.promoteStarved(time.Now())
 	count := int64(0)
 	build := func(reqs []Item) {
 		for _, e := range reqs {
@@ -141,7 +202,7 @@ func (b *batchCommandsBuilder) buildWithLimit(limit int64, collect func(id uint6
 			if e.isCanceled() {
 				continue
 			}
-			if e.priority() < highTaskPriority {
+			if e.priority() < highTaskEntryPriority {
 				count++
 			}
 
@@ -236,6 +297,12 @@ type batchConnMetrics struct {
 	batchMoreRequests   prometheus.Observer
 
 	bestBatchSize prometheus.Observer
+
+	sendWaitDur    prometheus.Observer
+	streamRestarts prometheus.Counter
+
+	// stats is exposed by RPCClient.BatchStats.
+	stats batchConnStats
 }
 
 type batchConn struct {
@@ -285,6 +352,8 @@ func (a *batchConn) initMetrics(target string) {
 	a.metrics.headArrivalInterval = metrics.TiKVBatchHeadArrivalInterval.WithLabelValues(target)
 	a.metrics.batchMoreRequests = metrics.TiKVBatchMoreRequests.WithLabelValues(target)
 	a.metrics.bestBatchSize = metrics.TiKVBatchBestSize.WithLabelValues(target)
+	a.metrics.sendWaitDur = metrics.TiKVBatchSendWaitDuration.WithLabelValues(target)
+	a.metrics.streamRestarts = metrics.TiKVBatchStreamRestartCounter.WithLabelValues(target)
 }
 
 func (a *batchConn) isIdle() bool {
@@ -570,6 +639,7 @@ func (a *batchConn) batchSendLoop(cfg config.TiKVClient) {
 		a.metrics.sendLoopWaitMoreDur.Observe(time.Since(sendLoopStartTime).Seconds())
 
 		a.getClientAndSend()
+		a.metrics.stats.builderPending.Store(int64(a.reqBuilder.len()))
 
 		sendLoopEndTime := time.Now()
 		a.metrics.sendLoopSendDur.Observe(sendLoopEndTime.Sub(sendLoopStartTime).Seconds())
@@ -632,7 +702,9 @@ func (a *batchConn) getClientAndSend() {
 	req, forwardingReqs := a.reqBuilder.buildWithLimit(available, func(id uint64, e *batchCommandsEntry) {
 		cli.batched.Store(id, e)
 		cli.sent.Add(1)
-		atomic.StoreInt64(&e.sendLat, int64(reqSendTime.Sub(e.start)))
+		sendWait := reqSendTime.Sub(e.start)
+		atomic.StoreInt64(&e.sendLat, int64(sendWait))
+		a.metrics.observeSendWait(sendWait)
 		if trace.IsEnabled() {
 			trace.Log(e.ctx, "rpc", "send")
 		}
@@ -647,6 +719,7 @@ func (a *batchConn) getClientAndSend() {
 	}
 	if batch > 0 {
 		a.metrics.batchSize.Observe(float64(batch))
+		a.metrics.stats.batches.Add(1)
 	}
 }
 
@@ -687,6 +760,7 @@ func (l *tryLock) unlockForRecreate() {
 type batchCommandsStream struct {
 	tikvpb.Tikv_BatchCommandsClient
 	forwardedHost string
+	compression   *storeCompression
 }
 
 func (s *batchCommandsStream) recv() (resp *tikvpb.BatchCommandsResponse, err error) {
@@ -711,6 +785,9 @@ func (s *batchCommandsStream) recv() (resp *tikvpb.BatchCommandsResponse, err er
 func (s *batchCommandsStream) recreate(conn *grpc.ClientConn) error {
 	tikvClient := tikvpb.NewTikvClient(conn)
 	ctx := context.TODO()
+	if s.compression != nil {
+		ctx = withCompressor(ctx, s.compression.forStream())
+	}
 	// Set metadata for forwarding stream.
 	if s.forwardedHost != "" {
 		ctx = metadata.AppendToOutgoingContext(ctx, forwardMetadataKey, s.forwardedHost)
@@ -743,6 +820,8 @@ type batchCommandsClient struct {
 	tikvClientCfg config.TiKVClient
 	tikvLoad      *uint64
 	dialTimeout   time.Duration
+	// compression is the gRPC compression negotiated with the target.
+	compression *storeCompression
 
 	// Increased in each reconnection.
 	// It's used to prevent the connection from reconnecting multiple times
@@ -992,6 +1071,11 @@ func (c *batchCommandsClient) onHealthFeedback(feedback *kvrpcpb.HealthFeedback)
 }
 
 func (c *batchCommandsClient) recreateStreamingClient(err error, streamClient *batchCommandsStream, epoch *uint64) (stopped bool) {
+	if c.compression != nil {
+		// The stream is recreated with the fallback compressor if the target
+		// rejects the current one.
+		c.compression.onError(c.compression.forStream(), err)
+	}
 	// Forbids the batchSendLoop using the old client and
 	// blocks other streams trying to recreate.
 	c.lockForRecreate()
@@ -1036,6 +1120,7 @@ func (c *batchCommandsClient) recreateStreamingClient(err error, streamClient *b
 		}
 		err1 := c.recreateStreamingClientOnce(streamClient)
 		if err1 == nil {
+			c.metrics.onStreamRestart()
 			break
 		}
 
@@ -1048,7 +1133,7 @@ func (c *batchCommandsClient) recreateStreamingClient(err error, streamClient *b
 }
 
 func (c *batchCommandsClient) newBatchStream(forwardedHost string) (*batchCommandsStream, error) {
-	batchStream := &batchCommandsStream{forwardedHost: forwardedHost}
+	batchStream := &batchCommandsStream{forwardedHost: forwardedHost, compression: c.compression}
 	if err := batchStream.recreate(c.conn); err != nil {
 		return nil, err
 	}
@@ -1092,6 +1177,26 @@ func (a *batchConn) Close() {
 	close(a.closed)
 }
 
+// batchLaneOf returns the lane of the request in the batch-commands sender.
+// The requests resolving the locks and keeping the transactions alive are in
+// the high lane, since the other transactions may be waiting for them. The
+// requests tagged by kvrpcpb.CommandPri go to the lanes of their priorities,
+// and the untagged ones are in the normal lane.
+func batchLaneOf(req *tikvrpc.Request) batchLane {
+	switch req.Type {
+	case tikvrpc.CmdTxnHeartBeat, tikvrpc.CmdCheckTxnStatus, tikvrpc.CmdCheckSecondaryLocks,
+		tikvrpc.CmdResolveLock, tikvrpc.CmdCleanup, tikvrpc.CmdPessimisticRollback:
+		return batchLaneHigh
+	}
+	switch req.Priority {
+	case kvrpcpb.CommandPri_High:
+		return batchLaneHigh
+	case kvrpcpb.CommandPri_Low:
+		return batchLaneLow
+	}
+	return batchLaneNormal
+}
+
 func sendBatchRequest(
 	ctx context.Context,
 	addr string,
@@ -1100,6 +1205,7 @@ func sendBatchRequest(
 	req *tikvpb.BatchCommandsRequest_Request,
 	timeout time.Duration,
 	priority uint64,
+	lane batchLane,
 ) (*tikvrpc.Response, error) {
 	entry := &batchCommandsEntry{
 		ctx:           ctx,
@@ -1109,6 +1215,7 @@ func sendBatchRequest(
 		canceled:      0,
 		err:           nil,
 		pri:           priority,
+		lane:          lane,
 		start:         time.Now(),
 	}
 	timer := time.NewTimer(timeout)
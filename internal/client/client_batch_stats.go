@@ -0,0 +1,107 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// BatchStats is the statistics of the batch commands pipeline to a store.
+// The counters are accumulated since the connections to the store are created.
+type BatchStats struct {
+	// Target is the address of the store.
+	Target string
+	// PendingRequests is the number of the requests queued and not sent yet.
+	PendingRequests int
+	// Requests is the number of the requests sent.
+	Requests uint64
+	// Batches is the number of the batches sent.
+	Batches uint64
+	// SendWait is the total time the sent requests waited before being sent.
+	SendWait time.Duration
+	// StreamRestarts is the number of the times the streams are re-created.
+	StreamRestarts uint64
+}
+
+// AvgBatchSize returns the average number of the requests in a batch.
+func (s *BatchStats) AvgBatchSize() float64 {
+	if s.Batches == 0 {
+		return 0
+	}
+	return float64(s.Requests) / float64(s.Batches)
+}
+
+// AvgSendWait returns the average time a request waited before being sent.
+func (s *BatchStats) AvgSendWait() time.Duration {
+	if s.Requests == 0 {
+		return 0
+	}
+	return s.SendWait / time.Duration(s.Requests)
+}
+
+type batchConnStats struct {
+	// builderPending is the number of the requests fetched from the channel
+	// but not sent, updated by the send loop.
+	builderPending atomic.Int64
+	requests       atomic.Uint64
+	batches        atomic.Uint64
+	sendWait       atomic.Int64
+	streamRestarts atomic.Uint64
+}
+
+func (m *batchConnMetrics) observeSendWait(wait time.Duration) {
+	if m.sendWaitDur != nil {
+		m.sendWaitDur.Observe(wait.Seconds())
+	}
+	m.stats.requests.Add(1)
+	m.stats.sendWait.Add(int64(wait))
+}
+
+func (m *batchConnMetrics) onStreamRestart() {
+	if m.streamRestarts != nil {
+		m.streamRestarts.Inc()
+	}
+	m.stats.streamRestarts.Add(1)
+}
+
+func (a *batchConn) stats(target string) BatchStats {
+	s := &a.metrics.stats
+	return BatchStats{
+		Target:          target,
+		PendingRequests: len(a.batchCommandsCh) + int(s.builderPending.Load()),
+		Requests:        s.requests.Load(),
+		Batches:         s.batches.Load(),
+		SendWait:        time.Duration(s.sendWait.Load()),
+		StreamRestarts:  s.streamRestarts.Load(),
+	}
+}
+
+// BatchStats returns the statistics of the batch commands pipelines to the
+// stores, ordered by the addresses. The stores connected without batching are
+// omitted.
+func (c *RPCClient) BatchStats() []BatchStats {
+	c.RLock()
+	stats := make([]BatchStats, 0, len(c.conns))
+	for addr, array := range c.conns {
+		if array.batchConn != nil {
+			stats = append(stats, array.batchConn.stats(addr))
+		}
+	}
+	c.RUnlock()
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Target < stats[j].Target })
+	return stats
+}
@@ -20,7 +20,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
+	"github.com/tikv/client-go/v2/metrics"
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/tikvrpc/interceptor"
 )
@@ -96,3 +100,39 @@ func TestAppendChainedInterceptor(t *testing.T) {
 	chain = interceptor.ChainRPCInterceptors(chain, mkInterceptorFn(1))
 	checkChained(chain, 5, []int{0, 2, 3, 4, 1})
 }
+
+func TestWithInterceptors(t *testing.T) {
+	var executed []string
+	mkInterceptor := func(name string, stop bool) interceptor.RPCInterceptor {
+		return interceptor.NewRPCInterceptor(name, func(next interceptor.RPCInterceptorFunc) interceptor.RPCInterceptorFunc {
+			return func(target string, req *tikvrpc.Request) (*tikvrpc.Response, error) {
+				executed = append(executed, "begin-"+name)
+				defer func() { executed = append(executed, "end-"+name) }()
+				if stop {
+					return &tikvrpc.Response{Resp: &kvrpcpb.GetResponse{Value: []byte(target)}}, nil
+				}
+				return next(target, req)
+			}
+		})
+	}
+	// The interceptors of the same name are all kept, and the last one
+	// returns without sending the request.
+	client := NewRPCClient(WithInterceptors(mkInterceptor("auth", false), mkInterceptor("audit", false), mkInterceptor("audit", false), mkInterceptor("fault", true)))
+	defer client.Close()
+
+	observed := func() uint64 {
+		m := dto.Metric{}
+		h := metrics.TiKVRPCInterceptorHistogram.WithLabelValues("audit", "ok").(prometheus.Histogram)
+		assert.NoError(t, h.Write(&m))
+		return m.GetHistogram().GetSampleCount()
+	}
+	before := observed()
+	resp, err := client.SendRequest(context.Background(), "store1", tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{}), time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("store1"), resp.Resp.(*kvrpcpb.GetResponse).Value)
+	assert.Equal(t, []string{
+		"begin-auth", "begin-audit", "begin-audit", "begin-fault",
+		"end-fault", "end-audit", "end-audit", "end-auth",
+	}, executed)
+	assert.Equal(t, before+2, observed())
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/tikv/client-go/v2/metrics"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/tikvrpc/interceptor"
+)
+
+// WithInterceptors is used to run the interceptors around every RPC sent by
+// the client, e.g. to inject auth tokens, audit the requests or inject faults.
+//
+// The interceptors are executed in the order of the parameters, the first is
+// the outermost: it sees the request first and the response last. Unlike
+// interceptor.RPCInterceptorChain, the interceptors of the same name are all
+// kept. They see the requests and responses before they are encoded and after
+// they are decoded by the codec of the client, and they are called for every
+// attempt including the retries. The time spent in each interceptor, excluding
+// the interceptors after it and the RPC, is observed by the
+// rpc_interceptor_seconds metrics labeled by its name.
+func WithInterceptors(interceptors ...interceptor.RPCInterceptor) Opt {
+	return func(c *option) {
+		c.interceptors = append(c.interceptors[:0:0], interceptors...)
+	}
+}
+
+// interceptorChain is the interceptors set by WithInterceptors.
+type interceptorChain []interceptor.RPCInterceptor
+
+// wrap decorates send with the interceptors in the order.
+func (c interceptorChain) wrap(send interceptor.RPCInterceptorFunc) interceptor.RPCInterceptorFunc {
+	next := send
+	for i := len(c) - 1; i >= 0; i-- {
+		next = observeInterceptor(c[i], next)
+	}
+	return next
+}
+
+// observeInterceptor wraps next with it and observes the time spent in it.
+func observeInterceptor(it interceptor.RPCInterceptor, next interceptor.RPCInterceptorFunc) interceptor.RPCInterceptorFunc {
+	name := it.Name()
+	return func(target string, req *tikvrpc.Request) (*tikvrpc.Response, error) {
+		var inner atomic.Int64
+		wrapped := it.Wrap(func(target string, req *tikvrpc.Request) (*tikvrpc.Response, error) {
+			start := time.Now()
+			resp, err := next(target, req)
+			inner.Add(int64(time.Since(start)))
+			return resp, err
+		})
+		start := time.Now()
+		resp, err := wrapped(target, req)
+		result := "ok"
+		if err != nil {
+			result = "err"
+		}
+		elapsed := time.Since(start) - time.Duration(inner.Load())
+		metrics.TiKVRPCInterceptorHistogram.WithLabelValues(name, result).Observe(elapsed.Seconds())
+		return resp, err
+	}
+}
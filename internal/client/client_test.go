@@ -118,10 +118,10 @@ func TestCancelTimeoutRetErr(t *testing.T) {
 
 	ctx, cancel := context.WithCancel(context.TODO())
 	cancel()
-	_, err := sendBatchRequest(ctx, "", "", a, req, 2*time.Second, 0)
+	_, err := sendBatchRequest(ctx, "", "", a, req, 2*time.Second, 0, batchLaneNormal)
 	assert.Equal(t, errors.Cause(err), context.Canceled)
 
-	_, err = sendBatchRequest(context.Background(), "", "", a, req, 0, 0)
+	_, err = sendBatchRequest(context.Background(), "", "", a, req, 0, 0, batchLaneNormal)
 	assert.Equal(t, errors.Cause(err), context.DeadlineExceeded)
 }
 
@@ -675,7 +675,7 @@ func TestBatchClientRecoverAfterServerRestart(t *testing.T) {
 	assert.Nil(t, err)
 	// send some request, it should be success.
 	for i := 0; i < 100; i++ {
-		_, err = sendBatchRequest(context.Background(), addr, "", conn.batchConn, req, time.Second*20, 0)
+		_, err = sendBatchRequest(context.Background(), addr, "", conn.batchConn, req, time.Second*20, 0, batchLaneNormal)
 		require.NoError(t, err)
 	}
 
@@ -685,7 +685,7 @@ func TestBatchClientRecoverAfterServerRestart(t *testing.T) {
 
 	// send some request, it should be failed since server is down.
 	for i := 0; i < 10; i++ {
-		_, err = sendBatchRequest(context.Background(), addr, "", conn.batchConn, req, time.Millisecond*100, 0)
+		_, err = sendBatchRequest(context.Background(), addr, "", conn.batchConn, req, time.Millisecond*100, 0, batchLaneNormal)
 		require.Error(t, err)
 		time.Sleep(time.Millisecond * time.Duration(rand.Intn(300)))
 		grpcConn := conn.Get()
@@ -728,9 +728,17 @@ func TestBatchClientRecoverAfterServerRestart(t *testing.T) {
 
 	// send some request, it should be success again.
 	for i := 0; i < 100; i++ {
-		_, err = sendBatchRequest(context.Background(), addr, "", conn.batchConn, req, time.Second*20, 0)
+		_, err = sendBatchRequest(context.Background(), addr, "", conn.batchConn, req, time.Second*20, 0, batchLaneNormal)
 		require.NoError(t, err)
 	}
+
+	stats := client.BatchStats()
+	require.Len(t, stats, 1)
+	require.Equal(t, addr, stats[0].Target)
+	require.GreaterOrEqual(t, stats[0].Requests, uint64(200))
+	require.GreaterOrEqual(t, stats[0].AvgBatchSize(), float64(1))
+	require.Greater(t, stats[0].StreamRestarts, uint64(0))
+	require.Equal(t, 0, stats[0].PendingRequests)
 }
 
 func TestLimitConcurrency(t *testing.T) {
@@ -774,6 +782,62 @@ func TestLimitConcurrency(t *testing.T) {
 
 }
 
+func TestBatchLanes(t *testing.T) {
+	re := require.New(t)
+	batch := newBatchConn(1, 128, nil)
+	now := time.Now()
+	newEntry := func(lane batchLane, pri uint64) *batchCommandsEntry {
+		return &batchCommandsEntry{req: &tikvpb.BatchCommandsRequest_Request{}, lane: lane, pri: pri, start: now}
+	}
+	low := newEntry(batchLaneLow, 0)
+	lowHigh := newEntry(batchLaneLow, highTaskPriority+1)
+	normal := newEntry(batchLaneNormal, 0)
+	high := newEntry(batchLaneHigh, 0)
+	for _, e := range []*batchCommandsEntry{low, lowHigh, normal, high} {
+		batch.reqBuilder.push(e)
+	}
+
+	// The high lane and the high priority tasks of any lane go first without
+	// consuming the limit, and the low lane waits for the normal one.
+	var sent []*batchCommandsEntry
+	collect := func(_ uint64, e *batchCommandsEntry) { sent = append(sent, e) }
+	reqs, _ := batch.reqBuilder.buildWithLimit(1, collect)
+	re.Len(reqs.RequestIds, 3)
+	re.Equal([]*batchCommandsEntry{lowHigh, high, normal}, sent)
+	batch.reqBuilder.reset()
+	re.Equal(1, batch.reqBuilder.len())
+
+	// The low lane is sent ahead of the normal one once it's starved.
+	sent = nil
+	batch.reqBuilder.push(newEntry(batchLaneNormal, highTaskPriority-1))
+	low.start = now.Add(-2 * batchLaneLowMaxWait)
+	reqs, _ = batch.reqBuilder.buildWithLimit(1, collect)
+	re.Len(reqs.RequestIds, 1)
+	re.Equal([]*batchCommandsEntry{low}, sent)
+	re.True(low.starved)
+	batch.reqBuilder.reset()
+
+	for _, c := range []struct {
+		cmd  tikvrpc.CmdType
+		pri  kvrpcpb.CommandPri
+		lane batchLane
+	}{
+		{tikvrpc.CmdGet, kvrpcpb.CommandPri_Normal, batchLaneNormal},
+		{tikvrpc.CmdGet, kvrpcpb.CommandPri_High, batchLaneHigh},
+		{tikvrpc.CmdGet, kvrpcpb.CommandPri_Low, batchLaneLow},
+		{tikvrpc.CmdScan, kvrpcpb.CommandPri_Normal, batchLaneNormal},
+		{tikvrpc.CmdScan, kvrpcpb.CommandPri_Low, batchLaneLow},
+		{tikvrpc.CmdScan, kvrpcpb.CommandPri_High, batchLaneHigh},
+		{tikvrpc.CmdCop, kvrpcpb.CommandPri_Normal, batchLaneNormal},
+		{tikvrpc.CmdTxnHeartBeat, kvrpcpb.CommandPri_Low, batchLaneHigh},
+		{tikvrpc.CmdResolveLock, kvrpcpb.CommandPri_Normal, batchLaneHigh},
+		{tikvrpc.CmdCheckTxnStatus, kvrpcpb.CommandPri_Normal, batchLaneHigh},
+	} {
+		req := &tikvrpc.Request{Type: c.cmd, Context: kvrpcpb.Context{Priority: c.pri}}
+		re.Equal(c.lane, batchLaneOf(req), "%s %s", c.cmd, c.pri)
+	}
+}
+
 func TestPrioritySentLimit(t *testing.T) {
 	re := require.New(t)
 	restoreFn := config.UpdateGlobal(func(conf *config.Config) {
@@ -950,7 +1014,7 @@ func TestRandomRestartStoreAndForwarding(t *testing.T) {
 				if i%2 != 0 {
 					forwardedHost = addr2
 				}
-				_, err := sendBatchRequest(context.Background(), addr1, forwardedHost, conn.batchConn, req, time.Millisecond*50, 0)
+				_, err := sendBatchRequest(context.Background(), addr1, forwardedHost, conn.batchConn, req, time.Millisecond*50, 0, batchLaneNormal)
 				if err == nil ||
 					err.Error() == "EOF" ||
 					err.Error() == "rpc error: code = Unavailable desc = error reading from server: EOF" ||
@@ -1033,7 +1097,7 @@ func TestFastFailWhenNoAvailableConn(t *testing.T) {
 	req := &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_Coprocessor{Coprocessor: &coprocessor.Request{}}}
 	conn, err := client.getConnArray(addr, true)
 	assert.Nil(t, err)
-	_, err = sendBatchRequest(context.Background(), addr, "", conn.batchConn, req, time.Second, 0)
+	_, err = sendBatchRequest(context.Background(), addr, "", conn.batchConn, req, time.Second, 0, batchLaneNormal)
 	require.NoError(t, err)
 
 	for _, c := range conn.batchConn.batchCommandsClients {
@@ -1042,7 +1106,7 @@ func TestFastFailWhenNoAvailableConn(t *testing.T) {
 	}
 	start := time.Now()
 	timeout := time.Second
-	_, err = sendBatchRequest(context.Background(), addr, "", conn.batchConn, req, timeout, 0)
+	_, err = sendBatchRequest(context.Background(), addr, "", conn.batchConn, req, timeout, 0, batchLaneNormal)
 	require.Error(t, err)
 	require.Equal(t, "no available connections", err.Error())
 	require.Less(t, time.Since(start), timeout)
@@ -1140,3 +1204,39 @@ func TestBatchPolicy(t *testing.T) {
 		}
 	})
 }
+
+func TestBatchCommandsStreamInterrupted(t *testing.T) {
+	server, port := mockserver.StartMockTikvService()
+	require.True(t, port > 0)
+	defer server.Stop()
+	addr := server.Addr()
+
+	defer config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.MaxBatchSize = 128
+		conf.TiKVClient.GrpcConnectionCount = 1
+	})()
+	rpcClient := NewRPCClient()
+	defer rpcClient.Close()
+
+	// The stream is reset after 2 responses, and the client recreates it.
+	server.InterruptBatchCommands(2, 1)
+	req := tikvrpc.NewRequest(tikvrpc.CmdPrewrite, &kvrpcpb.PrewriteRequest{})
+	var failed int
+	for i := 0; i < 5; i++ {
+		_, err := rpcClient.SendRequest(context.Background(), addr, req, 10*time.Second)
+		if err != nil {
+			failed++
+			require.Equal(t, 2, i)
+		}
+	}
+	require.Equal(t, 1, failed)
+
+	// The cleared interruption resets no stream.
+	server.InterruptBatchCommands(0, 0)
+	server.ClearBatchCommandsInterruption()
+	rpcClient.CloseAddr(addr)
+	for i := 0; i < 5; i++ {
+		_, err := rpcClient.SendRequest(context.Background(), addr, req, 10*time.Second)
+		require.NoError(t, err)
+	}
+}
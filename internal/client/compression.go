@@ -0,0 +1,233 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/tikv/client-go/v2/config"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/status"
+)
+
+const zstdName = "zstd"
+
+func init() {
+	encoding.RegisterCompressor(&zstdCompressor{})
+}
+
+// compressionFallbacks is the compressor to fall back to when a store doesn't
+// support one, and the empty name disables the compression.
+var compressionFallbacks = map[string]string{
+	zstdName:  gzip.Name,
+	gzip.Name: "",
+}
+
+// storeCompression is the gRPC compression negotiated with a store. It starts
+// from the configured compressor, and falls back to the next one in
+// compressionFallbacks once the store rejects it, e.g. zstd isn't supported by
+// all the TiKV versions.
+type storeCompression struct {
+	target string
+	// threshold is the size the requests must exceed to be compressed.
+	threshold uint64
+	name      atomic.Pointer[string]
+}
+
+func newStoreCompression(target string, cfg *config.TiKVClient) *storeCompression {
+	c := &storeCompression{target: target, threshold: cfg.GrpcCompressionThreshold}
+	name := cfg.GrpcCompressionType
+	if name == "none" {
+		name = ""
+	}
+	c.name.Store(&name)
+	return c
+}
+
+// current returns the compressor in use, or "" if the compression is disabled.
+func (c *storeCompression) current() string {
+	return *c.name.Load()
+}
+
+// forRequest returns the compressor for a request of size.
+func (c *storeCompression) forRequest(size int) string {
+	if uint64(size) <= c.threshold {
+		return ""
+	}
+	return c.current()
+}
+
+// forStream returns the compressor of the batch commands streams. A stream
+// compresses all or none of its messages, so it's only compressed if there is
+// no threshold.
+func (c *storeCompression) forStream() string {
+	if c.threshold > 0 {
+		return ""
+	}
+	return c.current()
+}
+
+// bypassBatch returns whether a request should be sent by a unary call instead
+// of the batch commands streams to be compressed.
+func (c *storeCompression) bypassBatch(size int) bool {
+	return c.threshold > 0 && c.forRequest(size) != ""
+}
+
+// onError falls back to the next compressor if err shows the store doesn't
+// support the compressor used, and returns whether the request should be
+// retried with the new one.
+func (c *storeCompression) onError(used string, err error) bool {
+	if used == "" || !isCompressionUnsupported(err) {
+		return false
+	}
+	for {
+		cur := c.name.Load()
+		if *cur != used {
+			// It has fallen back by another request.
+			return true
+		}
+		next := compressionFallbacks[used]
+		if c.name.CompareAndSwap(cur, &next) {
+			logutil.BgLogger().Warn("store doesn't support the gRPC compressor, fall back",
+				zap.String("target", c.target), zap.String("compressor", used), zap.String("fallback", next), zap.Error(err))
+			return true
+		}
+	}
+}
+
+// requestSize returns the size of the request body.
+func requestSize(req *tikvrpc.Request) int {
+	if m, ok := req.Req.(interface{ Size() int }); ok {
+		return m.Size()
+	}
+	return 0
+}
+
+// isCompressionUnsupported returns whether the error is returned by a server
+// not supporting the compressor of the request, which is reported as
+// Unimplemented by both gRPC Go and gRPC core.
+func isCompressionUnsupported(err error) bool {
+	s, ok := status.FromError(err)
+	if !ok || s.Code() != codes.Unimplemented {
+		return false
+	}
+	msg := strings.ToLower(s.Message())
+	return strings.Contains(msg, "compress") || strings.Contains(msg, "grpc-encoding")
+}
+
+type compressorCtxKey struct{}
+
+// withCompressor makes the calls with ctx compress the messages by name, see
+// compressionUnaryInterceptor and compressionStreamInterceptor.
+func withCompressor(ctx context.Context, name string) context.Context {
+	if name == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, compressorCtxKey{}, name)
+}
+
+func compressorFromContext(ctx context.Context, opts []grpc.CallOption) []grpc.CallOption {
+	if name, ok := ctx.Value(compressorCtxKey{}).(string); ok {
+		return append(opts, grpc.UseCompressor(name))
+	}
+	return opts
+}
+
+func compressionUnaryInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(ctx, method, req, reply, cc, compressorFromContext(ctx, opts)...)
+}
+
+func compressionStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(ctx, desc, cc, method, compressorFromContext(ctx, opts)...)
+}
+
+// zstdCompressor is the zstd compressor of gRPC, which reuses the encoders and
+// decoders.
+type zstdCompressor struct {
+	encoders sync.Pool
+	decoders sync.Pool
+}
+
+func (c *zstdCompressor) Name() string {
+	return zstdName
+}
+
+func (c *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	enc, ok := c.encoders.Get().(*zstd.Encoder)
+	if ok {
+		enc.Reset(w)
+	} else {
+		var err error
+		if enc, err = zstd.NewWriter(w, zstd.WithEncoderConcurrency(1)); err != nil {
+			return nil, err
+		}
+	}
+	return &zstdWriter{Encoder: enc, pool: &c.encoders}, nil
+}
+
+func (c *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	dec, ok := c.decoders.Get().(*zstd.Decoder)
+	if ok {
+		if err := dec.Reset(r); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if dec, err = zstd.NewReader(r, zstd.WithDecoderConcurrency(1)); err != nil {
+			return nil, err
+		}
+	}
+	return &zstdReader{Decoder: dec, pool: &c.decoders}, nil
+}
+
+type zstdWriter struct {
+	*zstd.Encoder
+	pool *sync.Pool
+}
+
+func (w *zstdWriter) Close() error {
+	err := w.Encoder.Close()
+	w.pool.Put(w.Encoder)
+	return err
+}
+
+type zstdReader struct {
+	*zstd.Decoder
+	pool *sync.Pool
+}
+
+func (r *zstdReader) Read(p []byte) (int, error) {
+	if r.Decoder == nil {
+		return 0, io.EOF
+	}
+	n, err := r.Decoder.Read(p)
+	if err == io.EOF {
+		// Return the decoder once the message is read up.
+		r.pool.Put(r.Decoder)
+		r.Decoder = nil
+	}
+	return n, err
+}
@@ -0,0 +1,128 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/config"
+	"github.com/tikv/client-go/v2/internal/client/mockserver"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/status"
+)
+
+func TestZstdCompressor(t *testing.T) {
+	c := encoding.GetCompressor(zstdName)
+	require.NotNil(t, c)
+
+	data := bytes.Repeat([]byte("tikv"), 1024)
+	for i := 0; i < 3; i++ {
+		var buf bytes.Buffer
+		w, err := c.Compress(&buf)
+		require.NoError(t, err)
+		_, err = w.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		require.Less(t, buf.Len(), len(data))
+
+		r, err := c.Decompress(&buf)
+		require.NoError(t, err)
+		got, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, data, got)
+	}
+}
+
+func TestStoreCompression(t *testing.T) {
+	cfg := config.DefaultConfig().TiKVClient
+	cfg.GrpcCompressionType = "none"
+	c := newStoreCompression("store", &cfg)
+	require.Equal(t, "", c.current())
+	require.Equal(t, "", c.forStream())
+	require.False(t, c.bypassBatch(1024))
+
+	cfg.GrpcCompressionType = zstdName
+	cfg.GrpcCompressionThreshold = 100
+	c = newStoreCompression("store", &cfg)
+	require.Equal(t, "", c.forRequest(100))
+	require.Equal(t, zstdName, c.forRequest(101))
+	require.Equal(t, "", c.forStream())
+	require.False(t, c.bypassBatch(100))
+	require.True(t, c.bypassBatch(101))
+
+	// Only the errors of the unsupported compressors fall back.
+	require.False(t, c.onError(zstdName, errors.New("connection reset")))
+	require.False(t, c.onError(zstdName, status.Error(codes.Unavailable, "unavailable")))
+	require.False(t, c.onError("", status.Error(codes.Unimplemented, "grpc-encoding")))
+	require.Equal(t, zstdName, c.current())
+
+	unsupported := status.Error(codes.Unimplemented, `grpc: Decompressor is not installed for grpc-encoding "zstd"`)
+	require.True(t, c.onError(zstdName, unsupported))
+	require.Equal(t, gzip.Name, c.current())
+	// Fallen back by another request.
+	require.True(t, c.onError(zstdName, unsupported))
+	require.Equal(t, gzip.Name, c.current())
+	require.True(t, c.onError(gzip.Name, unsupported))
+	require.Equal(t, "", c.current())
+	require.False(t, c.onError("", unsupported))
+}
+
+func TestCompressionNegotiation(t *testing.T) {
+	server, port := mockserver.StartMockTikvService()
+	require.True(t, port > 0)
+	defer server.Stop()
+	addr := fmt.Sprintf("%s:%d", "127.0.0.1", port)
+
+	// Disable batch.
+	defer config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.MaxBatchSize = 0
+		conf.TiKVClient.GrpcConnectionCount = 1
+		conf.TiKVClient.GrpcCompressionType = zstdName
+	})()
+	rpcClient := NewRPCClient()
+	defer rpcClient.Close()
+
+	// The store rejects zstd once.
+	var rejected atomic.Bool
+	server.SetMetaChecker(func(ctx context.Context) error {
+		if rejected.CompareAndSwap(false, true) {
+			return status.Error(codes.Unimplemented, `grpc: Decompressor is not installed for grpc-encoding "zstd"`)
+		}
+		return nil
+	})
+
+	req := tikvrpc.NewRequest(tikvrpc.CmdPrewrite, &kvrpcpb.PrewriteRequest{PrimaryLock: []byte("k")})
+	for i := 0; i < 3; i++ {
+		_, err := rpcClient.SendRequest(context.Background(), addr, req, 10*time.Second)
+		require.NoError(t, err)
+	}
+	require.True(t, rejected.Load())
+
+	conn, err := rpcClient.getConnArray(addr, false)
+	require.NoError(t, err)
+	require.Equal(t, gzip.Name, conn.compression.current())
+}
@@ -34,6 +34,8 @@ import (
 	"github.com/tikv/client-go/v2/internal/logutil"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // MockServer is a mock tikv server for testing purpose.
@@ -48,6 +50,14 @@ type MockServer struct {
 		sync.Mutex
 		check func(context.Context) error
 	}
+	// batchInterruption resets the batch commands streams, see
+	// InterruptBatchCommands.
+	batchInterruption struct {
+		sync.Mutex
+		enabled       bool
+		afterMessages int
+		times         int
+	}
 }
 
 // KvGet implements the TikvServer interface.
@@ -79,6 +89,7 @@ func (s *MockServer) BatchCommands(ss tikvpb.Tikv_BatchCommandsServer) error {
 	if err := s.checkMetadata(ss.Context()); err != nil {
 		return err
 	}
+	remaining, interrupt := s.takeBatchInterruption()
 	var feedbackSeq uint64 = 1
 	for {
 		req, err := ss.Recv()
@@ -86,6 +97,11 @@ func (s *MockServer) BatchCommands(ss tikvpb.Tikv_BatchCommandsServer) error {
 			logutil.BgLogger().Error("batch commands receive fail", zap.Error(err))
 			return err
 		}
+		if interrupt && remaining == 0 {
+			// Reset the stream with the requests in flight.
+			return ErrConnectionReset
+		}
+		remaining--
 
 		responses := make([]*tikvpb.BatchCommandsResponse_Response, 0, len(req.GetRequestIds()))
 		for i := 0; i < len(req.GetRequestIds()); i++ {
@@ -114,6 +130,46 @@ func (s *MockServer) BatchCommands(ss tikvpb.Tikv_BatchCommandsServer) error {
 	}
 }
 
+// ErrConnectionReset is the error the interrupted streams end with, which is
+// what gRPC returns when the connection is reset. It's shared by the stream
+// interruptions of mocktikv.
+var ErrConnectionReset = status.Error(codes.Unavailable, "connection reset by peer")
+
+// InterruptBatchCommands resets the batch commands streams after they send
+// afterMessages responses, failing the requests of the next message received.
+// Times is the number of the streams to reset, 0 means all of them, like
+// mocktikv.StreamInterruption. Call ClearBatchCommandsInterruption to remove it.
+func (s *MockServer) InterruptBatchCommands(afterMessages, times int) {
+	s.batchInterruption.Lock()
+	defer s.batchInterruption.Unlock()
+	s.batchInterruption.enabled = true
+	s.batchInterruption.afterMessages = afterMessages
+	s.batchInterruption.times = times
+}
+
+// ClearBatchCommandsInterruption removes the interruption set by
+// InterruptBatchCommands.
+func (s *MockServer) ClearBatchCommandsInterruption() {
+	s.batchInterruption.Lock()
+	defer s.batchInterruption.Unlock()
+	s.batchInterruption.enabled = false
+}
+
+func (s *MockServer) takeBatchInterruption() (int, bool) {
+	s.batchInterruption.Lock()
+	defer s.batchInterruption.Unlock()
+	if !s.batchInterruption.enabled {
+		return 0, false
+	}
+	if s.batchInterruption.times > 0 {
+		s.batchInterruption.times--
+		if s.batchInterruption.times == 0 {
+			s.batchInterruption.enabled = false
+		}
+	}
+	return s.batchInterruption.afterMessages, true
+}
+
 // SetMetaChecker set the meta checker for mock server.
 func (s *MockServer) SetMetaChecker(check func(context.Context) error) {
 	s.metaChecker.Lock()
@@ -117,6 +117,15 @@ func (pq *PriorityQueue) highestPriority() uint64 {
 	return pq.ps[0].priority()
 }
 
+// promote re-orders the entries whose priorities are raised by f.
+func (pq *PriorityQueue) promote(f func(Item) bool) {
+	for i := 0; i < pq.Len(); i++ {
+		if f(pq.ps[i]) {
+			heap.Fix(&pq.ps, i)
+		}
+	}
+}
+
 // all returns all entries in the priority queue not ensure the priority.
 func (pq *PriorityQueue) all() []Item {
 	items := make([]Item, 0, pq.Len())
@@ -0,0 +1,119 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// StoreTLS overrides the TLS settings of the connections to a store. It's
+// used when the certificate presented by a store doesn't match its address
+// reported by PD, e.g. the store is behind a proxy or NAT.
+type StoreTLS struct {
+	// ServerName is the name to verify the certificate of the store with,
+	// instead of the host of the address.
+	ServerName string
+	// PinnedCertSHA256 are the SHA-256 digests of the DER encoded leaf
+	// certificates accepted from the store. If it's set, the store must
+	// present one of them. If the CA isn't configured, the chain of the
+	// certificate isn't verified and the pinned certificates are the only
+	// trust.
+	PinnedCertSHA256 [][]byte
+	// VerifyPeerCertificate is called after the verifications above with the
+	// certificates presented by the store. The handshake fails if it returns
+	// an error.
+	VerifyPeerCertificate func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error
+}
+
+// StoreTLSResolver returns the TLS overrides of the store at addr, or nil to
+// connect to it with the default settings.
+type StoreTLSResolver func(addr string) *StoreTLS
+
+// WithStoreTLS is used to override the TLS settings per store. The client
+// certificate configured by WithSecurity is still used.
+func WithStoreTLS(resolver StoreTLSResolver) Opt {
+	return func(c *option) {
+		c.storeTLS = resolver
+	}
+}
+
+// dialOptions returns the gRPC dial options of the connections to addr.
+func (o *option) dialOptions(addr string) ([]grpc.DialOption, error) {
+	if o.storeTLS == nil {
+		return o.gRPCDialOptions, nil
+	}
+	t := o.storeTLS(addr)
+	if t == nil {
+		return o.gRPCDialOptions, nil
+	}
+	tlsConfig, err := t.tlsConfig(o.security)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "store %s", addr)
+	}
+	// The transport credentials given later override the default ones.
+	opts := append([]grpc.DialOption(nil), o.gRPCDialOptions...)
+	return append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))), nil
+}
+
+// tlsConfig builds the TLS config of the store on top of the security config.
+func (t *StoreTLS) tlsConfig(security config.Security) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if len(security.ClusterSSLCA) != 0 {
+		var err error
+		if tlsConfig, err = security.ToTLSConfig(); err != nil {
+			return nil, err
+		}
+	} else if len(t.PinnedCertSHA256) > 0 {
+		// There is no CA to verify the chain, only the pinned certificates
+		// are trusted.
+		tlsConfig.InsecureSkipVerify = true
+	} else {
+		return nil, errors.New("either the CA or the pinned certificates are required to connect with TLS")
+	}
+	if t.ServerName != "" {
+		tlsConfig.ServerName = t.ServerName
+	}
+	tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if len(t.PinnedCertSHA256) > 0 && !t.isPinned(rawCerts) {
+			return errors.New("the certificate of the store is not pinned")
+		}
+		if t.VerifyPeerCertificate != nil {
+			return t.VerifyPeerCertificate(rawCerts, verifiedChains)
+		}
+		return nil
+	}
+	return tlsConfig, nil
+}
+
+func (t *StoreTLS) isPinned(rawCerts [][]byte) bool {
+	if len(rawCerts) == 0 {
+		return false
+	}
+	digest := sha256.Sum256(rawCerts[0])
+	for _, pinned := range t.PinnedCertSHA256 {
+		if bytes.Equal(pinned, digest[:]) {
+			return true
+		}
+	}
+	return false
+}
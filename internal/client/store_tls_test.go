@@ -0,0 +1,88 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/config"
+)
+
+func newTestCert(t *testing.T, name string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestStoreTLS(t *testing.T) {
+	cert, other := newTestCert(t, "tikv-1"), newTestCert(t, "tikv-2")
+	digest := sha256.Sum256(cert)
+
+	_, err := (&StoreTLS{ServerName: "tikv-1"}).tlsConfig(config.Security{})
+	require.Error(t, err)
+
+	var called int
+	storeTLS := &StoreTLS{
+		ServerName:       "tikv-1",
+		PinnedCertSHA256: [][]byte{digest[:]},
+		VerifyPeerCertificate: func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			called++
+			return errors.New("rejected")
+		},
+	}
+	tlsConfig, err := storeTLS.tlsConfig(config.Security{})
+	require.NoError(t, err)
+	require.Equal(t, "tikv-1", tlsConfig.ServerName)
+	require.True(t, tlsConfig.InsecureSkipVerify)
+	require.ErrorContains(t, tlsConfig.VerifyPeerCertificate([][]byte{other}, nil), "not pinned")
+	require.Zero(t, called)
+	require.ErrorContains(t, tlsConfig.VerifyPeerCertificate([][]byte{cert}, nil), "rejected")
+	require.Equal(t, 1, called)
+
+	storeTLS.VerifyPeerCertificate = nil
+	require.NoError(t, tlsConfig.VerifyPeerCertificate([][]byte{cert}, nil))
+
+	o := &option{storeTLS: func(addr string) *StoreTLS {
+		if addr == "store1" {
+			return storeTLS
+		}
+		return nil
+	}}
+	opts, err := o.dialOptions("store1")
+	require.NoError(t, err)
+	require.Len(t, opts, 1)
+	opts, err = o.dialOptions("store2")
+	require.NoError(t, err)
+	require.Empty(t, opts)
+}
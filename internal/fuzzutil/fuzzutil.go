@@ -0,0 +1,80 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fuzzutil helps fuzz targets to build structured inputs, e.g. keys
+// and operations, from the raw bytes generated by `go test -fuzz`. It reads
+// the input deterministically, so a failing input in testdata/fuzz always
+// reproduces the same case.
+package fuzzutil
+
+// Reader consumes the raw fuzz input. All methods return zero values after
+// the input is exhausted.
+type Reader struct {
+	data []byte
+}
+
+// NewReader creates a Reader of data.
+func NewReader(data []byte) *Reader {
+	return &Reader{data: data}
+}
+
+// Done returns whether the input is exhausted.
+func (r *Reader) Done() bool {
+	return len(r.data) == 0
+}
+
+// Byte reads one byte.
+func (r *Reader) Byte() byte {
+	if len(r.data) == 0 {
+		return 0
+	}
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b
+}
+
+// Bool reads one bit of information.
+func (r *Reader) Bool() bool {
+	return r.Byte()&1 == 1
+}
+
+// Intn reads an int in [0, n). n must be > 0.
+func (r *Reader) Intn(n int) int {
+	v := int(r.Byte())<<8 | int(r.Byte())
+	return v % n
+}
+
+// Bytes reads a byte slice of at most maxLen bytes. The length is read from
+// the input first.
+func (r *Reader) Bytes(maxLen int) []byte {
+	n := r.Intn(maxLen + 1)
+	if n > len(r.data) {
+		n = len(r.data)
+	}
+	b := make([]byte, n)
+	copy(b, r.data[:n])
+	r.data = r.data[n:]
+	return b
+}
+
+// Keys reads at most maxCount keys whose lengths are at most maxLen. Keys may
+// be empty or duplicated.
+func (r *Reader) Keys(maxCount, maxLen int) [][]byte {
+	n := r.Intn(maxCount + 1)
+	keys := make([][]byte, 0, n)
+	for i := 0; i < n && !r.Done(); i++ {
+		keys = append(keys, r.Bytes(maxLen))
+	}
+	return keys
+}
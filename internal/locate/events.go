@@ -0,0 +1,177 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType is the type of an Event.
+type EventType int
+
+const (
+	// EventRegionInvalidated is published when a cached region is invalidated.
+	// RegionID and Reason are set.
+	EventRegionInvalidated EventType = iota + 1
+	// EventLeaderChanged is published when a region error reports a new leader.
+	// RegionID, StoreID of the new leader and Addr are set.
+	EventLeaderChanged
+	// EventStoreStateChanged is published when the state of a store changes.
+	// StoreID, Addr and State are set.
+	EventStoreStateChanged
+	// EventLockResolved is published when the lock of a finished transaction is
+	// resolved. TxnID, Key and CommitTS are set, and CommitTS is zero if the
+	// transaction is rolled back.
+	EventLockResolved
+	// EventSafePointUpdated is published when the GC safe point loaded from
+	// PD changes. SafePoint is set.
+	EventSafePointUpdated
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventRegionInvalidated:
+		return "RegionInvalidated"
+	case EventLeaderChanged:
+		return "LeaderChanged"
+	case EventStoreStateChanged:
+		return "StoreStateChanged"
+	case EventLockResolved:
+		return "LockResolved"
+	case EventSafePointUpdated:
+		return "SafePointUpdated"
+	}
+	return "Unknown"
+}
+
+// Event is a structured event observed by the client. Only the fields
+// documented for its type are set.
+type Event struct {
+	Type EventType
+	Time time.Time
+
+	RegionID uint64
+	StoreID  uint64
+	Addr     string
+	// Reason is why a region is invalidated.
+	Reason string
+	// State is the new state of a store: "needCheck", "tombstone",
+	// "unreachable", "reachable", or "changed" if its address or labels are
+	// changed.
+	State string
+
+	TxnID    uint64
+	Key      []byte
+	CommitTS uint64
+
+	SafePoint uint64
+}
+
+// eventBus fans the events out to the subscribers. A subscriber which doesn't
+// keep up misses the events published while its channel is full, so a slow
+// consumer never blocks the client.
+type eventBus struct {
+	// subscribed is the number of the subscribers, to skip building the events
+	// when there is none.
+	subscribed atomic.Int32
+	mu         sync.RWMutex
+	subs       []chan Event
+	closed     bool
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{}
+}
+
+func (b *eventBus) subscribe(size int) <-chan Event {
+	ch := make(chan Event, size)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch
+	}
+	b.subs = append(b.subs, ch)
+	b.subscribed.Add(1)
+	return ch
+}
+
+func (b *eventBus) enabled() bool {
+	return b != nil && b.subscribed.Load() > 0
+}
+
+func (b *eventBus) publish(e Event) {
+	if !b.enabled() {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+func (b *eventBus) close() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for _, ch := range b.subs {
+		close(ch)
+	}
+	b.subs = nil
+	b.subscribed.Store(0)
+}
+
+// SubscribeEvents returns a channel receiving the events observed by the
+// client from now on, with a buffer of the given size. The events are dropped
+// for the subscriber while the buffer is full. The channel is closed when the
+// region cache is closed.
+func (c *RegionCache) SubscribeEvents(size int) <-chan Event {
+	return c.events.subscribe(size)
+}
+
+// EventsEnabled returns whether there is any subscriber of the events, so the
+// publishers can skip building the events.
+func (c *RegionCache) EventsEnabled() bool {
+	return c.events.enabled()
+}
+
+// PublishEvent publishes an event observed by the components sharing the
+// region cache, e.g. the lock resolver.
+func (c *RegionCache) PublishEvent(e Event) {
+	c.events.publish(e)
+}
+
+func (c *storeCacheImpl) publishEvent(e Event) {
+	c.events.publish(e)
+}
+
+func publishStoreState(c storeCache, s *Store, state string) {
+	c.publishEvent(Event{Type: EventStoreStateChanged, StoreID: s.storeID, Addr: s.addr, State: state})
+}
@@ -0,0 +1,310 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/coprocessor"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+const (
+	// defaultHotRegionWindow is the window to measure the load of the regions
+	// if HotRegionConfig.Window is not set.
+	defaultHotRegionWindow = 10 * time.Second
+	// hotRegionKeySamples is the number of the keys sampled in a window to
+	// choose the split key of a hot region.
+	hotRegionKeySamples = 32
+)
+
+// HotRegionConfig is the thresholds of the hot regions detected by the client.
+type HotRegionConfig struct {
+	// QPSThreshold is the requests per second a region must reach to be hot,
+	// 0 means not checking the QPS.
+	QPSThreshold float64
+	// BytesThreshold is the request and response bytes per second a region
+	// must reach to be hot, 0 means not checking the bytes.
+	BytesThreshold float64
+	// Window is the period the load is measured over, 10s by default.
+	Window time.Duration
+	// AutoSplit makes the store ask PD to split the hot regions at their
+	// split keys, and Scatter makes PD scatter the new regions as well.
+	AutoSplit bool
+	Scatter   bool
+}
+
+// HotRegion is a region whose load measured by the client exceeds the
+// thresholds of HotRegionConfig.
+type HotRegion struct {
+	RegionID uint64
+	StartKey []byte
+	EndKey   []byte
+	// QPS and BytesRate are the load of the window the region is hot in.
+	QPS       float64
+	BytesRate float64
+	// SplitKey is the median of the keys sampled from the requests, which
+	// splits the load evenly. It's nil if no sampled key is inside the region.
+	SplitKey []byte
+}
+
+// regionLoad is the load of a region in the current and the last windows.
+type regionLoad struct {
+	mu       sync.Mutex
+	startKey []byte
+	endKey   []byte
+
+	windowStart time.Time
+	reqs        int
+	bytes       int
+	samples     [][]byte
+	reported    bool
+
+	// last is the hot region of the last window, nil if it wasn't hot.
+	last *HotRegion
+}
+
+// hotRegionTracker measures the load of the regions with fixed windows. A
+// region is reported once per window as soon as its load in the window reaches
+// the thresholds.
+type hotRegionTracker struct {
+	cfg   HotRegionConfig
+	onHot func(HotRegion)
+
+	regions   sync.Map // regionID -> *regionLoad
+	lastPrune atomic.Int64
+}
+
+func newHotRegionTracker(cfg HotRegionConfig, onHot func(HotRegion)) *hotRegionTracker {
+	if cfg.Window <= 0 {
+		cfg.Window = defaultHotRegionWindow
+	}
+	t := &hotRegionTracker{cfg: cfg, onHot: onHot}
+	t.lastPrune.Store(time.Now().UnixNano())
+	return t
+}
+
+func (t *hotRegionTracker) isHot(reqs, bytes int) bool {
+	secs := t.cfg.Window.Seconds()
+	return (t.cfg.QPSThreshold > 0 && float64(reqs) >= t.cfg.QPSThreshold*secs) ||
+		(t.cfg.BytesThreshold > 0 && float64(bytes) >= t.cfg.BytesThreshold*secs)
+}
+
+// hotRegion builds the hot region of the current window, l.mu must be held.
+func (t *hotRegionTracker) hotRegion(regionID uint64, l *regionLoad) *HotRegion {
+	secs := t.cfg.Window.Seconds()
+	return &HotRegion{
+		RegionID:  regionID,
+		StartKey:  l.startKey,
+		EndKey:    l.endKey,
+		QPS:       float64(l.reqs) / secs,
+		BytesRate: float64(l.bytes) / secs,
+		SplitKey:  medianSplitKey(l.samples, l.startKey, l.endKey),
+	}
+}
+
+func (t *hotRegionTracker) record(now time.Time, region *Region, key []byte, size int) {
+	regionID := region.GetID()
+	v, ok := t.regions.Load(regionID)
+	if !ok {
+		v, _ = t.regions.LoadOrStore(regionID, &regionLoad{windowStart: now})
+	}
+	l := v.(*regionLoad)
+
+	var hot *HotRegion
+	l.mu.Lock()
+	if elapsed := now.Sub(l.windowStart); elapsed >= t.cfg.Window {
+		l.last = nil
+		if elapsed < 2*t.cfg.Window && t.isHot(l.reqs, l.bytes) {
+			l.last = t.hotRegion(regionID, l)
+		}
+		l.windowStart, l.reqs, l.bytes, l.samples, l.reported = now, 0, 0, l.samples[:0], false
+	}
+	// The range may change by splits and merges.
+	l.startKey, l.endKey = region.StartKey(), region.EndKey()
+	l.reqs++
+	l.bytes += size
+	if len(key) > 0 {
+		if len(l.samples) < hotRegionKeySamples {
+			l.samples = append(l.samples, append([]byte(nil), key...))
+		} else if i := rand.Intn(l.reqs); i < hotRegionKeySamples {
+			l.samples[i] = append(l.samples[i][:0], key...)
+		}
+	}
+	if !l.reported && t.isHot(l.reqs, l.bytes) {
+		l.reported = true
+		hot = t.hotRegion(regionID, l)
+	}
+	l.mu.Unlock()
+
+	if hot != nil && t.onHot != nil {
+		t.onHot(*hot)
+	}
+	t.prune(now)
+}
+
+// prune removes the regions without requests in the last two windows.
+func (t *hotRegionTracker) prune(now time.Time) {
+	last := t.lastPrune.Load()
+	if now.UnixNano()-last < int64(t.cfg.Window) || !t.lastPrune.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	t.regions.Range(func(k, v any) bool {
+		l := v.(*regionLoad)
+		l.mu.Lock()
+		idle := now.Sub(l.windowStart) >= 2*t.cfg.Window
+		l.mu.Unlock()
+		if idle {
+			t.regions.Delete(k)
+		}
+		return true
+	})
+}
+
+// hotRegions returns the regions hot in the current or the last window.
+func (t *hotRegionTracker) hotRegions(now time.Time) []HotRegion {
+	var hot []HotRegion
+	t.regions.Range(func(k, v any) bool {
+		l := v.(*regionLoad)
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		elapsed := now.Sub(l.windowStart)
+		if elapsed < t.cfg.Window && l.reported {
+			hot = append(hot, *t.hotRegion(k.(uint64), l))
+		} else if elapsed < 2*t.cfg.Window && elapsed >= t.cfg.Window && t.isHot(l.reqs, l.bytes) {
+			hot = append(hot, *t.hotRegion(k.(uint64), l))
+		} else if elapsed < t.cfg.Window && l.last != nil {
+			hot = append(hot, *l.last)
+		}
+		return true
+	})
+	sort.Slice(hot, func(i, j int) bool { return hot[i].QPS > hot[j].QPS })
+	return hot
+}
+
+// medianSplitKey returns the median of the sampled keys inside (startKey,
+// endKey), or nil if there is none.
+func medianSplitKey(samples [][]byte, startKey, endKey []byte) []byte {
+	keys := make([][]byte, 0, len(samples))
+	for _, k := range samples {
+		if bytes.Compare(k, startKey) > 0 && (len(endKey) == 0 || bytes.Compare(k, endKey) < 0) {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+	return append([]byte(nil), keys[len(keys)/2]...)
+}
+
+// hotRegionSampleKey returns a key accessed by the request to sample.
+func hotRegionSampleKey(req *tikvrpc.Request) []byte {
+	switch r := req.Req.(type) {
+	case *kvrpcpb.GetRequest:
+		return r.Key
+	case *kvrpcpb.BatchGetRequest:
+		if len(r.Keys) > 0 {
+			return r.Keys[len(r.Keys)/2]
+		}
+	case *kvrpcpb.ScanRequest:
+		return r.StartKey
+	case *kvrpcpb.PrewriteRequest:
+		if len(r.Mutations) > 0 {
+			return r.Mutations[len(r.Mutations)/2].Key
+		}
+	case *kvrpcpb.PessimisticLockRequest:
+		if len(r.Mutations) > 0 {
+			return r.Mutations[len(r.Mutations)/2].Key
+		}
+	case *kvrpcpb.CommitRequest:
+		if len(r.Keys) > 0 {
+			return r.Keys[len(r.Keys)/2]
+		}
+	case *kvrpcpb.RawGetRequest:
+		return r.Key
+	case *kvrpcpb.RawPutRequest:
+		return r.Key
+	case *kvrpcpb.RawBatchGetRequest:
+		if len(r.Keys) > 0 {
+			return r.Keys[len(r.Keys)/2]
+		}
+	case *kvrpcpb.RawBatchPutRequest:
+		if len(r.Pairs) > 0 {
+			return r.Pairs[len(r.Pairs)/2].Key
+		}
+	case *kvrpcpb.RawScanRequest:
+		return r.StartKey
+	case *coprocessor.Request:
+		if len(r.Ranges) > 0 {
+			return r.Ranges[0].Start
+		}
+	}
+	return nil
+}
+
+func messageSize(m any) int {
+	if s, ok := m.(interface{ Size() int }); ok {
+		return s.Size()
+	}
+	return 0
+}
+
+// SetHotRegionDetection measures the load of the regions by the requests sent
+// through the region cache, and calls onHot once per window for each region
+// reaching the thresholds of cfg. Pass nil to disable the detection.
+func (c *RegionCache) SetHotRegionDetection(cfg *HotRegionConfig, onHot func(HotRegion)) {
+	if cfg == nil {
+		c.hotRegions.Store(nil)
+		return
+	}
+	c.hotRegions.Store(newHotRegionTracker(*cfg, onHot))
+}
+
+// HotRegions returns the regions hot in the current or the last window, sorted
+// by QPS in descending order, see SetHotRegionDetection.
+func (c *RegionCache) HotRegions() []HotRegion {
+	t := c.hotRegions.Load()
+	if t == nil {
+		return nil
+	}
+	return t.hotRegions(time.Now())
+}
+
+// onRegionResponse records the load of the request to the region.
+func (c *RegionCache) onRegionResponse(rpcCtx *RPCContext, req *tikvrpc.Request, resp *tikvrpc.Response) {
+	if c == nil || rpcCtx == nil || rpcCtx.Meta == nil {
+		return
+	}
+	t := c.hotRegions.Load()
+	if t == nil {
+		return
+	}
+	region := c.GetCachedRegionWithRLock(rpcCtx.Region)
+	if region == nil {
+		return
+	}
+	size := messageSize(req.Req)
+	if resp != nil {
+		size += messageSize(resp.Resp)
+	}
+	t.record(time.Now(), region, hotRegionSampleKey(req), size)
+}
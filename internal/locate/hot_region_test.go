@@ -0,0 +1,79 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHotRegionTracker(t *testing.T) {
+	var reported []HotRegion
+	tracker := newHotRegionTracker(HotRegionConfig{QPSThreshold: 10, BytesThreshold: 1000, Window: time.Second}, func(r HotRegion) {
+		reported = append(reported, r)
+	})
+	r1 := &Region{meta: &metapb.Region{Id: 1, StartKey: []byte("a"), EndKey: []byte("m")}}
+	r2 := &Region{meta: &metapb.Region{Id: 2, StartKey: []byte("m"), EndKey: []byte("")}}
+	now := time.Now()
+
+	// 10 requests in the window make region 1 hot, and it's reported once.
+	for i := 0; i < 20; i++ {
+		tracker.record(now, r1, []byte(fmt.Sprintf("k%02d", i)), 1)
+	}
+	// Region 2 isn't hot by QPS but by bytes.
+	tracker.record(now, r2, []byte("n"), 500)
+	require.Len(t, reported, 1)
+	require.Equal(t, uint64(1), reported[0].RegionID)
+	require.Equal(t, float64(10), reported[0].QPS)
+	require.Equal(t, []byte("k05"), reported[0].SplitKey)
+	tracker.record(now, r2, []byte("n"), 500)
+	require.Len(t, reported, 2)
+	require.Equal(t, uint64(2), reported[1].RegionID)
+	require.Equal(t, float64(1000), reported[1].BytesRate)
+	require.Equal(t, []byte("n"), reported[1].SplitKey)
+
+	hot := tracker.hotRegions(now)
+	require.Len(t, hot, 2)
+	require.Equal(t, uint64(1), hot[0].RegionID)
+	require.Equal(t, float64(20), hot[0].QPS)
+	require.Equal(t, []byte("k10"), hot[0].SplitKey)
+
+	// The hot regions of the last window are kept in the next window.
+	now = now.Add(time.Second)
+	tracker.record(now, r1, []byte("k00"), 1)
+	hot = tracker.hotRegions(now)
+	require.Len(t, hot, 2)
+	require.Equal(t, float64(20), hot[0].QPS)
+	require.Len(t, reported, 2)
+
+	// The regions become cold, and the idle ones are pruned.
+	now = now.Add(2 * time.Second)
+	tracker.record(now, r1, []byte("k00"), 1)
+	require.Empty(t, tracker.hotRegions(now))
+	_, ok := tracker.regions.Load(uint64(2))
+	require.False(t, ok)
+}
+
+func TestMedianSplitKey(t *testing.T) {
+	samples := [][]byte{[]byte("c"), []byte("a"), []byte("e"), []byte("b"), []byte("z")}
+	require.Equal(t, []byte("c"), medianSplitKey(samples, []byte("a"), []byte("f")))
+	require.Equal(t, []byte("e"), medianSplitKey(samples, []byte("b"), nil))
+	// The start key of the region can't split it.
+	require.Nil(t, medianSplitKey(samples, []byte("e"), []byte("f")))
+}
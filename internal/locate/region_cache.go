@@ -37,7 +37,6 @@ package locate
 import (
 	"bytes"
 	"context"
-	"encoding/hex"
 	"fmt"
 	"math/rand"
 	"slices"
@@ -66,6 +65,8 @@ import (
 	"github.com/tikv/client-go/v2/metrics"
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/util"
+	"github.com/tikv/client-go/v2/util/redact"
+	"github.com/tikv/client-go/v2/util/slowlog"
 	pd "github.com/tikv/pd/client"
 	"github.com/tikv/pd/client/clients/router"
 	"github.com/tikv/pd/client/opt"
@@ -142,8 +143,9 @@ var pdRegionMetaCircuitBreaker = circuitbreaker.NewCircuitBreaker("region-meta",
 		HalfOpenSuccessCount: 1,
 	})
 
-// wrap context with circuit breaker for PD region metadata calls
-func withPDCircuitBreaker(ctx context.Context) context.Context {
+// WithPDCircuitBreaker wraps the context with the circuit breaker for the PD
+// region metadata calls.
+func WithPDCircuitBreaker(ctx context.Context) context.Context {
 	return circuitbreaker.WithCircuitBreaker(ctx, pdRegionMetaCircuitBreaker)
 }
 
@@ -656,15 +658,60 @@ type RegionCache struct {
 
 	stores storeCache
 
+	// events publishes the events observed to the subscribers.
+	events *eventBus
+
 	// runner for background jobs
 	bg *bgRunner
 
 	clusterID uint64
+
+	slowLogger atomic.Pointer[slowlog.Logger]
+
+	writePacing atomic.Bool
+	writePacers sync.Map // storeID -> *writePacer
+
+	hotRegions atomic.Pointer[hotRegionTracker]
+
+	replicaSelectorPolicy atomic.Pointer[ReplicaSelectorPolicy]
+
+	// scanPrefetch is the number of regions to prefetch for scans.
+	scanPrefetch atomic.Int64
+	prefetching  atomic.Bool
+
+	// regionMap serves the routing while PD is unavailable, see EnableRegionMapFallback.
+	regionMap       atomic.Pointer[regionMap]
+	routingDegraded atomic.Bool
+}
+
+// SetSlowLogger sets the logger of the slow requests sent through the region
+// cache. Pass nil to disable the slow log.
+func (c *RegionCache) SetSlowLogger(l *slowlog.Logger) {
+	c.slowLogger.Store(l)
+}
+
+// InheritSettings copies the settings of the requests sent through parent to
+// c, i.e. the slow logger, the write pacing, the replica selector policy and
+// the scan prefetch. The hot region detection and the region map fallback are
+// not copied, because they are bound to the keys cached by parent.
+func (c *RegionCache) InheritSettings(parent *RegionCache) {
+	c.slowLogger.Store(parent.slowLogger.Load())
+	c.writePacing.Store(parent.writePacing.Load())
+	c.replicaSelectorPolicy.Store(parent.replicaSelectorPolicy.Load())
+	c.scanPrefetch.Store(parent.scanPrefetch.Load())
+}
+
+func (c *RegionCache) getSlowLogger() *slowlog.Logger {
+	if c == nil {
+		return nil
+	}
+	return c.slowLogger.Load()
 }
 
 type regionCacheOptions struct {
 	noHealthTick                  bool
 	requestHealthFeedbackCallback func(ctx context.Context, addr string) error
+	storeAddrChangedCallback      func(oldAddr, newAddr string)
 }
 
 type RegionCacheOpt func(*regionCacheOptions)
@@ -679,6 +726,14 @@ func WithRequestHealthFeedbackCallback(callback func(ctx context.Context, addr s
 	}
 }
 
+// WithStoreAddrChangedCallback sets the callback called when the address of a store changes, e.g. to close the
+// connections to the old address.
+func WithStoreAddrChangedCallback(callback func(oldAddr, newAddr string)) RegionCacheOpt {
+	return func(options *regionCacheOptions) {
+		options.storeAddrChangedCallback = callback
+	}
+}
+
 // NewRegionCache creates a RegionCache.
 func NewRegionCache(pdClient pd.Client, opt ...RegionCacheOpt) *RegionCache {
 	var options regionCacheOptions
@@ -696,7 +751,11 @@ func NewRegionCache(pdClient pd.Client, opt ...RegionCacheOpt) *RegionCache {
 		c.codec = codecPDClient.GetCodec()
 	}
 
-	c.stores = newStoreCache(pdClient)
+	c.events = newEventBus()
+	stores := newStoreCache(pdClient, c.events)
+	stores.addrChangedCallback = options.storeAddrChangedCallback
+	stores.regionMap = &c.regionMap
+	c.stores = stores
 	c.bg = newBackgroundRunner(context.Background())
 	c.enableForwarding = config.GetGlobalConfig().EnableForwarding
 	if c.pdClient != nil {
@@ -810,6 +869,7 @@ func (c *RegionCache) insertRegionToCache(cachedRegion *Region, invalidateOldReg
 // Close releases region cache's resource.
 func (c *RegionCache) Close() {
 	c.bg.shutdown(true)
+	c.events.close()
 }
 
 // checkAndResolve checks and resolve addr of failed stores.
@@ -840,7 +900,9 @@ func (c *RegionCache) SetRegionCacheStore(id uint64, addr string, peerAddr strin
 // SetPDClient replaces pd client,for testing only
 func (c *RegionCache) SetPDClient(client pd.Client) {
 	c.pdClient = client
-	c.stores = newStoreCache(client)
+	stores := newStoreCache(client, c.events)
+	stores.regionMap = &c.regionMap
+	c.stores = stores
 }
 
 // RPCContext contains data that is needed to send RPC to a region.
@@ -1000,6 +1062,23 @@ func (c *RegionCache) GetTiKVRPCContext(bo *retry.Backoffer, id RegionVerID, rep
 	}, nil
 }
 
+// HasTiFlashPeer reports whether the cached region has TiFlash peers the requests can be sent to, ok is false if the
+// region is not cached or invalid.
+func (c *RegionCache) HasTiFlashPeer(id RegionVerID) (has bool, ok bool) {
+	cachedRegion := c.GetCachedRegionWithRLock(id)
+	if !cachedRegion.isValid() {
+		return false, false
+	}
+	regionStore := cachedRegion.getStore()
+	for i := 0; i < regionStore.accessStoreNum(tiFlashOnly); i++ {
+		_, store := regionStore.accessStore(tiFlashOnly, AccessIndex(i))
+		if LabelFilterNoTiFlashWriteNode(store.labels) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
 // GetAllValidTiFlashStores returns the store ids of all valid TiFlash stores, the store id of currentStore is always the first one
 // Caller may use `nonPendingStores` first, this can avoid task need to wait tiflash replica syncing from tikv.
 // But if all tiflash peers are pending(len(nonPendingStores) == 0), use `allStores` is also ok.
@@ -1126,7 +1205,7 @@ func (l *KeyLocation) Contains(key []byte) bool {
 
 // String implements fmt.Stringer interface.
 func (l *KeyLocation) String() string {
-	return fmt.Sprintf("region %s,startKey:%s,endKey:%s", l.Region.String(), kv.StrKey(l.StartKey), kv.StrKey(l.EndKey))
+	return fmt.Sprintf("region %s,startKey:%s,endKey:%s", l.Region.String(), redact.Key(l.StartKey), redact.Key(l.EndKey))
 }
 
 // GetBucketVersion gets the bucket version of the region.
@@ -1181,7 +1260,7 @@ func (l *KeyLocation) LocateBucket(key []byte) *Bucket {
 	}
 	// unreachable
 	logutil.Logger(context.Background()).Info(
-		"Unreachable place", zap.String("KeyLocation", l.String()), zap.String("Key", hex.EncodeToString(key)))
+		"Unreachable place", zap.String("KeyLocation", l.String()), zap.String("Key", redact.Key(key)))
 	panic("Unreachable")
 }
 
@@ -1547,8 +1626,8 @@ func (c *RegionCache) findRegionByKey(bo *retry.Backoffer, key []byte, isEndKey
 		if err != nil {
 			// ignore error and use old region info.
 			logutil.Logger(bo.GetCtx()).Error("load region failure",
-				zap.String("key", util.HexRegionKeyStr(key)), zap.Error(err),
-				zap.String("encode-key", util.HexRegionKeyStr(c.codec.EncodeRegionKey(key))))
+				zap.String("key", redact.RegionKey(key)), zap.Error(err),
+				zap.String("encode-key", redact.RegionKey(c.codec.EncodeRegionKey(key))))
 		} else {
 			logutil.Eventf(bo.GetCtx(), "load region %d from pd, due to need-reload", lr.GetID())
 			reloadOnAccess := flags&needReloadOnAccess > 0
@@ -1801,8 +1880,8 @@ func (c *RegionCache) BatchLoadRegionsWithKeyRange(bo *retry.Backoffer, startKey
 	}
 	if len(regions) == 0 {
 		err = errors.Errorf("PD returned no region, start_key: %q, end_key: %q, encode_start_key: %q, encode_end_key: %q",
-			util.HexRegionKeyStr(startKey), util.HexRegionKeyStr(endKey),
-			util.HexRegionKeyStr(c.codec.EncodeRegionKey(startKey)), util.HexRegionKeyStr(c.codec.EncodeRegionKey(endKey)))
+			redact.RegionKey(startKey), redact.RegionKey(endKey),
+			redact.RegionKey(c.codec.EncodeRegionKey(startKey)), redact.RegionKey(c.codec.EncodeRegionKey(endKey)))
 		return
 	}
 
@@ -1865,6 +1944,7 @@ func (c *RegionCache) InvalidateCachedRegionWithReason(id RegionVerID, reason In
 		return
 	}
 	cachedRegion.invalidate(reason)
+	c.events.publish(Event{Type: EventRegionInvalidated, RegionID: id.GetID(), Reason: reason.String()})
 }
 
 // UpdateLeader update some region cache with newer leader info.
@@ -1891,11 +1971,19 @@ func (c *RegionCache) UpdateLeader(regionID RegionVerID, leader *metapb.Peer, cu
 			zap.Int("currIdx", int(currentPeerIdx)),
 			zap.Uint64("leaderStoreID", leader.GetStoreId()))
 		r.invalidate(StoreNotFound)
+		c.events.publish(Event{Type: EventRegionInvalidated, RegionID: regionID.GetID(), Reason: StoreNotFound.String()})
 	} else {
 		logutil.BgLogger().Info("switch region leader to specific leader due to kv return NotLeader",
 			zap.Uint64("regionID", regionID.GetID()),
 			zap.Int("currIdx", int(currentPeerIdx)),
 			zap.Uint64("leaderStoreID", leader.GetStoreId()))
+		if c.events.enabled() {
+			e := Event{Type: EventLeaderChanged, RegionID: regionID.GetID(), StoreID: leader.GetStoreId()}
+			if s, ok := c.stores.get(leader.GetStoreId()); ok {
+				e.Addr = s.addr
+			}
+			c.events.publish(e)
+		}
 	}
 }
 
@@ -2090,9 +2178,9 @@ func (c *RegionCache) loadRegion(bo *retry.Backoffer, key []byte, isEndKey bool,
 		var reg *router.Region
 		var err error
 		if searchPrev {
-			reg, err = c.pdClient.GetPrevRegion(withPDCircuitBreaker(ctx), key, opts...)
+			reg, err = c.pdClient.GetPrevRegion(WithPDCircuitBreaker(ctx), key, opts...)
 		} else {
-			reg, err = c.pdClient.GetRegion(withPDCircuitBreaker(ctx), key, opts...)
+			reg, err = c.pdClient.GetRegion(WithPDCircuitBreaker(ctx), key, opts...)
 		}
 		metrics.LoadRegionCacheHistogramWhenCacheMiss.Observe(time.Since(start).Seconds())
 		if err != nil {
@@ -2103,13 +2191,20 @@ func (c *RegionCache) loadRegion(bo *retry.Backoffer, key []byte, isEndKey bool,
 		if err != nil {
 			if apicodec.IsDecodeError(err) {
 				return nil, errors.Errorf("failed to decode region range key, key: %q, err: %v, encode_key: %q",
-					util.HexRegionKeyStr(key), err, util.HexRegionKey(c.codec.EncodeRegionKey(key)))
+					redact.RegionKey(key), err, redact.RegionKey(c.codec.EncodeRegionKey(key)))
 			}
-			backoffErr = errors.Errorf("loadRegion from PD failed, key: %q, err: %v", util.HexRegionKeyStr(key), err)
+			if backoffErr != nil {
+				// PD fails again after the retry.
+				if reg := c.fallbackRegion(key, isEndKey); reg != nil {
+					return newRegion(bo, c, reg)
+				}
+			}
+			backoffErr = errors.Errorf("loadRegion from PD failed, key: %q, err: %v", redact.RegionKey(key), err)
 			continue
 		}
+		c.onRoutingRecovered()
 		if reg == nil || reg.Meta == nil {
-			backoffErr = errors.Errorf("region not found for key %q, encode_key: %q", util.HexRegionKeyStr(key), util.HexRegionKey(c.codec.EncodeRegionKey(key)))
+			backoffErr = errors.Errorf("region not found for key %q, encode_key: %q", redact.RegionKey(key), redact.RegionKey(c.codec.EncodeRegionKey(key)))
 			continue
 		}
 		if len(reg.Meta.Peers) == 0 {
@@ -2140,7 +2235,7 @@ func (c *RegionCache) loadRegionByID(bo *retry.Backoffer, regionID uint64) (*Reg
 			}
 		}
 		start := time.Now()
-		reg, err := c.pdClient.GetRegionByID(withPDCircuitBreaker(ctx), regionID, opt.WithBuckets())
+		reg, err := c.pdClient.GetRegionByID(WithPDCircuitBreaker(ctx), regionID, opt.WithBuckets())
 		metrics.LoadRegionCacheHistogramWithRegionByID.Observe(time.Since(start).Seconds())
 		if err != nil {
 			metrics.RegionCacheCounterWithGetRegionByIDError.Inc()
@@ -2151,9 +2246,16 @@ func (c *RegionCache) loadRegionByID(bo *retry.Backoffer, regionID uint64) (*Reg
 			if apicodec.IsDecodeError(err) {
 				return nil, errors.Errorf("failed to decode region range key, regionID: %q, err: %v", regionID, err)
 			}
+			if backoffErr != nil {
+				// PD fails again after the retry.
+				if reg := c.fallbackRegionByID(regionID); reg != nil {
+					return newRegion(bo, c, reg)
+				}
+			}
 			backoffErr = errors.Errorf("loadRegion from PD failed, regionID: %v, err: %v", regionID, err)
 			continue
 		}
+		c.onRoutingRecovered()
 		if reg == nil || reg.Meta == nil {
 			return nil, errors.Errorf("region not found for regionID %d", regionID)
 		}
@@ -2220,7 +2322,7 @@ func (c *RegionCache) scanRegions(bo *retry.Backoffer, startKey, endKey []byte,
 		}
 		start := time.Now()
 		//nolint:staticcheck
-		regionsInfo, err := c.pdClient.ScanRegions(withPDCircuitBreaker(ctx), startKey, endKey, limit, opt.WithAllowFollowerHandle())
+		regionsInfo, err := c.pdClient.ScanRegions(WithPDCircuitBreaker(ctx), startKey, endKey, limit, opt.WithAllowFollowerHandle())
 		metrics.LoadRegionCacheHistogramWithRegions.Observe(time.Since(start).Seconds())
 		if err != nil {
 			if apicodec.IsDecodeError(err) {
@@ -2275,7 +2377,7 @@ func (c *RegionCache) batchScanRegions(bo *retry.Backoffer, keyRanges []router.K
 	for _, op := range opts {
 		op(&batchOpt)
 	}
-	// TODO: return start key and end key after redact is introduced.
+	startKey, endKey := redact.RegionKey(keyRanges[0].StartKey), redact.RegionKey(keyRanges[len(keyRanges)-1].EndKey)
 	var backoffErr error
 	for {
 		if backoffErr != nil {
@@ -2289,19 +2391,21 @@ func (c *RegionCache) batchScanRegions(bo *retry.Backoffer, keyRanges []router.K
 		if batchOpt.needBuckets {
 			pdOpts = append(pdOpts, opt.WithBuckets())
 		}
-		regionsInfo, err := c.pdClient.BatchScanRegions(withPDCircuitBreaker(ctx), keyRanges, limit, pdOpts...)
+		regionsInfo, err := c.pdClient.BatchScanRegions(WithPDCircuitBreaker(ctx), keyRanges, limit, pdOpts...)
 		metrics.LoadRegionCacheHistogramWithBatchScanRegions.Observe(time.Since(start).Seconds())
 		if err != nil {
 			if st, ok := status.FromError(err); ok && st.Code() == codes.Unimplemented {
 				return c.batchScanRegionsFallback(bo, keyRanges, limit, opts...)
 			}
 			if apicodec.IsDecodeError(err) {
-				return nil, errors.Errorf("failed to decode region range key, range num: %d, limit: %d, err: %v",
-					len(keyRanges), limit, err)
+				return nil, errors.Errorf("failed to decode region range key, start_key: %q, end_key: %q, range num: %d, limit: %d, err: %v",
+					startKey, endKey, len(keyRanges), limit, err)
 			}
 			metrics.RegionCacheCounterWithBatchScanRegionsError.Inc()
 			backoffErr = errors.Errorf(
-				"batchScanRegion from PD failed, range num: %d, limit: %d, err: %v",
+				"batchScanRegion from PD failed, start_key: %q, end_key: %q, range num: %d, limit: %d, err: %v",
+				startKey,
+				endKey,
 				len(keyRanges),
 				limit,
 				err)
@@ -2311,15 +2415,15 @@ func (c *RegionCache) batchScanRegions(bo *retry.Backoffer, keyRanges []router.K
 		metrics.RegionCacheCounterWithBatchScanRegionsOK.Inc()
 		if len(regionsInfo) == 0 {
 			backoffErr = errors.Errorf(
-				"PD returned no region, range num: %d, limit: %d",
-				len(keyRanges), limit,
+				"PD returned no region, start_key: %q, end_key: %q, range num: %d, limit: %d",
+				startKey, endKey, len(keyRanges), limit,
 			)
 			continue
 		}
 		if regionsHaveGapInRanges(keyRanges, regionsInfo, limit) {
 			backoffErr = errors.Errorf(
-				"PD returned regions have gaps, range num: %d, limit: %d",
-				len(keyRanges), limit,
+				"PD returned regions have gaps, start_key: %q, end_key: %q, range num: %d, limit: %d",
+				startKey, endKey, len(keyRanges), limit,
 			)
 			continue
 		}
@@ -2593,11 +2697,24 @@ func (c *RegionCache) OnRegionEpochNotMatch(bo *retry.Backoffer, ctx *RPCContext
 	newRegions := make([]*Region, 0, len(currentRegions))
 	// If the region epoch is not ahead of TiKV's, replace region meta in region cache.
 	for _, meta := range currentRegions {
+		verID := NewRegionVerID(meta.GetId(), meta.GetRegionEpoch().GetConfVer(), meta.GetRegionEpoch().GetVersion())
+		// During a split storm, the concurrent requests to the old region get the same current regions. Skip the
+		// ones already cached by the others, replacing them would invalidate the cached ones and make the requests
+		// using them reload the regions from PD.
+		if c.hasValidCachedRegion(verID) {
+			if ctx.Region == verID {
+				needInvalidateOld = false
+			}
+			continue
+		}
 		// TODO(youjiali1995): new regions inherit old region's buckets now. Maybe we should make EpochNotMatch error
 		// carry buckets information. Can it bring much overhead?
 		region, err := newRegion(bo, c, &router.Region{Meta: meta, Buckets: buckets})
 		if err != nil {
-			return false, err
+			// The region is loaded from PD lazily when it's accessed.
+			logutil.Logger(bo.GetCtx()).Info("failed to update region from EpochNotMatch",
+				zap.Uint64("region", meta.GetId()), zap.Error(err))
+			continue
 		}
 		var initLeaderStoreID uint64
 		if ctx.Store.storeType == tikvrpc.TiFlash {
@@ -2617,13 +2734,26 @@ func (c *RegionCache) OnRegionEpochNotMatch(bo *retry.Backoffer, ctx *RPCContext
 
 	c.mu.Lock()
 	for _, region := range newRegions {
-		c.insertRegionToCache(region, true, true)
+		if c.insertRegionToCache(region, true, true) {
+			metrics.RegionCacheCounterWithUpdateFromEpochNotMatchOK.Inc()
+		}
 	}
 	c.mu.Unlock()
 
 	return false, nil
 }
 
+// hasValidCachedRegion returns whether the region of verID is the latest version cached and still valid.
+func (c *RegionCache) hasValidCachedRegion(verID RegionVerID) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.mu.latestVersions[verID.id] != verID {
+		return false
+	}
+	r, ok := c.mu.regions[verID]
+	return ok && r.isValid()
+}
+
 // PDClient returns the pd.Client in RegionCache.
 func (c *RegionCache) PDClient() pd.Client {
 	return c.pdClient
@@ -3137,3 +3267,19 @@ type regionCacheClientEventListener struct {
 func (l *regionCacheClientEventListener) OnHealthFeedback(feedback *kvrpcpb.HealthFeedback) {
 	l.c.onHealthFeedback(feedback)
 }
+
+// OnConnTransientFailure implements the `client.ConnStateListener` interface.
+func (l *regionCacheClientEventListener) OnConnTransientFailure(addr string) {
+	l.c.onConnTransientFailure(addr)
+}
+
+// onConnTransientFailure resolves the stores at the address again, instead of waiting for the requests to them to
+// fail, in case they have moved to other addresses.
+func (c *RegionCache) onConnTransientFailure(addr string) {
+	stores := c.stores.filter(nil, func(s *Store) bool {
+		return s.addr == addr && s.getResolveState() == resolved
+	})
+	for _, store := range stores {
+		c.stores.markStoreNeedCheck(store)
+	}
+}
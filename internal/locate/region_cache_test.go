@@ -41,6 +41,7 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"path/filepath"
 	"reflect"
 	"sync"
 	"sync/atomic"
@@ -57,6 +58,7 @@ import (
 	"github.com/stretchr/testify/suite"
 	"github.com/tikv/client-go/v2/config/retry"
 	"github.com/tikv/client-go/v2/internal/apicodec"
+	"github.com/tikv/client-go/v2/internal/client"
 	"github.com/tikv/client-go/v2/internal/mockstore/mocktikv"
 	"github.com/tikv/client-go/v2/kv"
 	"github.com/tikv/client-go/v2/oracle"
@@ -470,6 +472,40 @@ func (s *testRegionCacheSuite) TestResolveStateTransition() {
 	s.cluster.AddStore(storeMeta.GetId(), storeMeta.GetAddress(), storeMeta.GetLabels()...)
 }
 
+func (s *testRegionCacheSuite) TestReResolveOnConnTransientFailure() {
+	var mu sync.Mutex
+	var changed [][2]string
+	pdCli := &CodecPDClient{mocktikv.NewPDClient(s.cluster), apicodec.NewCodecV1(apicodec.ModeTxn)}
+	cache := NewRegionCache(pdCli, WithStoreAddrChangedCallback(func(oldAddr, newAddr string) {
+		mu.Lock()
+		defer mu.Unlock()
+		changed = append(changed, [2]string{oldAddr, newAddr})
+	}))
+	defer cache.Close()
+
+	store := cache.stores.getOrInsertDefault(s.store1)
+	oldAddr, err := store.initResolve(s.bo, cache.stores)
+	s.Nil(err)
+	newAddr := oldAddr + "0"
+	s.cluster.UpdateStoreAddr(s.store1, newAddr)
+
+	// A failure of the connections to other addresses doesn't affect the store.
+	listener := cache.GetClientEventListener().(client.ConnStateListener)
+	listener.OnConnTransientFailure(newAddr)
+	s.Equal(store.getResolveState(), resolved)
+
+	listener.OnConnTransientFailure(oldAddr)
+	s.Eventually(func() bool {
+		return store.getResolveState() == deleted
+	}, 3*time.Second, 10*time.Millisecond)
+	newStore := cache.stores.getOrInsertDefault(s.store1)
+	s.Equal(newStore.getResolveState(), resolved)
+	s.Equal(newStore.addr, newAddr)
+	mu.Lock()
+	s.Equal([][2]string{{oldAddr, newAddr}}, changed)
+	mu.Unlock()
+}
+
 func (s *testRegionCacheSuite) TestReturnRegionWithNoLeader() {
 	region := s.getRegion([]byte("x"))
 	NoLeaderRegion := &router.Region{
@@ -1313,6 +1349,47 @@ func (s *testRegionCacheSuite) TestRegionEpochAheadOfTiKV() {
 	s.Equal(bo.ErrorsNum(), 2)
 }
 
+func (s *testRegionCacheSuite) TestRegionEpochNotMatchUpdateInPlace() {
+	loc, err := s.cache.LocateKey(s.bo, []byte("a"))
+	s.Require().Nil(err)
+	// key range: ['' - 'm' - 'z']
+	region2 := s.cluster.AllocID()
+	newPeers := s.cluster.AllocIDs(2)
+	s.cluster.Split(s.region1, region2, []byte("m"), newPeers, newPeers[0])
+	var currentRegions []*metapb.Region
+	for _, id := range []uint64{s.region1, region2} {
+		r, err := s.cache.loadRegionByID(s.bo, id)
+		s.Require().Nil(err)
+		currentRegions = append(currentRegions, r.meta)
+	}
+	s.cluster.ResetStats()
+
+	// The concurrent requests to the old region get the same current regions.
+	ctx := &RPCContext{Region: loc.Region, Store: s.cache.stores.getOrInsertDefault(s.store1)}
+	retry, err := s.cache.OnRegionEpochNotMatch(s.bo, ctx, currentRegions)
+	s.False(retry)
+	s.Nil(err)
+	r1, r2 := s.getRegion([]byte("a")), s.getRegion([]byte("x"))
+	retry, err = s.cache.OnRegionEpochNotMatch(s.bo, ctx, currentRegions)
+	s.False(retry)
+	s.Nil(err)
+	// The regions cached by the first request are kept valid.
+	s.Same(r1, s.getRegion([]byte("a")))
+	s.Same(r2, s.getRegion([]byte("x")))
+	s.True(r1.isValid())
+	s.True(r2.isValid())
+	s.checkCache(2)
+
+	loc1, err := s.cache.LocateKey(s.bo, []byte("a"))
+	s.Nil(err)
+	s.Equal(s.region1, loc1.Region.GetID())
+	s.Equal([]byte("m"), loc1.EndKey)
+	loc2, err := s.cache.LocateKey(s.bo, []byte("x"))
+	s.Nil(err)
+	s.Equal(region2, loc2.Region.GetID())
+	s.Zero(s.cluster.Stats().RegionLookups())
+}
+
 func (s *testRegionCacheSuite) TestRegionEpochOnTiFlash() {
 	// add store3 as tiflash
 	store3 := s.cluster.AllocID()
@@ -2996,3 +3073,58 @@ func BenchmarkBatchLocateKeyRangesFromCache(t *testing.B) {
 	}
 	s.TearDownTest()
 }
+
+type unavailablePDClient struct {
+	pd.Client
+}
+
+func (c *unavailablePDClient) GetRegion(context.Context, []byte, ...opt.GetRegionOption) (*router.Region, error) {
+	return nil, errors.New("PD is unavailable")
+}
+
+func (c *unavailablePDClient) GetPrevRegion(context.Context, []byte, ...opt.GetRegionOption) (*router.Region, error) {
+	return nil, errors.New("PD is unavailable")
+}
+
+func (c *unavailablePDClient) GetRegionByID(context.Context, uint64, ...opt.GetRegionOption) (*router.Region, error) {
+	return nil, errors.New("PD is unavailable")
+}
+
+func (c *unavailablePDClient) GetStore(context.Context, uint64) (*metapb.Store, error) {
+	return nil, errors.New("PD is unavailable")
+}
+
+func (s *testRegionCacheSuite) TestRegionMapFallback() {
+	path := filepath.Join(s.T().TempDir(), "region_map.json")
+
+	loc, err := s.cache.LocateKey(s.bo, []byte("a"))
+	s.NoError(err)
+	ctx, err := s.cache.GetTiKVRPCContext(s.bo, loc.Region, kv.ReplicaReadLeader, 0)
+	s.NoError(err)
+	s.Equal(s.storeAddr(s.store1), ctx.Addr)
+	s.NoError(s.cache.SaveRegionMap(path))
+
+	cache := NewRegionCache(&unavailablePDClient{Client: s.cache.pdClient})
+	defer cache.Close()
+	s.NoError(cache.EnableRegionMapFallback(path, 0))
+	s.False(cache.IsRoutingDegraded())
+
+	loc, err = cache.LocateKey(s.bo, []byte("a"))
+	s.NoError(err)
+	s.Equal(s.region1, loc.Region.GetID())
+	s.True(cache.IsRoutingDegraded())
+	ctx, err = cache.GetTiKVRPCContext(s.bo, loc.Region, kv.ReplicaReadLeader, 0)
+	s.NoError(err)
+	s.Equal(s.storeAddr(s.store1), ctx.Addr)
+
+	// The regions missing in the map still fail.
+	_, err = cache.LocateRegionByID(retry.NewBackofferWithVars(context.Background(), 100, nil), s.region1+100)
+	s.Error(err)
+
+	// Routes by PD again after it recovers.
+	cache.pdClient = s.cache.pdClient
+	cache.InvalidateCachedRegion(loc.Region)
+	_, err = cache.LocateKey(s.bo, []byte("a"))
+	s.NoError(err)
+	s.False(cache.IsRoutingDegraded())
+}
@@ -0,0 +1,278 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/pd/client/clients/router"
+	"go.uber.org/zap"
+)
+
+// regionMap is a snapshot of the routing metadata of the region cache, i.e. the cached regions and the resolved
+// stores, which is saved to a file periodically and serves the routing while PD is unavailable, see
+// RegionCache.EnableRegionMapFallback.
+type regionMap struct {
+	SavedAt time.Time        `json:"saved_at"`
+	Stores  []*metapb.Store  `json:"stores"`
+	Regions []regionMapEntry `json:"regions"`
+
+	// storeIDs and regionIDs index the stores and the regions by their ids, Regions are sorted by their start keys.
+	storeIDs  map[uint64]*metapb.Store
+	regionIDs map[uint64]*regionMapEntry
+}
+
+type regionMapEntry struct {
+	Meta   *metapb.Region `json:"meta"`
+	Leader *metapb.Peer   `json:"leader,omitempty"`
+}
+
+// index sorts the regions and builds the indexes by ids.
+func (m *regionMap) index() {
+	sort.Slice(m.Regions, func(i, j int) bool {
+		return bytes.Compare(m.Regions[i].Meta.GetStartKey(), m.Regions[j].Meta.GetStartKey()) < 0
+	})
+	m.storeIDs = make(map[uint64]*metapb.Store, len(m.Stores))
+	for _, s := range m.Stores {
+		m.storeIDs[s.GetId()] = s
+	}
+	m.regionIDs = make(map[uint64]*regionMapEntry, len(m.Regions))
+	for i := range m.Regions {
+		m.regionIDs[m.Regions[i].Meta.GetId()] = &m.Regions[i]
+	}
+}
+
+// locate returns the region containing key, or the region ending with key if isEndKey is set, nil if not found.
+func (m *regionMap) locate(key []byte, isEndKey bool) *router.Region {
+	var idx int
+	if isEndKey {
+		// The last region starting before key.
+		idx = sort.Search(len(m.Regions), func(i int) bool {
+			return bytes.Compare(m.Regions[i].Meta.GetStartKey(), key) >= 0
+		}) - 1
+		if len(key) == 0 {
+			idx = len(m.Regions) - 1
+		}
+	} else {
+		// The last region starting not after key.
+		idx = sort.Search(len(m.Regions), func(i int) bool {
+			return bytes.Compare(m.Regions[i].Meta.GetStartKey(), key) > 0
+		}) - 1
+	}
+	if idx < 0 {
+		return nil
+	}
+	e := &m.Regions[idx]
+	endKey := e.Meta.GetEndKey()
+	if len(endKey) > 0 {
+		if (isEndKey && (len(key) == 0 || bytes.Compare(key, endKey) > 0)) ||
+			(!isEndKey && bytes.Compare(key, endKey) >= 0) {
+			return nil
+		}
+	}
+	return e.toRouterRegion()
+}
+
+// region returns the region of the id, nil if not found.
+func (m *regionMap) region(id uint64) *router.Region {
+	e, ok := m.regionIDs[id]
+	if !ok {
+		return nil
+	}
+	return e.toRouterRegion()
+}
+
+// store returns the store of the id, nil if not found.
+func (m *regionMap) store(id uint64) *metapb.Store {
+	s, ok := m.storeIDs[id]
+	if !ok {
+		return nil
+	}
+	return proto.Clone(s).(*metapb.Store)
+}
+
+// toRouterRegion returns a copy of the region, since newRegion modifies the peers.
+func (e *regionMapEntry) toRouterRegion() *router.Region {
+	r := &router.Region{Meta: proto.Clone(e.Meta).(*metapb.Region)}
+	if e.Leader != nil {
+		r.Leader = proto.Clone(e.Leader).(*metapb.Peer)
+	}
+	return r
+}
+
+// SaveRegionMap saves the cached regions and the resolved stores to the file at path, which can be loaded by
+// EnableRegionMapFallback later, even by another process. The file is replaced atomically.
+func (c *RegionCache) SaveRegionMap(path string) error {
+	_, err := c.saveRegionMap(path)
+	return err
+}
+
+func (c *RegionCache) saveRegionMap(path string) (*regionMap, error) {
+	m := &regionMap{SavedAt: time.Now()}
+	c.stores.forEach(func(s *Store) {
+		if s.getResolveState() != resolved || s.addr == "" {
+			return
+		}
+		m.Stores = append(m.Stores, &metapb.Store{
+			Id:            s.storeID,
+			Address:       s.addr,
+			PeerAddress:   s.peerAddr,
+			StatusAddress: s.saddr,
+			Labels:        s.labels,
+			State:         metapb.StoreState_Up,
+		})
+	})
+	c.mu.RLock()
+	for _, ver := range c.mu.latestVersions {
+		r, ok := c.mu.regions[ver]
+		if !ok {
+			continue
+		}
+		e := regionMapEntry{Meta: r.meta}
+		leaderID := r.GetLeaderPeerID()
+		for _, p := range r.meta.GetPeers() {
+			if p.GetId() == leaderID {
+				e.Leader = p
+			}
+		}
+		m.Regions = append(m.Regions, e)
+	}
+	c.mu.RUnlock()
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return nil, errors.WithStack(err)
+	}
+	if err = tmp.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	m.index()
+	return m, nil
+}
+
+// loadRegionMap loads the region map saved by SaveRegionMap.
+func loadRegionMap(path string) (*regionMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var m regionMap
+	if err = json.Unmarshal(data, &m); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	m.index()
+	return &m, nil
+}
+
+// EnableRegionMapFallback makes the region cache route the requests by the region map saved at path while PD is
+// unavailable, i.e. the regions and the stores which fail to be loaded from PD after a retry are loaded from the region
+// map instead, so the requests can still be served by the stale routing until PD recovers, and the requests reaching
+// the moved regions are corrected by the region errors as usual. The region map is loaded from path if it exists, e.g.
+// saved by a previous process, and saved to path every saveInterval afterward. A non-positive saveInterval disables
+// the saving.
+func (c *RegionCache) EnableRegionMapFallback(path string, saveInterval time.Duration) error {
+	m, err := loadRegionMap(path)
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return err
+	}
+	if m != nil {
+		c.regionMap.Store(m)
+	}
+	c.bg.schedule(func(_ context.Context, _ time.Time) bool {
+		c.mu.RLock()
+		empty := len(c.mu.latestVersions) == 0
+		c.mu.RUnlock()
+		// Don't overwrite the map by the routing loaded from the map itself, or by an empty cache, e.g. right after
+		// the process starts.
+		if c.routingDegraded.Load() || empty {
+			return false
+		}
+		m, err := c.saveRegionMap(path)
+		if err != nil {
+			logutil.BgLogger().Warn("failed to save region map", zap.String("path", path), zap.Error(err))
+			return false
+		}
+		c.regionMap.Store(m)
+		return false
+	}, saveInterval)
+	return nil
+}
+
+// fallbackRegion returns the region containing key from the region map when PD is unavailable, nil if not found.
+func (c *RegionCache) fallbackRegion(key []byte, isEndKey bool) *router.Region {
+	m := c.regionMap.Load()
+	if m == nil {
+		return nil
+	}
+	r := m.locate(key, isEndKey)
+	if r != nil {
+		c.onRoutingDegraded(m)
+	}
+	return r
+}
+
+// fallbackRegionByID returns the region of the id from the region map when PD is unavailable, nil if not found.
+func (c *RegionCache) fallbackRegionByID(id uint64) *router.Region {
+	m := c.regionMap.Load()
+	if m == nil {
+		return nil
+	}
+	r := m.region(id)
+	if r != nil {
+		c.onRoutingDegraded(m)
+	}
+	return r
+}
+
+func (c *RegionCache) onRoutingDegraded(m *regionMap) {
+	if c.routingDegraded.CompareAndSwap(false, true) {
+		logutil.BgLogger().Warn("PD is unavailable, route the requests by the region map",
+			zap.Time("savedAt", m.SavedAt), zap.Int("regions", len(m.Regions)))
+	}
+}
+
+func (c *RegionCache) onRoutingRecovered() {
+	if c.routingDegraded.CompareAndSwap(true, false) {
+		logutil.BgLogger().Info("PD recovers, route the requests by PD")
+	}
+}
+
+// IsRoutingDegraded reports whether the region cache is routing the requests by the region map since PD is
+// unavailable, see EnableRegionMapFallback.
+func (c *RegionCache) IsRoutingDegraded() bool {
+	return c.routingDegraded.Load()
+}
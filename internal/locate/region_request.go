@@ -50,6 +50,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"github.com/gogo/protobuf/proto"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pingcap/kvproto/pkg/coprocessor"
 	"github.com/pingcap/kvproto/pkg/errorpb"
@@ -64,6 +65,8 @@ import (
 	"github.com/tikv/client-go/v2/metrics"
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/util"
+	"github.com/tikv/client-go/v2/util/redact"
+	"github.com/tikv/client-go/v2/util/slowlog"
 	"github.com/tikv/pd/client/errs"
 	pderr "github.com/tikv/pd/client/errs"
 )
@@ -116,6 +119,9 @@ type RegionRequestSender struct {
 	failProxyStoreIDs map[uint64]struct{}
 	Stats             *RegionRequestRuntimeStats
 	AccessStats       *ReplicaAccessStats
+	// slowLogAttempts records the RPCs sent by SendReqCtx when the slow log is
+	// enabled.
+	slowLogAttempts []slowlog.Attempt
 }
 
 func (s *RegionRequestSender) String() string {
@@ -723,6 +729,7 @@ func (s *RegionRequestSender) reset() {
 	s.replicaSelector = nil
 	s.failStoreIDs = nil
 	s.failProxyStoreIDs = nil
+	s.slowLogAttempts = nil
 }
 
 // IsFakeRegionError returns true if err is fake region error.
@@ -757,7 +764,7 @@ func (s *RegionRequestSender) SendReqCtx(
 	}
 
 	if err = s.validateReadTS(bo.GetCtx(), req); err != nil {
-		logutil.Logger(bo.GetCtx()).Error("validate read ts failed for request", zap.Stringer("reqType", req.Type), zap.Stringer("req", req.Req.(fmt.Stringer)), zap.Stringer("context", &req.Context), zap.Stack("stack"), zap.Error(err))
+		logutil.Logger(bo.GetCtx()).Error("validate read ts failed for request", zap.Stringer("reqType", req.Type), zap.Stringer("req", redactReq(req)), zap.Stringer("context", &req.Context), zap.Stack("stack"), zap.Error(err))
 		return nil, nil, 0, err
 	}
 
@@ -777,6 +784,13 @@ func (s *RegionRequestSender) SendReqCtx(
 		}
 	}()
 
+	slowLogger := s.regionCache.getSlowLogger()
+	if slowLogger != nil {
+		defer func() {
+			s.emitSlowLog(slowLogger, bo, req, regionID, timeout, startTime, startBackOff, retryTimes, err)
+		}()
+	}
+
 	if req.StaleRead {
 		defer func() {
 			if retryTimes == 0 {
@@ -870,15 +884,27 @@ func (s *RegionRequestSender) SendReqCtx(
 			}
 		}
 
+		if err := s.regionCache.waitWritePacing(bo.GetCtx(), rpcCtx.Store, req); err != nil {
+			return nil, nil, retryTimes, err
+		}
+
 		var retry bool
+		sendStart := time.Now()
 		resp, retry, err = s.sendReqToRegion(bo, rpcCtx, req, timeout)
 		req.IsRetryRequest = true
+		if err == nil && !retry {
+			s.regionCache.onWriteResponse(rpcCtx.Store, req, resp)
+			s.regionCache.onRegionResponse(rpcCtx, req, resp)
+		}
+		if slowLogger != nil {
+			s.recordSlowLogAttempt(rpcCtx, time.Since(sendStart), resp, retry, err)
+		}
 		if err != nil {
 			if cost := time.Since(startTime); cost > slowLogSendReqTime || cost > timeout || bo.GetTotalSleep() > 1000 {
 				msg := fmt.Sprintf("send request failed, err: %v", err.Error())
 				s.logSendReqError(bo, msg, regionID, retryTimes, req, cost, bo.GetTotalSleep()-startBackOff, timeout)
 			}
-			return nil, nil, retryTimes, err
+			return nil, nil, retryTimes, tikverr.AnnotateDeadlineExceeded(bo.GetCtx(), err)
 		}
 
 		if _, err1 := util.EvalFailpoint("afterSendReqToRegion"); err1 == nil {
@@ -898,6 +924,7 @@ func (s *RegionRequestSender) SendReqCtx(
 			}
 		}
 		if retry {
+			s.switchReplicaIfRequested(bo)
 			retryTimes++
 			continue
 		}
@@ -908,6 +935,9 @@ func (s *RegionRequestSender) SendReqCtx(
 			return nil, nil, retryTimes, err
 		}
 		if regionErr != nil {
+			if s.replicaSelector != nil {
+				s.replicaSelector.recordAttempt(nil, regionErr)
+			}
 			retry, err = s.onRegionError(bo, rpcCtx, req, regionErr)
 			if err != nil {
 				if cost := time.Since(startTime); cost > slowLogSendReqTime || cost > timeout || bo.GetTotalSleep() > 1000 {
@@ -917,6 +947,7 @@ func (s *RegionRequestSender) SendReqCtx(
 				return nil, nil, retryTimes, err
 			}
 			if retry {
+				s.switchReplicaIfRequested(bo)
 				retryTimes++
 				continue
 			}
@@ -933,6 +964,59 @@ func (s *RegionRequestSender) SendReqCtx(
 	}
 }
 
+// switchReplicaIfRequested makes the next attempts avoid the current replica
+// if the retry policy decides so, see retry.DecisionSwitchReplica.
+func (s *RegionRequestSender) switchReplicaIfRequested(bo *retry.Backoffer) {
+	if !bo.TakeReplicaSwitch() || s.replicaSelector == nil || s.replicaSelector.target == nil {
+		return
+	}
+	s.replicaSelector.target.attempts = maxReplicaAttempt
+}
+
+func (s *RegionRequestSender) recordSlowLogAttempt(rpcCtx *RPCContext, cost time.Duration, resp *tikvrpc.Response, retry bool, err error) {
+	attempt := slowlog.Attempt{
+		Addr:   rpcCtx.Addr,
+		PeerID: rpcCtx.Peer.GetId(),
+		Cost:   cost,
+	}
+	if rpcCtx.Store != nil {
+		attempt.StoreID = rpcCtx.Store.storeID
+	}
+	if err != nil {
+		attempt.Err = err.Error()
+	} else if retry && s.rpcError != nil {
+		// The RPC failed and the request is retried.
+		attempt.Err = s.rpcError.Error()
+	} else if resp != nil {
+		if regionErr, _ := resp.GetRegionError(); regionErr != nil {
+			attempt.RegionError = regionErr.String()
+		}
+	}
+	s.slowLogAttempts = append(s.slowLogAttempts, attempt)
+}
+
+func (s *RegionRequestSender) emitSlowLog(l *slowlog.Logger, bo *retry.Backoffer, req *tikvrpc.Request, regionID RegionVerID, timeout time.Duration, startTime time.Time, startBackOff int, retryTimes int, err error) {
+	cost := time.Since(startTime)
+	if cost < l.Threshold() {
+		return
+	}
+	e := &slowlog.Entry{
+		Time:         startTime,
+		Type:         req.Type.String(),
+		RegionID:     regionID.GetID(),
+		Cost:         cost,
+		Timeout:      timeout,
+		RetryTimes:   retryTimes,
+		Backoff:      time.Duration(bo.GetTotalSleep()-startBackOff) * time.Millisecond,
+		BackoffTimes: maps.Clone(bo.GetBackoffTimes()),
+		Attempts:     s.slowLogAttempts,
+	}
+	if err != nil {
+		e.Err = err.Error()
+	}
+	l.Log(e)
+}
+
 func (s *RegionRequestSender) logSendReqError(bo *retry.Backoffer, msg string, regionID RegionVerID, retryTimes int, req *tikvrpc.Request, cost time.Duration, currentBackoffMs int, timeout time.Duration) {
 	var builder strings.Builder
 	// build the total round stats string.
@@ -1025,6 +1109,18 @@ func (h *RPCCanceller) CancelAll() {
 	h.Unlock()
 }
 
+// redactReq formats the body of the request for the logs, with the keys
+// redacted.
+func redactReq(req *tikvrpc.Request) fmt.Stringer {
+	if req == nil {
+		return redact.Message(nil)
+	}
+	if m, ok := req.Req.(proto.Message); ok {
+		return redact.Message(m)
+	}
+	return &req.Context
+}
+
 func fetchRespInfo(resp *tikvrpc.Response) string {
 	var extraInfo string
 	if resp == nil || resp.Resp == nil {
@@ -1104,7 +1200,7 @@ func (s *RegionRequestSender) sendReqToRegion(
 		if inject {
 			logutil.Logger(ctx).Info(
 				"[failpoint] injected RPC error on send", zap.Stringer("type", req.Type),
-				zap.Stringer("req", req.Req.(fmt.Stringer)), zap.Stringer("ctx", &req.Context),
+				zap.Stringer("req", redactReq(req)), zap.Stringer("ctx", &req.Context),
 			)
 			injectFailOnSend = true
 			err = errors.New("injected RPC error on send")
@@ -1115,6 +1211,7 @@ func (s *RegionRequestSender) sendReqToRegion(
 		start := time.Now()
 		resp, err = s.client.SendRequest(ctx, sendToAddr, req, timeout)
 		rpcDuration := time.Since(start)
+		util.DeadlineAuditFromContext(ctx).Add(util.DeadlineStageRPC, rpcDuration)
 		if s.replicaSelector != nil {
 			recordAttemptedTime(s.replicaSelector, rpcDuration)
 		}
@@ -1151,7 +1248,7 @@ func (s *RegionRequestSender) sendReqToRegion(
 			if inject {
 				logutil.Logger(ctx).Info(
 					"[failpoint] injected RPC error on recv", zap.Stringer("type", req.Type),
-					zap.Stringer("req", req.Req.(fmt.Stringer)), zap.Stringer("ctx", &req.Context),
+					zap.Stringer("req", redactReq(req)), zap.Stringer("ctx", &req.Context),
 					zap.Error(err), zap.String("extra response info", fetchRespInfo(resp)),
 				)
 				err = errors.New("injected RPC error on recv")
@@ -1532,7 +1629,7 @@ func (s *RegionRequestSender) onRegionError(
 	if flashbackInProgress := regionErr.GetFlashbackInProgress(); flashbackInProgress != nil {
 		logutil.Logger(bo.GetCtx()).Debug(
 			"tikv reports `FlashbackInProgress`",
-			zap.Stringer("req", req),
+			zap.Stringer("req", redactReq(req)),
 			zap.Stringer("ctx", ctx),
 		)
 		if req != nil && s.replicaSelector != nil && s.replicaSelector.onFlashbackInProgress(req) {
@@ -1549,7 +1646,7 @@ func (s *RegionRequestSender) onRegionError(
 	if regionErr.GetFlashbackNotPrepared() != nil {
 		logutil.Logger(bo.GetCtx()).Debug(
 			"tikv reports `FlashbackNotPrepared`",
-			zap.Stringer("req", req),
+			zap.Stringer("req", redactReq(req)),
 			zap.Stringer("ctx", ctx),
 		)
 		return false, errors.Errorf(
@@ -1566,7 +1663,7 @@ func (s *RegionRequestSender) onRegionError(
 	}
 
 	if regionErr.GetKeyNotInRegion() != nil {
-		logutil.Logger(bo.GetCtx()).Error("tikv reports `KeyNotInRegion`", zap.Stringer("req", req), zap.Stringer("ctx", ctx))
+		logutil.Logger(bo.GetCtx()).Error("tikv reports `KeyNotInRegion`", zap.Stringer("req", redactReq(req)), zap.Stringer("ctx", ctx))
 		s.regionCache.InvalidateCachedRegion(ctx.Region)
 		return false, nil
 	}
@@ -1747,7 +1844,7 @@ func (s *RegionRequestSender) onRegionError(
 		logutil.Logger(bo.GetCtx()).Error(
 			"tikv reports `InvalidMaxTsUpdate`",
 			zap.String("message", regionErr.GetMessage()),
-			zap.Stringer("req", req),
+			zap.Stringer("req", redactReq(req)),
 			zap.Stringer("ctx", ctx),
 		)
 		return false, errors.New(regionErr.String())
@@ -60,6 +60,7 @@ import (
 	"github.com/stretchr/testify/suite"
 	"github.com/tikv/client-go/v2/config"
 	"github.com/tikv/client-go/v2/config/retry"
+	tikverr "github.com/tikv/client-go/v2/error"
 	"github.com/tikv/client-go/v2/internal/apicodec"
 	"github.com/tikv/client-go/v2/internal/client"
 	"github.com/tikv/client-go/v2/internal/client/mockserver"
@@ -67,6 +68,8 @@ import (
 	"github.com/tikv/client-go/v2/oracle"
 	"github.com/tikv/client-go/v2/oracle/oracles"
 	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/util"
+	"github.com/tikv/client-go/v2/util/slowlog"
 	pd "github.com/tikv/pd/client"
 	pderr "github.com/tikv/pd/client/errs"
 	"google.golang.org/grpc"
@@ -166,6 +169,111 @@ func (s *testRegionRequestToSingleStoreSuite) TestOnRegionError() {
 	}()
 }
 
+func (s *testRegionRequestToSingleStoreSuite) TestOnRegionMergedInFlight() {
+	region2, peer2 := s.cluster.AllocID(), s.cluster.AllocID()
+	s.cluster.Split(s.region, region2, []byte("m"), []uint64{peer2}, peer2)
+	loc1, err := s.cache.LocateKey(s.bo, []byte("a"))
+	s.Nil(err)
+	loc2, err := s.cache.LocateKey(s.bo, []byte("n"))
+	s.Nil(err)
+	s.Equal(region2, loc2.Region.GetID())
+
+	// The region is merged when the request to it arrives.
+	s.cluster.ScheduleMerge(s.region, region2, 0)
+	req := tikvrpc.NewRequest(tikvrpc.CmdRawGet, &kvrpcpb.RawGetRequest{Key: []byte("n")})
+	resp, _, err := s.regionRequestSender.SendReq(s.bo, req, loc2.Region, time.Second)
+	s.Nil(err)
+	regionErr, err := resp.GetRegionError()
+	s.Nil(err)
+	s.NotNil(regionErr.GetEpochNotMatch())
+
+	// The region cache is updated by the regions attached to the error without accessing PD.
+	s.Nil(s.cache.GetCachedRegionWithRLock(loc2.Region))
+	loc, err := s.cache.LocateKey(retry.NewNoopBackoff(context.Background()), []byte("n"))
+	s.Nil(err)
+	s.Equal(s.region, loc.Region.GetID())
+	s.Empty(loc.EndKey)
+	s.Greater(loc.Region.GetVer(), loc1.Region.GetVer())
+
+	resp, _, err = s.regionRequestSender.SendReq(s.bo, req, loc.Region, time.Second)
+	s.Nil(err)
+	regionErr, err = resp.GetRegionError()
+	s.Nil(err)
+	s.Nil(regionErr)
+}
+
+func (s *testRegionRequestToSingleStoreSuite) TestDeadlineAudit() {
+	s.regionRequestSender.client = &fnClient{fn: func(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+	region, err := s.cache.LocateRegionByID(s.bo, s.region)
+	s.Nil(err)
+	ctx, cancel := context.WithTimeout(util.ContextWithDeadlineAudit(context.Background()), 20*time.Millisecond)
+	defer cancel()
+	req := tikvrpc.NewRequest(tikvrpc.CmdRawGet, &kvrpcpb.RawGetRequest{Key: []byte("key")})
+	_, _, err = s.regionRequestSender.SendReq(retry.NewBackofferWithVars(ctx, 1000, nil), req, region.Region, time.Second)
+	var deadlineErr *tikverr.ErrDeadlineExceeded
+	s.Require().ErrorAs(err, &deadlineErr)
+	s.ErrorIs(err, context.DeadlineExceeded)
+	s.GreaterOrEqual(deadlineErr.Breakdown.RPC, 20*time.Millisecond)
+	s.Zero(deadlineErr.Breakdown.Backoff)
+}
+
+func (s *testRegionRequestToSingleStoreSuite) TestSlowLog() {
+	req := tikvrpc.NewRequest(tikvrpc.CmdRawPut, &kvrpcpb.RawPutRequest{
+		Key:   []byte("key"),
+		Value: []byte("value"),
+	})
+	region, err := s.cache.LocateRegionByID(s.bo, s.region)
+	s.Nil(err)
+	s.NotNil(region)
+
+	var entries []*slowlog.Entry
+	s.cache.SetSlowLogger(slowlog.New(0, slowlog.SinkFunc(func(e *slowlog.Entry) {
+		entries = append(entries, e)
+	})))
+	defer s.cache.SetSlowLogger(nil)
+
+	oc := s.regionRequestSender.client
+	defer func() {
+		s.regionRequestSender.client = oc
+	}()
+	calls := 0
+	s.regionRequestSender.client = &fnClient{fn: func(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+		calls++
+		if calls == 1 {
+			return &tikvrpc.Response{Resp: &kvrpcpb.RawPutResponse{
+				RegionError: &errorpb.Error{StaleCommand: &errorpb.StaleCommand{}},
+			}}, nil
+		}
+		return &tikvrpc.Response{Resp: &kvrpcpb.RawPutResponse{}}, nil
+	}}
+	bo := retry.NewBackofferWithVars(context.Background(), 10000, nil)
+	resp, _, err := s.regionRequestSender.SendReq(bo, req, region.Region, time.Second)
+	s.Nil(err)
+	s.NotNil(resp)
+
+	s.Len(entries, 1)
+	e := entries[0]
+	s.Equal(tikvrpc.CmdRawPut.String(), e.Type)
+	s.Equal(s.region, e.RegionID)
+	s.Equal(1, e.RetryTimes)
+	s.Len(e.Attempts, 2)
+	s.Equal(s.store, e.Attempts[0].StoreID)
+	s.Contains(e.Attempts[0].RegionError, "stale_command")
+	s.Empty(e.Attempts[1].RegionError)
+	s.Empty(e.Err)
+
+	// Requests faster than the threshold are not logged.
+	s.cache.SetSlowLogger(slowlog.New(time.Hour, slowlog.SinkFunc(func(e *slowlog.Entry) {
+		entries = append(entries, e)
+	})))
+	_, _, err = s.regionRequestSender.SendReq(bo, req, region.Region, time.Second)
+	s.Nil(err)
+	s.Len(entries, 1)
+}
+
 func (s *testRegionRequestToSingleStoreSuite) TestOnSendFailByResourceGroupThrottled() {
 	req := tikvrpc.NewRequest(tikvrpc.CmdRawPut, &kvrpcpb.RawPutRequest{
 		Key:   []byte("key"),
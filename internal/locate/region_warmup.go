@@ -0,0 +1,127 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"sync/atomic"
+
+	"github.com/tikv/client-go/v2/config/retry"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	warmUpMaxBackoff   = 20000
+	prefetchMaxBackoff = 5000
+)
+
+// WarmUp loads the regions in [startKey, endKey) from PD into the cache, so
+// that the first requests to them don't pay for the cache misses. The range is
+// split into at most concurrency parts, which are scanned concurrently. It
+// returns the number of the regions loaded, where the regions across the
+// borders of the parts are counted more than once.
+func (c *RegionCache) WarmUp(ctx context.Context, startKey, endKey []byte, concurrency int) (int, error) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	var loaded atomic.Int64
+	g, gctx := errgroup.WithContext(ctx)
+	for _, r := range splitKeyRange(startKey, endKey, concurrency) {
+		r := r
+		g.Go(func() error {
+			bo := retry.NewBackofferWithVars(gctx, warmUpMaxBackoff, nil)
+			regions, err := c.LoadRegionsInKeyRange(bo, r[0], r[1])
+			loaded.Add(int64(len(regions)))
+			return err
+		})
+	}
+	err := g.Wait()
+	return int(loaded.Load()), err
+}
+
+// splitKeyRange splits [startKey, endKey) into at most n parts by
+// interpolating the 8 bytes after the common prefix of the keys as numbers.
+// The parts are even if the keys are distributed evenly, e.g. the keys with
+// random suffixes.
+func splitKeyRange(startKey, endKey []byte, n int) [][2][]byte {
+	prefixLen := 0
+	if len(endKey) > 0 {
+		for prefixLen < len(startKey) && prefixLen < len(endKey) && startKey[prefixLen] == endKey[prefixLen] {
+			prefixLen++
+		}
+	}
+	toNum := func(key []byte) uint64 {
+		var buf [8]byte
+		if len(key) > prefixLen {
+			copy(buf[:], key[prefixLen:])
+		}
+		return binary.BigEndian.Uint64(buf[:])
+	}
+	start, end := toNum(startKey), uint64(math.MaxUint64)
+	if len(endKey) > 0 {
+		end = toNum(endKey)
+	}
+	if n <= 1 || end <= start || end-start < uint64(n) {
+		return [][2][]byte{{startKey, endKey}}
+	}
+
+	step := (end - start) / uint64(n)
+	ranges := make([][2][]byte, 0, n)
+	lower := startKey
+	for i := 1; i < n; i++ {
+		upper := make([]byte, prefixLen+8)
+		copy(upper, startKey[:prefixLen])
+		binary.BigEndian.PutUint64(upper[prefixLen:], start+uint64(i)*step)
+		ranges = append(ranges, [2][]byte{lower, upper})
+		lower = upper
+	}
+	return append(ranges, [2][]byte{lower, endKey})
+}
+
+// SetScanPrefetch makes the scans load the next n regions in the background
+// when they move to a region whose next region isn't cached. Zero disables the
+// prefetch.
+func (c *RegionCache) SetScanPrefetch(n int) {
+	c.scanPrefetch.Store(int64(n))
+}
+
+// PrefetchForScan is called by a forward scan over [loc.StartKey, endKey) when
+// it locates loc, which loads the regions after loc in the background if they
+// aren't cached, see SetScanPrefetch. Only one prefetch runs at a time.
+func (c *RegionCache) PrefetchForScan(loc *KeyLocation, endKey []byte) {
+	n := int(c.scanPrefetch.Load())
+	if n <= 0 || len(loc.EndKey) == 0 || (len(endKey) > 0 && bytes.Compare(loc.EndKey, endKey) >= 0) {
+		return
+	}
+	if c.tryFindRegionByKey(loc.EndKey, false) != nil {
+		return
+	}
+	if !c.prefetching.CompareAndSwap(false, true) {
+		return
+	}
+	startKey := loc.EndKey
+	c.bg.run(func(ctx context.Context) {
+		defer c.prefetching.Store(false)
+		bo := retry.NewBackofferWithVars(ctx, prefetchMaxBackoff, nil)
+		if _, err := c.BatchLoadRegionsWithKeyRange(bo, startKey, endKey, n); err != nil {
+			logutil.BgLogger().Debug("failed to prefetch regions for scan", zap.Error(err))
+		}
+	})
+}
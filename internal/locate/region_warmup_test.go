@@ -0,0 +1,83 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/internal/apicodec"
+	"github.com/tikv/client-go/v2/internal/mockstore/mocktikv"
+)
+
+func TestSplitKeyRange(t *testing.T) {
+	check := func(start, end []byte, n, expected int) {
+		ranges := splitKeyRange(start, end, n)
+		require.Len(t, ranges, expected)
+		require.Equal(t, start, ranges[0][0])
+		require.Equal(t, end, ranges[len(ranges)-1][1])
+		for i, r := range ranges {
+			require.True(t, len(r[1]) == 0 || bytes.Compare(r[0], r[1]) < 0, "%q", r)
+			if i > 0 {
+				require.Equal(t, ranges[i-1][1], r[0])
+			}
+		}
+	}
+	check(nil, nil, 4, 4)
+	check([]byte("t_a"), []byte("t_z"), 8, 8)
+	check([]byte("t_a"), []byte("t_a\x00"), 8, 1)
+	check([]byte("t_a"), []byte("t_b"), 1, 1)
+}
+
+func TestWarmUp(t *testing.T) {
+	mvccStore := mocktikv.MustNewMVCCStore()
+	defer mvccStore.Close()
+	cluster := mocktikv.NewCluster(mvccStore)
+	_, _, regionID, _ := mocktikv.BootstrapWithMultiStores(cluster, 1)
+	var splitKeys [][]byte
+	for i := 1; i < 20; i++ {
+		key := []byte(fmt.Sprintf("k%02d", i))
+		newRegionID, peerID := cluster.AllocID(), cluster.AllocID()
+		cluster.Split(regionID, newRegionID, key, []uint64{peerID}, peerID)
+		regionID = newRegionID
+		splitKeys = append(splitKeys, key)
+	}
+	cache := NewRegionCache(&CodecPDClient{mocktikv.NewPDClient(cluster), apicodec.NewCodecV1(apicodec.ModeTxn)})
+	defer cache.Close()
+
+	loaded, err := cache.WarmUp(context.Background(), nil, nil, 4)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, loaded, 20)
+	for _, key := range splitKeys {
+		require.NotNil(t, cache.tryFindRegionByKey(key, false))
+	}
+
+	// The scan prefetches the regions after the located one.
+	cache2 := NewRegionCache(&CodecPDClient{mocktikv.NewPDClient(cluster), apicodec.NewCodecV1(apicodec.ModeTxn)})
+	defer cache2.Close()
+	loc := &KeyLocation{StartKey: []byte("k01"), EndKey: []byte("k02")}
+	cache2.PrefetchForScan(loc, nil)
+	require.Nil(t, cache2.tryFindRegionByKey([]byte("k02"), false))
+	cache2.SetScanPrefetch(5)
+	cache2.PrefetchForScan(loc, nil)
+	require.Eventually(t, func() bool {
+		return cache2.tryFindRegionByKey([]byte("k06"), false) != nil
+	}, time.Second, 10*time.Millisecond)
+	require.Nil(t, cache2.tryFindRegionByKey([]byte("k07"), false))
+}
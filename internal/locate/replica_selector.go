@@ -38,6 +38,9 @@ type replicaSelector struct {
 	target          *replica
 	proxy           *replica
 	attempts        int
+	// policy replaces the built-in selection if it's set, see ReplicaSelectorPolicy.
+	policy  ReplicaSelectorPolicy
+	history []ReplicaAttempt
 }
 
 func newReplicaSelector(
@@ -68,6 +71,7 @@ func newReplicaSelector(
 		option:          option,
 		target:          nil,
 		attempts:        0,
+		policy:          regionCache.getReplicaSelectorPolicy(),
 	}, nil
 }
 
@@ -106,8 +110,10 @@ func (s *replicaSelector) next(bo *retry.Backoffer, req *tikvrpc.Request) (rpcCt
 	s.attempts++
 	s.target = nil
 	s.proxy = nil
-	switch s.replicaReadType {
-	case kv.ReplicaReadLeader:
+	switch {
+	case s.policy != nil:
+		s.nextByPolicy(req)
+	case s.replicaReadType == kv.ReplicaReadLeader:
 		s.nextForReplicaReadLeader(req)
 	default:
 		s.nextForReplicaReadMixed(req)
@@ -181,6 +187,18 @@ func (s *replicaSelector) nextForReplicaReadMixed(req *tikvrpc.Request) {
 		stores:       s.option.stores,
 	}
 	s.target = strategy.next(s)
+	if s.target != nil && s.isStaleRead && s.target.peer.Id != s.region.GetLeaderPeerID() && s.target.store.isStaleReadLagging() {
+		// The store keeps returning DataIsNotReady recently, read from the leader rather
+		// than retrying the lagging follower.
+		leader := ReplicaSelectLeaderStrategy{leaderIdx: leaderIdx}.next(s.replicas)
+		if leader != nil && !leader.isExhausted(1, 0) {
+			metrics.StaleReadLeaderFallbackCounter.Inc()
+			s.target = leader
+			req.StaleRead = false
+			req.ReplicaRead = false
+			return
+		}
+	}
 	if s.target != nil {
 		if s.isStaleRead {
 			isStaleRead := true
@@ -514,6 +532,7 @@ func (s *replicaSelector) onFlashbackInProgress(req *tikvrpc.Request) bool {
 func (s *replicaSelector) onDataIsNotReady() {
 	if s.target != nil {
 		s.target.addFlag(dataIsNotReadyFlag)
+		s.target.store.recordStaleReadNotReady()
 	}
 }
 
@@ -597,6 +616,7 @@ func isReadReq(tp tikvrpc.CmdType) bool {
 
 func (s *replicaSelector) onSendFailure(bo *retry.Backoffer, err error) {
 	metrics.RegionCacheCounterWithSendFail.Inc()
+	s.recordAttempt(err, nil)
 	// todo: mark store need check and return to fast retry.
 	target := s.target
 	if s.proxy != nil {
@@ -614,6 +634,9 @@ func (s *replicaSelector) onSendFailure(bo *retry.Backoffer, err error) {
 }
 
 func (s *replicaSelector) onSendSuccess(req *tikvrpc.Request) {
+	if s.target != nil && req != nil && req.StaleRead {
+		s.target.store.recordStaleReadSuccess()
+	}
 	if s.proxy != nil && s.target != nil {
 		for idx, r := range s.replicas {
 			if r.peer.Id == s.proxy.peer.Id {
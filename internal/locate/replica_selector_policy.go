@@ -0,0 +1,127 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/errorpb"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+// ReplicaSelectorPolicy replaces the built-in selection of the replicas the requests to TiKV are sent to.
+type ReplicaSelectorPolicy interface {
+	// Next returns the index in replicas of the replica to send req to, attempts are the outcomes of the previous
+	// attempts of req to the region, in order. Returning a negative index gives up the region, then req is retried
+	// after the region is reloaded, until the backoffer is exhausted. The policy decides the retries on the errors,
+	// but a region gives up anyway after each of its replicas is attempted maxReplicaAttempt times on average.
+	Next(req *tikvrpc.Request, replicas []ReplicaStatus, attempts []ReplicaAttempt) int
+}
+
+// ReplicaStatus is the status of a replica of the region, see ReplicaSelectorPolicy.
+type ReplicaStatus struct {
+	StoreID  uint64
+	PeerID   uint64
+	Addr     string
+	Labels   []*metapb.StoreLabel
+	Role     metapb.PeerRole
+	IsLeader bool
+	// Reachable reports whether the store is reachable by the health check.
+	Reachable bool
+	// Slow reports whether the store is considered slow by its slow score.
+	Slow bool
+	// EstimatedWaitTime is the time the requests are estimated to wait in the store.
+	EstimatedWaitTime time.Duration
+	// Attempts is the number of times the request is sent to the replica.
+	Attempts int
+}
+
+// ReplicaAttempt is the outcome of sending a request to a replica, see ReplicaSelectorPolicy.
+type ReplicaAttempt struct {
+	StoreID uint64
+	PeerID  uint64
+	// Err is the error sending the request, nil if TiKV responded.
+	Err error
+	// RegionError is the region error TiKV responded.
+	RegionError *errorpb.Error
+}
+
+// SetReplicaSelectorPolicy sets the policy selecting the replicas of the requests sent through the region cache.
+// Pass nil to use the built-in selection.
+func (c *RegionCache) SetReplicaSelectorPolicy(policy ReplicaSelectorPolicy) {
+	if policy == nil {
+		c.replicaSelectorPolicy.Store(nil)
+		return
+	}
+	c.replicaSelectorPolicy.Store(&policy)
+}
+
+func (c *RegionCache) getReplicaSelectorPolicy() ReplicaSelectorPolicy {
+	if p := c.replicaSelectorPolicy.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// nextByPolicy selects the target replica by the custom policy.
+func (s *replicaSelector) nextByPolicy(req *tikvrpc.Request) {
+	if s.attempts > maxReplicaAttempt*len(s.replicas) {
+		return
+	}
+	leaderID := s.region.GetLeaderPeerID()
+	replicas := make([]ReplicaStatus, 0, len(s.replicas))
+	for _, r := range s.replicas {
+		replicas = append(replicas, ReplicaStatus{
+			StoreID:           r.store.storeID,
+			PeerID:            r.peer.Id,
+			Addr:              r.store.GetAddr(),
+			Labels:            r.store.labels,
+			Role:              r.peer.Role,
+			IsLeader:          r.peer.Id == leaderID,
+			Reachable:         r.store.getLivenessState() == reachable,
+			Slow:              r.store.healthStatus.IsSlow(),
+			EstimatedWaitTime: r.store.EstimatedWaitTime(),
+			Attempts:          r.attempts,
+		})
+	}
+	idx := s.policy.Next(req, replicas, s.history)
+	if idx < 0 || idx >= len(s.replicas) {
+		return
+	}
+	s.target = s.replicas[idx]
+	if s.isStaleRead && s.attempts == 1 {
+		// Any replica can serve the stale read, fall back to the replica read or the leader read when retrying, like
+		// the built-in selection.
+		req.StaleRead = true
+		req.ReplicaRead = false
+	} else {
+		req.StaleRead = false
+		req.ReplicaRead = s.isReadOnlyReq && s.target.peer.Id != leaderID
+	}
+}
+
+// recordAttempt records the outcome of the attempt to the target replica for the custom policy.
+func (s *replicaSelector) recordAttempt(err error, regionErr *errorpb.Error) {
+	if s.policy == nil || s.target == nil {
+		return
+	}
+	s.history = append(s.history, ReplicaAttempt{
+		StoreID:     s.target.store.storeID,
+		PeerID:      s.target.peer.Id,
+		Err:         err,
+		RegionError: regionErr,
+	})
+}
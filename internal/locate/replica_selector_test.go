@@ -2561,6 +2561,72 @@ func TestReplicaReadAccessPathByLearnerCase(t *testing.T) {
 	s.True(s.runCaseAndCompare(ca))
 }
 
+func TestReplicaReadAccessPathByStaleReadLeaderFallbackCase(t *testing.T) {
+	s := new(testReplicaSelectorSuite)
+	s.SetupTest(t)
+	defer s.TearDownTest()
+
+	var store2 *Store
+	for _, store := range s.getRegion().getStore().stores {
+		if store.storeID == 2 {
+			store2 = store
+		}
+	}
+	s.NotNil(store2)
+	// The DataIsNotReady error exhausts the error budget of store2.
+	ca := replicaSelectorAccessPathCase{
+		reqType:   tikvrpc.CmdGet,
+		readType:  kv.ReplicaReadMixed,
+		staleRead: true,
+		label:     &metapb.StoreLabel{Key: "id", Value: "2"},
+		accessErr: []RegionErrorType{DataIsNotReadyErr},
+		expect: &accessPathResult{
+			accessPath: []string{
+				"{addr: store2, replica-read: false, stale-read: true}",
+				"{addr: store1, replica-read: false, stale-read: false}",
+			},
+			respErr:         "",
+			respRegionError: nil,
+			backoffCnt:      0,
+			backoffDetail:   []string{},
+			regionIsValid:   true,
+		},
+		beforeRun: func() {
+			s.resetStoreState()
+			store2.staleReadNotReady.Store(2)
+		},
+	}
+	s.True(s.runCaseAndCompare(ca))
+	s.True(store2.isStaleReadLagging())
+
+	// The stale reads go to the leader directly during the cooldown.
+	ca = replicaSelectorAccessPathCase{
+		reqType:   tikvrpc.CmdGet,
+		readType:  kv.ReplicaReadMixed,
+		staleRead: true,
+		label:     &metapb.StoreLabel{Key: "id", Value: "2"},
+		expect: &accessPathResult{
+			accessPath: []string{
+				"{addr: store1, replica-read: false, stale-read: false}",
+			},
+			respErr:         "",
+			respRegionError: nil,
+			backoffCnt:      0,
+			backoffDetail:   []string{},
+			regionIsValid:   true,
+		},
+		beforeRun: func() {},
+	}
+	s.True(s.runCaseAndCompare(ca))
+
+	// The stale reads go back to store2 after the cooldown.
+	ca.expect.accessPath = []string{"{addr: store2, replica-read: false, stale-read: true}"}
+	ca.beforeRun = func() {
+		store2.staleReadLaggingUntil.Store(time.Now().Add(-time.Second).UnixNano())
+	}
+	s.True(s.runCaseAndCompare(ca))
+}
+
 func TestReplicaReadAvoidSlowStore(t *testing.T) {
 	s := new(testReplicaSelectorSuite)
 	s.SetupTest(t)
@@ -2717,6 +2783,64 @@ func TestReplicaReadAvoidSlowStore(t *testing.T) {
 	}
 }
 
+type replicaSelectorPolicyFn func(req *tikvrpc.Request, replicas []ReplicaStatus, attempts []ReplicaAttempt) int
+
+func (f replicaSelectorPolicyFn) Next(req *tikvrpc.Request, replicas []ReplicaStatus, attempts []ReplicaAttempt) int {
+	return f(req, replicas, attempts)
+}
+
+func TestReplicaSelectorPolicy(t *testing.T) {
+	s := new(testReplicaSelectorSuite)
+	s.SetupTest(t)
+	defer s.TearDownTest()
+
+	var history [][]ReplicaAttempt
+	// The policy tries the replicas in the descending order of store IDs.
+	s.cache.SetReplicaSelectorPolicy(replicaSelectorPolicyFn(func(req *tikvrpc.Request, replicas []ReplicaStatus, attempts []ReplicaAttempt) int {
+		history = append(history, attempts)
+		best := -1
+		for i, r := range replicas {
+			s.Equal(r.StoreID == 1, r.IsLeader)
+			s.Equal(fmt.Sprintf("%v", r.StoreID), r.Labels[0].Value)
+			if r.Attempts == 0 && (best < 0 || r.StoreID > replicas[best].StoreID) {
+				best = i
+			}
+		}
+		return best
+	}))
+	ca := replicaSelectorAccessPathCase{
+		reqType:   tikvrpc.CmdGet,
+		readType:  kv.ReplicaReadLeader,
+		accessErr: []RegionErrorType{ServerIsBusyErr, DeadLineExceededErr},
+	}
+	ca.run(s)
+	s.Equal([]string{
+		"{addr: store3, replica-read: true, stale-read: false}",
+		"{addr: store2, replica-read: true, stale-read: false}",
+		"{addr: store1, replica-read: false, stale-read: false}",
+	}, ca.result.accessPath)
+	s.Len(history, 3)
+	s.Empty(history[0])
+	s.Len(history[1], 1)
+	s.Equal(uint64(3), history[1][0].StoreID)
+	s.NotNil(history[1][0].RegionError.GetServerIsBusy())
+	s.Len(history[2], 2)
+	s.Equal(uint64(2), history[2][1].StoreID)
+	s.NotNil(history[2][1].Err)
+
+	// The region is given up if the policy selects no replica.
+	s.cache.SetReplicaSelectorPolicy(replicaSelectorPolicyFn(func(*tikvrpc.Request, []ReplicaStatus, []ReplicaAttempt) int {
+		return -1
+	}))
+	ca = replicaSelectorAccessPathCase{
+		reqType:  tikvrpc.CmdGet,
+		readType: kv.ReplicaReadLeader,
+	}
+	ca.run(s)
+	s.Empty(ca.result.accessPath)
+	s.NotNil(ca.result.respRegionError.GetEpochNotMatch())
+}
+
 func (s *testReplicaSelectorSuite) changeRegionLeader(storeId uint64) {
 	loc, err := s.cache.LocateKey(s.bo, []byte("key"))
 	s.Nil(err)
@@ -2947,6 +3071,8 @@ func (s *testReplicaSelectorSuite) resetStoreState() {
 	s.NotNil(rc)
 	for _, store := range rc.getStore().stores {
 		store.loadStats.Store(nil)
+		store.staleReadNotReady.Store(0)
+		store.staleReadLaggingUntil.Store(0)
 		store.healthStatus.clientSideSlowScore.resetSlowScore()
 		store.healthStatus.ResetTiKVServerSideSlowScoreForTest(1)
 		store.healthStatus.updateSlowFlag()
@@ -68,10 +68,12 @@ type storeCache interface {
 	markTiflashComputeStoresNeedReload()
 	markStoreNeedCheck(store *Store)
 	getCheckStoreEvents() <-chan struct{}
+	publishEvent(e Event)
+	storeAddrChanged(oldAddr, newAddr string)
 }
 
-func newStoreCache(pdClient pd.Client) *storeCacheImpl {
-	c := &storeCacheImpl{pdClient: pdClient}
+func newStoreCache(pdClient pd.Client, events *eventBus) *storeCacheImpl {
+	c := &storeCacheImpl{pdClient: pdClient, events: events}
 	c.notifyCheckCh = make(chan struct{}, 1)
 	c.storeMu.stores = make(map[uint64]*Store)
 	c.tiflashComputeStoreMu.needReload = true
@@ -81,6 +83,11 @@ func newStoreCache(pdClient pd.Client) *storeCacheImpl {
 
 type storeCacheImpl struct {
 	pdClient pd.Client
+	events   *eventBus
+	// addrChangedCallback is called when the address of a store changes.
+	addrChangedCallback func(oldAddr, newAddr string)
+	// regionMap serves the stores while PD is unavailable, see RegionCache.EnableRegionMapFallback.
+	regionMap *atomic.Pointer[regionMap]
 
 	testingKnobs struct {
 		// Replace the requestLiveness function for test purpose. Note that in unit tests, if this is not set,
@@ -114,7 +121,15 @@ func (c *storeCacheImpl) setMockRequestLiveness(f livenessFunc) {
 }
 
 func (c *storeCacheImpl) fetchStore(ctx context.Context, id uint64) (*metapb.Store, error) {
-	return c.pdClient.GetStore(ctx, id)
+	store, err := c.pdClient.GetStore(ctx, id)
+	if err != nil && c.regionMap != nil {
+		if m := c.regionMap.Load(); m != nil {
+			if s := m.store(id); s != nil {
+				return s, nil
+			}
+		}
+	}
+	return store, err
 }
 
 func (c *storeCacheImpl) fetchAllStores(ctx context.Context) ([]*metapb.Store, error) {
@@ -193,6 +208,7 @@ func (c *storeCacheImpl) markTiflashComputeStoresNeedReload() {
 
 func (c *storeCacheImpl) markStoreNeedCheck(store *Store) {
 	if store.changeResolveStateTo(resolved, needCheck) {
+		publishStoreState(c, store, needCheck.String())
 		select {
 		case c.notifyCheckCh <- struct{}{}:
 		default:
@@ -204,6 +220,12 @@ func (c *storeCacheImpl) getCheckStoreEvents() <-chan struct{} {
 	return c.notifyCheckCh
 }
 
+func (c *storeCacheImpl) storeAddrChanged(oldAddr, newAddr string) {
+	if c.addrChangedCallback != nil {
+		c.addrChangedCallback(oldAddr, newAddr)
+	}
+}
+
 // Store contains a kv process's address.
 type Store struct {
 	addr         string               // loaded store address
@@ -228,6 +250,12 @@ type Store struct {
 	healthStatus *StoreHealthStatus
 	// A statistic for counting the flows of different replicas on this store
 	replicaFlowsStats [numReplicaFlowsType]uint64
+
+	// staleReadNotReady counts the consecutive DataIsNotReady errors returned to the stale reads.
+	staleReadNotReady atomic.Uint32
+	// staleReadLaggingUntil is the unix nano time until which the stale reads avoid the store,
+	// see config.StaleReadFallback.
+	staleReadLaggingUntil atomic.Int64
 }
 
 func newStore(
@@ -458,6 +486,7 @@ func (s *Store) reResolve(c storeCache, scheduler *bgRunner) (bool, error) {
 			zap.Uint64("store", s.storeID), zap.String("addr", s.addr))
 		atomic.AddUint32(&s.epoch, 1)
 		s.setResolveState(tombstone)
+		publishStoreState(c, s, tombstone.String())
 		metrics.RegionCacheCounterWithInvalidateStoreRegionsOK.Inc()
 		return false, nil
 	}
@@ -484,6 +513,10 @@ func (s *Store) reResolve(c storeCache, scheduler *bgRunner) (bool, error) {
 		}
 		c.put(newStore)
 		s.setResolveState(deleted)
+		publishStoreState(c, newStore, "changed")
+		if s.addr != addr {
+			c.storeAddrChanged(s.addr, addr)
+		}
 		logutil.BgLogger().Info("store address or labels changed, add new store and mark old store deleted",
 			zap.Uint64("store", s.storeID),
 			zap.String("old-addr", s.addr),
@@ -608,6 +641,7 @@ func (s *Store) requestLivenessAndStartHealthCheckLoopIfNeeded(bo *retry.Backoff
 	// It may be already started by another thread.
 	if atomic.CompareAndSwapUint32(&s.livenessState, uint32(reachable), uint32(liveness)) {
 		s.unreachableSince = time.Now()
+		publishStoreState(c, s, liveness.String())
 		reResolveInterval := storeReResolveInterval
 		if val, err := util.EvalFailpoint("injectReResolveInterval"); err == nil {
 			if dur, err := time.ParseDuration(val.(string)); err == nil {
@@ -646,6 +680,7 @@ func startHealthCheckLoop(scheduler *bgRunner, c storeCache, s *Store, liveness
 		atomic.StoreUint32(&s.livenessState, uint32(liveness))
 		if liveness == reachable {
 			logutil.BgLogger().Info("[health check] store became reachable", zap.Uint64("storeID", s.storeID))
+			publishStoreState(c, s, reachable.String())
 			return true
 		}
 		return false
@@ -1090,3 +1125,34 @@ func (s *Store) resetReplicaFlowsStats(destType replicaFlowsType) {
 func (s *Store) recordReplicaFlowsStats(destType replicaFlowsType) {
 	atomic.AddUint64(&s.replicaFlowsStats[destType], 1)
 }
+
+// recordStaleReadNotReady records a DataIsNotReady error returned to a stale read, and
+// regards the store as lagging behind for a cooldown period when the errors exceed
+// the budget.
+func (s *Store) recordStaleReadNotReady() {
+	cfg := config.GetGlobalConfig().TiKVClient.StaleReadFallback
+	if cfg.ErrorBudget == 0 || cfg.Cooldown <= 0 {
+		return
+	}
+	if s.staleReadNotReady.Add(1) < uint32(cfg.ErrorBudget) {
+		return
+	}
+	s.staleReadNotReady.Store(0)
+	s.staleReadLaggingUntil.Store(time.Now().Add(cfg.Cooldown).UnixNano())
+	logutil.BgLogger().Info("stale reads fall back to the leaders for the store lagging behind",
+		zap.Uint64("store", s.storeID), zap.Duration("cooldown", cfg.Cooldown))
+}
+
+// recordStaleReadSuccess resets the DataIsNotReady errors after a stale read succeeds.
+func (s *Store) recordStaleReadSuccess() {
+	if s.staleReadNotReady.Load() != 0 {
+		s.staleReadNotReady.Store(0)
+	}
+}
+
+// isStaleReadLagging returns whether the store is regarded as lagging behind, so the
+// stale reads should be sent to the leaders instead.
+func (s *Store) isStaleReadLagging() bool {
+	until := s.staleReadLaggingUntil.Load()
+	return until != 0 && time.Now().UnixNano() < until
+}
@@ -0,0 +1,52 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"context"
+	"time"
+
+	"github.com/tikv/client-go/v2/internal/client"
+	"github.com/tikv/client-go/v2/metrics"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+type storeMetricsClient struct {
+	client.Client
+	metrics *metrics.StoreMetrics
+}
+
+// NewStoreMetricsClient creates a Client observing the requests sent by it in
+// the metrics of a store.
+func NewStoreMetricsClient(c client.Client, m *metrics.StoreMetrics) client.Client {
+	return &storeMetricsClient{Client: c, metrics: m}
+}
+
+func (c *storeMetricsClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	start := time.Now()
+	resp, err := c.Client.SendRequest(ctx, addr, req, timeout)
+	c.metrics.SendReqHistogram.WithLabelValues(req.Type.String(), addr).Observe(time.Since(start).Seconds())
+	if err != nil {
+		c.metrics.RPCErrorCounter.WithLabelValues(req.Type.String(), addr).Inc()
+		return resp, err
+	}
+	if resp == nil {
+		return resp, err
+	}
+	if regionErr, _ := resp.GetRegionError(); regionErr != nil {
+		c.metrics.RegionErrorCounter.WithLabelValues(regionErrorToLabel(regionErr), addr).Inc()
+	}
+	return resp, err
+}
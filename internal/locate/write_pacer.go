@@ -0,0 +1,215 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+const (
+	// minWritePacingRate is the lowest rate (requests per second) the pacer
+	// decreases to.
+	minWritePacingRate = 1.0
+	// writePacingBusyFactor is applied to the rate when the store reports
+	// ServerIsBusy, which means the write is rejected.
+	writePacingBusyFactor = 0.5
+	// writePacingThrottleFactor is applied to the rate when the store delays
+	// the write by flow control, which means the write is accepted but slow.
+	writePacingThrottleFactor = 0.8
+	// writePacingIncrease is the ratio of the rate added on every write
+	// succeeding without being throttled.
+	writePacingIncrease = 0.02
+	// writePacingWindow is the window to measure the write rate before pacing.
+	writePacingWindow = time.Second
+)
+
+// WritePacingStats is the stats of the write pacing of a store.
+type WritePacingStats struct {
+	StoreID uint64
+	// Rate is the current limit of write requests per second, 0 means the
+	// store is not paced.
+	Rate float64
+	// ThrottleSignals is the number of ServerIsBusy errors and flow-control
+	// delays reported by the store.
+	ThrottleSignals uint64
+	// PacedRequests is the number of write requests delayed by the pacer.
+	PacedRequests uint64
+	// PacedDuration is the total time the write requests are delayed.
+	PacedDuration time.Duration
+}
+
+// writePacer paces the write requests to a store with a token bucket whose
+// rate is adjusted by the flow-control signals from the store: it's decreased
+// multiplicatively on signals and increased additively on normal writes. The
+// pacer stops limiting when the rate recovers to the rate observed before the
+// first signal.
+type writePacer struct {
+	mu sync.Mutex
+	// rate is in requests per second, 0 means unlimited.
+	rate    float64
+	ceiling float64
+	tokens  float64
+	last    time.Time
+
+	// the write rate is measured in windows before pacing.
+	windowStart time.Time
+	windowCount int
+
+	stats WritePacingStats
+}
+
+func newWritePacer(storeID uint64) *writePacer {
+	return &writePacer{stats: WritePacingStats{StoreID: storeID}}
+}
+
+// reserve takes a token and returns how long the caller should wait.
+func (p *writePacer) reserve(now time.Time) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.rate == 0 {
+		if now.Sub(p.windowStart) > writePacingWindow {
+			p.windowStart, p.windowCount = now, 0
+		}
+		p.windowCount++
+		return 0
+	}
+	burst := math.Max(1, p.rate/10)
+	p.tokens = math.Min(burst, p.tokens+now.Sub(p.last).Seconds()*p.rate)
+	p.last = now
+	p.tokens--
+	if p.tokens >= 0 {
+		return 0
+	}
+	wait := time.Duration(-p.tokens / p.rate * float64(time.Second))
+	p.stats.PacedRequests++
+	p.stats.PacedDuration += wait
+	return wait
+}
+
+func (p *writePacer) onThrottled(now time.Time, factor float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stats.ThrottleSignals++
+	if p.rate == 0 {
+		elapsed := math.Max(now.Sub(p.windowStart).Seconds(), 0.1)
+		p.ceiling = math.Max(float64(p.windowCount)/elapsed, minWritePacingRate)
+		p.rate = p.ceiling
+		p.tokens = 0
+		p.last = now
+	}
+	p.rate = math.Max(p.rate*factor, minWritePacingRate)
+}
+
+func (p *writePacer) onSuccess() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.rate == 0 {
+		return
+	}
+	p.rate += math.Max(p.rate*writePacingIncrease, 1)
+	if p.rate >= p.ceiling {
+		p.rate = 0
+		p.windowStart, p.windowCount = time.Time{}, 0
+	}
+}
+
+func (p *writePacer) getStats() WritePacingStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.stats
+	s.Rate = p.rate
+	return s
+}
+
+// SetWritePacing enables or disables pacing the write requests by the
+// flow-control signals of the stores.
+func (c *RegionCache) SetWritePacing(enable bool) {
+	c.writePacing.Store(enable)
+}
+
+// WritePacingStats returns the write pacing stats of the stores written since
+// the write pacing is enabled, sorted by store ID.
+func (c *RegionCache) WritePacingStats() []WritePacingStats {
+	var stats []WritePacingStats
+	c.writePacers.Range(func(_, v any) bool {
+		stats = append(stats, v.(*writePacer).getStats())
+		return true
+	})
+	sort.Slice(stats, func(i, j int) bool { return stats[i].StoreID < stats[j].StoreID })
+	return stats
+}
+
+func (c *RegionCache) getWritePacer(storeID uint64) *writePacer {
+	if v, ok := c.writePacers.Load(storeID); ok {
+		return v.(*writePacer)
+	}
+	v, _ := c.writePacers.LoadOrStore(storeID, newWritePacer(storeID))
+	return v.(*writePacer)
+}
+
+// waitWritePacing blocks until the write request can be sent to the store.
+func (c *RegionCache) waitWritePacing(ctx context.Context, store *Store, req *tikvrpc.Request) error {
+	if c == nil || !c.writePacing.Load() || store == nil || !isWriteReq(req.Type) {
+		return nil
+	}
+	wait := c.getWritePacer(store.storeID).reserve(time.Now())
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// onWriteResponse adjusts the write pacing of the store by the response.
+func (c *RegionCache) onWriteResponse(store *Store, req *tikvrpc.Request, resp *tikvrpc.Response) {
+	if c == nil || !c.writePacing.Load() || store == nil || resp == nil || !isWriteReq(req.Type) {
+		return
+	}
+	p := c.getWritePacer(store.storeID)
+	if regionErr, _ := resp.GetRegionError(); regionErr != nil {
+		if regionErr.GetServerIsBusy() != nil {
+			p.onThrottled(time.Now(), writePacingBusyFactor)
+		}
+		return
+	}
+	if details := resp.GetExecDetailsV2(); details.GetWriteDetail().GetThrottleNanos() > 0 {
+		p.onThrottled(time.Now(), writePacingThrottleFactor)
+		return
+	}
+	p.onSuccess()
+}
+
+func isWriteReq(tp tikvrpc.CmdType) bool {
+	switch tp {
+	case tikvrpc.CmdPrewrite, tikvrpc.CmdCommit, tikvrpc.CmdPessimisticLock, tikvrpc.CmdFlush,
+		tikvrpc.CmdRawPut, tikvrpc.CmdRawBatchPut, tikvrpc.CmdRawDelete, tikvrpc.CmdRawBatchDelete,
+		tikvrpc.CmdRawDeleteRange, tikvrpc.CmdRawCompareAndSwap:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritePacer(t *testing.T) {
+	p := newWritePacer(1)
+	now := time.Now()
+
+	// Not paced before any signal: 100 writes in 100ms, i.e. 1000/s.
+	for i := 0; i < 100; i++ {
+		require.Zero(t, p.reserve(now.Add(time.Duration(i)*time.Millisecond)))
+	}
+	now = now.Add(100 * time.Millisecond)
+
+	// ServerIsBusy halves the observed rate.
+	p.onThrottled(now, writePacingBusyFactor)
+	stats := p.getStats()
+	require.Equal(t, uint64(1), stats.ThrottleSignals)
+	require.InDelta(t, 500, stats.Rate, 1)
+
+	// The tokens are empty, so the writes are spaced by 1/rate.
+	require.InDelta(t, 2*time.Millisecond, p.reserve(now), float64(100*time.Microsecond))
+	require.InDelta(t, 4*time.Millisecond, p.reserve(now), float64(100*time.Microsecond))
+	// The tokens are refilled after a while.
+	now = now.Add(time.Second)
+	require.Zero(t, p.reserve(now))
+	stats = p.getStats()
+	require.Equal(t, uint64(2), stats.PacedRequests)
+	require.Greater(t, stats.PacedDuration, time.Duration(0))
+
+	// The rate doesn't drop below the minimum.
+	for i := 0; i < 100; i++ {
+		p.onThrottled(now, writePacingThrottleFactor)
+	}
+	require.Equal(t, minWritePacingRate, p.getStats().Rate)
+
+	// The pacing stops after the rate recovers to the ceiling.
+	for i := 0; i < 1000 && p.getStats().Rate > 0; i++ {
+		p.onSuccess()
+	}
+	require.Zero(t, p.getStats().Rate)
+	require.Zero(t, p.reserve(now))
+}
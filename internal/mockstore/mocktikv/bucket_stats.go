@@ -0,0 +1,92 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"math"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pingcap/kvproto/pkg/metapb"
+)
+
+// bucketStatsPeriodMs is the period reported with the bucket stats.
+const bucketStatsPeriodMs = 10000
+
+// UpdateBucketStats fills the stats of the buckets of the region, which are
+// set by SplitRegionBuckets, with the data stored in every bucket. The latest
+// committed values and the raw values are counted. As the kvproto doesn't
+// carry the approximate sizes, they are reported as the flow of a period in
+// which all the data was written and read once, i.e. the bytes and keys
+// written equal to the read ones, and the QPS is the number of the keys per
+// second. It returns false if the region doesn't exist or has no buckets.
+func (c *Cluster) UpdateBucketStats(regionID uint64) bool {
+	store := c.GetRegionMVCCStore(regionID)
+	c.Lock()
+	defer c.Unlock()
+	region := c.regions[regionID]
+	if region == nil || region.Buckets == nil || len(region.Buckets.Keys) < 2 {
+		return false
+	}
+	if store == nil {
+		store = c.mvccStore
+	}
+	keys := region.Buckets.Keys
+	n := len(keys) - 1
+	stats := &metapb.BucketStats{
+		ReadBytes:  make([]uint64, n),
+		WriteBytes: make([]uint64, n),
+		ReadQps:    make([]uint64, n),
+		WriteQps:   make([]uint64, n),
+		ReadKeys:   make([]uint64, n),
+		WriteKeys:  make([]uint64, n),
+	}
+	for i := 0; i < n; i++ {
+		size, count := approximateRangeSize(store, MvccKey(keys[i]).Raw(), MvccKey(keys[i+1]).Raw())
+		qps := count * 1000 / bucketStatsPeriodMs
+		stats.ReadBytes[i], stats.WriteBytes[i] = size, size
+		stats.ReadKeys[i], stats.WriteKeys[i] = count, count
+		stats.ReadQps[i], stats.WriteQps[i] = qps, qps
+	}
+	region.Buckets.Stats = stats
+	region.Buckets.PeriodInMs = bucketStatsPeriodMs
+	return true
+}
+
+// approximateRangeSize returns the total size and the number of the keys in
+// [startKey, endKey) of the store.
+func approximateRangeSize(store MVCCStore, startKey, endKey []byte) (size, keys uint64) {
+	if store == nil {
+		return 0, 0
+	}
+	pairs := store.Scan(startKey, endKey, math.MaxInt32, math.MaxUint64, kvrpcpb.IsolationLevel_RC, nil)
+	if raw, ok := store.(RawKV); ok {
+		for _, pair := range raw.RawScan("", startKey, endKey, math.MaxInt32) {
+			// The raw and the transactional data share the column family, skip
+			// the versions of the transactional keys counted above.
+			if _, _, err := mvccDecode(pair.Key); err == nil {
+				continue
+			}
+			pairs = append(pairs, pair)
+		}
+	}
+	for _, pair := range pairs {
+		if pair.Err != nil {
+			continue
+		}
+		size += uint64(len(pair.Key) + len(pair.Value))
+		keys++
+	}
+	return size, keys
+}
@@ -0,0 +1,50 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"time"
+
+	"github.com/tikv/client-go/v2/oracle"
+)
+
+// AdvanceClock moves the clock of the cluster forward by d, so that the locks
+// expire without waiting for their TTLs in tests. The timestamps allocated by
+// the mock PD are moved forward as well, and the TTLs of the locks are checked
+// against the clock in CheckTxnStatus and Cleanup even if the requests carry
+// an older current ts. A non-positive d is ignored.
+func (c *Cluster) AdvanceClock(d time.Duration) {
+	if d > 0 {
+		c.clockOffset.Add(int64(d))
+	}
+}
+
+// Now returns the current time of the cluster, see AdvanceClock.
+func (c *Cluster) Now() time.Time {
+	return time.Now().Add(time.Duration(c.clockOffset.Load()))
+}
+
+// currentTS returns the ts to check the TTLs of the locks with, given the
+// current ts in the request. The clock of the cluster is used only after it's
+// advanced, to keep the behavior of the requests with explicit current ts.
+func (c *Cluster) currentTS(reqTS uint64) uint64 {
+	if c.clockOffset.Load() == 0 {
+		return reqTS
+	}
+	if ts := oracle.GoTimeToTS(c.Now()); ts > reqTS {
+		return ts
+	}
+	return reqTS
+}
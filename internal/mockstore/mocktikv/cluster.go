@@ -41,11 +41,13 @@ import (
 	"slices"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/protobuf/proto" //nolint:staticcheck
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pkg/errors"
 	"github.com/tikv/client-go/v2/internal/mockstore/cluster"
 	"github.com/tikv/client-go/v2/util"
 	"github.com/tikv/pd/client/clients/router"
@@ -76,6 +78,63 @@ type Cluster struct {
 	// delayEvents is used to control the execution sequence of rpc requests for test.
 	delayEvents map[delayKey]time.Duration
 	delayMu     sync.Mutex
+
+	// storeLoads is used to simulate the resource usage of stores.
+	storeLoads map[uint64]*storeLoad
+	// storeStorages is used to simulate the disk usage of stores.
+	storeStorages map[uint64]StoreStorage
+	loadMu        sync.Mutex
+
+	// gcOnSafePointUpdate makes the mock PD run GC on mvccStore when the GC
+	// safe point is updated, like the compaction filter of TiKV.
+	gcOnSafePointUpdate atomic.Bool
+
+	// regionStores overrides the MVCCStore serving the regions.
+	regionStores  map[uint64]MVCCStore
+	regionStoreMu sync.RWMutex
+
+	// strictContext validates the context of every request if it's set.
+	strictContext atomic.Pointer[strictContextCheck]
+
+	// clockOffset is the offset of the clock of the cluster from the wall
+	// clock in nanoseconds, see AdvanceClock.
+	clockOffset atomic.Int64
+
+	// checkedEpochs is the region epochs seen by the last CheckIntegrity.
+	checkedEpochs map[uint64]*metapb.RegionEpoch
+
+	// writes deduplicates the retried writes carrying tokens.
+	writes   appliedWrites
+	writesMu sync.Mutex
+
+	// reqLog records the requests received by the stores for the tests.
+	reqLog requestLog
+
+	// sched holds the requests for the tests to handle them in a
+	// deterministic order, see EnableScheduler.
+	sched scheduler
+
+	// flashbacks tracks the regions in the flashback progress.
+	flashbacks regionFlashbacks
+
+	// streamFaults breaks the streaming responses, see InterruptStreams.
+	streamFaults streamFaults
+
+	// maxRaftEntrySize is the size limit of the writes, see SetMaxRaftEntrySize.
+	maxRaftEntrySize atomic.Int64
+
+	// merges runs the merges scheduled by ScheduleMerge.
+	merges regionMerges
+
+	// requestFaults fails the requests as registered by FailNext and FailNth.
+	requestFaults requestFaults
+
+	// rpcStats accounts the requests served by the cluster, see Stats.
+	rpcStats rpcStats
+
+	// watchers receive the changes of the stores and the regions, see
+	// WatchStores and WatchRegions.
+	watchers clusterWatchers
 }
 
 type delayKey struct {
@@ -87,11 +146,14 @@ type delayKey struct {
 // providing service.
 func NewCluster(mvccStore MVCCStore) *Cluster {
 	return &Cluster{
-		stores:      make(map[uint64]*Store),
-		regions:     make(map[uint64]*Region),
-		downPeers:   make(map[uint64]struct{}),
-		delayEvents: make(map[delayKey]time.Duration),
-		mvccStore:   mvccStore,
+		stores:        make(map[uint64]*Store),
+		regions:       make(map[uint64]*Region),
+		downPeers:     make(map[uint64]struct{}),
+		delayEvents:   make(map[delayKey]time.Duration),
+		storeLoads:    make(map[uint64]*storeLoad),
+		storeStorages: make(map[uint64]StoreStorage),
+		regionStores:  make(map[uint64]MVCCStore),
+		mvccStore:     mvccStore,
 	}
 }
 
@@ -153,26 +215,41 @@ func (c *Cluster) GetAllStores() []*metapb.Store {
 	return stores
 }
 
-// StopStore stops a store with storeID.
+// StopStore stops the process of a store with storeID: the connections to it
+// are refused, while it's still a member of the cluster known by PD and its
+// data is retained, so it serves the same data after StartStore. Use
+// RemoveStore to remove the store from the cluster instead.
 func (c *Cluster) StopStore(storeID uint64) {
 	c.Lock()
 	defer c.Unlock()
 
 	if store := c.stores[storeID]; store != nil {
-		store.meta.State = metapb.StoreState_Offline
+		store.stopped = true
 	}
 }
 
-// StartStore starts a store with storeID.
+// StartStore starts a store with storeID stopped by StopStore, or brings a
+// store marked as tombstone back up.
 func (c *Cluster) StartStore(storeID uint64) {
 	c.Lock()
 	defer c.Unlock()
 
 	if store := c.stores[storeID]; store != nil {
+		store.stopped = false
 		store.meta.State = metapb.StoreState_Up
+		c.notifyStore(storeID)
 	}
 }
 
+// isStoreStopped returns whether the store is stopped by StopStore.
+func (c *Cluster) isStoreStopped(storeID uint64) bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	store := c.stores[storeID]
+	return store != nil && store.stopped
+}
+
 // CancelStore makes the store with cancel state true.
 func (c *Cluster) CancelStore(storeID uint64) {
 	c.Lock()
@@ -194,6 +271,77 @@ func (c *Cluster) UnCancelStore(storeID uint64) {
 	}
 }
 
+// SetStoreVersion sets the TiKV version reported by the store.
+func (c *Cluster) SetStoreVersion(storeID uint64, version string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if store := c.stores[storeID]; store != nil {
+		store.meta.Version = version
+		c.notifyStore(storeID)
+	}
+}
+
+// SetStoreCapabilities replaces the feature capability flags of the store.
+func (c *Cluster) SetStoreCapabilities(storeID uint64, capabilities ...string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if store := c.stores[storeID]; store != nil {
+		store.capabilities = make(map[string]struct{}, len(capabilities))
+		for _, capability := range capabilities {
+			store.capabilities[capability] = struct{}{}
+		}
+	}
+}
+
+// GetStoreCapabilities returns the sorted feature capability flags of the store.
+func (c *Cluster) GetStoreCapabilities(storeID uint64) []string {
+	c.RLock()
+	defer c.RUnlock()
+
+	store := c.stores[storeID]
+	if store == nil {
+		return nil
+	}
+	capabilities := make([]string, 0, len(store.capabilities))
+	for capability := range store.capabilities {
+		capabilities = append(capabilities, capability)
+	}
+	sort.Strings(capabilities)
+	return capabilities
+}
+
+// StoreHasCapability checks whether the store supports the feature.
+func (c *Cluster) StoreHasCapability(storeID uint64, capability string) bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	if store := c.stores[storeID]; store != nil {
+		_, ok := store.capabilities[capability]
+		return ok
+	}
+	return false
+}
+
+// AllStoresHaveCapability checks whether all the stores that are not
+// tombstone support the feature, which is how features are usually gated in
+// a mixed-version cluster.
+func (c *Cluster) AllStoresHaveCapability(capability string) bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	for _, store := range c.stores {
+		if store.meta.GetState() == metapb.StoreState_Tombstone {
+			continue
+		}
+		if _, ok := store.capabilities[capability]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
 // GetStoreByAddr returns a Store's meta by an addr.
 func (c *Cluster) GetStoreByAddr(addr string) *metapb.Store {
 	c.RLock()
@@ -230,6 +378,7 @@ func (c *Cluster) AddStore(storeID uint64, addr string, labels ...*metapb.StoreL
 	defer c.Unlock()
 
 	c.stores[storeID] = newStore(storeID, addr, addr, labels...)
+	c.notifyStore(storeID)
 }
 
 // RemoveStore removes a Store from the cluster.
@@ -237,7 +386,10 @@ func (c *Cluster) RemoveStore(storeID uint64) {
 	c.Lock()
 	defer c.Unlock()
 
-	delete(c.stores, storeID)
+	if store := c.stores[storeID]; store != nil {
+		delete(c.stores, storeID)
+		c.pushStoreEvent(store.meta, true)
+	}
 }
 
 // MarkTombstone marks store as tombstone.
@@ -247,6 +399,7 @@ func (c *Cluster) MarkTombstone(storeID uint64) {
 	nm := *c.stores[storeID].meta
 	nm.State = metapb.StoreState_Tombstone
 	c.stores[storeID].meta = &nm
+	c.notifyStore(storeID)
 }
 
 func (c *Cluster) MarkPeerDown(peerID uint64) {
@@ -265,7 +418,8 @@ func (c *Cluster) RemoveDownPeer(peerID uint64) {
 func (c *Cluster) UpdateStoreAddr(storeID uint64, addr string, labels ...*metapb.StoreLabel) {
 	c.Lock()
 	defer c.Unlock()
-	c.stores[storeID] = newStore(storeID, addr, addr, labels...)
+	c.stores[storeID] = newStore(storeID, addr, addr, labels...).inherit(c.stores[storeID])
+	c.notifyStore(storeID)
 }
 
 // UpdateStorePeerAddr updates store peer address for cluster.
@@ -273,7 +427,8 @@ func (c *Cluster) UpdateStorePeerAddr(storeID uint64, peerAddr string, labels ..
 	c.Lock()
 	defer c.Unlock()
 	addr := c.stores[storeID].meta.Address
-	c.stores[storeID] = newStore(storeID, addr, peerAddr, labels...)
+	c.stores[storeID] = newStore(storeID, addr, peerAddr, labels...).inherit(c.stores[storeID])
+	c.notifyStore(storeID)
 }
 
 // GetRegion returns a Region's meta and leader ID.
@@ -424,6 +579,7 @@ func (c *Cluster) Bootstrap(regionID uint64, storeIDs, peerIDs []uint64, leaderP
 		panic("len(storeIDs) != len(peerIDs)")
 	}
 	c.regions[regionID] = newRegion(regionID, storeIDs, peerIDs, leaderPeerID)
+	c.notifyRegion(regionID)
 }
 
 // PutRegion adds or replaces a region.
@@ -432,6 +588,7 @@ func (c *Cluster) PutRegion(regionID, confVer, ver uint64, storeIDs, peerIDs []u
 	defer c.Unlock()
 
 	c.regions[regionID] = newRegion(regionID, storeIDs, peerIDs, leaderPeerID, confVer, ver)
+	c.notifyRegion(regionID)
 }
 
 // AddPeer adds a new Peer for the Region on the Store.
@@ -440,6 +597,7 @@ func (c *Cluster) AddPeer(regionID, storeID, peerID uint64) {
 	defer c.Unlock()
 
 	c.regions[regionID].addPeer(peerID, storeID, metapb.PeerRole_Voter)
+	c.notifyRegion(regionID)
 }
 
 // AddLearner adds a new learner for the Region on the Store.
@@ -448,6 +606,49 @@ func (c *Cluster) AddLearner(regionID, storeID, peerID uint64) {
 	defer c.Unlock()
 
 	c.regions[regionID].addPeer(peerID, storeID, metapb.PeerRole_Learner)
+	c.notifyRegion(regionID)
+}
+
+// AddPeerWithRole adds a new Peer with the role for the Region on the Store.
+// It's used to simulate joint consensus, where a peer may be IncomingVoter or
+// DemotingVoter.
+func (c *Cluster) AddPeerWithRole(regionID, storeID, peerID uint64, role metapb.PeerRole) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.regions[regionID].addPeer(peerID, storeID, role)
+	c.notifyRegion(regionID)
+}
+
+// AddWitness adds a new witness voter for the Region on the Store. A witness
+// only keeps the raft log, so it rejects all reads and writes.
+func (c *Cluster) AddWitness(regionID, storeID, peerID uint64) {
+	c.Lock()
+	defer c.Unlock()
+
+	r := c.regions[regionID]
+	r.addPeer(peerID, storeID, metapb.PeerRole_Voter)
+	r.Meta.Peers[len(r.Meta.Peers)-1].IsWitness = true
+	c.notifyRegion(regionID)
+}
+
+// ChangePeerRole changes the role of the Peer, e.g. promotes an IncomingVoter
+// to Voter when leaving the joint state.
+func (c *Cluster) ChangePeerRole(regionID, peerID uint64, role metapb.PeerRole) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.regions[regionID].changePeerRole(peerID, role)
+	c.notifyRegion(regionID)
+}
+
+// SwitchWitness switches the Peer between a witness and a normal peer.
+func (c *Cluster) SwitchWitness(regionID, peerID uint64, isWitness bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.regions[regionID].setWitness(peerID, isWitness)
+	c.notifyRegion(regionID)
 }
 
 // RemovePeer removes the Peer from the Region. Note that if the Peer is leader,
@@ -457,6 +658,7 @@ func (c *Cluster) RemovePeer(regionID, peerID uint64) {
 	defer c.Unlock()
 
 	c.regions[regionID].removePeer(peerID)
+	c.notifyRegion(regionID)
 }
 
 // ChangeLeader sets the Region's leader Peer. Caller should guarantee the Peer
@@ -466,6 +668,7 @@ func (c *Cluster) ChangeLeader(regionID, leaderPeerID uint64) {
 	defer c.Unlock()
 
 	c.regions[regionID].changeLeader(leaderPeerID)
+	c.notifyRegion(regionID)
 }
 
 // GiveUpLeader sets the Region's leader to 0. The Region will have no leader
@@ -489,6 +692,7 @@ func (c *Cluster) SplitRegionBuckets(regionID uint64, keys [][]byte, bucketVer u
 		mvccKeys = append(mvccKeys, NewMvccKey(k))
 	}
 	region.Buckets = &metapb.Buckets{RegionId: regionID, Version: bucketVer, Keys: mvccKeys}
+	c.notifyRegion(regionID)
 }
 
 // SplitRaw splits a Region at the key (not encoded) and creates new Region.
@@ -498,6 +702,8 @@ func (c *Cluster) SplitRaw(regionID, newRegionID uint64, rawKey []byte, peerIDs
 
 	newRegion := c.regions[regionID].split(newRegionID, rawKey, peerIDs, leaderPeerID)
 	c.regions[newRegionID] = newRegion
+	c.notifyRegion(regionID)
+	c.notifyRegion(newRegionID)
 	// The mocktikv should return a deep copy of meta info to avoid data race
 	meta := proto.Clone(newRegion.Meta)
 	return meta.(*metapb.Region)
@@ -509,7 +715,10 @@ func (c *Cluster) Merge(regionID1, regionID2 uint64) {
 	defer c.Unlock()
 
 	c.regions[regionID1].merge(c.regions[regionID2].Meta.GetEndKey())
+	removed := c.regions[regionID2]
 	delete(c.regions, regionID2)
+	c.notifyRegion(regionID1)
+	c.pushRegionEvent(removed, true)
 }
 
 // SplitKeys evenly splits the start, end key into "count" regions.
@@ -530,6 +739,39 @@ func (c *Cluster) UpdateStoreLabels(storeID uint64, labels []*metapb.StoreLabel)
 	c.Lock()
 	defer c.Unlock()
 	c.stores[storeID].mergeLabels(labels)
+	c.notifyStore(storeID)
+}
+
+// SetGCOnSafePointUpdate sets whether to GC the data in the cluster when the
+// GC safe point in the mock PD is advanced. Old versions and rollback records
+// under the safe point are removed, and the latest committed version of every
+// key is kept. The stores set by SetRegionMVCCStore are GCed too, except the
+// read-only ones. It's disabled by default.
+func (c *Cluster) SetGCOnSafePointUpdate(enabled bool) {
+	c.gcOnSafePointUpdate.Store(enabled)
+}
+
+func (c *Cluster) gcOnSafePointUpdated(safePoint uint64) error {
+	if !c.gcOnSafePointUpdate.Load() {
+		return nil
+	}
+	var stores []MVCCStore
+	if c.mvccStore != nil {
+		stores = append(stores, c.mvccStore)
+	}
+	c.regionStoreMu.RLock()
+	for _, store := range c.regionStores {
+		if !slices.Contains(stores, store) {
+			stores = append(stores, store)
+		}
+	}
+	c.regionStoreMu.RUnlock()
+	for _, store := range stores {
+		if err := store.GC(nil, nil, safePoint); err != nil && !errors.Is(err, ErrMVCCStoreReadOnly) {
+			return err
+		}
+	}
+	return nil
 }
 
 func (c *Cluster) handleDelay(startTS, regionID uint64) {
@@ -593,6 +835,7 @@ func (c *Cluster) createNewRegions(regionPairs [][]Pair, start, end MvccKey) {
 		}
 		newRegion.updateKeyRange(regionStartKey, regionEndKey)
 		c.regions[newRegion.Meta.Id] = newRegion
+		c.notifyRegion(newRegion.Meta.Id)
 	}
 }
 
@@ -609,6 +852,7 @@ func (c *Cluster) evacuateOldRegionRanges(start, end MvccKey) {
 		if startCmp >= 0 && endCmp <= 0 {
 			// The region is within table data, it will be replaced by new regions.
 			delete(c.regions, oldRegion.Meta.Id)
+			c.pushRegionEvent(oldRegion, true)
 		} else if startCmp < 0 && endCmp > 0 {
 			// A single Region covers table data, split into two regions that do not overlap table data.
 			oldEnd := oldRegion.Meta.EndKey
@@ -617,10 +861,14 @@ func (c *Cluster) evacuateOldRegionRanges(start, end MvccKey) {
 			newRegion := newRegion(c.allocID(), []uint64{c.firstStoreID()}, []uint64{peerID}, peerID)
 			newRegion.updateKeyRange(end, oldEnd)
 			c.regions[newRegion.Meta.Id] = newRegion
+			c.notifyRegion(oldRegion.Meta.Id)
+			c.notifyRegion(newRegion.Meta.Id)
 		} else if startCmp < 0 {
 			oldRegion.updateKeyRange(oldRegion.Meta.StartKey, start)
+			c.notifyRegion(oldRegion.Meta.Id)
 		} else {
 			oldRegion.updateKeyRange(end, oldRegion.Meta.EndKey)
+			c.notifyRegion(oldRegion.Meta.Id)
 		}
 	}
 }
@@ -706,6 +954,26 @@ func (r *Region) removePeer(peerID uint64) {
 	r.incConfVer()
 }
 
+func (r *Region) changePeerRole(peerID uint64, role metapb.PeerRole) {
+	for _, peer := range r.Meta.Peers {
+		if peer.GetId() == peerID {
+			peer.Role = role
+			r.incConfVer()
+			return
+		}
+	}
+}
+
+func (r *Region) setWitness(peerID uint64, isWitness bool) {
+	for _, peer := range r.Meta.Peers {
+		if peer.GetId() == peerID {
+			peer.IsWitness = isWitness
+			r.incConfVer()
+			return
+		}
+	}
+}
+
 func (r *Region) changeLeader(leaderID uint64) {
 	r.leader = leaderID
 }
@@ -762,6 +1030,10 @@ func (r *Region) incVersion() {
 type Store struct {
 	meta   *metapb.Store
 	cancel bool // return context.Cancelled error when cancel is true.
+	// stopped refuses the connections to the store, see StopStore.
+	stopped bool
+	// capabilities is the set of features the store supports.
+	capabilities map[string]struct{}
 }
 
 func newStore(storeID uint64, addr string, peerAddr string, labels ...*metapb.StoreLabel) *Store {
@@ -775,6 +1047,17 @@ func newStore(storeID uint64, addr string, peerAddr string, labels ...*metapb.St
 	}
 }
 
+// inherit keeps the version, the capabilities and the stopped state of the old
+// store.
+func (s *Store) inherit(old *Store) *Store {
+	if old != nil {
+		s.meta.Version = old.meta.GetVersion()
+		s.capabilities = old.capabilities
+		s.stopped = old.stopped
+	}
+	return s
+}
+
 func (s *Store) mergeLabels(labels []*metapb.StoreLabel) {
 	if len(s.meta.Labels) < 1 {
 		s.meta.Labels = labels
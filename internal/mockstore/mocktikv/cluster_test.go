@@ -0,0 +1,335 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/errorpb"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+func TestStoreVersionAndCapabilities(t *testing.T) {
+	cluster := NewCluster(nil)
+	storeIDs, _, _, _ := BootstrapWithMultiStores(cluster, 2)
+	s1, s2 := storeIDs[0], storeIDs[1]
+
+	cluster.SetStoreVersion(s1, "8.5.0")
+	cluster.SetStoreVersion(s2, "6.5.0")
+	cluster.SetStoreCapabilities(s1, "async-commit", "1pc")
+	cluster.SetStoreCapabilities(s2, "async-commit")
+
+	require.Equal(t, "8.5.0", cluster.GetStore(s1).GetVersion())
+	require.Equal(t, "6.5.0", cluster.GetStore(s2).GetVersion())
+	require.Equal(t, []string{"1pc", "async-commit"}, cluster.GetStoreCapabilities(s1))
+	require.True(t, cluster.StoreHasCapability(s1, "1pc"))
+	require.False(t, cluster.StoreHasCapability(s2, "1pc"))
+	require.True(t, cluster.AllStoresHaveCapability("async-commit"))
+	require.False(t, cluster.AllStoresHaveCapability("1pc"))
+
+	// The metadata is visible from PD.
+	pdCli := NewPDClient(cluster)
+	defer pdCli.Close()
+	store, err := pdCli.GetStore(context.Background(), s2)
+	require.Nil(t, err)
+	require.Equal(t, "6.5.0", store.GetVersion())
+
+	// Updating the address keeps the version and capabilities.
+	cluster.UpdateStoreAddr(s2, "new-addr")
+	require.Equal(t, "6.5.0", cluster.GetStore(s2).GetVersion())
+	require.Equal(t, []string{"async-commit"}, cluster.GetStoreCapabilities(s2))
+
+	// Tombstone stores don't gate features.
+	cluster.MarkTombstone(s2)
+	require.True(t, cluster.AllStoresHaveCapability("1pc"))
+}
+
+func TestWitnessAndPeerRoles(t *testing.T) {
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	cluster := NewCluster(store)
+	_, _, regionID, _ := BootstrapWithMultiStores(cluster, 2)
+	client := NewRPCClient(cluster, store, nil)
+	defer client.Close()
+
+	ids := cluster.AllocIDs(4)
+	witnessStore, witnessPeer, incomingStore, incomingPeer := ids[0], ids[1], ids[2], ids[3]
+	region, _ := cluster.GetRegion(regionID)
+	confVer := region.GetRegionEpoch().GetConfVer()
+	cluster.AddStore(witnessStore, "witness")
+	cluster.AddStore(incomingStore, "incoming")
+	cluster.AddWitness(regionID, witnessStore, witnessPeer)
+	cluster.AddPeerWithRole(regionID, incomingStore, incomingPeer, metapb.PeerRole_IncomingVoter)
+
+	region, _ = cluster.GetRegion(regionID)
+	require.Equal(t, confVer+2, region.GetRegionEpoch().GetConfVer())
+	for _, peer := range region.GetPeers() {
+		switch peer.GetId() {
+		case witnessPeer:
+			require.True(t, peer.GetIsWitness())
+		case incomingPeer:
+			require.Equal(t, metapb.PeerRole_IncomingVoter, peer.GetRole())
+		}
+	}
+
+	sendRawGet := func(region *metapb.Region, peer *metapb.Peer, addr string) *errorpb.Error {
+		req := tikvrpc.NewRequest(tikvrpc.CmdRawGet, &kvrpcpb.RawGetRequest{Key: []byte("a")})
+		require.Nil(t, tikvrpc.SetContext(req, region, peer))
+		resp, err := client.SendRequest(context.Background(), addr, req, time.Second)
+		require.Nil(t, err)
+		regionErr, err := resp.GetRegionError()
+		require.Nil(t, err)
+		return regionErr
+	}
+	regionErr := sendRawGet(region, &metapb.Peer{Id: witnessPeer, StoreId: witnessStore}, "witness")
+	require.NotNil(t, regionErr.GetIsWitness())
+	require.Equal(t, regionID, regionErr.GetIsWitness().GetRegionId())
+
+	region, leader, _, _ := cluster.GetRegionByKey([]byte("a"))
+	require.Nil(t, sendRawGet(region, leader, cluster.GetStore(leader.GetStoreId()).GetAddress()))
+
+	// Leave the joint state and turn the witness into a normal peer.
+	cluster.ChangePeerRole(regionID, incomingPeer, metapb.PeerRole_Voter)
+	cluster.SwitchWitness(regionID, witnessPeer, false)
+	region, _ = cluster.GetRegion(regionID)
+	require.Equal(t, confVer+4, region.GetRegionEpoch().GetConfVer())
+	for _, peer := range region.GetPeers() {
+		require.False(t, peer.GetIsWitness())
+		require.Equal(t, metapb.PeerRole_Voter, peer.GetRole())
+	}
+	regionErr = sendRawGet(region, &metapb.Peer{Id: witnessPeer, StoreId: witnessStore}, "witness")
+	require.NotNil(t, regionErr.GetNotLeader())
+}
+
+func TestGCOnSafePointUpdate(t *testing.T) {
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	defer store.Close()
+	cluster := NewCluster(store)
+	BootstrapWithSingleStore(cluster)
+	pdClient := NewPDClient(cluster)
+	ctx := context.Background()
+
+	mustPutOK(t, store, "k1", "v1", 1, 2)
+	mustPutOK(t, store, "k1", "v2", 11, 12)
+	mustRollbackOK(t, store, [][]byte{[]byte("k1")}, 21)
+	mustPutOK(t, store, "k1", "v3", 31, 32)
+
+	// GC is not triggered by default.
+	safePoint, err := pdClient.UpdateGCSafePoint(ctx, 10)
+	require.Nil(t, err)
+	require.Equal(t, uint64(10), safePoint)
+	mustGetOK(t, store, "k1", 5, "v1")
+
+	cluster.SetGCOnSafePointUpdate(true)
+	safePoint, err = pdClient.UpdateGCSafePoint(ctx, 25)
+	require.Nil(t, err)
+	require.Equal(t, uint64(25), safePoint)
+	mustGetNone(t, store, "k1", 5)
+	mustGetOK(t, store, "k1", 15, "v2")
+	mustGetOK(t, store, "k1", 35, "v3")
+	// The rollback record and the version under the latest one are removed.
+	require.Len(t, store.MvccGetByKey([]byte("k1")).GetWrites(), 2)
+
+	// A lock under the safe point blocks GC.
+	mustPrewriteOK(t, store, []*kvrpcpb.Mutation{{Op: kvrpcpb.Op_Put, Key: []byte("k2"), Value: []byte("v")}}, "k2", 40)
+	_, err = pdClient.UpdateGCSafePoint(ctx, 50)
+	require.NotNil(t, err)
+	safePoint, err = pdClient.UpdateGCSafePoint(ctx, 0)
+	require.Nil(t, err)
+	require.Equal(t, uint64(25), safePoint)
+}
+
+func TestGCOnSafePointUpdateRegionStore(t *testing.T) {
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	defer store.Close()
+	regionStore, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	defer regionStore.Close()
+	cluster := NewCluster(store)
+	_, _, regionID := BootstrapWithSingleStore(cluster)
+	cluster.SetRegionMVCCStore(regionID, regionStore)
+	pdClient := NewPDClient(cluster)
+	ctx := context.Background()
+
+	mustPutOK(t, regionStore, "k1", "v1", 1, 2)
+	mustPutOK(t, regionStore, "k1", "v2", 11, 12)
+
+	cluster.SetGCOnSafePointUpdate(true)
+	_, err = pdClient.UpdateGCSafePoint(ctx, 15)
+	require.Nil(t, err)
+	mustGetNone(t, regionStore, "k1", 5)
+	mustGetOK(t, regionStore, "k1", 15, "v2")
+
+	// The read-only stores are skipped.
+	cluster.SetRegionMVCCStore(regionID, NewReadOnlyMVCCStore(regionStore))
+	_, err = pdClient.UpdateGCSafePoint(ctx, 20)
+	require.Nil(t, err)
+}
+
+func TestBucketStats(t *testing.T) {
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	defer store.Close()
+	cluster := NewCluster(store)
+	_, _, regionID, _ := BootstrapWithMultiStores(cluster, 1)
+	require.False(t, cluster.UpdateBucketStats(regionID))
+
+	mustPrewriteWithTTLOK(t, store, []*kvrpcpb.Mutation{{Op: kvrpcpb.Op_Put, Key: []byte("a1"), Value: []byte("v1")}}, "a1", 10, 3000)
+	require.Nil(t, store.Commit([][]byte{[]byte("a1")}, 10, 11))
+	store.RawPut("", []byte("b1"), []byte("value"))
+	store.RawPut("", []byte("b2"), []byte("value"))
+
+	cluster.SplitRegionBuckets(regionID, [][]byte{{}, []byte("b"), []byte("c"), {}}, 1)
+	require.True(t, cluster.UpdateBucketStats(regionID))
+	region, _, buckets, _ := cluster.GetRegionByKey([]byte("a"))
+	require.Equal(t, regionID, region.GetId())
+	stats := buckets.GetStats()
+	require.Equal(t, []uint64{1, 2, 0}, stats.GetWriteKeys())
+	require.Equal(t, []uint64{4, 14, 0}, stats.GetWriteBytes())
+	require.Equal(t, stats.GetWriteKeys(), stats.GetReadKeys())
+	require.Equal(t, stats.GetWriteBytes(), stats.GetReadBytes())
+	require.Equal(t, uint64(bucketStatsPeriodMs), buckets.GetPeriodInMs())
+
+	// Splitting the buckets again resets the stats.
+	cluster.SplitRegionBuckets(regionID, [][]byte{{}, []byte("c"), {}}, 2)
+	_, _, buckets, _ = cluster.GetRegionByKey([]byte("a"))
+	require.Nil(t, buckets.GetStats())
+}
+
+func TestAdvanceClock(t *testing.T) {
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	cluster := NewCluster(store)
+	BootstrapWithSingleStore(cluster)
+	pdClient := NewPDClient(cluster)
+	client := NewRPCClient(cluster, store, nil)
+	defer client.Close()
+	ctx := context.Background()
+
+	getTS := func() uint64 {
+		physical, logical, err := pdClient.GetTS(ctx)
+		require.Nil(t, err)
+		return oracle.ComposeTS(physical, logical)
+	}
+	startTS := getTS()
+	mustPrewriteWithTTLOK(t, store, []*kvrpcpb.Mutation{{Op: kvrpcpb.Op_Put, Key: []byte("k"), Value: []byte("v")}}, "k", startTS, uint64(time.Minute/time.Millisecond))
+
+	checkTxnStatus := func(currentTS uint64) *kvrpcpb.CheckTxnStatusResponse {
+		region, leader, _, _ := cluster.GetRegionByKey([]byte("k"))
+		req := tikvrpc.NewRequest(tikvrpc.CmdCheckTxnStatus, &kvrpcpb.CheckTxnStatusRequest{
+			PrimaryKey: []byte("k"),
+			LockTs:     startTS,
+			CurrentTs:  currentTS,
+		})
+		require.Nil(t, tikvrpc.SetContext(req, region, leader))
+		resp, err := client.SendRequest(ctx, cluster.GetStore(leader.GetStoreId()).GetAddress(), req, time.Second)
+		require.Nil(t, err)
+		return resp.Resp.(*kvrpcpb.CheckTxnStatusResponse)
+	}
+	resp := checkTxnStatus(getTS())
+	require.NotEqual(t, kvrpcpb.Action_TTLExpireRollback, resp.GetAction())
+	require.NotZero(t, resp.GetLockTtl())
+
+	// The lock expires after the clock is advanced, even if the request carries
+	// an older current ts.
+	cluster.AdvanceClock(2 * time.Minute)
+	require.Greater(t, oracle.GetTimeFromTS(getTS()), time.Now().Add(time.Minute))
+	resp = checkTxnStatus(startTS)
+	require.Equal(t, kvrpcpb.Action_TTLExpireRollback, resp.GetAction())
+	mustGetNone(t, store, "k", getTS())
+}
+
+func TestRequestLog(t *testing.T) {
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	cluster := NewCluster(store)
+	storeIDs, peerIDs, _, _ := BootstrapWithMultiStores(cluster, 2)
+	client := NewRPCClient(cluster, store, nil)
+	defer client.Close()
+
+	region, _, _, _ := cluster.GetRegionByKey([]byte("a"))
+	send := func(i int, req *tikvrpc.Request) {
+		require.Nil(t, tikvrpc.SetContext(req, region, &metapb.Peer{Id: peerIDs[i], StoreId: storeIDs[i]}))
+		_, err := client.SendRequest(context.Background(), cluster.GetStore(storeIDs[i]).GetAddress(), req, time.Second)
+		require.Nil(t, err)
+	}
+
+	// The requests before enabling aren't recorded.
+	send(0, tikvrpc.NewRequest(tikvrpc.CmdRawGet, &kvrpcpb.RawGetRequest{Key: []byte("a")}))
+	cluster.EnableRequestLog()
+	send(0, tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: []byte("a"), Version: 10}))
+	send(1, tikvrpc.NewReplicaReadRequest(tikvrpc.CmdScan, &kvrpcpb.ScanRequest{StartKey: []byte("a"), EndKey: []byte("c"), Version: 20}, kv.ReplicaReadFollower, nil))
+
+	entries := cluster.DrainRequestLog()
+	require.Equal(t, []RequestLogEntry{
+		{Type: tikvrpc.CmdGet, RegionID: region.GetId(), StoreID: storeIDs[0], Keys: [][]byte{[]byte("a")}, TS: 10},
+		{Type: tikvrpc.CmdScan, RegionID: region.GetId(), StoreID: storeIDs[1], StartKey: []byte("a"), EndKey: []byte("c"), TS: 20, ReplicaRead: true},
+	}, entries)
+	require.Empty(t, cluster.DrainRequestLog())
+
+	cluster.DisableRequestLog()
+	send(0, tikvrpc.NewRequest(tikvrpc.CmdRawGet, &kvrpcpb.RawGetRequest{Key: []byte("a")}))
+	require.Empty(t, cluster.DrainRequestLog())
+}
+
+func TestStopAndStartStore(t *testing.T) {
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	cluster := NewCluster(store)
+	storeID, _, _ := BootstrapWithSingleStore(cluster)
+	client := NewRPCClient(cluster, store, nil)
+	defer client.Close()
+	addr := cluster.GetStore(storeID).GetAddress()
+
+	send := func(req *tikvrpc.Request) (*tikvrpc.Response, error) {
+		region, leader, _, _ := cluster.GetRegionByKey([]byte("a"))
+		require.Nil(t, tikvrpc.SetContext(req, region, leader))
+		return client.SendRequest(context.Background(), addr, req, time.Second)
+	}
+	get := func() (*tikvrpc.Response, error) {
+		return send(tikvrpc.NewRequest(tikvrpc.CmdRawGet, &kvrpcpb.RawGetRequest{Key: []byte("a")}))
+	}
+	_, err = send(tikvrpc.NewRequest(tikvrpc.CmdRawPut, &kvrpcpb.RawPutRequest{Key: []byte("a"), Value: []byte("v")}))
+	require.Nil(t, err)
+
+	// The stopped store refuses the connections, but it's still known by PD.
+	cluster.StopStore(storeID)
+	_, err = get()
+	require.ErrorContains(t, err, "connection refused")
+	pdCli := NewPDClient(cluster)
+	defer pdCli.Close()
+	meta, err := pdCli.GetStore(context.Background(), storeID)
+	require.Nil(t, err)
+	require.Equal(t, metapb.StoreState_Up, meta.GetState())
+
+	// The data is retained after the store is started again.
+	cluster.StartStore(storeID)
+	resp, err := get()
+	require.Nil(t, err)
+	require.Equal(t, []byte("v"), resp.Resp.(*kvrpcpb.RawGetResponse).GetValue())
+
+	cluster.RemoveStore(storeID)
+	_, err = get()
+	require.ErrorContains(t, err, "connect fail")
+}
@@ -108,12 +108,21 @@ type ErrConflict struct {
 	ConflictCommitTS uint64
 	Key              []byte
 	CanForceLock     bool
+	Reason           kvrpcpb.WriteConflict_Reason
 }
 
 func (e *ErrConflict) Error() string {
 	return "write conflict"
 }
 
+// withConflictReason sets the reason of err if it's a write conflict.
+func withConflictReason(err error, reason kvrpcpb.WriteConflict_Reason) error {
+	if conflict, ok := err.(*ErrConflict); ok {
+		conflict.Reason = reason
+	}
+	return err
+}
+
 // ErrDeadlock is returned when deadlock error is detected.
 type ErrDeadlock struct {
 	LockTS         uint64
@@ -0,0 +1,143 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pingcap/kvproto/pkg/errorpb"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+type flashbackKey struct {
+	regionID uint64
+	startTS  uint64
+}
+
+// regionFlashbacks tracks the regions prepared for the flashback, which reject
+// all the requests except the flashback ones like TiKV.
+type regionFlashbacks struct {
+	sync.Mutex
+	// prepared maps the regions to the start ts of their flashbacks.
+	prepared map[uint64]uint64
+	// finished records the flashbacks done, so the retried requests succeed.
+	finished map[flashbackKey]struct{}
+}
+
+// RegionInFlashback returns the start ts of the flashback the region is
+// prepared for, and false if it's not in the flashback progress.
+func (c *Cluster) RegionInFlashback(regionID uint64) (uint64, bool) {
+	c.flashbacks.Lock()
+	defer c.flashbacks.Unlock()
+	startTS, ok := c.flashbacks.prepared[regionID]
+	return startTS, ok
+}
+
+// checkRegionFlashback returns a FlashbackInProgress error if the request is
+// sent to a region in the flashback progress, except the flashback requests.
+func (c *Cluster) checkRegionFlashback(req *tikvrpc.Request) *errorpb.Error {
+	if req.Type == tikvrpc.CmdPrepareFlashbackToVersion || req.Type == tikvrpc.CmdFlashbackToVersion {
+		return nil
+	}
+	regionID := req.Context.GetRegionId()
+	startTS, ok := c.RegionInFlashback(regionID)
+	if !ok {
+		return nil
+	}
+	return &errorpb.Error{
+		Message: fmt.Sprintf("region %d is in flashback progress", regionID),
+		FlashbackInProgress: &errorpb.FlashbackInProgress{
+			RegionId:         regionID,
+			FlashbackStartTs: startTS,
+		},
+	}
+}
+
+// prepareFlashback marks the region in the flashback progress. It fails if the
+// region is prepared for another flashback.
+func (c *Cluster) prepareFlashback(regionID, startTS uint64) *errorpb.Error {
+	c.flashbacks.Lock()
+	defer c.flashbacks.Unlock()
+	if prepared, ok := c.flashbacks.prepared[regionID]; ok && prepared != startTS {
+		return &errorpb.Error{
+			Message: fmt.Sprintf("region %d is in flashback progress", regionID),
+			FlashbackInProgress: &errorpb.FlashbackInProgress{
+				RegionId:         regionID,
+				FlashbackStartTs: prepared,
+			},
+		}
+	}
+	if c.flashbacks.prepared == nil {
+		c.flashbacks.prepared = make(map[uint64]uint64)
+	}
+	c.flashbacks.prepared[regionID] = startTS
+	return nil
+}
+
+// checkFlashbackPrepared returns a FlashbackNotPrepared error if the region is
+// neither prepared for nor finished the flashback.
+func (c *Cluster) checkFlashbackPrepared(regionID, startTS uint64) *errorpb.Error {
+	c.flashbacks.Lock()
+	defer c.flashbacks.Unlock()
+	if prepared, ok := c.flashbacks.prepared[regionID]; ok && prepared == startTS {
+		return nil
+	}
+	if _, ok := c.flashbacks.finished[flashbackKey{regionID, startTS}]; ok {
+		return nil
+	}
+	return &errorpb.Error{
+		Message:              fmt.Sprintf("region %d is not prepared for the flashback", regionID),
+		FlashbackNotPrepared: &errorpb.FlashbackNotPrepared{RegionId: regionID},
+	}
+}
+
+// finishFlashback ends the flashback progress of the region.
+func (c *Cluster) finishFlashback(regionID, startTS uint64) {
+	c.flashbacks.Lock()
+	defer c.flashbacks.Unlock()
+	delete(c.flashbacks.prepared, regionID)
+	if c.flashbacks.finished == nil {
+		c.flashbacks.finished = make(map[flashbackKey]struct{})
+	}
+	c.flashbacks.finished[flashbackKey{regionID, startTS}] = struct{}{}
+}
+
+func (h kvHandler) handleKvPrepareFlashbackToVersion(req *kvrpcpb.PrepareFlashbackToVersionRequest) *kvrpcpb.PrepareFlashbackToVersionResponse {
+	if err := h.cluster.prepareFlashback(req.GetContext().GetRegionId(), req.GetStartTs()); err != nil {
+		return &kvrpcpb.PrepareFlashbackToVersionResponse{RegionError: err}
+	}
+	return &kvrpcpb.PrepareFlashbackToVersionResponse{}
+}
+
+func (h kvHandler) handleKvFlashbackToVersion(req *kvrpcpb.FlashbackToVersionRequest) *kvrpcpb.FlashbackToVersionResponse {
+	regionID := req.GetContext().GetRegionId()
+	if err := h.cluster.checkFlashbackPrepared(regionID, req.GetStartTs()); err != nil {
+		return &kvrpcpb.FlashbackToVersionResponse{RegionError: err}
+	}
+	var resp kvrpcpb.FlashbackToVersionResponse
+	flashback, ok := h.mvccStore.(MVCCFlashback)
+	if !ok {
+		resp.Error = "flashback is not supported"
+		return &resp
+	}
+	if err := flashback.Flashback(req.GetStartKey(), req.GetEndKey(), req.GetVersion(), req.GetStartTs(), req.GetCommitTs()); err != nil {
+		resp.Error = err.Error()
+		return &resp
+	}
+	h.cluster.finishFlashback(regionID, req.GetStartTs())
+	return &resp
+}
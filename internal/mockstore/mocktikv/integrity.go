@@ -0,0 +1,143 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pkg/errors"
+)
+
+// CheckIntegrity verifies the topology of the cluster is possible in a real
+// TiKV cluster, so that a test manipulating the topology by hand fails early
+// instead of observing undefined behaviors. It checks:
+//   - the regions cover the whole key space without gaps or overlaps,
+//   - the epoch of every region never decreases since the last check,
+//   - the leader of every region, if any, is a voter peer on a store that is
+//     not a tombstone, and the peer is not down. A stopped store can still hold
+//     leaders, which simulates a failed TiKV.
+//
+// It returns all the violations found in one error.
+func (c *Cluster) CheckIntegrity() error {
+	c.Lock()
+	defer c.Unlock()
+
+	var violations []string
+	violations = append(violations, c.checkRegionRanges()...)
+	violations = append(violations, c.checkRegionEpochs()...)
+	violations = append(violations, c.checkRegionLeaders()...)
+	if len(violations) == 0 {
+		return nil
+	}
+	return errors.Errorf("mocktikv: cluster integrity violated:\n%s", strings.Join(violations, "\n"))
+}
+
+func (c *Cluster) checkRegionRanges() []string {
+	if len(c.regions) == 0 {
+		return nil
+	}
+	regions := make([]*metapb.Region, 0, len(c.regions))
+	for _, r := range c.regions {
+		regions = append(regions, r.Meta)
+	}
+	sort.Slice(regions, func(i, j int) bool {
+		return bytes.Compare(regions[i].GetStartKey(), regions[j].GetStartKey()) < 0
+	})
+
+	var violations []string
+	if first := regions[0]; len(first.GetStartKey()) != 0 {
+		violations = append(violations, fmt.Sprintf("gap before region %d, which starts at %x", first.GetId(), first.GetStartKey()))
+	}
+	for i := 1; i < len(regions); i++ {
+		prev, cur := regions[i-1], regions[i]
+		if len(prev.GetEndKey()) == 0 {
+			violations = append(violations, fmt.Sprintf("region %d overlaps region %d, which is unbounded", cur.GetId(), prev.GetId()))
+			continue
+		}
+		switch cmp := bytes.Compare(prev.GetEndKey(), cur.GetStartKey()); {
+		case cmp < 0:
+			violations = append(violations, fmt.Sprintf("gap between region %d and region %d: [%x, %x)",
+				prev.GetId(), cur.GetId(), prev.GetEndKey(), cur.GetStartKey()))
+		case cmp > 0:
+			violations = append(violations, fmt.Sprintf("region %d overlaps region %d: [%x, %x)",
+				prev.GetId(), cur.GetId(), cur.GetStartKey(), prev.GetEndKey()))
+		}
+	}
+	if last := regions[len(regions)-1]; len(last.GetEndKey()) != 0 {
+		violations = append(violations, fmt.Sprintf("gap after region %d, which ends at %x", last.GetId(), last.GetEndKey()))
+	}
+	for _, r := range regions {
+		if len(r.GetEndKey()) != 0 && bytes.Compare(r.GetStartKey(), r.GetEndKey()) >= 0 {
+			violations = append(violations, fmt.Sprintf("region %d has an empty range [%x, %x)", r.GetId(), r.GetStartKey(), r.GetEndKey()))
+		}
+	}
+	return violations
+}
+
+func (c *Cluster) checkRegionEpochs() []string {
+	if c.checkedEpochs == nil {
+		c.checkedEpochs = make(map[uint64]*metapb.RegionEpoch, len(c.regions))
+	}
+	var violations []string
+	for id, r := range c.regions {
+		epoch := r.Meta.GetRegionEpoch()
+		if epoch == nil {
+			violations = append(violations, fmt.Sprintf("region %d has no epoch", id))
+			continue
+		}
+		if last, ok := c.checkedEpochs[id]; ok &&
+			(epoch.GetConfVer() < last.GetConfVer() || epoch.GetVersion() < last.GetVersion()) {
+			violations = append(violations, fmt.Sprintf("epoch of region %d decreases from %v to %v", id, last, epoch))
+		}
+		c.checkedEpochs[id] = &metapb.RegionEpoch{ConfVer: epoch.GetConfVer(), Version: epoch.GetVersion()}
+	}
+	sort.Strings(violations)
+	return violations
+}
+
+func (c *Cluster) checkRegionLeaders() []string {
+	var violations []string
+	for id, r := range c.regions {
+		if r.leader == 0 {
+			continue
+		}
+		leader := r.leaderPeer()
+		if leader == nil {
+			violations = append(violations, fmt.Sprintf("leader %d of region %d is not a peer of it", r.leader, id))
+			continue
+		}
+		if role := leader.GetRole(); role != metapb.PeerRole_Voter && role != metapb.PeerRole_IncomingVoter {
+			violations = append(violations, fmt.Sprintf("leader %d of region %d is a %s", leader.GetId(), id, role))
+		}
+		if leader.GetIsWitness() {
+			violations = append(violations, fmt.Sprintf("leader %d of region %d is a witness", leader.GetId(), id))
+		}
+		if _, down := c.downPeers[leader.GetId()]; down {
+			violations = append(violations, fmt.Sprintf("leader %d of region %d is down", leader.GetId(), id))
+		}
+		store := c.stores[leader.GetStoreId()]
+		if store == nil {
+			violations = append(violations, fmt.Sprintf("leader %d of region %d is on store %d, which does not exist", leader.GetId(), id, leader.GetStoreId()))
+		} else if store.meta.GetState() == metapb.StoreState_Tombstone {
+			violations = append(violations, fmt.Sprintf("leader %d of region %d is on store %d, which is a tombstone", leader.GetId(), id, leader.GetStoreId()))
+		}
+	}
+	sort.Strings(violations)
+	return violations
+}
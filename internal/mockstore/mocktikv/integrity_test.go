@@ -0,0 +1,66 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"testing"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckIntegrity(t *testing.T) {
+	newCluster := func() (*Cluster, []uint64, []uint64, uint64) {
+		store, err := NewMVCCLevelDB("")
+		require.NoError(t, err)
+		t.Cleanup(func() { store.Close() })
+		cluster := NewCluster(store)
+		storeIDs, peerIDs, regionID, _ := BootstrapWithMultiStores(cluster, 3)
+		return cluster, storeIDs, peerIDs, regionID
+	}
+
+	cluster, _, _, regionID := newCluster()
+	require.NoError(t, cluster.CheckIntegrity())
+	newRegionID, newPeerIDs := cluster.AllocID(), cluster.AllocIDs(3)
+	cluster.Split(regionID, newRegionID, []byte("b"), newPeerIDs, newPeerIDs[0])
+	require.NoError(t, cluster.CheckIntegrity())
+	cluster.Merge(regionID, newRegionID)
+	require.NoError(t, cluster.CheckIntegrity())
+
+	// An overlapping region, whose epoch also decreases.
+	cluster, storeIDs, peerIDs, regionID := newCluster()
+	cluster.Split(regionID, cluster.AllocID(), []byte("b"), cluster.AllocIDs(3), 0)
+	require.NoError(t, cluster.CheckIntegrity())
+	cluster.PutRegion(regionID, 0, 0, storeIDs, peerIDs, peerIDs[0])
+	err := cluster.CheckIntegrity()
+	require.ErrorContains(t, err, "overlaps")
+	require.ErrorContains(t, err, "decreases")
+
+	cluster, storeIDs, peerIDs, regionID = newCluster()
+	cluster.ChangePeerRole(regionID, peerIDs[0], metapb.PeerRole_Learner)
+	require.ErrorContains(t, cluster.CheckIntegrity(), "is a Learner")
+
+	cluster, storeIDs, peerIDs, regionID = newCluster()
+	cluster.StopStore(storeIDs[0])
+	require.NoError(t, cluster.CheckIntegrity())
+	cluster.MarkTombstone(storeIDs[0])
+	require.ErrorContains(t, cluster.CheckIntegrity(), "tombstone")
+	cluster.ChangeLeader(regionID, peerIDs[1])
+	require.NoError(t, cluster.CheckIntegrity())
+	cluster.MarkPeerDown(peerIDs[1])
+	require.ErrorContains(t, cluster.CheckIntegrity(), "is down")
+	cluster.GiveUpLeader(regionID)
+	require.NoError(t, cluster.CheckIntegrity())
+}
@@ -0,0 +1,100 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"sync"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/kvproto/pkg/errorpb"
+	"github.com/pingcap/kvproto/pkg/metapb"
+)
+
+type scheduledMerge struct {
+	target    uint64
+	source    uint64
+	remaining int
+}
+
+type regionMerges struct {
+	sync.Mutex
+	pending []*scheduledMerge
+	// mergedInto maps the regions merged by the schedules to the regions they
+	// are merged into.
+	mergedInto map[uint64]uint64
+}
+
+// ScheduleMerge merges regionB into regionA, whose key ranges should be
+// adjacent with regionA in the front, when the stores receive the
+// (afterNRequests+1)-th request to either of them from now on. The request
+// triggering the merge, and the following requests carrying the old epochs,
+// observe EpochNotMatch errors with the current regions attached, including
+// the requests to regionB which no longer exists, to test how the client
+// handles the merges happening while the requests are in flight.
+func (c *Cluster) ScheduleMerge(regionA, regionB uint64, afterNRequests int) {
+	c.merges.Lock()
+	defer c.merges.Unlock()
+	c.merges.pending = append(c.merges.pending, &scheduledMerge{
+		target:    regionA,
+		source:    regionB,
+		remaining: afterNRequests,
+	})
+}
+
+// onRegionRequest counts the request down for the scheduled merges of the
+// region, and runs the merges that are due.
+func (c *Cluster) onRegionRequest(regionID uint64) {
+	c.merges.Lock()
+	defer c.merges.Unlock()
+	pending := c.merges.pending[:0]
+	for _, m := range c.merges.pending {
+		if m.target != regionID && m.source != regionID {
+			pending = append(pending, m)
+			continue
+		}
+		if m.remaining > 0 {
+			m.remaining--
+			pending = append(pending, m)
+			continue
+		}
+		c.Merge(m.target, m.source)
+		if c.merges.mergedInto == nil {
+			c.merges.mergedInto = make(map[uint64]uint64)
+		}
+		c.merges.mergedInto[m.source] = m.target
+	}
+	c.merges.pending = pending
+}
+
+// mergedRegionError returns the EpochNotMatch error for the request to a
+// region merged by ScheduleMerge, or nil if the region isn't merged.
+func (c *Cluster) mergedRegionError(regionID uint64) *errorpb.Error {
+	c.merges.Lock()
+	target, ok := c.merges.mergedInto[regionID]
+	c.merges.Unlock()
+	if !ok {
+		return nil
+	}
+	region, _ := c.GetRegion(target)
+	if region == nil {
+		return nil
+	}
+	return &errorpb.Error{
+		Message: *proto.String("epoch not match"),
+		EpochNotMatch: &errorpb.EpochNotMatch{
+			CurrentRegions: []*metapb.Region{region},
+		},
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+func TestScheduleMerge(t *testing.T) {
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	cluster := NewCluster(store)
+	storeID, _, regionA := BootstrapWithSingleStore(cluster)
+	regionB, peerB := cluster.AllocID(), cluster.AllocID()
+	cluster.Split(regionA, regionB, []byte("m"), []uint64{peerB}, peerB)
+	client := NewRPCClient(cluster, store, nil)
+	defer client.Close()
+	addr := cluster.GetStore(storeID).GetAddress()
+	metaA, _ := cluster.GetRegion(regionA)
+	metaB, _ := cluster.GetRegion(regionB)
+
+	get := func(region *metapb.Region, key string) *tikvrpc.Response {
+		req := tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: []byte(key), Version: 1})
+		require.Nil(t, tikvrpc.SetContext(req, region, region.GetPeers()[0]))
+		resp, err := client.SendRequest(context.Background(), addr, req, time.Second)
+		require.Nil(t, err)
+		return resp
+	}
+
+	cluster.ScheduleMerge(regionA, regionB, 1)
+	// The first request is served before the merge.
+	regionErr, err := get(metaB, "n").GetRegionError()
+	require.Nil(t, err)
+	require.Nil(t, regionErr)
+
+	// The second request triggers the merge and observes it.
+	regionErr, err = get(metaA, "a").GetRegionError()
+	require.Nil(t, err)
+	require.NotNil(t, regionErr.GetEpochNotMatch())
+	merged := regionErr.GetEpochNotMatch().GetCurrentRegions()[0]
+	require.Equal(t, regionA, merged.GetId())
+	require.Empty(t, merged.GetEndKey())
+	require.Greater(t, merged.GetRegionEpoch().GetVersion(), metaA.GetRegionEpoch().GetVersion())
+
+	// The requests to the region merged away observe the merged region too.
+	regionErr, err = get(metaB, "n").GetRegionError()
+	require.Nil(t, err)
+	require.Equal(t, []*metapb.Region{merged}, regionErr.GetEpochNotMatch().GetCurrentRegions())
+
+	// The requests with the new epoch are served.
+	regionErr, err = get(merged, "n").GetRegionError()
+	require.Nil(t, err)
+	require.Nil(t, regionErr)
+}
@@ -39,6 +39,7 @@ import (
 	"encoding/binary"
 	"io"
 	"math"
+	"time"
 
 	"github.com/google/btree"
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
@@ -297,6 +298,14 @@ type RawKV interface {
 	RawDeleteRange(cf string, startKey, endKey []byte)
 	RawCompareAndSwap(cf string, key, expectedValue, newvalue []byte) ([]byte, bool, error)
 	RawChecksum(cf string, startKey, endKey []byte) (uint64, uint64, uint64, error)
+	// RawPutWithTTL puts the key which expires at expireAt, it never expires if expireAt is zero.
+	RawPutWithTTL(cf string, key, value []byte, expireAt time.Time)
+	// RawBatchPutWithTTL puts the keys which expire at expireAts, expireAts is ignored if it's nil.
+	RawBatchPutWithTTL(cf string, keys, values [][]byte, expireAts []time.Time)
+	// RawGetKeyTTL returns when the key expires, the zero time if it never expires.
+	RawGetKeyTTL(cf string, key []byte) (expireAt time.Time, found bool)
+	// RawExpire deletes the keys expired not after now.
+	RawExpire(cf string, now time.Time)
 }
 
 // MVCCDebugger is for debugging.
@@ -305,6 +314,13 @@ type MVCCDebugger interface {
 	MvccGetByKey(key []byte) *kvrpcpb.MvccInfo
 }
 
+// MVCCFlashback flashes the data back to an old version.
+type MVCCFlashback interface {
+	// Flashback rolls back the locks in [startKey, endKey), and writes the
+	// values of the keys at version as a new version at commitTS.
+	Flashback(startKey, endKey []byte, version, startTS, commitTS uint64) error
+}
+
 // Pair is a KV pair read from MvccStore or an error if any occurs.
 type Pair struct {
 	Key   []byte
@@ -0,0 +1,40 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func FuzzMvccKey(f *testing.F) {
+	f.Add([]byte("key"), uint64(0), []byte{})
+	f.Add([]byte{}, lockVer, []byte{0, 0, 0, 0, 0, 0, 0, 0, 247, 1})
+	f.Fuzz(func(t *testing.T, key []byte, ver uint64, malformed []byte) {
+		decoded, decodedVer, err := mvccDecode(mvccEncode(key, ver))
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(key, decoded))
+		require.Equal(t, ver, decodedVer)
+
+		if len(key) > 0 {
+			require.True(t, bytes.Equal(key, NewMvccKey(key).Raw()))
+		}
+
+		// Malformed keys must be rejected instead of panicking.
+		_, _, _ = mvccDecode(malformed)
+	})
+}
@@ -39,6 +39,7 @@ import (
 	"hash/crc64"
 	"math"
 	"sync"
+	"time"
 
 	"github.com/dgryski/go-farm"
 	"github.com/pingcap/goleveldb/leveldb"
@@ -95,6 +96,8 @@ type MVCCLevelDB struct {
 	// then write, another write may happen during it, so this lock is necessory.
 	mu               sync.RWMutex
 	deadlockDetector *deadlock.Detector
+	// rawExpires is when the raw keys put with ttls expire, by the column families and the keys.
+	rawExpires map[string]map[string]time.Time
 }
 
 const lockVer uint64 = math.MaxUint64
@@ -400,11 +403,11 @@ func (mvcc *MVCCLevelDB) Scan(startKey, endKey []byte, limit int, startTS uint64
 	defer mvcc.mu.RUnlock()
 
 	iter, currKey, err := newScanIterator(mvcc.getDB(""), startKey, endKey)
-	defer iter.Release()
 	if err != nil {
 		logutil.BgLogger().Error("scan new iterator fail", zap.Error(err))
 		return nil
 	}
+	defer iter.Release()
 
 	ok := true
 	var pairs []Pair
@@ -439,11 +442,17 @@ func (mvcc *MVCCLevelDB) ReverseScan(startKey, endKey []byte, limit int, startTS
 	mvcc.mu.RLock()
 	defer mvcc.mu.RUnlock()
 
-	var mvccEnd []byte
+	var mvccStart, mvccEnd []byte
+	if len(startKey) != 0 {
+		// Bound the iterator by the start key as well, otherwise the keys
+		// before the range are iterated when the range has less than limit keys.
+		mvccStart = mvccEncode(startKey, lockVer)
+	}
 	if len(endKey) != 0 {
 		mvccEnd = mvccEncode(endKey, lockVer)
 	}
 	iter := mvcc.getDB("").NewIterator(&util.Range{
+		Start: mvccStart,
 		Limit: mvccEnd,
 	}, nil)
 	defer iter.Release()
@@ -674,6 +683,7 @@ func (mvcc *MVCCLevelDB) pessimisticLockMutation(batch *leveldb.Batch, mutation
 	// It's also possible that the key is already locked by the same transaction. Also do the conflict check to
 	// provide an idempotent result.
 	val, err := checkConflictValue(iter, mutation, forUpdateTS, startTS, true, kvrpcpb.AssertionLevel_Off, lctx.LockOnlyIfExists, lctx.WakeUpMode == kvrpcpb.PessimisticLockWakeUpMode_WakeUpModeForceLock)
+	err = withConflictReason(err, kvrpcpb.WriteConflict_PessimisticRetry)
 	if err != nil {
 		if conflict, ok := err.(*ErrConflict); lctx.WakeUpMode == kvrpcpb.PessimisticLockWakeUpMode_WakeUpModeForceLock && ok && conflict.CanForceLock {
 			lctx.results = append(lctx.results, &kvrpcpb.PessimisticLockKeyResult{
@@ -990,6 +1000,16 @@ func checkConflictValue(iter *Iterator, m *kvrpcpb.Mutation, forUpdateTS uint64,
 	return nil, writeConflictErr
 }
 
+// prewriteConflictReason returns the reason of the write conflicts found in
+// prewrite, which are found by the lazy uniqueness checks if the constraints
+// are checked in prewrite.
+func prewriteConflictReason(action kvrpcpb.PrewriteRequest_PessimisticAction) kvrpcpb.WriteConflict_Reason {
+	if action == kvrpcpb.PrewriteRequest_DO_CONSTRAINT_CHECK {
+		return kvrpcpb.WriteConflict_LazyUniquenessCheck
+	}
+	return kvrpcpb.WriteConflict_Optimistic
+}
+
 func prewriteMutation(db *leveldb.DB, batch *leveldb.Batch,
 	mutation *kvrpcpb.Mutation, startTS uint64,
 	primary []byte, ttl uint64, txnSize uint64,
@@ -1032,7 +1052,7 @@ func prewriteMutation(db *leveldb.DB, batch *leveldb.Batch,
 		}
 		_, err = checkConflictValue(iter, mutation, startTS, startTS, false, assertionLevel, false, false)
 		if err != nil {
-			return err
+			return withConflictReason(err, prewriteConflictReason(pessimisticAction))
 		}
 	} else {
 		if pessimisticAction == kvrpcpb.PrewriteRequest_DO_PESSIMISTIC_CHECK {
@@ -1040,7 +1060,7 @@ func prewriteMutation(db *leveldb.DB, batch *leveldb.Batch,
 		}
 		_, err = checkConflictValue(iter, mutation, startTS, startTS, false, assertionLevel, false, false)
 		if err != nil {
-			return err
+			return withConflictReason(err, prewriteConflictReason(pessimisticAction))
 		}
 	}
 
@@ -1713,6 +1733,79 @@ func (mvcc *MVCCLevelDB) GC(startKey, endKey []byte, safePoint uint64) error {
 	return mvcc.getDB("").Write(batch, nil)
 }
 
+// Flashback implements the MVCCFlashback interface.
+func (mvcc *MVCCLevelDB) Flashback(startKey, endKey []byte, version, startTS, commitTS uint64) error {
+	mvcc.mu.Lock()
+	defer mvcc.mu.Unlock()
+
+	iter, currKey, err := newScanIterator(mvcc.getDB(""), startKey, endKey)
+	defer iter.Release()
+	if err != nil {
+		return err
+	}
+
+	batch := &leveldb.Batch{}
+	for iter.Valid() {
+		key := currKey
+		lockDec := lockDecoder{expectKey: key}
+		ok, err := lockDec.Decode(iter)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if err = rollbackLock(batch, key, lockDec.lock.startTS); err != nil {
+				return err
+			}
+		}
+
+		// The values are in the descending order of their commit ts.
+		var latest, old *mvccValue
+		dec := valueDecoder{expectKey: key}
+		for iter.Valid() {
+			ok, err := dec.Decode(iter)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				if currKey, _, err = mvccDecode(iter.Key()); err != nil {
+					return err
+				}
+				break
+			}
+			if dec.value.valueType != typePut && dec.value.valueType != typeDelete {
+				continue
+			}
+			value := dec.value
+			if latest == nil {
+				latest = &value
+			}
+			if old == nil && value.commitTS <= version {
+				old = &value
+			}
+		}
+
+		if latest == nil {
+			continue
+		}
+		value := mvccValue{valueType: typeDelete, startTS: startTS, commitTS: commitTS}
+		if old != nil && old.valueType == typePut {
+			value.valueType, value.value = typePut, old.value
+		}
+		// Skip the keys unchanged since version, including the ones already
+		// flashed back by a retried request.
+		if value.valueType == latest.valueType && bytes.Equal(value.value, latest.value) {
+			continue
+		}
+		writeValue, err := value.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		batch.Put(mvccEncode(key, commitTS), writeValue)
+	}
+
+	return mvcc.getDB("").Write(batch, nil)
+}
+
 // DeleteRange implements the MVCCStore interface.
 func (mvcc *MVCCLevelDB) DeleteRange(startKey, endKey []byte) error {
 	var end []byte
@@ -1729,6 +1822,11 @@ func (mvcc *MVCCLevelDB) Close() error {
 
 // RawPut implements the RawKV interface.
 func (mvcc *MVCCLevelDB) RawPut(cf string, key, value []byte) {
+	mvcc.RawPutWithTTL(cf, key, value, time.Time{})
+}
+
+// RawPutWithTTL implements the RawKV interface.
+func (mvcc *MVCCLevelDB) RawPutWithTTL(cf string, key, value []byte, expireAt time.Time) {
 	mvcc.mu.Lock()
 	defer mvcc.mu.Unlock()
 
@@ -1743,10 +1841,16 @@ func (mvcc *MVCCLevelDB) RawPut(cf string, key, value []byte) {
 	}
 
 	tikverr.Log(db.Put(key, value, nil))
+	mvcc.setRawExpire(cf, key, expireAt)
 }
 
 // RawBatchPut implements the RawKV interface
 func (mvcc *MVCCLevelDB) RawBatchPut(cf string, keys, values [][]byte) {
+	mvcc.RawBatchPutWithTTL(cf, keys, values, nil)
+}
+
+// RawBatchPutWithTTL implements the RawKV interface.
+func (mvcc *MVCCLevelDB) RawBatchPutWithTTL(cf string, keys, values [][]byte, expireAts []time.Time) {
 	mvcc.mu.Lock()
 	defer mvcc.mu.Unlock()
 
@@ -1769,6 +1873,13 @@ func (mvcc *MVCCLevelDB) RawBatchPut(cf string, keys, values [][]byte) {
 		batch.Put(key, value)
 	}
 	tikverr.Log(db.Write(batch, nil))
+	for i, key := range keys {
+		var expireAt time.Time
+		if expireAts != nil {
+			expireAt = expireAts[i]
+		}
+		mvcc.setRawExpire(cf, key, expireAt)
+	}
 }
 
 // RawGet implements the RawKV interface.
@@ -1820,6 +1931,7 @@ func (mvcc *MVCCLevelDB) RawDelete(cf string, key []byte) {
 		return
 	}
 	tikverr.Log(db.Delete(key, nil))
+	mvcc.setRawExpire(cf, key, time.Time{})
 }
 
 // RawBatchDelete implements the RawKV interface.
@@ -1835,6 +1947,7 @@ func (mvcc *MVCCLevelDB) RawBatchDelete(cf string, keys [][]byte) {
 	batch := &leveldb.Batch{}
 	for _, key := range keys {
 		batch.Delete(key)
+		mvcc.setRawExpire(cf, key, time.Time{})
 	}
 	tikverr.Log(db.Write(batch, nil))
 }
@@ -1967,6 +2080,9 @@ func (mvcc *MVCCLevelDB) RawCompareAndSwap(cf string, key, expectedValue, newVal
 	}
 
 	oldValue, err = db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		oldValue, err = nil, nil
+	}
 	if err != nil {
 		tikverr.Log(err)
 		return nil, false, errors.WithStack(err)
@@ -1981,10 +2097,73 @@ func (mvcc *MVCCLevelDB) RawCompareAndSwap(cf string, key, expectedValue, newVal
 		tikverr.Log(err)
 		return oldValue, false, errors.WithStack(err)
 	}
+	mvcc.setRawExpire(cf, key, time.Time{})
 
 	return oldValue, true, nil
 }
 
+// RawGetKeyTTL implements the RawKV interface.
+func (mvcc *MVCCLevelDB) RawGetKeyTTL(cf string, key []byte) (time.Time, bool) {
+	mvcc.mu.Lock()
+	defer mvcc.mu.Unlock()
+
+	db := mvcc.getDB(cf)
+	if db == nil {
+		return time.Time{}, false
+	}
+	ok, err := db.Has(key, nil)
+	tikverr.Log(err)
+	if !ok {
+		return time.Time{}, false
+	}
+	return mvcc.rawExpires[rawCf(cf)][string(key)], true
+}
+
+// RawExpire implements the RawKV interface.
+func (mvcc *MVCCLevelDB) RawExpire(cf string, now time.Time) {
+	mvcc.mu.Lock()
+	defer mvcc.mu.Unlock()
+
+	expires := mvcc.rawExpires[rawCf(cf)]
+	db := mvcc.getDB(cf)
+	if len(expires) == 0 || db == nil {
+		return
+	}
+	batch := &leveldb.Batch{}
+	for key, expireAt := range expires {
+		if !expireAt.After(now) {
+			batch.Delete([]byte(key))
+			delete(expires, key)
+		}
+	}
+	if batch.Len() > 0 {
+		tikverr.Log(db.Write(batch, nil))
+	}
+}
+
+// setRawExpire records when the raw key expires, or clears it if expireAt is zero. mvcc.mu must be held.
+func (mvcc *MVCCLevelDB) setRawExpire(cf string, key []byte, expireAt time.Time) {
+	cf = rawCf(cf)
+	if expireAt.IsZero() {
+		delete(mvcc.rawExpires[cf], string(key))
+		return
+	}
+	if mvcc.rawExpires == nil {
+		mvcc.rawExpires = make(map[string]map[string]time.Time)
+	}
+	if mvcc.rawExpires[cf] == nil {
+		mvcc.rawExpires[cf] = make(map[string]time.Time)
+	}
+	mvcc.rawExpires[cf][string(key)] = expireAt
+}
+
+func rawCf(cf string) string {
+	if cf == "" {
+		return defaultCf
+	}
+	return cf
+}
+
 // doRawDeleteRange deletes all keys in a range and return the error if any.
 func (mvcc *MVCCLevelDB) doRawDeleteRange(cf string, startKey, endKey []byte) error {
 	mvcc.mu.Lock()
@@ -2002,6 +2181,7 @@ func (mvcc *MVCCLevelDB) doRawDeleteRange(cf string, startKey, endKey []byte) er
 	}, nil)
 	for iter.Next() {
 		batch.Delete(iter.Key())
+		mvcc.setRawExpire(cf, iter.Key(), time.Time{})
 	}
 
 	return db.Write(batch, nil)
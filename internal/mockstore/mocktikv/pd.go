@@ -71,6 +71,9 @@ var tsMu = struct {
 
 const defaultResourceGroupName = "default"
 
+// maxLogicalTS is the max logical part of the timestamps.
+const maxLogicalTS = 1<<18 - 1
+
 var _ pd.Client = (*pdClient)(nil)
 
 type MockPDOption func(*pdClient)
@@ -145,9 +148,13 @@ func (c *pdClient) GetTS(context.Context) (int64, int64, error) {
 	tsMu.Lock()
 	defer tsMu.Unlock()
 
-	ts := time.Now().UnixNano() / int64(time.Millisecond)
-	if tsMu.physicalTS >= ts {
+	ts := c.cluster.Now().UnixNano() / int64(time.Millisecond)
+	if tsMu.physicalTS >= ts && tsMu.logicalTS < maxLogicalTS {
 		tsMu.logicalTS++
+	} else if tsMu.physicalTS >= ts {
+		// The clocks of other clusters may be ahead, see Cluster.AdvanceClock.
+		tsMu.physicalTS++
+		tsMu.logicalTS = 0
 	} else {
 		tsMu.physicalTS = ts
 		tsMu.logicalTS = 0
@@ -172,6 +179,18 @@ func (c *pdClient) WatchGCSafePointV2(ctx context.Context, revision int64) (chan
 	panic("unimplemented")
 }
 
+// WatchStores returns a channel receiving the changes of the stores in the
+// cluster, see Cluster.WatchStores.
+func (c *pdClient) WatchStores(ctx context.Context) (<-chan StoreEvent, error) {
+	return c.cluster.WatchStores(ctx), nil
+}
+
+// WatchRegions returns a channel receiving the changes of the regions in the
+// cluster, see Cluster.WatchRegions.
+func (c *pdClient) WatchRegions(ctx context.Context) (<-chan RegionEvent, error) {
+	return c.cluster.WatchRegions(ctx), nil
+}
+
 func (c *pdClient) GetLocalTS(ctx context.Context, dcLocation string) (int64, int64, error) {
 	return c.GetTS(ctx)
 }
@@ -228,6 +247,7 @@ func (m *mockTSFuture) Wait() (int64, int64, error) {
 
 func (c *pdClient) GetRegion(ctx context.Context, key []byte, opts ...opt.GetRegionOption) (*router.Region, error) {
 	enforceCircuitBreakerFor("GetRegion", ctx)
+	c.cluster.recordPDRequest("GetRegion")
 	region, peer, buckets, downPeers := c.cluster.GetRegionByKey(key)
 	if len(opts) == 0 {
 		buckets = nil
@@ -247,6 +267,7 @@ func (c *pdClient) GetRegionFromMember(ctx context.Context, key []byte, memberUR
 
 func (c *pdClient) GetPrevRegion(ctx context.Context, key []byte, opts ...opt.GetRegionOption) (*router.Region, error) {
 	enforceCircuitBreakerFor("GetPrevRegion", ctx)
+	c.cluster.recordPDRequest("GetPrevRegion")
 	region, peer, buckets, downPeers := c.cluster.GetPrevRegionByKey(key)
 	if len(opts) == 0 {
 		buckets = nil
@@ -256,18 +277,21 @@ func (c *pdClient) GetPrevRegion(ctx context.Context, key []byte, opts ...opt.Ge
 
 func (c *pdClient) GetRegionByID(ctx context.Context, regionID uint64, opts ...opt.GetRegionOption) (*router.Region, error) {
 	enforceCircuitBreakerFor("GetRegionByID", ctx)
+	c.cluster.recordPDRequest("GetRegionByID")
 	region, peer, buckets, downPeers := c.cluster.GetRegionByID(regionID)
 	return &router.Region{Meta: region, Leader: peer, Buckets: buckets, DownPeers: downPeers}, nil
 }
 
 func (c *pdClient) ScanRegions(ctx context.Context, startKey []byte, endKey []byte, limit int, opts ...opt.GetRegionOption) ([]*router.Region, error) {
 	enforceCircuitBreakerFor("ScanRegions", ctx)
+	c.cluster.recordPDRequest("ScanRegions")
 	regions := c.cluster.ScanRegions(startKey, endKey, limit, opts...)
 	return regions, nil
 }
 
 func (c *pdClient) BatchScanRegions(ctx context.Context, keyRanges []router.KeyRange, limit int, opts ...opt.GetRegionOption) ([]*router.Region, error) {
 	enforceCircuitBreakerFor("BatchScanRegions", ctx)
+	c.cluster.recordPDRequest("BatchScanRegions")
 	if _, err := util.EvalFailpoint("mockBatchScanRegionsUnimplemented"); err == nil {
 		return nil, status.Errorf(codes.Unimplemented, "mock BatchScanRegions is not implemented")
 	}
@@ -296,6 +320,7 @@ func (c *pdClient) BatchScanRegions(ctx context.Context, keyRanges []router.KeyR
 }
 
 func (c *pdClient) GetStore(ctx context.Context, storeID uint64) (*metapb.Store, error) {
+	c.cluster.recordPDRequest("GetStore")
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
@@ -321,6 +346,9 @@ func (c *pdClient) UpdateGCSafePoint(ctx context.Context, safePoint uint64) (uin
 	defer c.gcSafePointMu.Unlock()
 
 	if safePoint > c.gcSafePoint {
+		if err := c.cluster.gcOnSafePointUpdated(safePoint); err != nil {
+			return c.gcSafePoint, err
+		}
 		c.gcSafePoint = safePoint
 	}
 	return c.gcSafePoint, nil
@@ -0,0 +1,434 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pkg/errors"
+	pdhttp "github.com/tikv/pd/client/http"
+	sd "github.com/tikv/pd/client/servicediscovery"
+	"google.golang.org/grpc"
+)
+
+const pdhttpRegionByKey = "/pd/api/v1/region/key/"
+
+// PDHTTPHandler emulates the subset of the PD HTTP API used by the pd/http
+// client with a mock cluster, so the code going through the PD HTTP client can
+// be tested without a real PD. Serve it by httptest.NewServer and create the
+// client by pdhttp.NewClientWithServiceDiscovery with
+// NewPDHTTPServiceDiscovery(server.URL). The supported endpoints are:
+//   - the regions: region/id, region/key, regions, regions/key, regions/store
+//     and stats/region.
+//   - the stores: stores and store.
+//   - the config: GET and POST config, which only stores the items posted.
+//   - the min resolved ts set by SetMinResolvedTS.
+type PDHTTPHandler struct {
+	cluster *Cluster
+	mux     *http.ServeMux
+
+	mu            sync.Mutex
+	config        map[string]any
+	minResolvedTS map[uint64]uint64
+}
+
+// NewPDHTTPHandler creates a PDHTTPHandler serving the cluster.
+func NewPDHTTPHandler(cluster *Cluster) *PDHTTPHandler {
+	h := &PDHTTPHandler{
+		cluster:       cluster,
+		mux:           http.NewServeMux(),
+		config:        make(map[string]any),
+		minResolvedTS: make(map[uint64]uint64),
+	}
+	h.mux.HandleFunc("GET /pd/api/v1/region/id/{id}", h.getRegionByID)
+	h.mux.HandleFunc("GET "+pdhttpRegionByKey+"{key...}", h.getRegionByKey)
+	h.mux.HandleFunc("GET /pd/api/v1/regions", h.getRegions)
+	h.mux.HandleFunc("GET /pd/api/v1/regions/key", h.getRegionsByKeyRange)
+	h.mux.HandleFunc("GET /pd/api/v1/regions/store/{id}", h.getRegionsByStoreID)
+	h.mux.HandleFunc("GET /pd/api/v1/stats/region", h.getRegionStats)
+	h.mux.HandleFunc("GET /pd/api/v1/stores", h.getStores)
+	h.mux.HandleFunc("GET /pd/api/v1/store/{id}", h.getStore)
+	h.mux.HandleFunc("GET /pd/api/v1/config", h.getConfig)
+	h.mux.HandleFunc("POST /pd/api/v1/config", h.setConfig)
+	h.mux.HandleFunc("GET /pd/api/v1/min-resolved-ts", h.getMinResolvedTS)
+	return h
+}
+
+// SetMinResolvedTS sets the min resolved ts of a store reported by the handler.
+func (h *PDHTTPHandler) SetMinResolvedTS(storeID, ts uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.minResolvedTS[storeID] = ts
+}
+
+// ServeHTTP implements http.Handler.
+func (h *PDHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *PDHTTPHandler) getRegionByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	h.writeRegion(w, id)
+}
+
+func (h *PDHTTPHandler) getRegionByKey(w http.ResponseWriter, r *http.Request) {
+	// The key is query escaped by the client.
+	key, err := url.QueryUnescape(strings.TrimPrefix(r.URL.EscapedPath(), pdhttpRegionByKey))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	region, _, _, _ := h.cluster.GetRegionByKey([]byte(key))
+	if region == nil {
+		writeJSON(w, nil)
+		return
+	}
+	h.writeRegion(w, region.GetId())
+}
+
+func (h *PDHTTPHandler) writeRegion(w http.ResponseWriter, id uint64) {
+	regions := h.regionInfos(func(region *Region) bool { return region.Meta.GetId() == id })
+	if len(regions) == 0 {
+		// PD responds null for the region not found.
+		writeJSON(w, nil)
+		return
+	}
+	writeJSON(w, regions[0])
+}
+
+func (h *PDHTTPHandler) getRegions(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, newRegionsInfo(h.regionInfos(func(*Region) bool { return true })))
+}
+
+func (h *PDHTTPHandler) getRegionsByKeyRange(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	startKey, endKey := []byte(query.Get("key")), []byte(query.Get("end_key"))
+	limit := math.MaxInt
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l >= 0 {
+		limit = l
+	}
+	regions := h.regionInfos(func(region *Region) bool {
+		return overlapsRange(region.Meta, startKey, endKey)
+	})
+	writeJSON(w, newRegionsInfo(regions[:min(limit, len(regions))]))
+}
+
+func (h *PDHTTPHandler) getRegionsByStoreID(w http.ResponseWriter, r *http.Request) {
+	storeID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, newRegionsInfo(h.regionInfos(func(region *Region) bool {
+		return slices.ContainsFunc(region.Meta.GetPeers(), func(p *metapb.Peer) bool { return p.GetStoreId() == storeID })
+	})))
+}
+
+func (h *PDHTTPHandler) getRegionStats(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	startKey, endKey := []byte(query.Get("start_key")), []byte(query.Get("end_key"))
+	regions := h.regionInfos(func(region *Region) bool {
+		return overlapsRange(region.Meta, startKey, endKey)
+	})
+	stats := &pdhttp.RegionStats{Count: len(regions)}
+	if !query.Has("count") {
+		stats.StoreLeaderCount = make(map[uint64]int)
+		stats.StorePeerCount = make(map[uint64]int)
+		for _, region := range regions {
+			if region.ApproximateKeys == 0 {
+				stats.EmptyCount++
+			}
+			stats.StorageSize += region.ApproximateSize
+			stats.StorageKeys += region.ApproximateKeys
+			stats.StoreLeaderCount[uint64(region.Leader.StoreID)]++
+			for _, peer := range region.Peers {
+				stats.StorePeerCount[uint64(peer.StoreID)]++
+			}
+		}
+	}
+	writeJSON(w, stats)
+}
+
+func (h *PDHTTPHandler) getStores(w http.ResponseWriter, _ *http.Request) {
+	stores := h.cluster.GetAllStores()
+	slices.SortFunc(stores, func(a, b *metapb.Store) int {
+		return cmp.Compare(a.GetId(), b.GetId())
+	})
+	infos := &pdhttp.StoresInfo{Count: len(stores), Stores: make([]pdhttp.StoreInfo, 0, len(stores))}
+	for _, store := range stores {
+		infos.Stores = append(infos.Stores, h.storeInfo(store))
+	}
+	writeJSON(w, infos)
+}
+
+func (h *PDHTTPHandler) getStore(w http.ResponseWriter, r *http.Request) {
+	storeID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	store := h.cluster.GetStore(storeID)
+	if store == nil {
+		http.Error(w, fmt.Sprintf("store %d not found", storeID), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, h.storeInfo(store))
+}
+
+func (h *PDHTTPHandler) storeInfo(store *metapb.Store) pdhttp.StoreInfo {
+	info := pdhttp.StoreInfo{
+		Store: pdhttp.MetaStore{
+			ID:            int64(store.GetId()),
+			Address:       store.GetAddress(),
+			State:         int64(store.GetState()),
+			StateName:     store.GetState().String(),
+			Version:       store.GetVersion(),
+			StatusAddress: store.GetStatusAddress(),
+		},
+	}
+	for _, label := range store.GetLabels() {
+		info.Store.Labels = append(info.Store.Labels, pdhttp.StoreLabel{Key: label.GetKey(), Value: label.GetValue()})
+	}
+	stats := h.cluster.GetStoreStats(store.GetId())
+	info.Status.Capacity = fmt.Sprintf("%dB", stats.GetCapacity())
+	info.Status.Available = fmt.Sprintf("%dB", stats.GetAvailable())
+	for _, region := range h.regionInfos(func(*Region) bool { return true }) {
+		if uint64(region.Leader.StoreID) == store.GetId() {
+			info.Status.LeaderCount++
+			info.Status.LeaderSize += region.ApproximateSize
+		}
+		for _, peer := range region.Peers {
+			if uint64(peer.StoreID) == store.GetId() {
+				info.Status.RegionCount++
+				info.Status.RegionSize += region.ApproximateSize
+			}
+		}
+	}
+	return info
+}
+
+func (h *PDHTTPHandler) getConfig(w http.ResponseWriter, _ *http.Request) {
+	h.mu.Lock()
+	config := maps.Clone(h.config)
+	h.mu.Unlock()
+	writeJSON(w, config)
+}
+
+func (h *PDHTTPHandler) setConfig(w http.ResponseWriter, r *http.Request) {
+	var config map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.mu.Lock()
+	maps.Copy(h.config, config)
+	h.mu.Unlock()
+	writeJSON(w, "The config is updated.")
+}
+
+func (h *PDHTTPHandler) getMinResolvedTS(w http.ResponseWriter, r *http.Request) {
+	var storeIDs []uint64
+	scope := r.URL.Query().Get("scope")
+	for _, s := range strings.Split(scope, ",") {
+		if id, err := strconv.ParseUint(s, 10, 64); err == nil {
+			storeIDs = append(storeIDs, id)
+		}
+	}
+	if len(storeIDs) == 0 {
+		for _, store := range h.cluster.GetAllStores() {
+			storeIDs = append(storeIDs, store.GetId())
+		}
+	}
+	resp := struct {
+		MinResolvedTS       uint64            `json:"min_resolved_ts"`
+		IsRealTime          bool              `json:"is_real_time,omitempty"`
+		StoresMinResolvedTS map[uint64]uint64 `json:"stores_min_resolved_ts"`
+	}{IsRealTime: true}
+	h.mu.Lock()
+	for i, id := range storeIDs {
+		ts := h.minResolvedTS[id]
+		if i == 0 || ts < resp.MinResolvedTS {
+			resp.MinResolvedTS = ts
+		}
+		if scope != "" {
+			if resp.StoresMinResolvedTS == nil {
+				resp.StoresMinResolvedTS = make(map[uint64]uint64)
+			}
+			resp.StoresMinResolvedTS[id] = ts
+		}
+	}
+	h.mu.Unlock()
+	writeJSON(w, resp)
+}
+
+// regionInfos returns the regions matching the filter in the order of their
+// start keys, in the format of the PD HTTP API.
+func (h *PDHTTPHandler) regionInfos(filter func(*Region) bool) []pdhttp.RegionInfo {
+	type regionData struct {
+		meta   *metapb.Region
+		leader *metapb.Peer
+	}
+	var regions []regionData
+	h.cluster.RLock()
+	for _, region := range h.cluster.regions {
+		if filter(region) {
+			regions = append(regions, regionData{meta: region.Meta, leader: region.leaderPeer()})
+		}
+	}
+	h.cluster.RUnlock()
+	slices.SortFunc(regions, func(a, b regionData) int {
+		return bytes.Compare(a.meta.GetStartKey(), b.meta.GetStartKey())
+	})
+
+	infos := make([]pdhttp.RegionInfo, 0, len(regions))
+	for _, region := range regions {
+		info := pdhttp.RegionInfo{
+			ID:       int64(region.meta.GetId()),
+			StartKey: strings.ToUpper(hex.EncodeToString(region.meta.GetStartKey())),
+			EndKey:   strings.ToUpper(hex.EncodeToString(region.meta.GetEndKey())),
+			Epoch: pdhttp.RegionEpoch{
+				ConfVer: int64(region.meta.GetRegionEpoch().GetConfVer()),
+				Version: int64(region.meta.GetRegionEpoch().GetVersion()),
+			},
+		}
+		for _, peer := range region.meta.GetPeers() {
+			info.Peers = append(info.Peers, newRegionPeer(peer))
+		}
+		if region.leader != nil {
+			info.Leader = newRegionPeer(region.leader)
+		}
+		size, keys := h.cluster.regionDataSize(region.meta.GetId())
+		info.ApproximateSize, info.ApproximateKeys = int64(size), int64(keys)
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func newRegionPeer(peer *metapb.Peer) pdhttp.RegionPeer {
+	return pdhttp.RegionPeer{
+		ID:        int64(peer.GetId()),
+		StoreID:   int64(peer.GetStoreId()),
+		IsLearner: peer.GetRole() == metapb.PeerRole_Learner,
+	}
+}
+
+func newRegionsInfo(regions []pdhttp.RegionInfo) *pdhttp.RegionsInfo {
+	return &pdhttp.RegionsInfo{Count: int64(len(regions)), Regions: regions}
+}
+
+// overlapsRange returns whether the region overlaps [startKey, endKey). An
+// empty endKey means no upper bound.
+func overlapsRange(region *metapb.Region, startKey, endKey []byte) bool {
+	if len(region.GetEndKey()) > 0 && bytes.Compare(region.GetEndKey(), startKey) <= 0 {
+		return false
+	}
+	return len(endKey) == 0 || bytes.Compare(region.GetStartKey(), endKey) < 0
+}
+
+func pathID(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// NewPDHTTPServiceDiscovery returns a service discovery pointing the pd/http
+// client to the PD HTTP API served at addr, e.g. by a PDHTTPHandler. Pass it to
+// pdhttp.NewClientWithServiceDiscovery, because pdhttp.NewClient discovers the
+// PD members by gRPC, which the handler doesn't serve.
+func NewPDHTTPServiceDiscovery(addr string) sd.ServiceDiscovery {
+	return &pdHTTPServiceDiscovery{client: pdHTTPServiceClient{url: addr}}
+}
+
+// pdHTTPServiceDiscovery is a service discovery with a single static member,
+// which is always the leader.
+type pdHTTPServiceDiscovery struct {
+	client pdHTTPServiceClient
+}
+
+func (d *pdHTTPServiceDiscovery) Init() error                      { return nil }
+func (d *pdHTTPServiceDiscovery) Close()                           {}
+func (d *pdHTTPServiceDiscovery) GetClusterID() uint64             { return 0 }
+func (d *pdHTTPServiceDiscovery) GetKeyspaceID() uint32            { return 0 }
+func (d *pdHTTPServiceDiscovery) SetKeyspaceID(uint32)             {}
+func (d *pdHTTPServiceDiscovery) GetKeyspaceGroupID() uint32       { return 0 }
+func (d *pdHTTPServiceDiscovery) GetServiceURLs() []string         { return []string{d.client.url} }
+func (d *pdHTTPServiceDiscovery) GetServingURL() string            { return d.client.url }
+func (d *pdHTTPServiceDiscovery) GetBackupURLs() []string          { return nil }
+func (d *pdHTTPServiceDiscovery) GetClientConns() *sync.Map        { return &sync.Map{} }
+func (d *pdHTTPServiceDiscovery) ScheduleCheckMemberChanged()      {}
+func (d *pdHTTPServiceDiscovery) CheckMemberChanged() error        { return nil }
+func (d *pdHTTPServiceDiscovery) AddMembersChangedCallback(func()) {}
+
+func (d *pdHTTPServiceDiscovery) GetServingEndpointClientConn() *grpc.ClientConn { return nil }
+
+func (d *pdHTTPServiceDiscovery) GetServiceClient() sd.ServiceClient { return &d.client }
+
+func (d *pdHTTPServiceDiscovery) GetServiceClientByKind(sd.APIKind) sd.ServiceClient {
+	return &d.client
+}
+
+func (d *pdHTTPServiceDiscovery) GetAllServiceClients() []sd.ServiceClient {
+	return []sd.ServiceClient{&d.client}
+}
+
+func (d *pdHTTPServiceDiscovery) GetOrCreateGRPCConn(string) (*grpc.ClientConn, error) {
+	return nil, errors.New("mock PD HTTP service discovery has no gRPC connection")
+}
+
+func (d *pdHTTPServiceDiscovery) ExecAndAddLeaderSwitchedCallback(sd.LeaderSwitchedCallbackFunc) {}
+
+func (d *pdHTTPServiceDiscovery) AddLeaderSwitchedCallback(sd.LeaderSwitchedCallbackFunc) {}
+
+type pdHTTPServiceClient struct {
+	url string
+}
+
+func (c *pdHTTPServiceClient) GetURL() string                  { return c.url }
+func (c *pdHTTPServiceClient) GetClientConn() *grpc.ClientConn { return nil }
+func (c *pdHTTPServiceClient) IsConnectedToLeader() bool       { return true }
+func (c *pdHTTPServiceClient) Available() bool                 { return true }
+func (c *pdHTTPServiceClient) NeedRetry(*pdpb.Error, error) bool {
+	return false
+}
+
+func (c *pdHTTPServiceClient) BuildGRPCTargetContext(ctx context.Context, _ bool) context.Context {
+	return ctx
+}
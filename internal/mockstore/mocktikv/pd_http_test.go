@@ -0,0 +1,104 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pdhttp "github.com/tikv/pd/client/http"
+)
+
+func TestPDHTTPHandler(t *testing.T) {
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	defer store.Close()
+	cluster := NewCluster(store)
+	storeIDs, _, regionID, _ := BootstrapWithMultiStores(cluster, 2)
+	newRegionID, newPeerIDs := cluster.AllocID(), cluster.AllocIDs(2)
+	cluster.Split(regionID, newRegionID, []byte("m"), newPeerIDs, newPeerIDs[0])
+	store.RawPut("", []byte("a"), []byte("v"))
+	// The keys of the regions are encoded.
+	splitKey := strings.ToUpper(hex.EncodeToString(NewMvccKey([]byte("m"))))
+
+	handler := NewPDHTTPHandler(cluster)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	client := pdhttp.NewClientWithServiceDiscovery("test", NewPDHTTPServiceDiscovery(server.URL))
+	defer client.Close()
+	ctx := context.Background()
+
+	region, err := client.GetRegionByKey(ctx, NewMvccKey([]byte("z")))
+	require.Nil(t, err)
+	require.Equal(t, int64(newRegionID), region.ID)
+	require.Equal(t, splitKey, region.StartKey)
+	require.Equal(t, "", region.EndKey)
+	require.Len(t, region.Peers, 2)
+	require.Equal(t, int64(newPeerIDs[0]), region.Leader.ID)
+	region, err = client.GetRegionByID(ctx, regionID)
+	require.Nil(t, err)
+	require.Equal(t, splitKey, region.EndKey)
+	require.Equal(t, int64(1), region.ApproximateKeys)
+
+	regions, err := client.GetRegions(ctx)
+	require.Nil(t, err)
+	require.Equal(t, int64(2), regions.Count)
+	require.Equal(t, int64(regionID), regions.Regions[0].ID)
+	regions, err = client.GetRegionsByKeyRange(ctx, pdhttp.NewKeyRange(NewMvccKey([]byte("n")), []byte("")), -1)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), regions.Count)
+	require.Equal(t, int64(newRegionID), regions.Regions[0].ID)
+	regions, err = client.GetRegionsByStoreID(ctx, storeIDs[1])
+	require.Nil(t, err)
+	require.Equal(t, int64(2), regions.Count)
+
+	stats, err := client.GetRegionStatusByKeyRange(ctx, pdhttp.NewKeyRange([]byte(""), []byte("")), false)
+	require.Nil(t, err)
+	require.Equal(t, 2, stats.Count)
+	require.Equal(t, 1, stats.EmptyCount)
+	require.Equal(t, int64(1), stats.StorageKeys)
+	require.Equal(t, 2, stats.StorePeerCount[storeIDs[0]])
+
+	stores, err := client.GetStores(ctx)
+	require.Nil(t, err)
+	require.Equal(t, 2, stores.Count)
+	storeInfo, err := client.GetStore(ctx, storeIDs[0])
+	require.Nil(t, err)
+	require.Equal(t, int64(storeIDs[0]), storeInfo.Store.ID)
+	require.Equal(t, int64(2), storeInfo.Status.RegionCount)
+	require.Equal(t, int64(2), storeInfo.Status.LeaderCount)
+	_, err = client.GetStore(ctx, 100)
+	require.NotNil(t, err)
+
+	require.Nil(t, client.SetConfig(ctx, map[string]any{"schedule.max-merge-region-size": float64(20)}))
+	config, err := client.GetConfig(ctx)
+	require.Nil(t, err)
+	require.Equal(t, float64(20), config["schedule.max-merge-region-size"])
+
+	handler.SetMinResolvedTS(storeIDs[0], 100)
+	handler.SetMinResolvedTS(storeIDs[1], 200)
+	minResolvedTS, storesMinResolvedTS, err := client.GetMinResolvedTSByStoresIDs(ctx, nil)
+	require.Nil(t, err)
+	require.Equal(t, uint64(100), minResolvedTS)
+	require.Nil(t, storesMinResolvedTS)
+	minResolvedTS, storesMinResolvedTS, err = client.GetMinResolvedTSByStoresIDs(ctx, storeIDs[1:])
+	require.Nil(t, err)
+	require.Equal(t, uint64(200), minResolvedTS)
+	require.Equal(t, map[uint64]uint64{storeIDs[1]: 200}, storesMinResolvedTS)
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"fmt"
+
+	"github.com/pingcap/kvproto/pkg/errorpb"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+// SetMaxRaftEntrySize makes the stores reject the writes larger than size
+// bytes with RaftEntryTooLarge errors, like TiKV does with the raft entries
+// exceeding raft-entry-max-size, to test how the client splits the batches.
+// The size of a write is the size of its request. Pass 0 to remove the limit.
+func (c *Cluster) SetMaxRaftEntrySize(size int) {
+	c.maxRaftEntrySize.Store(int64(size))
+}
+
+// checkRaftEntrySize returns a RaftEntryTooLarge error if the request is a
+// write exceeding the limit set by SetMaxRaftEntrySize.
+func (c *Cluster) checkRaftEntrySize(req *tikvrpc.Request) *errorpb.Error {
+	limit := c.maxRaftEntrySize.Load()
+	if limit <= 0 {
+		return nil
+	}
+	switch req.Type {
+	case tikvrpc.CmdPrewrite, tikvrpc.CmdCommit, tikvrpc.CmdPessimisticLock, tikvrpc.CmdRawPut, tikvrpc.CmdRawBatchPut:
+	default:
+		return nil
+	}
+	m, ok := req.Req.(interface{ Size() int })
+	if !ok {
+		return nil
+	}
+	size := int64(m.Size())
+	if size <= limit {
+		return nil
+	}
+	regionID := req.Context.GetRegionId()
+	return &errorpb.Error{
+		Message: fmt.Sprintf("raft entry is too large, region %d, entry size %d", regionID, size),
+		RaftEntryTooLarge: &errorpb.RaftEntryTooLarge{
+			RegionId:  regionID,
+			EntrySize: uint64(size),
+		},
+	}
+}
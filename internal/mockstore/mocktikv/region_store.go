@@ -0,0 +1,255 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pkg/errors"
+)
+
+// ErrMVCCStoreReadOnly is returned by the write methods of the MVCCStore
+// created by NewReadOnlyMVCCStore.
+var ErrMVCCStoreReadOnly = errors.New("mock mvcc store is read-only")
+
+// SetRegionMVCCStore makes the requests to the region served by store instead
+// of the MVCCStore of the RPCClient, so that regions in one cluster can behave
+// differently. Pass a nil store to remove it. The store is not inherited by the
+// regions split from the region, and it's not closed by the cluster.
+func (c *Cluster) SetRegionMVCCStore(regionID uint64, store MVCCStore) {
+	c.regionStoreMu.Lock()
+	defer c.regionStoreMu.Unlock()
+	if store == nil {
+		delete(c.regionStores, regionID)
+		return
+	}
+	c.regionStores[regionID] = store
+}
+
+// GetRegionMVCCStore returns the MVCCStore set by SetRegionMVCCStore, or nil.
+func (c *Cluster) GetRegionMVCCStore(regionID uint64) MVCCStore {
+	c.regionStoreMu.RLock()
+	defer c.regionStoreMu.RUnlock()
+	return c.regionStores[regionID]
+}
+
+// MVCCStoreHook is called before every method of the MVCCStore created by
+// NewMVCCStoreWithHook. write indicates whether the method modifies data. If
+// it returns an error, the method fails with the error without calling the
+// underlying store.
+type MVCCStoreHook func(method string, write bool) error
+
+type hookedMVCCStore struct {
+	MVCCStore
+	hook MVCCStoreHook
+}
+
+// hookedRawKVStore forwards the RawKV methods, which can't report the errors
+// of the hook, to the wrapped store.
+type hookedRawKVStore struct {
+	*hookedMVCCStore
+	RawKV
+}
+
+type hookedDebugMVCCStore struct {
+	*hookedMVCCStore
+	MVCCDebugger
+}
+
+type hookedRawKVDebugStore struct {
+	*hookedMVCCStore
+	RawKV
+	MVCCDebugger
+}
+
+// NewMVCCStoreWithHook wraps store with hook. Closing the returned store
+// doesn't close store. The returned store implements RawKV and MVCCDebugger
+// if store does, and those methods are forwarded without calling hook.
+func NewMVCCStoreWithHook(store MVCCStore, hook MVCCStoreHook) MVCCStore {
+	hooked := &hookedMVCCStore{MVCCStore: store, hook: hook}
+	rawKV, isRawKV := store.(RawKV)
+	debugger, isDebugger := store.(MVCCDebugger)
+	switch {
+	case isRawKV && isDebugger:
+		return &hookedRawKVDebugStore{hookedMVCCStore: hooked, RawKV: rawKV, MVCCDebugger: debugger}
+	case isRawKV:
+		return &hookedRawKVStore{hookedMVCCStore: hooked, RawKV: rawKV}
+	case isDebugger:
+		return &hookedDebugMVCCStore{hookedMVCCStore: hooked, MVCCDebugger: debugger}
+	}
+	return hooked
+}
+
+// NewReadOnlyMVCCStore wraps store to reject all writes with
+// ErrMVCCStoreReadOnly.
+func NewReadOnlyMVCCStore(store MVCCStore) MVCCStore {
+	return NewMVCCStoreWithHook(store, func(_ string, write bool) error {
+		if write {
+			return errors.WithStack(ErrMVCCStoreReadOnly)
+		}
+		return nil
+	})
+}
+
+// NewFailingMVCCStore wraps store to fail all the reads and writes with err.
+func NewFailingMVCCStore(store MVCCStore, err error) MVCCStore {
+	return NewMVCCStoreWithHook(store, func(string, bool) error {
+		return err
+	})
+}
+
+// NewSlowMVCCStore wraps store to sleep delay before every read and write.
+func NewSlowMVCCStore(store MVCCStore, delay time.Duration) MVCCStore {
+	return NewMVCCStoreWithHook(store, func(string, bool) error {
+		time.Sleep(delay)
+		return nil
+	})
+}
+
+func (s *hookedMVCCStore) Get(key []byte, startTS uint64, isoLevel kvrpcpb.IsolationLevel, resolvedLocks []uint64) ([]byte, error) {
+	if err := s.hook("Get", false); err != nil {
+		return nil, err
+	}
+	return s.MVCCStore.Get(key, startTS, isoLevel, resolvedLocks)
+}
+
+func (s *hookedMVCCStore) Scan(startKey, endKey []byte, limit int, startTS uint64, isoLevel kvrpcpb.IsolationLevel, resolvedLocks []uint64) []Pair {
+	if err := s.hook("Scan", false); err != nil {
+		return []Pair{{Err: err}}
+	}
+	return s.MVCCStore.Scan(startKey, endKey, limit, startTS, isoLevel, resolvedLocks)
+}
+
+func (s *hookedMVCCStore) ReverseScan(startKey, endKey []byte, limit int, startTS uint64, isoLevel kvrpcpb.IsolationLevel, resolvedLocks []uint64) []Pair {
+	if err := s.hook("ReverseScan", false); err != nil {
+		return []Pair{{Err: err}}
+	}
+	return s.MVCCStore.ReverseScan(startKey, endKey, limit, startTS, isoLevel, resolvedLocks)
+}
+
+func (s *hookedMVCCStore) BatchGet(ks [][]byte, startTS uint64, isoLevel kvrpcpb.IsolationLevel, resolvedLocks []uint64) []Pair {
+	if err := s.hook("BatchGet", false); err != nil {
+		return []Pair{{Err: err}}
+	}
+	return s.MVCCStore.BatchGet(ks, startTS, isoLevel, resolvedLocks)
+}
+
+func (s *hookedMVCCStore) PessimisticLock(req *kvrpcpb.PessimisticLockRequest) *kvrpcpb.PessimisticLockResponse {
+	if err := s.hook("PessimisticLock", true); err != nil {
+		return &kvrpcpb.PessimisticLockResponse{Errors: []*kvrpcpb.KeyError{convertToKeyError(err)}}
+	}
+	return s.MVCCStore.PessimisticLock(req)
+}
+
+func (s *hookedMVCCStore) PessimisticRollback(startKey []byte, endKey []byte, keys [][]byte, startTS, forUpdateTS uint64) []error {
+	if err := s.hook("PessimisticRollback", true); err != nil {
+		return []error{err}
+	}
+	return s.MVCCStore.PessimisticRollback(startKey, endKey, keys, startTS, forUpdateTS)
+}
+
+func (s *hookedMVCCStore) Prewrite(req *kvrpcpb.PrewriteRequest) []error {
+	if err := s.hook("Prewrite", true); err != nil {
+		return []error{err}
+	}
+	return s.MVCCStore.Prewrite(req)
+}
+
+func (s *hookedMVCCStore) Commit(keys [][]byte, startTS, commitTS uint64) error {
+	if err := s.hook("Commit", true); err != nil {
+		return err
+	}
+	return s.MVCCStore.Commit(keys, startTS, commitTS)
+}
+
+func (s *hookedMVCCStore) Rollback(keys [][]byte, startTS uint64) error {
+	if err := s.hook("Rollback", true); err != nil {
+		return err
+	}
+	return s.MVCCStore.Rollback(keys, startTS)
+}
+
+func (s *hookedMVCCStore) Cleanup(key []byte, startTS, currentTS uint64) error {
+	if err := s.hook("Cleanup", true); err != nil {
+		return err
+	}
+	return s.MVCCStore.Cleanup(key, startTS, currentTS)
+}
+
+func (s *hookedMVCCStore) ScanLock(startKey, endKey []byte, maxTS uint64) ([]*kvrpcpb.LockInfo, error) {
+	if err := s.hook("ScanLock", false); err != nil {
+		return nil, err
+	}
+	return s.MVCCStore.ScanLock(startKey, endKey, maxTS)
+}
+
+func (s *hookedMVCCStore) TxnHeartBeat(primaryKey []byte, startTS uint64, adviseTTL uint64) (uint64, error) {
+	if err := s.hook("TxnHeartBeat", true); err != nil {
+		return 0, err
+	}
+	return s.MVCCStore.TxnHeartBeat(primaryKey, startTS, adviseTTL)
+}
+
+func (s *hookedMVCCStore) ResolveLock(startKey, endKey []byte, startTS, commitTS uint64) error {
+	if err := s.hook("ResolveLock", true); err != nil {
+		return err
+	}
+	return s.MVCCStore.ResolveLock(startKey, endKey, startTS, commitTS)
+}
+
+func (s *hookedMVCCStore) BatchResolveLock(startKey, endKey []byte, txnInfos map[uint64]uint64) error {
+	if err := s.hook("BatchResolveLock", true); err != nil {
+		return err
+	}
+	return s.MVCCStore.BatchResolveLock(startKey, endKey, txnInfos)
+}
+
+func (s *hookedMVCCStore) GC(startKey, endKey []byte, safePoint uint64) error {
+	if err := s.hook("GC", true); err != nil {
+		return err
+	}
+	return s.MVCCStore.GC(startKey, endKey, safePoint)
+}
+
+func (s *hookedMVCCStore) DeleteRange(startKey, endKey []byte) error {
+	if err := s.hook("DeleteRange", true); err != nil {
+		return err
+	}
+	return s.MVCCStore.DeleteRange(startKey, endKey)
+}
+
+func (s *hookedMVCCStore) Flashback(startKey, endKey []byte, version, startTS, commitTS uint64) error {
+	if err := s.hook("Flashback", true); err != nil {
+		return err
+	}
+	flashback, ok := s.MVCCStore.(MVCCFlashback)
+	if !ok {
+		return errors.New("flashback is not supported")
+	}
+	return flashback.Flashback(startKey, endKey, version, startTS, commitTS)
+}
+
+func (s *hookedMVCCStore) CheckTxnStatus(primaryKey []byte, lockTS uint64, startTS, currentTS uint64, rollbackIfNotFound bool, resolvingPessimisticLock bool) (uint64, uint64, kvrpcpb.Action, error) {
+	if err := s.hook("CheckTxnStatus", true); err != nil {
+		return 0, 0, kvrpcpb.Action_NoAction, err
+	}
+	return s.MVCCStore.CheckTxnStatus(primaryKey, lockTS, startTS, currentTS, rollbackIfNotFound, resolvingPessimisticLock)
+}
+
+// Close doesn't close the wrapped store, which may be shared with others.
+func (s *hookedMVCCStore) Close() error {
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+func TestRegionMVCCStore(t *testing.T) {
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	cluster := NewCluster(store)
+	storeID, _, regionID := BootstrapWithSingleStore(cluster)
+	ids := cluster.AllocIDs(2)
+	cluster.Split(regionID, ids[0], []byte("m"), []uint64{ids[1]}, ids[1])
+	client := NewRPCClient(cluster, store, nil)
+	defer client.Close()
+	addr := cluster.GetStore(storeID).GetAddress()
+
+	send := func(req *tikvrpc.Request, key []byte) *tikvrpc.Response {
+		region, leader, _, _ := cluster.GetRegionByKey(key)
+		require.Nil(t, tikvrpc.SetContext(req, region, leader))
+		resp, err := client.SendRequest(context.Background(), addr, req, time.Second)
+		require.Nil(t, err)
+		return resp
+	}
+	prewrite := func(key []byte, startTS uint64) []*kvrpcpb.KeyError {
+		req := tikvrpc.NewRequest(tikvrpc.CmdPrewrite, &kvrpcpb.PrewriteRequest{
+			Mutations:    []*kvrpcpb.Mutation{{Op: kvrpcpb.Op_Put, Key: key, Value: key}},
+			PrimaryLock:  key,
+			StartVersion: startTS,
+			LockTtl:      3000,
+		})
+		return send(req, key).Resp.(*kvrpcpb.PrewriteResponse).GetErrors()
+	}
+	get := func(key []byte) *kvrpcpb.GetResponse {
+		req := tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: key, Version: 100})
+		return send(req, key).Resp.(*kvrpcpb.GetResponse)
+	}
+
+	// The second region is read-only, the first one is not affected.
+	cluster.SetRegionMVCCStore(ids[0], NewReadOnlyMVCCStore(store))
+	require.Empty(t, prewrite([]byte("a"), 10))
+	errs := prewrite([]byte("x"), 10)
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0].GetAbort(), ErrMVCCStoreReadOnly.Error())
+	require.Nil(t, get([]byte("x")).GetError())
+
+	// The first region fails all the requests.
+	cluster.SetRegionMVCCStore(regionID, NewFailingMVCCStore(store, errors.New("disk failure")))
+	require.Equal(t, "disk failure", get([]byte("a")).GetError().GetAbort())
+
+	cluster.SetRegionMVCCStore(regionID, nil)
+	cluster.SetRegionMVCCStore(ids[0], nil)
+	require.Nil(t, cluster.GetRegionMVCCStore(regionID))
+	require.Empty(t, prewrite([]byte("x"), 10))
+
+	// The requests to a slow region are delayed.
+	cluster.SetRegionMVCCStore(regionID, NewSlowMVCCStore(store, 50*time.Millisecond))
+	start := time.Now()
+	get([]byte("b"))
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	// The raw KV and debug requests are still served by the wrapped store.
+	hooked := NewReadOnlyMVCCStore(store)
+	rawKV, ok := hooked.(RawKV)
+	require.True(t, ok)
+	rawKV.RawPut("", []byte("raw"), []byte("v"))
+	require.Equal(t, []byte("v"), store.RawGet("", []byte("raw")))
+	_, ok = hooked.(MVCCDebugger)
+	require.True(t, ok)
+}
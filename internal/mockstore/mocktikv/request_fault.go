@@ -0,0 +1,106 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"sync"
+
+	"github.com/pingcap/kvproto/pkg/errorpb"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+// RequestFault is the failure injected into a request by FailNext or FailNth.
+// The request fails before it's handled, so nothing is applied.
+type RequestFault struct {
+	// RegionError is responded as the region error of the request.
+	RegionError *errorpb.Error
+	// Err fails the request with the RPC error, e.g. a timeout. It takes
+	// precedence over RegionError.
+	Err error
+}
+
+type pendingRequestFault struct {
+	cmd      tikvrpc.CmdType
+	regionID uint64
+	// remaining is the number of the matching requests to pass before failing one.
+	remaining int
+	fault     RequestFault
+}
+
+type requestFaults struct {
+	sync.Mutex
+	pending []*pendingRequestFault
+}
+
+// FailNext fails the next request of cmd to the region with the fault. A zero
+// regionID matches the requests to any region. The faults registered for the
+// same requests fail them one by one in the order they are registered.
+func (c *Cluster) FailNext(cmd tikvrpc.CmdType, regionID uint64, fault RequestFault) {
+	c.addRequestFault(&pendingRequestFault{cmd: cmd, regionID: regionID, fault: fault})
+}
+
+// FailNth fails the n-th request of cmd from now on, counting from 1, with the
+// fault, regardless of the region it's sent to.
+func (c *Cluster) FailNth(cmd tikvrpc.CmdType, n int, fault RequestFault) {
+	if n < 1 {
+		n = 1
+	}
+	c.addRequestFault(&pendingRequestFault{cmd: cmd, remaining: n - 1, fault: fault})
+}
+
+// ClearRequestFaults removes the faults registered by FailNext and FailNth
+// which haven't fired yet.
+func (c *Cluster) ClearRequestFaults() {
+	c.requestFaults.Lock()
+	defer c.requestFaults.Unlock()
+	c.requestFaults.pending = nil
+}
+
+func (c *Cluster) addRequestFault(f *pendingRequestFault) {
+	c.requestFaults.Lock()
+	defer c.requestFaults.Unlock()
+	c.requestFaults.pending = append(c.requestFaults.pending, f)
+}
+
+// takeRequestFault counts the request down for the registered faults, and
+// returns the first one firing on it.
+func (c *Cluster) takeRequestFault(cmd tikvrpc.CmdType, regionID uint64) (RequestFault, bool) {
+	c.requestFaults.Lock()
+	defer c.requestFaults.Unlock()
+	var (
+		fault RequestFault
+		fired bool
+	)
+	pending := c.requestFaults.pending[:0]
+	for _, f := range c.requestFaults.pending {
+		if f.cmd != cmd || (f.regionID != 0 && f.regionID != regionID) {
+			pending = append(pending, f)
+			continue
+		}
+		if f.remaining > 0 {
+			f.remaining--
+			pending = append(pending, f)
+			continue
+		}
+		if fired {
+			// The request is failed by the former fault, keep it for the next one.
+			pending = append(pending, f)
+			continue
+		}
+		fault, fired = f.fault, true
+	}
+	c.requestFaults.pending = pending
+	return fault, fired
+}
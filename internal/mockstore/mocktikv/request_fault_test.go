@@ -0,0 +1,104 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/errorpb"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+func TestRequestFaults(t *testing.T) {
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	cluster := NewCluster(store)
+	storeID, _, regionID := BootstrapWithSingleStore(cluster)
+	region2, peer2 := cluster.AllocID(), cluster.AllocID()
+	cluster.Split(regionID, region2, []byte("m"), []uint64{peer2}, peer2)
+	client := NewRPCClient(cluster, store, nil)
+	defer client.Close()
+	addr := cluster.GetStore(storeID).GetAddress()
+
+	// send returns the region error or the RPC error of the request.
+	send := func(cmd tikvrpc.CmdType, key string) (*errorpb.Error, error) {
+		var req *tikvrpc.Request
+		switch cmd {
+		case tikvrpc.CmdGet:
+			req = tikvrpc.NewRequest(cmd, &kvrpcpb.GetRequest{Key: []byte(key), Version: 1})
+		case tikvrpc.CmdScan:
+			req = tikvrpc.NewRequest(cmd, &kvrpcpb.ScanRequest{StartKey: []byte(key), Limit: 1, Version: 1})
+		}
+		region, leader, _, _ := cluster.GetRegionByKey([]byte(key))
+		require.Nil(t, tikvrpc.SetContext(req, region, leader))
+		resp, err := client.SendRequest(context.Background(), addr, req, time.Second)
+		if err != nil {
+			return nil, err
+		}
+		regionErr, err := resp.GetRegionError()
+		require.Nil(t, err)
+		return regionErr, nil
+	}
+	notLeader := RequestFault{RegionError: &errorpb.Error{NotLeader: &errorpb.NotLeader{}}}
+	serverIsBusy := RequestFault{RegionError: &errorpb.Error{ServerIsBusy: &errorpb.ServerIsBusy{}}}
+	timeout := RequestFault{Err: errors.New("timeout")}
+
+	// FailNext only fails the requests of the command to the region.
+	cluster.FailNext(tikvrpc.CmdGet, region2, notLeader)
+	regionErr, err := send(tikvrpc.CmdScan, "z")
+	require.Nil(t, err)
+	require.Nil(t, regionErr)
+	regionErr, err = send(tikvrpc.CmdGet, "a")
+	require.Nil(t, err)
+	require.Nil(t, regionErr)
+	regionErr, err = send(tikvrpc.CmdGet, "z")
+	require.Nil(t, err)
+	require.NotNil(t, regionErr.GetNotLeader())
+	regionErr, err = send(tikvrpc.CmdGet, "z")
+	require.Nil(t, err)
+	require.Nil(t, regionErr)
+
+	// The faults of the same requests fire one by one.
+	cluster.FailNext(tikvrpc.CmdGet, 0, notLeader)
+	cluster.FailNext(tikvrpc.CmdGet, 0, timeout)
+	regionErr, err = send(tikvrpc.CmdGet, "a")
+	require.Nil(t, err)
+	require.NotNil(t, regionErr.GetNotLeader())
+	_, err = send(tikvrpc.CmdGet, "z")
+	require.EqualError(t, err, "timeout")
+
+	// FailNth counts the requests of the command to any region.
+	cluster.FailNth(tikvrpc.CmdGet, 3, serverIsBusy)
+	for i, key := range []string{"a", "z", "a", "z"} {
+		regionErr, err = send(tikvrpc.CmdGet, key)
+		require.Nil(t, err)
+		if i == 2 {
+			require.NotNil(t, regionErr.GetServerIsBusy())
+		} else {
+			require.Nil(t, regionErr)
+		}
+	}
+
+	cluster.FailNth(tikvrpc.CmdGet, 1, timeout)
+	cluster.ClearRequestFaults()
+	regionErr, err = send(tikvrpc.CmdGet, "a")
+	require.Nil(t, err)
+	require.Nil(t, regionErr)
+}
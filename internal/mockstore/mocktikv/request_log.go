@@ -0,0 +1,147 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"sync"
+
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+// RequestLogEntry is a request received by the mock stores.
+type RequestLogEntry struct {
+	Type tikvrpc.CmdType
+	// RegionID is the region in the context of the request.
+	RegionID uint64
+	// StoreID is the store the request is sent to.
+	StoreID uint64
+	// Keys are the keys of the requests on keys.
+	Keys [][]byte
+	// StartKey and EndKey are the range of the requests on a range.
+	StartKey []byte
+	EndKey   []byte
+	// TS is the start ts or the read ts of the request, if any.
+	TS uint64
+	// ReplicaRead and StaleRead are the read flags in the context.
+	ReplicaRead bool
+	StaleRead   bool
+}
+
+type requestLog struct {
+	sync.Mutex
+	enabled bool
+	entries []RequestLogEntry
+}
+
+// EnableRequestLog makes the cluster record the requests received by the
+// stores, including the ones failed with region errors, see DrainRequestLog.
+func (c *Cluster) EnableRequestLog() {
+	c.reqLog.Lock()
+	defer c.reqLog.Unlock()
+	c.reqLog.enabled = true
+}
+
+// DisableRequestLog stops recording the requests and drops the recorded ones.
+func (c *Cluster) DisableRequestLog() {
+	c.reqLog.Lock()
+	defer c.reqLog.Unlock()
+	c.reqLog.enabled = false
+	c.reqLog.entries = nil
+}
+
+// DrainRequestLog returns the requests recorded since the last drain in the
+// order they are received, and clears them.
+func (c *Cluster) DrainRequestLog() []RequestLogEntry {
+	c.reqLog.Lock()
+	defer c.reqLog.Unlock()
+	entries := c.reqLog.entries
+	c.reqLog.entries = nil
+	return entries
+}
+
+func (c *Cluster) logRequest(storeID uint64, req *tikvrpc.Request) {
+	c.reqLog.Lock()
+	defer c.reqLog.Unlock()
+	if !c.reqLog.enabled {
+		return
+	}
+	entry := RequestLogEntry{
+		Type:        req.Type,
+		RegionID:    req.Context.GetRegionId(),
+		StoreID:     storeID,
+		TS:          req.GetStartTS(),
+		ReplicaRead: req.Context.GetReplicaRead(),
+		StaleRead:   req.Context.GetStaleRead(),
+	}
+	entry.Keys, entry.StartKey, entry.EndKey = requestKeys(req)
+	c.reqLog.entries = append(c.reqLog.entries, entry)
+}
+
+// requestKeys returns the keys or the range of the request.
+func requestKeys(req *tikvrpc.Request) (keys [][]byte, startKey, endKey []byte) {
+	switch req.Type {
+	case tikvrpc.CmdGet:
+		keys = [][]byte{req.Get().GetKey()}
+	case tikvrpc.CmdBatchGet:
+		keys = req.BatchGet().GetKeys()
+	case tikvrpc.CmdScan:
+		startKey, endKey = req.Scan().GetStartKey(), req.Scan().GetEndKey()
+	case tikvrpc.CmdPrewrite:
+		for _, m := range req.Prewrite().GetMutations() {
+			keys = append(keys, m.GetKey())
+		}
+	case tikvrpc.CmdPessimisticLock:
+		for _, m := range req.PessimisticLock().GetMutations() {
+			keys = append(keys, m.GetKey())
+		}
+	case tikvrpc.CmdPessimisticRollback:
+		keys = req.PessimisticRollback().GetKeys()
+	case tikvrpc.CmdCommit:
+		keys = req.Commit().GetKeys()
+	case tikvrpc.CmdCleanup:
+		keys = [][]byte{req.Cleanup().GetKey()}
+	case tikvrpc.CmdBatchRollback:
+		keys = req.BatchRollback().GetKeys()
+	case tikvrpc.CmdCheckTxnStatus:
+		keys = [][]byte{req.CheckTxnStatus().GetPrimaryKey()}
+	case tikvrpc.CmdCheckSecondaryLocks:
+		keys = req.CheckSecondaryLocks().GetKeys()
+	case tikvrpc.CmdTxnHeartBeat:
+		keys = [][]byte{req.TxnHeartBeat().GetPrimaryLock()}
+	case tikvrpc.CmdScanLock:
+		startKey, endKey = req.ScanLock().GetStartKey(), req.ScanLock().GetEndKey()
+	case tikvrpc.CmdDeleteRange:
+		startKey, endKey = req.DeleteRange().GetStartKey(), req.DeleteRange().GetEndKey()
+	case tikvrpc.CmdRawGet:
+		keys = [][]byte{req.RawGet().GetKey()}
+	case tikvrpc.CmdRawBatchGet:
+		keys = req.RawBatchGet().GetKeys()
+	case tikvrpc.CmdRawPut:
+		keys = [][]byte{req.RawPut().GetKey()}
+	case tikvrpc.CmdRawBatchPut:
+		for _, pair := range req.RawBatchPut().GetPairs() {
+			keys = append(keys, pair.GetKey())
+		}
+	case tikvrpc.CmdRawDelete:
+		keys = [][]byte{req.RawDelete().GetKey()}
+	case tikvrpc.CmdRawBatchDelete:
+		keys = req.RawBatchDelete().GetKeys()
+	case tikvrpc.CmdRawDeleteRange:
+		startKey, endKey = req.RawDeleteRange().GetStartKey(), req.RawDeleteRange().GetEndKey()
+	case tikvrpc.CmdRawScan:
+		startKey, endKey = req.RawScan().GetStartKey(), req.RawScan().GetEndKey()
+	}
+	return keys, startKey, endKey
+}
@@ -93,6 +93,7 @@ func convertToKeyError(err error) *kvrpcpb.KeyError {
 				ConflictTs:       writeConflict.ConflictTS,
 				ConflictCommitTs: writeConflict.ConflictCommitTS,
 				StartTs:          writeConflict.StartTS,
+				Reason:           writeConflict.Reason,
 			},
 		}
 	}
@@ -213,6 +214,11 @@ func (h kvHandler) handleKvScan(req *kvrpcpb.ScanRequest) *kvrpcpb.ScanResponse
 
 		pairs = h.mvccStore.ReverseScan(req.EndKey, endKey, int(req.GetLimit()), req.GetVersion(), h.isolationLevel, req.Context.ResolvedLocks)
 	}
+	if req.GetKeyOnly() {
+		for i := range pairs {
+			pairs[i].Value = nil
+		}
+	}
 
 	return &kvrpcpb.ScanResponse{
 		Pairs: convertToPbPairs(pairs),
@@ -295,7 +301,7 @@ func (h kvHandler) handleKvCleanup(req *kvrpcpb.CleanupRequest) *kvrpcpb.Cleanup
 		panic("KvCleanup: key not in region")
 	}
 	var resp kvrpcpb.CleanupResponse
-	err := h.mvccStore.Cleanup(req.Key, req.GetStartVersion(), req.GetCurrentTs())
+	err := h.mvccStore.Cleanup(req.Key, req.GetStartVersion(), h.cluster.currentTS(req.GetCurrentTs()))
 	if err != nil {
 		if commitTS, ok := errors.Cause(err).(ErrAlreadyCommitted); ok {
 			resp.CommitVersion = uint64(commitTS)
@@ -311,7 +317,7 @@ func (h kvHandler) handleKvCheckTxnStatus(req *kvrpcpb.CheckTxnStatusRequest) *k
 		panic("KvCheckTxnStatus: key not in region")
 	}
 	var resp kvrpcpb.CheckTxnStatusResponse
-	ttl, commitTS, action, err := h.mvccStore.CheckTxnStatus(req.GetPrimaryKey(), req.GetLockTs(), req.GetCallerStartTs(), req.GetCurrentTs(), req.GetRollbackIfNotExist(), req.ResolvingPessimisticLock)
+	ttl, commitTS, action, err := h.mvccStore.CheckTxnStatus(req.GetPrimaryKey(), req.GetLockTs(), req.GetCallerStartTs(), h.cluster.currentTS(req.GetCurrentTs()), req.GetRollbackIfNotExist(), req.ResolvingPessimisticLock)
 	if err != nil {
 		resp.Error = convertToKeyError(err)
 	} else {
@@ -433,6 +439,16 @@ func (h kvHandler) handleKvDeleteRange(req *kvrpcpb.DeleteRangeRequest) *kvrpcpb
 	return &resp
 }
 
+// handleKvUnsafeDestroyRange deletes all versions of the keys in the range
+// from the mvcc store shared by the stores, so it's idempotent across them.
+func (h kvHandler) handleKvUnsafeDestroyRange(req *kvrpcpb.UnsafeDestroyRangeRequest) *kvrpcpb.UnsafeDestroyRangeResponse {
+	var resp kvrpcpb.UnsafeDestroyRangeResponse
+	if err := h.mvccStore.DeleteRange(req.StartKey, req.EndKey); err != nil {
+		resp.Error = err.Error()
+	}
+	return &resp
+}
+
 func (h kvHandler) handleKvRawGet(req *kvrpcpb.RawGetRequest) *kvrpcpb.RawGetResponse {
 	rawKV, ok := h.mvccStore.(RawKV)
 	if !ok {
@@ -440,6 +456,7 @@ func (h kvHandler) handleKvRawGet(req *kvrpcpb.RawGetRequest) *kvrpcpb.RawGetRes
 			Error: "not implemented",
 		}
 	}
+	h.expireRawKeys(rawKV, req.GetCf())
 	v := rawKV.RawGet(req.Cf, req.GetKey())
 	return &kvrpcpb.RawGetResponse{
 		NotFound: v == nil,
@@ -457,6 +474,7 @@ func (h kvHandler) handleKvRawBatchGet(req *kvrpcpb.RawBatchGetRequest) *kvrpcpb
 			},
 		}
 	}
+	h.expireRawKeys(rawKV, req.GetCf())
 	values := rawKV.RawBatchGet(req.Cf, req.Keys)
 	kvPairs := make([]*kvrpcpb.KvPair, len(values))
 	for i, key := range req.Keys {
@@ -477,7 +495,7 @@ func (h kvHandler) handleKvRawPut(req *kvrpcpb.RawPutRequest) *kvrpcpb.RawPutRes
 			Error: "not implemented",
 		}
 	}
-	rawKV.RawPut(req.GetCf(), req.GetKey(), req.GetValue())
+	rawKV.RawPutWithTTL(req.GetCf(), req.GetKey(), req.GetValue(), h.rawExpireAt(req.GetTtl()))
 	return &kvrpcpb.RawPutResponse{}
 }
 
@@ -490,11 +508,25 @@ func (h kvHandler) handleKvRawBatchPut(req *kvrpcpb.RawBatchPutRequest) *kvrpcpb
 	}
 	keys := make([][]byte, 0, len(req.Pairs))
 	values := make([][]byte, 0, len(req.Pairs))
-	for _, pair := range req.Pairs {
+	var expireAts []time.Time
+	if len(req.Ttls) > 0 || req.Ttl > 0 {
+		expireAts = make([]time.Time, 0, len(req.Pairs))
+	}
+	for i, pair := range req.Pairs {
 		keys = append(keys, pair.Key)
 		values = append(values, pair.Value)
+		if expireAts != nil {
+			// Ttls is either one for each pair, or one for all the pairs, which is also how the deprecated Ttl is used.
+			ttl := req.Ttl
+			if len(req.Ttls) == len(req.Pairs) {
+				ttl = req.Ttls[i]
+			} else if len(req.Ttls) > 0 {
+				ttl = req.Ttls[0]
+			}
+			expireAts = append(expireAts, h.rawExpireAt(ttl))
+		}
 	}
-	rawKV.RawBatchPut(req.GetCf(), keys, values)
+	rawKV.RawBatchPutWithTTL(req.GetCf(), keys, values, expireAts)
 	return &kvrpcpb.RawBatchPutResponse{}
 }
 
@@ -517,6 +549,7 @@ func (h kvHandler) HandleKvRawCompareAndSwap(req *kvrpcpb.RawCASRequest) *kvrpcp
 		}
 	}
 
+	h.expireRawKeys(rawKV, req.GetCf())
 	oldValue, success, err := rawKV.RawCompareAndSwap(
 		req.Cf,
 		req.GetKey(),
@@ -569,34 +602,101 @@ func (h kvHandler) handleKvRawScan(req *kvrpcpb.RawScanRequest) *kvrpcpb.RawScan
 		}
 	}
 
+	h.expireRawKeys(rawKV, req.GetCf())
+	return &kvrpcpb.RawScanResponse{
+		Kvs: h.rawScan(rawKV, req.GetCf(), req.StartKey, req.EndKey, int(req.GetLimit()), req.Reverse, req.KeyOnly),
+	}
+}
+
+// handleKvRawBatchScan scans each of the ranges in the region for at most EachLimit pairs, the pairs of the ranges
+// are returned in the order of the ranges.
+func (h kvHandler) handleKvRawBatchScan(req *kvrpcpb.RawBatchScanRequest) *kvrpcpb.RawBatchScanResponse {
+	rawKV, ok := h.mvccStore.(RawKV)
+	if !ok {
+		return &kvrpcpb.RawBatchScanResponse{
+			RegionError: &errorpb.Error{
+				Message: "not implemented",
+			},
+		}
+	}
+
+	h.expireRawKeys(rawKV, req.GetCf())
+	var kvs []*kvrpcpb.KvPair
+	for _, r := range req.Ranges {
+		kvs = append(kvs, h.rawScan(rawKV, req.GetCf(), r.StartKey, r.EndKey, int(req.GetEachLimit()), req.Reverse, req.KeyOnly)...)
+	}
+	return &kvrpcpb.RawBatchScanResponse{
+		Kvs: kvs,
+	}
+}
+
+// rawScan scans at most limit pairs in the range bounded by the region. If reverse is set, the range is
+// [endKey, startKey) and scanned backward.
+func (h kvHandler) rawScan(rawKV RawKV, cf string, startKey, endKey []byte, limit int, reverse, keyOnly bool) []*kvrpcpb.KvPair {
 	var pairs []Pair
-	if req.Reverse {
+	if reverse {
 		lowerBound := h.startKey
-		if bytes.Compare(req.EndKey, lowerBound) > 0 {
-			lowerBound = req.EndKey
+		if bytes.Compare(endKey, lowerBound) > 0 {
+			lowerBound = endKey
 		}
 		pairs = rawKV.RawReverseScan(
-			req.GetCf(),
-			req.StartKey,
+			cf,
+			startKey,
 			lowerBound,
-			int(req.GetLimit()),
+			limit,
 		)
 	} else {
 		upperBound := h.endKey
-		if len(req.EndKey) > 0 && (len(upperBound) == 0 || bytes.Compare(req.EndKey, upperBound) < 0) {
-			upperBound = req.EndKey
+		if len(endKey) > 0 && (len(upperBound) == 0 || bytes.Compare(endKey, upperBound) < 0) {
+			upperBound = endKey
 		}
 		pairs = rawKV.RawScan(
-			req.GetCf(),
-			req.StartKey,
+			cf,
+			startKey,
 			upperBound,
-			int(req.GetLimit()),
+			limit,
 		)
 	}
+	if keyOnly {
+		for i := range pairs {
+			pairs[i].Value = nil
+		}
+	}
+	return convertToPbPairs(pairs)
+}
 
-	return &kvrpcpb.RawScanResponse{
-		Kvs: convertToPbPairs(pairs),
+func (h kvHandler) handleKvRawGetKeyTTL(req *kvrpcpb.RawGetKeyTTLRequest) *kvrpcpb.RawGetKeyTTLResponse {
+	rawKV, ok := h.mvccStore.(RawKV)
+	if !ok {
+		return &kvrpcpb.RawGetKeyTTLResponse{
+			Error: "not implemented",
+		}
+	}
+	h.expireRawKeys(rawKV, req.GetCf())
+	expireAt, found := rawKV.RawGetKeyTTL(req.GetCf(), req.GetKey())
+	if !found {
+		return &kvrpcpb.RawGetKeyTTLResponse{NotFound: true}
+	}
+	var ttl uint64
+	if !expireAt.IsZero() {
+		// Round up, so a key not expired yet never has a zero ttl, which means no ttl.
+		ttl = uint64((expireAt.Sub(h.cluster.Now()) + time.Second - 1) / time.Second)
 	}
+	return &kvrpcpb.RawGetKeyTTLResponse{Ttl: ttl}
+}
+
+// rawExpireAt returns when a raw key put with ttl seconds expires by the clock of the cluster, the zero time if ttl
+// is 0.
+func (h kvHandler) rawExpireAt(ttl uint64) time.Time {
+	if ttl == 0 {
+		return time.Time{}
+	}
+	return h.cluster.Now().Add(time.Duration(ttl) * time.Second)
+}
+
+// expireRawKeys deletes the raw keys of cf expired by the clock of the cluster before reading them.
+func (h kvHandler) expireRawKeys(rawKV RawKV, cf string) {
+	rawKV.RawExpire(cf, h.cluster.Now())
 }
 
 func (h kvHandler) handleKvRawChecksum(req *kvrpcpb.RawChecksumRequest) *kvrpcpb.RawChecksumResponse {
@@ -610,6 +710,7 @@ func (h kvHandler) handleKvRawChecksum(req *kvrpcpb.RawChecksumRequest) *kvrpcpb
 		}
 	}
 
+	h.expireRawKeys(rawKV, "CF_DEFAULT")
 	crc64Xor := uint64(0)
 	totalKvs := uint64(0)
 	totalBytes := uint64(0)
@@ -706,7 +807,8 @@ func (c *RPCClient) getAndCheckStoreByAddr(addr string) (*metapb.Store, error) {
 	}
 	for _, store := range stores {
 		if store.GetState() != metapb.StoreState_Offline &&
-			store.GetState() != metapb.StoreState_Tombstone {
+			store.GetState() != metapb.StoreState_Tombstone &&
+			!c.Cluster.isStoreStopped(store.GetId()) {
 			return store, nil
 		}
 	}
@@ -733,6 +835,13 @@ func (c *RPCClient) checkArgs(ctx context.Context, addr string) (*Session, error
 
 // SendRequest sends a request to mock cluster.
 func (c *RPCClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	start := time.Now()
+	resp, err := c.sendRequest(ctx, addr, req, timeout)
+	c.Cluster.recordRequest(req.Type, time.Since(start), resp)
+	return resp, err
+}
+
+func (c *RPCClient) sendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
 	tikvrpc.AttachContext(req, req.Context)
 
 	if span := opentracing.SpanFromContext(ctx); span != nil && span.Tracer() != nil {
@@ -752,6 +861,51 @@ func (c *RPCClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.R
 	if err != nil {
 		return nil, err
 	}
+	c.Cluster.logRequest(session.storeID, req)
+	c.Cluster.onRegionRequest(reqCtx.GetRegionId())
+	if fault, ok := c.Cluster.takeRequestFault(req.Type, reqCtx.GetRegionId()); ok {
+		if fault.Err != nil {
+			return nil, fault.Err
+		}
+		return tikvrpc.GenRegionErrorResp(req, fault.RegionError)
+	}
+	scheduled, err := c.Cluster.schedule(ctx, session.storeID, req)
+	if err != nil {
+		return nil, err
+	}
+	defer scheduled()
+	if serverIsBusy := c.Cluster.acquireStoreLoad(session.storeID); serverIsBusy != nil {
+		return tikvrpc.GenRegionErrorResp(req, &errorpb.Error{
+			Message:      serverIsBusy.Reason,
+			ServerIsBusy: serverIsBusy,
+		})
+	}
+	defer c.Cluster.releaseStoreLoad(session.storeID)
+	if diskFull := c.Cluster.checkStoreDiskFull(session.storeID, req); diskFull != nil {
+		return tikvrpc.GenRegionErrorResp(req, &errorpb.Error{
+			Message:  diskFull.Reason,
+			DiskFull: diskFull,
+		})
+	}
+	if flashbackErr := c.Cluster.checkRegionFlashback(req); flashbackErr != nil {
+		return tikvrpc.GenRegionErrorResp(req, flashbackErr)
+	}
+	if entryErr := c.Cluster.checkRaftEntrySize(req); entryErr != nil {
+		return tikvrpc.GenRegionErrorResp(req, entryErr)
+	}
+
+	if token := req.WriteToken; token != (tikvrpc.WriteToken{}) {
+		if applied, ok := c.Cluster.getAppliedWrite(token); ok {
+			return &tikvrpc.Response{Resp: applied}, nil
+		}
+		defer func() {
+			// The writes failed with region errors are not applied.
+			if regionErr, err := resp.GetRegionError(); resp.Resp != nil && err == nil && regionErr == nil {
+				c.Cluster.putAppliedWrite(token, resp.Resp)
+			}
+		}()
+	}
+
 	switch req.Type {
 	case tikvrpc.CmdGet:
 		r := req.Get()
@@ -918,6 +1072,20 @@ func (c *RPCClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.R
 			return resp, nil
 		}
 		resp.Resp = kvHandler{session}.handleKvDeleteRange(r)
+	case tikvrpc.CmdPrepareFlashbackToVersion:
+		r := req.PrepareFlashbackToVersion()
+		if err := session.checkRequest(reqCtx, r.Size()); err != nil {
+			resp.Resp = &kvrpcpb.PrepareFlashbackToVersionResponse{RegionError: err}
+			return resp, nil
+		}
+		resp.Resp = kvHandler{session}.handleKvPrepareFlashbackToVersion(r)
+	case tikvrpc.CmdFlashbackToVersion:
+		r := req.FlashbackToVersion()
+		if err := session.checkRequest(reqCtx, r.Size()); err != nil {
+			resp.Resp = &kvrpcpb.FlashbackToVersionResponse{RegionError: err}
+			return resp, nil
+		}
+		resp.Resp = kvHandler{session}.handleKvFlashbackToVersion(r)
 	case tikvrpc.CmdRawGet:
 		r := req.RawGet()
 		if err := session.checkRequest(reqCtx, r.Size()); err != nil {
@@ -957,6 +1125,7 @@ func (c *RPCClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.R
 		r := req.RawBatchDelete()
 		if err := session.checkRequest(reqCtx, r.Size()); err != nil {
 			resp.Resp = &kvrpcpb.RawBatchDeleteResponse{RegionError: err}
+			return resp, nil
 		}
 		resp.Resp = kvHandler{session}.handleKvRawBatchDelete(r)
 	case tikvrpc.CmdRawDeleteRange:
@@ -973,6 +1142,20 @@ func (c *RPCClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.R
 			return resp, nil
 		}
 		resp.Resp = kvHandler{session}.handleKvRawScan(r)
+	case tikvrpc.CmdRawBatchScan:
+		r := req.RawBatchScan()
+		if err := session.checkRequest(reqCtx, r.Size()); err != nil {
+			resp.Resp = &kvrpcpb.RawBatchScanResponse{RegionError: err}
+			return resp, nil
+		}
+		resp.Resp = kvHandler{session}.handleKvRawBatchScan(r)
+	case tikvrpc.CmdRawGetKeyTTL:
+		r := req.RawGetKeyTTL()
+		if err := session.checkRequest(reqCtx, r.Size()); err != nil {
+			resp.Resp = &kvrpcpb.RawGetKeyTTLResponse{RegionError: err}
+			return resp, nil
+		}
+		resp.Resp = kvHandler{session}.handleKvRawGetKeyTTL(r)
 	case tikvrpc.CmdRawCompareAndSwap:
 		r := req.RawCompareAndSwap()
 		if err := session.checkRequest(reqCtx, r.Size()); err != nil {
@@ -983,12 +1166,12 @@ func (c *RPCClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.R
 	case tikvrpc.CmdRawChecksum:
 		r := req.RawChecksum()
 		if err := session.checkRequest(reqCtx, r.Size()); err != nil {
-			resp.Resp = &kvrpcpb.RawScanResponse{RegionError: err}
+			resp.Resp = &kvrpcpb.RawChecksumResponse{RegionError: err}
 			return resp, nil
 		}
 		resp.Resp = kvHandler{session}.handleKvRawChecksum(r)
 	case tikvrpc.CmdUnsafeDestroyRange:
-		panic("unimplemented")
+		resp.Resp = kvHandler{session}.handleKvUnsafeDestroyRange(req.UnsafeDestroyRange())
 	case tikvrpc.CmdRegisterLockObserver:
 		return nil, errors.New("unimplemented")
 	case tikvrpc.CmdCheckLockObserver:
@@ -1011,7 +1194,7 @@ func (c *RPCClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.R
 			}
 		}
 
-		if value, err := util.EvalFailpoint("BatchCopRpcErr"); err != nil {
+		if value, err := util.EvalFailpoint("BatchCopRpcErr"); err == nil {
 			if value.(string) == addr {
 				return nil, errors.New("rpc error")
 			}
@@ -1023,6 +1206,9 @@ func (c *RPCClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.R
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
+		if batchResp, err = c.Cluster.interruptBatchCopStream(session.storeID, batchResp); err != nil {
+			return nil, err
+		}
 		resp.Resp = batchResp
 	case tikvrpc.CmdCopStream:
 		if c.coprHandler == nil {
@@ -1034,6 +1220,9 @@ func (c *RPCClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.R
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
+		if streamResp, err = c.Cluster.interruptCopStream(session.storeID, streamResp); err != nil {
+			return nil, err
+		}
 		resp.Resp = streamResp
 	case tikvrpc.CmdMvccGetByKey:
 		r := req.MvccGetByKey()
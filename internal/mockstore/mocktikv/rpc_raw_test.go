@@ -0,0 +1,87 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+func TestRawBatchScanAndTTL(t *testing.T) {
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	cluster := NewCluster(store)
+	storeID, _, _ := BootstrapWithSingleStore(cluster)
+	client := NewRPCClient(cluster, store, nil)
+	defer client.Close()
+	addr := cluster.GetStore(storeID).GetAddress()
+
+	send := func(req *tikvrpc.Request) any {
+		region, leader, _, _ := cluster.GetRegionByKey([]byte("a"))
+		require.Nil(t, tikvrpc.SetContext(req, region, leader))
+		resp, err := client.SendRequest(context.Background(), addr, req, time.Second)
+		require.Nil(t, err)
+		return resp.Resp
+	}
+	var pairs []*kvrpcpb.KvPair
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		pairs = append(pairs, &kvrpcpb.KvPair{Key: []byte(k), Value: []byte(k + k)})
+	}
+	send(tikvrpc.NewRequest(tikvrpc.CmdRawBatchPut, &kvrpcpb.RawBatchPutRequest{Pairs: pairs}))
+
+	scan := func(reverse, keyOnly bool, ranges ...string) []string {
+		req := &kvrpcpb.RawBatchScanRequest{EachLimit: 2, Reverse: reverse, KeyOnly: keyOnly}
+		for i := 0; i < len(ranges); i += 2 {
+			req.Ranges = append(req.Ranges, &kvrpcpb.KeyRange{StartKey: []byte(ranges[i]), EndKey: []byte(ranges[i+1])})
+		}
+		resp := send(tikvrpc.NewRequest(tikvrpc.CmdRawBatchScan, req)).(*kvrpcpb.RawBatchScanResponse)
+		require.Nil(t, resp.RegionError)
+		var kvs []string
+		for _, kv := range resp.Kvs {
+			kvs = append(kvs, string(kv.Key)+"="+string(kv.Value))
+		}
+		return kvs
+	}
+	require.Equal(t, []string{"a=aa", "b=bb", "d=dd", "e=ee"}, scan(false, false, "a", "c", "d", ""))
+	require.Equal(t, []string{"e=", "d=", "b=", "a="}, scan(true, true, "f", "c", "c", "a"))
+
+	getTTL := func(key string) *kvrpcpb.RawGetKeyTTLResponse {
+		return send(tikvrpc.NewRequest(tikvrpc.CmdRawGetKeyTTL, &kvrpcpb.RawGetKeyTTLRequest{Key: []byte(key)})).(*kvrpcpb.RawGetKeyTTLResponse)
+	}
+	send(tikvrpc.NewRequest(tikvrpc.CmdRawPut, &kvrpcpb.RawPutRequest{Key: []byte("a"), Value: []byte("a1"), Ttl: 10}))
+	send(tikvrpc.NewRequest(tikvrpc.CmdRawBatchPut, &kvrpcpb.RawBatchPutRequest{
+		Pairs: pairs[1:3],
+		Ttls:  []uint64{20, 0},
+	}))
+	require.Equal(t, &kvrpcpb.RawGetKeyTTLResponse{Ttl: 10}, getTTL("a"))
+	require.Equal(t, &kvrpcpb.RawGetKeyTTLResponse{Ttl: 20}, getTTL("b"))
+	require.Equal(t, &kvrpcpb.RawGetKeyTTLResponse{}, getTTL("c"))
+	require.Equal(t, &kvrpcpb.RawGetKeyTTLResponse{NotFound: true}, getTTL("x"))
+
+	cluster.AdvanceClock(15 * time.Second)
+	require.Equal(t, &kvrpcpb.RawGetKeyTTLResponse{NotFound: true}, getTTL("a"))
+	require.Equal(t, &kvrpcpb.RawGetKeyTTLResponse{Ttl: 5}, getTTL("b"))
+	get := send(tikvrpc.NewRequest(tikvrpc.CmdRawGet, &kvrpcpb.RawGetRequest{Key: []byte("a")})).(*kvrpcpb.RawGetResponse)
+	require.True(t, get.NotFound)
+	// Putting the key again without ttl clears its ttl.
+	send(tikvrpc.NewRequest(tikvrpc.CmdRawPut, &kvrpcpb.RawPutRequest{Key: []byte("b"), Value: []byte("b1")}))
+	cluster.AdvanceClock(10 * time.Second)
+	require.Equal(t, []string{"b=b1", "c=cc"}, scan(false, false, "", ""))
+}
@@ -0,0 +1,162 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+// LatencyBuckets are the upper bounds of the buckets of LatencyHistogram. The
+// last bucket of a histogram counts the latencies above all the bounds.
+var LatencyBuckets = []time.Duration{
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+}
+
+// LatencyHistogram is the distribution of the latencies of a type of requests,
+// including the delays simulated by the cluster, e.g. by ScheduleDelay, the
+// scheduler or the slow stores.
+type LatencyHistogram struct {
+	Count int
+	Sum   time.Duration
+	Max   time.Duration
+	// Buckets[i] counts the latencies not above LatencyBuckets[i], and not in
+	// the former buckets.
+	Buckets []int
+}
+
+func (h *LatencyHistogram) observe(d time.Duration) {
+	if h.Buckets == nil {
+		h.Buckets = make([]int, len(LatencyBuckets)+1)
+	}
+	h.Count++
+	h.Sum += d
+	if d > h.Max {
+		h.Max = d
+	}
+	i := 0
+	for i < len(LatencyBuckets) && d > LatencyBuckets[i] {
+		i++
+	}
+	h.Buckets[i]++
+}
+
+// RPCStats is the accounting of the requests served by the cluster since it's
+// created or ResetStats is called, see Cluster.Stats.
+type RPCStats struct {
+	// Requests is the number of the requests received by the stores per type.
+	Requests map[tikvrpc.CmdType]int
+	// RegionErrors is the number of the requests responded with region errors
+	// per type, which are usually retried by the client.
+	RegionErrors map[tikvrpc.CmdType]int
+	// Latencies are the latencies of the requests per type.
+	Latencies map[tikvrpc.CmdType]*LatencyHistogram
+	// PDRequests is the number of the requests received by the mock PD per
+	// method, e.g. "GetRegion".
+	PDRequests map[string]int
+}
+
+// RegionLookups returns the number of the requests loading the regions from
+// the mock PD.
+func (s *RPCStats) RegionLookups() int {
+	return s.PDRequests["GetRegion"] + s.PDRequests["GetPrevRegion"] + s.PDRequests["GetRegionByID"] +
+		s.PDRequests["ScanRegions"] + s.PDRequests["BatchScanRegions"]
+}
+
+// Retries returns the number of the requests responded with region errors.
+func (s *RPCStats) Retries() int {
+	n := 0
+	for _, c := range s.RegionErrors {
+		n += c
+	}
+	return n
+}
+
+type rpcStats struct {
+	sync.Mutex
+	stats RPCStats
+}
+
+// Stats returns a copy of the accounting of the requests served by the cluster.
+func (c *Cluster) Stats() *RPCStats {
+	c.rpcStats.Lock()
+	defer c.rpcStats.Unlock()
+	s := &RPCStats{
+		Requests:     make(map[tikvrpc.CmdType]int, len(c.rpcStats.stats.Requests)),
+		RegionErrors: make(map[tikvrpc.CmdType]int, len(c.rpcStats.stats.RegionErrors)),
+		Latencies:    make(map[tikvrpc.CmdType]*LatencyHistogram, len(c.rpcStats.stats.Latencies)),
+		PDRequests:   make(map[string]int, len(c.rpcStats.stats.PDRequests)),
+	}
+	for k, v := range c.rpcStats.stats.Requests {
+		s.Requests[k] = v
+	}
+	for k, v := range c.rpcStats.stats.RegionErrors {
+		s.RegionErrors[k] = v
+	}
+	for k, v := range c.rpcStats.stats.Latencies {
+		h := *v
+		h.Buckets = append([]int(nil), v.Buckets...)
+		s.Latencies[k] = &h
+	}
+	for k, v := range c.rpcStats.stats.PDRequests {
+		s.PDRequests[k] = v
+	}
+	return s
+}
+
+// ResetStats clears the accounting of the requests.
+func (c *Cluster) ResetStats() {
+	c.rpcStats.Lock()
+	defer c.rpcStats.Unlock()
+	c.rpcStats.stats = RPCStats{}
+}
+
+func (c *Cluster) recordRequest(cmd tikvrpc.CmdType, cost time.Duration, resp *tikvrpc.Response) {
+	c.rpcStats.Lock()
+	defer c.rpcStats.Unlock()
+	s := &c.rpcStats.stats
+	if s.Requests == nil {
+		s.Requests = make(map[tikvrpc.CmdType]int)
+		s.RegionErrors = make(map[tikvrpc.CmdType]int)
+		s.Latencies = make(map[tikvrpc.CmdType]*LatencyHistogram)
+	}
+	s.Requests[cmd]++
+	if resp != nil && resp.Resp != nil {
+		if regionErr, err := resp.GetRegionError(); err == nil && regionErr != nil {
+			s.RegionErrors[cmd]++
+		}
+	}
+	h, ok := s.Latencies[cmd]
+	if !ok {
+		h = &LatencyHistogram{}
+		s.Latencies[cmd] = h
+	}
+	h.observe(cost)
+}
+
+func (c *Cluster) recordPDRequest(method string) {
+	c.rpcStats.Lock()
+	defer c.rpcStats.Unlock()
+	if c.rpcStats.stats.PDRequests == nil {
+		c.rpcStats.stats.PDRequests = make(map[string]int)
+	}
+	c.rpcStats.stats.PDRequests[method]++
+}
@@ -0,0 +1,87 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/errorpb"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/pd/client/pkg/circuitbreaker"
+)
+
+func TestRPCStats(t *testing.T) {
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	cluster := NewCluster(store)
+	storeID, _, regionID := BootstrapWithSingleStore(cluster)
+	client := NewRPCClient(cluster, store, nil)
+	defer client.Close()
+	addr := cluster.GetStore(storeID).GetAddress()
+
+	send := func(req *tikvrpc.Request) {
+		region, leader, _, _ := cluster.GetRegionByKey([]byte("a"))
+		require.Nil(t, tikvrpc.SetContext(req, region, leader))
+		_, err := client.SendRequest(context.Background(), addr, req, time.Second)
+		require.Nil(t, err)
+	}
+	get := func() *tikvrpc.Request {
+		return tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: []byte("a"), Version: 1})
+	}
+
+	cluster.FailNext(tikvrpc.CmdGet, 0, RequestFault{RegionError: &errorpb.Error{NotLeader: &errorpb.NotLeader{}}})
+	send(get())
+	send(get())
+	cluster.ScheduleDelay(10, regionID, 20*time.Millisecond)
+	send(tikvrpc.NewRequest(tikvrpc.CmdPrewrite, &kvrpcpb.PrewriteRequest{
+		Mutations:    []*kvrpcpb.Mutation{{Op: kvrpcpb.Op_Put, Key: []byte("a"), Value: []byte("a")}},
+		PrimaryLock:  []byte("a"),
+		StartVersion: 10,
+		LockTtl:      1000,
+	}))
+	ctx := circuitbreaker.WithCircuitBreaker(context.Background(),
+		circuitbreaker.NewCircuitBreaker("test", circuitbreaker.AlwaysClosedSettings))
+	pdClient := NewPDClient(cluster)
+	_, err = pdClient.GetRegion(ctx, []byte("a"))
+	require.Nil(t, err)
+	_, err = pdClient.GetRegionByID(ctx, regionID)
+	require.Nil(t, err)
+	_, err = pdClient.GetStore(ctx, storeID)
+	require.Nil(t, err)
+
+	stats := cluster.Stats()
+	require.Equal(t, map[tikvrpc.CmdType]int{tikvrpc.CmdGet: 2, tikvrpc.CmdPrewrite: 1}, stats.Requests)
+	require.Equal(t, map[tikvrpc.CmdType]int{tikvrpc.CmdGet: 1}, stats.RegionErrors)
+	require.Equal(t, 1, stats.Retries())
+	require.Equal(t, 2, stats.RegionLookups())
+	require.Equal(t, 1, stats.PDRequests["GetStore"])
+
+	h := stats.Latencies[tikvrpc.CmdPrewrite]
+	require.Equal(t, 1, h.Count)
+	require.GreaterOrEqual(t, h.Max, 20*time.Millisecond)
+	require.Equal(t, h.Sum, h.Max)
+	// The delay falls in (10ms, 100ms].
+	require.Equal(t, []int{0, 0, 0, 1, 0, 0}, h.Buckets)
+	require.Equal(t, 2, stats.Latencies[tikvrpc.CmdGet].Count)
+
+	cluster.ResetStats()
+	stats = cluster.Stats()
+	require.Empty(t, stats.Requests)
+	require.Zero(t, stats.RegionLookups())
+}
@@ -0,0 +1,213 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+// stepWaitTimeout is how long Step waits for a request to arrive.
+const stepWaitTimeout = 10 * time.Second
+
+// ScheduledRequest is a request held by the deterministic scheduler, see
+// EnableScheduler.
+type ScheduledRequest struct {
+	// Seq is the order the request arrives in, starting from 1.
+	Seq     uint64
+	Type    tikvrpc.CmdType
+	StoreID uint64
+	// RegionID is the region in the context of the request.
+	RegionID uint64
+	// Keys are the keys of the requests on keys.
+	Keys [][]byte
+	// TS is the start ts or the read ts of the request, if any.
+	TS uint64
+}
+
+type scheduledRequest struct {
+	ScheduledRequest
+	release chan struct{}
+	done    chan struct{}
+}
+
+type scheduler struct {
+	sync.Mutex
+	enabled bool
+	seq     uint64
+	pending []*scheduledRequest
+	// arrived is closed and replaced when a request arrives.
+	arrived chan struct{}
+}
+
+// EnableScheduler makes the stores hold every request until the test releases
+// it by Step or StepWhere, so the requests sent concurrently are handled in
+// the order chosen by the test. It's used to reproduce the race-dependent
+// bugs deterministically, e.g. interleaving the prewrites and commits of
+// concurrent transactions.
+func (c *Cluster) EnableScheduler() {
+	c.sched.Lock()
+	defer c.sched.Unlock()
+	c.sched.enabled = true
+}
+
+// DisableScheduler stops holding the requests and releases the pending ones.
+func (c *Cluster) DisableScheduler() {
+	c.sched.Lock()
+	defer c.sched.Unlock()
+	c.sched.enabled = false
+	for _, r := range c.sched.pending {
+		close(r.release)
+	}
+	c.sched.pending = nil
+}
+
+// PendingRequests returns the requests held by the scheduler in the order they
+// arrive.
+func (c *Cluster) PendingRequests() []ScheduledRequest {
+	c.sched.Lock()
+	defer c.sched.Unlock()
+	reqs := make([]ScheduledRequest, 0, len(c.sched.pending))
+	for _, r := range c.sched.pending {
+		reqs = append(reqs, r.ScheduledRequest)
+	}
+	return reqs
+}
+
+// WaitPending waits until at least n requests are held by the scheduler, and
+// returns false if it times out.
+func (c *Cluster) WaitPending(n int, timeout time.Duration) bool {
+	if n <= 0 {
+		return true
+	}
+	return c.waitScheduled(func(pending []*scheduledRequest) *scheduledRequest {
+		if len(pending) >= n {
+			return pending[n-1]
+		}
+		return nil
+	}, false, timeout) != nil
+}
+
+// Step releases the earliest pending request and waits until the store
+// finishes handling it. If no request is pending, it waits for one to arrive
+// for a while, and returns false if none arrives.
+func (c *Cluster) Step() bool {
+	return c.StepWhere(func(ScheduledRequest) bool { return true })
+}
+
+// StepWhere is like Step, but releases the earliest pending request matching
+// the function.
+func (c *Cluster) StepWhere(match func(ScheduledRequest) bool) bool {
+	r := c.waitScheduled(func(pending []*scheduledRequest) *scheduledRequest {
+		for _, r := range pending {
+			if match(r.ScheduledRequest) {
+				return r
+			}
+		}
+		return nil
+	}, true, stepWaitTimeout)
+	if r == nil {
+		return false
+	}
+	<-r.done
+	return true
+}
+
+// waitScheduled waits until find returns a pending request, and returns it, or
+// nil if it times out. If release is set, the request is removed from the
+// pending ones and released under the same lock it's found.
+func (c *Cluster) waitScheduled(find func([]*scheduledRequest) *scheduledRequest, release bool, timeout time.Duration) *scheduledRequest {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		c.sched.Lock()
+		if r := find(c.sched.pending); r != nil {
+			if release {
+				c.sched.removePendingLocked(r)
+				close(r.release)
+			}
+			c.sched.Unlock()
+			return r
+		}
+		if c.sched.arrived == nil {
+			c.sched.arrived = make(chan struct{})
+		}
+		arrived := c.sched.arrived
+		c.sched.Unlock()
+		select {
+		case <-arrived:
+		case <-timer.C:
+			return nil
+		}
+	}
+}
+
+// removePendingLocked removes r from the pending requests, and returns false
+// if it's not pending.
+func (s *scheduler) removePendingLocked(r *scheduledRequest) bool {
+	for i, p := range s.pending {
+		if p == r {
+			s.pending = append(s.pending[:i], s.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// schedule holds the request until it's released if the scheduler is enabled.
+// The returned function must be called when the request is handled.
+func (c *Cluster) schedule(ctx context.Context, storeID uint64, req *tikvrpc.Request) (func(), error) {
+	c.sched.Lock()
+	if !c.sched.enabled {
+		c.sched.Unlock()
+		return func() {}, nil
+	}
+	c.sched.seq++
+	r := &scheduledRequest{
+		ScheduledRequest: ScheduledRequest{
+			Seq:      c.sched.seq,
+			Type:     req.Type,
+			StoreID:  storeID,
+			RegionID: req.Context.GetRegionId(),
+			TS:       req.GetStartTS(),
+		},
+		release: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	r.Keys, _, _ = requestKeys(req)
+	c.sched.pending = append(c.sched.pending, r)
+	if c.sched.arrived != nil {
+		close(c.sched.arrived)
+		c.sched.arrived = nil
+	}
+	c.sched.Unlock()
+
+	select {
+	case <-r.release:
+		return func() { close(r.done) }, nil
+	case <-ctx.Done():
+		c.sched.Lock()
+		defer c.sched.Unlock()
+		if c.sched.removePendingLocked(r) {
+			return nil, errors.WithStack(ctx.Err())
+		}
+		// It's released concurrently.
+		return func() { close(r.done) }, nil
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+func TestScheduler(t *testing.T) {
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	cluster := NewCluster(store)
+	storeID, _, _ := BootstrapWithSingleStore(cluster)
+	client := NewRPCClient(cluster, store, nil)
+	defer client.Close()
+
+	region, leader, _, _ := cluster.GetRegionByKey([]byte("k"))
+	addr := cluster.GetStore(storeID).GetAddress()
+	rawPut := func(ctx context.Context, value string) error {
+		req := tikvrpc.NewRequest(tikvrpc.CmdRawPut, &kvrpcpb.RawPutRequest{Key: []byte("k"), Value: []byte(value)})
+		require.Nil(t, tikvrpc.SetContext(req, region, leader))
+		_, err := client.SendRequest(ctx, addr, req, time.Second)
+		return err
+	}
+
+	cluster.EnableScheduler()
+	var wg sync.WaitGroup
+	for i, v := range []string{"v1", "v2"} {
+		v := v
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.Nil(t, rawPut(context.Background(), v))
+		}()
+		require.True(t, cluster.WaitPending(i+1, 5*time.Second))
+	}
+	pending := cluster.PendingRequests()
+	require.Len(t, pending, 2)
+	require.Equal(t, tikvrpc.CmdRawPut, pending[0].Type)
+	require.Equal(t, storeID, pending[0].StoreID)
+	require.Equal(t, region.GetId(), pending[0].RegionID)
+	require.Equal(t, [][]byte{[]byte("k")}, pending[0].Keys)
+	require.Less(t, pending[0].Seq, pending[1].Seq)
+
+	// Handle the puts in the reversed order of their arrival.
+	require.True(t, cluster.StepWhere(func(r ScheduledRequest) bool { return r.Seq == pending[1].Seq }))
+	require.Equal(t, []byte("v2"), store.RawGet("", []byte("k")))
+	require.Len(t, cluster.PendingRequests(), 1)
+	require.True(t, cluster.Step())
+	wg.Wait()
+	require.Equal(t, []byte("v1"), store.RawGet("", []byte("k")))
+	require.Empty(t, cluster.PendingRequests())
+
+	// The request whose context is canceled leaves the scheduler.
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- rawPut(ctx, "v3") }()
+	require.True(t, cluster.WaitPending(1, 5*time.Second))
+	cancel()
+	require.ErrorIs(t, <-errCh, context.Canceled)
+	require.Empty(t, cluster.PendingRequests())
+
+	// Disabling the scheduler releases the pending requests.
+	go func() { errCh <- rawPut(context.Background(), "v4") }()
+	require.True(t, cluster.WaitPending(1, 5*time.Second))
+	cluster.DisableScheduler()
+	require.Nil(t, <-errCh)
+	require.Equal(t, []byte("v4"), store.RawGet("", []byte("k")))
+
+	// The request stepped is not affected by the ones leaving concurrently.
+	cluster.EnableScheduler()
+	defer cluster.DisableScheduler()
+	for i := 0; i < 20; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() { errCh <- rawPut(ctx, "v5") }()
+		require.True(t, cluster.WaitPending(1, 5*time.Second))
+		done := make(chan error, 1)
+		go func() { done <- rawPut(context.Background(), "v6") }()
+		require.True(t, cluster.WaitPending(2, 5*time.Second))
+		seq := cluster.PendingRequests()[1].Seq
+		go cancel()
+		require.True(t, cluster.StepWhere(func(r ScheduledRequest) bool { return r.Seq == seq }))
+		require.Nil(t, <-done)
+		require.ErrorIs(t, <-errCh, context.Canceled)
+		require.Equal(t, []byte("v6"), store.RawGet("", []byte("k")))
+	}
+	require.Empty(t, cluster.PendingRequests())
+}
@@ -86,6 +86,7 @@ func (s *Session) GetResolvedLocks() []uint64 {
 
 // CheckRequestContext checks if the context matches the request status.
 func (s *Session) CheckRequestContext(ctx *kvrpcpb.Context) *errorpb.Error {
+	s.checkStrictContext(ctx)
 	ctxPeer := ctx.GetPeer()
 	if ctxPeer != nil && ctxPeer.GetStoreId() != s.storeID {
 		return &errorpb.Error{
@@ -96,6 +97,9 @@ func (s *Session) CheckRequestContext(ctx *kvrpcpb.Context) *errorpb.Error {
 	region, leaderID := s.cluster.GetRegion(ctx.GetRegionId())
 	// No region found.
 	if region == nil {
+		if mergedErr := s.cluster.mergedRegionError(ctx.GetRegionId()); mergedErr != nil {
+			return mergedErr
+		}
 		return &errorpb.Error{
 			Message: *proto.String("region not found"),
 			RegionNotFound: &errorpb.RegionNotFound{
@@ -121,6 +125,15 @@ func (s *Session) CheckRequestContext(ctx *kvrpcpb.Context) *errorpb.Error {
 			},
 		}
 	}
+	// A witness has no data to serve any request.
+	if storePeer.GetIsWitness() {
+		return &errorpb.Error{
+			Message: *proto.String("peer is witness"),
+			IsWitness: &errorpb.IsWitness{
+				RegionId: *proto.Uint64(ctx.GetRegionId()),
+			},
+		}
+	}
 	// No leader.
 	if leaderPeer == nil {
 		return &errorpb.Error{
@@ -154,6 +167,9 @@ func (s *Session) CheckRequestContext(ctx *kvrpcpb.Context) *errorpb.Error {
 			},
 		}
 	}
+	if store := s.cluster.GetRegionMVCCStore(region.GetId()); store != nil {
+		s.mvccStore = store
+	}
 	s.startKey, s.endKey = region.StartKey, region.EndKey
 	s.isolationLevel = ctx.IsolationLevel
 	s.resolvedLocks = ctx.ResolvedLocks
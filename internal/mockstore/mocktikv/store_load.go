@@ -0,0 +1,134 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/errorpb"
+)
+
+// storeLoadWindow is the length of the window used to count the QPS of a store.
+const storeLoadWindow = time.Second
+
+// StoreCapacity limits the simulated load a mock store accepts. A zero value
+// of any field means no limit. Requests exceeding the capacity are rejected
+// with a ServerIsBusy region error.
+type StoreCapacity struct {
+	// MaxQPS is the max number of requests the store handles in one second.
+	MaxQPS uint64
+	// MaxPendingTasks is the max number of requests the store handles concurrently.
+	MaxPendingTasks uint64
+	// BackoffMs is the suggested backoff time carried by the ServerIsBusy error.
+	BackoffMs uint64
+}
+
+// StoreLoad is a snapshot of the simulated load of a mock store.
+type StoreLoad struct {
+	// QPS is the number of requests accepted in the current one-second window.
+	QPS uint64
+	// PendingTasks is the number of requests being handled.
+	PendingTasks uint64
+	// Total is the number of requests accepted since the last reset.
+	Total uint64
+	// Rejected is the number of requests rejected with ServerIsBusy since the last reset.
+	Rejected uint64
+}
+
+type storeLoad struct {
+	capacity    StoreCapacity
+	windowStart time.Time
+	StoreLoad
+}
+
+// SetStoreCapacity sets the simulated capacity of a store. Pass a zero
+// StoreCapacity to remove the limit.
+func (c *Cluster) SetStoreCapacity(storeID uint64, capacity StoreCapacity) {
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	c.getStoreLoadNoLock(storeID).capacity = capacity
+}
+
+// GetStoreLoad returns the simulated load of a store.
+func (c *Cluster) GetStoreLoad(storeID uint64) StoreLoad {
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	load := c.getStoreLoadNoLock(storeID)
+	load.rollWindow(time.Now())
+	return load.StoreLoad
+}
+
+// ResetStoreLoad clears the load counters of a store. The capacity and the
+// requests being handled are kept.
+func (c *Cluster) ResetStoreLoad(storeID uint64) {
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	load := c.getStoreLoadNoLock(storeID)
+	load.StoreLoad = StoreLoad{PendingTasks: load.PendingTasks}
+	load.windowStart = time.Now()
+}
+
+func (c *Cluster) getStoreLoadNoLock(storeID uint64) *storeLoad {
+	load, ok := c.storeLoads[storeID]
+	if !ok {
+		load = &storeLoad{windowStart: time.Now()}
+		c.storeLoads[storeID] = load
+	}
+	return load
+}
+
+// acquireStoreLoad records a new request on the store. It returns a
+// ServerIsBusy error if the store is overloaded, otherwise the caller must
+// call releaseStoreLoad after the request is handled.
+func (c *Cluster) acquireStoreLoad(storeID uint64) *errorpb.ServerIsBusy {
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	load := c.getStoreLoadNoLock(storeID)
+	load.rollWindow(time.Now())
+	capacity := load.capacity
+	var reason string
+	if capacity.MaxPendingTasks > 0 && load.PendingTasks >= capacity.MaxPendingTasks {
+		reason = "mock store pending tasks exceed capacity"
+	} else if capacity.MaxQPS > 0 && load.QPS >= capacity.MaxQPS {
+		reason = "mock store qps exceeds capacity"
+	}
+	if len(reason) > 0 {
+		load.Rejected++
+		return &errorpb.ServerIsBusy{
+			Reason:    reason,
+			BackoffMs: capacity.BackoffMs,
+		}
+	}
+	load.QPS++
+	load.PendingTasks++
+	load.Total++
+	return nil
+}
+
+func (c *Cluster) releaseStoreLoad(storeID uint64) {
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	load := c.getStoreLoadNoLock(storeID)
+	if load.PendingTasks > 0 {
+		load.PendingTasks--
+	}
+}
+
+func (l *storeLoad) rollWindow(now time.Time) {
+	if now.Sub(l.windowStart) >= storeLoadWindow {
+		l.QPS = 0
+		l.windowStart = now
+	}
+}
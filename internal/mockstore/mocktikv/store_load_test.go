@@ -0,0 +1,133 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+func TestStoreLoadCapacity(t *testing.T) {
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	cluster := NewCluster(store)
+	storeID, _, _ := BootstrapWithSingleStore(cluster)
+	client := NewRPCClient(cluster, store, nil)
+	defer client.Close()
+
+	region, leader, _, _ := cluster.GetRegionByKey([]byte("a"))
+	addr := cluster.GetStore(storeID).GetAddress()
+	sendRawGet := func() *tikvrpc.Response {
+		req := tikvrpc.NewRequest(tikvrpc.CmdRawGet, &kvrpcpb.RawGetRequest{Key: []byte("a")})
+		require.Nil(t, tikvrpc.SetContext(req, region, leader))
+		resp, err := client.SendRequest(context.Background(), addr, req, time.Second)
+		require.Nil(t, err)
+		return resp
+	}
+
+	cluster.SetStoreCapacity(storeID, StoreCapacity{MaxQPS: 2, BackoffMs: 10})
+	for i := 0; i < 2; i++ {
+		regionErr, err := sendRawGet().GetRegionError()
+		require.Nil(t, err)
+		require.Nil(t, regionErr)
+	}
+	regionErr, err := sendRawGet().GetRegionError()
+	require.Nil(t, err)
+	require.NotNil(t, regionErr.GetServerIsBusy())
+	require.Equal(t, uint64(10), regionErr.GetServerIsBusy().GetBackoffMs())
+
+	load := cluster.GetStoreLoad(storeID)
+	require.Equal(t, uint64(2), load.QPS)
+	require.Equal(t, uint64(2), load.Total)
+	require.Equal(t, uint64(1), load.Rejected)
+	require.Equal(t, uint64(0), load.PendingTasks)
+
+	cluster.ResetStoreLoad(storeID)
+	regionErr, err = sendRawGet().GetRegionError()
+	require.Nil(t, err)
+	require.Nil(t, regionErr)
+
+	cluster.SetStoreCapacity(storeID, StoreCapacity{MaxPendingTasks: 1})
+	require.Nil(t, cluster.acquireStoreLoad(storeID))
+	regionErr, err = sendRawGet().GetRegionError()
+	require.Nil(t, err)
+	require.NotNil(t, regionErr.GetServerIsBusy())
+	cluster.releaseStoreLoad(storeID)
+	regionErr, err = sendRawGet().GetRegionError()
+	require.Nil(t, err)
+	require.Nil(t, regionErr)
+}
+
+func TestStoreStorage(t *testing.T) {
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	cluster := NewCluster(store)
+	storeID, _, _ := BootstrapWithSingleStore(cluster)
+	client := NewRPCClient(cluster, store, nil)
+	defer client.Close()
+
+	region, leader, _, _ := cluster.GetRegionByKey([]byte("a"))
+	addr := cluster.GetStore(storeID).GetAddress()
+	sendRawPut := func(key string, opt kvrpcpb.DiskFullOpt) *tikvrpc.Response {
+		req := tikvrpc.NewRequest(tikvrpc.CmdRawPut, &kvrpcpb.RawPutRequest{Key: []byte(key), Value: []byte("0123456789")})
+		require.Nil(t, tikvrpc.SetContext(req, region, leader))
+		req.Context.DiskFullOpt = opt
+		resp, err := client.SendRequest(context.Background(), addr, req, time.Second)
+		require.Nil(t, err)
+		return resp
+	}
+
+	stats := cluster.GetStoreStats(storeID)
+	require.Equal(t, uint64(0), stats.Capacity)
+	require.Equal(t, uint32(1), stats.RegionCount)
+
+	// Each put uses 11 bytes.
+	cluster.SetStoreStorage(storeID, StoreStorage{Capacity: 100, ExtraUsedSize: 67, AlmostFullSize: 20})
+	regionErr, err := sendRawPut("a", kvrpcpb.DiskFullOpt_NotAllowedOnFull).GetRegionError()
+	require.Nil(t, err)
+	require.Nil(t, regionErr)
+	stats = cluster.GetStoreStats(storeID)
+	require.Equal(t, uint64(78), stats.UsedSize)
+	require.Equal(t, uint64(22), stats.Available)
+
+	regionErr, err = sendRawPut("b", kvrpcpb.DiskFullOpt_NotAllowedOnFull).GetRegionError()
+	require.Nil(t, err)
+	require.Nil(t, regionErr)
+	// Almost full.
+	regionErr, err = sendRawPut("c", kvrpcpb.DiskFullOpt_NotAllowedOnFull).GetRegionError()
+	require.Nil(t, err)
+	require.Equal(t, []uint64{storeID}, regionErr.GetDiskFull().GetStoreId())
+	regionErr, err = sendRawPut("c", kvrpcpb.DiskFullOpt_AllowedOnAlmostFull).GetRegionError()
+	require.Nil(t, err)
+	require.Nil(t, regionErr)
+	// Already full.
+	require.Equal(t, uint64(0), cluster.GetStoreStats(storeID).Available)
+	regionErr, err = sendRawPut("d", kvrpcpb.DiskFullOpt_AllowedOnAlmostFull).GetRegionError()
+	require.Nil(t, err)
+	require.NotNil(t, regionErr.GetDiskFull())
+	regionErr, err = sendRawPut("d", kvrpcpb.DiskFullOpt_AllowedOnAlreadyFull).GetRegionError()
+	require.Nil(t, err)
+	require.Nil(t, regionErr)
+
+	cluster.SetStoreStorage(storeID, StoreStorage{})
+	regionErr, err = sendRawPut("e", kvrpcpb.DiskFullOpt_NotAllowedOnFull).GetRegionError()
+	require.Nil(t, err)
+	require.Nil(t, regionErr)
+}
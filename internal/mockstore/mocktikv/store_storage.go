@@ -0,0 +1,142 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"github.com/pingcap/kvproto/pkg/errorpb"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+// StoreStorage is the simulated storage of a mock store.
+type StoreStorage struct {
+	// Capacity is the size of the disk of the store in bytes. Zero means
+	// unlimited, and the store is never full.
+	Capacity uint64
+	// ExtraUsedSize is the size in bytes used besides the data of the regions
+	// with peers on the store, e.g. by the logs and the other processes.
+	ExtraUsedSize uint64
+	// AlmostFullSize is the available size in bytes below which the store is
+	// almost full. The writes are rejected by an almost full store, unless
+	// they are allowed on almost full by the DiskFullOpt. The writes allowed on
+	// already full are accepted until the available size is zero.
+	AlmostFullSize uint64
+}
+
+// SetStoreStorage sets the simulated storage of a store. Pass a zero
+// StoreStorage to make the store unlimited.
+func (c *Cluster) SetStoreStorage(storeID uint64, storage StoreStorage) {
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	if storage == (StoreStorage{}) {
+		delete(c.storeStorages, storeID)
+		return
+	}
+	c.storeStorages[storeID] = storage
+}
+
+// GetStoreStats returns the storage stats of a store like the ones reported
+// to PD. The used size is the size of the data of the regions with peers on
+// the store plus StoreStorage.ExtraUsedSize, and the capacity of an unlimited
+// store is reported as zero.
+func (c *Cluster) GetStoreStats(storeID uint64) *pdpb.StoreStats {
+	c.loadMu.Lock()
+	storage := c.storeStorages[storeID]
+	c.loadMu.Unlock()
+
+	stats := &pdpb.StoreStats{
+		StoreId:  storeID,
+		Capacity: storage.Capacity,
+	}
+	var regionIDs []uint64
+	c.RLock()
+	for id, region := range c.regions {
+		for _, peer := range region.Meta.Peers {
+			if peer.GetStoreId() == storeID {
+				regionIDs = append(regionIDs, id)
+				break
+			}
+		}
+	}
+	c.RUnlock()
+	stats.RegionCount = uint32(len(regionIDs))
+	stats.UsedSize = storage.ExtraUsedSize + c.regionsDataSize(regionIDs)
+	if stats.Capacity > stats.UsedSize {
+		stats.Available = stats.Capacity - stats.UsedSize
+	}
+	return stats
+}
+
+// regionsDataSize returns the total size of the data in the regions.
+func (c *Cluster) regionsDataSize(regionIDs []uint64) uint64 {
+	var size uint64
+	for _, id := range regionIDs {
+		regionSize, _ := c.regionDataSize(id)
+		size += regionSize
+	}
+	return size
+}
+
+// regionDataSize returns the size and the number of the keys of the data in the
+// region.
+func (c *Cluster) regionDataSize(regionID uint64) (size, keys uint64) {
+	store := c.GetRegionMVCCStore(regionID)
+	c.RLock()
+	region := c.regions[regionID]
+	if region == nil {
+		c.RUnlock()
+		return 0, 0
+	}
+	start, end := region.Meta.StartKey, region.Meta.EndKey
+	if store == nil {
+		store = c.mvccStore
+	}
+	c.RUnlock()
+	return approximateRangeSize(store, MvccKey(start).Raw(), MvccKey(end).Raw())
+}
+
+// checkStoreDiskFull returns a DiskFull error if the request writes data to a
+// store which is full according to its simulated storage. Only the writes
+// adding data are rejected, so the transactions can still be rolled back.
+func (c *Cluster) checkStoreDiskFull(storeID uint64, req *tikvrpc.Request) *errorpb.DiskFull {
+	switch req.Type {
+	case tikvrpc.CmdPrewrite, tikvrpc.CmdPessimisticLock, tikvrpc.CmdRawPut, tikvrpc.CmdRawBatchPut:
+	default:
+		return nil
+	}
+	c.loadMu.Lock()
+	storage, ok := c.storeStorages[storeID]
+	c.loadMu.Unlock()
+	if !ok || storage.Capacity == 0 {
+		return nil
+	}
+	available := c.GetStoreStats(storeID).Available
+	var reason string
+	switch {
+	case available == 0:
+		if req.Context.DiskFullOpt != kvrpcpb.DiskFullOpt_AllowedOnAlreadyFull {
+			reason = "disk already full"
+		}
+	case available < storage.AlmostFullSize:
+		if req.Context.DiskFullOpt == kvrpcpb.DiskFullOpt_NotAllowedOnFull {
+			reason = "disk almost full"
+		}
+	}
+	if len(reason) == 0 {
+		return nil
+	}
+	return &errorpb.DiskFull{StoreId: []uint64{storeID}, Reason: reason}
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"io"
+	"sync"
+
+	"github.com/pingcap/kvproto/pkg/coprocessor"
+	"github.com/pingcap/kvproto/pkg/tikvpb"
+	"github.com/tikv/client-go/v2/internal/client/mockserver"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+// StreamInterruption breaks the streaming responses mid-stream.
+type StreamInterruption struct {
+	// AfterMessages is the number of the messages received before the stream
+	// breaks, 0 breaks it before the first message.
+	AfterMessages int
+	// Times is the number of the streams to break, 0 means all of them.
+	Times int
+}
+
+type streamFaultKey struct {
+	storeID uint64
+	cmd     tikvrpc.CmdType
+}
+
+type streamFaults struct {
+	sync.Mutex
+	faults map[streamFaultKey]*StreamInterruption
+}
+
+// InterruptStreams breaks the streaming responses of cmd, i.e. CmdCopStream or
+// CmdBatchCop, from the store after some messages, to test the handling of the
+// partial results and the stream recreation. StoreID 0 applies to all the
+// stores. Pass a nil si to remove the interruption.
+func (c *Cluster) InterruptStreams(storeID uint64, cmd tikvrpc.CmdType, si *StreamInterruption) {
+	c.streamFaults.Lock()
+	defer c.streamFaults.Unlock()
+	key := streamFaultKey{storeID, cmd}
+	if si == nil {
+		delete(c.streamFaults.faults, key)
+		return
+	}
+	if c.streamFaults.faults == nil {
+		c.streamFaults.faults = make(map[streamFaultKey]*StreamInterruption)
+	}
+	cp := *si
+	c.streamFaults.faults[key] = &cp
+}
+
+// takeStreamInterruption returns the interruption of the next stream of cmd
+// from the store, and counts it down.
+func (c *Cluster) takeStreamInterruption(storeID uint64, cmd tikvrpc.CmdType) (StreamInterruption, bool) {
+	c.streamFaults.Lock()
+	defer c.streamFaults.Unlock()
+	for _, key := range []streamFaultKey{{storeID, cmd}, {0, cmd}} {
+		si, ok := c.streamFaults.faults[key]
+		if !ok {
+			continue
+		}
+		if si.Times > 0 {
+			si.Times--
+			if si.Times == 0 {
+				delete(c.streamFaults.faults, key)
+			}
+		}
+		return *si, true
+	}
+	return StreamInterruption{}, false
+}
+
+// interruptCopStream makes the cop stream break as configured by
+// InterruptStreams.
+func (c *Cluster) interruptCopStream(storeID uint64, resp *tikvrpc.CopStreamResponse) (*tikvrpc.CopStreamResponse, error) {
+	si, ok := c.takeStreamInterruption(storeID, tikvrpc.CmdCopStream)
+	if !ok {
+		return resp, nil
+	}
+	if si.AfterMessages == 0 {
+		return nil, mockserver.ErrConnectionReset
+	}
+	interrupted := *resp
+	interrupted.Tikv_CoprocessorStreamClient = &interruptedCopStream{
+		Tikv_CoprocessorStreamClient: resp.Tikv_CoprocessorStreamClient,
+		remaining:                    si.AfterMessages - 1,
+	}
+	return &interrupted, nil
+}
+
+// interruptBatchCopStream makes the batch cop stream break as configured by
+// InterruptStreams.
+func (c *Cluster) interruptBatchCopStream(storeID uint64, resp *tikvrpc.BatchCopStreamResponse) (*tikvrpc.BatchCopStreamResponse, error) {
+	si, ok := c.takeStreamInterruption(storeID, tikvrpc.CmdBatchCop)
+	if !ok {
+		return resp, nil
+	}
+	if si.AfterMessages == 0 {
+		return nil, mockserver.ErrConnectionReset
+	}
+	interrupted := *resp
+	interrupted.Tikv_BatchCoprocessorClient = &interruptedBatchCopStream{
+		Tikv_BatchCoprocessorClient: resp.Tikv_BatchCoprocessorClient,
+		remaining:                   si.AfterMessages - 1,
+	}
+	return &interrupted, nil
+}
+
+// interruptedCopStream returns mockserver.ErrConnectionReset after receiving
+// remaining messages following the first one.
+type interruptedCopStream struct {
+	tikvpb.Tikv_CoprocessorStreamClient
+	remaining int
+}
+
+func (s *interruptedCopStream) Recv() (*coprocessor.Response, error) {
+	if s.remaining == 0 {
+		return nil, mockserver.ErrConnectionReset
+	}
+	s.remaining--
+	if s.Tikv_CoprocessorStreamClient == nil {
+		return nil, io.EOF
+	}
+	return s.Tikv_CoprocessorStreamClient.Recv()
+}
+
+type interruptedBatchCopStream struct {
+	tikvpb.Tikv_BatchCoprocessorClient
+	remaining int
+}
+
+func (s *interruptedBatchCopStream) Recv() (*coprocessor.BatchResponse, error) {
+	if s.remaining == 0 {
+		return nil, mockserver.ErrConnectionReset
+	}
+	s.remaining--
+	if s.Tikv_BatchCoprocessorClient == nil {
+		return nil, io.EOF
+	}
+	return s.Tikv_BatchCoprocessorClient.Recv()
+}
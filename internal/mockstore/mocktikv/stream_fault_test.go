@@ -0,0 +1,130 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/coprocessor"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pingcap/kvproto/pkg/tikvpb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/internal/client/mockserver"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+// streamCoprHandler streams 3 messages for every cop stream request.
+type streamCoprHandler struct{}
+
+func (h streamCoprHandler) HandleCmdCop(reqCtx *kvrpcpb.Context, session *Session, r *coprocessor.Request) *coprocessor.Response {
+	return &coprocessor.Response{}
+}
+
+func (h streamCoprHandler) HandleBatchCop(ctx context.Context, reqCtx *kvrpcpb.Context, session *Session, r *coprocessor.BatchRequest, timeout time.Duration) (*tikvrpc.BatchCopStreamResponse, error) {
+	return &tikvrpc.BatchCopStreamResponse{BatchResponse: &coprocessor.BatchResponse{Data: []byte("0")}}, nil
+}
+
+func (h streamCoprHandler) HandleCopStream(ctx context.Context, reqCtx *kvrpcpb.Context, session *Session, r *coprocessor.Request, timeout time.Duration) (*tikvrpc.CopStreamResponse, error) {
+	return &tikvrpc.CopStreamResponse{
+		Tikv_CoprocessorStreamClient: &sliceCopStream{msgs: []string{"1", "2"}},
+		Response:                     &coprocessor.Response{Data: []byte("0")},
+	}, nil
+}
+
+func (h streamCoprHandler) Close() {}
+
+type sliceCopStream struct {
+	tikvpb.Tikv_CoprocessorStreamClient
+	msgs []string
+}
+
+func (s *sliceCopStream) Recv() (*coprocessor.Response, error) {
+	if len(s.msgs) == 0 {
+		return nil, io.EOF
+	}
+	resp := &coprocessor.Response{Data: []byte(s.msgs[0])}
+	s.msgs = s.msgs[1:]
+	return resp, nil
+}
+
+func TestInterruptStreams(t *testing.T) {
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	cluster := NewCluster(store)
+	storeID, _, _ := BootstrapWithSingleStore(cluster)
+	client := NewRPCClient(cluster, store, streamCoprHandler{})
+	defer client.Close()
+	addr := cluster.GetStore(storeID).GetAddress()
+
+	// readAll returns the messages of the stream and the error ending it.
+	readAll := func() ([]string, error) {
+		req := tikvrpc.NewRequest(tikvrpc.CmdCopStream, &coprocessor.Request{})
+		region, _, _, _ := cluster.GetRegionByKey([]byte("a"))
+		require.Nil(t, tikvrpc.SetContext(req, region, region.GetPeers()[0]))
+		resp, err := client.SendRequest(context.Background(), addr, req, time.Second)
+		if err != nil {
+			return nil, err
+		}
+		stream := resp.Resp.(*tikvrpc.CopStreamResponse)
+		msgs := []string{string(stream.Response.Data)}
+		for {
+			r, err := stream.Recv()
+			if errors.Cause(err) == io.EOF {
+				return msgs, nil
+			}
+			if err != nil {
+				return msgs, err
+			}
+			msgs = append(msgs, string(r.Data))
+		}
+	}
+
+	msgs, err := readAll()
+	require.Nil(t, err)
+	require.Equal(t, []string{"0", "1", "2"}, msgs)
+
+	// The first stream breaks after 2 messages, the next one isn't affected.
+	cluster.InterruptStreams(storeID, tikvrpc.CmdCopStream, &StreamInterruption{AfterMessages: 2, Times: 1})
+	msgs, err = readAll()
+	require.ErrorIs(t, err, mockserver.ErrConnectionReset)
+	require.Equal(t, []string{"0", "1"}, msgs)
+	msgs, err = readAll()
+	require.Nil(t, err)
+	require.Len(t, msgs, 3)
+
+	// All the streams of all the stores break before the first message.
+	cluster.InterruptStreams(0, tikvrpc.CmdCopStream, &StreamInterruption{})
+	for i := 0; i < 2; i++ {
+		_, err = readAll()
+		require.ErrorIs(t, err, mockserver.ErrConnectionReset)
+	}
+	cluster.InterruptStreams(0, tikvrpc.CmdCopStream, nil)
+	_, err = readAll()
+	require.Nil(t, err)
+
+	// The batch cop stream breaks after the first message.
+	cluster.InterruptStreams(storeID, tikvrpc.CmdBatchCop, &StreamInterruption{AfterMessages: 1, Times: 1})
+	req := tikvrpc.NewRequest(tikvrpc.CmdBatchCop, &coprocessor.BatchRequest{})
+	resp, err := client.SendRequest(context.Background(), addr, req, time.Second)
+	require.Nil(t, err)
+	batchStream := resp.Resp.(*tikvrpc.BatchCopStreamResponse)
+	require.Equal(t, "0", string(batchStream.BatchResponse.Data))
+	_, err = batchStream.Recv()
+	require.ErrorIs(t, err, mockserver.ErrConnectionReset)
+}
@@ -0,0 +1,110 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pkg/errors"
+)
+
+// ContextCheck is a set of the fields of kvrpcpb.Context validated in the
+// strict mode.
+type ContextCheck uint
+
+const (
+	// ContextCheckPeer checks the region ID and the peer are set, and the peer
+	// is a peer of the region on the store serving the request.
+	ContextCheckPeer ContextCheck = 1 << iota
+	// ContextCheckEpoch checks the region epoch is set. A stale epoch is not a
+	// violation, it's handled as EpochNotMatch like TiKV.
+	ContextCheckEpoch
+	// ContextCheckBuckets checks the buckets version is not newer than the
+	// buckets of the region.
+	ContextCheckBuckets
+	// ContextCheckResourceGroup checks the resource control context is set.
+	ContextCheckResourceGroup
+
+	// ContextCheckAll enables all the checks.
+	ContextCheckAll = ContextCheckPeer | ContextCheckEpoch | ContextCheckBuckets | ContextCheckResourceGroup
+)
+
+type strictContextCheck struct {
+	checks      ContextCheck
+	onViolation func(error)
+}
+
+// SetStrictContextCheck makes the cluster validate the context of every
+// request with checks, and call onViolation with the violations, e.g. to fail
+// the test by t.Error. The requests are served as usual regardless of the
+// violations. Pass 0 checks to disable the strict mode.
+func (c *Cluster) SetStrictContextCheck(checks ContextCheck, onViolation func(error)) {
+	if checks == 0 || onViolation == nil {
+		c.strictContext.Store(nil)
+		return
+	}
+	c.strictContext.Store(&strictContextCheck{checks: checks, onViolation: onViolation})
+}
+
+func (s *Session) checkStrictContext(ctx *kvrpcpb.Context) {
+	check := s.cluster.strictContext.Load()
+	if check == nil {
+		return
+	}
+	if err := check.validate(s.cluster, s.storeID, ctx); err != nil {
+		check.onViolation(err)
+	}
+}
+
+func (c *strictContextCheck) validate(cluster *Cluster, storeID uint64, ctx *kvrpcpb.Context) error {
+	if ctx.GetRegionId() == 0 {
+		return errors.Errorf("strict context: region id is not set, ctx: %v", ctx)
+	}
+	region, _, buckets, _ := cluster.GetRegionByID(ctx.GetRegionId())
+	if region == nil {
+		// The region may be merged, let the normal check handle it.
+		return nil
+	}
+	if c.checks&ContextCheckPeer != 0 {
+		peer := ctx.GetPeer()
+		if peer == nil {
+			return errors.Errorf("strict context: peer is not set, ctx: %v", ctx)
+		}
+		if peer.GetStoreId() != storeID {
+			return errors.Errorf("strict context: peer store %d doesn't match the store %d, ctx: %v", peer.GetStoreId(), storeID, ctx)
+		}
+		found := false
+		for _, p := range region.GetPeers() {
+			if p.GetId() == peer.GetId() && p.GetStoreId() == peer.GetStoreId() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.Errorf("strict context: peer %d is not a peer of region %d, ctx: %v", peer.GetId(), region.GetId(), ctx)
+		}
+	}
+	if c.checks&ContextCheckEpoch != 0 {
+		if ctx.GetRegionEpoch() == nil {
+			return errors.Errorf("strict context: region epoch is not set, ctx: %v", ctx)
+		}
+	}
+	if c.checks&ContextCheckBuckets != 0 && ctx.GetBucketsVersion() > buckets.GetVersion() {
+		return errors.Errorf("strict context: buckets version %d is newer than the region's %d, ctx: %v", ctx.GetBucketsVersion(), buckets.GetVersion(), ctx)
+	}
+	if c.checks&ContextCheckResourceGroup != 0 && ctx.GetResourceControlContext() == nil {
+		return errors.Errorf("strict context: resource control context is not set, ctx: %v", ctx)
+	}
+	return nil
+}
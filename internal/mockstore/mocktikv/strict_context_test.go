@@ -0,0 +1,81 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+func TestStrictContextCheck(t *testing.T) {
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	cluster := NewCluster(store)
+	storeID, _, regionID := BootstrapWithSingleStore(cluster)
+	client := NewRPCClient(cluster, store, nil)
+	defer client.Close()
+	addr := cluster.GetStore(storeID).GetAddress()
+
+	var violations []error
+	cluster.SetStrictContextCheck(ContextCheckAll, func(err error) {
+		violations = append(violations, err)
+	})
+	send := func(modify func(ctx *kvrpcpb.Context)) {
+		req := tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: []byte("k"), Version: 10})
+		region, leader, _, _ := cluster.GetRegionByID(regionID)
+		require.Nil(t, tikvrpc.SetContext(req, region, leader))
+		req.Context.ResourceControlContext = &kvrpcpb.ResourceControlContext{ResourceGroupName: "default"}
+		modify(&req.Context)
+		_, err := client.SendRequest(context.Background(), addr, req, time.Second)
+		require.Nil(t, err)
+	}
+
+	send(func(*kvrpcpb.Context) {})
+	require.Empty(t, violations)
+
+	cases := []struct {
+		modify func(ctx *kvrpcpb.Context)
+		msg    string
+	}{
+		{func(ctx *kvrpcpb.Context) { ctx.Peer = nil }, "peer is not set"},
+		{func(ctx *kvrpcpb.Context) { ctx.Peer.Id = 12345 }, "is not a peer of region"},
+		{func(ctx *kvrpcpb.Context) { ctx.RegionEpoch = nil }, "region epoch is not set"},
+		{func(ctx *kvrpcpb.Context) { ctx.BucketsVersion = 100 }, "buckets version"},
+		{func(ctx *kvrpcpb.Context) { ctx.ResourceControlContext = nil }, "resource control context"},
+	}
+	for _, c := range cases {
+		violations = nil
+		send(c.modify)
+		require.Len(t, violations, 1)
+		require.Contains(t, violations[0].Error(), c.msg)
+	}
+
+	// Only the enabled checks are applied.
+	violations = nil
+	cluster.SetStrictContextCheck(ContextCheckPeer, func(err error) {
+		violations = append(violations, err)
+	})
+	send(func(ctx *kvrpcpb.Context) { ctx.ResourceControlContext = nil })
+	require.Empty(t, violations)
+
+	cluster.SetStrictContextCheck(0, nil)
+	send(func(ctx *kvrpcpb.Context) { ctx.Peer = nil })
+	require.Empty(t, violations)
+}
@@ -0,0 +1,177 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/kvproto/pkg/metapb"
+)
+
+// StoreEvent is a change of a store in the cluster, see WatchStores.
+type StoreEvent struct {
+	// Store is the meta of the store after the change, or the last meta of it
+	// if it's removed.
+	Store *metapb.Store
+	// Removed is true if the store is removed from the cluster.
+	Removed bool
+}
+
+// RegionEvent is a change of a region in the cluster, see WatchRegions.
+type RegionEvent struct {
+	// Region is the meta of the region after the change, or the last meta of
+	// it if it's removed.
+	Region *metapb.Region
+	// Leader is the leader of the region, it's nil if the region has no leader.
+	Leader *metapb.Peer
+	// Removed is true if the region is removed from the cluster, e.g. merged
+	// into another region.
+	Removed bool
+}
+
+// watcher delivers the events to a channel in order. The events are queued
+// without limit, so the changes of the cluster never wait for the watchers.
+type watcher[T any] struct {
+	mu      sync.Mutex
+	pending []T
+	notify  chan struct{}
+	ch      chan T
+}
+
+func newWatcher[T any]() *watcher[T] {
+	return &watcher[T]{
+		notify: make(chan struct{}, 1),
+		ch:     make(chan T),
+	}
+}
+
+func (w *watcher[T]) push(e T) {
+	w.mu.Lock()
+	w.pending = append(w.pending, e)
+	w.mu.Unlock()
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers the events until ctx is done, and then closes the channel.
+func (w *watcher[T]) run(ctx context.Context, done func()) {
+	defer func() {
+		done()
+		close(w.ch)
+	}()
+	for {
+		w.mu.Lock()
+		events := w.pending
+		w.pending = nil
+		w.mu.Unlock()
+		for _, e := range events {
+			select {
+			case w.ch <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case <-w.notify:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+type clusterWatchers struct {
+	sync.Mutex
+	stores  map[*watcher[StoreEvent]]struct{}
+	regions map[*watcher[RegionEvent]]struct{}
+}
+
+// WatchStores returns a channel receiving the changes of the stores from now
+// on, e.g. the stores added, removed, stopped or whose addresses or labels are
+// updated. The channel is closed when ctx is done.
+func (c *Cluster) WatchStores(ctx context.Context) <-chan StoreEvent {
+	w := newWatcher[StoreEvent]()
+	c.watchers.Lock()
+	if c.watchers.stores == nil {
+		c.watchers.stores = make(map[*watcher[StoreEvent]]struct{})
+	}
+	c.watchers.stores[w] = struct{}{}
+	c.watchers.Unlock()
+	go w.run(ctx, func() {
+		c.watchers.Lock()
+		delete(c.watchers.stores, w)
+		c.watchers.Unlock()
+	})
+	return w.ch
+}
+
+// WatchRegions returns a channel receiving the changes of the regions from now
+// on, e.g. the regions split, merged, or whose peers or leaders are changed.
+// A split sends both the regions, and a merge sends the region merged into
+// and the removed one. The channel is closed when ctx is done.
+func (c *Cluster) WatchRegions(ctx context.Context) <-chan RegionEvent {
+	w := newWatcher[RegionEvent]()
+	c.watchers.Lock()
+	if c.watchers.regions == nil {
+		c.watchers.regions = make(map[*watcher[RegionEvent]]struct{})
+	}
+	c.watchers.regions[w] = struct{}{}
+	c.watchers.Unlock()
+	go w.run(ctx, func() {
+		c.watchers.Lock()
+		delete(c.watchers.regions, w)
+		c.watchers.Unlock()
+	})
+	return w.ch
+}
+
+// notifyStore sends the change of the store to the watchers. The cluster lock
+// must be held.
+func (c *Cluster) notifyStore(storeID uint64) {
+	if store := c.stores[storeID]; store != nil {
+		c.pushStoreEvent(store.meta, false)
+	}
+}
+
+func (c *Cluster) pushStoreEvent(meta *metapb.Store, removed bool) {
+	c.watchers.Lock()
+	defer c.watchers.Unlock()
+	for w := range c.watchers.stores {
+		w.push(StoreEvent{Store: proto.Clone(meta).(*metapb.Store), Removed: removed})
+	}
+}
+
+// notifyRegion sends the change of the region to the watchers. The cluster
+// lock must be held.
+func (c *Cluster) notifyRegion(regionID uint64) {
+	if region := c.regions[regionID]; region != nil {
+		c.pushRegionEvent(region, false)
+	}
+}
+
+func (c *Cluster) pushRegionEvent(region *Region, removed bool) {
+	c.watchers.Lock()
+	defer c.watchers.Unlock()
+	for w := range c.watchers.regions {
+		e := RegionEvent{Region: proto.Clone(region.Meta).(*metapb.Region), Removed: removed}
+		if leader := region.leaderPeer(); leader != nil {
+			e.Leader = proto.Clone(leader).(*metapb.Peer)
+		}
+		w.push(e)
+	}
+}
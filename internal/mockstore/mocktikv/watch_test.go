@@ -0,0 +1,103 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchStoresAndRegions(t *testing.T) {
+	cluster := NewCluster(nil)
+	storeID, peerID, regionID := BootstrapWithSingleStore(cluster)
+	pdClient := NewPDClient(cluster)
+	ctx, cancel := context.WithCancel(context.Background())
+	stores, err := pdClient.WatchStores(ctx)
+	require.Nil(t, err)
+	regions, err := pdClient.WatchRegions(ctx)
+	require.Nil(t, err)
+
+	nextStore := func() StoreEvent {
+		select {
+		case e := <-stores:
+			return e
+		case <-time.After(time.Second):
+			require.FailNow(t, "no store event")
+			return StoreEvent{}
+		}
+	}
+	nextRegion := func() RegionEvent {
+		select {
+		case e := <-regions:
+			return e
+		case <-time.After(time.Second):
+			require.FailNow(t, "no region event")
+			return RegionEvent{}
+		}
+	}
+
+	newStoreID := cluster.AllocID()
+	cluster.AddStore(newStoreID, "store2")
+	e := nextStore()
+	require.Equal(t, newStoreID, e.Store.GetId())
+	require.Equal(t, "store2", e.Store.GetAddress())
+	require.False(t, e.Removed)
+	cluster.MarkTombstone(storeID)
+	e = nextStore()
+	require.Equal(t, storeID, e.Store.GetId())
+	require.Equal(t, metapb.StoreState_Tombstone, e.Store.GetState())
+	cluster.RemoveStore(newStoreID)
+	e = nextStore()
+	require.Equal(t, newStoreID, e.Store.GetId())
+	require.True(t, e.Removed)
+
+	newRegionID, newPeerID := cluster.AllocID(), cluster.AllocID()
+	cluster.Split(regionID, newRegionID, []byte("m"), []uint64{newPeerID}, newPeerID)
+	r := nextRegion()
+	require.Equal(t, regionID, r.Region.GetId())
+	require.Equal(t, NewMvccKey([]byte("m")), MvccKey(r.Region.GetEndKey()))
+	require.Equal(t, peerID, r.Leader.GetId())
+	r = nextRegion()
+	require.Equal(t, newRegionID, r.Region.GetId())
+	require.Equal(t, newPeerID, r.Leader.GetId())
+
+	cluster.GiveUpLeader(regionID)
+	r = nextRegion()
+	require.Equal(t, regionID, r.Region.GetId())
+	require.Nil(t, r.Leader)
+
+	cluster.Merge(regionID, newRegionID)
+	r = nextRegion()
+	require.Equal(t, regionID, r.Region.GetId())
+	require.Empty(t, r.Region.GetEndKey())
+	require.False(t, r.Removed)
+	r = nextRegion()
+	require.Equal(t, newRegionID, r.Region.GetId())
+	require.True(t, r.Removed)
+
+	// The channels are closed when the watches are canceled, and the changes
+	// are not blocked by the watchers not receiving.
+	cluster.StartStore(storeID)
+	cancel()
+	for range stores {
+	}
+	for range regions {
+	}
+	cluster.MarkTombstone(storeID)
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+// maxAppliedWrites is the number of the latest applied writes remembered to
+// deduplicate the retries.
+const maxAppliedWrites = 4096
+
+// appliedWrites remembers the responses of the writes carrying tokens, so that
+// a retried write is answered with the response of the applied one instead of
+// being applied again.
+type appliedWrites struct {
+	resps map[tikvrpc.WriteToken]interface{}
+	// order is a ring of the tokens in resps, oldest first from next.
+	order []tikvrpc.WriteToken
+	next  int
+	// deduplicated is the number of the retried writes skipped.
+	deduplicated uint64
+}
+
+// getAppliedWrite returns the response of the applied write of the token.
+func (c *Cluster) getAppliedWrite(token tikvrpc.WriteToken) (interface{}, bool) {
+	c.writesMu.Lock()
+	defer c.writesMu.Unlock()
+	resp, ok := c.writes.resps[token]
+	if ok {
+		c.writes.deduplicated++
+	}
+	return resp, ok
+}
+
+// putAppliedWrite records the response of the applied write of the token.
+func (c *Cluster) putAppliedWrite(token tikvrpc.WriteToken, resp interface{}) {
+	c.writesMu.Lock()
+	defer c.writesMu.Unlock()
+	w := &c.writes
+	if w.resps == nil {
+		w.resps = make(map[tikvrpc.WriteToken]interface{})
+	}
+	if _, ok := w.resps[token]; ok {
+		return
+	}
+	if len(w.order) < maxAppliedWrites {
+		w.order = append(w.order, token)
+	} else {
+		delete(w.resps, w.order[w.next])
+		w.order[w.next] = token
+		w.next = (w.next + 1) % maxAppliedWrites
+	}
+	w.resps[token] = resp
+}
+
+// DeduplicatedWrites returns the number of the retried writes which are not
+// applied again because their tokens are seen, see tikvrpc.Request.WriteToken.
+func (c *Cluster) DeduplicatedWrites() uint64 {
+	c.writesMu.Lock()
+	defer c.writesMu.Unlock()
+	return c.writes.deduplicated
+}
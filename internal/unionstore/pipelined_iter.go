@@ -0,0 +1,167 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unionstore
+
+import (
+	"bytes"
+	"context"
+)
+
+// BufferScanner returns a page of the mutations in [startKey, endKey) that
+// have been flushed to TiKV by a pipelined transaction, sorted by key. A
+// deleted key has an empty value. Keys only locked are not returned.
+//
+// The page starts from startKey if reverse is false, otherwise it ends at
+// endKey. next is the start key (or the end key if reverse is true) of the rest
+// of the range, or nil if the range is exhausted. A page may be empty even if
+// the range is not exhausted.
+type BufferScanner func(ctx context.Context, startKey, endKey []byte, reverse bool) (keys [][]byte, values [][]byte, next []byte, err error)
+
+// bufferIter iterates over the flushed mutations, it fetches them by the
+// BufferScanner a page at a time.
+type bufferIter struct {
+	ctx        context.Context
+	scanner    BufferScanner
+	lowerBound []byte
+	upperBound []byte
+	reverse    bool
+	exhausted  bool
+
+	keys   [][]byte
+	values [][]byte
+	idx    int
+}
+
+func newBufferIter(ctx context.Context, scanner BufferScanner, lowerBound, upperBound []byte, reverse bool) (*bufferIter, error) {
+	it := &bufferIter{
+		ctx:        ctx,
+		scanner:    scanner,
+		lowerBound: lowerBound,
+		upperBound: upperBound,
+		reverse:    reverse,
+	}
+	if err := it.fetch(); err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+// fetch loads the next non-empty page, the iterator becomes invalid if the
+// range is exhausted.
+func (it *bufferIter) fetch() error {
+	it.keys, it.values = nil, nil
+	for len(it.keys) == 0 && !it.exhausted {
+		keys, values, next, err := it.scanner(it.ctx, it.lowerBound, it.upperBound, it.reverse)
+		if err != nil {
+			return err
+		}
+		it.keys, it.values = keys, values
+		if next == nil {
+			it.exhausted = true
+		} else if it.reverse {
+			it.upperBound = next
+		} else {
+			it.lowerBound = next
+		}
+	}
+	it.idx = 0
+	if it.reverse {
+		it.idx = len(it.keys) - 1
+	}
+	return nil
+}
+
+func (it *bufferIter) Valid() bool   { return it.idx >= 0 && it.idx < len(it.keys) }
+func (it *bufferIter) Key() []byte   { return it.keys[it.idx] }
+func (it *bufferIter) Value() []byte { return it.values[it.idx] }
+func (it *bufferIter) Close()        {}
+
+func (it *bufferIter) Next() error {
+	if it.reverse {
+		it.idx--
+	} else {
+		it.idx++
+	}
+	if it.Valid() {
+		return nil
+	}
+	return it.fetch()
+}
+
+// mergedIter merges the iterators of the layers of a PipelinedMemDB. When a
+// key exists in several layers, the entry of the earliest layer is used, so the
+// layers must be ordered from the newest to the oldest. Unlike UnionIter,
+// deleted entries (with empty values) are kept, so that they can hide the keys
+// of the snapshot in the outer UnionIter.
+type mergedIter struct {
+	iters   []Iterator
+	cur     int
+	reverse bool
+}
+
+func newMergedIter(iters []Iterator, reverse bool) *mergedIter {
+	it := &mergedIter{iters: iters, reverse: reverse}
+	it.updateCur()
+	return it
+}
+
+func (it *mergedIter) updateCur() {
+	it.cur = -1
+	for i, iter := range it.iters {
+		if !iter.Valid() {
+			continue
+		}
+		if it.cur < 0 {
+			it.cur = i
+			continue
+		}
+		cmp := bytes.Compare(iter.Key(), it.iters[it.cur].Key())
+		if it.reverse {
+			cmp = -cmp
+		}
+		if cmp < 0 {
+			it.cur = i
+		}
+	}
+}
+
+func (it *mergedIter) Valid() bool   { return it.cur >= 0 }
+func (it *mergedIter) Key() []byte   { return it.iters[it.cur].Key() }
+func (it *mergedIter) Value() []byte { return it.iters[it.cur].Value() }
+
+func (it *mergedIter) Next() error {
+	key := it.Key()
+	// Skip the same key in the older layers.
+	for i, iter := range it.iters {
+		if i != it.cur && iter.Valid() && bytes.Equal(iter.Key(), key) {
+			if err := iter.Next(); err != nil {
+				it.cur = -1
+				return err
+			}
+		}
+	}
+	if err := it.iters[it.cur].Next(); err != nil {
+		it.cur = -1
+		return err
+	}
+	it.updateCur()
+	return nil
+}
+
+func (it *mergedIter) Close() {
+	for _, iter := range it.iters {
+		iter.Close()
+	}
+}
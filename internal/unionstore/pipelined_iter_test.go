@@ -0,0 +1,126 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unionstore
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelinedMemDBIter(t *testing.T) {
+	// flushed is the mutations in TiKV.
+	flushed := map[string][]byte{}
+	memdb := NewPipelinedMemDB(emptyBufferBatchGetter, func(_ uint64, db *MemDB) error {
+		for it, _ := db.Iter(nil, nil); it.Valid(); it.Next() {
+			flushed[string(it.Key())] = append([]byte{}, it.Value()...)
+		}
+		return nil
+	})
+	// The scanner returns a page of one key to test the paging.
+	scans := 0
+	memdb.SetBufferScanner(func(_ context.Context, start, end []byte, reverse bool) ([][]byte, [][]byte, []byte, error) {
+		scans++
+		var keys, values [][]byte
+		for k := range flushed {
+			if bytes.Compare([]byte(k), start) >= 0 && (len(end) == 0 || bytes.Compare([]byte(k), end) < 0) {
+				keys = append(keys, []byte(k))
+			}
+		}
+		sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+		var next []byte
+		if len(keys) > 1 {
+			if reverse {
+				keys = keys[len(keys)-1:]
+				next = keys[0]
+			} else {
+				keys = keys[:1]
+				next = append(append([]byte{}, keys[0]...), 0)
+			}
+		}
+		for _, k := range keys {
+			values = append(values, flushed[string(k)])
+		}
+		return keys, values, next, nil
+	})
+
+	collect := func(it Iterator, err error) []string {
+		require.Nil(t, err)
+		var res []string
+		for ; it.Valid(); require.Nil(t, it.Next()) {
+			res = append(res, string(it.Key())+"="+string(it.Value()))
+		}
+		it.Close()
+		return res
+	}
+
+	require.Nil(t, memdb.Set([]byte("a"), []byte("1")))
+	require.Nil(t, memdb.Set([]byte("b"), []byte("1")))
+	require.Nil(t, memdb.Delete([]byte("c")))
+	require.Nil(t, memdb.Set([]byte("d"), []byte("1")))
+	// Nothing is flushed, the buffer scanner is not used.
+	require.Equal(t, []string{"a=1", "b=1", "c=", "d=1"}, collect(memdb.Iter(nil, nil)))
+
+	ok, err := memdb.Flush(true)
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Nil(t, memdb.FlushWait())
+
+	require.Nil(t, memdb.Set([]byte("b"), []byte("2")))
+	require.Nil(t, memdb.Set([]byte("c"), []byte("2")))
+	require.Nil(t, memdb.Delete([]byte("d")))
+	require.Nil(t, memdb.Set([]byte("e"), []byte("2")))
+
+	// The flushed mutations are fetched as the iterator moves.
+	scans = 0
+	it, err := memdb.Iter(nil, nil)
+	require.Nil(t, err)
+	require.Equal(t, 1, scans)
+	require.Equal(t, []byte("a"), it.Key())
+	require.Nil(t, it.Next())
+	require.Equal(t, 2, scans)
+	it.Close()
+
+	require.Equal(t, []string{"a=1", "b=2", "c=2", "d=", "e=2"}, collect(memdb.Iter(nil, nil)))
+	require.Equal(t, []string{"b=2", "c=2"}, collect(memdb.Iter([]byte("b"), []byte("d"))))
+	require.Equal(t, []string{"e=2", "d=", "c=2", "b=2", "a=1"}, collect(memdb.IterReverse(nil, nil)))
+	require.Equal(t, []string{"c=2", "b=2"}, collect(memdb.IterReverse([]byte("d"), []byte("b"))))
+}
+
+func TestPipelinedMemDBIterWithContext(t *testing.T) {
+	memdb := NewPipelinedMemDB(emptyBufferBatchGetter, func(uint64, *MemDB) error { return nil })
+	memdb.SetBufferScanner(func(ctx context.Context, _, _ []byte, _ bool) ([][]byte, [][]byte, []byte, error) {
+		return nil, nil, nil, ctx.Err()
+	})
+	require.Nil(t, memdb.Set([]byte("a"), []byte("1")))
+	ok, err := memdb.Flush(true)
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Nil(t, memdb.FlushWait())
+
+	// The context of the caller is passed to the buffer scanner.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = memdb.IterWithContext(ctx, nil, nil)
+	require.ErrorIs(t, err, context.Canceled)
+	_, err = memdb.IterReverseWithContext(ctx, nil, nil)
+	require.ErrorIs(t, err, context.Canceled)
+	it, err := memdb.Iter(nil, nil)
+	require.Nil(t, err)
+	it.Close()
+}
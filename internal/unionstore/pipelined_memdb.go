@@ -43,6 +43,7 @@ type PipelinedMemDB struct {
 	errCh             chan error
 	flushFunc         FlushFunc
 	bufferBatchGetter BufferBatchGetter
+	bufferScanner     BufferScanner
 	memDB             *MemDB
 	flushingMemDB     *MemDB // the flushingMemDB is not wrapped by a mutex, because there is no data race in it.
 	len, size         int    // len and size records the total flushed and onflushing memdb.
@@ -402,14 +403,78 @@ func (p *PipelinedMemDB) handleAlreadyExistErr(err error) error {
 	return err
 }
 
-// Iter implements the Retriever interface.
-func (p *PipelinedMemDB) Iter([]byte, []byte) (Iterator, error) {
-	return nil, errors.New("pipelined memdb does not support Iter")
+// SetBufferScanner sets the function to scan the flushed mutations, which is
+// required by Iter and IterReverse after the first flush.
+func (p *PipelinedMemDB) SetBufferScanner(scanner BufferScanner) {
+	p.bufferScanner = scanner
 }
 
-// IterReverse implements the Retriever interface.
-func (p *PipelinedMemDB) IterReverse([]byte, []byte) (Iterator, error) {
-	return nil, errors.New("pipelined memdb does not support IterReverse")
+// Iter implements the Retriever interface. The returned iterator merges the
+// mutable memdb, the flushing memdb and the mutations flushed to TiKV, and the
+// deleted keys are kept with empty values. The flushed mutations are fetched
+// a page at a time as the iterator moves, without a deadline, use
+// IterWithContext to bound it.
+func (p *PipelinedMemDB) Iter(k []byte, upperBound []byte) (Iterator, error) {
+	return p.iter(context.Background(), k, upperBound, false)
+}
+
+// IterReverse implements the Retriever interface. See Iter for details.
+func (p *PipelinedMemDB) IterReverse(k []byte, lowerBound []byte) (Iterator, error) {
+	return p.iter(context.Background(), lowerBound, k, true)
+}
+
+// IterWithContext is like Iter, but scans the flushed mutations with ctx, which
+// is kept by the iterator for the pages fetched later.
+func (p *PipelinedMemDB) IterWithContext(ctx context.Context, k []byte, upperBound []byte) (Iterator, error) {
+	return p.iter(ctx, k, upperBound, false)
+}
+
+// IterReverseWithContext is like IterReverse, but scans the flushed mutations
+// with ctx.
+func (p *PipelinedMemDB) IterReverseWithContext(ctx context.Context, k []byte, lowerBound []byte) (Iterator, error) {
+	return p.iter(ctx, lowerBound, k, true)
+}
+
+func (p *PipelinedMemDB) iter(ctx context.Context, lower, upper []byte, reverse bool) (Iterator, error) {
+	iters := make([]Iterator, 0, 3)
+	closeIters := func() {
+		for _, it := range iters {
+			it.Close()
+		}
+	}
+	layers := []*MemDB{p.memDB}
+	if p.flushingMemDB != nil {
+		layers = append(layers, p.flushingMemDB)
+	}
+	for _, db := range layers {
+		var (
+			it  Iterator
+			err error
+		)
+		if reverse {
+			it, err = db.IterReverse(upper, lower)
+		} else {
+			it, err = db.Iter(lower, upper)
+		}
+		if err != nil {
+			closeIters()
+			return nil, err
+		}
+		iters = append(iters, it)
+	}
+	if p.generation > 0 {
+		if p.bufferScanner == nil {
+			closeIters()
+			return nil, errors.New("pipelined memdb does not support iterating flushed mutations without buffer scanner")
+		}
+		it, err := newBufferIter(ctx, p.bufferScanner, lower, upper, reverse)
+		if err != nil {
+			closeIters()
+			return nil, err
+		}
+		iters = append(iters, it)
+	}
+	return newMergedIter(iters, reverse), nil
 }
 
 // SetEntrySizeLimit sets the size limit for each entry and total buffer.
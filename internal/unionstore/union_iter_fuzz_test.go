@@ -0,0 +1,90 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unionstore
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/internal/fuzzutil"
+)
+
+func FuzzUnionIter(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0, 2, 0, 1, 'a', 0, 1, 'b', 0, 2, 0, 1, 'a', 0, 0, 0, 1, 'c', 0, 1})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := fuzzutil.NewReader(data)
+		snapshot, dirty := NewMemDB(), NewMemDB()
+		// expected is the merged view, a nil value means the key doesn't exist.
+		expected := make(map[string][]byte)
+		for _, k := range r.Keys(32, 4) {
+			if len(k) == 0 {
+				continue
+			}
+			v := append([]byte{'s'}, r.Bytes(4)...)
+			require.NoError(t, snapshot.Set(k, v))
+			expected[string(k)] = v
+		}
+		for _, k := range r.Keys(32, 4) {
+			if len(k) == 0 {
+				continue
+			}
+			if r.Bool() {
+				require.NoError(t, dirty.Delete(k))
+				expected[string(k)] = nil
+			} else {
+				v := append([]byte{'d'}, r.Bytes(4)...)
+				require.NoError(t, dirty.Set(k, v))
+				expected[string(k)] = v
+			}
+		}
+		var keys []string
+		for k, v := range expected {
+			if v != nil {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+
+		check := func(reverse bool, keys []string) {
+			var dirtyIt, snapshotIt Iterator
+			var err error
+			if reverse {
+				dirtyIt, err = dirty.IterReverse(nil, nil)
+				require.NoError(t, err)
+				snapshotIt, err = snapshot.IterReverse(nil, nil)
+			} else {
+				dirtyIt, err = dirty.Iter(nil, nil)
+				require.NoError(t, err)
+				snapshotIt, err = snapshot.Iter(nil, nil)
+			}
+			require.NoError(t, err)
+			it, err := NewUnionIter(dirtyIt, snapshotIt, reverse)
+			require.NoError(t, err)
+			defer it.Close()
+			for _, k := range keys {
+				require.True(t, it.Valid())
+				require.Equal(t, k, string(it.Key()))
+				require.Equal(t, expected[k], it.Value())
+				require.NoError(t, it.Next())
+			}
+			require.False(t, it.Valid())
+		}
+		check(false, keys)
+		slices.Reverse(keys)
+		check(true, keys)
+	})
+}
@@ -137,6 +137,48 @@ func (us *KVUnionStore) IterReverse(k, lowerBound []byte) (Iterator, error) {
 	return NewUnionIter(bufferIt, retrieverIt, true)
 }
 
+// contextIterable is implemented by the MemBuffers whose iterators may read
+// from TiKV, e.g. PipelinedMemDB.
+type contextIterable interface {
+	IterWithContext(ctx context.Context, k, upperBound []byte) (Iterator, error)
+	IterReverseWithContext(ctx context.Context, k, lowerBound []byte) (Iterator, error)
+}
+
+// IterWithContext is like Iter, but the MemBuffer reads from TiKV with ctx if
+// it needs to, see PipelinedMemDB.IterWithContext.
+func (us *KVUnionStore) IterWithContext(ctx context.Context, k, upperBound []byte) (Iterator, error) {
+	b, ok := us.memBuffer.(contextIterable)
+	if !ok {
+		return us.Iter(k, upperBound)
+	}
+	bufferIt, err := b.IterWithContext(ctx, k, upperBound)
+	if err != nil {
+		return nil, err
+	}
+	retrieverIt, err := us.snapshot.Iter(k, upperBound)
+	if err != nil {
+		return nil, err
+	}
+	return NewUnionIter(bufferIt, retrieverIt, false)
+}
+
+// IterReverseWithContext is like IterReverse, see IterWithContext.
+func (us *KVUnionStore) IterReverseWithContext(ctx context.Context, k, lowerBound []byte) (Iterator, error) {
+	b, ok := us.memBuffer.(contextIterable)
+	if !ok {
+		return us.IterReverse(k, lowerBound)
+	}
+	bufferIt, err := b.IterReverseWithContext(ctx, k, lowerBound)
+	if err != nil {
+		return nil, err
+	}
+	retrieverIt, err := us.snapshot.IterReverse(k, lowerBound)
+	if err != nil {
+		return nil, err
+	}
+	return NewUnionIter(bufferIt, retrieverIt, true)
+}
+
 // HasPresumeKeyNotExists gets the key exist error info for the lazy check.
 func (us *KVUnionStore) HasPresumeKeyNotExists(k []byte) bool {
 	flags, err := us.memBuffer.GetFlags(k)
@@ -0,0 +1,35 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import "time"
+
+// OperationDeadlines are the default deadlines of the operations, applied
+// when the context of an operation has no deadline of its own, so a deadline
+// set by the caller overrides the default one. Zero means no default deadline.
+//
+// The remaining time before the deadline is sent to TiKV as the max execution
+// duration of the requests, so TiKV stops the work the client has given up on.
+type OperationDeadlines struct {
+	// Get is the deadline of the point gets.
+	Get time.Duration
+	// BatchGet is the deadline of the batch gets.
+	BatchGet time.Duration
+	// Scan is the deadline of fetching each batch of the scanners, since the
+	// scanners have no contexts and may be iterated for long.
+	Scan time.Duration
+	// Commit is the deadline of committing the transactions.
+	Commit time.Duration
+}
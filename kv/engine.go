@@ -0,0 +1,46 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import "fmt"
+
+// ReadEngine is the storage engine whose replicas the reads are sent to.
+type ReadEngine byte
+
+const (
+	// EngineTiKV reads from the TiKV replicas only, picked by the replica read
+	// type. It's the default.
+	EngineTiKV ReadEngine = iota
+	// EngineTiFlash reads from the TiFlash replicas only. The reads of the
+	// regions without TiFlash replicas fail with tikverr.ErrNoEngineReplica.
+	EngineTiFlash
+	// EngineAny reads from the TiFlash replicas of the regions having them,
+	// and from the TiKV replicas of the others.
+	EngineAny
+)
+
+// String implements fmt.Stringer interface.
+func (e ReadEngine) String() string {
+	switch e {
+	case EngineTiKV:
+		return "tikv"
+	case EngineTiFlash:
+		return "tiflash"
+	case EngineAny:
+		return "any"
+	default:
+		return fmt.Sprintf("unknown-%v", byte(e))
+	}
+}
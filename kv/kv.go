@@ -39,14 +39,24 @@ type ReturnedValue struct {
 }
 
 // Used for pessimistic lock wait time
-// these two constants are special for lock protocol with tikv
+// these constants are special for lock protocol with tikv
 // math.MaxInt64 means always wait, -1 means nowait, 0 means the default wait duration in TiKV,
 // others meaning lock wait in milliseconds
 const (
-	LockAlwaysWait = int64(math.MaxInt64)
-	LockNoWait     = int64(-1)
+	LockAlwaysWait  = int64(math.MaxInt64)
+	LockNoWait      = int64(-1)
+	LockWaitDefault = int64(0)
 )
 
+// LockWaitTimeout returns the lock wait time waiting for the locks of other transactions for at most d, it doesn't
+// wait if d is less than a millisecond.
+func LockWaitTimeout(d time.Duration) int64 {
+	if ms := d.Milliseconds(); ms > 0 {
+		return ms
+	}
+	return LockNoWait
+}
+
 type lockWaitTimeInMs struct {
 	value int64
 }
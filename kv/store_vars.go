@@ -50,6 +50,10 @@ const DefTxnCommitBatchSize uint64 = 16 * 1024
 // TiKV recommends each RPC packet should be less than ~1MB.
 var TxnCommitBatchSize atomic.Uint64
 
+// TxnCommitInFlightBytesLimit limits the total size of commit related requests sent concurrently by all the
+// transactions with more than one batch. Non-positive value means no limit.
+var TxnCommitInFlightBytesLimit atomic.Int64
+
 func init() {
 	TxnCommitBatchSize.Store(DefTxnCommitBatchSize)
 }
@@ -0,0 +1,71 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import "fmt"
+
+// WorkloadClass is the class of the workload the reads belong to, which
+// decides the replicas to read from, see WorkloadRouting.
+type WorkloadClass byte
+
+const (
+	// WorkloadOLTP stands for the latency sensitive online reads.
+	WorkloadOLTP WorkloadClass = iota
+	// WorkloadOLAP stands for the analytical reads.
+	WorkloadOLAP
+	// WorkloadBackup stands for the reads exporting the data, e.g. backups
+	// and dumps.
+	WorkloadBackup
+)
+
+// String implements fmt.Stringer interface.
+func (c WorkloadClass) String() string {
+	switch c {
+	case WorkloadOLTP:
+		return "oltp"
+	case WorkloadOLAP:
+		return "olap"
+	case WorkloadBackup:
+		return "backup"
+	default:
+		return fmt.Sprintf("unknown-%v", byte(c))
+	}
+}
+
+// WorkloadRouting maps the workload classes to the replicas to read from, so
+// that the workloads of different classes are isolated from each other.
+type WorkloadRouting map[WorkloadClass]ReplicaReadType
+
+// DefaultWorkloadRouting returns the default routing, which reads from the
+// leaders for OLTP, the learners for OLAP and the followers for backups.
+func DefaultWorkloadRouting() WorkloadRouting {
+	return WorkloadRouting{
+		WorkloadOLTP:   ReplicaReadLeader,
+		WorkloadOLAP:   ReplicaReadLearner,
+		WorkloadBackup: ReplicaReadFollower,
+	}
+}
+
+// ReplicaRead returns the replica read type of the class. The classes missing
+// in r fall back to the default routing.
+func (r WorkloadRouting) ReplicaRead(class WorkloadClass) ReplicaReadType {
+	if readType, ok := r[class]; ok {
+		return readType
+	}
+	if readType, ok := DefaultWorkloadRouting()[class]; ok {
+		return readType
+	}
+	return ReplicaReadLeader
+}
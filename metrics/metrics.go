@@ -72,6 +72,8 @@ var (
 	TiKVBatchWaitOverLoad                          prometheus.Counter
 	TiKVBatchPendingRequests                       *prometheus.HistogramVec
 	TiKVBatchRequests                              *prometheus.HistogramVec
+	TiKVBatchSendWaitDuration                      *prometheus.HistogramVec
+	TiKVBatchStreamRestartCounter                  *prometheus.CounterVec
 	TiKVBatchRequestDuration                       *prometheus.SummaryVec
 	TiKVBatchClientUnavailable                     prometheus.Histogram
 	TiKVBatchClientWaitEstablish                   prometheus.Histogram
@@ -87,6 +89,7 @@ var (
 	TiKVTwoPCTxnCounter                            *prometheus.CounterVec
 	TiKVAsyncCommitTxnCounter                      *prometheus.CounterVec
 	TiKVOnePCTxnCounter                            *prometheus.CounterVec
+	TiKVOnePCIneligibleCounter                     *prometheus.CounterVec
 	TiKVStoreLimitErrorCounter                     *prometheus.CounterVec
 	TiKVGRPCConnTransientFailureCounter            *prometheus.CounterVec
 	TiKVPanicCounter                               *prometheus.CounterVec
@@ -118,6 +121,7 @@ var (
 	TiKVLowResolutionTSOUpdateIntervalSecondsGauge prometheus.Gauge
 	TiKVStaleRegionFromPDCounter                   prometheus.Counter
 	TiKVPipelinedFlushThrottleSecondsHistogram     prometheus.Histogram
+	TiKVRPCInterceptorHistogram                    *prometheus.HistogramVec
 )
 
 // Label constants.
@@ -143,6 +147,7 @@ const (
 	LblGeneral         = "general"
 	LblDirection       = "direction"
 	LblReason          = "reason"
+	LblName            = "name"
 )
 
 func initMetrics(namespace, subsystem string, constLabels prometheus.Labels) {
@@ -440,6 +445,25 @@ func initMetrics(namespace, subsystem string, constLabels prometheus.Labels) {
 			ConstLabels: constLabels,
 		}, []string{"store"})
 
+	TiKVBatchSendWaitDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "batch_send_wait_seconds",
+			Buckets:     prometheus.ExponentialBuckets(0.0001, 2, 20), // 100us ~ 52s
+			Help:        "time the requests wait in the batch queue before being sent",
+			ConstLabels: constLabels,
+		}, []string{"store"})
+
+	TiKVBatchStreamRestartCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "batch_stream_restart_total",
+			Help:        "counter of the batch commands streams re-created",
+			ConstLabels: constLabels,
+		}, []string{"store"})
+
 	TiKVBatchRequestDuration = prometheus.NewSummaryVec(
 		prometheus.SummaryOpts{
 			Namespace:   namespace,
@@ -583,6 +607,15 @@ func initMetrics(namespace, subsystem string, constLabels prometheus.Labels) {
 			ConstLabels: constLabels,
 		}, []string{LblType})
 
+	TiKVOnePCIneligibleCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "one_pc_ineligible_total",
+			Help:        "Counter of the transactions with 1PC enabled but not committed by 1PC, by the reasons.",
+			ConstLabels: constLabels,
+		}, []string{LblReason})
+
 	TiKVStoreLimitErrorCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace:   namespace,
@@ -859,6 +892,16 @@ func initMetrics(namespace, subsystem string, constLabels prometheus.Labels) {
 			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 28), // 0.5ms ~ 18h
 		})
 
+	TiKVRPCInterceptorHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "rpc_interceptor_seconds",
+			Help:        "Time spent in the client RPC interceptors, excluding the time of the interceptors after them and the RPC.",
+			Buckets:     prometheus.ExponentialBuckets(0.00001, 2, 20), // 10us ~ 5s
+			ConstLabels: constLabels,
+		}, []string{LblName, LblResult})
+
 	initShortcuts()
 }
 
@@ -879,83 +922,111 @@ func InitMetricsWithConstLabels(namespace, subsystem string, constLabels prometh
 // RegisterMetrics registers all metrics variables.
 // Note: to change default namespace and subsystem name, call `InitMetrics` before registering.
 func RegisterMetrics() {
-	prometheus.MustRegister(TiKVTxnCmdHistogram)
-	prometheus.MustRegister(TiKVBackoffHistogram)
-	prometheus.MustRegister(TiKVSendReqHistogram)
-	prometheus.MustRegister(TiKVSendReqSummary)
-	prometheus.MustRegister(TiKVRPCNetLatencyHistogram)
-	prometheus.MustRegister(TiKVCoprocessorHistogram)
-	prometheus.MustRegister(TiKVLockResolverCounter)
-	prometheus.MustRegister(TiKVRegionErrorCounter)
-	prometheus.MustRegister(TiKVRPCErrorCounter)
-	prometheus.MustRegister(TiKVTxnWriteKVCountHistogram)
-	prometheus.MustRegister(TiKVTxnWriteSizeHistogram)
-	prometheus.MustRegister(TiKVRawkvCmdHistogram)
-	prometheus.MustRegister(TiKVRawkvSizeHistogram)
-	prometheus.MustRegister(TiKVTxnRegionsNumHistogram)
-	prometheus.MustRegister(TiKVLoadSafepointCounter)
-	prometheus.MustRegister(TiKVSecondaryLockCleanupFailureCounter)
-	prometheus.MustRegister(TiKVRegionCacheCounter)
-	prometheus.MustRegister(TiKVLoadRegionCounter)
-	prometheus.MustRegister(TiKVLoadRegionCacheHistogram)
-	prometheus.MustRegister(TiKVLocalLatchWaitTimeHistogram)
-	prometheus.MustRegister(TiKVStatusDuration)
-	prometheus.MustRegister(TiKVStatusCounter)
-	prometheus.MustRegister(TiKVBatchSendTailLatency)
-	prometheus.MustRegister(TiKVBatchSendLoopDuration)
-	prometheus.MustRegister(TiKVBatchRecvLoopDuration)
-	prometheus.MustRegister(TiKVBatchHeadArrivalInterval)
-	prometheus.MustRegister(TiKVBatchBestSize)
-	prometheus.MustRegister(TiKVBatchMoreRequests)
-	prometheus.MustRegister(TiKVBatchWaitOverLoad)
-	prometheus.MustRegister(TiKVBatchPendingRequests)
-	prometheus.MustRegister(TiKVBatchRequests)
-	prometheus.MustRegister(TiKVBatchRequestDuration)
-	prometheus.MustRegister(TiKVBatchClientUnavailable)
-	prometheus.MustRegister(TiKVBatchClientWaitEstablish)
-	prometheus.MustRegister(TiKVBatchClientRecycle)
-	prometheus.MustRegister(TiKVRangeTaskStats)
-	prometheus.MustRegister(TiKVRangeTaskPushDuration)
-	prometheus.MustRegister(TiKVTokenWaitDuration)
-	prometheus.MustRegister(TiKVTxnHeartBeatHistogram)
-	prometheus.MustRegister(TiKVTTLManagerHistogram)
-	prometheus.MustRegister(TiKVPessimisticLockKeysDuration)
-	prometheus.MustRegister(TiKVTTLLifeTimeReachCounter)
-	prometheus.MustRegister(TiKVNoAvailableConnectionCounter)
-	prometheus.MustRegister(TiKVTwoPCTxnCounter)
-	prometheus.MustRegister(TiKVAsyncCommitTxnCounter)
-	prometheus.MustRegister(TiKVOnePCTxnCounter)
-	prometheus.MustRegister(TiKVStoreLimitErrorCounter)
-	prometheus.MustRegister(TiKVGRPCConnTransientFailureCounter)
-	prometheus.MustRegister(TiKVPanicCounter)
-	prometheus.MustRegister(TiKVForwardRequestCounter)
-	prometheus.MustRegister(TiKVTSFutureWaitDuration)
-	prometheus.MustRegister(TiKVSafeTSUpdateCounter)
-	prometheus.MustRegister(TiKVMinSafeTSGapSeconds)
-	prometheus.MustRegister(TiKVReplicaSelectorFailureCounter)
-	prometheus.MustRegister(TiKVRequestRetryTimesHistogram)
-	prometheus.MustRegister(TiKVTxnCommitBackoffSeconds)
-	prometheus.MustRegister(TiKVTxnCommitBackoffCount)
-	prometheus.MustRegister(TiKVSmallReadDuration)
-	prometheus.MustRegister(TiKVReadThroughput)
-	prometheus.MustRegister(TiKVUnsafeDestroyRangeFailuresCounterVec)
-	prometheus.MustRegister(TiKVPrewriteAssertionUsageCounter)
-	prometheus.MustRegister(TiKVGrpcConnectionState)
-	prometheus.MustRegister(TiKVAggressiveLockedKeysCounter)
-	prometheus.MustRegister(TiKVStoreSlowScoreGauge)
-	prometheus.MustRegister(TiKVFeedbackSlowScoreGauge)
-	prometheus.MustRegister(TiKVHealthFeedbackOpsCounter)
-	prometheus.MustRegister(TiKVPreferLeaderFlowsGauge)
-	prometheus.MustRegister(TiKVStaleReadCounter)
-	prometheus.MustRegister(TiKVStaleReadReqCounter)
-	prometheus.MustRegister(TiKVStaleReadBytes)
-	prometheus.MustRegister(TiKVPipelinedFlushLenHistogram)
-	prometheus.MustRegister(TiKVPipelinedFlushSizeHistogram)
-	prometheus.MustRegister(TiKVPipelinedFlushDuration)
-	prometheus.MustRegister(TiKVValidateReadTSFromPDCount)
-	prometheus.MustRegister(TiKVLowResolutionTSOUpdateIntervalSecondsGauge)
-	prometheus.MustRegister(TiKVStaleRegionFromPDCounter)
-	prometheus.MustRegister(TiKVPipelinedFlushThrottleSecondsHistogram)
+	for _, c := range collectors() {
+		prometheus.MustRegister(c)
+	}
+}
+
+// RegisterMetricsTo registers all metrics variables to the given registerer instead of the default one. Metrics that
+// are already registered are skipped, so it's safe to be called more than once with the same registerer.
+// Note that the metrics variables are process-wide, they observe the requests of all the clients in the process. Use
+// StoreMetrics for the metrics of one client.
+func RegisterMetricsTo(r prometheus.Registerer) error {
+	for _, c := range collectors() {
+		if err := r.Register(c); err != nil {
+			if _, ok := err.(prometheus.AlreadyRegisteredError); ok {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		TiKVTxnCmdHistogram,
+		TiKVBackoffHistogram,
+		TiKVSendReqHistogram,
+		TiKVSendReqSummary,
+		TiKVRPCNetLatencyHistogram,
+		TiKVCoprocessorHistogram,
+		TiKVLockResolverCounter,
+		TiKVRegionErrorCounter,
+		TiKVRPCErrorCounter,
+		TiKVTxnWriteKVCountHistogram,
+		TiKVTxnWriteSizeHistogram,
+		TiKVRawkvCmdHistogram,
+		TiKVRawkvSizeHistogram,
+		TiKVTxnRegionsNumHistogram,
+		TiKVLoadSafepointCounter,
+		TiKVSecondaryLockCleanupFailureCounter,
+		TiKVRegionCacheCounter,
+		TiKVLoadRegionCounter,
+		TiKVLoadRegionCacheHistogram,
+		TiKVLocalLatchWaitTimeHistogram,
+		TiKVStatusDuration,
+		TiKVStatusCounter,
+		TiKVBatchSendTailLatency,
+		TiKVBatchSendLoopDuration,
+		TiKVBatchRecvLoopDuration,
+		TiKVBatchHeadArrivalInterval,
+		TiKVBatchBestSize,
+		TiKVBatchMoreRequests,
+		TiKVBatchWaitOverLoad,
+		TiKVBatchPendingRequests,
+		TiKVBatchRequests,
+		TiKVBatchSendWaitDuration,
+		TiKVBatchStreamRestartCounter,
+		TiKVBatchRequestDuration,
+		TiKVBatchClientUnavailable,
+		TiKVBatchClientWaitEstablish,
+		TiKVBatchClientRecycle,
+		TiKVRangeTaskStats,
+		TiKVRangeTaskPushDuration,
+		TiKVTokenWaitDuration,
+		TiKVTxnHeartBeatHistogram,
+		TiKVTTLManagerHistogram,
+		TiKVPessimisticLockKeysDuration,
+		TiKVTTLLifeTimeReachCounter,
+		TiKVNoAvailableConnectionCounter,
+		TiKVTwoPCTxnCounter,
+		TiKVAsyncCommitTxnCounter,
+		TiKVOnePCTxnCounter,
+		TiKVOnePCIneligibleCounter,
+		TiKVStoreLimitErrorCounter,
+		TiKVGRPCConnTransientFailureCounter,
+		TiKVPanicCounter,
+		TiKVForwardRequestCounter,
+		TiKVTSFutureWaitDuration,
+		TiKVSafeTSUpdateCounter,
+		TiKVMinSafeTSGapSeconds,
+		TiKVReplicaSelectorFailureCounter,
+		TiKVRequestRetryTimesHistogram,
+		TiKVTxnCommitBackoffSeconds,
+		TiKVTxnCommitBackoffCount,
+		TiKVSmallReadDuration,
+		TiKVReadThroughput,
+		TiKVUnsafeDestroyRangeFailuresCounterVec,
+		TiKVPrewriteAssertionUsageCounter,
+		TiKVGrpcConnectionState,
+		TiKVAggressiveLockedKeysCounter,
+		TiKVStoreSlowScoreGauge,
+		TiKVFeedbackSlowScoreGauge,
+		TiKVHealthFeedbackOpsCounter,
+		TiKVPreferLeaderFlowsGauge,
+		TiKVStaleReadCounter,
+		TiKVStaleReadReqCounter,
+		TiKVStaleReadBytes,
+		TiKVPipelinedFlushLenHistogram,
+		TiKVPipelinedFlushSizeHistogram,
+		TiKVPipelinedFlushDuration,
+		TiKVValidateReadTSFromPDCount,
+		TiKVLowResolutionTSOUpdateIntervalSecondsGauge,
+		TiKVStaleRegionFromPDCounter,
+		TiKVPipelinedFlushThrottleSecondsHistogram,
+		TiKVRPCInterceptorHistogram,
+	}
 }
 
 // readCounter reads the value of a prometheus.Counter.
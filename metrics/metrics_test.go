@@ -0,0 +1,58 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterMetricsTo(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	r := prometheus.WrapRegistererWithPrefix("prod_", prometheus.WrapRegistererWith(prometheus.Labels{"cluster": "prod-2"}, reg))
+	require.NoError(t, RegisterMetricsTo(r))
+	// Registering again is a no-op.
+	require.NoError(t, RegisterMetricsTo(r))
+
+	TiKVPanicCounter.WithLabelValues(LabelBatchRecvLoop).Inc()
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	found := false
+	for _, f := range families {
+		require.True(t, strings.HasPrefix(f.GetName(), "prod_"))
+		if f.GetName() != "prod_tikv_client_go_panic_total" {
+			continue
+		}
+		found = true
+		for _, m := range f.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			require.Equal(t, "prod-2", labels["cluster"])
+		}
+	}
+	require.True(t, found)
+
+	// Other registries are not affected.
+	other := prometheus.NewRegistry()
+	require.NoError(t, RegisterMetricsTo(other))
+	families, err = other.Gather()
+	require.NoError(t, err)
+	require.NotEmpty(t, families)
+}
@@ -124,6 +124,7 @@ var (
 	RegionCacheCounterWithGetStoreOK                  prometheus.Counter
 	RegionCacheCounterWithGetStoreError               prometheus.Counter
 	RegionCacheCounterWithInvalidateStoreRegionsOK    prometheus.Counter
+	RegionCacheCounterWithUpdateFromEpochNotMatchOK   prometheus.Counter
 
 	LoadRegionCacheHistogramWhenCacheMiss        prometheus.Observer
 	LoadRegionCacheHistogramWithRegions          prometheus.Observer
@@ -163,8 +164,9 @@ var (
 	AggressiveLockedKeysLockedWithConflict prometheus.Counter
 	AggressiveLockedKeysNonForceLock       prometheus.Counter
 
-	StaleReadHitCounter  prometheus.Counter
-	StaleReadMissCounter prometheus.Counter
+	StaleReadHitCounter            prometheus.Counter
+	StaleReadMissCounter           prometheus.Counter
+	StaleReadLeaderFallbackCounter prometheus.Counter
 
 	StaleReadReqLocalCounter     prometheus.Counter
 	StaleReadReqCrossZoneCounter prometheus.Counter
@@ -265,6 +267,7 @@ func initShortcuts() {
 	RegionCacheCounterWithGetStoreOK = TiKVRegionCacheCounter.WithLabelValues("get_store", "ok")
 	RegionCacheCounterWithGetStoreError = TiKVRegionCacheCounter.WithLabelValues("get_store", "err")
 	RegionCacheCounterWithInvalidateStoreRegionsOK = TiKVRegionCacheCounter.WithLabelValues("invalidate_store_regions", "ok")
+	RegionCacheCounterWithUpdateFromEpochNotMatchOK = TiKVRegionCacheCounter.WithLabelValues("update_from_epoch_not_match", "ok")
 
 	LoadRegionCacheHistogramWhenCacheMiss = TiKVLoadRegionCacheHistogram.WithLabelValues("get_region_when_miss")
 	LoadRegionCacheHistogramWithRegionByID = TiKVLoadRegionCacheHistogram.WithLabelValues("get_region_by_id")
@@ -315,6 +318,7 @@ func initShortcuts() {
 
 	StaleReadHitCounter = TiKVStaleReadCounter.WithLabelValues("hit")
 	StaleReadMissCounter = TiKVStaleReadCounter.WithLabelValues("miss")
+	StaleReadLeaderFallbackCounter = TiKVStaleReadCounter.WithLabelValues("leader_fallback")
 
 	StaleReadReqLocalCounter = TiKVStaleReadReqCounter.WithLabelValues("local")
 	StaleReadReqCrossZoneCounter = TiKVStaleReadReqCounter.WithLabelValues("cross-zone")
@@ -0,0 +1,85 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// StoreMetrics are the metrics of the requests sent by one store. Unlike the
+// process-wide metrics variables, they are created for the store and only
+// observe its own requests, so the stores in one process can be monitored
+// separately.
+type StoreMetrics struct {
+	SendReqHistogram   *prometheus.HistogramVec
+	RPCErrorCounter    *prometheus.CounterVec
+	RegionErrorCounter *prometheus.CounterVec
+}
+
+// NewStoreMetrics creates the metrics of a store with the given namespace,
+// subsystem name and const labels.
+func NewStoreMetrics(namespace, subsystem string, constLabels prometheus.Labels) *StoreMetrics {
+	return &StoreMetrics{
+		SendReqHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace:   namespace,
+				Subsystem:   subsystem,
+				Name:        "store_request_seconds",
+				Help:        "Bucketed histogram of sending request duration of the store.",
+				Buckets:     prometheus.ExponentialBuckets(0.0005, 2, 29), // 0.5ms ~ 1.5days
+				ConstLabels: constLabels,
+			}, []string{LblType, LblStore}),
+		RPCErrorCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   namespace,
+				Subsystem:   subsystem,
+				Name:        "store_rpc_err_total",
+				Help:        "Counter of rpc errors of the store by the request type.",
+				ConstLabels: constLabels,
+			}, []string{LblType, LblStore}),
+		RegionErrorCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   namespace,
+				Subsystem:   subsystem,
+				Name:        "store_region_err_total",
+				Help:        "Counter of region errors of the store.",
+				ConstLabels: constLabels,
+			}, []string{LblType, LblStore}),
+	}
+}
+
+// Register registers the metrics to r. If the same metrics are registered to r
+// by another store, the registered ones are shared with it.
+func (m *StoreMetrics) Register(r prometheus.Registerer) error {
+	var err error
+	if m.SendReqHistogram, err = register(r, m.SendReqHistogram); err != nil {
+		return err
+	}
+	if m.RPCErrorCounter, err = register(r, m.RPCErrorCounter); err != nil {
+		return err
+	}
+	m.RegionErrorCounter, err = register(r, m.RegionErrorCounter)
+	return err
+}
+
+func register[T prometheus.Collector](r prometheus.Registerer, c T) (T, error) {
+	if err := r.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing, nil
+			}
+		}
+		return c, err
+	}
+	return c, nil
+}
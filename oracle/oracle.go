@@ -45,6 +45,20 @@ type Option struct {
 	TxnScope string
 }
 
+type lowLatencyKey struct{}
+
+// WithLowLatency marks the context so that timestamps requested with it are
+// sent to PD immediately instead of waiting to be batched with others.
+func WithLowLatency(ctx context.Context) context.Context {
+	return context.WithValue(ctx, lowLatencyKey{}, true)
+}
+
+// IsLowLatency returns whether the context is marked by WithLowLatency.
+func IsLowLatency(ctx context.Context) bool {
+	v, _ := ctx.Value(lowLatencyKey{}).(bool)
+	return v
+}
+
 // Oracle is the interface that provides strictly ascending timestamps.
 type Oracle interface {
 	GetTimestamp(ctx context.Context, opt *Option) (uint64, error)
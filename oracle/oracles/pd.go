@@ -119,6 +119,8 @@ type pdOracle struct {
 	// txn_scope (string) -> lastTSPointer (*atomic.Pointer[lastTSO])
 	lastTSMap sync.Map
 	quit      chan struct{}
+	// tsoBatcher is nil if TSO batching is disabled.
+	tsoBatcher atomic.Pointer[tsoBatcher]
 	// The configured interval to update the low resolution ts. Set by SetLowResolutionTimestampUpdateInterval.
 	// For TiDB, this is directly controlled by the system variable `tidb_low_resolution_tso_update_interval`.
 	lastTSUpdateInterval atomic.Int64
@@ -166,6 +168,11 @@ type PDOracleOptions struct {
 	UpdateInterval time.Duration
 	// Disable the background periodic update of the last ts. This is for test purposes only.
 	NoUpdateTS bool
+	// The max duration a GetTimestamp call waits to be batched with others. Batching is disabled if it's 0.
+	// Calls with a context marked by oracle.WithLowLatency are never batched.
+	TSOBatchMaxWait time.Duration
+	// The max number of GetTimestamp calls in a batch. A default value is used if it's 0.
+	TSOBatchMaxSize int
 }
 
 // NewPdOracle create an Oracle that uses a pd client source.
@@ -187,6 +194,7 @@ func NewPdOracle(pdClient pd.Client, options *PDOracleOptions) (oracle.Oracle, e
 	o.lastTSUpdateInterval.Store(int64(options.UpdateInterval))
 	o.adaptiveLastTSUpdateInterval.Store(int64(options.UpdateInterval))
 	o.adaptiveUpdateIntervalState.lastTick = time.Now()
+	o.SetTSOBatchOptions(options.TSOBatchMaxWait, options.TSOBatchMaxSize)
 
 	ctx := context.TODO()
 	if !options.NoUpdateTS {
@@ -251,7 +259,15 @@ func (o *pdOracle) GetTimestampAsync(ctx context.Context, opt *oracle.Option) or
 
 func (o *pdOracle) getTimestamp(ctx context.Context, txnScope string) (uint64, error) {
 	now := time.Now()
-	physical, logical, err := o.c.GetTS(ctx)
+	var (
+		physical, logical int64
+		err               error
+	)
+	if b := o.tsoBatcher.Load(); b != nil && !oracle.IsLowLatency(ctx) {
+		physical, logical, err = b.getTS(ctx)
+	} else {
+		physical, logical, err = o.c.GetTS(ctx)
+	}
 	if err != nil {
 		return 0, errors.WithStack(err)
 	}
@@ -521,6 +537,22 @@ func (o *pdOracle) UntilExpired(lockTS uint64, TTL uint64, opt *oracle.Option) i
 
 func (o *pdOracle) Close() {
 	close(o.quit)
+	if b := o.tsoBatcher.Load(); b != nil {
+		b.close()
+	}
+}
+
+// SetTSOBatchOptions makes GetTimestamp calls from concurrent goroutines wait up to maxWait to be batched into one PD
+// request, a batch is sent as soon as it has maxBatchSize calls. Batching is disabled if maxWait is 0. Calls with a
+// context marked by oracle.WithLowLatency always bypass the batching.
+func (o *pdOracle) SetTSOBatchOptions(maxWait time.Duration, maxBatchSize int) {
+	var b *tsoBatcher
+	if maxWait > 0 {
+		b = newTSOBatcher(o.c, maxWait, maxBatchSize)
+	}
+	if old := o.tsoBatcher.Swap(b); old != nil {
+		old.close()
+	}
 }
 
 // A future that resolves immediately to a low resolution timestamp.
@@ -0,0 +1,147 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oracles
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	pd "github.com/tikv/pd/client"
+	"github.com/tikv/pd/client/clients/tso"
+)
+
+const defaultTSOBatchMaxSize = 64
+
+var errTSOBatcherClosed = errors.New("tso batcher is closed")
+
+type tsoResult struct {
+	physical int64
+	logical  int64
+	err      error
+}
+
+type tsoRequest struct {
+	ctx  context.Context
+	done chan tsoResult
+}
+
+// tsoBatcher coalesces the GetTS calls from concurrent goroutines. A batch is
+// flushed when it has maxBatchSize requests or maxWait has elapsed since its
+// first request, then all the requests in the batch are issued to the PD
+// client at once, so that they are sent in the same TSO RPC.
+type tsoBatcher struct {
+	c            pd.Client
+	maxWait      time.Duration
+	maxBatchSize int
+	reqCh        chan *tsoRequest
+	quit         chan struct{}
+	closeOnce    sync.Once
+}
+
+func newTSOBatcher(c pd.Client, maxWait time.Duration, maxBatchSize int) *tsoBatcher {
+	if maxBatchSize <= 0 {
+		maxBatchSize = defaultTSOBatchMaxSize
+	}
+	b := &tsoBatcher{
+		c:            c,
+		maxWait:      maxWait,
+		maxBatchSize: maxBatchSize,
+		reqCh:        make(chan *tsoRequest, maxBatchSize),
+		quit:         make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+func (b *tsoBatcher) close() {
+	b.closeOnce.Do(func() { close(b.quit) })
+}
+
+func (b *tsoBatcher) getTS(ctx context.Context) (int64, int64, error) {
+	req := &tsoRequest{ctx: ctx, done: make(chan tsoResult, 1)}
+	select {
+	case b.reqCh <- req:
+	case <-ctx.Done():
+		return 0, 0, ctx.Err()
+	case <-b.quit:
+		return 0, 0, errTSOBatcherClosed
+	}
+	select {
+	case res := <-req.done:
+		return res.physical, res.logical, res.err
+	case <-ctx.Done():
+		return 0, 0, ctx.Err()
+	case <-b.quit:
+		return 0, 0, errTSOBatcherClosed
+	}
+}
+
+func (b *tsoBatcher) run() {
+	for {
+		var first *tsoRequest
+		select {
+		case first = <-b.reqCh:
+		case <-b.quit:
+			b.failPending()
+			return
+		}
+		batch := []*tsoRequest{first}
+		timer := time.NewTimer(b.maxWait)
+	collect:
+		for len(batch) < b.maxBatchSize {
+			select {
+			case req := <-b.reqCh:
+				batch = append(batch, req)
+			case <-timer.C:
+				break collect
+			case <-b.quit:
+				timer.Stop()
+				for _, req := range batch {
+					req.done <- tsoResult{err: errTSOBatcherClosed}
+				}
+				b.failPending()
+				return
+			}
+		}
+		timer.Stop()
+		b.flush(batch)
+	}
+}
+
+func (b *tsoBatcher) flush(batch []*tsoRequest) {
+	futures := make([]tso.TSFuture, len(batch))
+	for i, req := range batch {
+		futures[i] = b.c.GetTSAsync(req.ctx)
+	}
+	go func() {
+		for i, f := range futures {
+			physical, logical, err := f.Wait()
+			batch[i].done <- tsoResult{physical: physical, logical: logical, err: err}
+		}
+	}()
+}
+
+func (b *tsoBatcher) failPending() {
+	for {
+		select {
+		case req := <-b.reqCh:
+			req.done <- tsoResult{err: errTSOBatcherClosed}
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oracles
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/pd/client/clients/tso"
+)
+
+type mockTSFuture struct {
+	physical, logical int64
+}
+
+func (f *mockTSFuture) Wait() (int64, int64, error) {
+	return f.physical, f.logical, nil
+}
+
+type MockPdClientWithAsync struct {
+	MockPdClient
+
+	asyncCalls atomic.Int64
+}
+
+func (c *MockPdClientWithAsync) GetTSAsync(ctx context.Context) tso.TSFuture {
+	c.asyncCalls.Add(1)
+	p, l, _ := c.GetTS(ctx)
+	return &mockTSFuture{p, l}
+}
+
+func TestTSOBatch(t *testing.T) {
+	pdClient := &MockPdClientWithAsync{}
+	o, err := NewPdOracle(pdClient, &PDOracleOptions{
+		UpdateInterval: time.Second,
+		NoUpdateTS:     true,
+	})
+	assert.Nil(t, err)
+	defer o.Close()
+	o.(*pdOracle).SetTSOBatchOptions(time.Hour, 4)
+
+	// The batch is flushed once it's full, even though the max wait is long.
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := make(map[uint64]struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ts, err := o.GetTimestamp(context.Background(), &oracle.Option{TxnScope: oracle.GlobalTxnScope})
+			assert.Nil(t, err)
+			mu.Lock()
+			seen[ts] = struct{}{}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, seen, 4)
+	assert.Equal(t, int64(4), pdClient.asyncCalls.Load())
+
+	// Low latency requests are not batched.
+	lowLatencyCtx, cancel := context.WithTimeout(oracle.WithLowLatency(context.Background()), time.Second)
+	defer cancel()
+	calls := pdClient.asyncCalls.Load()
+	_, err = o.GetTimestamp(lowLatencyCtx, &oracle.Option{TxnScope: oracle.GlobalTxnScope})
+	assert.Nil(t, err)
+	assert.Equal(t, calls, pdClient.asyncCalls.Load())
+
+	// A batch that isn't full is flushed after the max wait.
+	o.(*pdOracle).SetTSOBatchOptions(10*time.Millisecond, 4)
+	start := time.Now()
+	_, err = o.GetTimestamp(context.Background(), &oracle.Option{TxnScope: oracle.GlobalTxnScope})
+	assert.Nil(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+	assert.Equal(t, calls+1, pdClient.asyncCalls.Load())
+}
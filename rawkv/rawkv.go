@@ -37,6 +37,9 @@ package rawkv
 import (
 	"bytes"
 	"context"
+	"hash/crc32"
+	"math/rand"
+	"sync/atomic"
 	"time"
 
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
@@ -47,13 +50,17 @@ import (
 	"github.com/tikv/client-go/v2/internal/client"
 	"github.com/tikv/client-go/v2/internal/kvrpc"
 	"github.com/tikv/client-go/v2/internal/locate"
+	"github.com/tikv/client-go/v2/internal/logutil"
 	"github.com/tikv/client-go/v2/metrics"
 	"github.com/tikv/client-go/v2/oracle"
 	"github.com/tikv/client-go/v2/tikv"
 	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/tikvrpc/interceptor"
+	"github.com/tikv/client-go/v2/util/slowlog"
 	pd "github.com/tikv/pd/client"
 	"github.com/tikv/pd/client/opt"
 	"github.com/tikv/pd/client/pkg/caller"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
 )
 
@@ -131,6 +138,16 @@ type Client struct {
 	rpcClient   client.Client
 	cf          string
 	atomic      bool
+	// verifyWrites re-reads the values written to check their checksums.
+	verifyWrites bool
+
+	// clientID and writeSeq generate the tokens of the writes, see
+	// tikvrpc.Request.WriteToken.
+	clientID uint64
+	writeSeq atomic.Uint64
+
+	// wal persists the writes before they are sent, see WithWriteAheadLog.
+	wal *writeAheadLog
 }
 
 type option struct {
@@ -139,6 +156,12 @@ type option struct {
 	gRPCDialOptions []grpc.DialOption
 	pdOptions       []opt.ClientOption
 	keyspace        string
+	slowLogger      *slowlog.Logger
+	storeTLS        client.StoreTLSResolver
+	interceptors    []interceptor.RPCInterceptor
+	scanPrefetch    int
+	verifyWrites    bool
+	walDir          string
 }
 
 // ClientOpt is factory to set the client options.
@@ -158,6 +181,55 @@ func WithSecurity(security config.Security) ClientOpt {
 	}
 }
 
+// WithStoreTLS is used to override the TLS settings of the connections per
+// store. See tikv.StoreTLS for details.
+func WithStoreTLS(resolver tikv.StoreTLSResolver) ClientOpt {
+	return func(o *option) {
+		o.storeTLS = resolver
+	}
+}
+
+// WithInterceptors is used to run the interceptors around every RPC sent by
+// the client. See tikv.WithInterceptors for details.
+func WithInterceptors(interceptors ...interceptor.RPCInterceptor) ClientOpt {
+	return func(o *option) {
+		o.interceptors = interceptors
+	}
+}
+
+// WithWriteVerification makes Put and BatchPut read the values back after
+// they are written and compare their CRC32 checksums with the ones written, so
+// that the corruptions in transit, e.g. by unreliable network middleboxes, are
+// surfaced as *tikverr.ErrChecksumMismatch. It doubles the RPCs of the writes.
+// The keys written concurrently by others may fail the check as well.
+func WithWriteVerification() ClientOpt {
+	return func(o *option) {
+		o.verifyWrites = true
+	}
+}
+
+// WithWriteAheadLog persists the puts and deletes to an append-only log in dir
+// before sending them, and replays the ones not acknowledged when a client is
+// created with the same dir, e.g. after the process crashes. It makes the
+// writes delivered at least once for the deployments whose processes may die
+// at any time. A write is acknowledged once it returns, even if it fails, and
+// the acknowledgements aren't synced to the disk, so the writes may be
+// replayed more than once, and the concurrent writes to a key may be replayed
+// in another order. The dir must not be shared by the clients.
+func WithWriteAheadLog(dir string) ClientOpt {
+	return func(o *option) {
+		o.walDir = dir
+	}
+}
+
+// WithScanRegionPrefetch is used to load the next n regions in the background
+// during scans.
+func WithScanRegionPrefetch(n int) ClientOpt {
+	return func(o *option) {
+		o.scanPrefetch = n
+	}
+}
+
 // WithGRPCDialOptions is used to set the grpc.DialOption.
 func WithGRPCDialOptions(opts ...grpc.DialOption) ClientOpt {
 	return func(o *option) {
@@ -179,6 +251,14 @@ func WithKeyspace(name string) ClientOpt {
 	}
 }
 
+// WithSlowLog is used to log the requests to TiKV taking longer than threshold
+// to sink.
+func WithSlowLog(threshold time.Duration, sink slowlog.Sink) ClientOpt {
+	return func(o *option) {
+		o.slowLogger = slowlog.New(threshold, sink)
+	}
+}
+
 // SetAtomicForCAS sets atomic mode for CompareAndSwap
 func (c *Client) SetAtomicForCAS(b bool) *Client {
 	c.atomic = b
@@ -234,15 +314,38 @@ func NewClientWithOpts(ctx context.Context, pdAddrs []string, opts ...ClientOpt)
 		client.WithSecurity(opt.security),
 		client.WithGRPCDialOptions(opt.gRPCDialOptions...),
 		client.WithCodec(codecCli.GetCodec()),
+		client.WithStoreTLS(opt.storeTLS),
+		client.WithInterceptors(opt.interceptors...),
 	)
 
-	return &Client{
-		apiVersion:  opt.apiVersion,
-		clusterID:   pdCli.GetClusterID(ctx),
-		regionCache: locate.NewRegionCache(pdCli),
-		pdClient:    pdCli,
-		rpcClient:   rpcCli,
-	}, nil
+	regionCache := locate.NewRegionCache(pdCli)
+	if opt.slowLogger != nil {
+		regionCache.SetSlowLogger(opt.slowLogger)
+	}
+	regionCache.SetScanPrefetch(opt.scanPrefetch)
+
+	c := &Client{
+		apiVersion:   opt.apiVersion,
+		clusterID:    pdCli.GetClusterID(ctx),
+		regionCache:  regionCache,
+		pdClient:     pdCli,
+		rpcClient:    rpcCli,
+		clientID:     rand.Uint64() | 1,
+		verifyWrites: opt.verifyWrites,
+	}
+	if opt.walDir != "" {
+		wal, entries, err := openWriteAheadLog(opt.walDir)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.wal = wal
+		if err = c.replayWriteAheadLog(ctx, entries); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
 }
 
 // Close closes the client.
@@ -253,6 +356,11 @@ func (c *Client) Close() error {
 	if c.regionCache != nil {
 		c.regionCache.Close()
 	}
+	if c.wal != nil {
+		if err := c.wal.close(); err != nil {
+			logutil.BgLogger().Warn("failed to close rawkv write-ahead log", zap.Error(err))
+		}
+	}
 	if c.rpcClient == nil {
 		return nil
 	}
@@ -338,6 +446,11 @@ func (c *Client) PutWithTTL(ctx context.Context, key, value []byte, ttl uint64,
 	metrics.RawkvSizeHistogramWithValue.Observe(float64(len(value)))
 
 	opts := c.getRawKVOptions(options...)
+	ack, err := c.logWrite(&walEntry{op: walPut, cf: c.getColumnFamily(opts), keys: [][]byte{key}, values: [][]byte{value}, ttls: []uint64{ttl}})
+	if err != nil {
+		return err
+	}
+	defer ack()
 	req := tikvrpc.NewRequest(tikvrpc.CmdRawPut, &kvrpcpb.RawPutRequest{
 		Key:    key,
 		Value:  value,
@@ -345,6 +458,7 @@ func (c *Client) PutWithTTL(ctx context.Context, key, value []byte, ttl uint64,
 		Cf:     c.getColumnFamily(opts),
 		ForCas: c.atomic,
 	})
+	req.WriteToken = c.nextWriteToken()
 	resp, _, err := c.sendReq(ctx, key, req, false)
 	if err != nil {
 		return err
@@ -356,6 +470,9 @@ func (c *Client) PutWithTTL(ctx context.Context, key, value []byte, ttl uint64,
 	if cmdResp.GetError() != "" {
 		return errors.New(cmdResp.GetError())
 	}
+	if c.verifyWrites {
+		return c.verifyValues(ctx, [][]byte{key}, [][]byte{value}, options...)
+	}
 	return nil
 }
 
@@ -421,7 +538,15 @@ func (c *Client) BatchPutWithTTL(ctx context.Context, keys, values [][]byte, ttl
 	}
 	bo := retry.NewBackofferWithVars(ctx, rawkvMaxBackoff, nil)
 	opts := c.getRawKVOptions(options...)
-	err := c.sendBatchPut(bo, keys, values, ttls, opts)
+	ack, err := c.logWrite(&walEntry{op: walPut, cf: c.getColumnFamily(opts), keys: keys, values: values, ttls: ttls})
+	if err != nil {
+		return err
+	}
+	defer ack()
+	err = c.sendBatchPut(bo, keys, values, ttls, opts)
+	if err == nil && c.verifyWrites {
+		err = c.verifyValues(ctx, keys, values, options...)
+	}
 	return err
 }
 
@@ -431,12 +556,18 @@ func (c *Client) Delete(ctx context.Context, key []byte, options ...RawOption) e
 	defer func() { metrics.RawkvCmdHistogramWithDelete.Observe(time.Since(start).Seconds()) }()
 
 	opts := c.getRawKVOptions(options...)
+	ack, err := c.logWrite(&walEntry{op: walDelete, cf: c.getColumnFamily(opts), keys: [][]byte{key}})
+	if err != nil {
+		return err
+	}
+	defer ack()
 	req := tikvrpc.NewRequest(tikvrpc.CmdRawDelete, &kvrpcpb.RawDeleteRequest{
 		Key:    key,
 		Cf:     c.getColumnFamily(opts),
 		ForCas: c.atomic,
 	})
 	req.MaxExecutionDurationMs = uint64(client.MaxWriteExecutionTime.Milliseconds())
+	req.WriteToken = c.nextWriteToken()
 	resp, _, err := c.sendReq(ctx, key, req, false)
 	if err != nil {
 		return err
@@ -460,6 +591,11 @@ func (c *Client) BatchDelete(ctx context.Context, keys [][]byte, options ...RawO
 
 	bo := retry.NewBackofferWithVars(ctx, rawkvMaxBackoff, nil)
 	opts := c.getRawKVOptions(options...)
+	ack, err := c.logWrite(&walEntry{op: walDelete, cf: c.getColumnFamily(opts), keys: keys})
+	if err != nil {
+		return err
+	}
+	defer ack()
 	resp, err := c.sendBatchReq(bo, keys, opts, tikvrpc.CmdRawBatchDelete)
 	if err != nil {
 		return err
@@ -537,6 +673,7 @@ func (c *Client) Scan(ctx context.Context, startKey, endKey []byte, limit int, o
 		if err != nil {
 			return nil, nil, err
 		}
+		c.regionCache.PrefetchForScan(loc, endKey)
 		if resp.Resp == nil {
 			return nil, nil, errors.WithStack(tikverr.ErrBodyMissing)
 		}
@@ -629,6 +766,9 @@ func (c *Client) Checksum(ctx context.Context, startKey, endKey []byte, options
 			return RawChecksum{0, 0, 0}, errors.WithStack(tikverr.ErrBodyMissing)
 		}
 		cmdResp := resp.Resp.(*kvrpcpb.RawChecksumResponse)
+		if cmdResp.GetError() != "" {
+			return RawChecksum{0, 0, 0}, errors.New(cmdResp.GetError())
+		}
 		check.Crc64Xor ^= cmdResp.GetChecksum()
 		check.TotalKvs += cmdResp.GetTotalKvs()
 		check.TotalBytes += cmdResp.GetTotalBytes()
@@ -669,6 +809,7 @@ func (c *Client) CompareAndSwap(ctx context.Context, key, previousValue, newValu
 
 	req := tikvrpc.NewRequest(tikvrpc.CmdRawCompareAndSwap, &reqArgs)
 	req.MaxExecutionDurationMs = uint64(client.MaxWriteExecutionTime.Milliseconds())
+	req.WriteToken = c.nextWriteToken()
 	resp, _, err := c.sendReq(ctx, key, req, false)
 	if err != nil {
 		return nil, false, err
@@ -785,6 +926,7 @@ func (c *Client) doBatchReq(bo *retry.Backoffer, batch kvrpc.Batch, options *raw
 			Cf:     c.getColumnFamily(options),
 			ForCas: c.atomic,
 		})
+		req.WriteToken = c.nextWriteToken()
 	}
 
 	sender := locate.NewRegionRequestSender(c.regionCache, c.rpcClient, oracle.NoopReadTSValidator{})
@@ -942,6 +1084,7 @@ func (c *Client) doBatchPut(bo *retry.Backoffer, batch kvrpc.Batch, opts *rawOpt
 	sender := locate.NewRegionRequestSender(c.regionCache, c.rpcClient, oracle.NoopReadTSValidator{})
 	req.MaxExecutionDurationMs = uint64(client.MaxWriteExecutionTime.Milliseconds())
 	req.ApiVersion = c.apiVersion
+	req.WriteToken = c.nextWriteToken()
 	resp, _, err := sender.SendReq(bo, req, batch.RegionID, client.ReadTimeoutShort)
 	if err != nil {
 		return err
@@ -969,6 +1112,32 @@ func (c *Client) doBatchPut(bo *retry.Backoffer, batch kvrpc.Batch, opts *rawOpt
 	return nil
 }
 
+// nextWriteToken returns the token of a new write, which is shared by the
+// retries of the write to avoid applying it twice. The writes of a client
+// without a clientID have no token.
+func (c *Client) nextWriteToken() tikvrpc.WriteToken {
+	if c.clientID == 0 {
+		return tikvrpc.WriteToken{}
+	}
+	return tikvrpc.WriteToken{ClientID: c.clientID, Seq: c.writeSeq.Add(1)}
+}
+
+// verifyValues reads keys back and checks the checksums of their values, see
+// WithWriteVerification.
+func (c *Client) verifyValues(ctx context.Context, keys, values [][]byte, options ...RawOption) error {
+	got, err := c.BatchGet(ctx, keys, options...)
+	if err != nil {
+		return err
+	}
+	for i, key := range keys {
+		expected, actual := crc32.ChecksumIEEE(values[i]), crc32.ChecksumIEEE(got[i])
+		if expected != actual {
+			return errors.WithStack(&tikverr.ErrChecksumMismatch{Key: key, Expected: expected, Actual: actual})
+		}
+	}
+	return nil
+}
+
 func (c *Client) getColumnFamily(options *rawOptions) string {
 	if options.ColumnFamily == "" {
 		return c.cf
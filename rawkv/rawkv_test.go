@@ -40,14 +40,18 @@ import (
 	"fmt"
 	"hash/crc64"
 	"testing"
+	"time"
 
 	"github.com/pingcap/failpoint"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/suite"
 	"github.com/tikv/client-go/v2/config/retry"
+	tikverr "github.com/tikv/client-go/v2/error"
 	"github.com/tikv/client-go/v2/internal/locate"
 	"github.com/tikv/client-go/v2/internal/mockstore/mocktikv"
 	"github.com/tikv/client-go/v2/kv"
 	"github.com/tikv/client-go/v2/tikv"
+	"github.com/tikv/client-go/v2/tikvrpc"
 )
 
 func TestRawKV(t *testing.T) {
@@ -656,4 +660,192 @@ func (s *testRawkvSuite) TestRawChecksum() {
 	s.Equal(expectCrc64Xor, check.Crc64Xor)
 	s.Equal(expectTotalKvs, check.TotalKvs)
 	s.Equal(expectTotalBytes, check.TotalBytes)
+
+	// The checksums of the regions are aggregated.
+	ids := s.cluster.AllocIDs(3)
+	s.cluster.SplitRaw(s.region1, ids[0], []byte("key2"), ids[1:], ids[1])
+	check, err = client.Checksum(context.Background(), startKey, endKey, SetColumnFamily(cf))
+	s.Nil(err)
+	s.Equal(expectCrc64Xor, check.Crc64Xor)
+	s.Equal(expectTotalKvs, check.TotalKvs)
+	s.Equal(expectTotalBytes, check.TotalBytes)
+
+	// Only the keys in the range are counted.
+	check, err = client.Checksum(context.Background(), []byte("key1"), []byte("key3"), SetColumnFamily(cf))
+	s.Nil(err)
+	s.Equal(uint64(2), check.TotalKvs)
+	s.Equal(uint64(len("key1value1key2value2")), check.TotalBytes)
+}
+
+// lostRespClient drops the response of the first CAS request after it's
+// applied, like a timeout after the write is committed.
+type lostRespClient struct {
+	tikv.Client
+	lost bool
+}
+
+func (c *lostRespClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	resp, err := c.Client.SendRequest(ctx, addr, req, timeout)
+	if err == nil && req.Type == tikvrpc.CmdRawCompareAndSwap && !c.lost {
+		c.lost = true
+		return nil, errors.New("mock lost response")
+	}
+	return resp, err
+}
+
+func (s *testRawkvSuite) TestWriteTokenDedup() {
+	mvccStore := mocktikv.MustNewMVCCStore()
+	defer mvccStore.Close()
+	// The lost response mustn't make the store unreachable.
+	s.Nil(failpoint.Enable("tikvclient/injectLiveness", `return("reachable")`))
+	defer failpoint.Disable("tikvclient/injectLiveness")
+
+	client := &Client{
+		clusterID:   0,
+		regionCache: locate.NewRegionCache(mocktikv.NewPDClient(s.cluster)),
+		rpcClient:   &lostRespClient{Client: mocktikv.NewRPCClient(s.cluster, mvccStore, nil)},
+		atomic:      true,
+		clientID:    1,
+	}
+	defer client.Close()
+	testKey := []byte("test_key")
+	testValue := []byte("test_value")
+
+	// The retry of the CAS gets the result of the applied one, instead of
+	// failing for the value written by itself.
+	_, swapped, err := client.CompareAndSwap(context.Background(), testKey, nil, testValue)
+	s.Nil(err)
+	s.True(swapped)
+	s.Equal(uint64(1), s.cluster.DeduplicatedWrites())
+
+	getVal, err := client.Get(context.Background(), testKey)
+	s.Nil(err)
+	s.Equal(testValue, getVal)
+	_, swapped, err = client.CompareAndSwap(context.Background(), testKey, nil, testValue)
+	s.Nil(err)
+	s.False(swapped)
+}
+
+// corruptClient flips the last byte of the values of the RawPut requests, like
+// a faulty network middlebox.
+type corruptClient struct {
+	tikv.Client
+}
+
+func (c *corruptClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	if req.Type == tikvrpc.CmdRawPut {
+		putReq := *req.RawPut()
+		putReq.Value = append([]byte(nil), putReq.Value...)
+		putReq.Value[len(putReq.Value)-1] ^= 0xff
+		req = tikvrpc.NewRequest(req.Type, &putReq, req.Context)
+	}
+	return c.Client.SendRequest(ctx, addr, req, timeout)
+}
+
+func (s *testRawkvSuite) TestWriteVerification() {
+	mvccStore := mocktikv.MustNewMVCCStore()
+	defer mvccStore.Close()
+
+	rpcClient := mocktikv.NewRPCClient(s.cluster, mvccStore, nil)
+	client := &Client{
+		clusterID:    0,
+		regionCache:  locate.NewRegionCache(mocktikv.NewPDClient(s.cluster)),
+		rpcClient:    rpcClient,
+		verifyWrites: true,
+	}
+	defer client.Close()
+
+	keys := [][]byte{[]byte("key1"), []byte("key2")}
+	values := [][]byte{[]byte("value1"), []byte("value2")}
+	s.Nil(client.Put(context.Background(), keys[0], values[0]))
+	s.Nil(client.BatchPut(context.Background(), keys, values))
+
+	client.rpcClient = &corruptClient{Client: rpcClient}
+	err := client.Put(context.Background(), keys[0], []byte("value3"))
+	s.True(tikverr.IsErrChecksumMismatch(err))
+	var mismatch *tikverr.ErrChecksumMismatch
+	s.True(errors.As(err, &mismatch))
+	s.Equal(keys[0], mismatch.Key)
+	// The batch puts aren't corrupted.
+	s.Nil(client.BatchPut(context.Background(), keys, values))
+}
+
+func (s *testRawkvSuite) TestWriteAheadLog() {
+	mvccStore := mocktikv.MustNewMVCCStore()
+	defer mvccStore.Close()
+	client := &Client{
+		clusterID:   0,
+		regionCache: locate.NewRegionCache(mocktikv.NewPDClient(s.cluster)),
+		rpcClient:   mocktikv.NewRPCClient(s.cluster, mvccStore, nil),
+	}
+	defer client.Close()
+	ctx := context.Background()
+	dir := s.T().TempDir()
+
+	wal, entries, err := openWriteAheadLog(dir)
+	s.Nil(err)
+	s.Empty(entries)
+	client.wal = wal
+	s.Nil(client.Put(ctx, []byte("k1"), []byte("v1")))
+	s.Nil(client.BatchPut(ctx, [][]byte{[]byte("k2"), []byte("k3")}, [][]byte{[]byte("v2"), []byte("v3")}))
+	s.Nil(client.Delete(ctx, []byte("k3")))
+	// The process crashes after logging the writes but before sending them.
+	_, err = wal.append(&walEntry{op: walPut, keys: [][]byte{[]byte("k4"), []byte("k5")}, values: [][]byte{[]byte("v4"), []byte("v5")}})
+	s.Nil(err)
+	_, err = wal.append(&walEntry{op: walDelete, keys: [][]byte{[]byte("k1")}})
+	s.Nil(err)
+	// A torn record is left at the end of the log.
+	_, err = wal.f.Write([]byte{1, 2, 3})
+	s.Nil(err)
+	s.Nil(wal.close())
+
+	wal, entries, err = openWriteAheadLog(dir)
+	s.Nil(err)
+	s.Len(entries, 2)
+	client.wal = wal
+	s.Nil(client.replayWriteAheadLog(ctx, entries))
+	for key, expected := range map[string]string{"k1": "", "k2": "v2", "k3": "", "k4": "v4", "k5": "v5"} {
+		v, err := client.Get(ctx, []byte(key))
+		s.Nil(err)
+		s.Equal(expected, string(v))
+	}
+
+	// All the writes are acknowledged after the replay.
+	s.Nil(wal.close())
+	wal, entries, err = openWriteAheadLog(dir)
+	s.Nil(err)
+	s.Empty(entries)
+	s.Empty(wal.pending)
+	client.wal = wal
+}
+
+func (s *testRawkvSuite) TestWriteAheadLogCompaction() {
+	dir := s.T().TempDir()
+	wal, _, err := openWriteAheadLog(dir)
+	s.Nil(err)
+	seq1, err := wal.append(&walEntry{op: walPut, keys: [][]byte{[]byte("k1")}, values: [][]byte{make([]byte, walCompactSize)}})
+	s.Nil(err)
+	seq2, err := wal.append(&walEntry{op: walDelete, keys: [][]byte{[]byte("k2")}})
+	s.Nil(err)
+	// The log is compacted to the write in flight once the large write is
+	// acknowledged.
+	wal.ack(seq1)
+	s.Less(wal.size, int64(1024))
+	info, err := wal.f.Stat()
+	s.Nil(err)
+	s.Equal(wal.size, info.Size())
+
+	// The compacted log is appended as usual.
+	seq3, err := wal.append(&walEntry{op: walPut, keys: [][]byte{[]byte("k3")}, values: [][]byte{[]byte("v3")}})
+	s.Nil(err)
+	wal.ack(seq3)
+	s.Nil(wal.close())
+	wal, entries, err := openWriteAheadLog(dir)
+	s.Nil(err)
+	s.Len(entries, 1)
+	s.Equal(seq2, entries[0].seq)
+	s.Equal([]byte("k2"), entries[0].keys[0])
+
+	wal.ack(seq2)
+	s.Nil(wal.close())
 }
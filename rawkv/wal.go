@@ -0,0 +1,396 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rawkv
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"go.uber.org/zap"
+)
+
+const (
+	walFileName = "rawkv.wal"
+	// walCompactSize is the size the log is compacted at, if at least half of
+	// it is taken by the acknowledged writes.
+	walCompactSize = 64 << 20
+	// walHeaderSize is the size of the length and the checksum of a record.
+	walHeaderSize = 8
+)
+
+var walCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+type walOp byte
+
+const (
+	walPut walOp = iota + 1
+	walDelete
+	walAck
+)
+
+// walEntry is a record of the write-ahead log. An ack record only has the
+// sequence number of the write acknowledged.
+type walEntry struct {
+	op     walOp
+	seq    uint64
+	cf     string
+	keys   [][]byte
+	values [][]byte
+	ttls   []uint64
+}
+
+func (e *walEntry) marshal() []byte {
+	buf := make([]byte, walHeaderSize, walHeaderSize+64)
+	buf = append(buf, byte(e.op))
+	buf = binary.AppendUvarint(buf, e.seq)
+	if e.op != walAck {
+		buf = appendWALBytes(buf, []byte(e.cf))
+		buf = binary.AppendUvarint(buf, uint64(len(e.keys)))
+		for i, key := range e.keys {
+			buf = appendWALBytes(buf, key)
+			if e.op == walPut {
+				buf = appendWALBytes(buf, e.values[i])
+				var ttl uint64
+				if len(e.ttls) > 0 {
+					ttl = e.ttls[i]
+				}
+				buf = binary.AppendUvarint(buf, ttl)
+			}
+		}
+	}
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(buf)-walHeaderSize))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.Checksum(buf[walHeaderSize:], walCRCTable))
+	return buf
+}
+
+func appendWALBytes(buf, b []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+type walDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *walDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = errors.New("corrupted write-ahead log record")
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *walDecoder) bytes() []byte {
+	l := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	if uint64(len(d.buf)) < l {
+		d.err = errors.New("corrupted write-ahead log record")
+		return nil
+	}
+	b := d.buf[:l:l]
+	d.buf = d.buf[l:]
+	return b
+}
+
+func unmarshalWALEntry(payload []byte) (*walEntry, error) {
+	if len(payload) == 0 {
+		return nil, errors.New("empty write-ahead log record")
+	}
+	e := &walEntry{op: walOp(payload[0])}
+	d := &walDecoder{buf: payload[1:]}
+	e.seq = d.uvarint()
+	switch e.op {
+	case walAck:
+	case walPut, walDelete:
+		e.cf = string(d.bytes())
+		n := d.uvarint()
+		for i := uint64(0); i < n && d.err == nil; i++ {
+			e.keys = append(e.keys, d.bytes())
+			if e.op == walPut {
+				e.values = append(e.values, d.bytes())
+				e.ttls = append(e.ttls, d.uvarint())
+			}
+		}
+	default:
+		return nil, errors.Errorf("unknown write-ahead log record type %d", e.op)
+	}
+	return e, d.err
+}
+
+// writeAheadLog persists the rawkv writes to a local append-only file before
+// they are sent, so that the writes interrupted by the crashes of the process
+// are replayed when the client is created again. A write is acknowledged by
+// appending an ack record once it's returned, which isn't synced to the disk,
+// so a write may be replayed even if it's done. The log is compacted to the
+// writes not acknowledged once it grows large, so it's bounded by the writes
+// in flight rather than all the writes done.
+type writeAheadLog struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	size int64
+	seq  uint64
+	// pending is the records of the writes not acknowledged by sequence
+	// number, which are written to the compacted log.
+	pending     map[uint64][]byte
+	pendingSize int64
+}
+
+// openWriteAheadLog opens the log in dir, and returns the writes not
+// acknowledged in it in order. The records torn by a crash at the end of the
+// log are discarded.
+func openWriteAheadLog(dir string) (*writeAheadLog, []*walEntry, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	path := filepath.Join(dir, walFileName)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	w := &writeAheadLog{path: path, f: f, pending: make(map[uint64][]byte)}
+	entries, err := w.recover()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return w, entries, nil
+}
+
+func (w *writeAheadLog) recover() ([]*walEntry, error) {
+	pending := make(map[uint64]*walEntry)
+	r := bufio.NewReader(w.f)
+	var header [walHeaderSize]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				return nil, errors.WithStack(err)
+			}
+			break
+		}
+		payload := make([]byte, binary.LittleEndian.Uint32(header[0:4]))
+		if _, err := io.ReadFull(r, payload); err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				return nil, errors.WithStack(err)
+			}
+			break
+		}
+		if crc32.Checksum(payload, walCRCTable) != binary.LittleEndian.Uint32(header[4:8]) {
+			break
+		}
+		e, err := unmarshalWALEntry(payload)
+		if err != nil {
+			break
+		}
+		w.size += int64(walHeaderSize + len(payload))
+		if e.seq > w.seq {
+			w.seq = e.seq
+		}
+		if e.op == walAck {
+			delete(pending, e.seq)
+			w.removePending(e.seq)
+		} else {
+			pending[e.seq] = e
+			w.addPending(e.seq, append(header[:], payload...))
+		}
+	}
+	if err := w.f.Truncate(w.size); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if _, err := w.f.Seek(w.size, io.SeekStart); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	entries := make([]*walEntry, 0, len(pending))
+	for _, e := range pending {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+	return entries, nil
+}
+
+func (w *writeAheadLog) addPending(seq uint64, record []byte) {
+	w.pending[seq] = record
+	w.pendingSize += int64(len(record))
+}
+
+func (w *writeAheadLog) removePending(seq uint64) {
+	w.pendingSize -= int64(len(w.pending[seq]))
+	delete(w.pending, seq)
+}
+
+// append persists the write, and returns its sequence number to acknowledge.
+// The write must fail if it returns an error, since the record isn't kept
+// in the log.
+func (w *writeAheadLog) append(e *walEntry) (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.seq++
+	e.seq = w.seq
+	size := w.size
+	buf, err := w.write(e)
+	if err != nil {
+		return 0, err
+	}
+	if err = w.f.Sync(); err != nil {
+		// Don't replay the write failed.
+		w.truncateTo(size)
+		return 0, errors.WithStack(err)
+	}
+	w.addPending(e.seq, buf)
+	return e.seq, nil
+}
+
+// ack acknowledges the write once it's applied, which won't be replayed
+// anymore unless the ack is lost by a crash. The log is compacted if it's
+// large and mostly acknowledged.
+func (w *writeAheadLog) ack(seq uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.removePending(seq)
+	if w.size >= walCompactSize && w.size >= 2*w.pendingSize {
+		err := w.compact()
+		if err == nil {
+			return
+		}
+		logutil.BgLogger().Warn("failed to compact rawkv write-ahead log", zap.Error(err))
+	}
+	if _, err := w.write(&walEntry{op: walAck, seq: seq}); err != nil {
+		logutil.BgLogger().Warn("failed to acknowledge rawkv write in write-ahead log", zap.Uint64("seq", seq), zap.Error(err))
+	}
+}
+
+func (w *writeAheadLog) write(e *walEntry) ([]byte, error) {
+	buf := e.marshal()
+	n, err := w.f.Write(buf)
+	if err != nil {
+		// Drop the partial record, which would fail the recovery of the
+		// records following it.
+		if n > 0 {
+			w.truncateTo(w.size)
+		}
+		return nil, errors.WithStack(err)
+	}
+	w.size += int64(n)
+	return buf, nil
+}
+
+func (w *writeAheadLog) truncateTo(size int64) {
+	if w.f.Truncate(size) == nil {
+		if _, err := w.f.Seek(size, io.SeekStart); err == nil {
+			w.size = size
+		}
+	}
+}
+
+// compact replaces the log by a new one of the writes not acknowledged.
+func (w *writeAheadLog) compact() error {
+	seqs := make([]uint64, 0, len(w.pending))
+	for seq := range w.pending {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+
+	tmpPath := w.path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var size int64
+	for _, seq := range seqs {
+		n, err := f.Write(w.pending[seq])
+		if err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+			return errors.WithStack(err)
+		}
+		size += int64(n)
+	}
+	if err = f.Sync(); err == nil {
+		err = os.Rename(tmpPath, w.path)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return errors.WithStack(err)
+	}
+	w.f.Close()
+	w.f, w.size = f, size
+	return nil
+}
+
+func (w *writeAheadLog) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return errors.WithStack(w.f.Close())
+}
+
+// logWrite persists the write to the write-ahead log if it's enabled, and
+// returns the function to acknowledge it once the write returns.
+func (c *Client) logWrite(e *walEntry) (func(), error) {
+	if c.wal == nil {
+		return func() {}, nil
+	}
+	seq, err := c.wal.append(e)
+	if err != nil {
+		return nil, err
+	}
+	return func() { c.wal.ack(seq) }, nil
+}
+
+// replayWriteAheadLog writes the entries recovered from the write-ahead log
+// again in order. It stops at the first failed write, whose entry and the
+// following ones stay in the log to be replayed by the next client.
+func (c *Client) replayWriteAheadLog(ctx context.Context, entries []*walEntry) error {
+	for _, e := range entries {
+		cf := SetColumnFamily(e.cf)
+		var err error
+		switch {
+		case e.op == walPut && len(e.keys) == 1:
+			err = c.PutWithTTL(ctx, e.keys[0], e.values[0], e.ttls[0], cf)
+		case e.op == walPut:
+			err = c.BatchPutWithTTL(ctx, e.keys, e.values, e.ttls, cf)
+		case len(e.keys) == 1:
+			err = c.Delete(ctx, e.keys[0], cf)
+		default:
+			err = c.BatchDelete(ctx, e.keys, cf)
+		}
+		if err != nil {
+			return errors.WithMessagef(err, "replay rawkv write %d in write-ahead log", e.seq)
+		}
+		c.wal.ack(e.seq)
+	}
+	if len(entries) > 0 {
+		logutil.BgLogger().Info("replayed rawkv writes in write-ahead log", zap.Int("count", len(entries)))
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package restore writes the key-value pairs of backup range files back into
+// a running cluster, for small-scale restores without the BR toolchain. The
+// keys can be moved to other prefixes by rewrite rules on the way.
+//
+// The pairs are written in batches, by RawKV puts with Raw, or by a
+// transaction per batch with Txn. Ingesting SST files isn't supported, because
+// the client has no access to the import service of TiKV, so a restore costs
+// as much as writing the data, and the restored values are visible batch by
+// batch rather than atomically.
+package restore
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/rawkv"
+	"github.com/tikv/client-go/v2/tikv"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	defaultBatchSize   = 256
+	defaultConcurrency = 4
+)
+
+// RangeFile is a backup file of the pairs in a key range.
+type RangeFile interface {
+	// Next returns the next pair in the file, or io.EOF if there is no more.
+	Next() (key, value []byte, err error)
+}
+
+// PairsFile is a RangeFile of the pairs in memory.
+type PairsFile struct {
+	Keys   [][]byte
+	Values [][]byte
+	next   int
+}
+
+// Next implements RangeFile.
+func (f *PairsFile) Next() (key, value []byte, err error) {
+	if f.next >= len(f.Keys) {
+		return nil, nil, io.EOF
+	}
+	f.next++
+	return f.Keys[f.next-1], f.Values[f.next-1], nil
+}
+
+// RewriteRule moves the keys with OldPrefix to NewPrefix.
+type RewriteRule struct {
+	OldPrefix []byte
+	NewPrefix []byte
+}
+
+// Stats is the statistics of a restore.
+type Stats struct {
+	// Files is the number of the files to restore.
+	Files int
+	// Pairs and Bytes are the number and the size of the pairs written.
+	Pairs int64
+	Bytes int64
+}
+
+type options struct {
+	rules       []RewriteRule
+	batchSize   int
+	concurrency int
+	skipOthers  bool
+}
+
+// Option configures a restore.
+type Option func(*options)
+
+// WithRewriteRules rewrites the prefixes of the keys by the first rule
+// matching the key.
+func WithRewriteRules(rules ...RewriteRule) Option {
+	return func(o *options) {
+		o.rules = append(o.rules, rules...)
+	}
+}
+
+// WithSkipUnmatched skips the keys matching no rewrite rule instead of
+// writing them as they are.
+func WithSkipUnmatched() Option {
+	return func(o *options) {
+		o.skipOthers = true
+	}
+}
+
+// WithBatchSize sets the number of the pairs written in a batch, the default
+// is 256.
+func WithBatchSize(size int) Option {
+	return func(o *options) {
+		o.batchSize = size
+	}
+}
+
+// WithConcurrency sets the number of the files restored concurrently, the
+// default is 4.
+func WithConcurrency(concurrency int) Option {
+	return func(o *options) {
+		o.concurrency = concurrency
+	}
+}
+
+// Raw restores the files into the cluster of the RawKV client. It stops at
+// the first error, and the pairs written before it stay in the cluster, so a
+// failed restore can be retried as a whole.
+func Raw(ctx context.Context, client *rawkv.Client, files []RangeFile, opts ...Option) (*Stats, error) {
+	return restoreFiles(ctx, files, opts, func(ctx context.Context, keys, values [][]byte) error {
+		return client.BatchPut(ctx, keys, values)
+	})
+}
+
+// Txn restores the files into the transactional cluster of store, e.g. the
+// KVStore of a txnkv.Client. Each batch is written by a transaction of its
+// own, so the restored keys get new commit timestamps instead of the versions
+// in the backup, and a restore conflicting with the other writes of the keys
+// fails. Like Raw, it stops at the first error, and the batches committed
+// before it stay in the cluster.
+func Txn(ctx context.Context, store *tikv.KVStore, files []RangeFile, opts ...Option) (*Stats, error) {
+	return restoreFiles(ctx, files, opts, func(ctx context.Context, keys, values [][]byte) error {
+		txn, err := store.Begin()
+		if err != nil {
+			return err
+		}
+		for i := range keys {
+			if err = txn.Set(keys[i], values[i]); err != nil {
+				txn.Rollback()
+				return err
+			}
+		}
+		return txn.Commit(ctx)
+	})
+}
+
+// restoreFiles writes the pairs of the files by put, which must not keep the
+// slices after it returns.
+func restoreFiles(ctx context.Context, files []RangeFile, opts []Option, put func(ctx context.Context, keys, values [][]byte) error) (*Stats, error) {
+	o := &options{batchSize: defaultBatchSize, concurrency: defaultConcurrency}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.batchSize <= 0 {
+		o.batchSize = defaultBatchSize
+	}
+	if o.concurrency <= 0 {
+		o.concurrency = defaultConcurrency
+	}
+
+	var pairs, size atomic.Int64
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(o.concurrency)
+	for i := range files {
+		file := files[i]
+		g.Go(func() error {
+			keys := make([][]byte, 0, o.batchSize)
+			values := make([][]byte, 0, o.batchSize)
+			batchBytes := 0
+			flush := func() error {
+				if len(keys) == 0 {
+					return nil
+				}
+				if err := put(ctx, keys, values); err != nil {
+					return err
+				}
+				pairs.Add(int64(len(keys)))
+				size.Add(int64(batchBytes))
+				keys, values, batchBytes = keys[:0], values[:0], 0
+				return nil
+			}
+			for {
+				key, value, err := file.Next()
+				if err == io.EOF {
+					return flush()
+				}
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				key, ok := o.rewrite(key)
+				if !ok {
+					continue
+				}
+				keys = append(keys, key)
+				values = append(values, value)
+				batchBytes += len(key) + len(value)
+				if len(keys) >= o.batchSize {
+					if err = flush(); err != nil {
+						return err
+					}
+				}
+			}
+		})
+	}
+	err := g.Wait()
+	return &Stats{Files: len(files), Pairs: pairs.Load(), Bytes: size.Load()}, err
+}
+
+// rewrite returns the key rewritten by the rules, and whether it should be
+// restored.
+func (o *options) rewrite(key []byte) ([]byte, bool) {
+	for _, rule := range o.rules {
+		if bytes.HasPrefix(key, rule.OldPrefix) {
+			newKey := make([]byte, 0, len(rule.NewPrefix)+len(key)-len(rule.OldPrefix))
+			newKey = append(newKey, rule.NewPrefix...)
+			return append(newKey, key[len(rule.OldPrefix):]...), true
+		}
+	}
+	return key, !o.skipOthers
+}
@@ -0,0 +1,110 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/rawkv"
+	"github.com/tikv/client-go/v2/testutils"
+	"github.com/tikv/client-go/v2/tikv"
+	tikvtesting "github.com/tikv/client-go/v2/tikv/testing"
+)
+
+func newTestRawClient(t *testing.T) *rawkv.Client {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	require.NoError(t, err)
+	testutils.BootstrapWithSingleStore(cluster)
+	probe := rawkv.ClientProbe{Client: &rawkv.Client{}}
+	probe.SetPDClient(pdClient)
+	probe.SetRegionCache(tikv.NewRegionCache(pdClient))
+	probe.SetRPCClient(client)
+	t.Cleanup(func() { probe.Close() })
+	return probe.Client
+}
+
+func TestRaw(t *testing.T) {
+	cli := newTestRawClient(t)
+	ctx := context.Background()
+
+	var files []RangeFile
+	for i := 0; i < 3; i++ {
+		file := &PairsFile{}
+		for j := 0; j < 10; j++ {
+			file.Keys = append(file.Keys, []byte(fmt.Sprintf("old%d-%d", i, j)))
+			file.Values = append(file.Values, []byte("v"))
+		}
+		files = append(files, file)
+	}
+	files = append(files, &PairsFile{Keys: [][]byte{[]byte("other")}, Values: [][]byte{[]byte("v")}})
+
+	stats, err := Raw(ctx, cli, files,
+		WithRewriteRules(RewriteRule{OldPrefix: []byte("old"), NewPrefix: []byte("new")}),
+		WithBatchSize(4), WithConcurrency(2))
+	require.NoError(t, err)
+	require.Equal(t, 4, stats.Files)
+	require.Equal(t, int64(31), stats.Pairs)
+	require.Equal(t, int64(30*len("new0-0v")+len("otherv")), stats.Bytes)
+
+	value, err := cli.Get(ctx, []byte("new2-9"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("v"), value)
+	value, err = cli.Get(ctx, []byte("old2-9"))
+	require.NoError(t, err)
+	require.Nil(t, value)
+	value, err = cli.Get(ctx, []byte("other"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("v"), value)
+
+	// The keys matching no rule are skipped.
+	stats, err = Raw(ctx, cli, []RangeFile{&PairsFile{Keys: [][]byte{[]byte("skipped"), []byte("old")}, Values: [][]byte{[]byte("v"), []byte("v")}}},
+		WithRewriteRules(RewriteRule{OldPrefix: []byte("old"), NewPrefix: []byte("new")}), WithSkipUnmatched())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), stats.Pairs)
+	value, err = cli.Get(ctx, []byte("skipped"))
+	require.NoError(t, err)
+	require.Nil(t, value)
+}
+
+func TestTxn(t *testing.T) {
+	store, err := tikvtesting.NewStore()
+	require.NoError(t, err)
+	defer store.Close()
+	ctx := context.Background()
+
+	file := &PairsFile{}
+	for i := 0; i < 10; i++ {
+		file.Keys = append(file.Keys, []byte(fmt.Sprintf("old%d", i)))
+		file.Values = append(file.Values, []byte("v"))
+	}
+	stats, err := Txn(ctx, store, []RangeFile{file, &PairsFile{Keys: [][]byte{[]byte("other")}, Values: [][]byte{[]byte("v")}}},
+		WithRewriteRules(RewriteRule{OldPrefix: []byte("old"), NewPrefix: []byte("new")}),
+		WithBatchSize(4), WithSkipUnmatched())
+	require.NoError(t, err)
+	require.Equal(t, 2, stats.Files)
+	require.Equal(t, int64(10), stats.Pairs)
+
+	txn, err := store.Begin()
+	require.NoError(t, err)
+	value, err := txn.Get(ctx, []byte("new9"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("v"), value)
+	_, err = txn.Get(ctx, []byte("other"))
+	require.True(t, tikverr.IsErrNotFound(err))
+}
@@ -56,6 +56,18 @@ var BootstrapWithMultiStores = mocktikv.BootstrapWithMultiStores
 // Store. The number of Regions will be len(splitKeys) + 1.
 var BootstrapWithMultiRegions = mocktikv.BootstrapWithMultiRegions
 
+// StreamInterruption breaks the streaming responses of the mock cluster
+// mid-stream, see MockCluster.InterruptStreams.
+type StreamInterruption = mocktikv.StreamInterruption
+
+// RequestFault is the failure injected into the requests to the mock cluster,
+// see MockCluster.FailNext and MockCluster.FailNth.
+type RequestFault = mocktikv.RequestFault
+
+// RPCStats is the accounting of the requests served by the mock cluster, see
+// MockCluster.Stats.
+type RPCStats = mocktikv.RPCStats
+
 // ErrLocked is returned when trying to Read/Write on a locked key. Client should
 // backoff or cleanup the lock then retry.
 type ErrLocked = mocktikv.ErrLocked
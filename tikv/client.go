@@ -38,6 +38,7 @@ import (
 	"github.com/tikv/client-go/v2/config"
 	"github.com/tikv/client-go/v2/internal/apicodec"
 	"github.com/tikv/client-go/v2/internal/client"
+	"github.com/tikv/client-go/v2/tikvrpc/interceptor"
 )
 
 // Client is a client that sends RPC.
@@ -47,6 +48,9 @@ type Client = client.Client
 // ClientEventListener is a listener to handle events produced by `Client`.
 type ClientEventListener = client.ClientEventListener
 
+// BatchStats is the statistics of the batch commands pipeline to a store, see RPCClient.BatchStats.
+type BatchStats = client.BatchStats
+
 // ClientOpt defines the option to create RPC client.
 type ClientOpt = client.Opt
 
@@ -55,6 +59,23 @@ func WithSecurity(security config.Security) ClientOpt {
 	return client.WithSecurity(security)
 }
 
+// StoreTLS overrides the TLS settings of the connections to a store.
+type StoreTLS = client.StoreTLS
+
+// StoreTLSResolver returns the TLS overrides of the store at an address, or nil to use the default settings.
+type StoreTLSResolver = client.StoreTLSResolver
+
+// WithStoreTLS is used to override the TLS settings per store.
+func WithStoreTLS(resolver StoreTLSResolver) ClientOpt {
+	return client.WithStoreTLS(resolver)
+}
+
+// WithInterceptors is used to run the interceptors around every RPC sent by the client.
+// See client.WithInterceptors for the order and the metrics.
+func WithInterceptors(interceptors ...interceptor.RPCInterceptor) ClientOpt {
+	return client.WithInterceptors(interceptors...)
+}
+
 // WithCodec is used to set client codec.
 func WithCodec(codec apicodec.Codec) ClientOpt {
 	return client.WithCodec(codec)
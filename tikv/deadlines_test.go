@@ -0,0 +1,93 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/internal/client"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/tikvrpc/interceptor"
+)
+
+func TestOperationDeadlines(t *testing.T) {
+	store, _, err := newMockStore(nil, WithOperationDeadlines(kv.OperationDeadlines{Commit: 5 * time.Second}))
+	require.Nil(t, err)
+	defer store.Close()
+
+	var mu sync.Mutex
+	durations := make(map[tikvrpc.CmdType]uint64)
+	recorder := interceptor.NewRPCInterceptor("max-execution-duration", func(next interceptor.RPCInterceptorFunc) interceptor.RPCInterceptorFunc {
+		return func(target string, req *tikvrpc.Request) (*tikvrpc.Response, error) {
+			mu.Lock()
+			durations[req.Type] = req.MaxExecutionDurationMs
+			mu.Unlock()
+			return next(target, req)
+		}
+	})
+	duration := func(cmd tikvrpc.CmdType) uint64 {
+		mu.Lock()
+		defer mu.Unlock()
+		d, ok := durations[cmd]
+		require.True(t, ok, "%v is not sent", cmd)
+		return d
+	}
+
+	// The transactions inherit the default deadlines of the store.
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	txn.SetRPCInterceptor(recorder)
+	require.Nil(t, txn.Set([]byte("od1"), []byte("v1")))
+	require.Nil(t, txn.Set([]byte("od2"), []byte("v2")))
+	require.Nil(t, txn.Commit(context.Background()))
+	require.LessOrEqual(t, duration(tikvrpc.CmdPrewrite), uint64(5000))
+	require.LessOrEqual(t, duration(tikvrpc.CmdCommit), uint64(5000))
+
+	ctx := context.Background()
+	snapshot := store.GetSnapshot(math.MaxUint64)
+	snapshot.SetOperationDeadlines(kv.OperationDeadlines{Get: 2 * time.Second, BatchGet: 3 * time.Second, Scan: 4 * time.Second})
+	snapshot.SetRPCInterceptor(recorder)
+	_, err = snapshot.Get(ctx, []byte("od1"))
+	require.Nil(t, err)
+	require.LessOrEqual(t, duration(tikvrpc.CmdGet), uint64(2000))
+	_, err = snapshot.BatchGet(ctx, [][]byte{[]byte("od1"), []byte("od2")})
+	require.Nil(t, err)
+	require.LessOrEqual(t, duration(tikvrpc.CmdBatchGet), uint64(3000))
+	it, err := snapshot.Iter([]byte("od"), []byte("oe"))
+	require.Nil(t, err)
+	require.True(t, it.Valid())
+	it.Close()
+	require.LessOrEqual(t, duration(tikvrpc.CmdScan), uint64(4000))
+
+	// The deadline of the caller overrides the default one.
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	_, err = snapshot.Get(ctx, []byte("od1"))
+	require.Nil(t, err)
+	require.Greater(t, duration(tikvrpc.CmdGet), uint64(2000))
+	require.LessOrEqual(t, duration(tikvrpc.CmdGet), uint64(10000))
+
+	// The requests are limited by the RPC timeouts without deadlines.
+	snapshot.SetOperationDeadlines(kv.OperationDeadlines{})
+	_, err = snapshot.Get(context.Background(), []byte("od1"))
+	require.Nil(t, err)
+	require.Equal(t, uint64(client.ReadTimeoutShort.Milliseconds()), duration(tikvrpc.CmdGet))
+}
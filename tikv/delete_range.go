@@ -0,0 +1,247 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/txnkv/rangetask"
+	"go.uber.org/zap"
+)
+
+// deleteRangeTxnBatchSize is the max number of the keys deleted by a transaction of DeleteRangeTxn.
+const deleteRangeTxnBatchSize = 1024
+
+// DeleteRangeMode is how KVStore.DeleteRange deletes the keys.
+type DeleteRangeMode int
+
+const (
+	// DeleteRangeRaft deletes all versions of the keys immediately through Raft. The snapshots of any ts see nothing in
+	// the range afterward. It's the default.
+	DeleteRangeRaft DeleteRangeMode = iota
+	// DeleteRangeTxn deletes the keys by transactions, i.e. writes the tombstones of the keys and keeps the older
+	// versions until GC, so it's compatible with the snapshots older than the deletions and the GC safe point.
+	DeleteRangeTxn
+	// DeleteRangeUnsafeDestroy removes the keys from the disks of all stores directly, see KVStore.UnsafeDestroyRange.
+	DeleteRangeUnsafeDestroy
+)
+
+type deleteRangeOption struct {
+	mode           DeleteRangeMode
+	destroyAfterGC bool
+}
+
+// DeleteRangeOpt is the option of KVStore.DeleteRange.
+type DeleteRangeOpt func(*deleteRangeOption)
+
+// WithDeleteRangeMode sets how the keys are deleted.
+func WithDeleteRangeMode(mode DeleteRangeMode) DeleteRangeOpt {
+	return func(o *deleteRangeOption) {
+		o.mode = mode
+	}
+}
+
+// WithDestroyAfterGC registers the range deleted by DeleteRangeTxn to be destroyed by UnsafeDestroyRange once the GC
+// safe point passes the deletions, i.e. no snapshot can see the deleted keys anymore, to free the disk space of the
+// tombstones and the older versions quickly. The range must not be written afterward, e.g. the range of a dropped
+// table, since the later writes are destroyed too. The ranges are destroyed by KVStore.GC, or DestroyDeleteRanges for
+// the GC run by others, and kept by the DeleteRangeRegistry of the store until then.
+func WithDestroyAfterGC() DeleteRangeOpt {
+	return func(o *deleteRangeOption) {
+		o.destroyAfterGC = true
+	}
+}
+
+// PendingDeleteRange is a range waiting to be destroyed once the GC safe point reaches TS.
+type PendingDeleteRange struct {
+	StartKey []byte
+	EndKey   []byte
+	TS       uint64
+}
+
+// DeleteRangeRegistry keeps the ranges to destroy after GC, see WithDestroyAfterGC. It must be safe for concurrent use.
+type DeleteRangeRegistry interface {
+	// RegisterDeleteRange adds the range.
+	RegisterDeleteRange(ctx context.Context, r PendingDeleteRange) error
+	// PendingDeleteRanges returns the ranges not removed yet.
+	PendingDeleteRanges(ctx context.Context) ([]PendingDeleteRange, error)
+	// RemoveDeleteRange removes the range after it's destroyed.
+	RemoveDeleteRange(ctx context.Context, r PendingDeleteRange) error
+}
+
+// DeleteRange deletes the keys in the range [startKey, endKey) in the way of the mode set by WithDeleteRangeMode, and
+// returns the number of the regions processed, which is 0 for DeleteRangeUnsafeDestroy. By default, it deletes all
+// versions of the keys immediately, be careful while using it, since it doesn't keep recent MVCC versions, and frequent
+// invocation to it may cause performance problems to TiKV.
+func (s *KVStore) DeleteRange(
+	ctx context.Context, startKey []byte, endKey []byte, concurrency int, opts ...DeleteRangeOpt,
+) (completedRegions int, err error) {
+	var o deleteRangeOption
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.destroyAfterGC && o.mode != DeleteRangeTxn {
+		return 0, errors.New("destroying after GC requires DeleteRangeTxn")
+	}
+
+	switch o.mode {
+	case DeleteRangeRaft:
+		task := rangetask.NewDeleteRangeTask(s, startKey, endKey, concurrency)
+		if err = task.Execute(ctx); err != nil {
+			return 0, err
+		}
+		return task.CompletedRegions(), nil
+	case DeleteRangeUnsafeDestroy:
+		return 0, s.UnsafeDestroyRange(ctx, startKey, endKey)
+	case DeleteRangeTxn:
+	default:
+		return 0, errors.Errorf("unknown delete range mode %d", o.mode)
+	}
+
+	runner := rangetask.NewRangeTaskRunner("delete-range-txn", s, concurrency, s.deleteRangeTxn)
+	if err = runner.RunOnRange(ctx, startKey, endKey); err != nil {
+		return 0, err
+	}
+	if o.destroyAfterGC {
+		// The snapshots of the ts see all the deletions.
+		ts, err := s.CurrentTimestamp(oracle.GlobalTxnScope)
+		if err != nil {
+			return 0, err
+		}
+		r := PendingDeleteRange{StartKey: startKey, EndKey: endKey, TS: ts}
+		if err = s.deleteRanges.RegisterDeleteRange(ctx, r); err != nil {
+			return 0, err
+		}
+	}
+	return runner.CompletedRegions(), nil
+}
+
+// deleteRangeTxn deletes the keys of a region range by transactions.
+func (s *KVStore) deleteRangeTxn(ctx context.Context, r kv.KeyRange) (rangetask.TaskStat, error) {
+	startKey := r.StartKey
+	for retries := 0; ; {
+		txn, err := s.Begin()
+		if err != nil {
+			return rangetask.TaskStat{}, err
+		}
+		snapshot := txn.GetSnapshot()
+		snapshot.SetKeyOnly(true)
+		it, err := snapshot.IterWithLimit(startKey, r.EndKey, deleteRangeTxnBatchSize)
+		if err != nil {
+			_ = txn.Rollback()
+			return rangetask.TaskStat{}, err
+		}
+		var lastKey []byte
+		n := 0
+		for ; it.Valid(); n++ {
+			lastKey = it.Key()
+			if err = txn.Delete(lastKey); err != nil {
+				break
+			}
+			if err = it.Next(); err != nil {
+				break
+			}
+		}
+		it.Close()
+		if err != nil {
+			_ = txn.Rollback()
+			return rangetask.TaskStat{}, err
+		}
+		if n == 0 {
+			_ = txn.Rollback()
+			return rangetask.TaskStat{CompletedRegions: 1}, nil
+		}
+		if err = txn.Commit(ctx); err != nil {
+			if !tikverr.IsErrWriteConflict(err) || retries >= writeBatchMaxConflictRetries {
+				return rangetask.TaskStat{}, err
+			}
+			// Scan the batch again for the keys written concurrently.
+			retries++
+			continue
+		}
+		if n < deleteRangeTxnBatchSize {
+			return rangetask.TaskStat{CompletedRegions: 1}, nil
+		}
+		startKey, retries = kv.NextKey(lastKey), 0
+	}
+}
+
+// DestroyDeleteRanges destroys the ranges registered by WithDestroyAfterGC whose deletions are passed by the GC safe
+// point, and returns the number of the ranges destroyed. It's called by KVStore.GC, and should be called after updating
+// the GC safe point if the GC is run by others.
+func (s *KVStore) DestroyDeleteRanges(ctx context.Context, safePoint uint64) (destroyed int, err error) {
+	ranges, err := s.deleteRanges.PendingDeleteRanges(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range ranges {
+		if r.TS > safePoint {
+			continue
+		}
+		if err = s.UnsafeDestroyRange(ctx, r.StartKey, r.EndKey); err != nil {
+			return destroyed, err
+		}
+		if err = s.deleteRanges.RemoveDeleteRange(ctx, r); err != nil {
+			return destroyed, err
+		}
+		destroyed++
+		logutil.Logger(ctx).Info("destroyed deleted range after GC",
+			zap.Uint64("ts", r.TS),
+			zap.Uint64("safePoint", safePoint))
+	}
+	return destroyed, nil
+}
+
+// memDeleteRangeRegistry keeps the ranges in memory.
+type memDeleteRangeRegistry struct {
+	mu     sync.Mutex
+	ranges []PendingDeleteRange
+}
+
+func newMemDeleteRangeRegistry() *memDeleteRangeRegistry {
+	return &memDeleteRangeRegistry{}
+}
+
+func (r *memDeleteRangeRegistry) RegisterDeleteRange(_ context.Context, dr PendingDeleteRange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ranges = append(r.ranges, dr)
+	return nil
+}
+
+func (r *memDeleteRangeRegistry) PendingDeleteRanges(_ context.Context) ([]PendingDeleteRange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]PendingDeleteRange(nil), r.ranges...), nil
+}
+
+func (r *memDeleteRangeRegistry) RemoveDeleteRange(_ context.Context, dr PendingDeleteRange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, pending := range r.ranges {
+		if pending.TS == dr.TS && bytes.Equal(pending.StartKey, dr.StartKey) && bytes.Equal(pending.EndKey, dr.EndKey) {
+			r.ranges = append(r.ranges[:i], r.ranges[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/oracle"
+)
+
+func TestDeleteRangeTxn(t *testing.T) {
+	store, _, err := newMockStore(nil)
+	require.Nil(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	for i := 0; i < 5; i++ {
+		require.Nil(t, txn.Set([]byte(fmt.Sprintf("dr%d", i)), []byte("v")))
+	}
+	require.Nil(t, txn.Commit(ctx))
+	oldTS, err := store.CurrentTimestamp(oracle.GlobalTxnScope)
+	require.Nil(t, err)
+
+	_, err = store.DeleteRange(ctx, []byte("dr"), []byte("ds"), 1, WithDestroyAfterGC())
+	require.Error(t, err)
+	regions, err := store.DeleteRange(ctx, []byte("dr"), []byte("ds"), 1,
+		WithDeleteRangeMode(DeleteRangeTxn), WithDestroyAfterGC())
+	require.Nil(t, err)
+	require.Equal(t, 1, regions)
+
+	count := func(ts uint64) int {
+		it, err := store.GetSnapshot(ts).Iter([]byte("dr"), []byte("ds"))
+		require.Nil(t, err)
+		defer it.Close()
+		n := 0
+		for ; it.Valid(); n++ {
+			require.Nil(t, it.Next())
+		}
+		return n
+	}
+	// The deletions are tombstones, the older snapshots still see the keys.
+	require.Equal(t, 0, count(math.MaxUint64))
+	require.Equal(t, 5, count(oldTS))
+
+	pending, err := store.deleteRanges.PendingDeleteRanges(ctx)
+	require.Nil(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, []byte("dr"), pending[0].StartKey)
+	require.Greater(t, pending[0].TS, oldTS)
+	destroyed, err := store.DestroyDeleteRanges(ctx, pending[0].TS-1)
+	require.Nil(t, err)
+	require.Zero(t, destroyed)
+	destroyed, err = store.DestroyDeleteRanges(ctx, pending[0].TS)
+	require.Nil(t, err)
+	require.Equal(t, 1, destroyed)
+	require.Equal(t, 0, count(oldTS))
+	pending, err = store.deleteRanges.PendingDeleteRanges(ctx)
+	require.Nil(t, err)
+	require.Empty(t, pending)
+}
@@ -0,0 +1,57 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvents(t *testing.T) {
+	store, _, err := newMockStore(nil)
+	require.Nil(t, err)
+	defer store.Close()
+
+	events := store.Events()
+	expect := func(typ EventType) Event {
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case e := <-events:
+				if e.Type == typ {
+					return e
+				}
+			case <-timeout:
+				require.FailNow(t, "event not received", typ.String())
+			}
+		}
+	}
+
+	store.UpdateSPCache(100, time.Now())
+	e := expect(EventSafePointUpdated)
+	require.Equal(t, uint64(100), e.SafePoint)
+	require.False(t, e.Time.IsZero())
+
+	bo := NewNoopBackoff(context.Background())
+	loc, err := store.GetRegionCache().LocateKey(bo, []byte("k"))
+	require.Nil(t, err)
+	store.GetRegionCache().InvalidateCachedRegion(loc.Region)
+	e = expect(EventRegionInvalidated)
+	require.Equal(t, loc.Region.GetID(), e.RegionID)
+	require.Equal(t, "Other", e.Reason)
+}
@@ -0,0 +1,238 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config/retry"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/client"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"go.uber.org/zap"
+)
+
+// flashbackOneRegionMaxBackoff bounds the retries of each region in milliseconds.
+var flashbackOneRegionMaxBackoff = 100000
+
+// FlashbackPhase is the phase of a flashback.
+type FlashbackPhase int
+
+const (
+	// FlashbackPreparing prepares the regions for the flashback. The prepared
+	// regions reject all the other reads and writes until the flashback ends.
+	FlashbackPreparing FlashbackPhase = iota
+	// FlashbackApplying writes the old versions back as the latest versions
+	// and ends the flashback region by region.
+	FlashbackApplying
+	// FlashbackFinished means the flashback is done.
+	FlashbackFinished
+)
+
+func (p FlashbackPhase) String() string {
+	switch p {
+	case FlashbackPreparing:
+		return "preparing"
+	case FlashbackApplying:
+		return "applying"
+	case FlashbackFinished:
+		return "finished"
+	}
+	return "unknown"
+}
+
+// FlashbackCheckpoint is the progress of a flashback, from which a failed or
+// interrupted flashback can be resumed by WithFlashbackCheckpoint.
+type FlashbackCheckpoint struct {
+	// StartTS is the timestamp the regions are prepared with.
+	StartTS uint64
+	// CommitTS is the timestamp of the versions written back, which is
+	// allocated when the preparing phase is done.
+	CommitTS uint64
+	Phase    FlashbackPhase
+	// NextKey is the key the phase continues from.
+	NextKey []byte
+	// CompletedRegions is the number of the region requests done in both
+	// phases.
+	CompletedRegions int
+}
+
+type flashbackOption struct {
+	progress   func(FlashbackCheckpoint)
+	checkpoint *FlashbackCheckpoint
+}
+
+// FlashbackOpt is the option of FlashbackRange.
+type FlashbackOpt func(*flashbackOption)
+
+// WithFlashbackProgress sets the function called with the checkpoint every
+// time a region is done or the phase changes. Persist the checkpoint to resume
+// the flashback after the process crashes.
+func WithFlashbackProgress(fn func(FlashbackCheckpoint)) FlashbackOpt {
+	return func(opt *flashbackOption) {
+		opt.progress = fn
+	}
+}
+
+// WithFlashbackCheckpoint resumes the flashback from a checkpoint reported by
+// WithFlashbackProgress. The range and the flashback ts must be the same as the
+// ones of the interrupted flashback.
+func WithFlashbackCheckpoint(cp FlashbackCheckpoint) FlashbackOpt {
+	return func(opt *flashbackOption) {
+		opt.checkpoint = &cp
+	}
+}
+
+// FlashbackRange flashes the data in [startKey, endKey) back to flashbackTS by
+// the two-phase flashback protocol of TiKV. It first prepares all the regions
+// in the range, which stops them serving the other reads and writes, then
+// writes the versions at flashbackTS back as the latest versions region by
+// region, which also ends the flashback of the region. The workload on the
+// range should be stopped before, and the regions stay unavailable if it
+// fails, until the flashback is resumed by WithFlashbackCheckpoint.
+func (s *KVStore) FlashbackRange(ctx context.Context, startKey, endKey []byte, flashbackTS uint64, opts ...FlashbackOpt) error {
+	opt := &flashbackOption{}
+	for _, o := range opts {
+		o(opt)
+	}
+	if err := s.CheckVisibility(flashbackTS); err != nil {
+		return err
+	}
+	var cp FlashbackCheckpoint
+	if opt.checkpoint != nil {
+		cp = *opt.checkpoint
+	} else {
+		startTS, err := s.CurrentTimestamp(oracle.GlobalTxnScope)
+		if err != nil {
+			return err
+		}
+		cp = FlashbackCheckpoint{StartTS: startTS, Phase: FlashbackPreparing, NextKey: startKey}
+	}
+	if flashbackTS >= cp.StartTS {
+		return errors.Errorf("flashback ts %d is not less than the start ts %d", flashbackTS, cp.StartTS)
+	}
+	report := func() {
+		if opt.progress != nil {
+			opt.progress(cp)
+		}
+	}
+
+	if cp.Phase == FlashbackPreparing {
+		if err := s.flashbackRegions(ctx, &cp, endKey, flashbackTS, report); err != nil {
+			return err
+		}
+		commitTS, err := s.CurrentTimestamp(oracle.GlobalTxnScope)
+		if err != nil {
+			return err
+		}
+		cp.Phase, cp.CommitTS, cp.NextKey = FlashbackApplying, commitTS, startKey
+		report()
+	}
+	if cp.Phase == FlashbackApplying {
+		if err := s.flashbackRegions(ctx, &cp, endKey, flashbackTS, report); err != nil {
+			return err
+		}
+		cp.Phase, cp.NextKey = FlashbackFinished, endKey
+		report()
+		logutil.Logger(ctx).Info("flashback range finished",
+			zap.Uint64("flashbackTS", flashbackTS),
+			zap.Uint64("startTS", cp.StartTS),
+			zap.Uint64("commitTS", cp.CommitTS),
+			zap.Int("completedRegions", cp.CompletedRegions))
+	}
+	return nil
+}
+
+// flashbackRegions sends the requests of the phase of cp to the regions in
+// [cp.NextKey, endKey) one by one, and advances cp after each region.
+func (s *KVStore) flashbackRegions(ctx context.Context, cp *FlashbackCheckpoint, endKey []byte, flashbackTS uint64, report func()) error {
+	// The backoffer is shared by the retries of a region, and renewed for the
+	// next region.
+	bo := retry.NewBackofferWithVars(ctx, flashbackOneRegionMaxBackoff, nil)
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		default:
+		}
+
+		loc, err := s.regionCache.LocateKey(bo, cp.NextKey)
+		if err != nil {
+			return err
+		}
+		regionEndKey := loc.EndKey
+		isLast := len(regionEndKey) == 0 || (len(endKey) > 0 && bytes.Compare(regionEndKey, endKey) >= 0)
+		if isLast {
+			regionEndKey = endKey
+		}
+
+		var req *tikvrpc.Request
+		if cp.Phase == FlashbackPreparing {
+			req = tikvrpc.NewRequest(tikvrpc.CmdPrepareFlashbackToVersion, &kvrpcpb.PrepareFlashbackToVersionRequest{
+				StartKey: cp.NextKey,
+				EndKey:   regionEndKey,
+				StartTs:  cp.StartTS,
+				Version:  flashbackTS,
+			})
+		} else {
+			req = tikvrpc.NewRequest(tikvrpc.CmdFlashbackToVersion, &kvrpcpb.FlashbackToVersionRequest{
+				Version:  flashbackTS,
+				StartKey: cp.NextKey,
+				EndKey:   regionEndKey,
+				StartTs:  cp.StartTS,
+				CommitTs: cp.CommitTS,
+			})
+		}
+		resp, err := s.SendReq(bo, req, loc.Region, client.ReadTimeoutMedium)
+		if err != nil {
+			return err
+		}
+		regionErr, err := resp.GetRegionError()
+		if err != nil {
+			return err
+		}
+		if regionErr != nil {
+			if err = bo.Backoff(retry.BoRegionMiss, errors.New(regionErr.String())); err != nil {
+				return err
+			}
+			continue
+		}
+		if resp.Resp == nil {
+			return errors.WithStack(tikverr.ErrBodyMissing)
+		}
+		var respErr string
+		if cp.Phase == FlashbackPreparing {
+			respErr = resp.Resp.(*kvrpcpb.PrepareFlashbackToVersionResponse).GetError()
+		} else {
+			respErr = resp.Resp.(*kvrpcpb.FlashbackToVersionResponse).GetError()
+		}
+		if respErr != "" {
+			return errors.Errorf("unexpected flashback err in %s phase of region %d: %s", cp.Phase, loc.Region.GetID(), respErr)
+		}
+
+		cp.CompletedRegions++
+		if isLast {
+			return nil
+		}
+		cp.NextKey = regionEndKey
+		report()
+		bo = retry.NewBackofferWithVars(ctx, flashbackOneRegionMaxBackoff, nil)
+	}
+}
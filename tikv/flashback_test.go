@@ -0,0 +1,145 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/errorpb"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/config/retry"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/tikvrpc/interceptor"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+)
+
+func TestFlashbackRange(t *testing.T) {
+	store, cluster, err := newMockStore(nil)
+	require.Nil(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	write := func(mutate func(txn *transaction.KVTxn)) {
+		txn, err := store.Begin()
+		require.Nil(t, err)
+		mutate(txn)
+		require.Nil(t, txn.Commit(ctx))
+	}
+	write(func(txn *transaction.KVTxn) {
+		require.Nil(t, txn.Set([]byte("k1"), []byte("v1")))
+		require.Nil(t, txn.Set([]byte("k2"), []byte("v2")))
+	})
+	flashbackTS, err := store.CurrentTimestamp(oracle.GlobalTxnScope)
+	require.Nil(t, err)
+	write(func(txn *transaction.KVTxn) {
+		require.Nil(t, txn.Set([]byte("k1"), []byte("v1-new")))
+		require.Nil(t, txn.Delete([]byte("k2")))
+		require.Nil(t, txn.Set([]byte("k3"), []byte("v3")))
+	})
+	_, err = store.SplitRegions(ctx, [][]byte{[]byte("k2")}, false, nil)
+	require.Nil(t, err)
+
+	// Interrupt the flashback after the first region is prepared.
+	var checkpoints []FlashbackCheckpoint
+	interruptCtx, cancel := context.WithCancel(ctx)
+	err = store.FlashbackRange(interruptCtx, []byte("k"), []byte("l"), flashbackTS, WithFlashbackProgress(func(cp FlashbackCheckpoint) {
+		checkpoints = append(checkpoints, cp)
+		cancel()
+	}))
+	require.ErrorIs(t, err, context.Canceled)
+	require.Len(t, checkpoints, 1)
+	require.Equal(t, FlashbackPreparing, checkpoints[0].Phase)
+	require.Equal(t, []byte("k2"), checkpoints[0].NextKey)
+	loc, err := store.GetRegionCache().LocateKey(retry.NewNoopBackoff(ctx), []byte("k1"))
+	require.Nil(t, err)
+	startTS, ok := cluster.RegionInFlashback(loc.Region.GetID())
+	require.True(t, ok)
+	require.Equal(t, checkpoints[0].StartTS, startTS)
+	_, err = store.GetSnapshot(math.MaxUint64).Get(ctx, []byte("k1"))
+	require.ErrorContains(t, err, "flashback progress")
+
+	// Resume the flashback from the checkpoint.
+	resumed := checkpoints[0]
+	checkpoints = nil
+	err = store.FlashbackRange(ctx, []byte("k"), []byte("l"), flashbackTS,
+		WithFlashbackCheckpoint(resumed),
+		WithFlashbackProgress(func(cp FlashbackCheckpoint) { checkpoints = append(checkpoints, cp) }))
+	require.Nil(t, err)
+	phases := make([]FlashbackPhase, 0, len(checkpoints))
+	for _, cp := range checkpoints {
+		phases = append(phases, cp.Phase)
+	}
+	require.Equal(t, []FlashbackPhase{FlashbackApplying, FlashbackApplying, FlashbackFinished}, phases)
+	last := checkpoints[len(checkpoints)-1]
+	require.Equal(t, resumed.StartTS, last.StartTS)
+	require.Greater(t, last.CommitTS, last.StartTS)
+	require.Equal(t, 4, last.CompletedRegions)
+	_, ok = cluster.RegionInFlashback(loc.Region.GetID())
+	require.False(t, ok)
+
+	snapshot := store.GetSnapshot(math.MaxUint64)
+	for k, v := range map[string]string{"k1": "v1", "k2": "v2"} {
+		val, err := snapshot.Get(ctx, []byte(k))
+		require.Nil(t, err)
+		require.Equal(t, []byte(v), val)
+	}
+	_, err = snapshot.Get(ctx, []byte("k3"))
+	require.True(t, tikverr.IsErrNotFound(err))
+
+	require.ErrorContains(t, store.FlashbackRange(ctx, []byte("k"), []byte("l"), math.MaxUint64), "not less than the start ts")
+}
+
+func TestFlashbackRangeRetryLimit(t *testing.T) {
+	store, _, err := newMockStore(nil)
+	require.Nil(t, err)
+	defer store.Close()
+	defer func(backoff int) { flashbackOneRegionMaxBackoff = backoff }(flashbackOneRegionMaxBackoff)
+	flashbackOneRegionMaxBackoff = 100
+
+	// The region keeps responding a region error retried by the flashback.
+	var sent atomic.Int64
+	ctx := interceptor.WithRPCInterceptor(context.Background(), interceptor.NewRPCInterceptor("region-not-found",
+		func(next interceptor.RPCInterceptorFunc) interceptor.RPCInterceptorFunc {
+			return func(target string, req *tikvrpc.Request) (*tikvrpc.Response, error) {
+				if req.Type != tikvrpc.CmdPrepareFlashbackToVersion {
+					return next(target, req)
+				}
+				sent.Add(1)
+				return &tikvrpc.Response{Resp: &kvrpcpb.PrepareFlashbackToVersionResponse{
+					RegionError: &errorpb.Error{RegionNotFound: &errorpb.RegionNotFound{RegionId: req.RegionId}},
+				}}, nil
+			}
+		}))
+	flashbackTS, err := store.CurrentTimestamp(oracle.GlobalTxnScope)
+	require.Nil(t, err)
+	done := make(chan error, 1)
+	go func() {
+		done <- store.FlashbackRange(ctx, []byte("k"), []byte("l"), flashbackTS)
+	}()
+	select {
+	case err = <-done:
+		require.Error(t, err)
+		require.Greater(t, sent.Load(), int64(1))
+	case <-time.After(10 * time.Second):
+		require.FailNow(t, "the flashback retries the region forever")
+	}
+}
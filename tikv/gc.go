@@ -51,9 +51,9 @@ const GCScanLockLimit = txnlock.ResolvedCacheSize / 2
 // GC is performed by:
 // 1. resolving all locks with timestamp <= `safepoint`
 // 2. updating PD's known safepoint
+// 3. destroying the ranges registered by DeleteRange with WithDestroyAfterGC, see DestroyDeleteRanges
 //
 // GC is a simplified version of [GC in TiDB](https://docs.pingcap.com/tidb/stable/garbage-collection-overview).
-// We skip the second step "delete ranges" which is an optimization for TiDB.
 func (s *KVStore) GC(ctx context.Context, safepoint uint64, opts ...GCOpt) (newSafePoint uint64, err error) {
 	// default concurrency 8
 	opt := &gcOption{concurrency: 8}
@@ -67,7 +67,15 @@ func (s *KVStore) GC(ctx context.Context, safepoint uint64, opts ...GCOpt) (newS
 		return
 	}
 
-	return s.pdClient.UpdateGCSafePoint(ctx, safepoint)
+	newSafePoint, err = s.pdClient.UpdateGCSafePoint(ctx, safepoint)
+	if err != nil {
+		return
+	}
+	// The ranges failing to be destroyed are retried by the next GC.
+	if _, err1 := s.DestroyDeleteRanges(ctx, newSafePoint); err1 != nil {
+		logutil.Logger(ctx).Warn("failed to destroy deleted ranges after GC", zap.Error(err1))
+	}
+	return newSafePoint, nil
 }
 
 type gcOption struct {
@@ -0,0 +1,79 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"time"
+
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"go.uber.org/zap"
+)
+
+const (
+	// hotRegionSplitQueueSize is the number of the hot regions waiting to be
+	// split, the others are dropped.
+	hotRegionSplitQueueSize = 16
+	// hotRegionSplitCooldown is the interval a region isn't split again, which
+	// gives PD time to update the region.
+	hotRegionSplitCooldown = time.Minute
+)
+
+// startHotRegionSplitter starts the goroutine splitting the hot regions one by
+// one, and returns the function queueing a hot region to split.
+func (s *KVStore) startHotRegionSplitter(scatter bool) func(HotRegion) {
+	ch := make(chan HotRegion, hotRegionSplitQueueSize)
+	s.wg.Add(1)
+	go s.splitHotRegions(ch, scatter)
+	return func(r HotRegion) {
+		if len(r.SplitKey) == 0 {
+			return
+		}
+		select {
+		case ch <- r:
+		default:
+		}
+	}
+}
+
+func (s *KVStore) splitHotRegions(ch <-chan HotRegion, scatter bool) {
+	defer s.wg.Done()
+	lastSplit := make(map[uint64]time.Time)
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case r := <-ch:
+			now := time.Now()
+			if t, ok := lastSplit[r.RegionID]; ok && now.Sub(t) < hotRegionSplitCooldown {
+				continue
+			}
+			for id, t := range lastSplit {
+				if now.Sub(t) >= hotRegionSplitCooldown {
+					delete(lastSplit, id)
+				}
+			}
+			lastSplit[r.RegionID] = now
+			regionIDs, err := s.SplitRegions(s.ctx, [][]byte{r.SplitKey}, scatter, nil)
+			if err != nil {
+				logutil.BgLogger().Warn("split hot region failed",
+					zap.Uint64("regionID", r.RegionID), zap.Float64("qps", r.QPS), zap.Float64("bytesRate", r.BytesRate), zap.Error(err))
+				continue
+			}
+			logutil.BgLogger().Info("split hot region",
+				zap.Uint64("regionID", r.RegionID), zap.Float64("qps", r.QPS), zap.Float64("bytesRate", r.BytesRate),
+				zap.Uint64s("newRegionIDs", regionIDs))
+		}
+	}
+}
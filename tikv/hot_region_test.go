@@ -0,0 +1,57 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/config/retry"
+	tikverr "github.com/tikv/client-go/v2/error"
+)
+
+func TestHotRegionAutoSplit(t *testing.T) {
+	store, cluster, err := newMockStore(nil)
+	require.Nil(t, err)
+	defer store.Close()
+
+	hot := make(chan HotRegion, 1)
+	WithHotRegionDetection(HotRegionConfig{QPSThreshold: 10, Window: time.Minute, AutoSplit: true}, func(r HotRegion) {
+		hot <- r
+	})(store)
+
+	ctx := context.Background()
+	bo := retry.NewBackofferWithVars(ctx, 10000, nil)
+	loc, err := store.GetRegionCache().LocateKey(bo, []byte("k"))
+	require.Nil(t, err)
+	regions := len(cluster.GetAllRegions())
+	snapshot := store.GetSnapshot(math.MaxUint64)
+	for i := 0; i < 600; i++ {
+		_, err = snapshot.Get(ctx, []byte(fmt.Sprintf("k%03d", i)))
+		require.ErrorIs(t, err, tikverr.ErrNotExist)
+	}
+	r := <-hot
+	require.Equal(t, loc.Region.GetID(), r.RegionID)
+	require.NotEmpty(t, r.SplitKey)
+	require.NotEmpty(t, store.GetHotRegions())
+
+	require.Eventually(t, func() bool {
+		return len(cluster.GetAllRegions()) == regions+1
+	}, 5*time.Second, 10*time.Millisecond)
+}
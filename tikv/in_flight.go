@@ -0,0 +1,157 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/oracle"
+	"go.uber.org/zap"
+)
+
+// inFlightTxns counts the transactions begun by the store and not committed or
+// rolled back yet by their start ts. The entries older than maxAge are evicted,
+// so the transactions leaked without being closed don't hold the minimum back
+// forever.
+type inFlightTxns struct {
+	sync.Mutex
+	maxAge    time.Duration
+	startTSs  map[uint64]*inFlightTxn
+	lastEvict time.Time
+}
+
+type inFlightTxn struct {
+	count int
+	begin time.Time
+}
+
+func newInFlightTxns(maxAge time.Duration) *inFlightTxns {
+	return &inFlightTxns{
+		maxAge:    maxAge,
+		startTSs:  make(map[uint64]*inFlightTxn),
+		lastEvict: time.Now(),
+	}
+}
+
+func (t *inFlightTxns) add(startTS uint64) {
+	t.Lock()
+	defer t.Unlock()
+	now := time.Now()
+	// Evict at most once per maxAge, so the map holds the entries of at most
+	// 2*maxAge without scanning it on every transaction.
+	if now.Sub(t.lastEvict) >= t.maxAge {
+		t.evictLocked(now)
+	}
+	if e, ok := t.startTSs[startTS]; ok {
+		e.count++
+		return
+	}
+	t.startTSs[startTS] = &inFlightTxn{count: 1, begin: now}
+}
+
+func (t *inFlightTxns) remove(startTS uint64) {
+	t.Lock()
+	defer t.Unlock()
+	// The entry is missing if it's evicted.
+	if e, ok := t.startTSs[startTS]; ok {
+		if e.count--; e.count <= 0 {
+			delete(t.startTSs, startTS)
+		}
+	}
+}
+
+func (t *inFlightTxns) min() uint64 {
+	t.Lock()
+	defer t.Unlock()
+	t.evictLocked(time.Now())
+	var minTS uint64
+	for ts := range t.startTSs {
+		if minTS == 0 || ts < minTS {
+			minTS = ts
+		}
+	}
+	return minTS
+}
+
+func (t *inFlightTxns) evictLocked(now time.Time) {
+	t.lastEvict = now
+	for ts, e := range t.startTSs {
+		if age := now.Sub(e.begin); age > t.maxAge {
+			logutil.BgLogger().Warn("evict the in-flight transaction not closed in time",
+				zap.Uint64("startTS", ts), zap.Int("count", e.count), zap.Duration("age", age))
+			delete(t.startTSs, ts)
+		}
+	}
+}
+
+// WithInFlightTxnTracking makes the store track the start ts of the transactions begun by it and not committed or
+// rolled back yet, see MinInFlightStartTS. Every transaction must be closed by Commit or Rollback, including the
+// read-only ones and the ones failed on errors, or it's tracked until it's evicted after maxAge. A non-positive maxAge
+// uses the max TTL of the transactions in the config, after which the locks of the transactions are resolved anyway.
+func WithInFlightTxnTracking(maxAge time.Duration) Option {
+	return func(o *KVStore) {
+		if maxAge <= 0 {
+			maxAge = time.Duration(config.GetGlobalConfig().MaxTxnTTL) * time.Millisecond
+		}
+		o.inFlightTxns = newInFlightTxns(maxAge)
+	}
+}
+
+// MinInFlightStartTS returns the minimum start ts of the transactions begun by
+// the store and not committed or rolled back yet, or 0 if there is none or the
+// store isn't created with WithInFlightTxnTracking. The transactions not
+// finished yet are committed at the timestamps greater than it, so the
+// consumers of the changes, e.g. changefeeds, can bound their resolved ts by
+// it.
+func (s *KVStore) MinInFlightStartTS() uint64 {
+	if s.inFlightTxns == nil {
+		return 0
+	}
+	return s.inFlightTxns.min()
+}
+
+// UpdateInFlightServiceSafePoint pushes the minimum start ts of the in-flight
+// transactions, or the current timestamp if there is none, to PD as the service
+// safe point of serviceID with the ttl, so the data they read is not garbage
+// collected. It returns the safe point pushed. The caller should update it
+// periodically within the ttl, and a zero ttl removes the safe point. The store
+// must be created with WithInFlightTxnTracking.
+func (s *KVStore) UpdateInFlightServiceSafePoint(ctx context.Context, serviceID string, ttl time.Duration) (uint64, error) {
+	if s.inFlightTxns == nil {
+		return 0, errors.New("in-flight transactions are not tracked, see WithInFlightTxnTracking")
+	}
+	// The current timestamp is fetched first, so the transactions begun after
+	// it have greater start ts.
+	safePoint, err := s.CurrentTimestamp(oracle.GlobalTxnScope)
+	if err != nil {
+		return 0, err
+	}
+	if minTS := s.MinInFlightStartTS(); minTS != 0 && minTS < safePoint {
+		safePoint = minTS
+	}
+	minSafePoint, err := s.pdClient.UpdateServiceGCSafePoint(ctx, serviceID, int64(ttl/time.Second), safePoint)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if ttl > 0 && minSafePoint > safePoint {
+		return 0, errors.Errorf("in-flight service safe point %d is behind the GC safe point %d", safePoint, minSafePoint)
+	}
+	return safePoint, nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinInFlightStartTS(t *testing.T) {
+	store, _, err := newMockStore(nil, WithInFlightTxnTracking(time.Hour))
+	require.Nil(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	require.Zero(t, store.MinInFlightStartTS())
+	txn1, err := store.Begin()
+	require.Nil(t, err)
+	txn2, err := store.Begin()
+	require.Nil(t, err)
+	require.Equal(t, txn1.StartTS(), store.MinInFlightStartTS())
+
+	// The callbacks added by the caller don't replace the tracking.
+	closed := false
+	txn1.AddCloseCallback(func() { closed = true })
+	require.Nil(t, txn1.Set([]byte("k"), []byte("v")))
+	require.Nil(t, txn1.Commit(ctx))
+	require.True(t, closed)
+	require.Equal(t, txn2.StartTS(), store.MinInFlightStartTS())
+	safePoint, err := store.UpdateInFlightServiceSafePoint(ctx, "in-flight", time.Minute)
+	require.Nil(t, err)
+	require.Equal(t, txn2.StartTS(), safePoint)
+
+	// The current timestamp is pushed if no transaction is in flight.
+	require.Nil(t, txn2.Rollback())
+	require.Zero(t, store.MinInFlightStartTS())
+	safePoint, err = store.UpdateInFlightServiceSafePoint(ctx, "in-flight", time.Minute)
+	require.Nil(t, err)
+	require.Greater(t, safePoint, txn2.StartTS())
+	_, err = store.UpdateInFlightServiceSafePoint(ctx, "in-flight", 0)
+	require.Nil(t, err)
+}
+
+func TestInFlightTxnsNotTracked(t *testing.T) {
+	store, _, err := newMockStore(nil)
+	require.Nil(t, err)
+	defer store.Close()
+
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	require.Zero(t, store.MinInFlightStartTS())
+	_, err = store.UpdateInFlightServiceSafePoint(context.Background(), "in-flight", time.Minute)
+	require.Error(t, err)
+	require.Nil(t, txn.Rollback())
+}
+
+func TestInFlightTxnsEvict(t *testing.T) {
+	txns := newInFlightTxns(time.Minute)
+	txns.add(10)
+	txns.add(10)
+	txns.add(20)
+	require.Equal(t, uint64(10), txns.min())
+
+	// The leaked transactions are evicted after maxAge.
+	txns.startTSs[10].begin = time.Now().Add(-2 * time.Minute)
+	require.Equal(t, uint64(20), txns.min())
+	txns.remove(10)
+	txns.remove(10)
+	require.Len(t, txns.startTSs, 1)
+
+	// Adding the transactions evicts the entries once per maxAge.
+	txns.startTSs[20].begin = time.Now().Add(-2 * time.Minute)
+	txns.add(30)
+	require.Len(t, txns.startTSs, 2)
+	txns.lastEvict = time.Now().Add(-time.Minute)
+	txns.add(40)
+	require.Len(t, txns.startTSs, 2)
+	require.Equal(t, uint64(30), txns.min())
+}
@@ -0,0 +1,186 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/internal/apicodec"
+	"github.com/tikv/client-go/v2/internal/client"
+	"github.com/tikv/client-go/v2/internal/locate"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/txnkv/txnlock"
+	"go.uber.org/zap"
+)
+
+// WithKeyspace returns the store of the keyspace with the given name, which
+// shares the oracle, the PD client, the safe point kv and the connections to
+// TiKV with s, so that a multi-tenant service doesn't need a full client per
+// keyspace. s must be created in API v2.
+//
+// The keyspace store also shares the goroutine pool, the safe point and the
+// safe ts of s, which are updated by the background loops of s, and the
+// tracker of the in-flight transactions. It inherits the options of s, i.e.
+// the operation deadlines, the archive reader, the workload routing, the scan
+// options, the metrics registerer and the settings of the requests sent
+// through the region cache, e.g. the slow log and the write pacing. The PD
+// circuit breaker is process-wide, so it applies to the keyspace stores too.
+//
+// The keyspace store has its own region cache and lock resolver, because the
+// keys in them are relative to the keyspace. For the same reason, the hot
+// region detection, the region map fallback and the delete range registry are
+// not inherited, and the events of the keyspace store are only published to
+// its own subscribers, see Events. The txn local latches are not inherited
+// either, see EnableTxnLocalLatches. The stores of the same name are reused,
+// and all of them are closed when s is closed. Closing a keyspace store only
+// releases the resources of its own.
+func (s *KVStore) WithKeyspace(keyspaceName string) (*KVStore, error) {
+	if s.parent != nil {
+		return s.parent.WithKeyspace(keyspaceName)
+	}
+	s.keyspaces.Lock()
+	defer s.keyspaces.Unlock()
+	if store, ok := s.keyspaces.stores[keyspaceName]; ok {
+		return store, nil
+	}
+	if s.close.Load() {
+		return nil, errors.New("the store is closed")
+	}
+
+	codecPDClient, ok := s.pdClient.(*locate.CodecPDClient)
+	if !ok || codecPDClient.GetCodec().GetAPIVersion() != kvrpcpb.APIVersion_V2 {
+		return nil, errors.New("keyspace stores can only be created from a store in API v2")
+	}
+	pdClient, err := locate.NewCodecPDClientWithKeyspace(ModeTxn, codecPDClient.Client, keyspaceName)
+	if err != nil {
+		return nil, err
+	}
+	tikvClient, err := s.keyspaceClient(pdClient.GetCodec())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	regionCache := locate.NewRegionCache(pdClient, locate.WithRequestHealthFeedbackCallback(func(ctx context.Context, addr string) error {
+		return requestHealthFeedbackFromKVClient(ctx, addr, tikvClient)
+	}))
+	regionCache.InheritSettings(s.regionCache)
+	s.spMutex.RLock()
+	safePoint, spTime := s.safePoint, s.spTime
+	s.spMutex.RUnlock()
+	store := &KVStore{
+		clusterID:         s.clusterID,
+		uuid:              fmt.Sprintf("%s-%s", s.uuid, keyspaceName),
+		oracle:            s.oracle,
+		pdClient:          pdClient,
+		pdHttpClient:      s.pdHttpClient,
+		rpcClient:         tikvClient,
+		parent:            s,
+		keyspaceName:      keyspaceName,
+		metricsRegisterer: s.metricsRegisterer,
+		storeMetrics:      s.storeMetrics,
+		archiveReader:     s.archiveReader,
+		workloadRouting:   s.workloadRouting,
+		adaptiveScanBatch: s.adaptiveScanBatch,
+		scanPrefetch:      s.scanPrefetch,
+		deadlines:         s.deadlines,
+		inFlightTxns:      s.inFlightTxns,
+		regionCache:       regionCache,
+		kv:                s.kv,
+		safePoint:         safePoint,
+		spTime:            spTime,
+		replicaReadSeed:   rand.Uint32(),
+		deleteRanges:      newMemDeleteRangeRegistry(),
+		mock:              s.mock,
+		ctx:               ctx,
+		cancel:            cancel,
+		gP:                s.gP,
+	}
+	store.clientMu.client = client.NewReqCollapse(client.NewInterceptedClient(store.metricsClient(tikvClient)))
+	store.lockResolver = txnlock.NewLockResolver(store)
+
+	if s.keyspaces.stores == nil {
+		s.keyspaces.stores = make(map[string]*KVStore)
+	}
+	s.keyspaces.stores[keyspaceName] = store
+	return store, nil
+}
+
+// keyspaceClient returns a client sending requests by the connections of s
+// with the codec of another keyspace.
+func (s *KVStore) keyspaceClient(codec apicodec.Codec) (Client, error) {
+	switch c := s.rpcClient.(type) {
+	case *client.RPCClient:
+		return c.WithCodec(codec), nil
+	case *CodecClient:
+		return &CodecClient{Client: c.Client, codec: codec}, nil
+	}
+	return nil, errors.Errorf("keyspace stores are not supported by client %T", s.rpcClient)
+}
+
+// root returns the store running the background loops shared by s, i.e. the
+// parent of a keyspace store or s itself.
+func (s *KVStore) root() *KVStore {
+	if s.parent != nil {
+		return s.parent
+	}
+	return s
+}
+
+// keyspaceStores returns the keyspace stores created from s.
+func (s *KVStore) keyspaceStores() []*KVStore {
+	s.keyspaces.Lock()
+	defer s.keyspaces.Unlock()
+	stores := make([]*KVStore, 0, len(s.keyspaces.stores))
+	for _, store := range s.keyspaces.stores {
+		stores = append(stores, store)
+	}
+	return stores
+}
+
+// closeKeyspaceStores closes the keyspace stores created from s.
+func (s *KVStore) closeKeyspaceStores() {
+	s.keyspaces.Lock()
+	stores := s.keyspaces.stores
+	s.keyspaces.stores = nil
+	s.keyspaces.Unlock()
+	for _, store := range stores {
+		if err := store.Close(); err != nil {
+			logutil.BgLogger().Warn("failed to close keyspace store",
+				zap.String("keyspace", store.keyspaceName), zap.Error(err))
+		}
+	}
+}
+
+// closeKeyspaceStore releases the resources owned by the keyspace store s.
+// The shared ones are closed by its parent.
+func (s *KVStore) closeKeyspaceStore() error {
+	s.parent.keyspaces.Lock()
+	if s.parent.keyspaces.stores[s.keyspaceName] == s {
+		delete(s.parent.keyspaces.stores, s.keyspaceName)
+	}
+	s.parent.keyspaces.Unlock()
+
+	s.lockResolver.Close()
+	if s.txnLatches != nil {
+		s.txnLatches.Close()
+	}
+	s.regionCache.Close()
+	return nil
+}
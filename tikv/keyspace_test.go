@@ -0,0 +1,152 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/keyspacepb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/mockstore/mocktikv"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/testutils"
+	pd "github.com/tikv/pd/client"
+)
+
+type keyspacePDClient struct {
+	pd.Client
+	keyspaces map[string]uint32
+}
+
+func (c *keyspacePDClient) LoadKeyspace(ctx context.Context, name string) (*keyspacepb.KeyspaceMeta, error) {
+	id, ok := c.keyspaces[name]
+	if !ok {
+		return nil, errors.Errorf("keyspace %s not found", name)
+	}
+	return &keyspacepb.KeyspaceMeta{Id: id, Name: name, State: keyspacepb.KeyspaceState_ENABLED}, nil
+}
+
+func TestWithKeyspace(t *testing.T) {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	require.NoError(t, err)
+	mocktikv.BootstrapWithSingleStore(cluster)
+	pdClient = &keyspacePDClient{Client: pdClient, keyspaces: map[string]uint32{"ks1": 1, "ks2": 2}}
+	store, err := NewTestKeyspaceTiKVStore(client, pdClient, nil, nil, 0, keyspacepb.KeyspaceMeta{Id: 1, Name: "ks1"})
+	require.NoError(t, err)
+	defer store.Close()
+
+	ks2, err := store.WithKeyspace("ks2")
+	require.NoError(t, err)
+	same, err := ks2.WithKeyspace("ks2")
+	require.NoError(t, err)
+	require.Same(t, ks2, same)
+	require.Same(t, store.GetOracle(), ks2.GetOracle())
+	_, err = store.WithKeyspace("ks3")
+	require.Error(t, err)
+
+	ctx := context.Background()
+	put := func(s *KVStore, key, value string) {
+		txn, err := s.Begin()
+		require.NoError(t, err)
+		require.NoError(t, txn.Set([]byte(key), []byte(value)))
+		require.NoError(t, txn.Commit(ctx))
+	}
+	get := func(s *KVStore, key string) (string, error) {
+		txn, err := s.Begin()
+		require.NoError(t, err)
+		value, err := txn.Get(ctx, []byte(key))
+		return string(value), err
+	}
+	put(store, "k1", "v1")
+	put(ks2, "k1", "v2")
+	put(ks2, "k2", "v2")
+	value, err := get(store, "k1")
+	require.NoError(t, err)
+	require.Equal(t, "v1", value)
+	value, err = get(ks2, "k1")
+	require.NoError(t, err)
+	require.Equal(t, "v2", value)
+	_, err = get(store, "k2")
+	require.True(t, tikverr.IsErrNotFound(err))
+
+	// A closed keyspace store is recreated.
+	require.NoError(t, ks2.Close())
+	ks2Again, err := store.WithKeyspace("ks2")
+	require.NoError(t, err)
+	require.NotSame(t, ks2, ks2Again)
+	value, err = get(ks2Again, "k2")
+	require.NoError(t, err)
+	require.Equal(t, "v2", value)
+}
+
+func TestWithKeyspaceAPIV1(t *testing.T) {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	require.NoError(t, err)
+	mocktikv.BootstrapWithSingleStore(cluster)
+	store, err := NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	require.NoError(t, err)
+	defer store.Close()
+
+	_, err = store.WithKeyspace("ks1")
+	require.Error(t, err)
+}
+
+type mapArchiveReader map[string]string
+
+func (r mapArchiveReader) Get(_ context.Context, key []byte, _ uint64) ([]byte, error) {
+	return []byte(r[string(key)]), nil
+}
+
+func (r mapArchiveReader) BatchGet(context.Context, [][]byte, uint64) (map[string][]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (r mapArchiveReader) Scan(context.Context, []byte, []byte, int, uint64, bool) ([][]byte, [][]byte, error) {
+	return nil, nil, errors.New("not implemented")
+}
+
+func TestWithKeyspaceInheritance(t *testing.T) {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	require.NoError(t, err)
+	mocktikv.BootstrapWithSingleStore(cluster)
+	pdClient = &keyspacePDClient{Client: pdClient, keyspaces: map[string]uint32{"ks1": 1, "ks2": 2}}
+	store, err := NewTestKeyspaceTiKVStore(client, pdClient, nil, nil, 0, keyspacepb.KeyspaceMeta{Id: 1, Name: "ks1"},
+		WithArchiveReader(mapArchiveReader{"k": "archived"}))
+	require.NoError(t, err)
+	defer store.Close()
+
+	ks2, err := store.WithKeyspace("ks2")
+	require.NoError(t, err)
+	require.NotNil(t, ks2.archiveReader)
+
+	// The safe point and the safe ts are updated by the parent.
+	ts, err := store.CurrentTimestamp(oracle.GlobalTxnScope)
+	require.NoError(t, err)
+	store.UpdateSPCache(ts, time.Now())
+	var gcErr *tikverr.ErrGCTooEarly
+	require.ErrorAs(t, ks2.CheckVisibility(ts-1), &gcErr)
+	store.setMinSafeTS(oracle.GlobalTxnScope, ts)
+	require.Equal(t, ts, ks2.GetMinSafeTS(oracle.GlobalTxnScope))
+
+	// The reads of the keyspace store older than the safe point are served by
+	// the archive reader of the parent.
+	value, err := ks2.GetSnapshot(ts-1).Get(context.Background(), []byte("k"))
+	require.NoError(t, err)
+	require.Equal(t, "archived", string(value))
+}
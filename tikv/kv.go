@@ -49,6 +49,7 @@ import (
 	"github.com/opentracing/opentracing-go"
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tikv/client-go/v2/config"
 	"github.com/tikv/client-go/v2/config/retry"
 	tikverr "github.com/tikv/client-go/v2/error"
@@ -61,11 +62,11 @@ import (
 	"github.com/tikv/client-go/v2/oracle"
 	"github.com/tikv/client-go/v2/oracle/oracles"
 	"github.com/tikv/client-go/v2/tikvrpc"
-	"github.com/tikv/client-go/v2/txnkv/rangetask"
 	"github.com/tikv/client-go/v2/txnkv/transaction"
 	"github.com/tikv/client-go/v2/txnkv/txnlock"
 	"github.com/tikv/client-go/v2/txnkv/txnsnapshot"
 	"github.com/tikv/client-go/v2/util"
+	"github.com/tikv/client-go/v2/util/slowlog"
 	pd "github.com/tikv/pd/client"
 	pdhttp "github.com/tikv/pd/client/http"
 	"github.com/tikv/pd/client/opt"
@@ -121,6 +122,39 @@ type KVStore struct {
 	}
 	pdClient     pd.Client
 	pdHttpClient pdhttp.Client
+	// rpcClient is the client passed to NewKVStore, whose connections are
+	// shared by the keyspace stores.
+	rpcClient Client
+
+	// parent is the store sharing its infrastructure with this one if it's
+	// created by WithKeyspace.
+	parent       *KVStore
+	keyspaceName string
+	keyspaces    struct {
+		sync.Mutex
+		stores map[string]*KVStore
+	}
+
+	// metricsRegisterer is the registerer the metrics of the store are registered to when creating the store.
+	metricsRegisterer prometheus.Registerer
+	// storeMetrics observes the requests sent by the store if it's not nil.
+	storeMetrics *metrics.StoreMetrics
+	// archiveReader serves the snapshot reads older than the GC safe point.
+	archiveReader txnsnapshot.ArchiveReader
+	// workloadRouting routes the snapshot reads by their workload classes.
+	workloadRouting kv.WorkloadRouting
+	// adaptiveScanBatch makes the scanners of the snapshots adapt their batch sizes.
+	adaptiveScanBatch *txnsnapshot.AdaptiveScanBatch
+	// scanPrefetch is the number of the batches the scanners of the snapshots fetch ahead.
+	scanPrefetch int
+	// deadlines are the default deadlines of the operations of the transactions and the snapshots.
+	deadlines kv.OperationDeadlines
+	// inFlightTxns tracks the start ts of the transactions not finished yet if it's not nil, see
+	// WithInFlightTxnTracking.
+	inFlightTxns *inFlightTxns
+	// deleteRanges keeps the ranges to destroy after GC, see WithDestroyAfterGC.
+	deleteRanges DeleteRangeRegistry
+
 	regionCache  *locate.RegionCache
 	lockResolver *txnlock.LockResolver
 	txnLatches   *latch.LatchesScheduler
@@ -135,6 +169,7 @@ type KVStore struct {
 	// storeID -> safeTS, stored as map[uint64]uint64
 	// safeTS here will be used during the Stale Read process,
 	// it indicates the safe timestamp point that can be used to read consistent but may not the latest data.
+	// The keyspace stores use the ones of their parents, see WithKeyspace.
 	safeTSMap sync.Map
 
 	// MinSafeTs stores the minimum ts value for each txnScope
@@ -142,6 +177,9 @@ type KVStore struct {
 
 	replicaReadSeed uint32 // this is used to load balance followers / learners when replica read is enabled
 
+	// asyncOps tracks the background operations of the transactions.
+	asyncOps transaction.AsyncOps
+
 	ctx    context.Context
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
@@ -159,9 +197,17 @@ func (s *KVStore) Go(f func()) error {
 // UpdateSPCache updates cached safepoint.
 func (s *KVStore) UpdateSPCache(cachedSP uint64, cachedTime time.Time) {
 	s.spMutex.Lock()
+	changed := s.safePoint != cachedSP
 	s.safePoint = cachedSP
 	s.spTime = cachedTime
 	s.spMutex.Unlock()
+	if changed {
+		s.regionCache.PublishEvent(Event{Type: EventSafePointUpdated, SafePoint: cachedSP})
+	}
+	// The keyspace stores share the safe point kv and don't load it themselves.
+	for _, store := range s.keyspaceStores() {
+		store.UpdateSPCache(cachedSP, cachedTime)
+	}
 }
 
 // CheckVisibility checks if it is safe to read using given ts.
@@ -210,6 +256,136 @@ func WithUpdateInterval(updateInterval time.Duration) Option {
 	}
 }
 
+// WithTSOBatch makes GetTimestamp calls from concurrent goroutines wait up to maxWait to be coalesced into one PD
+// request, a batch is sent as soon as it has maxBatchSize calls. It trades latency for less TSO RPCs when there are
+// many small transactions. Transactions begun with WithLowLatencyTSO bypass the batching.
+func WithTSOBatch(maxWait time.Duration, maxBatchSize int) Option {
+	return func(o *KVStore) {
+		if b, ok := o.oracle.(interface {
+			SetTSOBatchOptions(time.Duration, int)
+		}); ok {
+			b.SetTSOBatchOptions(maxWait, maxBatchSize)
+		}
+	}
+}
+
+// WithSlowLog emits the requests to TiKV taking longer than threshold to sink, with their routing, retry and
+// backoff history. See the slowlog package for the sinks provided.
+func WithSlowLog(threshold time.Duration, sink slowlog.Sink) Option {
+	return func(o *KVStore) {
+		o.regionCache.SetSlowLogger(slowlog.New(threshold, sink))
+	}
+}
+
+// WithWritePacing paces the write requests to the stores reporting flow-control signals, i.e. ServerIsBusy errors
+// and write throttling in the execution details, instead of retrying into the stalled stores. The rate of each store
+// is decreased on the signals and increased back on normal writes. See KVStore.GetWritePacingStats for the stats.
+func WithWritePacing() Option {
+	return func(o *KVStore) {
+		o.regionCache.SetWritePacing(true)
+	}
+}
+
+// WithHotRegionDetection measures the QPS and bytes of the regions by the requests sent from the store, and calls onHot
+// once per window for each region reaching the thresholds of cfg, which can be nil. If cfg.AutoSplit is set, the hot
+// regions are also split at the median of the keys requested, for the workloads TiKV's hotspot scheduling can't
+// handle in time. See KVStore.GetHotRegions for the hot regions.
+func WithHotRegionDetection(cfg HotRegionConfig, onHot func(HotRegion)) Option {
+	return func(o *KVStore) {
+		if cfg.AutoSplit {
+			splitter := o.startHotRegionSplitter(cfg.Scatter)
+			if onHot != nil {
+				fn := onHot
+				onHot = func(r HotRegion) {
+					fn(r)
+					splitter(r)
+				}
+			} else {
+				onHot = splitter
+			}
+		}
+		o.regionCache.SetHotRegionDetection(&cfg, onHot)
+	}
+}
+
+// WithReplicaSelectorPolicy makes the requests sent to TiKV select the replicas by policy instead of the built-in
+// selection, including the replicas to retry on errors, see ReplicaSelectorPolicy.
+func WithReplicaSelectorPolicy(policy ReplicaSelectorPolicy) Option {
+	return func(o *KVStore) {
+		o.regionCache.SetReplicaSelectorPolicy(policy)
+	}
+}
+
+// WithArchiveReader makes the snapshots of the store read from r at the timestamps older than the GC safe point,
+// instead of failing with ErrGCTooEarly. See txnsnapshot.KVSnapshot.SetArchiveReader for details.
+func WithArchiveReader(r txnsnapshot.ArchiveReader) Option {
+	return func(o *KVStore) {
+		o.archiveReader = r
+	}
+}
+
+// WithDeleteRangeRegistry makes the store keep the ranges to destroy after GC in r, see WithDestroyAfterGC. By default
+// they are kept in memory and lost when the process exits.
+func WithDeleteRangeRegistry(r DeleteRangeRegistry) Option {
+	return func(o *KVStore) {
+		o.deleteRanges = r
+	}
+}
+
+// WithAdaptiveScanBatch makes the scanners of the snapshots of the store adapt the number of the entries fetched per
+// RPC within the bounds of cfg, see txnsnapshot.KVSnapshot.SetAdaptiveScanBatch.
+func WithAdaptiveScanBatch(cfg txnsnapshot.AdaptiveScanBatch) Option {
+	return func(o *KVStore) {
+		o.adaptiveScanBatch = &cfg
+	}
+}
+
+// WithScanPrefetch makes the scanners of the snapshots of the store fetch at most lookahead batches ahead in the
+// background while the caller consumes the current one, see txnsnapshot.KVSnapshot.SetScanPrefetch.
+func WithScanPrefetch(lookahead int) Option {
+	return func(o *KVStore) {
+		o.scanPrefetch = lookahead
+	}
+}
+
+// WithScanRegionPrefetch makes the forward scans load the next n regions in the background when they move to a region
+// whose next region isn't cached, so that the scans don't wait for PD at the borders of the regions.
+func WithScanRegionPrefetch(n int) Option {
+	return func(o *KVStore) {
+		o.regionCache.SetScanPrefetch(n)
+	}
+}
+
+// WithRegionMapFallback saves the routing metadata to the file at path every saveInterval, and routes the requests by
+// the file while PD is unavailable, so the store can keep serving with the stale routing until PD recovers. See
+// RegionCache.EnableRegionMapFallback.
+func WithRegionMapFallback(path string, saveInterval time.Duration) Option {
+	return func(o *KVStore) {
+		if err := o.regionCache.EnableRegionMapFallback(path, saveInterval); err != nil {
+			logutil.BgLogger().Warn("failed to enable region map fallback", zap.String("path", path), zap.Error(err))
+		}
+	}
+}
+
+// WithWorkloadRouting sets the replicas to read from for the workload classes, which are tagged on the transactions
+// by WithWorkloadClass or on the snapshots by SetWorkloadClass, so that one store can serve mixed workloads without
+// them interfering each other. The classes missing in routing use kv.DefaultWorkloadRouting.
+func WithWorkloadRouting(routing kv.WorkloadRouting) Option {
+	return func(o *KVStore) {
+		o.workloadRouting = routing
+	}
+}
+
+// WithOperationDeadlines sets the default deadlines of the operations of the transactions and the snapshots of the
+// store, which are applied if the contexts of the operations have no deadlines, and can be overridden per transaction
+// or snapshot by their SetOperationDeadlines. The remaining time before a deadline is sent to TiKV as the max execution
+// duration of the requests, so TiKV stops the work the client has given up on.
+func WithOperationDeadlines(deadlines kv.OperationDeadlines) Option {
+	return func(o *KVStore) {
+		o.deadlines = deadlines
+	}
+}
+
 // WithPDHTTPClient sets the PD HTTP client with the given PD addresses and options.
 // Source is to mark where the HTTP client is created, which is used for metrics and logs.
 func WithPDHTTPClient(
@@ -234,6 +410,21 @@ func WithPDHTTPClient(
 	}
 }
 
+// WithMetricsRegisterer creates the metrics of the requests sent by the store (see metrics.StoreMetrics) and registers
+// them to r when creating the store. The metrics are named in namespace, "tikv" if it's empty, and constLabels (e.g.
+// cluster=prod-2) are attached to all of them, so that multiple stores in one process can export their own metrics to
+// different registries or distinguish themselves in the same registry. The stores created by WithKeyspace share the
+// metrics of the store. The process-wide metrics variables are not affected, see metrics.RegisterMetrics.
+func WithMetricsRegisterer(r prometheus.Registerer, namespace string, constLabels prometheus.Labels) Option {
+	return func(o *KVStore) {
+		if namespace == "" {
+			namespace = "tikv"
+		}
+		o.metricsRegisterer = r
+		o.storeMetrics = metrics.NewStoreMetrics(namespace, "client_go", constLabels)
+	}
+}
+
 // loadOption load KVStore option into KVStore.
 func loadOption(store *KVStore, opt ...Option) {
 	for _, f := range opt {
@@ -274,26 +465,42 @@ func NewKVStore(uuid string, pdClient pd.Client, spkv SafePointKV, tikvclient Cl
 	ctx, cancel := context.WithCancel(context.Background())
 	regionCache := locate.NewRegionCache(pdClient, locate.WithRequestHealthFeedbackCallback(func(ctx context.Context, addr string) error {
 		return requestHealthFeedbackFromKVClient(ctx, addr, tikvclient)
+	}), locate.WithStoreAddrChangedCallback(func(oldAddr, _ string) {
+		// The connections to the old address may reach another process taking over the address.
+		if err := tikvclient.CloseAddr(oldAddr); err != nil {
+			logutil.BgLogger().Warn("failed to close connections to the old store address", zap.String("addr", oldAddr), zap.Error(err))
+		}
 	}))
 	store := &KVStore{
 		clusterID:       pdClient.GetClusterID(context.TODO()),
 		uuid:            uuid,
 		oracle:          o,
 		pdClient:        pdClient,
+		rpcClient:       tikvclient,
 		regionCache:     regionCache,
 		kv:              spkv,
 		safePoint:       0,
 		spTime:          time.Now(),
 		replicaReadSeed: rand.Uint32(),
+		deleteRanges:    newMemDeleteRangeRegistry(),
 		ctx:             ctx,
 		cancel:          cancel,
 		gP:              NewSpool(128, 10*time.Second),
 	}
-	store.clientMu.client = client.NewReqCollapse(client.NewInterceptedClient(tikvclient))
-	store.clientMu.client.SetEventListener(regionCache.GetClientEventListener())
-
 	store.lockResolver = txnlock.NewLockResolver(store)
 	loadOption(store, opt...)
+	if store.storeMetrics != nil {
+		if err = store.storeMetrics.Register(store.metricsRegisterer); err != nil {
+			store.lockResolver.Close()
+			regionCache.Close()
+			store.gP.Close()
+			o.Close()
+			cancel()
+			return nil, err
+		}
+	}
+	store.clientMu.client = client.NewReqCollapse(client.NewInterceptedClient(store.metricsClient(tikvclient)))
+	store.clientMu.client.SetEventListener(regionCache.GetClientEventListener())
 
 	store.wg.Add(2)
 	go store.runSafePointChecker()
@@ -302,6 +509,15 @@ func NewKVStore(uuid string, pdClient pd.Client, spkv SafePointKV, tikvclient Cl
 	return store, nil
 }
 
+// metricsClient wraps c to observe the requests in the metrics of the store if
+// it's set by WithMetricsRegisterer.
+func (s *KVStore) metricsClient(c Client) Client {
+	if s.storeMetrics == nil {
+		return c
+	}
+	return locate.NewStoreMetricsClient(c, s.storeMetrics)
+}
+
 // NewPDClient returns an unwrapped pd client.
 func NewPDClient(pdAddrs []string) (pd.Client, error) {
 	cfg := config.GetGlobalConfig()
@@ -379,30 +595,36 @@ func (s *KVStore) Begin(opts ...TxnOption) (txn *transaction.KVTxn, err error) {
 	)
 	if options.StartTS != nil {
 		startTS = *options.StartTS
+		if startTS <= options.MinStartTS {
+			return nil, errors.Errorf("start ts %d is not greater than the min start ts %d", startTS, options.MinStartTS)
+		}
 	} else {
-		bo := retry.NewBackofferWithVars(context.Background(), transaction.TsoMaxBackoff, nil)
+		ctx := context.Background()
+		if options.LowLatencyTSO {
+			ctx = oracle.WithLowLatency(ctx)
+		}
+		bo := retry.NewBackofferWithVars(ctx, transaction.TsoMaxBackoff, nil)
 		startTS, err = s.getTimestampWithRetry(bo, options.TxnScope)
 		if err != nil {
 			return nil, err
 		}
+		if startTS <= options.MinStartTS {
+			if startTS, err = s.waitTimestampAfter(bo, options.TxnScope, options.MinStartTS); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	snapshot := txnsnapshot.NewTiKVSnapshot(s, startTS, s.nextReplicaReadSeed())
-	return transaction.NewTiKVTxn(s, snapshot, startTS, options)
-}
-
-// DeleteRange delete all versions of all keys in the range[startKey,endKey) immediately.
-// Be careful while using this API. This API doesn't keep recent MVCC versions, but will delete all versions of all keys
-// in the range immediately. Also notice that frequent invocation to this API may cause performance problems to TiKV.
-func (s *KVStore) DeleteRange(
-	ctx context.Context, startKey []byte, endKey []byte, concurrency int,
-) (completedRegions int, err error) {
-	task := rangetask.NewDeleteRangeTask(s, startKey, endKey, concurrency)
-	err = task.Execute(ctx)
-	if err == nil {
-		completedRegions = task.CompletedRegions()
+	snapshot := s.newSnapshot(startTS)
+	txn, err = transaction.NewTiKVTxn(s, snapshot, startTS, options)
+	if err != nil {
+		return nil, err
 	}
-	return completedRegions, err
+	if t := s.inFlightTxns; t != nil {
+		t.add(startTS)
+		txn.AddCloseCallback(func() { t.remove(startTS) })
+	}
+	return txn, nil
 }
 
 // GetSnapshot gets a snapshot that is able to read any data which data is <= the given ts.
@@ -410,17 +632,39 @@ func (s *KVStore) DeleteRange(
 // to be consistent.
 // Specially, it is useful to set ts to math.MaxUint64 to point get the latest committed data.
 func (s *KVStore) GetSnapshot(ts uint64) *txnsnapshot.KVSnapshot {
+	return s.newSnapshot(ts)
+}
+
+func (s *KVStore) newSnapshot(ts uint64) *txnsnapshot.KVSnapshot {
 	snapshot := txnsnapshot.NewTiKVSnapshot(s, ts, s.nextReplicaReadSeed())
+	if s.archiveReader != nil {
+		snapshot.SetArchiveReader(s.archiveReader)
+	}
+	if s.workloadRouting != nil {
+		snapshot.SetWorkloadRouting(s.workloadRouting)
+	}
+	if s.adaptiveScanBatch != nil {
+		snapshot.SetAdaptiveScanBatch(s.adaptiveScanBatch)
+	}
+	if s.scanPrefetch > 0 {
+		snapshot.SetScanPrefetch(s.scanPrefetch)
+	}
+	snapshot.SetOperationDeadlines(s.deadlines)
 	return snapshot
 }
 
 // Close store
 func (s *KVStore) Close() error {
-	defer s.gP.Close()
 	s.close.Store(true)
 	s.cancel()
 	s.wg.Wait()
 
+	s.closeKeyspaceStores()
+	if s.parent != nil {
+		return s.closeKeyspaceStore()
+	}
+	defer s.gP.Close()
+
 	s.oracle.Close()
 	s.pdClient.Close()
 	if s.pdHttpClient != nil {
@@ -502,6 +746,25 @@ func (s *KVStore) getTimestampWithRetry(bo *Backoffer, txnScope string) (uint64,
 	}
 }
 
+// waitTimestampAfter fetches the timestamps bypassing the TSO batching until one is greater than minTS, which may be
+// the commit ts of another client.
+func (s *KVStore) waitTimestampAfter(bo *Backoffer, txnScope string, minTS uint64) (uint64, error) {
+	bo.SetCtx(oracle.WithLowLatency(bo.GetCtx()))
+	for {
+		ts, err := s.getTimestampWithRetry(bo, txnScope)
+		if err != nil {
+			return 0, err
+		}
+		if ts > minTS {
+			return ts, nil
+		}
+		err = bo.Backoff(retry.BoPDRPC, errors.Errorf("timestamp %d is not greater than the min start ts %d", ts, minTS))
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
 func (s *KVStore) getAllTSOKeyspaceGroupMinTSWithRetry(bo *Backoffer) (uint64, error) {
 	if span := opentracing.SpanFromContext(bo.GetCtx()); span != nil && span.Tracer() != nil {
 		span1 := span.Tracer().StartSpan("TiKVStore.getAllTSOKeyspaceGroupMinTSWithRetry", opentracing.ChildOf(span.Context()))
@@ -558,11 +821,32 @@ func (s *KVStore) SendReq(
 	return resp, err
 }
 
+// Events returns a channel receiving the events observed by the store from
+// now on, e.g. the region cache invalidations, the leader changes, the store
+// state changes, the resolved locks and the safe point updates. Each call
+// subscribes a new channel. The events are dropped for a subscriber falling
+// behind by more than EventsBufferSize events, and the channels are closed
+// when the store is closed.
+func (s *KVStore) Events() <-chan Event {
+	return s.regionCache.SubscribeEvents(EventsBufferSize)
+}
+
 // GetRegionCache returns the region cache instance.
 func (s *KVStore) GetRegionCache() *locate.RegionCache {
 	return s.regionCache
 }
 
+// GetWritePacingStats returns the write pacing stats of the stores, see WithWritePacing.
+func (s *KVStore) GetWritePacingStats() []WritePacingStats {
+	return s.regionCache.WritePacingStats()
+}
+
+// GetHotRegions returns the hot regions detected in the current or the last window, sorted by QPS in descending order,
+// see WithHotRegionDetection.
+func (s *KVStore) GetHotRegions() []HotRegion {
+	return s.regionCache.HotRegions()
+}
+
 // GetLockResolver returns the lock resolver instance.
 func (s *KVStore) GetLockResolver() *txnlock.LockResolver {
 	return s.lockResolver
@@ -599,7 +883,7 @@ func (s *KVStore) GetTiKVClient() (client Client) {
 
 // GetMinSafeTS return the minimal safeTS of the storage with given txnScope.
 func (s *KVStore) GetMinSafeTS(txnScope string) uint64 {
-	if val, ok := s.minSafeTS.Load(txnScope); ok {
+	if val, ok := s.root().minSafeTS.Load(txnScope); ok {
 		return val.(uint64)
 	}
 	return 0
@@ -611,7 +895,7 @@ func (s *KVStore) setMinSafeTS(txnScope string, safeTS uint64) {
 		logutil.AssertWarn(logutil.BgLogger(), "skip setting min-safe-ts to max uint64", zap.String("txnScope", txnScope), zap.Stack("stack"))
 		return
 	}
-	s.minSafeTS.Store(txnScope, safeTS)
+	s.root().minSafeTS.Store(txnScope, safeTS)
 }
 
 // Ctx returns ctx.
@@ -629,6 +913,23 @@ func (s *KVStore) WaitGroup() *sync.WaitGroup {
 	return &s.wg
 }
 
+// AsyncOps returns the tracker of the operations the transactions run in the
+// background.
+func (s *KVStore) AsyncOps() *transaction.AsyncOps {
+	return &s.asyncOps
+}
+
+// Barrier waits until the background operations of the transactions started
+// before it finish, e.g. committing the secondaries, the async commits, the
+// pipelined flushes and the cleanups. It returns the errors of the background
+// operations failed since the last barrier, joined into one, so it can be
+// called before shutting down or between the phases of a batch job to make
+// sure the previous writes are settled. The requests the callers are waiting
+// for are not tracked, nor are the locks resolved asynchronously for reads.
+func (s *KVStore) Barrier(ctx context.Context) error {
+	return s.asyncOps.Barrier(ctx)
+}
+
 // TxnLatches returns txnLatches.
 func (s *KVStore) TxnLatches() *latch.LatchesScheduler {
 	return s.txnLatches
@@ -640,7 +941,7 @@ func (s *KVStore) GetClusterID() uint64 {
 }
 
 func (s *KVStore) getSafeTS(storeID uint64) (bool, uint64) {
-	safeTS, ok := s.safeTSMap.Load(storeID)
+	safeTS, ok := s.root().safeTSMap.Load(storeID)
 	if !ok {
 		return false, 0
 	}
@@ -654,7 +955,7 @@ func (s *KVStore) setSafeTS(storeID, safeTS uint64) {
 		logutil.AssertWarn(logutil.BgLogger(), "skip setting safe-ts to max uint64", zap.Uint64("storeID", storeID), zap.Stack("stack"))
 		return
 	}
-	s.safeTSMap.Store(storeID, safeTS)
+	s.root().safeTSMap.Store(storeID, safeTS)
 }
 
 func (s *KVStore) updateMinSafeTS(txnScope string, storeIDs []uint64) {
@@ -952,6 +1253,29 @@ func WithStartTS(startTS uint64) TxnOption {
 	}
 }
 
+// WithLowLatencyTSO makes the timestamps of the transaction bypass the TSO batching enabled by WithTSOBatch.
+func WithLowLatencyTSO() TxnOption {
+	return func(st *transaction.TxnOptions) {
+		st.LowLatencyTSO = true
+	}
+}
+
+// WithMinStartTS makes the start ts of the transaction greater than minStartTS. Pass the CommitTS of a transaction
+// committed by another client, maybe in another process, to read its writes, i.e. the causality across the clients
+// holds even if the timestamps of the store are batched or fetched from another TSO.
+func WithMinStartTS(minStartTS uint64) TxnOption {
+	return func(st *transaction.TxnOptions) {
+		st.MinStartTS = minStartTS
+	}
+}
+
+// WithWorkloadClass tags the reads of the transaction with the workload class, see WithWorkloadRouting.
+func WithWorkloadClass(class kv.WorkloadClass) TxnOption {
+	return func(st *transaction.TxnOptions) {
+		st.WorkloadClass = &class
+	}
+}
+
 // WithDefaultPipelinedTxn creates pipelined txn with default parameters
 func WithDefaultPipelinedTxn() TxnOption {
 	return func(st *transaction.TxnOptions) {
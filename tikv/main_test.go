@@ -17,6 +17,7 @@ package tikv
 import (
 	"testing"
 
+	"github.com/tikv/client-go/v2/internal/mockstore/mocktikv"
 	"go.uber.org/goleak"
 )
 
@@ -27,3 +28,24 @@ func TestMain(m *testing.M) {
 
 	goleak.VerifyTestMain(m, opts...)
 }
+
+// newMockStore creates a store backed by a mock cluster of a single store,
+// which is split into regions at the keys.
+func newMockStore(splitKeys [][]byte, opts ...Option) (*KVStore, *mocktikv.Cluster, error) {
+	client, cluster, pdClient, err := mocktikv.NewTiKVAndPDClient("", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, _, regionID := mocktikv.BootstrapWithSingleStore(cluster)
+	for _, key := range splitKeys {
+		newRegionID, newPeerID := cluster.AllocID(), cluster.AllocID()
+		cluster.Split(regionID, newRegionID, key, []uint64{newPeerID}, newPeerID)
+		regionID = newRegionID
+	}
+	store, err := NewTestTiKVStore(client, pdClient, nil, nil, 0, opts...)
+	if err != nil {
+		client.Close()
+		return nil, nil, err
+	}
+	return store, cluster, nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreMetrics(t *testing.T) {
+	countRequests := func(reg *prometheus.Registry, name string) uint64 {
+		families, err := reg.Gather()
+		require.Nil(t, err)
+		var count uint64
+		for _, f := range families {
+			if f.GetName() != name {
+				continue
+			}
+			for _, m := range f.GetMetric() {
+				count += m.GetHistogram().GetSampleCount()
+			}
+		}
+		return count
+	}
+
+	reg1, reg2 := prometheus.NewRegistry(), prometheus.NewRegistry()
+	store1, _, err := newMockStore(nil, WithMetricsRegisterer(reg1, "prod", prometheus.Labels{"cluster": "prod-1"}))
+	require.Nil(t, err)
+	defer store1.Close()
+	store2, _, err := newMockStore(nil, WithMetricsRegisterer(reg2, "", nil))
+	require.Nil(t, err)
+	defer store2.Close()
+
+	txn, err := store1.Begin()
+	require.Nil(t, err)
+	require.Nil(t, txn.Set([]byte("sm"), []byte("v")))
+	require.Nil(t, txn.Commit(context.Background()))
+
+	// Each registry only observes the requests of its own store.
+	require.Greater(t, countRequests(reg1, "prod_client_go_store_request_seconds"), uint64(0))
+	require.Equal(t, uint64(0), countRequests(reg2, "tikv_client_go_store_request_seconds"))
+	families, err := reg1.Gather()
+	require.Nil(t, err)
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			require.Equal(t, "prod-1", labels["cluster"])
+		}
+	}
+
+	// The stores registering to the same registry share the metrics.
+	store3, _, err := newMockStore(nil, WithMetricsRegisterer(reg2, "", nil))
+	require.Nil(t, err)
+	defer store3.Close()
+	require.Same(t, store2.storeMetrics.SendReqHistogram, store3.storeMetrics.SendReqHistogram)
+
+	// The metrics conflicting with the registered ones fail the store.
+	_, _, err = newMockStore(nil, WithMetricsRegisterer(reg2, "", prometheus.Labels{"cluster": "prod-2"}))
+	require.NotNil(t, err)
+}
@@ -0,0 +1,57 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/oracle"
+)
+
+func TestMinStartTS(t *testing.T) {
+	store, _, err := newMockStore(nil)
+	require.Nil(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	require.Nil(t, txn.Set([]byte("k"), []byte("v")))
+	require.Nil(t, txn.Commit(ctx))
+	commitTS := txn.CommitTS()
+	require.Greater(t, commitTS, txn.StartTS())
+
+	// Another client starts after the commit ts it's given.
+	txn, err = store.Begin(WithMinStartTS(commitTS))
+	require.Nil(t, err)
+	require.Greater(t, txn.StartTS(), commitTS)
+	val, err := txn.Get(ctx, []byte("k"))
+	require.Nil(t, err)
+	require.Equal(t, []byte("v"), val)
+	require.Nil(t, txn.Rollback())
+
+	// The timestamps are fetched until they pass the min start ts.
+	minStartTS := oracle.ComposeTS(oracle.GetPhysical(time.Now().Add(50*time.Millisecond)), 0)
+	txn, err = store.Begin(WithMinStartTS(minStartTS))
+	require.Nil(t, err)
+	require.Greater(t, txn.StartTS(), minStartTS)
+	require.Nil(t, txn.Rollback())
+
+	_, err = store.Begin(WithStartTS(commitTS), WithMinStartTS(commitTS))
+	require.Error(t, err)
+}
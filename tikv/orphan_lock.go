@@ -0,0 +1,180 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/txnkv/txnlock"
+	"go.uber.org/zap"
+)
+
+// OrphanLockCleanupStat is the result of CleanupOrphanAsyncCommitLocks.
+type OrphanLockCleanupStat struct {
+	// ScannedLocks is the number of async-commit locks older than the threshold.
+	ScannedLocks int
+	// ResolvedTxns is the start ts of transactions whose locks are resolved.
+	// In dry-run mode, it's the transactions whose locks are expired and
+	// would be resolved.
+	ResolvedTxns []uint64
+	// SkippedTxns is the start ts of transactions whose locks are still alive.
+	SkippedTxns []uint64
+	// Locks is the scanned locks. It's only filled in dry-run mode.
+	Locks []*txnlock.Lock
+}
+
+type orphanLockCleanupOption struct {
+	dryRun     bool
+	txnsPerSec int
+}
+
+// OrphanLockCleanupOpt is the option of CleanupOrphanAsyncCommitLocks.
+type OrphanLockCleanupOpt func(*orphanLockCleanupOption)
+
+// WithCleanupDryRun makes CleanupOrphanAsyncCommitLocks check the status of
+// the transactions as usual but report the locks without resolving them.
+func WithCleanupDryRun() OrphanLockCleanupOpt {
+	return func(opt *orphanLockCleanupOption) {
+		opt.dryRun = true
+	}
+}
+
+// WithCleanupRateLimit limits the number of transactions resolved per second.
+func WithCleanupRateLimit(txnsPerSec int) OrphanLockCleanupOpt {
+	return func(opt *orphanLockCleanupOption) {
+		opt.txnsPerSec = txnsPerSec
+	}
+}
+
+// CleanupOrphanAsyncCommitLocks resolves async-commit locks in [startKey,
+// endKey) whose transactions started earlier than olderThan ago, which are
+// usually left by crashed clients. Empty keys mean the range is unbounded.
+//
+// The locks are resolved by the same protocol as reads do: the status of the
+// primary lock is checked first, and if the transaction is neither committed
+// nor rolled back, all secondary locks are checked to decide whether the
+// transaction can be committed. Locks whose TTL is not expired are skipped,
+// so the function is safe to be called repeatedly and concurrently with
+// running transactions.
+func (s *KVStore) CleanupOrphanAsyncCommitLocks(ctx context.Context, startKey, endKey []byte, olderThan time.Duration, opts ...OrphanLockCleanupOpt) (*OrphanLockCleanupStat, error) {
+	opt := &orphanLockCleanupOption{}
+	for _, o := range opts {
+		o(opt)
+	}
+	var interval time.Duration
+	if opt.txnsPerSec > 0 {
+		interval = time.Second / time.Duration(opt.txnsPerSec)
+	}
+
+	bo := NewGcResolveLockMaxBackoffer(ctx)
+	currentTS, err := s.getTimestampWithRetry(bo, oracle.GlobalTxnScope)
+	if err != nil {
+		return nil, err
+	}
+	maxVersion := oracle.ComposeTS(oracle.ExtractPhysical(currentTS)-olderThan.Milliseconds(), 0)
+
+	stat := &OrphanLockCleanupStat{}
+	seen := make(map[uint64]struct{})
+	key := startKey
+	var lastResolve time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return stat, errors.WithStack(ctx.Err())
+		default:
+		}
+		bo = NewGcResolveLockMaxBackoffer(ctx)
+		locks, loc, err := scanLocksInOneRegionWithStartKey(bo, s, key, maxVersion, GCScanLockLimit)
+		if err != nil {
+			return stat, err
+		}
+
+		// Group the async-commit locks by transactions.
+		var txnIDs []uint64
+		txnLocks := make(map[uint64][]*txnlock.Lock)
+		for _, l := range locks {
+			if !l.UseAsyncCommit || (len(endKey) > 0 && bytes.Compare(l.Key, endKey) >= 0) {
+				continue
+			}
+			stat.ScannedLocks++
+			if opt.dryRun {
+				stat.Locks = append(stat.Locks, l)
+			}
+			if _, ok := txnLocks[l.TxnID]; !ok {
+				txnIDs = append(txnIDs, l.TxnID)
+			}
+			txnLocks[l.TxnID] = append(txnLocks[l.TxnID], l)
+		}
+
+		for _, txnID := range txnIDs {
+			if _, ok := seen[txnID]; ok {
+				continue
+			}
+			if interval > 0 {
+				if wait := interval - time.Since(lastResolve); wait > 0 {
+					select {
+					case <-ctx.Done():
+						return stat, errors.WithStack(ctx.Err())
+					case <-time.After(wait):
+					}
+				}
+				lastResolve = time.Now()
+			}
+			var alive bool
+			if opt.dryRun {
+				// Only the status of the primary lock is checked, the resolve
+				// RPCs, including CheckSecondaryLocks, are skipped.
+				status, err := s.lockResolver.CheckTxnStatusForLock(bo, txnLocks[txnID][0], currentTS)
+				if err != nil {
+					return stat, err
+				}
+				alive = status.TTL() != 0
+			} else {
+				msBeforeExpired, err := s.lockResolver.ResolveLocks(bo, currentTS, txnLocks[txnID])
+				if err != nil {
+					return stat, err
+				}
+				alive = msBeforeExpired > 0
+			}
+			seen[txnID] = struct{}{}
+			if alive {
+				stat.SkippedTxns = append(stat.SkippedTxns, txnID)
+			} else {
+				stat.ResolvedTxns = append(stat.ResolvedTxns, txnID)
+			}
+		}
+
+		if len(locks) < GCScanLockLimit {
+			key = loc.EndKey
+		} else {
+			key = kv.NextKey(locks[len(locks)-1].Key)
+		}
+		if len(key) == 0 || (len(endKey) != 0 && bytes.Compare(key, endKey) >= 0) {
+			break
+		}
+	}
+	logutil.Logger(ctx).Info("cleanup orphan async-commit locks finished",
+		zap.Bool("dryRun", opt.dryRun),
+		zap.Int("scannedLocks", stat.ScannedLocks),
+		zap.Int("resolvedTxns", len(stat.ResolvedTxns)),
+		zap.Int("skippedTxns", len(stat.SkippedTxns)))
+	return stat, nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/txnkv/txnsnapshot"
+	"go.uber.org/zap"
+)
+
+// DefReadOnlyServiceSafePointTTL is the default TTL of the service safe point
+// registered by a read-only transaction.
+const DefReadOnlyServiceSafePointTTL = 10 * time.Minute
+
+type readOnlyOption struct {
+	serviceID string
+	ttl       time.Duration
+}
+
+// ReadOnlyOpt is the option of BeginReadOnly.
+type ReadOnlyOpt func(*readOnlyOption)
+
+// WithServiceSafePointTTL sets the TTL of the service safe point. The safe
+// point is refreshed every third of the TTL until the transaction is closed, so
+// the TTL only bounds how long the data is kept after the client crashes.
+func WithServiceSafePointTTL(ttl time.Duration) ReadOnlyOpt {
+	return func(opt *readOnlyOption) {
+		opt.ttl = ttl
+	}
+}
+
+// WithServiceID sets the service ID of the service safe point, which is
+// generated by default.
+func WithServiceID(serviceID string) ReadOnlyOpt {
+	return func(opt *readOnlyOption) {
+		opt.serviceID = serviceID
+	}
+}
+
+// ReadOnlyTxn is a transaction reading the data at a pinned timestamp. It has
+// no method to write, so writing through it is rejected at compile time.
+// It registers a service safe point at its timestamp in PD and keeps it alive,
+// so the data it reads isn't garbage collected however long it runs, e.g. for
+// analytical reads. It must be closed to release the safe point.
+type ReadOnlyTxn struct {
+	store     *KVStore
+	startTS   uint64
+	snapshot  *txnsnapshot.KVSnapshot
+	serviceID string
+	ttl       time.Duration
+
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+}
+
+// BeginReadOnly begins a read-only transaction reading at ts, or at the current
+// timestamp if ts is zero. It fails if the data at ts is already garbage
+// collected.
+func (s *KVStore) BeginReadOnly(ctx context.Context, ts uint64, opts ...ReadOnlyOpt) (*ReadOnlyTxn, error) {
+	opt := &readOnlyOption{ttl: DefReadOnlyServiceSafePointTTL}
+	for _, o := range opts {
+		o(opt)
+	}
+	// PD removes the service safe point whose TTL is less than a second.
+	if opt.ttl < time.Second {
+		opt.ttl = time.Second
+	}
+	if opt.serviceID == "" {
+		opt.serviceID = "client-go-read-only-" + uuid.NewString()
+	}
+	if ts == 0 {
+		var err error
+		if ts, err = s.CurrentTimestamp(oracle.GlobalTxnScope); err != nil {
+			return nil, err
+		}
+	}
+
+	txn := &ReadOnlyTxn{
+		store:     s,
+		startTS:   ts,
+		snapshot:  s.GetSnapshot(ts),
+		serviceID: opt.serviceID,
+		ttl:       opt.ttl,
+	}
+	if err := txn.keepAlive(ctx); err != nil {
+		txn.releaseSafePoint()
+		return nil, err
+	}
+	var loopCtx context.Context
+	loopCtx, txn.cancel = context.WithCancel(s.ctx)
+	s.wg.Add(1)
+	go txn.keepAliveLoop(loopCtx)
+	return txn, nil
+}
+
+// StartTS returns the timestamp the transaction reads at.
+func (txn *ReadOnlyTxn) StartTS() uint64 {
+	return txn.startTS
+}
+
+// ServiceID returns the service ID of the service safe point.
+func (txn *ReadOnlyTxn) ServiceID() string {
+	return txn.serviceID
+}
+
+// Get gets the value of a key.
+func (txn *ReadOnlyTxn) Get(ctx context.Context, k []byte) ([]byte, error) {
+	return txn.snapshot.Get(ctx, k)
+}
+
+// BatchGet gets the values of the keys. The keys not found are absent in the
+// result.
+func (txn *ReadOnlyTxn) BatchGet(ctx context.Context, keys [][]byte) (map[string][]byte, error) {
+	return txn.snapshot.BatchGet(ctx, keys)
+}
+
+// Iter returns an iterator over [k, upperBound).
+func (txn *ReadOnlyTxn) Iter(k []byte, upperBound []byte) (Iterator, error) {
+	return txn.snapshot.Iter(k, upperBound)
+}
+
+// IterReverse returns a reversed iterator over [lowerBound, k).
+func (txn *ReadOnlyTxn) IterReverse(k, lowerBound []byte) (Iterator, error) {
+	return txn.snapshot.IterReverse(k, lowerBound)
+}
+
+// Close stops keeping the service safe point alive and removes it.
+func (txn *ReadOnlyTxn) Close() {
+	txn.closeOnce.Do(func() {
+		txn.cancel()
+		txn.releaseSafePoint()
+	})
+}
+
+// keepAlive registers or refreshes the service safe point, and fails if GC has
+// gone beyond the timestamp of the transaction.
+func (txn *ReadOnlyTxn) keepAlive(ctx context.Context) error {
+	ts := txn.StartTS()
+	minSafePoint, err := txn.store.pdClient.UpdateServiceGCSafePoint(ctx, txn.serviceID, int64(txn.ttl/time.Second), ts)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if minSafePoint > ts {
+		return errors.Errorf("read-only txn: ts %d is behind the GC safe point %d", ts, minSafePoint)
+	}
+	return nil
+}
+
+func (txn *ReadOnlyTxn) keepAliveLoop(ctx context.Context) {
+	defer txn.store.wg.Done()
+	ticker := time.NewTicker(txn.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := txn.keepAlive(ctx); err != nil && ctx.Err() == nil {
+				logutil.BgLogger().Warn("failed to keep the service safe point of read-only txn alive",
+					zap.String("serviceID", txn.serviceID), zap.Uint64("startTS", txn.StartTS()), zap.Error(err))
+			}
+		}
+	}
+}
+
+func (txn *ReadOnlyTxn) releaseSafePoint() {
+	// A zero TTL removes the service safe point.
+	if _, err := txn.store.pdClient.UpdateServiceGCSafePoint(context.Background(), txn.serviceID, 0, txn.StartTS()); err != nil {
+		logutil.BgLogger().Warn("failed to remove the service safe point of read-only txn",
+			zap.String("serviceID", txn.serviceID), zap.Error(err))
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/oracle"
+)
+
+func TestBeginReadOnly(t *testing.T) {
+	store, _, err := newMockStore(nil)
+	require.Nil(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	put := func(value string) {
+		txn, err := store.Begin()
+		require.Nil(t, err)
+		require.Nil(t, txn.Set([]byte("k"), []byte(value)))
+		require.Nil(t, txn.Commit(ctx))
+	}
+	put("v1")
+	ts, err := store.CurrentTimestamp(oracle.GlobalTxnScope)
+	require.Nil(t, err)
+	put("v2")
+
+	txn, err := store.BeginReadOnly(ctx, ts, WithServiceID("reader"))
+	require.Nil(t, err)
+	require.Equal(t, ts, txn.StartTS())
+	v, err := txn.Get(ctx, []byte("k"))
+	require.Nil(t, err)
+	require.Equal(t, []byte("v1"), v)
+
+	// The service safe point keeps GC from going beyond the transaction.
+	pdClient := store.GetPDClient()
+	minSafePoint, err := pdClient.UpdateServiceGCSafePoint(ctx, "gc_worker", 100, ts+100)
+	require.Nil(t, err)
+	require.Equal(t, ts, minSafePoint)
+
+	txn.Close()
+	minSafePoint, err = pdClient.UpdateServiceGCSafePoint(ctx, "gc_worker", 100, ts+100)
+	require.Nil(t, err)
+	require.Equal(t, ts+100, minSafePoint)
+
+	_, err = store.BeginReadOnly(ctx, ts)
+	require.ErrorContains(t, err, "behind the GC safe point")
+}
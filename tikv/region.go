@@ -63,6 +63,34 @@ type RegionCache = locate.RegionCache
 // KeyLocation is the region and range that a key is located.
 type KeyLocation = locate.KeyLocation
 
+// WritePacingStats is the stats of the write pacing of a store.
+type WritePacingStats = locate.WritePacingStats
+
+// HotRegionConfig is the thresholds of the hot regions detected by the client, see WithHotRegionDetection.
+type HotRegionConfig = locate.HotRegionConfig
+
+// HotRegion is a region whose load measured by the client exceeds the thresholds.
+type HotRegion = locate.HotRegion
+
+// Event is a structured event observed by the store, see KVStore.Events.
+type Event = locate.Event
+
+// EventType is the type of an Event.
+type EventType = locate.EventType
+
+// The types of the events.
+const (
+	EventRegionInvalidated = locate.EventRegionInvalidated
+	EventLeaderChanged     = locate.EventLeaderChanged
+	EventStoreStateChanged = locate.EventStoreStateChanged
+	EventLockResolved      = locate.EventLockResolved
+	EventSafePointUpdated  = locate.EventSafePointUpdated
+)
+
+// EventsBufferSize is the size of the buffer of the channels returned by
+// KVStore.Events.
+const EventsBufferSize = 1024
+
 // RPCCancellerCtxKey is context key attach rpc send cancelFunc collector to ctx.
 type RPCCancellerCtxKey = locate.RPCCancellerCtxKey
 
@@ -86,6 +114,16 @@ type RegionRequestSender = locate.RegionRequestSender
 // StoreSelectorOption configures storeSelectorOp.
 type StoreSelectorOption = locate.StoreSelectorOption
 
+// ReplicaSelectorPolicy replaces the built-in selection of the replicas the requests to TiKV are sent to, see
+// WithReplicaSelectorPolicy.
+type ReplicaSelectorPolicy = locate.ReplicaSelectorPolicy
+
+// ReplicaStatus is the status of a replica of the region, see ReplicaSelectorPolicy.
+type ReplicaStatus = locate.ReplicaStatus
+
+// ReplicaAttempt is the outcome of sending a request to a replica, see ReplicaSelectorPolicy.
+type ReplicaAttempt = locate.ReplicaAttempt
+
 // RegionRequestRuntimeStats records the runtime stats of send region requests.
 type RegionRequestRuntimeStats = locate.RegionRequestRuntimeStats
 
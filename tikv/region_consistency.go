@@ -0,0 +1,205 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"bytes"
+	"context"
+	"slices"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pkg/errors"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/locate"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/util/redact"
+)
+
+const consistencyCheckScanBatchSize = 256
+
+// ReplicaScanResult is the data read from one replica of the region.
+type ReplicaScanResult struct {
+	Peer   *metapb.Peer
+	Addr   string
+	Keys   [][]byte
+	Values [][]byte
+	// Err is the error met when reading the replica, e.g. a region error or a
+	// lock. The replica is excluded from the comparison if Err is not nil.
+	Err error
+}
+
+// ReplicaMismatch is a key whose value differs between replicas. Values maps
+// peer ID to the value read from the peer, a missing entry means the key
+// doesn't exist on the peer.
+type ReplicaMismatch struct {
+	Key    []byte
+	Values map[uint64][]byte
+}
+
+// RegionConsistencyReport is the result of CheckRegionConsistency.
+type RegionConsistencyReport struct {
+	Region   *metapb.Region
+	StartKey []byte
+	EndKey   []byte
+	ReadTS   uint64
+	Replicas []*ReplicaScanResult
+	// Mismatches is sorted by key.
+	Mismatches []*ReplicaMismatch
+}
+
+// Consistent returns whether all the replicas that are read successfully
+// return the same data.
+func (r *RegionConsistencyReport) Consistent() bool {
+	return len(r.Mismatches) == 0
+}
+
+// CheckRegionConsistency reads [startKey, endKey) from every peer, including
+// followers and learners, of the region containing startKey at ts, and diffs
+// the results. The range is clipped to the region. An empty endKey means the
+// end of the region. It's an admin API to investigate suspected replica
+// divergence; reads on non-leader peers are sent as replica reads.
+func (s *KVStore) CheckRegionConsistency(ctx context.Context, startKey, endKey []byte, ts uint64) (*RegionConsistencyReport, error) {
+	region, err := s.pdClient.GetRegion(locate.WithPDCircuitBreaker(ctx), startKey)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if region == nil || region.Meta == nil {
+		return nil, errors.Errorf("region not found for key %s", redact.Key(startKey))
+	}
+	meta := region.Meta
+	if len(meta.GetEndKey()) > 0 && (len(endKey) == 0 || bytes.Compare(endKey, meta.GetEndKey()) > 0) {
+		endKey = meta.GetEndKey()
+	}
+	report := &RegionConsistencyReport{
+		Region:   meta,
+		StartKey: startKey,
+		EndKey:   endKey,
+		ReadTS:   ts,
+	}
+	for _, peer := range meta.GetPeers() {
+		res := &ReplicaScanResult{Peer: peer}
+		store, err := s.pdClient.GetStore(ctx, peer.GetStoreId())
+		if err != nil {
+			res.Err = errors.WithStack(err)
+		} else if store == nil {
+			res.Err = errors.Errorf("store %d not found", peer.GetStoreId())
+		} else {
+			res.Addr = store.GetAddress()
+			isLeader := region.Leader != nil && region.Leader.GetId() == peer.GetId()
+			res.Keys, res.Values, res.Err = s.scanReplica(ctx, meta, peer, res.Addr, !isLeader, startKey, endKey, ts)
+		}
+		report.Replicas = append(report.Replicas, res)
+	}
+	report.Mismatches = diffReplicas(report.Replicas)
+	return report, nil
+}
+
+func (s *KVStore) scanReplica(ctx context.Context, region *metapb.Region, peer *metapb.Peer, addr string, replicaRead bool, startKey, endKey []byte, ts uint64) (keys, values [][]byte, err error) {
+	for {
+		req := tikvrpc.NewRequest(tikvrpc.CmdScan, &kvrpcpb.ScanRequest{
+			StartKey: startKey,
+			EndKey:   endKey,
+			Limit:    consistencyCheckScanBatchSize,
+			Version:  ts,
+		})
+		req.ReplicaRead = replicaRead
+		if err = tikvrpc.SetContextNoAttach(req, region, peer); err != nil {
+			return nil, nil, err
+		}
+		resp, err := s.GetTiKVClient().SendRequest(ctx, addr, req, ReadTimeoutMedium)
+		if err != nil {
+			return nil, nil, err
+		}
+		regionErr, err := resp.GetRegionError()
+		if err != nil {
+			return nil, nil, err
+		}
+		if regionErr != nil {
+			return nil, nil, errors.Errorf("region error: %s", regionErr.String())
+		}
+		if resp.Resp == nil {
+			return nil, nil, errors.WithStack(tikverr.ErrBodyMissing)
+		}
+		scanResp := resp.Resp.(*kvrpcpb.ScanResponse)
+		if keyErr := scanResp.GetError(); keyErr != nil {
+			return nil, nil, errors.Errorf("key error: %s", keyErr.String())
+		}
+		for _, pair := range scanResp.GetPairs() {
+			if keyErr := pair.GetError(); keyErr != nil {
+				return nil, nil, errors.Errorf("key error: %s", keyErr.String())
+			}
+			keys = append(keys, pair.GetKey())
+			values = append(values, pair.GetValue())
+		}
+		if len(scanResp.GetPairs()) < consistencyCheckScanBatchSize {
+			return keys, values, nil
+		}
+		startKey = kv.NextKey(keys[len(keys)-1])
+	}
+}
+
+func diffReplicas(replicas []*ReplicaScanResult) []*ReplicaMismatch {
+	var (
+		valid   []*ReplicaScanResult
+		allKeys [][]byte
+		seen    = make(map[string]struct{})
+	)
+	for _, r := range replicas {
+		if r.Err != nil {
+			continue
+		}
+		valid = append(valid, r)
+		for _, k := range r.Keys {
+			if _, ok := seen[string(k)]; !ok {
+				seen[string(k)] = struct{}{}
+				allKeys = append(allKeys, k)
+			}
+		}
+	}
+	if len(valid) < 2 {
+		return nil
+	}
+	replicaValues := make([]map[string][]byte, len(valid))
+	for i, r := range valid {
+		replicaValues[i] = make(map[string][]byte, len(r.Keys))
+		for j, k := range r.Keys {
+			replicaValues[i][string(k)] = r.Values[j]
+		}
+	}
+	var mismatches []*ReplicaMismatch
+	for _, k := range allKeys {
+		consistent := true
+		values := make(map[uint64][]byte, len(valid))
+		for i, r := range valid {
+			v, ok := replicaValues[i][string(k)]
+			if ok {
+				values[r.Peer.GetId()] = v
+			}
+			first, firstOK := replicaValues[0][string(k)]
+			if ok != firstOK || !bytes.Equal(v, first) {
+				consistent = false
+			}
+		}
+		if !consistent {
+			mismatches = append(mismatches, &ReplicaMismatch{Key: k, Values: values})
+		}
+	}
+	slices.SortFunc(mismatches, func(a, b *ReplicaMismatch) int {
+		return bytes.Compare(a.Key, b.Key)
+	})
+	return mismatches
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+type divergedReplicaClient struct {
+	Client
+	addr string
+}
+
+func (c *divergedReplicaClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	resp, err := c.Client.SendRequest(ctx, addr, req, timeout)
+	if err != nil || addr != c.addr || req.Type != tikvrpc.CmdScan {
+		return resp, err
+	}
+	scanResp := resp.Resp.(*kvrpcpb.ScanResponse)
+	for _, pair := range scanResp.Pairs {
+		if string(pair.Key) == "k2" {
+			pair.Value = []byte("diverged")
+		}
+	}
+	return resp, nil
+}
+
+func (s *testKVSuite) TestCheckRegionConsistency() {
+	ctx := context.Background()
+	txn, err := s.store.Begin()
+	s.Require().Nil(err)
+	for _, k := range []string{"k1", "k2", "k3"} {
+		s.Require().Nil(txn.Set([]byte(k), []byte("v"+k)))
+	}
+	s.Require().Nil(txn.Commit(ctx))
+	ts, err := s.store.CurrentTimestamp(oracle.GlobalTxnScope)
+	s.Require().Nil(err)
+
+	report, err := s.store.CheckRegionConsistency(ctx, []byte("k"), []byte("k3"), ts)
+	s.Require().Nil(err)
+	s.Require().Len(report.Replicas, 2)
+	for _, r := range report.Replicas {
+		s.Require().Nil(r.Err)
+		s.Require().Len(r.Keys, 2)
+	}
+	s.Require().True(report.Consistent())
+
+	s.store.SetTiKVClient(&divergedReplicaClient{Client: s.store.GetTiKVClient(), addr: s.storeAddr(s.tiflashStoreID)})
+	report, err = s.store.CheckRegionConsistency(ctx, nil, nil, ts)
+	s.Require().Nil(err)
+	s.Require().False(report.Consistent())
+	s.Require().Len(report.Mismatches, 1)
+	s.Require().Equal([]byte("k2"), report.Mismatches[0].Key)
+	s.Require().Len(report.Mismatches[0].Values, 2)
+	for _, r := range report.Replicas {
+		v := report.Mismatches[0].Values[r.Peer.GetId()]
+		if r.Addr == s.storeAddr(s.tiflashStoreID) {
+			s.Require().Equal([]byte("diverged"), v)
+		} else {
+			s.Require().Equal([]byte("vk2"), v)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/oracle"
+)
+
+func TestStaleReadMaxStaleness(t *testing.T) {
+	store, _, err := newMockStore(nil)
+	require.Nil(t, err)
+	defer store.Close()
+
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	startTS := txn.StartTS()
+	startTime := oracle.GetTimeFromTS(startTS)
+
+	// The safe ts is fresher than the max staleness allows.
+	safeTS := oracle.GoTimeToTS(startTime.Add(-time.Second))
+	store.setMinSafeTS(oracle.GlobalTxnScope, safeTS)
+	require.Nil(t, txn.SetStaleReadMaxStaleness(time.Minute))
+	require.Equal(t, safeTS, txn.StartTS())
+
+	// The safe ts falls behind the max staleness.
+	txn, err = store.Begin()
+	require.Nil(t, err)
+	startTS = txn.StartTS()
+	require.Nil(t, txn.SetStaleReadMaxStaleness(time.Millisecond))
+	require.Equal(t, oracle.GoTimeToTS(oracle.GetTimeFromTS(startTS).Add(-time.Millisecond)), txn.StartTS())
+
+	// The read ts never exceeds the start ts.
+	txn, err = store.Begin()
+	require.Nil(t, err)
+	startTS = txn.StartTS()
+	store.setMinSafeTS(oracle.GlobalTxnScope, startTS+1)
+	require.Nil(t, txn.SetStaleReadMaxStaleness(0))
+	require.Equal(t, startTS, txn.StartTS())
+
+	txn, err = store.Begin()
+	require.Nil(t, err)
+	require.Nil(t, txn.Set([]byte("k"), []byte("v")))
+	require.NotNil(t, txn.SetStaleReadMaxStaleness(time.Second))
+}
@@ -87,13 +87,16 @@ func NewTestTiKVStore(client Client, pdClient pd.Client, clientHijack func(Clien
 	uid := uuid.New().String()
 	spkv := NewMockSafePointKV()
 	tikvStore, err := NewKVStore(uid, pdCli, spkv, client, opt...)
+	if err != nil {
+		return nil, err
+	}
 
 	if txnLocalLatches > 0 {
 		tikvStore.EnableTxnLocalLatches(txnLocalLatches)
 	}
 
 	tikvStore.mock = true
-	return tikvStore, err
+	return tikvStore, nil
 }
 
 // NewTestTiKVStore creates a test store with Option
@@ -0,0 +1,138 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testing creates the stores backed by an embedded mock TiKV cluster,
+// for the hermetic tests of the projects using the client.
+package testing
+
+import (
+	"github.com/tikv/client-go/v2/testutils"
+	"github.com/tikv/client-go/v2/tikv"
+)
+
+type options struct {
+	dataDir     string
+	stores      int
+	splitKeys   [][]byte
+	coprHandler testutils.CoprRPCHandler
+	latches     uint
+	storeOpts   []tikv.Option
+	onCluster   func(*testutils.MockCluster)
+}
+
+// Opt configures the store created by NewStore.
+type Opt func(*options)
+
+// WithDataDir keeps the data of the testing store in dir, instead of the
+// memory by default.
+func WithDataDir(dir string) Opt {
+	return func(o *options) {
+		o.dataDir = dir
+	}
+}
+
+// WithStores sets the number of the stores of the testing cluster, 1 by
+// default. Every region has a peer in each store, and the peer in the first
+// store is the leader, so follower reads can be tested with more stores.
+func WithStores(n int) Opt {
+	return func(o *options) {
+		o.stores = n
+	}
+}
+
+// WithSplitKeys splits the testing cluster into regions at the keys, so the
+// requests crossing the regions can be tested. The keys must be in ascending
+// order.
+func WithSplitKeys(keys ...[]byte) Opt {
+	return func(o *options) {
+		o.splitKeys = keys
+	}
+}
+
+// WithCoprHandler sets the handler of the coprocessor requests, which fail
+// without it.
+func WithCoprHandler(h testutils.CoprRPCHandler) Opt {
+	return func(o *options) {
+		o.coprHandler = h
+	}
+}
+
+// WithTxnLocalLatches enables the local latches of the transactions with the
+// size, see tikv.KVStore.EnableTxnLocalLatches.
+func WithTxnLocalLatches(size uint) Opt {
+	return func(o *options) {
+		o.latches = size
+	}
+}
+
+// WithKVStoreOptions passes the options to the KVStore, as tikv.NewKVStore
+// does.
+func WithKVStoreOptions(opts ...tikv.Option) Opt {
+	return func(o *options) {
+		o.storeOpts = append(o.storeOpts, opts...)
+	}
+}
+
+// WithCluster calls f with the mock cluster behind the testing store once it's
+// bootstrapped, to manipulate it in the tests, e.g. splitting the regions,
+// stopping the stores and injecting the faults.
+func WithCluster(f func(cluster *testutils.MockCluster)) Opt {
+	return func(o *options) {
+		o.onCluster = f
+	}
+}
+
+// NewStore creates a KVStore backed by an embedded mock TiKV cluster and
+// a mock PD, which needs no server, so that the projects using the client can
+// run hermetic tests through the public API without importing the internal
+// packages. The store is closed as usual by tikv.KVStore.Close, which releases
+// the mock cluster as well.
+//
+// The mock cluster supports:
+//   - the transactions, optimistic and pessimistic, with the snapshot and the
+//     read committed isolation levels, the lock resolving and the MVCC GC;
+//   - the region splits and the follower reads with WithStores and
+//     WithSplitKeys;
+//   - the coprocessor requests with WithCoprHandler;
+//   - the faults of the cluster with WithCluster.
+//
+// The async commit and the 1PC are not supported, the transactions enabling
+// them fall back to the 2PC as they do with the stores not supporting them.
+// The TiFlash, the MPP and the resource control aren't supported either.
+func NewStore(opts ...Opt) (*tikv.KVStore, error) {
+	o := options{stores: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	client, cluster, pdClient, err := testutils.NewMockTiKV(o.dataDir, o.coprHandler)
+	if err != nil {
+		return nil, err
+	}
+	storeIDs, _, regionID, _ := testutils.BootstrapWithMultiStores(cluster, o.stores)
+	for _, key := range o.splitKeys {
+		newRegionID := cluster.AllocID()
+		peerIDs := cluster.AllocIDs(len(storeIDs))
+		cluster.Split(regionID, newRegionID, key, peerIDs, peerIDs[0])
+		regionID = newRegionID
+	}
+	if o.onCluster != nil {
+		o.onCluster(cluster)
+	}
+	store, err := tikv.NewTestTiKVStore(client, pdClient, nil, nil, o.latches, o.storeOpts...)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return store, nil
+}
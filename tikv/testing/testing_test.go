@@ -0,0 +1,51 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/testutils"
+	tikvtesting "github.com/tikv/client-go/v2/tikv/testing"
+)
+
+func TestNewStore(t *testing.T) {
+	var cluster *testutils.MockCluster
+	store, err := tikvtesting.NewStore(
+		tikvtesting.WithStores(3),
+		tikvtesting.WithSplitKeys([]byte("b"), []byte("c")),
+		tikvtesting.WithCluster(func(c *testutils.MockCluster) { cluster = c }),
+	)
+	require.Nil(t, err)
+	defer store.Close()
+	require.Len(t, cluster.GetAllStores(), 3)
+	require.Len(t, cluster.GetAllRegions(), 3)
+
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	for _, k := range []string{"a", "b", "c"} {
+		require.Nil(t, txn.Set([]byte(k), []byte(k)))
+	}
+	require.Nil(t, txn.Commit(context.Background()))
+
+	ts, err := store.CurrentTimestamp(oracle.GlobalTxnScope)
+	require.Nil(t, err)
+	m, err := store.GetSnapshot(ts).BatchGet(context.Background(), [][]byte{[]byte("a"), []byte("b"), []byte("c")})
+	require.Nil(t, err)
+	require.Equal(t, map[string][]byte{"a": []byte("a"), "b": []byte("b"), "c": []byte("c")}, m)
+}
@@ -0,0 +1,167 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/txnkv/rangetask"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	writeBatchConcurrency = 16
+	// writeBatchMaxConflictRetries is the number of times the writes of a
+	// region are retried on write conflicts.
+	writeBatchMaxConflictRetries = 3
+)
+
+// WriteBatch is a set of mutations applied by KVStore.WriteBatch. It's not
+// safe for concurrent use.
+type WriteBatch struct {
+	// mutations maps the keys to the values, nil values are deletions.
+	mutations map[string][]byte
+}
+
+// NewWriteBatch creates an empty WriteBatch.
+func NewWriteBatch() *WriteBatch {
+	return &WriteBatch{mutations: make(map[string][]byte)}
+}
+
+// Set sets the value of the key, which must not be empty. It overwrites the
+// previous mutation of the key in the batch.
+func (b *WriteBatch) Set(key, value []byte) {
+	if value == nil {
+		value = []byte{}
+	}
+	b.mutations[string(key)] = value
+}
+
+// Delete deletes the key. It overwrites the previous mutation of the key in
+// the batch.
+func (b *WriteBatch) Delete(key []byte) {
+	b.mutations[string(key)] = nil
+}
+
+// Len returns the number of the keys mutated by the batch.
+func (b *WriteBatch) Len() int {
+	return len(b.mutations)
+}
+
+// WriteBatchError is returned by KVStore.WriteBatch if some regions fail to
+// apply their mutations. The mutations of the other keys are applied.
+type WriteBatchError struct {
+	// FailedKeys are the keys whose mutations are not applied, in order.
+	FailedKeys [][]byte
+	// Err is the first error of the failed regions.
+	Err error
+}
+
+func (e *WriteBatchError) Error() string {
+	return fmt.Sprintf("write batch failed to apply %d keys: %v", len(e.FailedKeys), e.Err)
+}
+
+// Unwrap returns the first error of the failed regions.
+func (e *WriteBatchError) Unwrap() error {
+	return e.Err
+}
+
+// WriteBatch applies the mutations of the batch grouped by the regions of the
+// keys. The mutations of each region are committed by a transaction of their
+// own with 1PC enabled, so they are applied atomically, usually in a single
+// round trip. The batch as a whole is NOT atomic or isolated: the regions are
+// committed independently and concurrently, the readers can observe some of
+// them applied but not the others, and a failure leaves the mutations of the
+// other regions applied, which is reported by a WriteBatchError. It's for the
+// tools like migrations which need the throughput and can tolerate the
+// region-level atomicity. A region whose writes conflict with the concurrent
+// transactions is retried with a newer start ts a few times, so the batch
+// overwrites the keys like the last writer.
+func (s *KVStore) WriteBatch(ctx context.Context, batch *WriteBatch) error {
+	if batch.Len() == 0 {
+		return nil
+	}
+	keys := make([][]byte, 0, batch.Len())
+	for k := range batch.mutations {
+		keys = append(keys, []byte(k))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+	groups, _, err := s.regionCache.GroupKeysByRegion(rangetask.NewLocateRegionBackoffer(ctx), keys, nil)
+	if err != nil {
+		return err
+	}
+
+	var (
+		mu     sync.Mutex
+		failed [][]byte
+		first  error
+		eg     errgroup.Group
+	)
+	eg.SetLimit(writeBatchConcurrency)
+	for _, regionKeys := range groups {
+		eg.Go(func() error {
+			if err := s.writeBatchRegion(ctx, batch, regionKeys); err != nil {
+				mu.Lock()
+				failed = append(failed, regionKeys...)
+				if first == nil {
+					first = err
+				}
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	_ = eg.Wait()
+	if first == nil {
+		return nil
+	}
+	sort.Slice(failed, func(i, j int) bool {
+		return bytes.Compare(failed[i], failed[j]) < 0
+	})
+	return errors.WithStack(&WriteBatchError{FailedKeys: failed, Err: first})
+}
+
+// writeBatchRegion commits the mutations of the keys in a region.
+func (s *KVStore) writeBatchRegion(ctx context.Context, batch *WriteBatch, keys [][]byte) error {
+	for retries := 0; ; retries++ {
+		txn, err := s.Begin()
+		if err != nil {
+			return err
+		}
+		txn.SetEnable1PC(true)
+		for _, k := range keys {
+			if v := batch.mutations[string(k)]; v != nil {
+				err = txn.Set(k, v)
+			} else {
+				err = txn.Delete(k)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		err = txn.Commit(ctx)
+		if err == nil || !tikverr.IsErrWriteConflict(err) || retries >= writeBatchMaxConflictRetries {
+			return err
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/oracle"
+)
+
+func TestWriteBatch(t *testing.T) {
+	store, _, err := newMockStore([][]byte{[]byte("b"), []byte("c")})
+	require.Nil(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	require.Nil(t, txn.Set([]byte("a2"), []byte("old")))
+	require.Nil(t, txn.Commit(ctx))
+
+	batch := NewWriteBatch()
+	batch.Set([]byte("a1"), []byte("a1"))
+	batch.Delete([]byte("a2"))
+	batch.Set([]byte("b1"), []byte("b1"))
+	batch.Set([]byte("c1"), []byte("x"))
+	batch.Set([]byte("c1"), []byte("c1"))
+	require.Equal(t, 4, batch.Len())
+	require.Nil(t, store.WriteBatch(ctx, batch))
+
+	read := func() map[string][]byte {
+		ts, err := store.CurrentTimestamp(oracle.GlobalTxnScope)
+		require.Nil(t, err)
+		m, err := store.GetSnapshot(ts).BatchGet(ctx, [][]byte{[]byte("a1"), []byte("a2"), []byte("b1"), []byte("b2"), []byte("c1")})
+		require.Nil(t, err)
+		return m
+	}
+	require.Equal(t, map[string][]byte{"a1": []byte("a1"), "b1": []byte("b1"), "c1": []byte("c1")}, read())
+
+	// The regions fail independently, and the others are applied.
+	batch = NewWriteBatch()
+	batch.Set([]byte("a1"), []byte("a1-2"))
+	batch.Set([]byte("b1"), nil)
+	batch.Set([]byte("b2"), []byte("b2"))
+	err = store.WriteBatch(ctx, batch)
+	var batchErr *WriteBatchError
+	require.ErrorAs(t, err, &batchErr)
+	require.Equal(t, [][]byte{[]byte("b1"), []byte("b2")}, batchErr.FailedKeys)
+	require.Equal(t, map[string][]byte{"a1": []byte("a1-2"), "b1": []byte("b1"), "c1": []byte("c1")}, read())
+}
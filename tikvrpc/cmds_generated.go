@@ -197,6 +197,15 @@ func patchCmdCtx(req *Request, cmd CmdType, ctx *kvrpcpb.Context) bool {
 			req.Req = &cmd
 		}
 		req.rev++
+	case CmdRawBatchScan:
+		if req.rev == 0 {
+			req.RawBatchScan().Context = ctx
+		} else {
+			cmd := *req.RawBatchScan()
+			cmd.Context = ctx
+			req.Req = &cmd
+		}
+		req.rev++
 	case CmdRawGetKeyTTL:
 		if req.rev == 0 {
 			req.RawGetKeyTTL().Context = ctx
@@ -427,6 +436,8 @@ func isValidReqType(cmd CmdType) bool {
 		return true
 	case CmdRawScan:
 		return true
+	case CmdRawBatchScan:
+		return true
 	case CmdRawGetKeyTTL:
 		return true
 	case CmdRawCompareAndSwap:
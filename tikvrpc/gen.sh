@@ -48,6 +48,7 @@ cmds=(
   RawBatchDelete
   RawDeleteRange
   RawScan
+  RawBatchScan
   RawGetKeyTTL
   RawCompareAndSwap
   RawChecksum
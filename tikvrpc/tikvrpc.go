@@ -88,6 +88,7 @@ const (
 	CmdRawGetKeyTTL
 	CmdRawCompareAndSwap
 	CmdRawChecksum
+	CmdRawBatchScan
 
 	CmdUnsafeDestroyRange
 
@@ -170,6 +171,8 @@ func (t CmdType) String() string {
 		return "RawDeleteRange"
 	case CmdRawScan:
 		return "RawScan"
+	case CmdRawBatchScan:
+		return "RawBatchScan"
 	case CmdRawChecksum:
 		return "RawChecksum"
 	case CmdRawGetKeyTTL:
@@ -266,10 +269,21 @@ type Request struct {
 	InputRequestSource string
 	// AccessLocationAttr indicates the request is sent to a different zone.
 	AccessLocation kv.AccessLocationType
+	// WriteToken identifies a write among its retries, so that it's applied at most once if the response of a
+	// previous attempt is lost, e.g. after a timeout. It's honored by mocktikv, while TiKV doesn't support it yet.
+	WriteToken WriteToken
 	// rev represents the revision of the request, it's increased when `Req.Context` gets patched.
 	rev uint32
 }
 
+// WriteToken identifies a write request of a client. The zero value means the request has no token.
+type WriteToken struct {
+	// ClientID is a random ID of the client sending the request.
+	ClientID uint64
+	// Seq is increased for every write of the client.
+	Seq uint64
+}
+
 // NewRequest returns new kv rpc request.
 func NewRequest(typ CmdType, pointer interface{}, ctxs ...kvrpcpb.Context) *Request {
 	if len(ctxs) > 0 {
@@ -432,6 +446,11 @@ func (req *Request) UnsafeDestroyRange() *kvrpcpb.UnsafeDestroyRangeRequest {
 	return req.Req.(*kvrpcpb.UnsafeDestroyRangeRequest)
 }
 
+// RawBatchScan returns RawBatchScanRequest in request.
+func (req *Request) RawBatchScan() *kvrpcpb.RawBatchScanRequest {
+	return req.Req.(*kvrpcpb.RawBatchScanRequest)
+}
+
 // RawGetKeyTTL returns RawGetKeyTTLRequest in request.
 func (req *Request) RawGetKeyTTL() *kvrpcpb.RawGetKeyTTLRequest {
 	return req.Req.(*kvrpcpb.RawGetKeyTTLRequest)
@@ -637,6 +656,8 @@ func (req *Request) ToBatchCommandsRequest() *tikvpb.BatchCommandsRequest_Reques
 		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_RawDeleteRange{RawDeleteRange: req.RawDeleteRange()}}
 	case CmdRawScan:
 		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_RawScan{RawScan: req.RawScan()}}
+	case CmdRawBatchScan:
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_RawBatchScan{RawBatchScan: req.RawBatchScan()}}
 	case CmdCop:
 		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_Coprocessor{Coprocessor: req.Cop()}}
 	case CmdPessimisticLock:
@@ -720,6 +741,8 @@ func FromBatchCommandsResponse(res *tikvpb.BatchCommandsResponse_Response) (*Res
 		return &Response{Resp: res.RawDeleteRange}, nil
 	case *tikvpb.BatchCommandsResponse_Response_RawScan:
 		return &Response{Resp: res.RawScan}, nil
+	case *tikvpb.BatchCommandsResponse_Response_RawBatchScan:
+		return &Response{Resp: res.RawBatchScan}, nil
 	case *tikvpb.BatchCommandsResponse_Response_Coprocessor:
 		return &Response{Resp: res.Coprocessor}, nil
 	case *tikvpb.BatchCommandsResponse_Response_PessimisticLock:
@@ -927,6 +950,10 @@ func GenRegionErrorResp(req *Request, e *errorpb.Error) (*Response, error) {
 		p = &kvrpcpb.UnsafeDestroyRangeResponse{
 			RegionError: e,
 		}
+	case CmdRawBatchScan:
+		p = &kvrpcpb.RawBatchScanResponse{
+			RegionError: e,
+		}
 	case CmdGetKeyTTL:
 		p = &kvrpcpb.RawGetKeyTTLResponse{
 			RegionError: e,
@@ -1097,6 +1124,8 @@ func CallRPC(ctx context.Context, client tikvpb.TikvClient, req *Request) (*Resp
 		resp.Resp, err = client.RawScan(ctx, req.RawScan())
 	case CmdUnsafeDestroyRange:
 		resp.Resp, err = client.UnsafeDestroyRange(ctx, req.UnsafeDestroyRange())
+	case CmdRawBatchScan:
+		resp.Resp, err = client.RawBatchScan(ctx, req.RawBatchScan())
 	case CmdGetKeyTTL:
 		resp.Resp, err = client.RawGetKeyTTL(ctx, req.RawGetKeyTTL())
 	case CmdRawCompareAndSwap:
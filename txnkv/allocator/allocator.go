@@ -0,0 +1,198 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package allocator implements monotonically increasing ID allocation backed
+// by a TiKV key.
+package allocator
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config/retry"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/tikv"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+	"github.com/tikv/client-go/v2/util/redact"
+)
+
+const (
+	// DefaultStep is the default number of IDs cached locally by an Allocator.
+	DefaultStep uint64 = 1000
+
+	allocMaxBackoff = 20000
+)
+
+type storage interface {
+	// Begin starts a transaction.
+	Begin(opts ...tikv.TxnOption) (*transaction.KVTxn, error)
+}
+
+type option struct {
+	step uint64
+}
+
+// Opt is the option of NewAllocator.
+type Opt func(*option)
+
+// WithStep sets the number of IDs fetched from TiKV and cached locally at a
+// time.
+func WithStep(step uint64) Opt {
+	return func(opt *option) {
+		opt.step = step
+	}
+}
+
+// Allocator allocates IDs that are unique among all the allocators sharing the
+// same key. The IDs allocated by one allocator are monotonically increasing,
+// but they are not continuous: IDs cached by an allocator are skipped if the
+// allocator exits or crashes before using them up.
+//
+// The key stores the max ID ever fetched by any allocator. It's updated in a
+// transaction before any ID of the batch is handed out, so an ID is never
+// allocated twice even after crashes.
+type Allocator struct {
+	store storage
+	key   []byte
+	step  uint64
+
+	mu sync.Mutex
+	// IDs in (base, end] are cached.
+	base uint64
+	end  uint64
+}
+
+// NewAllocator creates an Allocator that persists its state in key.
+func NewAllocator(store storage, key []byte, opts ...Opt) *Allocator {
+	opt := &option{step: DefaultStep}
+	for _, o := range opts {
+		o(opt)
+	}
+	if opt.step == 0 {
+		opt.step = 1
+	}
+	return &Allocator{
+		store: store,
+		key:   key,
+		step:  opt.step,
+	}
+}
+
+// Alloc allocates an ID.
+func (a *Allocator) Alloc(ctx context.Context) (uint64, error) {
+	return a.AllocN(ctx, 1)
+}
+
+// AllocN allocates n continuous IDs and returns the first one, i.e. the IDs
+// allocated are [first, first+n).
+func (a *Allocator) AllocN(ctx context.Context, n uint64) (first uint64, err error) {
+	if n == 0 {
+		return 0, errors.New("the number of IDs to allocate should be positive")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.end-a.base < n {
+		base, end, err := a.fetch(ctx, max(a.step, n))
+		if err != nil {
+			return 0, err
+		}
+		a.base, a.end = base, end
+	}
+	first = a.base + 1
+	a.base += n
+	return first, nil
+}
+
+// Rebase makes sure all the IDs allocated afterwards are greater than base. It
+// is used when IDs are allocated by others, e.g. importing data.
+func (a *Allocator) Rebase(ctx context.Context, base uint64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if base <= a.base {
+		return nil
+	}
+	if base < a.end {
+		a.base = base
+		return nil
+	}
+	err := a.update(ctx, func(current uint64) (uint64, bool, error) {
+		return base, base > current, nil
+	})
+	if err != nil {
+		return err
+	}
+	a.base, a.end = base, base
+	return nil
+}
+
+// fetch reserves step IDs in TiKV and returns the range (base, end].
+func (a *Allocator) fetch(ctx context.Context, step uint64) (base, end uint64, err error) {
+	err = a.update(ctx, func(current uint64) (uint64, bool, error) {
+		if current > math.MaxUint64-step {
+			return 0, false, errors.Errorf("ID allocator of key %s is exhausted", redact.Key(a.key))
+		}
+		base, end = current, current+step
+		return end, true, nil
+	})
+	return base, end, err
+}
+
+// update reads the persisted max ID, and writes the new value returned by f
+// if needed. It retries on write conflicts with other allocators.
+func (a *Allocator) update(ctx context.Context, f func(current uint64) (next uint64, write bool, err error)) error {
+	bo := retry.NewBackofferWithVars(ctx, allocMaxBackoff, nil)
+	for {
+		err := a.updateOnce(ctx, f)
+		if err == nil || !tikverr.IsErrWriteConflict(err) {
+			return err
+		}
+		if err = bo.Backoff(retry.BoTxnLock, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (a *Allocator) updateOnce(ctx context.Context, f func(current uint64) (uint64, bool, error)) error {
+	txn, err := a.store.Begin()
+	if err != nil {
+		return err
+	}
+	var current uint64
+	val, err := txn.Get(ctx, a.key)
+	if err == nil {
+		if len(val) != 8 {
+			txn.Rollback()
+			return errors.Errorf("invalid value of ID allocator key %s", redact.Key(a.key))
+		}
+		current = binary.BigEndian.Uint64(val)
+	} else if !tikverr.IsErrNotFound(err) {
+		txn.Rollback()
+		return err
+	}
+	next, write, err := f(current)
+	if err != nil || !write {
+		txn.Rollback()
+		return err
+	}
+	if err = txn.Set(a.key, binary.BigEndian.AppendUint64(nil, next)); err != nil {
+		txn.Rollback()
+		return err
+	}
+	return txn.Commit(ctx)
+}
@@ -17,15 +17,21 @@ package txnkv
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tikv/client-go/v2/config"
 	"github.com/tikv/client-go/v2/config/retry"
+	"github.com/tikv/client-go/v2/kv"
 	"github.com/tikv/client-go/v2/oracle"
 	"github.com/tikv/client-go/v2/tikv"
+	"github.com/tikv/client-go/v2/tikvrpc/interceptor"
 	"github.com/tikv/client-go/v2/txnkv/transaction"
+	"github.com/tikv/client-go/v2/txnkv/txnsnapshot"
 	"github.com/tikv/client-go/v2/util"
+	"github.com/tikv/client-go/v2/util/slowlog"
 )
 
 // Client is a txn client.
@@ -37,6 +43,9 @@ type option struct {
 	apiVersion   kvrpcpb.APIVersion
 	keyspaceName string
 	spKVPrefix   string
+	storeOpts    []tikv.Option
+	storeTLS     tikv.StoreTLSResolver
+	interceptors []interceptor.RPCInterceptor
 }
 
 // ClientOpt is factory to set the client options.
@@ -63,6 +72,78 @@ func WithSafePointKVPrefix(prefix string) ClientOpt {
 	}
 }
 
+// WithStoreTLS is used to override the TLS settings of the connections per
+// store. See tikv.StoreTLS for details.
+func WithStoreTLS(resolver tikv.StoreTLSResolver) ClientOpt {
+	return func(opt *option) {
+		opt.storeTLS = resolver
+	}
+}
+
+// WithInterceptors is used to run the interceptors around every RPC sent by
+// the client. See tikv.WithInterceptors for details.
+func WithInterceptors(interceptors ...interceptor.RPCInterceptor) ClientOpt {
+	return func(opt *option) {
+		opt.interceptors = interceptors
+	}
+}
+
+// WithMetricsRegisterer is used to register the client metrics to r with the
+// namespace and const labels. See tikv.WithMetricsRegisterer for details.
+func WithMetricsRegisterer(r prometheus.Registerer, namespace string, constLabels prometheus.Labels) ClientOpt {
+	return func(opt *option) {
+		opt.storeOpts = append(opt.storeOpts, tikv.WithMetricsRegisterer(r, namespace, constLabels))
+	}
+}
+
+// WithTSOBatch is used to coalesce timestamp requests from concurrent
+// transactions. See tikv.WithTSOBatch for details.
+func WithTSOBatch(maxWait time.Duration, maxBatchSize int) ClientOpt {
+	return func(opt *option) {
+		opt.storeOpts = append(opt.storeOpts, tikv.WithTSOBatch(maxWait, maxBatchSize))
+	}
+}
+
+// WithSlowLog is used to log the slow requests to TiKV. See tikv.WithSlowLog
+// for details.
+func WithSlowLog(threshold time.Duration, sink slowlog.Sink) ClientOpt {
+	return func(opt *option) {
+		opt.storeOpts = append(opt.storeOpts, tikv.WithSlowLog(threshold, sink))
+	}
+}
+
+// WithWritePacing is used to pace the writes to the stores reporting
+// flow-control signals. See tikv.WithWritePacing for details.
+func WithWritePacing() ClientOpt {
+	return func(opt *option) {
+		opt.storeOpts = append(opt.storeOpts, tikv.WithWritePacing())
+	}
+}
+
+// WithArchiveReader is used to read from r at the timestamps older than the GC
+// safe point. See tikv.WithArchiveReader for details.
+func WithArchiveReader(r txnsnapshot.ArchiveReader) ClientOpt {
+	return func(opt *option) {
+		opt.storeOpts = append(opt.storeOpts, tikv.WithArchiveReader(r))
+	}
+}
+
+// WithScanRegionPrefetch is used to load the next n regions in the background
+// during scans. See tikv.WithScanRegionPrefetch for details.
+func WithScanRegionPrefetch(n int) ClientOpt {
+	return func(opt *option) {
+		opt.storeOpts = append(opt.storeOpts, tikv.WithScanRegionPrefetch(n))
+	}
+}
+
+// WithWorkloadRouting is used to set the replicas to read from for the
+// workload classes. See tikv.WithWorkloadRouting for details.
+func WithWorkloadRouting(routing kv.WorkloadRouting) ClientOpt {
+	return func(opt *option) {
+		opt.storeOpts = append(opt.storeOpts, tikv.WithWorkloadRouting(routing))
+	}
+}
+
 // NewClient creates a txn client with pdAddrs.
 func NewClient(pdAddrs []string, opts ...ClientOpt) (*Client, error) {
 	// Apply options.
@@ -107,9 +188,9 @@ func NewClient(pdAddrs []string, opts ...ClientOpt) (*Client, error) {
 		return nil, err
 	}
 
-	rpcClient := tikv.NewRPCClient(tikv.WithSecurity(cfg.Security), tikv.WithCodec(codecCli.GetCodec()))
+	rpcClient := tikv.NewRPCClient(tikv.WithSecurity(cfg.Security), tikv.WithCodec(codecCli.GetCodec()), tikv.WithStoreTLS(opt.storeTLS), tikv.WithInterceptors(opt.interceptors...))
 
-	s, err := tikv.NewKVStore(uuid, pdClient, spkv, rpcClient)
+	s, err := tikv.NewKVStore(uuid, pdClient, spkv, rpcClient, opt.storeOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -128,3 +209,31 @@ func (c *Client) GetTimestamp(ctx context.Context) (uint64, error) {
 	}
 	return startTS, nil
 }
+
+// ResumeScan continues the scan recorded in token, which is returned by
+// KVSnapshot.ScanBatch or a previous ResumeScan, and reads at most limit
+// key-value pairs. The scan is continued at the snapshot ts recorded in the
+// token, or at the current ts if useLatestTS is true.
+func (c *Client) ResumeScan(ctx context.Context, token ScanResumeToken, limit int, useLatestTS bool) (keys, values [][]byte, next ScanResumeToken, err error) {
+	var ts uint64
+	if useLatestTS {
+		ts, err = c.GetTimestamp(ctx)
+	} else {
+		ts, err = token.StartTS()
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return c.GetSnapshot(ts).ResumeScanBatch(ctx, token, limit)
+}
+
+// WithKeyspace returns the client of the keyspace with the given name, which
+// shares the connections and the PD client with c. See
+// tikv.KVStore.WithKeyspace for details.
+func (c *Client) WithKeyspace(keyspaceName string) (*Client, error) {
+	s, err := c.KVStore.WithKeyspace(keyspaceName)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{KVStore: s}, nil
+}
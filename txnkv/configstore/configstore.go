@@ -0,0 +1,280 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package configstore stores cluster-wide client settings in TiKV, so that a
+// fleet of clients can be retuned centrally.
+package configstore
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config/retry"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/tikv"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+	"go.uber.org/zap"
+)
+
+// DefaultPrefix is the default prefix of the keys storing the settings.
+var DefaultPrefix = []byte("_tikv_client_config/")
+
+const (
+	versionLen      = 8
+	headerLen       = versionLen + 1
+	writeMaxBackoff = 20000
+
+	flagDeleted byte = 1
+)
+
+// ErrVersionMismatch is returned by CompareAndSet if the setting has been
+// changed by others.
+var ErrVersionMismatch = errors.New("config version mismatch")
+
+type storage interface {
+	// Begin starts a transaction.
+	Begin(opts ...tikv.TxnOption) (*transaction.KVTxn, error)
+}
+
+// Entry is a version of a setting. Version is increased by one on every
+// update including the deletion, and it's 0 if the setting has never been set.
+type Entry struct {
+	Name    string
+	Value   []byte
+	Version uint64
+	// Deleted is set if the setting is deleted. The tombstone of the setting
+	// is kept, so that the version keeps increasing after it's set again.
+	Deleted bool
+}
+
+type option struct {
+	prefix []byte
+}
+
+// Opt is the option of NewStore.
+type Opt func(*option)
+
+// WithPrefix sets the prefix of the keys storing the settings.
+func WithPrefix(prefix []byte) Opt {
+	return func(opt *option) {
+		opt.prefix = prefix
+	}
+}
+
+// Store reads and writes the settings stored under a reserved key prefix.
+type Store struct {
+	store  storage
+	prefix []byte
+}
+
+// NewStore creates a Store.
+func NewStore(store storage, opts ...Opt) *Store {
+	opt := &option{prefix: DefaultPrefix}
+	for _, o := range opts {
+		o(opt)
+	}
+	return &Store{store: store, prefix: opt.prefix}
+}
+
+func (s *Store) key(name string) []byte {
+	key := make([]byte, 0, len(s.prefix)+len(name))
+	key = append(key, s.prefix...)
+	return append(key, name...)
+}
+
+// The value of a setting is the version, the flag byte and the value of the
+// setting.
+func decodeEntry(name string, val []byte) (*Entry, error) {
+	if len(val) < headerLen {
+		return nil, errors.Errorf("invalid value of config %q", name)
+	}
+	return &Entry{
+		Name:    name,
+		Value:   val[headerLen:],
+		Version: binary.BigEndian.Uint64(val[:versionLen]),
+		Deleted: val[versionLen]&flagDeleted != 0,
+	}, nil
+}
+
+func encodeEntry(e *Entry) []byte {
+	val := make([]byte, headerLen, headerLen+len(e.Value))
+	binary.BigEndian.PutUint64(val, e.Version)
+	if e.Deleted {
+		val[versionLen] = flagDeleted
+	}
+	return append(val, e.Value...)
+}
+
+// Get returns the latest version of the setting, which is a tombstone if the
+// setting is deleted.
+func (s *Store) Get(ctx context.Context, name string) (*Entry, error) {
+	txn, err := s.store.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Rollback()
+	return s.get(ctx, txn, name)
+}
+
+func (s *Store) get(ctx context.Context, txn *transaction.KVTxn, name string) (*Entry, error) {
+	val, err := txn.Get(ctx, s.key(name))
+	if tikverr.IsErrNotFound(err) {
+		return &Entry{Name: name}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeEntry(name, val)
+}
+
+// List returns the latest versions of all the settings not deleted.
+func (s *Store) List(ctx context.Context) ([]*Entry, error) {
+	txn, err := s.store.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Rollback()
+	it, err := txn.Iter(s.prefix, kv.PrefixNextKey(s.prefix))
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	var entries []*Entry
+	for it.Valid() {
+		e, err := decodeEntry(string(it.Key()[len(s.prefix):]), it.Value())
+		if err != nil {
+			return nil, err
+		}
+		if !e.Deleted {
+			entries = append(entries, e)
+		}
+		if err = it.Next(); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// Set updates the setting and returns the new version.
+func (s *Store) Set(ctx context.Context, name string, value []byte) (uint64, error) {
+	return s.update(ctx, name, func(e *Entry) (*Entry, error) {
+		return &Entry{Name: name, Value: value, Version: e.Version + 1}, nil
+	})
+}
+
+// CompareAndSet updates the setting only if its current version is version,
+// otherwise ErrVersionMismatch is returned. Version 0 means the setting
+// should have never been set, and the version of a deleted setting is the one
+// of its tombstone.
+func (s *Store) CompareAndSet(ctx context.Context, name string, value []byte, version uint64) (uint64, error) {
+	return s.update(ctx, name, func(e *Entry) (*Entry, error) {
+		if e.Version != version {
+			return nil, errors.WithStack(ErrVersionMismatch)
+		}
+		return &Entry{Name: name, Value: value, Version: e.Version + 1}, nil
+	})
+}
+
+// Delete removes the setting by replacing it with a tombstone of the next
+// version.
+func (s *Store) Delete(ctx context.Context, name string) error {
+	_, err := s.update(ctx, name, func(e *Entry) (*Entry, error) {
+		if e.Version == 0 || e.Deleted {
+			return nil, nil
+		}
+		return &Entry{Name: name, Version: e.Version + 1, Deleted: true}, nil
+	})
+	return err
+}
+
+// update applies f to the current version of the setting in a transaction.
+// Nothing is written if f returns nil. It retries on write conflicts.
+func (s *Store) update(ctx context.Context, name string, f func(*Entry) (*Entry, error)) (uint64, error) {
+	bo := retry.NewBackofferWithVars(ctx, writeMaxBackoff, nil)
+	for {
+		version, err := s.updateOnce(ctx, name, f)
+		if err == nil || !tikverr.IsErrWriteConflict(err) {
+			return version, err
+		}
+		if err = bo.Backoff(retry.BoTxnLock, err); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (s *Store) updateOnce(ctx context.Context, name string, f func(*Entry) (*Entry, error)) (uint64, error) {
+	txn, err := s.store.Begin()
+	if err != nil {
+		return 0, err
+	}
+	current, err := s.get(ctx, txn, name)
+	if err != nil {
+		txn.Rollback()
+		return 0, err
+	}
+	next, err := f(current)
+	if err != nil {
+		txn.Rollback()
+		return 0, err
+	}
+	if next == nil {
+		txn.Rollback()
+		return current.Version, nil
+	}
+	if err = txn.Set(s.key(name), encodeEntry(next)); err != nil {
+		txn.Rollback()
+		return 0, err
+	}
+	if err = txn.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return next.Version, nil
+}
+
+// Watch polls the setting every interval, and sends the entry to the returned
+// channel when its version changes. The current version is sent first. The
+// channel is closed after ctx is done. Errors during polling are logged and
+// retried in the next round.
+func (s *Store) Watch(ctx context.Context, name string, interval time.Duration) <-chan *Entry {
+	ch := make(chan *Entry, 1)
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var last *Entry
+		for {
+			e, err := s.Get(ctx, name)
+			if err != nil {
+				logutil.Logger(ctx).Warn("poll config failed", zap.String("name", name), zap.Error(err))
+			} else if last == nil || e.Version != last.Version {
+				select {
+				case ch <- e:
+					last = e
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,306 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package indexkv maintains the secondary indexes of the rows stored by the
+// transactions, without any schema: the indexed values of a row are extracted
+// from it by a function, and the index entries are written in the same
+// transaction as the row, so the indexes are always consistent with the rows.
+//
+// The keys of a Table are laid out under its prefix as:
+//
+//	prefix 'r' primaryKey                      -> row value
+//	prefix 'i' name indexedValue primaryKey    -> '0'         (non-unique index)
+//	prefix 'i' name indexedValue               -> primaryKey  (unique index)
+//
+// where name and indexedValue are encoded by codec.EncodeBytes, so the index
+// entries are sorted by the indexed values.
+package indexkv
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/pkg/errors"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+	"github.com/tikv/client-go/v2/util/codec"
+)
+
+const (
+	rowTag   = 'r'
+	indexTag = 'i'
+)
+
+// nonUniqueValue is the value of the entries of the non-unique indexes, since
+// empty values can't be set.
+var nonUniqueValue = []byte{'0'}
+
+// ErrUniqueConflict is returned by Table.Set if a value of a unique index is
+// already indexed by another row.
+var ErrUniqueConflict = errors.New("unique index conflict")
+
+// ErrIndexNotFound is returned if the index isn't defined in the Table.
+var ErrIndexNotFound = errors.New("index not found")
+
+// Index is a secondary index of the rows of a Table.
+type Index struct {
+	// Name identifies the index in the Table.
+	Name string
+	// Unique makes a value indexed by one row at most.
+	Unique bool
+	// Extract returns the indexed values of the row, a row may be indexed by
+	// several values, or not at all if no value is returned. It must be
+	// deterministic, since the index entries of the old row are found by
+	// extracting from it again when the row is updated or deleted.
+	Extract func(key, value []byte) [][]byte
+}
+
+// Row is a row of a Table.
+type Row struct {
+	Key   []byte
+	Value []byte
+}
+
+// Table is a set of the rows under a key prefix with their secondary indexes.
+// The methods take the transaction the rows are read and written in, and the
+// index entries of the rows are written in the same transaction.
+type Table struct {
+	prefix  []byte
+	indexes map[string]*Index
+}
+
+// NewTable creates a Table of the rows under prefix. The prefix must not be a
+// prefix of the prefixes of other tables, since their keys would overlap.
+func NewTable(prefix []byte, indexes ...*Index) (*Table, error) {
+	t := &Table{prefix: prefix, indexes: make(map[string]*Index, len(indexes))}
+	for _, idx := range indexes {
+		if idx.Extract == nil {
+			return nil, errors.Errorf("indexkv: index %q has no Extract", idx.Name)
+		}
+		if _, ok := t.indexes[idx.Name]; ok {
+			return nil, errors.Errorf("indexkv: duplicated index %q", idx.Name)
+		}
+		t.indexes[idx.Name] = idx
+	}
+	return t, nil
+}
+
+func (t *Table) rowKey(key []byte) []byte {
+	k := make([]byte, 0, len(t.prefix)+1+len(key))
+	k = append(k, t.prefix...)
+	k = append(k, rowTag)
+	return append(k, key...)
+}
+
+// indexPrefix returns the prefix of the entries of the index, followed by the
+// encoded indexed value if value is not nil.
+func (t *Table) indexPrefix(idx *Index, value []byte) []byte {
+	var k []byte
+	k = append(k, t.prefix...)
+	k = append(k, indexTag)
+	k = codec.EncodeBytes(k, []byte(idx.Name))
+	if value != nil {
+		k = codec.EncodeBytes(k, value)
+	}
+	return k
+}
+
+func (t *Table) indexKey(idx *Index, value, key []byte) []byte {
+	if value == nil {
+		value = []byte{}
+	}
+	k := t.indexPrefix(idx, value)
+	if !idx.Unique {
+		k = append(k, key...)
+	}
+	return k
+}
+
+func (t *Table) index(name string) (*Index, error) {
+	idx, ok := t.indexes[name]
+	if !ok {
+		return nil, errors.Wrapf(ErrIndexNotFound, "indexkv: index %q", name)
+	}
+	return idx, nil
+}
+
+// Get returns the value of the row, or nil if the row doesn't exist.
+func (t *Table) Get(ctx context.Context, txn *transaction.KVTxn, key []byte) ([]byte, error) {
+	val, err := txn.Get(ctx, t.rowKey(key))
+	if tikverr.IsErrNotFound(err) {
+		return nil, nil
+	}
+	return val, err
+}
+
+// Set inserts the row, or updates it if it exists. The index entries of the
+// old row which are not of the new row are deleted.
+func (t *Table) Set(ctx context.Context, txn *transaction.KVTxn, key, value []byte) error {
+	old, err := t.Get(ctx, txn, key)
+	if err != nil {
+		return err
+	}
+	for _, idx := range t.indexes {
+		var oldValues [][]byte
+		if old != nil {
+			oldValues = idx.Extract(key, old)
+		}
+		newValues := idx.Extract(key, value)
+		for _, v := range oldValues {
+			if !containsValue(newValues, v) {
+				if err := txn.Delete(t.indexKey(idx, v, key)); err != nil {
+					return err
+				}
+			}
+		}
+		for _, v := range newValues {
+			if containsValue(oldValues, v) {
+				continue
+			}
+			if err := t.setIndex(ctx, txn, idx, v, key); err != nil {
+				return err
+			}
+		}
+	}
+	return txn.Set(t.rowKey(key), value)
+}
+
+func (t *Table) setIndex(ctx context.Context, txn *transaction.KVTxn, idx *Index, value, key []byte) error {
+	indexKey := t.indexKey(idx, value, key)
+	if !idx.Unique {
+		return txn.Set(indexKey, nonUniqueValue)
+	}
+	owner, err := txn.Get(ctx, indexKey)
+	if err == nil && !bytes.Equal(owner, key) {
+		return errors.Wrapf(ErrUniqueConflict, "indexkv: value %q of index %q is indexed by row %q", value, idx.Name, owner)
+	}
+	if err != nil && !tikverr.IsErrNotFound(err) {
+		return err
+	}
+	return txn.Set(indexKey, key)
+}
+
+// Delete deletes the row and its index entries. Deleting a row not existing
+// does nothing.
+func (t *Table) Delete(ctx context.Context, txn *transaction.KVTxn, key []byte) error {
+	old, err := t.Get(ctx, txn, key)
+	if err != nil || old == nil {
+		return err
+	}
+	for _, idx := range t.indexes {
+		for _, v := range idx.Extract(key, old) {
+			if err := txn.Delete(t.indexKey(idx, v, key)); err != nil {
+				return err
+			}
+		}
+	}
+	return txn.Delete(t.rowKey(key))
+}
+
+// Lookup returns at most limit rows indexed by the value in the index, in the
+// order of their primary keys. A non-positive limit means no limit.
+func (t *Table) Lookup(ctx context.Context, txn *transaction.KVTxn, index string, value []byte, limit int) ([]Row, error) {
+	idx, err := t.index(index)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	prefix := t.indexPrefix(idx, value)
+	if idx.Unique {
+		// The entry of a unique index has no primary key in it, so its key is
+		// the prefix itself.
+		return t.scan(ctx, txn, idx, prefix, kv.NextKey(prefix), limit)
+	}
+	return t.scan(ctx, txn, idx, prefix, kv.PrefixNextKey(prefix), limit)
+}
+
+// Scan returns at most limit rows indexed by the values in [lower, upper) in
+// the index, in the order of the indexed values and then their primary keys.
+// A nil upper means no upper bound, and a non-positive limit means no limit.
+func (t *Table) Scan(ctx context.Context, txn *transaction.KVTxn, index string, lower, upper []byte, limit int) ([]Row, error) {
+	idx, err := t.index(index)
+	if err != nil {
+		return nil, err
+	}
+	start := t.indexPrefix(idx, lower)
+	var end []byte
+	if upper != nil {
+		end = t.indexPrefix(idx, upper)
+	} else {
+		end = kv.PrefixNextKey(t.indexPrefix(idx, nil))
+	}
+	return t.scan(ctx, txn, idx, start, end, limit)
+}
+
+// scan reads the index entries in [start, end) and resolves them to the rows.
+func (t *Table) scan(ctx context.Context, txn *transaction.KVTxn, idx *Index, start, end []byte, limit int) ([]Row, error) {
+	it, err := txn.Iter(start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	nameLen := len(t.indexPrefix(idx, nil))
+	var keys [][]byte
+	for it.Valid() && (limit <= 0 || len(keys) < limit) {
+		var key []byte
+		if idx.Unique {
+			key = append([]byte(nil), it.Value()...)
+		} else {
+			rest, _, err := codec.DecodeBytes(it.Key()[nameLen:], nil)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "indexkv: decode entry of index %q", idx.Name)
+			}
+			key = append([]byte(nil), rest...)
+		}
+		keys = append(keys, key)
+		if err := it.Next(); err != nil {
+			return nil, err
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	rowKeys := make([][]byte, len(keys))
+	for i, k := range keys {
+		rowKeys[i] = t.rowKey(k)
+	}
+	vals, err := txn.BatchGet(ctx, rowKeys)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]Row, 0, len(keys))
+	for i, k := range keys {
+		val, ok := vals[string(rowKeys[i])]
+		if !ok {
+			// The index and the rows are written in the same transactions, so
+			// it happens only if the rows are written bypassing the Table.
+			return nil, errors.Errorf("indexkv: row %q indexed by %q not found", k, idx.Name)
+		}
+		rows = append(rows, Row{Key: k, Value: val})
+	}
+	return rows, nil
+}
+
+func containsValue(values [][]byte, v []byte) bool {
+	for _, value := range values {
+		if bytes.Equal(value, v) {
+			return true
+		}
+	}
+	return false
+}
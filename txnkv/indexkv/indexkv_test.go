@@ -0,0 +1,130 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexkv
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	tikvtesting "github.com/tikv/client-go/v2/tikv/testing"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+)
+
+func TestTable(t *testing.T) {
+	store, err := tikvtesting.NewStore()
+	require.Nil(t, err)
+	defer store.Close()
+	ctx := context.Background()
+
+	// The rows are "city,email,tag1|tag2...".
+	field := func(i int) func(key, value []byte) [][]byte {
+		return func(key, value []byte) [][]byte {
+			f := strings.Split(string(value), ",")[i]
+			var values [][]byte
+			for _, v := range strings.Split(f, "|") {
+				if v != "" {
+					values = append(values, []byte(v))
+				}
+			}
+			return values
+		}
+	}
+	table, err := NewTable([]byte("users/"),
+		&Index{Name: "city", Extract: field(0)},
+		&Index{Name: "email", Unique: true, Extract: field(1)},
+		&Index{Name: "tag", Extract: field(2)},
+	)
+	require.Nil(t, err)
+	_, err = NewTable([]byte("t/"), &Index{Name: "a", Extract: field(0)}, &Index{Name: "a", Extract: field(1)})
+	require.Error(t, err)
+
+	update := func(f func(txn *transaction.KVTxn) error) error {
+		txn, err := store.Begin()
+		require.Nil(t, err)
+		if err := f(txn); err != nil {
+			require.Nil(t, txn.Rollback())
+			return err
+		}
+		return txn.Commit(ctx)
+	}
+	keysOf := func(rows []Row) []string {
+		var keys []string
+		for _, r := range rows {
+			keys = append(keys, string(r.Key))
+		}
+		return keys
+	}
+	lookup := func(index, value string) []string {
+		txn, err := store.Begin()
+		require.Nil(t, err)
+		defer txn.Rollback()
+		rows, err := table.Lookup(ctx, txn, index, []byte(value), 0)
+		require.Nil(t, err)
+		return keysOf(rows)
+	}
+
+	require.Nil(t, update(func(txn *transaction.KVTxn) error {
+		for _, r := range [][2]string{{"u1", "bj,a@x,go|db"}, {"u2", "sh,b@x,db"}, {"u3", "bj,c@x,"}} {
+			if err := table.Set(ctx, txn, []byte(r[0]), []byte(r[1])); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	require.Equal(t, []string{"u1", "u3"}, lookup("city", "bj"))
+	require.Equal(t, []string{"u2"}, lookup("email", "b@x"))
+	require.Equal(t, []string{"u1", "u2"}, lookup("tag", "db"))
+
+	// The unique index rejects the value indexed by another row.
+	err = update(func(txn *transaction.KVTxn) error {
+		return table.Set(ctx, txn, []byte("u4"), []byte("gz,a@x,"))
+	})
+	require.ErrorIs(t, err, ErrUniqueConflict)
+
+	// Updating a row moves its index entries.
+	require.Nil(t, update(func(txn *transaction.KVTxn) error {
+		return table.Set(ctx, txn, []byte("u1"), []byte("sh,a@y,go"))
+	}))
+	require.Equal(t, []string{"u3"}, lookup("city", "bj"))
+	require.Equal(t, []string{"u1", "u2"}, lookup("city", "sh"))
+	require.Nil(t, lookup("email", "a@x"))
+	require.Equal(t, []string{"u1"}, lookup("email", "a@y"))
+	require.Equal(t, []string{"u2"}, lookup("tag", "db"))
+
+	// The uncommitted writes of the transaction are read by itself.
+	require.Nil(t, update(func(txn *transaction.KVTxn) error {
+		require.Nil(t, table.Delete(ctx, txn, []byte("u2")))
+		rows, err := table.Scan(ctx, txn, "city", []byte("a"), []byte("c"), 0)
+		require.Nil(t, err)
+		require.Equal(t, []string{"u3"}, keysOf(rows))
+		rows, err = table.Scan(ctx, txn, "email", nil, nil, 1)
+		require.Nil(t, err)
+		require.Equal(t, []Row{{Key: []byte("u1"), Value: []byte("sh,a@y,go")}}, rows)
+		return nil
+	}))
+	require.Equal(t, []string{"u1"}, lookup("city", "sh"))
+	require.Nil(t, lookup("tag", "db"))
+
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	defer txn.Rollback()
+	_, err = table.Lookup(ctx, txn, "age", []byte("1"), 0)
+	require.ErrorIs(t, err, ErrIndexNotFound)
+	val, err := table.Get(ctx, txn, []byte("u2"))
+	require.Nil(t, err)
+	require.Nil(t, val)
+}
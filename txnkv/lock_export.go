@@ -16,6 +16,7 @@ package txnkv
 
 import (
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/tikv/client-go/v2/tikv"
 	"github.com/tikv/client-go/v2/txnkv/txnlock"
 )
 
@@ -32,3 +33,21 @@ type TxnStatus = txnlock.TxnStatus
 func NewLock(l *kvrpcpb.LockInfo) *Lock {
 	return txnlock.NewLock(l)
 }
+
+// OrphanLockCleanupStat is the result of CleanupOrphanAsyncCommitLocks.
+type OrphanLockCleanupStat = tikv.OrphanLockCleanupStat
+
+// OrphanLockCleanupOpt is the option of CleanupOrphanAsyncCommitLocks.
+type OrphanLockCleanupOpt = tikv.OrphanLockCleanupOpt
+
+// WithCleanupDryRun makes CleanupOrphanAsyncCommitLocks check the status of
+// the transactions as usual but report the locks without resolving them.
+func WithCleanupDryRun() OrphanLockCleanupOpt {
+	return tikv.WithCleanupDryRun()
+}
+
+// WithCleanupRateLimit limits the number of transactions resolved per second
+// by CleanupOrphanAsyncCommitLocks.
+func WithCleanupRateLimit(txnsPerSec int) OrphanLockCleanupOpt {
+	return tikv.WithCleanupRateLimit(txnsPerSec)
+}
@@ -0,0 +1,145 @@
+// Copyright 2021 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rangetask
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/util/redact"
+	"go.uber.org/zap"
+)
+
+// Checkpoint is the progress of a range task.
+type Checkpoint struct {
+	// StartKey and EndKey are the range of the task.
+	StartKey []byte
+	EndKey   []byte
+	// NextKey is the key where the task continues, all the keys before it in the range are processed.
+	NextKey []byte
+	// Finished means the whole range is processed.
+	Finished bool
+}
+
+// CheckpointStore persists the checkpoints of the range tasks, so a task can be resumed from where it stopped, e.g.
+// after the process crashes. The checkpoints are keyed by the identifier of the runner.
+type CheckpointStore interface {
+	// LoadCheckpoint returns the last saved checkpoint of the task, or nil if there is none.
+	LoadCheckpoint(ctx context.Context, identifier string) (*Checkpoint, error)
+	// SaveCheckpoint saves the checkpoint of the task.
+	SaveCheckpoint(ctx context.Context, identifier string, checkpoint *Checkpoint) error
+}
+
+// checkpointer tracks the sub tasks completed out of order and saves the checkpoint whenever the processed prefix of
+// the range grows.
+type checkpointer struct {
+	store      CheckpointStore
+	identifier string
+
+	mu         sync.Mutex
+	checkpoint Checkpoint
+	// pending is the sub tasks that are sent to the workers but not yet covered by the checkpoint, in key order.
+	pending []*kv.KeyRange
+	done    map[*kv.KeyRange]struct{}
+	// version is increased every time the checkpoint advances.
+	version uint64
+
+	// saveMu serializes the saves outside mu, so a slow store doesn't block
+	// the workers, and a checkpoint is never overwritten by an older one.
+	saveMu       sync.Mutex
+	savedVersion uint64
+}
+
+// loadCheckpoint loads the checkpoint of the task on the given range. A checkpoint saved for a different range is
+// ignored.
+func (s *Runner) loadCheckpoint(ctx context.Context, startKey, endKey []byte) (*checkpointer, error) {
+	c := &checkpointer{
+		store:      s.checkpointStore,
+		identifier: s.identifier,
+		checkpoint: Checkpoint{StartKey: startKey, EndKey: endKey, NextKey: startKey},
+		done:       make(map[*kv.KeyRange]struct{}),
+	}
+	saved, err := s.checkpointStore.LoadCheckpoint(ctx, s.identifier)
+	if err != nil {
+		return nil, err
+	}
+	if saved == nil {
+		return c, nil
+	}
+	if !bytes.Equal(saved.StartKey, startKey) || !bytes.Equal(saved.EndKey, endKey) {
+		logutil.Logger(ctx).Info("range task ignores the checkpoint of a different range",
+			zap.String("name", s.identifier),
+			zap.String("startKey", redact.Key(startKey)),
+			zap.String("endKey", redact.Key(endKey)),
+			zap.String("checkpointStartKey", redact.Key(saved.StartKey)),
+			zap.String("checkpointEndKey", redact.Key(saved.EndKey)))
+		return c, nil
+	}
+	c.checkpoint.NextKey = saved.NextKey
+	c.checkpoint.Finished = saved.Finished
+	return c, nil
+}
+
+// push adds a sub task that is going to be sent to the workers.
+func (c *checkpointer) push(r *kv.KeyRange) {
+	c.mu.Lock()
+	c.pending = append(c.pending, r)
+	c.mu.Unlock()
+}
+
+// finish marks the sub task processed, and saves the checkpoint if all the sub tasks before it are processed too.
+func (c *checkpointer) finish(ctx context.Context, r *kv.KeyRange) {
+	c.mu.Lock()
+	c.done[r] = struct{}{}
+	advanced := false
+	for len(c.pending) > 0 {
+		head := c.pending[0]
+		if _, ok := c.done[head]; !ok {
+			break
+		}
+		delete(c.done, head)
+		c.pending = c.pending[1:]
+		c.checkpoint.NextKey = head.EndKey
+		// Only the last sub task ends with the end key of the range.
+		c.checkpoint.Finished = bytes.Equal(head.EndKey, c.checkpoint.EndKey)
+		advanced = true
+	}
+	if !advanced {
+		c.mu.Unlock()
+		return
+	}
+	c.version++
+	checkpoint, version := c.checkpoint, c.version
+	c.mu.Unlock()
+
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+	if version <= c.savedVersion {
+		// A newer checkpoint is saved already.
+		return
+	}
+	// Failing to save the checkpoint only makes the task redo more work when it's resumed.
+	if err := c.store.SaveCheckpoint(ctx, c.identifier, &checkpoint); err != nil {
+		logutil.Logger(ctx).Warn("range task failed to save checkpoint",
+			zap.String("name", c.identifier),
+			zap.String("nextKey", redact.Key(checkpoint.NextKey)),
+			zap.Error(err))
+		return
+	}
+	c.savedVersion = version
+}
@@ -46,6 +46,7 @@ import (
 	"github.com/tikv/client-go/v2/internal/logutil"
 	"github.com/tikv/client-go/v2/kv"
 	"github.com/tikv/client-go/v2/metrics"
+	"github.com/tikv/client-go/v2/util/redact"
 	"go.uber.org/zap"
 )
 
@@ -70,6 +71,7 @@ type Runner struct {
 	handler         TaskHandler
 	statLogInterval time.Duration
 	regionsPerTask  int
+	checkpointStore CheckpointStore
 
 	completedRegions int32
 	failedRegions    int32
@@ -143,6 +145,13 @@ func (s *Runner) SetRegionsPerTask(regionsPerTask int) {
 	s.regionsPerTask = regionsPerTask
 }
 
+// SetCheckpointStore sets the store to persist the progress of the task. RunOnRange resumes from the saved checkpoint
+// if it's running on the same range as the checkpoint, and does nothing if the range is already finished. The
+// checkpoints are keyed by the identifier, so the runners sharing a store must have different identifiers.
+func (s *Runner) SetCheckpointStore(store CheckpointStore) {
+	s.checkpointStore = store
+}
+
 const locateRegionMaxBackoff = 20000
 
 // NewLocateRegionBackoffer creates the backoofer for LocateRegion request.
@@ -159,15 +168,34 @@ func (s *Runner) RunOnRange(ctx context.Context, startKey, endKey []byte) error
 	if len(endKey) != 0 && bytes.Compare(startKey, endKey) >= 0 {
 		logutil.Logger(ctx).Info("empty range task executed. ignored",
 			zap.String("name", s.identifier),
-			zap.String("startKey", kv.StrKey(startKey)),
-			zap.String("endKey", kv.StrKey(endKey)))
+			zap.String("startKey", redact.Key(startKey)),
+			zap.String("endKey", redact.Key(endKey)))
 		return nil
 	}
 
+	key := startKey
+	var checkpointer *checkpointer
+	if s.checkpointStore != nil {
+		var err error
+		checkpointer, err = s.loadCheckpoint(ctx, startKey, endKey)
+		if err != nil {
+			return err
+		}
+		if checkpointer.checkpoint.Finished {
+			logutil.Logger(ctx).Info("range task already finished by checkpoint. ignored",
+				zap.String("name", s.identifier),
+				zap.String("startKey", redact.Key(startKey)),
+				zap.String("endKey", redact.Key(endKey)))
+			return nil
+		}
+		key = checkpointer.checkpoint.NextKey
+	}
+
 	logutil.Logger(ctx).Info("range task started",
 		zap.String("name", s.identifier),
-		zap.String("startKey", kv.StrKey(startKey)),
-		zap.String("endKey", kv.StrKey(endKey)),
+		zap.String("startKey", redact.Key(startKey)),
+		zap.String("endKey", redact.Key(endKey)),
+		zap.String("resumeKey", redact.Key(key)),
 		zap.Int("concurrency", s.concurrency))
 
 	// Periodically log the progress
@@ -180,7 +208,7 @@ func (s *Runner) RunOnRange(ctx context.Context, startKey, endKey []byte) error
 	// Create workers that concurrently process the whole range.
 	workers := make([]*rangeTaskWorker, 0, s.concurrency)
 	for i := 0; i < s.concurrency; i++ {
-		w := s.createWorker(taskCh, &wg)
+		w := s.createWorker(taskCh, &wg, checkpointer)
 		workers = append(workers, w)
 		wg.Add(1)
 		go w.run(ctx, cancel)
@@ -201,15 +229,14 @@ func (s *Runner) RunOnRange(ctx context.Context, startKey, endKey []byte) error
 	}()
 
 	// Iterate all regions and send each region's range as a task to the workers.
-	key := startKey
 Loop:
 	for {
 		select {
 		case <-statLogTicker.C:
 			logutil.Logger(ctx).Info("range task in progress",
 				zap.String("name", s.identifier),
-				zap.String("startKey", kv.StrKey(startKey)),
-				zap.String("endKey", kv.StrKey(endKey)),
+				zap.String("startKey", redact.Key(startKey)),
+				zap.String("endKey", redact.Key(endKey)),
 				zap.Int("concurrency", s.concurrency),
 				zap.Duration("cost time", time.Since(startTime)),
 				zap.Int("completed regions", s.CompletedRegions()))
@@ -222,9 +249,9 @@ Loop:
 		if err != nil {
 			logutil.Logger(ctx).Info("range task try to get range end key failure",
 				zap.String("name", s.identifier),
-				zap.String("startKey", kv.StrKey(startKey)),
-				zap.String("endKey", kv.StrKey(endKey)),
-				zap.String("loadRegionKey", kv.StrKey(key)),
+				zap.String("startKey", redact.Key(startKey)),
+				zap.String("endKey", redact.Key(endKey)),
+				zap.String("loadRegionKey", redact.Key(key)),
 				zap.Duration("cost time", time.Since(startTime)),
 				zap.Error(err))
 			return err
@@ -240,6 +267,9 @@ Loop:
 			task.EndKey = endKey
 		}
 
+		if checkpointer != nil {
+			checkpointer.push(task)
+		}
 		pushTaskStartTime := time.Now()
 
 		select {
@@ -263,8 +293,8 @@ Loop:
 		if w.err != nil {
 			logutil.Logger(ctx).Info("range task failed",
 				zap.String("name", s.identifier),
-				zap.String("startKey", kv.StrKey(startKey)),
-				zap.String("endKey", kv.StrKey(endKey)),
+				zap.String("startKey", redact.Key(startKey)),
+				zap.String("endKey", redact.Key(endKey)),
 				zap.Duration("cost time", time.Since(startTime)),
 				zap.Int("completed regions", s.CompletedRegions()),
 				zap.Int("failed regions", s.FailedRegions()),
@@ -275,8 +305,8 @@ Loop:
 
 	logutil.Logger(ctx).Info("range task finished",
 		zap.String("name", s.identifier),
-		zap.String("startKey", kv.StrKey(startKey)),
-		zap.String("endKey", kv.StrKey(endKey)),
+		zap.String("startKey", redact.Key(startKey)),
+		zap.String("endKey", redact.Key(endKey)),
 		zap.Duration("cost time", time.Since(startTime)),
 		zap.Int("completed regions", s.CompletedRegions()))
 
@@ -284,7 +314,7 @@ Loop:
 }
 
 // createWorker creates a worker that can process tasks from the given channel.
-func (s *Runner) createWorker(taskCh chan *kv.KeyRange, wg *sync.WaitGroup) *rangeTaskWorker {
+func (s *Runner) createWorker(taskCh chan *kv.KeyRange, wg *sync.WaitGroup, checkpointer *checkpointer) *rangeTaskWorker {
 	return &rangeTaskWorker{
 		name:       s.name,
 		identifier: s.identifier,
@@ -292,6 +322,7 @@ func (s *Runner) createWorker(taskCh chan *kv.KeyRange, wg *sync.WaitGroup) *ran
 		handler:    s.handler,
 		taskCh:     taskCh,
 		wg:         wg,
+		checkpoint: checkpointer,
 
 		completedRegions: &s.completedRegions,
 		failedRegions:    &s.failedRegions,
@@ -318,6 +349,8 @@ type rangeTaskWorker struct {
 	handler    TaskHandler
 	taskCh     chan *kv.KeyRange
 	wg         *sync.WaitGroup
+	// checkpoint is nil if the runner has no checkpoint store.
+	checkpoint *checkpointer
 
 	err error
 
@@ -346,12 +379,15 @@ func (w *rangeTaskWorker) run(ctx context.Context, cancel context.CancelFunc) {
 		if err != nil {
 			logutil.Logger(ctx).Info("canceling range task because of error",
 				zap.String("name", w.identifier),
-				zap.String("startKey", kv.StrKey(r.StartKey)),
-				zap.String("endKey", kv.StrKey(r.EndKey)),
+				zap.String("startKey", redact.Key(r.StartKey)),
+				zap.String("endKey", redact.Key(r.EndKey)),
 				zap.Error(err))
 			w.err = err
 			cancel()
 			break
 		}
+		if w.checkpoint != nil {
+			w.checkpoint.finish(ctx, r)
+		}
 	}
 }
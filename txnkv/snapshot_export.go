@@ -34,6 +34,9 @@ type IsoLevel = txnsnapshot.IsoLevel
 // based on the keys count for BatchPointGet and PointGet
 type ReplicaReadAdjuster = txnsnapshot.ReplicaReadAdjuster
 
+// ScanResumeToken is an opaque token to continue a scan.
+type ScanResumeToken = txnsnapshot.ScanResumeToken
+
 // IsoLevel value for transaction priority.
 const (
 	SI        = txnsnapshot.SI
@@ -37,7 +37,6 @@ package transaction
 import (
 	"bytes"
 	"context"
-	"encoding/hex"
 	errors2 "errors"
 	"math"
 	"math/rand"
@@ -65,6 +64,7 @@ import (
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/txnkv/txnlock"
 	"github.com/tikv/client-go/v2/util"
+	"github.com/tikv/client-go/v2/util/redact"
 	atomicutil "go.uber.org/atomic"
 	zap "go.uber.org/zap"
 )
@@ -113,6 +113,8 @@ type kvstore interface {
 	GetLockResolver() *txnlock.LockResolver
 	Ctx() context.Context
 	WaitGroup() *sync.WaitGroup
+	// AsyncOps returns the tracker of the background operations.
+	AsyncOps() *AsyncOps
 	// TxnLatches returns txnLatches.
 	TxnLatches() *latch.LatchesScheduler
 	GetClusterID() uint64
@@ -124,17 +126,19 @@ type kvstore interface {
 
 // twoPhaseCommitter executes a two-phase commit protocol.
 type twoPhaseCommitter struct {
-	store               kvstore
-	txn                 *KVTxn
-	startTS             uint64
-	mutations           *memBufferMutations
-	lockTTL             uint64
-	commitTS            uint64
-	priority            kvrpcpb.CommandPri
-	sessionID           uint64 // sessionID is used for log.
-	cleanWg             sync.WaitGroup
-	detail              unsafe.Pointer
-	txnSize             int
+	store     kvstore
+	txn       *KVTxn
+	startTS   uint64
+	mutations *memBufferMutations
+	lockTTL   uint64
+	commitTS  uint64
+	priority  kvrpcpb.CommandPri
+	sessionID uint64 // sessionID is used for log.
+	cleanWg   sync.WaitGroup
+	detail    unsafe.Pointer
+	txnSize   int
+	// sizes is collected by initKeysAndMutations for the commit size check.
+	sizes               commitSizes
 	hasNoNeedCommitKeys bool
 	resourceGroupName   string
 
@@ -170,6 +174,8 @@ type twoPhaseCommitter struct {
 	prewriteCancelled uint32
 	useOnePC          uint32
 	onePCCommitTS     uint64
+	// onePCIneligibleReason is why 1PC isn't used, see KVTxn.OnePCIneligibleReason.
+	onePCIneligibleReason string
 
 	hasTriedAsyncCommit bool
 	hasTriedOnePC       bool
@@ -486,7 +492,7 @@ func (c *twoPhaseCommitter) extractKeyExistsErr(err *tikverr.ErrKeyExist) error
 	c.txn.GetMemBuffer().RLock()
 	defer c.txn.GetMemBuffer().RUnlock()
 	if !c.txn.us.HasPresumeKeyNotExists(err.GetKey()) {
-		return errors.Errorf("session %d, existErr for key:%s should not be nil", c.sessionID, err.GetKey())
+		return errors.Errorf("session %d, existErr for key:%s should not be nil", c.sessionID, redact.Key(err.GetKey()))
 	}
 	return errors.WithStack(err)
 }
@@ -554,6 +560,7 @@ func (c *twoPhaseCommitter) initKeysAndMutations(ctx context.Context) error {
 	memBuf := txn.GetMemBuffer().GetMemDB()
 	sizeHint := txn.us.GetMemBuffer().Len()
 	c.mutations = newMemBufferMutations(sizeHint, memBuf)
+	c.sizes = commitSizes{}
 	c.isPessimistic = txn.IsPessimistic()
 	filter := txn.kvFilter
 
@@ -638,6 +645,7 @@ func (c *twoPhaseCommitter) initKeysAndMutations(ctx context.Context) error {
 		}
 		c.mutations.Push(op, isPessimistic, mustExist, mustNotExist, flags.HasNeedConstraintCheckInPrewrite(), it.Handle())
 		size += len(key) + len(value)
+		c.sizes.add(key, value)
 
 		if c.txn.assertionLevel != kvrpcpb.AssertionLevel_Off {
 			// Check mutations for pessimistic-locked keys with the read results of pessimistic lock requests.
@@ -692,7 +700,7 @@ func (c *twoPhaseCommitter) initKeysAndMutations(ctx context.Context) error {
 	if c.mutations.Len() > logEntryCount || size > logSize {
 		logutil.BgLogger().Info("[BIG_TXN]",
 			zap.Uint64("session", c.sessionID),
-			zap.String("key sample", kv.StrKey(c.mutations.GetKey(0))),
+			zap.String("key sample", redact.Key(c.mutations.GetKey(0))),
 			zap.Int("size", size),
 			zap.Int("keys", c.mutations.Len()),
 			zap.Int("puts", putCnt),
@@ -811,7 +819,9 @@ func (c *twoPhaseCommitter) doActionOnMutations(bo *retry.Backoffer, action twoP
 
 	// This is redundant since `doActionOnGroupMutations` will still split groups into batches and
 	// check the number of batches. However we don't want the check fail after any code changes.
-	c.checkOnePCFallBack(action, len(groups))
+	if err = c.checkOnePCFallBack(action, len(groups)); err != nil {
+		return err
+	}
 
 	return c.doActionOnGroupMutations(bo, action, groups)
 }
@@ -954,8 +964,7 @@ func (c *twoPhaseCommitter) doActionOnGroupMutations(bo *retry.Backoffer, action
 
 	batchBuilder := newBatched(c.primary())
 	for _, group := range groups {
-		batchBuilder.appendBatchMutationsBySize(group.region, group.mutations, sizeFunc,
-			int(kv.TxnCommitBatchSize.Load()))
+		batchBuilder.appendBatchMutationsBySize(group.region, group.mutations, sizeFunc, c.commitBatchSize())
 	}
 	firstIsPrimary := batchBuilder.setPrimary()
 
@@ -964,7 +973,9 @@ func (c *twoPhaseCommitter) doActionOnGroupMutations(bo *retry.Backoffer, action
 	_, actionIsPessimisticLock := action.(actionPessimisticLock)
 	_, actionIsPrewrite := action.(actionPrewrite)
 
-	c.checkOnePCFallBack(action, len(batchBuilder.allBatches()))
+	if err := c.checkOnePCFallBack(action, len(batchBuilder.allBatches())); err != nil {
+		return err
+	}
 
 	var err error
 	if val, err := util.EvalFailpoint("skipKeyReturnOK"); err == nil {
@@ -1017,8 +1028,10 @@ func (c *twoPhaseCommitter) doActionOnGroupMutations(bo *retry.Backoffer, action
 			logutil.Logger(bo.GetCtx()).Warn("the store is closed",
 				zap.Uint64("startTS", c.startTS), zap.Uint64("commitTS", c.commitTS),
 				zap.Uint64("sessionID", c.sessionID))
+			c.txn.secondaries.finish(errors.New("secondaries are not committed as the store is closed"))
 			return nil
 		}
+		c.txn.secondaries.background = true
 		err = c.txn.spawnWithStorePool(func() {
 			if c.sessionID > 0 {
 				if v, err := util.EvalFailpoint("beforeCommitSecondaries"); err == nil {
@@ -1029,13 +1042,16 @@ func (c *twoPhaseCommitter) doActionOnGroupMutations(bo *retry.Backoffer, action
 					} else if s == "skip" {
 						logutil.Logger(bo.GetCtx()).Info("[failpoint] injected skip committing secondaries",
 							zap.Uint64("sessionID", c.sessionID), zap.Uint64("txnStartTS", c.startTS), zap.Uint64("txnCommitTS", c.commitTS))
+						c.txn.secondaries.finish(errors.New("injected skip committing secondaries"))
 						return
 					}
 				}
 			}
 
 			e := c.doActionOnBatches(secondaryBo, action, batchBuilder.allBatches())
+			c.txn.secondaries.finish(e)
 			if e != nil {
+				c.store.AsyncOps().fail(errors.WithMessagef(e, "commit secondaries of txn %d", c.startTS))
 				logutil.BgLogger().Debug("2PC async doActionOnBatches",
 					zap.Uint64("session", c.sessionID),
 					zap.Stringer("action type", action),
@@ -1044,6 +1060,7 @@ func (c *twoPhaseCommitter) doActionOnGroupMutations(bo *retry.Backoffer, action
 			}
 		})
 		if err != nil {
+			c.txn.secondaries.background = false
 			logutil.BgLogger().Error("fail to create goroutine",
 				zap.Uint64("session", c.sessionID),
 				zap.Stringer("action type", action),
@@ -1119,13 +1136,24 @@ func (c *twoPhaseCommitter) calcActionConcurrency(
 	case actionPipelinedFlush:
 		rateLim = min(rateLim, max(1, c.txn.pipelinedFlushConcurrency))
 	default:
-		if rateLim > config.GetGlobalConfig().CommitterConcurrency {
-			rateLim = config.GetGlobalConfig().CommitterConcurrency
+		concurrency := config.GetGlobalConfig().CommitterConcurrency
+		if c.txn != nil && c.txn.commitConcurrency > 0 {
+			concurrency = c.txn.commitConcurrency
+		}
+		if rateLim > concurrency {
+			rateLim = concurrency
 		}
 	}
 	return rateLim
 }
 
+func (c *twoPhaseCommitter) commitBatchSize() int {
+	if c.txn != nil && c.txn.commitBatchSize > 0 {
+		return c.txn.commitBatchSize
+	}
+	return int(kv.TxnCommitBatchSize.Load())
+}
+
 func (c *twoPhaseCommitter) keyValueSize(key, value []byte) int {
 	return len(key) + len(value)
 }
@@ -1507,7 +1535,7 @@ func sendTxnHeartBeat(
 		}
 		cmdResp := resp.Resp.(*kvrpcpb.TxnHeartBeatResponse)
 		if keyErr := cmdResp.GetError(); keyErr != nil {
-			return 0, true, errors.Errorf("txn %d heartbeat fail, primary key = %v, err = %s", startTS, hex.EncodeToString(primary), tikverr.ExtractKeyErr(keyErr))
+			return 0, true, errors.Errorf("txn %d heartbeat fail, primary key = %v, err = %s", startTS, redact.Key(primary), tikverr.ExtractKeyErr(keyErr))
 		}
 		return cmdResp.GetLockTtl(), false, nil
 	}
@@ -1544,18 +1572,62 @@ func (c *twoPhaseCommitter) checkAsyncCommit() bool {
 	return false
 }
 
+// The reasons why a transaction isn't committed by 1PC.
+const (
+	onePCReasonDisabled           = "disabled"
+	onePCReasonAssertion          = "assertion"
+	onePCReasonPipelined          = "pipelined"
+	onePCReasonLocalScope         = "local-scope"
+	onePCReasonCommitTSUpperBound = "commit-ts-upper-bound-check"
+	onePCReasonBinlog             = "binlog"
+	onePCReasonKeysLimit          = "keys-limit"
+	onePCReasonSizeLimit          = "size-limit"
+	onePCReasonMultipleBatches    = "multiple-batches"
+	// TiKV prewrites the keys with 2PC instead, e.g. for the unsupported
+	// requests. It's counted by OnePCTxnCounterFallback.
+	onePCReasonServerFallback = "server-fallback"
+)
+
 // checkOnePC checks if 1PC protocol is available for current transaction.
 func (c *twoPhaseCommitter) checkOnePC() bool {
-	// Disable 1PC in local transactions
-	if c.txn.GetScope() != oracle.GlobalTxnScope {
-		return false
+	c.onePCIneligibleReason = c.ineligibleForOnePC()
+	if len(c.onePCIneligibleReason) == 0 {
+		return true
 	}
-	// Disable 1PC for transaction when commitTSUpperBoundCheck is set.
-	if c.txn.commitTSUpperBoundCheck != nil {
-		return false
+	if c.onePCIneligibleReason != onePCReasonDisabled {
+		metrics.TiKVOnePCIneligibleCounter.WithLabelValues(c.onePCIneligibleReason).Inc()
 	}
+	return false
+}
 
-	return !c.shouldWriteBinlog() && c.txn.enable1PC
+// ineligibleForOnePC returns why 1PC can't be used for the transaction before
+// grouping the mutations by the regions, or an empty string if it may be used.
+func (c *twoPhaseCommitter) ineligibleForOnePC() string {
+	switch {
+	// The assertion failure found when initializing the mutations disables 1PC.
+	case c.stashedAssertionError != nil:
+		return onePCReasonAssertion
+	case !c.txn.enable1PC:
+		return onePCReasonDisabled
+	case c.txn.isPipelined:
+		return onePCReasonPipelined
+	// Disable 1PC in local transactions
+	case c.txn.GetScope() != oracle.GlobalTxnScope:
+		return onePCReasonLocalScope
+	// Disable 1PC for transaction when commitTSUpperBoundCheck is set.
+	case c.txn.commitTSUpperBoundCheck != nil:
+		return onePCReasonCommitTSUpperBound
+	case c.shouldWriteBinlog():
+		return onePCReasonBinlog
+	}
+	cfg := config.GetGlobalConfig().TiKVClient.OnePC
+	if cfg.KeysLimit > 0 && uint(c.mutations.Len()) > cfg.KeysLimit {
+		return onePCReasonKeysLimit
+	}
+	if cfg.TotalSizeLimit > 0 && uint64(c.txnSize) > cfg.TotalSizeLimit {
+		return onePCReasonSizeLimit
+	}
+	return ""
 }
 
 func (c *twoPhaseCommitter) needLinearizability() bool {
@@ -1586,12 +1658,20 @@ func (c *twoPhaseCommitter) setOnePC(val bool) {
 	}
 }
 
-func (c *twoPhaseCommitter) checkOnePCFallBack(action twoPhaseCommitAction, batchCount int) {
+// checkOnePCFallBack falls back to 2PC if the prewrite needs more than one
+// request, or returns ErrOnePCIneligible if the transaction requires 1PC.
+func (c *twoPhaseCommitter) checkOnePCFallBack(action twoPhaseCommitAction, batchCount int) error {
 	if _, ok := action.(actionPrewrite); ok {
-		if batchCount > 1 {
+		if batchCount > 1 && c.isOnePC() {
+			c.onePCIneligibleReason = onePCReasonMultipleBatches
+			metrics.TiKVOnePCIneligibleCounter.WithLabelValues(onePCReasonMultipleBatches).Inc()
+			if c.txn.require1PC {
+				return errors.WithStack(&tikverr.ErrOnePCIneligible{Reason: onePCReasonMultipleBatches})
+			}
 			c.setOnePC(false)
 		}
 	}
+	return nil
 }
 
 const (
@@ -1653,6 +1733,7 @@ func (c *twoPhaseCommitter) cleanup(ctx context.Context) {
 		}
 
 		if err != nil {
+			c.store.AsyncOps().fail(errors.WithMessagef(err, "cleanup txn %d", c.startTS))
 			metrics.SecondaryLockCleanupFailureCounterRollback.Inc()
 			logutil.Logger(ctx).Info("2PC cleanup failed", zap.Error(err), zap.Uint64("txnStartTS", c.startTS),
 				zap.Bool("isPessimistic", c.isPessimistic), zap.Bool("isOnePC", c.isOnePC()))
@@ -1974,15 +2055,20 @@ func (c *twoPhaseCommitter) execute(ctx context.Context) (err error) {
 			logutil.Logger(ctx).Warn("2PC will use async commit protocol to commit this txn but the store is closed",
 				zap.Uint64("startTS", c.startTS), zap.Uint64("commitTS", c.commitTS),
 				zap.Uint64("sessionID", c.sessionID))
+			c.txn.secondaries.finish(errors.New("secondaries are not committed as the store is closed"))
 			return nil
 		}
+		c.txn.secondaries.background = true
 		c.txn.spawn(func() {
 			if _, err := util.EvalFailpoint("asyncCommitDoNothing"); err == nil {
+				c.txn.secondaries.finish(errors.New("injected skip committing async commit txn"))
 				return
 			}
 			commitBo := retry.NewBackofferWithVars(c.store.Ctx(), CommitSecondaryMaxBackoff, c.txn.vars)
 			err := c.commitMutations(commitBo, c.mutations)
+			c.txn.secondaries.finish(err)
 			if err != nil {
+				c.store.AsyncOps().fail(errors.WithMessagef(err, "async commit txn %d", c.startTS))
 				logutil.Logger(ctx).Warn("2PC async commit failed", zap.Uint64("sessionID", c.sessionID),
 					zap.Uint64("startTS", c.startTS), zap.Uint64("commitTS", c.commitTS), zap.Error(err))
 			}
@@ -2013,6 +2099,9 @@ func (c *twoPhaseCommitter) commitTxn(ctx context.Context, commitDetail *util.Co
 				zap.NamedError("rpcErr", undeterminedErr),
 				zap.Uint64("txnStartTS", c.startTS))
 			err = errors.WithStack(tikverr.ErrResultUndetermined)
+			if c.txn.checkUndeterminedCommit {
+				err = c.checkUndeterminedCommit(ctx, err)
+			}
 		}
 		if !c.mu.committed {
 			logutil.Logger(ctx).Debug("2PC failed on commit",
@@ -2233,11 +2322,19 @@ func (batchExe *batchExecutor) initUtils() error {
 func (batchExe *batchExecutor) startWorker(exitCh chan struct{}, ch chan error, batches []batchMutations) {
 	for idx, batch1 := range batches {
 		waitStart := time.Now()
-		if exit := batchExe.rateLimiter.GetToken(exitCh); !exit {
+		exit := batchExe.rateLimiter.GetToken(exitCh)
+		size := batchBytes(batchExe.action, batch1)
+		if !exit {
+			if exit = globalCommitBytesBudget.acquire(size, exitCh); exit {
+				batchExe.rateLimiter.PutToken()
+			}
+		}
+		if !exit {
 			batchExe.tokenWaitDuration += time.Since(waitStart)
 			batch := batch1
 			go func() {
 				defer batchExe.rateLimiter.PutToken()
+				defer globalCommitBytesBudget.release(size)
 				var singleBatchBackoffer *retry.Backoffer
 				if _, ok := batchExe.action.(actionCommit); ok {
 					// Because the secondary batches of the commit actions are implemented to be
@@ -2359,6 +2456,37 @@ func (c *twoPhaseCommitter) getDetail() *util.CommitDetails {
 	return (*util.CommitDetails)(atomic.LoadPointer(&c.detail))
 }
 
+// checkUndeterminedCommit finds out whether the commit of the primary key with the undetermined result is applied by
+// checking the status of the primary key. It returns nil if the transaction is committed, ErrCommitRolledBack if it's
+// rolled back, and err if the outcome is still unknown.
+func (c *twoPhaseCommitter) checkUndeterminedCommit(ctx context.Context, err error) error {
+	// The callerStartTS is 0 so the min commit ts of the primary lock isn't pushed.
+	status, checkErr := c.store.GetLockResolver().GetTxnStatus(c.startTS, 0, c.primary())
+	if checkErr != nil || status.TTL() != 0 {
+		// The primary lock is alive, the commit request may be still in flight.
+		logutil.Logger(ctx).Warn("2PC commit result is still undetermined after checking the txn status",
+			zap.Uint64("txnStartTS", c.startTS),
+			zap.Stringer("status", status),
+			zap.NamedError("checkErr", checkErr))
+		return err
+	}
+	c.setUndeterminedErr(nil)
+	if status.IsRolledBack() {
+		logutil.Logger(ctx).Info("2PC commit result undetermined, the txn is rolled back",
+			zap.Uint64("txnStartTS", c.startTS))
+		return errors.WithStack(tikverr.ErrCommitRolledBack)
+	}
+	logutil.Logger(ctx).Info("2PC commit result undetermined, the txn is committed",
+		zap.Uint64("txnStartTS", c.startTS),
+		zap.Uint64("commitTS", status.CommitTS()))
+	// The secondary locks are left to be resolved by the readers.
+	c.mu.Lock()
+	c.mu.committed = true
+	c.commitTS = status.CommitTS()
+	c.mu.Unlock()
+	return err
+}
+
 func (c *twoPhaseCommitter) setUndeterminedErr(err error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
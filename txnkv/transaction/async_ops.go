@@ -0,0 +1,127 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// maxAsyncOpsErrs is the max number of the errors kept for the next barrier,
+// the following ones are only counted.
+const maxAsyncOpsErrs = 16
+
+// AsyncOps tracks the operations the transactions keep running in the
+// background after returning to the callers, e.g. committing the secondaries,
+// the async commit, the pipelined flushes and the cleanups, so that a barrier
+// can wait for them.
+type AsyncOps struct {
+	mu sync.Mutex
+	// seq is the sequence number of the last started operation.
+	seq     uint64
+	pending map[uint64]struct{}
+	// changed is closed and replaced when an operation finishes.
+	changed chan struct{}
+	errs    []error
+	// dropped is the number of the errors not kept in errs.
+	dropped int
+}
+
+// begin starts tracking an operation, and returns the function to call when
+// it finishes.
+func (o *AsyncOps) begin() func() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.pending == nil {
+		o.pending = make(map[uint64]struct{})
+	}
+	o.seq++
+	seq := o.seq
+	o.pending[seq] = struct{}{}
+	return func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		delete(o.pending, seq)
+		if o.changed != nil {
+			close(o.changed)
+			o.changed = nil
+		}
+	}
+}
+
+// fail records the error of an operation to be returned by the next barrier.
+// Only the first maxAsyncOpsErrs errors are kept, so the errors don't pile up
+// if no barrier is ever called.
+func (o *AsyncOps) fail(err error) {
+	if err == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.errs) >= maxAsyncOpsErrs {
+		o.dropped++
+		return
+	}
+	o.errs = append(o.errs, err)
+}
+
+// Pending returns the number of the operations running.
+func (o *AsyncOps) Pending() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.pending)
+}
+
+// Barrier waits until the operations started before it finish, and returns
+// the errors of the operations failed since the last barrier, joined into one.
+// The errors are taken by the barrier, so they are returned only once. At most
+// maxAsyncOpsErrs of them are returned, followed by the number of the others.
+func (o *AsyncOps) Barrier(ctx context.Context) error {
+	o.mu.Lock()
+	target := o.seq
+	for {
+		waiting := false
+		for seq := range o.pending {
+			if seq <= target {
+				waiting = true
+				break
+			}
+		}
+		if !waiting {
+			break
+		}
+		if o.changed == nil {
+			o.changed = make(chan struct{})
+		}
+		changed := o.changed
+		o.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		}
+		o.mu.Lock()
+	}
+	errs, dropped := o.errs, o.dropped
+	o.errs, o.dropped = nil, 0
+	o.mu.Unlock()
+	if dropped > 0 {
+		errs = append(errs, errors.Errorf("%d more background operations failed", dropped))
+	}
+	return stderrors.Join(errs...)
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsyncOpsBarrier(t *testing.T) {
+	var ops AsyncOps
+	assert.NoError(t, ops.Barrier(context.Background()))
+
+	done1 := ops.begin()
+	done2 := ops.begin()
+	assert.Equal(t, 2, ops.Pending())
+
+	returned := make(chan error, 1)
+	go func() {
+		returned <- ops.Barrier(context.Background())
+	}()
+	assert.Eventually(t, func() bool {
+		ops.mu.Lock()
+		defer ops.mu.Unlock()
+		return ops.changed != nil
+	}, 5*time.Second, time.Millisecond)
+	// The operations started after the barrier are not waited.
+	done3 := ops.begin()
+	done1()
+	select {
+	case <-returned:
+		assert.Fail(t, "barrier returns before the operations finish")
+	case <-time.After(50 * time.Millisecond):
+	}
+	ops.fail(errors.New("op2 failed"))
+	done2()
+	select {
+	case err := <-returned:
+		assert.ErrorContains(t, err, "op2 failed")
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "barrier doesn't return")
+	}
+	assert.Equal(t, 1, ops.Pending())
+
+	// The errors are returned once.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, ops.Barrier(ctx), context.DeadlineExceeded)
+	done3()
+	assert.NoError(t, ops.Barrier(context.Background()))
+}
+
+func TestAsyncOpsErrsCapped(t *testing.T) {
+	var ops AsyncOps
+	for i := 0; i < maxAsyncOpsErrs+3; i++ {
+		ops.fail(errors.Errorf("op%d failed", i))
+	}
+	ops.mu.Lock()
+	assert.Len(t, ops.errs, maxAsyncOpsErrs)
+	ops.mu.Unlock()
+
+	err := ops.Barrier(context.Background())
+	assert.ErrorContains(t, err, "op0 failed")
+	assert.NotContains(t, err.Error(), fmt.Sprintf("op%d failed", maxAsyncOpsErrs))
+	assert.ErrorContains(t, err, "3 more background operations failed")
+	assert.NoError(t, ops.Barrier(context.Background()))
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/internal/fuzzutil"
+	"github.com/tikv/client-go/v2/internal/locate"
+)
+
+func FuzzAppendBatchMutationsBySize(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0, 3, 0, 2, 'a', 'b', 0, 1, 'c', 0, 0, 0, 8, 0, 1})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := fuzzutil.NewReader(data)
+		keys := r.Keys(64, 16)
+		limit := r.Intn(64) + 1
+		var primary []byte
+		if len(keys) > 0 {
+			primary = keys[r.Intn(len(keys))]
+		}
+		mutations := NewPlainMutations(len(keys))
+		for _, k := range keys {
+			mutations.Push(kvrpcpb.Op_Put, k, r.Bytes(16), false, false, false, false)
+		}
+		sizeFn := func(k, v []byte) int { return len(k) + len(v) + 1 }
+
+		b := newBatched(primary)
+		b.appendBatchMutationsBySize(locate.RegionVerID{}, &mutations, sizeFn, limit)
+		var got [][]byte
+		for _, batch := range b.allBatches() {
+			n := batch.mutations.Len()
+			require.Greater(t, n, 0)
+			// A batch only exceeds the limit by its last mutation.
+			size := 0
+			for i := 0; i < n-1; i++ {
+				size += sizeFn(batch.mutations.GetKey(i), batch.mutations.GetValue(i))
+			}
+			require.Less(t, size, limit)
+			got = append(got, batch.mutations.GetKeys()...)
+		}
+		require.Equal(t, len(keys), len(got))
+		for i := range keys {
+			require.True(t, bytes.Equal(keys[i], got[i]))
+		}
+
+		require.Equal(t, len(keys) > 0, b.setPrimary())
+		if len(keys) > 0 {
+			found := false
+			for _, k := range b.primaryBatch()[0].mutations.GetKeys() {
+				found = found || bytes.Equal(k, primary)
+			}
+			require.True(t, found)
+		}
+	})
+}
@@ -36,7 +36,6 @@ package transaction
 
 import (
 	"bytes"
-	"encoding/hex"
 	"time"
 
 	"github.com/opentracing/opentracing-go"
@@ -50,6 +49,8 @@ import (
 	"github.com/tikv/client-go/v2/internal/logutil"
 	"github.com/tikv/client-go/v2/metrics"
 	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/util"
+	"github.com/tikv/client-go/v2/util/redact"
 	"go.uber.org/zap"
 )
 
@@ -105,6 +106,7 @@ func (action actionCommit) handleSingleBatch(c *twoPhaseCommitter, bo *retry.Bac
 			tBegin = time.Now()
 		}
 
+		req.MaxExecutionDurationMs = util.MaxExecutionDurationMs(bo.GetCtx(), client.MaxWriteExecutionTime)
 		resp, _, err := sender.SendReq(bo, req, batch.region, client.ReadTimeoutShort)
 		// If we fail to receive response for the request that commits primary key, it will be undetermined whether this
 		// transaction has been successfully committed.
@@ -165,8 +167,8 @@ func (action actionCommit) handleSingleBatch(c *twoPhaseCommitter, bo *retry.Bac
 				if !batch.isPrimary || !bytes.Equal(rejected.Key, c.primary()) {
 					logutil.Logger(bo.GetCtx()).Error("2PC commitTS rejected by TiKV, but the key is not the primary key",
 						zap.Uint64("txnStartTS", c.startTS),
-						zap.String("key", hex.EncodeToString(rejected.Key)),
-						zap.String("primary", hex.EncodeToString(c.primary())),
+						zap.String("key", redact.Key(rejected.Key)),
+						zap.String("primary", redact.Key(c.primary())),
 						zap.Bool("batchIsPrimary", batch.isPrimary))
 					return errors.New("2PC commitTS rejected by TiKV, but the key is not the primary key")
 				}
@@ -204,7 +206,7 @@ func (action actionCommit) handleSingleBatch(c *twoPhaseCommitter, bo *retry.Bac
 				hexBatchKeys := func(keys [][]byte) []string {
 					var res []string
 					for _, k := range keys {
-						res = append(res, hex.EncodeToString(k))
+						res = append(res, redact.Key(k))
 					}
 					return res
 				}
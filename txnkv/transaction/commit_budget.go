@@ -0,0 +1,96 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction
+
+import (
+	"sync"
+
+	"github.com/tikv/client-go/v2/kv"
+)
+
+// commitBytesBudget schedules the batches of all the concurrently committing
+// transactions so that the total size of in-flight requests doesn't exceed
+// kv.TxnCommitInFlightBytesLimit. Only transactions with multiple batches go
+// through it, so small transactions are never blocked by large ones.
+type commitBytesBudget struct {
+	mu     sync.Mutex
+	used   int64
+	notify chan struct{}
+}
+
+var globalCommitBytesBudget = newCommitBytesBudget()
+
+func newCommitBytesBudget() *commitBytesBudget {
+	return &commitBytesBudget{notify: make(chan struct{})}
+}
+
+// acquire blocks until size bytes are available or done is closed. A batch is
+// always admitted if nothing is in flight, so a batch larger than the limit
+// can still make progress.
+func (b *commitBytesBudget) acquire(size int64, done <-chan struct{}) (exit bool) {
+	for {
+		limit := kv.TxnCommitInFlightBytesLimit.Load()
+		b.mu.Lock()
+		if limit <= 0 || b.used == 0 || b.used+size <= limit {
+			b.used += size
+			b.mu.Unlock()
+			return false
+		}
+		notify := b.notify
+		b.mu.Unlock()
+		select {
+		case <-done:
+			return true
+		case <-notify:
+		}
+	}
+}
+
+// release gives size bytes back and wakes up the waiting batches.
+func (b *commitBytesBudget) release(size int64) {
+	b.mu.Lock()
+	b.used -= size
+	close(b.notify)
+	b.notify = make(chan struct{})
+	b.mu.Unlock()
+}
+
+// inFlight returns the size of in-flight requests.
+func (b *commitBytesBudget) inFlight() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// batchBytes returns the size of the batch counted by the budget, which is 0 if
+// the budget is disabled so the mutations are not walked for nothing.
+func batchBytes(action twoPhaseCommitAction, batch batchMutations) int64 {
+	if kv.TxnCommitInFlightBytesLimit.Load() <= 0 {
+		return 0
+	}
+	withValue := false
+	switch action.(type) {
+	case actionPrewrite, actionPipelinedFlush:
+		withValue = true
+	}
+	var size int64
+	for i := 0; i < batch.mutations.Len(); i++ {
+		size += int64(len(batch.mutations.GetKey(i)))
+		if withValue {
+			size += int64(len(batch.mutations.GetValue(i)))
+		}
+	}
+	return size
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tikv/client-go/v2/kv"
+)
+
+func TestCommitBytesBudget(t *testing.T) {
+	defer kv.TxnCommitInFlightBytesLimit.Store(kv.TxnCommitInFlightBytesLimit.Load())
+	kv.TxnCommitInFlightBytesLimit.Store(100)
+
+	b := newCommitBytesBudget()
+	// A batch larger than the limit is admitted when nothing is in flight.
+	assert.False(t, b.acquire(150, nil))
+	assert.Equal(t, int64(150), b.inFlight())
+	b.release(150)
+
+	assert.False(t, b.acquire(60, nil))
+	acquired := make(chan struct{})
+	go func() {
+		assert.False(t, b.acquire(60, nil))
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		assert.Fail(t, "should wait for the budget")
+	case <-time.After(50 * time.Millisecond):
+	}
+	b.release(60)
+	<-acquired
+	assert.Equal(t, int64(60), b.inFlight())
+
+	// Waiting can be canceled.
+	done := make(chan struct{})
+	close(done)
+	assert.True(t, b.acquire(60, done))
+	assert.Equal(t, int64(60), b.inFlight())
+
+	// No limit.
+	kv.TxnCommitInFlightBytesLimit.Store(0)
+	assert.False(t, b.acquire(1000, nil))
+	assert.Equal(t, int64(1060), b.inFlight())
+}
@@ -0,0 +1,119 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction
+
+import (
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config"
+	tikverr "github.com/tikv/client-go/v2/error"
+)
+
+// CommitSizeEstimate is the estimated size of the mutations a transaction commits.
+type CommitSizeEstimate struct {
+	// Mutations is the number of the keys to prewrite.
+	Mutations int
+	// Size is the total size of the keys and values.
+	Size uint64
+	// MaxKeySize is the size of the largest key.
+	MaxKeySize uint64
+	// MaxEntrySize is the size of the largest key and its value.
+	MaxEntrySize uint64
+}
+
+// EstimateCommitSize estimates the size of the mutations the transaction commits if
+// it's committed now. It's an upper bound, since some of the mutations may be found
+// unnecessary when committing.
+func (txn *KVTxn) EstimateCommitSize() CommitSizeEstimate {
+	var est CommitSizeEstimate
+	txn.forEachCommitEntry(func(key, value []byte) bool {
+		est.Mutations++
+		entrySize := uint64(len(key) + len(value))
+		est.Size += entrySize
+		est.MaxKeySize = max(est.MaxKeySize, uint64(len(key)))
+		est.MaxEntrySize = max(est.MaxEntrySize, entrySize)
+		return true
+	})
+	return est
+}
+
+// ValidateCommitSize checks the mutations of the transaction against
+// config.CommitSizeLimit, and returns *tikverr.ErrCommitSizeLimit for the first limit
+// exceeded. It walks the membuffer, so it's for checking in advance to split the
+// work; Commit checks the sizes collected when building the mutations instead.
+func (txn *KVTxn) ValidateCommitSize() error {
+	limit := config.GetGlobalConfig().TiKVClient.CommitSizeLimit
+	if limit.MaxKeySize == 0 && limit.MaxEntrySize == 0 && limit.MaxTxnSize == 0 {
+		return nil
+	}
+	var sizes commitSizes
+	txn.forEachCommitEntry(func(key, value []byte) bool {
+		sizes.add(key, value)
+		return true
+	})
+	return sizes.check(limit)
+}
+
+// commitSizes collects the sizes of the mutations to check them against
+// config.CommitSizeLimit.
+type commitSizes struct {
+	size         uint64
+	maxKey       []byte
+	maxKeySize   uint64
+	maxEntryKey  []byte
+	maxEntrySize uint64
+}
+
+func (s *commitSizes) add(key, value []byte) {
+	keySize, entrySize := uint64(len(key)), uint64(len(key)+len(value))
+	s.size += entrySize
+	if keySize > s.maxKeySize {
+		s.maxKey, s.maxKeySize = key, keySize
+	}
+	if entrySize > s.maxEntrySize {
+		s.maxEntryKey, s.maxEntrySize = key, entrySize
+	}
+}
+
+// check returns *tikverr.ErrCommitSizeLimit of the largest key or entry
+// exceeding the limit, or of the total size.
+func (s *commitSizes) check(limit config.CommitSizeLimit) error {
+	var err error
+	switch {
+	case limit.MaxKeySize > 0 && s.maxKeySize > limit.MaxKeySize:
+		err = &tikverr.ErrCommitSizeLimit{Limit: tikverr.CommitSizeLimitKey, Key: s.maxKey, Size: s.maxKeySize, Max: limit.MaxKeySize}
+	case limit.MaxEntrySize > 0 && s.maxEntrySize > limit.MaxEntrySize:
+		err = &tikverr.ErrCommitSizeLimit{Limit: tikverr.CommitSizeLimitEntry, Key: s.maxEntryKey, Size: s.maxEntrySize, Max: limit.MaxEntrySize}
+	case limit.MaxTxnSize > 0 && s.size > limit.MaxTxnSize:
+		err = &tikverr.ErrCommitSizeLimit{Limit: tikverr.CommitSizeLimitTxn, Size: s.size, Max: limit.MaxTxnSize}
+	}
+	return errors.WithStack(err)
+}
+
+// forEachCommitEntry calls f with the keys to prewrite and their values, until f
+// returns false. The locked keys without values are called with nil values.
+func (txn *KVTxn) forEachCommitEntry(f func(key, value []byte) bool) {
+	memBuf := txn.GetMemBuffer().GetMemDB()
+	for it := memBuf.IterWithFlags(nil, nil); it.Valid(); _ = it.Next() {
+		var value []byte
+		if it.HasValue() {
+			value = it.Value()
+		} else if !it.Flags().HasLocked() {
+			continue
+		}
+		if !f(it.Key(), value) {
+			return
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/config"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/kv"
+	tikvtesting "github.com/tikv/client-go/v2/tikv/testing"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+)
+
+func TestCommitSizeLimit(t *testing.T) {
+	store, err := tikvtesting.NewStore()
+	require.Nil(t, err)
+	defer store.Close()
+	require.NotZero(t, config.DefaultTiKVClient().CommitSizeLimit.MaxTxnSize)
+
+	defer config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.CommitSizeLimit = config.CommitSizeLimit{MaxKeySize: 8, MaxEntrySize: 16, MaxTxnSize: 32}
+	})()
+	ctx := context.Background()
+
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	require.Nil(t, txn.Set([]byte("k1"), []byte("value1")))
+	require.Nil(t, txn.Delete([]byte("k2")))
+	require.Nil(t, txn.LockKeys(ctx, kv.NewLockCtx(txn.StartTS(), 0, time.Now()), []byte("k3")))
+	require.Equal(t, transaction.CommitSizeEstimate{Mutations: 3, Size: 12, MaxKeySize: 2, MaxEntrySize: 8}, txn.EstimateCommitSize())
+	require.Nil(t, txn.ValidateCommitSize())
+
+	check := func(limit string, key []byte, mutate func(txn *transaction.KVTxn)) {
+		txn, err := store.Begin()
+		require.Nil(t, err)
+		mutate(txn)
+		err = txn.Commit(ctx)
+		var e *tikverr.ErrCommitSizeLimit
+		require.ErrorAs(t, err, &e)
+		require.Equal(t, limit, e.Limit)
+		require.Equal(t, key, e.Key)
+	}
+	check(tikverr.CommitSizeLimitKey, []byte("too-long-key"), func(txn *transaction.KVTxn) {
+		require.Nil(t, txn.Set([]byte("too-long-key"), []byte("v")))
+	})
+	check(tikverr.CommitSizeLimitEntry, []byte("k"), func(txn *transaction.KVTxn) {
+		require.Nil(t, txn.Set([]byte("k"), []byte("a-too-large-value")))
+	})
+	check(tikverr.CommitSizeLimitTxn, nil, func(txn *transaction.KVTxn) {
+		for i := 0; i < 4; i++ {
+			require.Nil(t, txn.Set([]byte(fmt.Sprintf("k%d", i)), []byte("value-6")))
+		}
+	})
+	// Nothing is written.
+	snapshot := store.GetSnapshot(math.MaxUint64)
+	_, err = snapshot.Get(ctx, []byte("k0"))
+	require.True(t, tikverr.IsErrNotFound(err))
+}
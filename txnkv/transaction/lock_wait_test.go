@@ -0,0 +1,65 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/kv"
+	tikvtesting "github.com/tikv/client-go/v2/tikv/testing"
+)
+
+func TestLockWaitPolicy(t *testing.T) {
+	store, err := tikvtesting.NewStore()
+	require.Nil(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	holder, err := store.Begin()
+	require.Nil(t, err)
+	holder.SetPessimistic(true)
+	require.Nil(t, holder.LockKeysWithWaitTime(ctx, kv.LockNoWait, []byte("k1")))
+	defer holder.Rollback()
+
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	txn.SetPessimistic(true)
+	defer txn.Rollback()
+
+	var lockWaitErr *tikverr.ErrLockWait
+	err = txn.LockKeysWithWaitTime(ctx, kv.LockNoWait, []byte("k1"))
+	require.ErrorIs(t, err, tikverr.ErrLockAcquireFailAndNoWaitSet)
+	require.ErrorAs(t, err, &lockWaitErr)
+	require.Equal(t, tikverr.ErrLockAcquireFailAndNoWaitSet.Error(), err.Error())
+	require.Equal(t, []byte("k1"), lockWaitErr.Key)
+	require.Equal(t, []byte("k1"), lockWaitErr.Primary)
+	require.Equal(t, holder.StartTS(), lockWaitErr.LockTS)
+	require.Positive(t, lockWaitErr.LockTTL)
+
+	start := time.Now()
+	err = txn.LockKeysWithWaitTime(ctx, kv.LockWaitTimeout(50*time.Millisecond), []byte("k1"))
+	require.ErrorIs(t, err, tikverr.ErrLockWaitTimeout)
+	require.ErrorAs(t, err, &lockWaitErr)
+	require.Equal(t, holder.StartTS(), lockWaitErr.LockTS)
+	require.GreaterOrEqual(t, lockWaitErr.WaitTime, 50*time.Millisecond)
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	require.Equal(t, kv.LockNoWait, kv.LockWaitTimeout(0))
+	require.Equal(t, int64(1500), kv.LockWaitTimeout(1500*time.Millisecond))
+}
@@ -35,7 +35,6 @@
 package transaction
 
 import (
-	"encoding/hex"
 	"math/rand"
 	"strings"
 	"sync/atomic"
@@ -55,6 +54,7 @@ import (
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/txnkv/txnlock"
 	"github.com/tikv/client-go/v2/util"
+	"github.com/tikv/client-go/v2/util/redact"
 	"go.uber.org/zap"
 )
 
@@ -172,7 +172,7 @@ func (action actionPessimisticLock) handleSingleBatch(
 			ttl = 1
 			keys := make([]string, 0, len(mutations))
 			for _, m := range mutations {
-				keys = append(keys, hex.EncodeToString(m.Key))
+				keys = append(keys, redact.Key(m.Key))
 			}
 			logutil.BgLogger().Debug(
 				"[failpoint] injected lock ttl = 1 on pessimistic lock",
@@ -372,13 +372,13 @@ func (action actionPessimisticLock) handlePessimisticLockResponseNormalMode(
 	// the pessimistic lock. We should return acquire fail with nowait set or timeout error if necessary.
 	if resolveLockRes.TTL > 0 {
 		if action.LockWaitTime() == kv.LockNoWait {
-			return true, errors.WithStack(tikverr.ErrLockAcquireFailAndNoWaitSet)
+			return true, action.lockWaitErr(tikverr.ErrLockAcquireFailAndNoWaitSet, locks)
 		} else if action.LockWaitTime() == kv.LockAlwaysWait {
 			// do nothing but keep wait
 		} else {
 			// the lockWaitTime is set, we should return wait timeout if we are still blocked by a lock
 			if time.Since(action.WaitStartTime).Milliseconds() >= action.LockWaitTime() {
-				return true, errors.WithStack(tikverr.ErrLockWaitTimeout)
+				return true, action.lockWaitErr(tikverr.ErrLockWaitTimeout, locks)
 			}
 		}
 		if action.LockCtx.PessimisticLockWaited != nil {
@@ -389,6 +389,20 @@ func (action actionPessimisticLock) handlePessimisticLockResponseNormalMode(
 	return false, nil
 }
 
+// lockWaitErr returns the error of giving up waiting for the locks by the lock wait policy, carrying the first lock
+// blocking the request.
+func (action actionPessimisticLock) lockWaitErr(err error, locks []*txnlock.Lock) error {
+	e := &tikverr.ErrLockWait{Err: err, WaitTime: time.Since(action.WaitStartTime)}
+	if len(locks) > 0 {
+		e.Key = locks[0].Key
+		e.Primary = locks[0].Primary
+		e.LockTS = locks[0].TxnID
+		e.LockTTL = locks[0].TTL
+	}
+	// Not wrapped by errors.WithStack, so errors.Unwrap returns the sentinel error as before.
+	return e
+}
+
 func (action actionPessimisticLock) handlePessimisticLockResponseForceLockMode(
 	c *twoPhaseCommitter, bo *retry.Backoffer, batch *batchMutations, mutationsPb []*kvrpcpb.Mutation,
 	resp *tikvrpc.Response, diagCtx *diagnosticContext,
@@ -501,13 +515,13 @@ func (action actionPessimisticLock) handlePessimisticLockResponseForceLockMode(
 			// the pessimistic lock. We should return acquire fail with nowait set or timeout error if necessary.
 			if resolveLockRes.TTL > 0 {
 				if action.LockWaitTime() == kv.LockNoWait {
-					return true, errors.WithStack(tikverr.ErrLockAcquireFailAndNoWaitSet)
+					return true, action.lockWaitErr(tikverr.ErrLockAcquireFailAndNoWaitSet, locks)
 				} else if action.LockWaitTime() == kv.LockAlwaysWait {
 					// do nothing but keep wait
 				} else {
 					// the lockWaitTime is set, we should return wait timeout if we are still blocked by a lock
 					if time.Since(action.WaitStartTime).Milliseconds() >= action.LockWaitTime() {
-						return true, errors.WithStack(tikverr.ErrLockWaitTimeout)
+						return true, action.lockWaitErr(tikverr.ErrLockWaitTimeout, locks)
 					}
 				}
 				if action.LockCtx.PessimisticLockWaited != nil {
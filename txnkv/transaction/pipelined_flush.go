@@ -26,6 +26,7 @@ import (
 	"github.com/golang/protobuf/proto" //nolint:staticcheck
 	"github.com/opentracing/opentracing-go"
 	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/errorpb"
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tikv/client-go/v2/config/retry"
@@ -37,6 +38,7 @@ import (
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/txnkv/rangetask"
 	"github.com/tikv/client-go/v2/txnkv/txnlock"
+	"github.com/tikv/client-go/v2/txnkv/txnsnapshot"
 	"github.com/tikv/client-go/v2/util"
 	"go.uber.org/zap"
 )
@@ -272,11 +274,13 @@ func (action actionPipelinedFlush) handleSingleBatch(
 		}
 		msBeforeExpired := resolveLockRes.TTL
 		if msBeforeExpired > 0 {
+			backoffStart := time.Now()
 			err = bo.BackoffWithCfgAndMaxSleep(
 				retry.BoTxnLock,
 				int(msBeforeExpired),
 				errors.Errorf("[pipelined dml] flush lockedKeys: %d", len(locks)),
 			)
+			resolveLockOpts.Detail.RecordBackoff(time.Since(backoffStart))
 			if err != nil {
 				logutil.Logger(bo.GetCtx()).Warn(
 					"[pipelined dml] backoff failed during flush",
@@ -358,6 +362,7 @@ func (c *twoPhaseCommitter) commitFlushedMutations(bo *retry.Backoffer) error {
 	)
 
 	if _, err := util.EvalFailpoint("pipelinedSkipResolveLock"); err == nil {
+		c.txn.secondaries.finish(errors.New("injected skip resolving flushed locks"))
 		return nil
 	}
 
@@ -468,6 +473,9 @@ func (c *twoPhaseCommitter) resolveFlushedLocks(bo *retry.Backoffer, start, end
 			zap.Uint64("startTS", c.startTS),
 			zap.Uint64("commitTS", commitTs),
 		)
+		if commit {
+			c.txn.secondaries.finish(err)
+		}
 		return
 	}
 
@@ -485,8 +493,16 @@ func (c *twoPhaseCommitter) resolveFlushedLocks(bo *retry.Backoffer, start, end
 	)
 	runner.SetStatLogInterval(30 * time.Second)
 
-	c.txn.spawnWithStorePool(func() {
-		if err = runner.RunOnRange(bo.GetCtx(), start, end); err != nil {
+	if commit {
+		c.txn.secondaries.background = true
+	}
+	spawnErr := c.txn.spawnWithStorePool(func() {
+		err = runner.RunOnRange(bo.GetCtx(), start, end)
+		if commit {
+			c.txn.secondaries.finish(err)
+		}
+		if err != nil {
+			c.store.AsyncOps().fail(errors.WithMessage(err, fmt.Sprintf("resolve flushed locks of txn %d", c.startTS)))
 			logutil.Logger(bo.GetCtx()).Error("[pipelined dml] resolve flushed locks failed",
 				zap.String("txn-status", status),
 				zap.Uint64("resolved regions", resolved.Load()),
@@ -529,4 +545,182 @@ func (c *twoPhaseCommitter) resolveFlushedLocks(bo *retry.Backoffer, start, end
 			)
 		}
 	})
+	if spawnErr != nil && commit {
+		c.txn.secondaries.finish(spawnErr)
+	}
+}
+
+const (
+	// scanFlushedLockLimit is the max number of locks returned by one ScanLock
+	// request when scanning the flushed mutations.
+	scanFlushedLockLimit  = 1024
+	scanFlushedMaxBackoff = 20000
+)
+
+// scanFlushedMutations implements unionstore.BufferScanner. It finds the keys
+// flushed by the transaction by scanning the locks of the transaction, and
+// reads the values of the put keys from the buffer in TiKV.
+//
+// A forward page is the locks returned by one ScanLock request from startKey,
+// while a reverse page is all the locks of the transaction in the last region
+// of the range, because ScanLock only scans forward.
+func (txn *KVTxn) scanFlushedMutations(ctx context.Context, startKey, endKey []byte, reverse bool) ([][]byte, [][]byte, []byte, error) {
+	bo := retry.NewBackofferWithVars(ctx, scanFlushedMaxBackoff, txn.vars)
+	var (
+		locks []*kvrpcpb.LockInfo
+		next  []byte
+	)
+	for {
+		var (
+			loc *locate.KeyLocation
+			err error
+		)
+		if reverse && len(endKey) > 0 {
+			loc, err = txn.store.GetRegionCache().LocateEndKey(bo, endKey)
+		} else {
+			loc, err = txn.store.GetRegionCache().LocateKey(bo, startKey)
+			// An unbounded reverse page is the last region, which is found by
+			// walking the regions from startKey.
+			for reverse && err == nil && len(loc.EndKey) > 0 {
+				loc, err = txn.store.GetRegionCache().LocateKey(bo, loc.EndKey)
+			}
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		scanStart, scanEnd := startKey, endKey
+		if bytes.Compare(loc.StartKey, scanStart) > 0 {
+			scanStart = loc.StartKey
+		}
+		if len(loc.EndKey) > 0 && (len(scanEnd) == 0 || bytes.Compare(loc.EndKey, scanEnd) < 0) {
+			scanEnd = loc.EndKey
+		}
+
+		locks = locks[:0]
+		key, regionMiss := scanStart, false
+		for {
+			page, regionErr, err := txn.scanFlushedLocks(bo, loc.Region, key, scanEnd)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if regionErr != nil {
+				if err = bo.Backoff(retry.BoRegionMiss, errors.New(regionErr.String())); err != nil {
+					return nil, nil, nil, err
+				}
+				regionMiss = true
+				break
+			}
+			locks = append(locks, page...)
+			if len(page) < scanFlushedLockLimit {
+				key = nil
+				break
+			}
+			key = kv.NextKey(page[len(page)-1].Key)
+			if !reverse {
+				break
+			}
+		}
+		if regionMiss {
+			continue
+		}
+
+		if reverse {
+			if len(loc.StartKey) > 0 && bytes.Compare(loc.StartKey, startKey) > 0 {
+				next = loc.StartKey
+			}
+		} else if key != nil {
+			if len(endKey) == 0 || bytes.Compare(key, endKey) < 0 {
+				next = key
+			}
+		} else if len(scanEnd) > 0 && (len(endKey) == 0 || bytes.Compare(scanEnd, endKey) < 0) {
+			next = scanEnd
+		}
+		break
+	}
+
+	keys, values, err := txn.readFlushedMutations(ctx, locks)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return keys, values, next, nil
+}
+
+// scanFlushedLocks scans at most scanFlushedLockLimit locks in [startKey,
+// endKey) of the region. It returns the region error if the region is stale.
+func (txn *KVTxn) scanFlushedLocks(bo *retry.Backoffer, region locate.RegionVerID, startKey, endKey []byte) ([]*kvrpcpb.LockInfo, *errorpb.Error, error) {
+	// Some implementations exclude the locks of MaxVersion, so the max version
+	// is set past the start ts, and the locks of the other transactions are
+	// filtered out by the caller.
+	req := tikvrpc.NewRequest(tikvrpc.CmdScanLock, &kvrpcpb.ScanLockRequest{
+		MaxVersion: txn.startTS + 1,
+		Limit:      scanFlushedLockLimit,
+		StartKey:   startKey,
+		EndKey:     endKey,
+	})
+	resp, err := txn.store.SendReq(bo, req, region, client.ReadTimeoutMedium)
+	if err != nil {
+		return nil, nil, err
+	}
+	regionErr, err := resp.GetRegionError()
+	if err != nil || regionErr != nil {
+		return nil, regionErr, err
+	}
+	if resp.Resp == nil {
+		return nil, nil, errors.WithStack(tikverr.ErrBodyMissing)
+	}
+	locksResp := resp.Resp.(*kvrpcpb.ScanLockResponse)
+	if locksResp.GetError() != nil {
+		return nil, nil, errors.Errorf("unexpected scanlock error: %s", locksResp)
+	}
+	return locksResp.GetLocks(), nil, nil
+}
+
+// readFlushedMutations returns the mutations of the transaction's locks, the
+// values of the put keys are read from the buffer in TiKV.
+func (txn *KVTxn) readFlushedMutations(ctx context.Context, locks []*kvrpcpb.LockInfo) ([][]byte, [][]byte, error) {
+	var keys, values, putKeys [][]byte
+	for _, lock := range locks {
+		if lock.LockVersion != txn.startTS {
+			continue
+		}
+		switch lock.LockType {
+		case kvrpcpb.Op_Put, kvrpcpb.Op_Insert:
+			keys = append(keys, lock.Key)
+			values = append(values, nil)
+			putKeys = append(putKeys, lock.Key)
+		case kvrpcpb.Op_Del:
+			keys = append(keys, lock.Key)
+			values = append(values, []byte{})
+		default:
+			// Op_Lock and Op_PessimisticLock don't change the values of the
+			// keys, so they are skipped like the keys only locked in the
+			// memdb, and the reads fall through to the snapshot.
+		}
+	}
+	if len(putKeys) == 0 {
+		return keys, values, nil
+	}
+
+	bufferValues, err := txn.snapshot.BatchGetWithTier(ctx, putKeys, txnsnapshot.BatchGetBufferTier)
+	if err != nil {
+		return nil, nil, err
+	}
+	n := 0
+	for i, k := range keys {
+		if values[i] == nil {
+			v, ok := bufferValues[string(k)]
+			if !ok {
+				// The lock is resolved or overwritten by another flush
+				// in the meantime, skip it.
+				continue
+			}
+			if v == nil {
+				v = []byte{}
+			}
+			values[i] = v
+		}
+		keys[n], values[n] = k, values[i]
+		n++
+	}
+	return keys[:n], values[:n], nil
 }
@@ -35,7 +35,6 @@
 package transaction
 
 import (
-	"encoding/hex"
 	"math"
 	"strconv"
 	"sync/atomic"
@@ -57,6 +56,7 @@ import (
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/txnkv/txnlock"
 	"github.com/tikv/client-go/v2/util"
+	"github.com/tikv/client-go/v2/util/redact"
 	"go.uber.org/zap"
 )
 
@@ -153,7 +153,7 @@ func (c *twoPhaseCommitter) buildPrewriteRequest(batch batchMutations, txnSize u
 			ttl = 1
 			keys := make([]string, 0, len(mutations))
 			for _, m := range mutations {
-				keys = append(keys, hex.EncodeToString(m.Key))
+				keys = append(keys, redact.Key(m.Key))
 			}
 			logutil.BgLogger().Info(
 				"[failpoint] injected lock ttl = 1 on prewrite",
@@ -349,6 +349,7 @@ func (handler *prewrite1BatchReqHandler) drop(err error) {
 
 func (handler *prewrite1BatchReqHandler) beforeSend(reqBegin time.Time) {
 	handler.attempts++
+	handler.req.MaxExecutionDurationMs = util.MaxExecutionDurationMs(handler.bo.GetCtx(), client.MaxWriteExecutionTime)
 	if handler.action.hasRpcRetries {
 		handler.req.IsRetryRequest = true
 	}
@@ -521,11 +522,13 @@ func (handler *prewrite1BatchReqHandler) resolveLocks(locks []*txnlock.Lock) err
 	}
 	msBeforeExpired := resolveLockRes.TTL
 	if msBeforeExpired > 0 {
+		backoffStart := time.Now()
 		err = handler.bo.BackoffWithCfgAndMaxSleep(
 			retry.BoTxnLock,
 			int(msBeforeExpired),
 			errors.Errorf("2PC prewrite lockedKeys: %d", len(locks)),
 		)
+		resolveLockOpts.Detail.RecordBackoff(time.Since(backoffStart))
 		if err != nil {
 			return err
 		}
@@ -566,6 +569,7 @@ func (handler *prewrite1BatchReqHandler) handleSingleBatchSucceed(reqBegin time.
 				zap.Uint64("startTS", handler.committer.startTS),
 			)
 			metrics.OnePCTxnCounterFallback.Inc()
+			handler.committer.onePCIneligibleReason = onePCReasonServerFallback
 			handler.committer.setOnePC(false)
 			handler.committer.setAsyncCommit(false)
 		} else {
@@ -0,0 +1,55 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/testutils"
+	tikvtesting "github.com/tikv/client-go/v2/tikv/testing"
+)
+
+func TestMaxRaftEntrySize(t *testing.T) {
+	var cluster *testutils.MockCluster
+	store, err := tikvtesting.NewStore(tikvtesting.WithCluster(func(c *testutils.MockCluster) {
+		cluster = c
+	}))
+	require.Nil(t, err)
+	defer store.Close()
+
+	cluster.SetMaxRaftEntrySize(4096)
+	commit := func(prefix string) error {
+		txn, err := store.Begin()
+		require.Nil(t, err)
+		for i := 0; i < 16; i++ {
+			require.Nil(t, txn.Set([]byte(fmt.Sprintf("%s%02d", prefix, i)), make([]byte, 512)))
+		}
+		return txn.Commit(context.Background())
+	}
+
+	// The prewrite of all the keys in a batch exceeds the limit.
+	err = commit("a")
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "raft entry is too large")
+
+	// The smaller batches fit in the limit.
+	defer kv.TxnCommitBatchSize.Store(kv.TxnCommitBatchSize.Load())
+	kv.TxnCommitBatchSize.Store(2048)
+	require.Nil(t, commit("b"))
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/kv"
+	tikvtesting "github.com/tikv/client-go/v2/tikv/testing"
+	"github.com/tikv/client-go/v2/util"
+)
+
+func TestResolveLockDetail(t *testing.T) {
+	store, err := tikvtesting.NewStore()
+	require.Nil(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	holder, err := store.Begin()
+	require.Nil(t, err)
+	holder.SetPessimistic(true)
+	require.Nil(t, holder.LockKeysWithWaitTime(ctx, kv.LockNoWait, []byte("k1"), []byte("k2")))
+	defer holder.Rollback()
+
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	txn.SetPessimistic(true)
+	defer txn.Rollback()
+	lockCtx := kv.NewLockCtx(txn.StartTS(), kv.LockNoWait, time.Now())
+	lockCtx.Stats = &util.LockKeysDetails{}
+	err = txn.LockKeys(ctx, lockCtx, []byte("k1"))
+	require.ErrorIs(t, err, tikverr.ErrLockAcquireFailAndNoWaitSet)
+
+	detail := &lockCtx.Stats.ResolveLock
+	require.Equal(t, int64(1), detail.LockCount)
+	require.Equal(t, int64(0), detail.ResolvedLockCount)
+	require.Equal(t, []util.ConflictTxn{{TxnID: holder.StartTS(), LockCount: 1}}, detail.TopConflictTxns(3))
+
+	// The details are merged by the transactions, and cloned deeply.
+	var merged util.ResolveLockDetail
+	merged.RecordLocks([]uint64{1, 1, 2})
+	merged.RecordResolved(2)
+	merged.Merge(detail)
+	clone := merged.Clone()
+	merged.RecordLocks([]uint64{2, 2})
+	require.Equal(t, int64(4), clone.LockCount)
+	require.Equal(t, int64(2), clone.ResolvedLockCount)
+	require.Equal(t, []util.ConflictTxn{{TxnID: 1, LockCount: 2}, {TxnID: 2, LockCount: 1}}, clone.TopConflictTxns(2))
+	require.Equal(t, fmt.Sprintf("resolve_lock: {locks: 4, resolved: 2, top_conflict_txns: [1:2, 2:1, %d:1]}", holder.StartTS()), clone.String())
+	require.Equal(t, int64(3), merged.TopConflictTxns(1)[0].LockCount)
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	tikverr "github.com/tikv/client-go/v2/error"
+	tikvtesting "github.com/tikv/client-go/v2/tikv/testing"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+)
+
+func TestCommittedSecondariesDone(t *testing.T) {
+	store, err := tikvtesting.NewStore()
+	require.Nil(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	wait := func(txn *transaction.KVTxn) error {
+		select {
+		case <-txn.CommittedSecondariesDone():
+			return txn.CommittedSecondariesErr()
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "secondaries are not done")
+			return nil
+		}
+	}
+
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	for _, k := range []string{"a", "b", "c"} {
+		require.Nil(t, txn.Set([]byte(k), []byte(k)))
+	}
+	require.Nil(t, txn.Commit(ctx))
+	require.Nil(t, wait(txn))
+
+	// The channel receives the error of the failed commit.
+	txn1, err := store.Begin()
+	require.Nil(t, err)
+	require.Nil(t, txn1.Set([]byte("a"), []byte("a1")))
+	txn2, err := store.Begin()
+	require.Nil(t, err)
+	require.Nil(t, txn2.Set([]byte("a"), []byte("a2")))
+	require.Nil(t, txn2.Commit(ctx))
+	err = txn1.Commit(ctx)
+	require.Error(t, err)
+	require.Equal(t, err, wait(txn1))
+	// Every receiver sees the same result.
+	require.Equal(t, err, wait(txn1))
+
+	// A read-only transaction has nothing to commit.
+	txn, err = store.Begin()
+	require.Nil(t, err)
+	require.Nil(t, txn.Commit(ctx))
+	require.Nil(t, wait(txn))
+	require.ErrorIs(t, txn.Commit(ctx), tikverr.ErrInvalidTxn)
+	require.Nil(t, wait(txn))
+
+	// Nothing is committed by a rolled back transaction.
+	txn, err = store.Begin()
+	require.Nil(t, err)
+	require.Nil(t, txn.Set([]byte("a"), []byte("a3")))
+	require.Nil(t, txn.Rollback())
+	require.ErrorContains(t, wait(txn), "rolled back")
+	require.ErrorIs(t, txn.Commit(ctx), tikverr.ErrInvalidTxn)
+}
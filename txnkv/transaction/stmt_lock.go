@@ -0,0 +1,95 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config/retry"
+	tikv "github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/tikvrpc/interceptor"
+	"github.com/tikv/client-go/v2/util"
+)
+
+// stmtLocks tracks the keys locked by the current statement of a pessimistic
+// transaction, see StartStmt.
+type stmtLocks struct {
+	active bool
+	keys   [][]byte
+}
+
+// StartStmt starts a statement of the pessimistic transaction. The keys locked
+// from now on are tracked, so they can be released by PessimisticRollbackStmt
+// if the statement fails. The keys locked by the former statements are kept.
+func (txn *KVTxn) StartStmt() {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	txn.stmtLocks = stmtLocks{active: true}
+}
+
+// trackStmtLock records a key locked by the current statement when the key is
+// marked locked in the membuffer.
+func (txn *KVTxn) trackStmtLock(key []byte) {
+	if txn.stmtLocks.active {
+		txn.stmtLocks.keys = append(txn.stmtLocks.keys, key)
+	}
+}
+
+// PessimisticRollbackStmt releases the pessimistic locks acquired since the
+// last StartStmt, which mirrors the statement rollback of TiDB for the
+// embedders retrying the failed statements. The keys locked by the former
+// statements stay locked. If the primary key is locked by the statement, it's
+// unset, and the next lock chooses a new one. The changes to the membuffer are
+// not reverted, which should be done by the staging of the membuffer.
+func (txn *KVTxn) PessimisticRollbackStmt(ctx context.Context) error {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	if !txn.IsPessimistic() {
+		return errors.New("statement rollback of pessimistic locks in optimistic transaction")
+	}
+	if txn.IsInAggressiveLockingMode() {
+		return errors.New("statement rollback of pessimistic locks in aggressive locking")
+	}
+	keys := txn.stmtLocks.keys
+	txn.stmtLocks.keys = nil
+	if len(keys) == 0 {
+		return nil
+	}
+
+	// The committer must have been initialized if some keys are locked.
+	if primary := txn.committer.primaryKey; primary != nil {
+		for _, key := range keys {
+			if bytes.Equal(key, primary) {
+				txn.resetPrimary(false)
+				break
+			}
+		}
+	}
+	memBuf := txn.GetMemBuffer()
+	for _, key := range keys {
+		memBuf.UpdateFlags(key, tikv.DelKeyLocked)
+		delete(txn.forUpdateTSChecks, string(key))
+	}
+	txn.lockedCnt -= len(keys)
+
+	ctx = context.WithValue(ctx, util.RequestSourceKey, *txn.RequestSource)
+	if txn.interceptor != nil {
+		ctx = interceptor.WithRPCInterceptor(ctx, txn.interceptor)
+	}
+	bo := retry.NewBackofferWithVars(ctx, cleanupMaxBackoff, txn.vars)
+	return txn.committer.pessimisticRollbackMutations(bo, &PlainMutations{keys: keys})
+}
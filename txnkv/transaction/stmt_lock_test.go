@@ -0,0 +1,82 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/kv"
+	tikvtesting "github.com/tikv/client-go/v2/tikv/testing"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+)
+
+func TestPessimisticRollbackStmt(t *testing.T) {
+	store, err := tikvtesting.NewStore()
+	require.Nil(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	txn.SetPessimistic(true)
+	lock := func(txn *transaction.KVTxn, keys ...string) error {
+		lockCtx := kv.NewLockCtx(txn.StartTS(), kv.LockNoWait, time.Now())
+		for _, k := range keys {
+			if err := txn.LockKeys(ctx, lockCtx, []byte(k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// The locks of the first statement, including the primary, are released.
+	txn.StartStmt()
+	require.Nil(t, lock(txn, "k1"))
+	require.Nil(t, txn.PessimisticRollbackStmt(ctx))
+	flags, err := txn.GetMemBuffer().GetFlags([]byte("k1"))
+	require.Nil(t, err)
+	require.False(t, flags.HasLocked())
+
+	txn.StartStmt()
+	require.Nil(t, lock(txn, "k2"))
+	txn.StartStmt()
+	require.Nil(t, lock(txn, "k2", "k3", "k4"))
+	require.Nil(t, txn.PessimisticRollbackStmt(ctx))
+	// Rolling back again releases nothing.
+	require.Nil(t, txn.PessimisticRollbackStmt(ctx))
+
+	other, err := store.Begin()
+	require.Nil(t, err)
+	other.SetPessimistic(true)
+	require.Nil(t, lock(other, "k1", "k3", "k4"))
+	require.ErrorIs(t, lock(other, "k2"), tikverr.ErrLockAcquireFailAndNoWaitSet)
+	require.Nil(t, other.Rollback())
+
+	require.Nil(t, txn.Set([]byte("k2"), []byte("v2")))
+	require.Nil(t, txn.Commit(ctx))
+	val, err := store.GetSnapshot(math.MaxUint64).Get(ctx, []byte("k2"))
+	require.Nil(t, err)
+	require.Equal(t, []byte("v2"), val)
+
+	optimistic, err := store.Begin()
+	require.Nil(t, err)
+	require.Error(t, optimistic.PessimisticRollbackStmt(ctx))
+	require.Nil(t, optimistic.Rollback())
+}
@@ -37,7 +37,6 @@ package transaction
 import (
 	"bytes"
 	"context"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -63,11 +62,14 @@ import (
 	"github.com/tikv/client-go/v2/internal/unionstore"
 	tikv "github.com/tikv/client-go/v2/kv"
 	"github.com/tikv/client-go/v2/metrics"
+	"github.com/tikv/client-go/v2/oracle"
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/tikvrpc/interceptor"
 	"github.com/tikv/client-go/v2/txnkv/txnsnapshot"
 	"github.com/tikv/client-go/v2/txnkv/txnutil"
 	"github.com/tikv/client-go/v2/util"
+	"github.com/tikv/client-go/v2/util/memquota"
+	"github.com/tikv/client-go/v2/util/redact"
 	atomicutil "go.uber.org/atomic"
 	"go.uber.org/zap"
 )
@@ -125,6 +127,12 @@ type TxnOptions struct {
 	TxnScope     string
 	StartTS      *uint64
 	PipelinedTxn PipelinedTxnOptions
+	// LowLatencyTSO makes the transaction fetch timestamps without waiting to be batched with others.
+	LowLatencyTSO bool
+	// WorkloadClass routes the reads of the transaction by the workload class if it's set.
+	WorkloadClass *tikv.WorkloadClass
+	// MinStartTS is the timestamp the start ts of the transaction must be greater than.
+	MinStartTS uint64
 }
 
 // PrewriteEncounterLockPolicy specifies the policy when prewrite encounters locks.
@@ -162,6 +170,14 @@ type KVTxn struct {
 	vars      *tikv.Variables
 	committer *twoPhaseCommitter
 	lockedCnt int
+	// memTracker tracks the footprint of the membuffer against the global memory quota if it's set when the
+	// transaction begins. It never blocks, since the memory may be held only by the transaction itself, which is
+	// freed on Commit or Rollback.
+	memTracker memquota.Tracker
+	// trackMem is set if memTracker follows the footprint by the hook of the membuffer, and memHook is the hook set
+	// by SetMemoryFootprintChangeHook then, which is called after it.
+	trackMem bool
+	memHook  func(uint64)
 
 	valid bool
 
@@ -169,6 +185,8 @@ type KVTxn struct {
 	schemaVer SchemaVer
 	// commitCallback is called after current transaction gets committed
 	commitCallback func(info string, err error)
+	// closeCallbacks are called once the transaction is committed or rolled back.
+	closeCallbacks []func()
 
 	// backgroundGoroutineLifecycleHooks tracks the lifecycle of background goroutines of a
 	// transaction. The `.Pre` will be executed before the start of each background goroutine,
@@ -182,6 +200,7 @@ type KVTxn struct {
 	isPessimistic           bool
 	enableAsyncCommit       bool
 	enable1PC               bool
+	require1PC              bool
 	causalConsistency       bool
 	scope                   string
 	kvFilter                KVFilter
@@ -190,6 +209,8 @@ type KVTxn struct {
 	diskFullOpt             kvrpcpb.DiskFullOpt
 	txnSource               uint64
 	commitTSUpperBoundCheck func(uint64) bool
+	// checkUndeterminedCommit makes the commit check the status of the primary key when the result is undetermined.
+	checkUndeterminedCommit bool
 	// interceptor is used to decorate the RPC request logic related to the txn.
 	interceptor    interceptor.RPCInterceptor
 	assertionLevel kvrpcpb.AssertionLevel
@@ -202,15 +223,30 @@ type KVTxn struct {
 
 	forUpdateTSChecks map[string]uint64
 
+	// stmtLocks tracks the keys locked by the current statement.
+	stmtLocks stmtLocks
+
 	isPipelined                     bool
 	pipelinedCancel                 context.CancelFunc
 	pipelinedFlushConcurrency       int
 	pipelinedResolveLockConcurrency int
 	writeThrottleRatio              float64
+	commitConcurrency               int
+	commitBatchSize                 int
+	fairLocking                     bool
+	lowLatencyTSO                   bool
+	// commitDeadline is the default deadline of Commit, see tikv.OperationDeadlines.
+	commitDeadline time.Duration
 	// flushBatchDurationEWMA is read before each flush, and written after each flush => no race
 	flushBatchDurationEWMA ewma.MovingAverage
 
 	prewriteEncounterLockPolicy PrewriteEncounterLockPolicy
+
+	// secondaries is the result of committing the secondary keys in the background.
+	secondaries secondariesResult
+
+	// useChecker detects the concurrent misuse, see EnableConcurrentUseAudit.
+	useChecker useChecker
 }
 
 // NewTiKVTxn creates a new KVTxn.
@@ -224,14 +260,20 @@ func NewTiKVTxn(store kvstore, snapshot *txnsnapshot.KVSnapshot, startTS uint64,
 		valid:                  true,
 		vars:                   tikv.DefaultVars,
 		scope:                  options.TxnScope,
+		lowLatencyTSO:          options.LowLatencyTSO,
 		enableAsyncCommit:      cfg.EnableAsyncCommit,
 		enable1PC:              cfg.Enable1PC,
 		diskFullOpt:            kvrpcpb.DiskFullOpt_NotAllowedOnFull,
 		RequestSource:          snapshot.RequestSource,
+		commitDeadline:         snapshot.GetOperationDeadlines().Commit,
 		flushBatchDurationEWMA: ewma.NewMovingAverage(defaultEWMAAge),
 	}
+	if options.WorkloadClass != nil {
+		snapshot.SetWorkloadClass(*options.WorkloadClass)
+	}
 	if !options.PipelinedTxn.Enable {
 		newTiKVTxn.us = unionstore.NewUnionStore(unionstore.NewMemDB(), snapshot)
+		newTiKVTxn.initMemTracking()
 		return newTiKVTxn, nil
 	}
 	if options.PipelinedTxn.FlushConcurrency == 0 {
@@ -273,6 +315,7 @@ func (txn *KVTxn) GetVars() *tikv.Variables {
 
 // Get implements transaction interface.
 func (txn *KVTxn) Get(ctx context.Context, k []byte) ([]byte, error) {
+	defer txn.auditRead("Get")()
 	ret, err := txn.us.Get(ctx, k)
 	if tikverr.IsErrNotFound(err) {
 		return nil, err
@@ -288,14 +331,21 @@ func (txn *KVTxn) Get(ctx context.Context, k []byte) ([]byte, error) {
 // Do not use len(value) == 0 or value == nil to represent non-exist.
 // If a key doesn't exist, there shouldn't be any corresponding entry in the result map.
 func (txn *KVTxn) BatchGet(ctx context.Context, keys [][]byte) (map[string][]byte, error) {
+	defer txn.auditRead("BatchGet")()
 	return NewBufferBatchGetter(txn.GetMemBuffer(), txn.GetSnapshot()).BatchGet(ctx, keys)
 }
 
 // Set sets the value for key k as v into kv store.
 // v must NOT be nil or empty, otherwise it returns ErrCannotSetNilValue.
 func (txn *KVTxn) Set(k []byte, v []byte) error {
+	defer txn.auditWrite("Set")()
+	if err := txn.reserveMem(len(k) + len(v)); err != nil {
+		return err
+	}
 	txn.setCnt++
-	return txn.GetMemBuffer().Set(k, v)
+	err := txn.GetMemBuffer().Set(k, v)
+	txn.syncMem()
+	return err
 }
 
 // String implements fmt.Stringer interface.
@@ -312,17 +362,72 @@ func (txn *KVTxn) String() string {
 // It yields only keys that < upperBound. If upperBound is nil, it means the upperBound is unbounded.
 // The Iterator must be Closed after use.
 func (txn *KVTxn) Iter(k []byte, upperBound []byte) (unionstore.Iterator, error) {
+	defer txn.auditRead("Iter")()
 	return txn.us.Iter(k, upperBound)
 }
 
 // IterReverse creates a reversed Iterator positioned on the first entry which key is less than k.
 func (txn *KVTxn) IterReverse(k, lowerBound []byte) (unionstore.Iterator, error) {
+	defer txn.auditRead("IterReverse")()
 	return txn.us.IterReverse(k, lowerBound)
 }
 
+// IterWithContext is like Iter, but the mutations flushed by a pipelined
+// transaction are scanned with ctx.
+func (txn *KVTxn) IterWithContext(ctx context.Context, k []byte, upperBound []byte) (unionstore.Iterator, error) {
+	defer txn.auditRead("Iter")()
+	return txn.us.IterWithContext(ctx, k, upperBound)
+}
+
+// IterReverseWithContext is like IterReverse, see IterWithContext.
+func (txn *KVTxn) IterReverseWithContext(ctx context.Context, k, lowerBound []byte) (unionstore.Iterator, error) {
+	defer txn.auditRead("IterReverse")()
+	return txn.us.IterReverseWithContext(ctx, k, lowerBound)
+}
+
 // Delete removes the entry for key k from kv store.
 func (txn *KVTxn) Delete(k []byte) error {
-	return txn.GetMemBuffer().Delete(k)
+	defer txn.auditWrite("Delete")()
+	if err := txn.reserveMem(len(k)); err != nil {
+		return err
+	}
+	err := txn.GetMemBuffer().Delete(k)
+	txn.syncMem()
+	return err
+}
+
+// initMemTracking makes memTracker follow the footprint of the membuffer if the global memory quota is set.
+func (txn *KVTxn) initMemTracking() {
+	if memquota.Global() == nil {
+		return
+	}
+	txn.trackMem = true
+	txn.us.GetMemBuffer().SetMemoryFootprintChangeHook(func(mem uint64) {
+		// The hook is called when the values grow the footprint, including the ones written through
+		// GetMemBuffer, and after the pipelined flushes, which drop the footprint of the flushed mutations.
+		txn.memTracker.Resize(int64(mem))
+		if txn.memHook != nil {
+			txn.memHook(mem)
+		}
+	})
+	txn.syncMem()
+}
+
+// reserveMem acquires n bytes from the memory quota for a write, which fails if the quota is exceeded. The
+// reservation is replaced by the footprint after the write by syncMem.
+func (txn *KVTxn) reserveMem(n int) error {
+	if !txn.trackMem {
+		return nil
+	}
+	return txn.memTracker.TryAcquire(int64(n))
+}
+
+// syncMem makes the memory quota held by the transaction the footprint of the membuffer, since the hook is called
+// only when the footprint changes.
+func (txn *KVTxn) syncMem() {
+	if txn.trackMem {
+		txn.memTracker.Resize(int64(txn.Mem()))
+	}
 }
 
 // SetSchemaLeaseChecker sets a hook to check schema version.
@@ -348,6 +453,53 @@ func (txn *KVTxn) SetSchemaVer(schemaVer SchemaVer) {
 	txn.schemaVer = schemaVer
 }
 
+// SetWorkloadClass sets the workload class of the reads, which decides the
+// replicas to read from, see txnsnapshot.KVSnapshot.SetWorkloadClass.
+func (txn *KVTxn) SetWorkloadClass(class tikv.WorkloadClass) {
+	txn.GetSnapshot().SetWorkloadClass(class)
+}
+
+// SetReadEngine restricts the replicas the reads are sent to to the ones of the
+// storage engine, see txnsnapshot.KVSnapshot.SetReadEngine.
+func (txn *KVTxn) SetReadEngine(engine tikv.ReadEngine) {
+	txn.GetSnapshot().SetReadEngine(engine)
+}
+
+// minSafeTSGetter is implemented by the stores tracking the safe ts of the
+// TiKV stores, e.g. tikv.KVStore.
+type minSafeTSGetter interface {
+	GetMinSafeTS(txnScope string) uint64
+}
+
+// SetStaleReadMaxStaleness makes the transaction a stale read only one, which
+// reads at the freshest ts all the replicas in the txn scope can serve, i.e.
+// the min safe ts of the stores tracked by the store. The read ts is no older
+// than maxStaleness before the start ts, so if the safe ts falls further
+// behind, the replicas that can't serve the read ts redirect the reads to the
+// leaders. It resets the start ts of the transaction, so it must be called
+// before any write.
+func (txn *KVTxn) SetStaleReadMaxStaleness(maxStaleness time.Duration) error {
+	if !txn.IsReadOnly() {
+		return errors.New("stale read can't be set on a transaction with writes")
+	}
+	if maxStaleness < 0 {
+		return errors.Errorf("invalid max staleness %v", maxStaleness)
+	}
+	readTS := oracle.GoTimeToTS(oracle.GetTimeFromTS(txn.startTS).Add(-maxStaleness))
+	if getter, ok := txn.store.(minSafeTSGetter); ok {
+		if safeTS := getter.GetMinSafeTS(txn.scope); safeTS > readTS {
+			readTS = safeTS
+		}
+	}
+	if readTS > txn.startTS {
+		readTS = txn.startTS
+	}
+	txn.startTS = readTS
+	txn.snapshot.SetSnapshotTS(readTS)
+	txn.snapshot.SetIsStalenessReadOnly(true)
+	return nil
+}
+
 // SetPriority sets the priority for both write and read.
 func (txn *KVTxn) SetPriority(pri txnutil.Priority) {
 	txn.priority = pri
@@ -391,6 +543,13 @@ func (txn *KVTxn) SetRPCInterceptor(it interceptor.RPCInterceptor) {
 	txn.GetSnapshot().SetRPCInterceptor(it)
 }
 
+// SetOperationDeadlines sets the default deadlines of the reads and the commit of the transaction, which are applied
+// if the contexts passed to them have no deadlines. See tikv.OperationDeadlines.
+func (txn *KVTxn) SetOperationDeadlines(deadlines tikv.OperationDeadlines) {
+	txn.commitDeadline = deadlines.Commit
+	txn.GetSnapshot().SetOperationDeadlines(deadlines)
+}
+
 // AddRPCInterceptor adds an interceptor, the order of addition is the order of execution.
 func (txn *KVTxn) AddRPCInterceptor(it interceptor.RPCInterceptor) {
 	if txn.interceptor == nil {
@@ -407,6 +566,13 @@ func (txn *KVTxn) SetCommitCallback(f func(string, error)) {
 	txn.commitCallback = f
 }
 
+// AddCloseCallback adds a function that will be called once the transaction
+// becomes invalid, i.e. it's committed or rolled back, successfully or not. The
+// functions are called in the order they are added.
+func (txn *KVTxn) AddCloseCallback(f func()) {
+	txn.closeCallbacks = append(txn.closeCallbacks, f)
+}
+
 // SetBackgroundGoroutineLifecycleHooks sets up the hooks to track the lifecycle of the background goroutines of a transaction.
 func (txn *KVTxn) SetBackgroundGoroutineLifecycleHooks(hooks LifecycleHooks) {
 	txn.backgroundGoroutineLifecycleHooks = hooks
@@ -418,11 +584,13 @@ func (txn *KVTxn) spawn(f func()) {
 		txn.backgroundGoroutineLifecycleHooks.Pre()
 	}
 	txn.store.WaitGroup().Add(1)
+	done := txn.store.AsyncOps().begin()
 	go func() {
 		if txn.backgroundGoroutineLifecycleHooks.Post != nil {
 			defer txn.backgroundGoroutineLifecycleHooks.Post()
 		}
 		defer txn.store.WaitGroup().Done()
+		defer done()
 
 		f()
 	}()
@@ -434,16 +602,19 @@ func (txn *KVTxn) spawnWithStorePool(f func()) error {
 		txn.backgroundGoroutineLifecycleHooks.Pre()
 	}
 	txn.store.WaitGroup().Add(1)
+	done := txn.store.AsyncOps().begin()
 	err := txn.store.Go(func() {
 		if txn.backgroundGoroutineLifecycleHooks.Post != nil {
 			defer txn.backgroundGoroutineLifecycleHooks.Post()
 		}
 		defer txn.store.WaitGroup().Done()
+		defer done()
 
 		f()
 	})
 	if err != nil {
 		txn.store.WaitGroup().Done()
+		done()
 	}
 	return err
 }
@@ -459,6 +630,45 @@ func (txn *KVTxn) SetEnable1PC(b bool) {
 	txn.enable1PC = b
 }
 
+// SetRequire1PC makes the transaction enable 1PC, and fail to commit with
+// ErrOnePCIneligible instead of falling back to 2PC if it can't be committed by
+// 1PC, e.g. the keys are in multiple regions or exceed the limits of the OnePC
+// config. The check is done before writing anything, except that TiKV may
+// still fall back to 2PC after receiving the prewrite, then the transaction is
+// committed by 2PC.
+func (txn *KVTxn) SetRequire1PC(b bool) {
+	txn.require1PC = b
+	if b {
+		txn.enable1PC = true
+	}
+}
+
+// OnePCIneligibleReason returns why the committed transaction isn't committed
+// by 1PC, or an empty string if it is or it hasn't been committed. The
+// reasons are "disabled", "assertion", "pipelined", "local-scope",
+// "commit-ts-upper-bound-check", "binlog", "keys-limit", "size-limit",
+// "multiple-batches" and "server-fallback".
+func (txn *KVTxn) OnePCIneligibleReason() string {
+	if txn.committer == nil {
+		return ""
+	}
+	return txn.committer.onePCIneligibleReason
+}
+
+// SetCommitConcurrency sets the max number of concurrent requests sent by the
+// transaction in each phase of 2PC. Non-positive value means using
+// CommitterConcurrency of the global config.
+func (txn *KVTxn) SetCommitConcurrency(n int) {
+	txn.commitConcurrency = n
+}
+
+// SetCommitBatchSize sets the max size in bytes of each batch of mutations
+// sent by the transaction in 2PC. Non-positive value means using
+// kv.TxnCommitBatchSize.
+func (txn *KVTxn) SetCommitBatchSize(size int) {
+	txn.commitBatchSize = size
+}
+
 // SetCausalConsistency indicates if the transaction does not need to
 // guarantee linearizability. Default value is false which means
 // linearizability is guaranteed.
@@ -466,6 +676,14 @@ func (txn *KVTxn) SetCausalConsistency(b bool) {
 	txn.causalConsistency = b
 }
 
+// SetCheckUndeterminedCommit makes the commit find out the outcome by checking the status of the primary key when
+// the result of committing the primary key is undetermined, e.g. the RPC times out, instead of returning
+// ErrResultUndetermined. The commit returns nil if the transaction turns out committed, and ErrCommitRolledBack if it's
+// rolled back. ErrResultUndetermined is still returned if the primary lock is alive or the status can't be checked.
+func (txn *KVTxn) SetCheckUndeterminedCommit(b bool) {
+	txn.checkUndeterminedCommit = b
+}
+
 // SetScope sets the geographical scope of the transaction.
 func (txn *KVTxn) SetScope(scope string) {
 	txn.scope = scope
@@ -694,12 +912,14 @@ func (txn *KVTxn) InitPipelinedMemDB() error {
 		}
 		return err
 	})
+	pipelinedMemDB.SetBufferScanner(txn.scanFlushedMutations)
 	txn.committer.priority = txn.priority.ToPB()
 	txn.committer.syncLog = txn.syncLog
 	txn.committer.resourceGroupTag = txn.resourceGroupTag
 	txn.committer.resourceGroupTagger = txn.resourceGroupTagger
 	txn.committer.resourceGroupName = txn.resourceGroupName
 	txn.us = unionstore.NewUnionStore(pipelinedMemDB, txn.snapshot)
+	txn.initMemTracking()
 	return nil
 }
 
@@ -743,7 +963,8 @@ func (txn *KVTxn) GetScope() string {
 }
 
 // Commit commits the transaction operations to KV store.
-func (txn *KVTxn) Commit(ctx context.Context) error {
+func (txn *KVTxn) Commit(ctx context.Context) (err error) {
+	defer txn.auditWrite("Commit")()
 	if span := opentracing.SpanFromContext(ctx); span != nil && span.Tracer() != nil {
 		span1 := span.Tracer().StartSpan("tikvTxn.Commit", opentracing.ChildOf(span.Context()))
 		defer span1.Finish()
@@ -755,8 +976,19 @@ func (txn *KVTxn) Commit(ctx context.Context) error {
 		return tikverr.ErrInvalidTxn
 	}
 	defer txn.close()
+	defer func() {
+		// The committer reports the result itself if it's committing the secondaries in the background.
+		if !txn.secondaries.background {
+			txn.secondaries.finish(err)
+		}
+	}()
 
 	ctx = context.WithValue(ctx, util.RequestSourceKey, *txn.RequestSource)
+	if txn.lowLatencyTSO {
+		ctx = oracle.WithLowLatency(ctx)
+	}
+	ctx, cancel := util.WithDefaultDeadline(ctx, txn.commitDeadline)
+	defer cancel()
 
 	if txn.IsInAggressiveLockingMode() {
 		if len(txn.aggressiveLockingContext.currentLockedKeys) != 0 {
@@ -795,7 +1027,6 @@ func (txn *KVTxn) Commit(ctx context.Context) error {
 		ctx = interceptor.WithRPCInterceptor(ctx, txn.interceptor)
 	}
 
-	var err error
 	// If the txn use pessimistic lock, committer is initialized.
 	committer := txn.committer
 	if committer == nil {
@@ -828,6 +1059,24 @@ func (txn *KVTxn) Commit(ctx context.Context) error {
 	if !txn.isPipelined && committer.mutations.Len() == 0 {
 		return nil
 	}
+	// The pipelined transactions have flushed the mutations already.
+	if !txn.isPipelined {
+		if err = committer.sizes.check(config.GetGlobalConfig().TiKVClient.CommitSizeLimit); err != nil {
+			if txn.IsPessimistic() {
+				txn.asyncPessimisticRollback(ctx, committer.mutations.GetKeys(), txn.committer.forUpdateTS)
+			}
+			return err
+		}
+	}
+	if txn.require1PC {
+		if reason := committer.ineligibleForOnePC(); len(reason) > 0 {
+			committer.onePCIneligibleReason = reason
+			if txn.IsPessimistic() {
+				txn.asyncPessimisticRollback(ctx, committer.mutations.GetKeys(), txn.committer.forUpdateTS)
+			}
+			return errors.WithStack(&tikverr.ErrOnePCIneligible{Reason: reason})
+		}
+	}
 
 	defer func() {
 		detail := committer.getDetail()
@@ -885,10 +1134,23 @@ func (txn *KVTxn) Commit(ctx context.Context) error {
 func (txn *KVTxn) close() {
 	txn.valid = false
 	txn.ClearDiskFullOpt()
+	txn.memTracker.ReleaseAll()
+	// Commit reports its result before closing the transaction, unless the
+	// secondaries are committed in the background, so the transaction is rolled
+	// back here.
+	if !txn.secondaries.background {
+		txn.secondaries.finish(errors.New("the transaction is rolled back"))
+	}
+	callbacks := txn.closeCallbacks
+	txn.closeCallbacks = nil
+	for _, f := range callbacks {
+		f()
+	}
 }
 
 // Rollback undoes the transaction operations to KV store.
 func (txn *KVTxn) Rollback() error {
+	defer txn.auditWrite("Rollback")()
 	if !txn.valid {
 		return tikverr.ErrInvalidTxn
 	}
@@ -1050,11 +1312,17 @@ func (txn *KVTxn) onCommitted(err error) {
 }
 
 // LockKeysWithWaitTime tries to lock the entries with the keys in KV store.
-// lockWaitTime in ms, 0 means nowait lock.
+// lockWaitTime is the lock wait policy of the request, i.e. kv.LockNoWait, kv.LockWaitTimeout(d), kv.LockWaitDefault
+// for the default wait duration of TiKV, or kv.LockAlwaysWait. If the keys are blocked by the lock of another
+// transaction beyond the policy, a *tikverr.ErrLockWait carrying the conflicting lock is returned.
 func (txn *KVTxn) LockKeysWithWaitTime(ctx context.Context, lockWaitTime int64, keysInput ...[]byte) (err error) {
 	forUpdateTs := txn.startTS
 	if txn.IsPessimistic() {
-		bo := retry.NewBackofferWithVars(context.Background(), TsoMaxBackoff, nil)
+		tsCtx := context.Background()
+		if txn.lowLatencyTSO {
+			tsCtx = oracle.WithLowLatency(tsCtx)
+		}
+		bo := retry.NewBackofferWithVars(tsCtx, TsoMaxBackoff, nil)
 		forUpdateTs, err = txn.store.GetTimestampWithRetry(bo, txn.scope)
 		if err != nil {
 			return err
@@ -1191,6 +1459,7 @@ func (txn *KVTxn) DoneAggressiveLocking(ctx context.Context) {
 			setValExists = tikv.SetKeyLockedValueNotExists
 		}
 		memBuffer.UpdateFlags([]byte(key), tikv.SetKeyLocked, tikv.DelNeedCheckExists, setValExists)
+		txn.trackStmtLock([]byte(key))
 
 		if _, ok := txn.forUpdateTSChecks[key]; !ok {
 			txn.forUpdateTSChecks[key] = entry.ActualLockForUpdateTS
@@ -1204,6 +1473,23 @@ func (txn *KVTxn) DoneAggressiveLocking(ctx context.Context) {
 	}
 }
 
+// SetFairLocking sets whether single-key pessimistic locks of the transaction are acquired by the fair locking
+// protocol. When enabled, a waiting lock request is woken up in ForceLock mode
+// (kvrpcpb.PessimisticLockWakeUpMode_WakeUpModeForceLock): the lock is acquired even if the key was updated after the
+// forUpdateTS, and the conflict is reported by LockedWithConflictTS in lockCtx.Values and
+// lockCtx.MaxLockedWithConflictTS instead of a write conflict error, so the caller can retry the read at a newer
+// forUpdateTS while still holding the lock. This avoids livelocks when many transactions contend for a hot key.
+//
+// It takes no effect on optimistic transactions, or when the transaction is already in aggressive locking mode.
+func (txn *KVTxn) SetFairLocking(b bool) {
+	txn.fairLocking = b
+}
+
+// IsFairLockingEnabled returns whether fair locking is enabled by SetFairLocking.
+func (txn *KVTxn) IsFairLockingEnabled() bool {
+	return txn.fairLocking
+}
+
 // IsInAggressiveLockingMode checks if the transaction is currently in aggressive locking mode.
 func (txn *KVTxn) IsInAggressiveLockingMode() bool {
 	return txn.aggressiveLockingContext != nil
@@ -1323,6 +1609,7 @@ func (txn *KVTxn) exitAggressiveLockingIfInapplicable(ctx context.Context, keys
 // LockKeys tries to lock the entries with the keys in KV store.
 // lockCtx is the context for lock, lockCtx.lockWaitTime in ms
 func (txn *KVTxn) LockKeys(ctx context.Context, lockCtx *tikv.LockCtx, keysInput ...[]byte) error {
+	defer txn.auditWrite("LockKeys")()
 	return txn.lockKeys(ctx, lockCtx, nil, keysInput...)
 }
 
@@ -1330,10 +1617,26 @@ func (txn *KVTxn) LockKeys(ctx context.Context, lockCtx *tikv.LockCtx, keysInput
 // lockCtx is the context for lock, lockCtx.lockWaitTime in ms
 // fn is a function which run before the lock is released.
 func (txn *KVTxn) LockKeysFunc(ctx context.Context, lockCtx *tikv.LockCtx, fn func(), keysInput ...[]byte) error {
+	defer txn.auditWrite("LockKeysFunc")()
 	return txn.lockKeys(ctx, lockCtx, fn, keysInput...)
 }
 
 func (txn *KVTxn) lockKeys(ctx context.Context, lockCtx *tikv.LockCtx, fn func(), keysInput ...[]byte) error {
+	if !txn.fairLocking || !txn.IsPessimistic() || txn.IsInAggressiveLockingMode() || len(keysInput) != 1 {
+		return txn.doLockKeys(ctx, lockCtx, fn, keysInput...)
+	}
+	// Lock the key in a one-shot aggressive locking stage, so that the request is sent in ForceLock mode and the
+	// conflict info is returned by lockCtx instead of an error.
+	txn.StartAggressiveLocking()
+	if err := txn.doLockKeys(ctx, lockCtx, fn, keysInput...); err != nil {
+		txn.CancelAggressiveLocking(ctx)
+		return err
+	}
+	txn.DoneAggressiveLocking(ctx)
+	return nil
+}
+
+func (txn *KVTxn) doLockKeys(ctx context.Context, lockCtx *tikv.LockCtx, fn func(), keysInput ...[]byte) error {
 	if txn.interceptor != nil {
 		// User has called txn.SetRPCInterceptor() to explicitly set an interceptor, we
 		// need to bind it to ctx so that the internal client can perceive and execute
@@ -1635,7 +1938,7 @@ func (txn *KVTxn) lockKeys(ctx context.Context, lockCtx *tikv.LockCtx, fn func()
 				// If LockedWithConflictTS is not zero, it must indicate that there's a version of the key whose
 				// commitTS is greater than lockCtx.ForUpdateTS. Therefore, this branch should never be executed.
 				err = errors.Errorf("pessimistic lock request to key %v returns LockedWithConflictTS(%v) not greater than requested ForUpdateTS(%v)",
-					hex.EncodeToString(key), val.LockedWithConflictTS, lockCtx.ForUpdateTS)
+					redact.Key(key), val.LockedWithConflictTS, lockCtx.ForUpdateTS)
 			}
 			txn.aggressiveLockingContext.currentLockedKeys[keyStr] = tempLockBufferEntry{
 				HasReturnValue:        lockCtx.ReturnValues,
@@ -1650,6 +1953,7 @@ func (txn *KVTxn) lockKeys(ctx context.Context, lockCtx *tikv.LockCtx, fn func()
 				setValExists = tikv.SetKeyLockedValueNotExists
 			}
 			memBuf.UpdateFlags(key, tikv.SetKeyLocked, tikv.DelNeedCheckExists, setValExists)
+			txn.trackStmtLock(key)
 		}
 	}
 	if err != nil {
@@ -1792,8 +2096,10 @@ func (txn *KVTxn) asyncPessimisticRollback(ctx context.Context, keys [][]byte, s
 	wg := new(sync.WaitGroup)
 	wg.Add(1)
 	txn.store.WaitGroup().Add(1)
+	done := txn.store.AsyncOps().begin()
 	go func() {
 		defer txn.store.WaitGroup().Done()
+		defer done()
 		if val, err := util.EvalFailpoint("beforeAsyncPessimisticRollback"); err == nil {
 			if s, ok := val.(string); ok {
 				if s == "skip" {
@@ -1812,6 +2118,7 @@ func (txn *KVTxn) asyncPessimisticRollback(ctx context.Context, keys [][]byte, s
 
 		err := committer.pessimisticRollbackMutations(retry.NewBackofferWithVars(ctx, pessimisticRollbackMaxBackoff, txn.vars), &PlainMutations{keys: keys})
 		if err != nil {
+			txn.store.AsyncOps().fail(errors.WithMessagef(err, "pessimistic rollback txn %d", txn.startTS))
 			logutil.Logger(ctx).Warn("[kv] pessimisticRollback failed.", zap.Error(err))
 		}
 		wg.Done()
@@ -1843,6 +2150,22 @@ func (txn *KVTxn) CommitTS() uint64 {
 	return txn.commitTS
 }
 
+// CommittedSecondariesDone returns a channel closed once the transaction finishes committing all of its keys, see
+// CommittedSecondariesErr for the result. Commit returns once the primary key is committed, and commits the secondary
+// keys in the background, so the applications needing all the keys committed, e.g. before deleting the source data,
+// can wait for the channel. The channel is also closed if Commit fails or the transaction is rolled back.
+func (txn *KVTxn) CommittedSecondariesDone() <-chan struct{} {
+	return txn.secondaries.doneCh()
+}
+
+// CommittedSecondariesErr returns the result of committing all the keys of the transaction once the channel returned
+// by CommittedSecondariesDone is closed, and nil before that. A non-nil error means the secondary keys are not
+// committed by the transaction, they will be committed by the readers resolving their locks later. It's the error of
+// Commit if Commit fails, and an error as well if the transaction is rolled back.
+func (txn *KVTxn) CommittedSecondariesErr() error {
+	return txn.secondaries.result()
+}
+
 // Valid returns if the transaction is valid.
 // A transaction become invalid after commit or rollback.
 func (txn *KVTxn) Valid() bool {
@@ -1889,6 +2212,10 @@ func (txn *KVTxn) GetClusterID() uint64 {
 
 // SetMemoryFootprintChangeHook sets the hook function that is triggered when memdb grows
 func (txn *KVTxn) SetMemoryFootprintChangeHook(hook func(uint64)) {
+	if txn.trackMem {
+		txn.memHook = hook
+		return
+	}
 	txn.us.GetMemBuffer().SetMemoryFootprintChangeHook(hook)
 }
 
@@ -1914,6 +2241,9 @@ func (txn *KVTxn) SetExplicitRequestSourceType(tp string) {
 
 // MemHookSet returns whether the mem buffer has a memory footprint change hook set.
 func (txn *KVTxn) MemHookSet() bool {
+	if txn.trackMem {
+		return txn.memHook != nil
+	}
 	return txn.us.GetMemBuffer().MemHookSet()
 }
 
@@ -1923,3 +2253,38 @@ type LifecycleHooks struct {
 	Pre  func()
 	Post func()
 }
+
+// secondariesResult is the result of committing the secondary keys of a transaction, see
+// KVTxn.CommittedSecondariesDone.
+type secondariesResult struct {
+	once       sync.Once
+	finishOnce sync.Once
+	// done is closed after err is set.
+	done chan struct{}
+	err  error
+	// background is set if the secondary keys are committed in the background, whose goroutine reports the result.
+	background bool
+}
+
+func (r *secondariesResult) doneCh() chan struct{} {
+	r.once.Do(func() {
+		r.done = make(chan struct{})
+	})
+	return r.done
+}
+
+func (r *secondariesResult) result() error {
+	select {
+	case <-r.doneCh():
+		return r.err
+	default:
+		return nil
+	}
+}
+
+func (r *secondariesResult) finish(err error) {
+	r.finishOnce.Do(func() {
+		r.err = err
+		close(r.doneCh())
+	})
+}
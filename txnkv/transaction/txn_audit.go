@@ -0,0 +1,152 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"go.uber.org/zap"
+)
+
+var (
+	concurrentUseAudit        atomic.Bool
+	concurrentUseAuditHandler atomic.Pointer[func(*ConcurrentUseError)]
+)
+
+// EnableConcurrentUseAudit enables or disables the checker detecting the
+// concurrent misuse of the transactions. A transaction may be read from
+// multiple goroutines concurrently, but its writes, locks, commit and rollback
+// must not run concurrently with any other operation of it. The misuses are
+// reported to the handler set by SetConcurrentUseAuditHandler, or logged if
+// it's not set. The checker costs an atomic operation per call, so it's meant
+// for the tests and the debugging.
+func EnableConcurrentUseAudit(enable bool) {
+	concurrentUseAudit.Store(enable)
+}
+
+// SetConcurrentUseAuditHandler sets the handler of the concurrent misuses
+// detected by the checker. Pass nil to log them.
+func SetConcurrentUseAuditHandler(handler func(*ConcurrentUseError)) {
+	if handler == nil {
+		concurrentUseAuditHandler.Store(nil)
+		return
+	}
+	concurrentUseAuditHandler.Store(&handler)
+}
+
+// ConcurrentUseError describes a concurrent misuse of a transaction.
+type ConcurrentUseError struct {
+	StartTS uint64
+	// Method and CallSite are the method called and its caller.
+	Method   string
+	CallSite string
+	// ConflictMethod and ConflictCallSite are the ones running concurrently.
+	// ConflictCallSite is empty if the conflicting call has just entered.
+	ConflictMethod   string
+	ConflictCallSite string
+}
+
+func (e *ConcurrentUseError) Error() string {
+	return fmt.Sprintf("concurrent use of txn %d: %s at %s conflicts with %s at %s",
+		e.StartTS, e.Method, e.CallSite, e.ConflictMethod, e.ConflictCallSite)
+}
+
+type useSite struct {
+	method string
+	caller string
+}
+
+// useChecker tracks the operations running on a transaction. state is the
+// number of the running reads, or -1 if a write is running.
+type useChecker struct {
+	state      atomic.Int64
+	writerSite atomic.Pointer[useSite]
+	readerSite atomic.Pointer[useSite]
+}
+
+func noopRelease() {}
+
+// auditRead checks a read of the transaction, and returns the function to
+// call after the read.
+func (txn *KVTxn) auditRead(method string) func() {
+	if !concurrentUseAudit.Load() {
+		return noopRelease
+	}
+	site := newUseSite(method)
+	c := &txn.useChecker
+	for {
+		s := c.state.Load()
+		if s < 0 {
+			txn.reportConcurrentUse(site, c.writerSite.Load())
+			return noopRelease
+		}
+		if c.state.CompareAndSwap(s, s+1) {
+			c.readerSite.Store(site)
+			return func() { c.state.Add(-1) }
+		}
+	}
+}
+
+// auditWrite checks an operation of the transaction which must not run
+// concurrently with others, and returns the function to call after it.
+func (txn *KVTxn) auditWrite(method string) func() {
+	if !concurrentUseAudit.Load() {
+		return noopRelease
+	}
+	site := newUseSite(method)
+	c := &txn.useChecker
+	if !c.state.CompareAndSwap(0, -1) {
+		if c.state.Load() < 0 {
+			txn.reportConcurrentUse(site, c.writerSite.Load())
+		} else {
+			txn.reportConcurrentUse(site, c.readerSite.Load())
+		}
+		return noopRelease
+	}
+	c.writerSite.Store(site)
+	return func() {
+		c.writerSite.Store(nil)
+		c.state.Store(0)
+	}
+}
+
+func newUseSite(method string) *useSite {
+	site := &useSite{method: method}
+	// Skip newUseSite, the audit function and the method of KVTxn.
+	if _, file, line, ok := runtime.Caller(3); ok {
+		site.caller = fmt.Sprintf("%s:%d", file, line)
+	}
+	return site
+}
+
+func (txn *KVTxn) reportConcurrentUse(site, conflict *useSite) {
+	err := &ConcurrentUseError{
+		StartTS:  txn.startTS,
+		Method:   site.method,
+		CallSite: site.caller,
+	}
+	if conflict != nil {
+		err.ConflictMethod = conflict.method
+		err.ConflictCallSite = conflict.caller
+	}
+	if handler := concurrentUseAuditHandler.Load(); handler != nil {
+		(*handler)(err)
+		return
+	}
+	logutil.BgLogger().Error("concurrent use of transaction detected", zap.Error(err), zap.Stack("stack"))
+}
@@ -0,0 +1,110 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	tikverr "github.com/tikv/client-go/v2/error"
+	tikvtesting "github.com/tikv/client-go/v2/tikv/testing"
+	"github.com/tikv/client-go/v2/util/memquota"
+)
+
+func TestMemQuota(t *testing.T) {
+	store, err := tikvtesting.NewStore()
+	require.Nil(t, err)
+	defer store.Close()
+	defer memquota.SetGlobal(nil)
+
+	for _, cfg := range []memquota.Config{
+		{Limit: 1 << 20, Policy: memquota.PolicyError},
+		// The membuffer doesn't wait for the memory, which may be held only by
+		// itself, though there is no block timeout.
+		{Limit: 1 << 20, Policy: memquota.PolicyBlock},
+	} {
+		quota := memquota.New(cfg)
+		memquota.SetGlobal(quota)
+
+		txn, err := store.Begin()
+		require.Nil(t, err)
+		value := make([]byte, 4096)
+		done := make(chan error, 1)
+		go func() {
+			for i := 0; ; i++ {
+				if err := txn.Set([]byte(fmt.Sprintf("memquota%04d", i)), value); err != nil {
+					done <- err
+					return
+				}
+			}
+		}()
+		select {
+		case err = <-done:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "set is blocked", "policy %v", cfg.Policy)
+		}
+		require.True(t, tikverr.IsErrMemQuotaExceeded(err), "%v", err)
+		require.Greater(t, quota.Used(), cfg.Limit-int64(len(value))-16)
+		require.Nil(t, txn.Rollback())
+		require.Zero(t, quota.Used())
+
+		txn, err = store.Begin()
+		require.Nil(t, err)
+		for i := 0; i < 10; i++ {
+			require.Nil(t, txn.Set([]byte(fmt.Sprintf("memquota%04d", i)), value))
+		}
+		require.Positive(t, quota.Used())
+		require.Nil(t, txn.Commit(context.Background()))
+		require.Zero(t, quota.Used())
+	}
+}
+
+func TestMemQuotaFootprint(t *testing.T) {
+	store, err := tikvtesting.NewStore()
+	require.Nil(t, err)
+	defer store.Close()
+	defer memquota.SetGlobal(nil)
+	quota := memquota.New(memquota.Config{Limit: 1 << 30, Policy: memquota.PolicyError})
+	memquota.SetGlobal(quota)
+
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	var hooked uint64
+	txn.SetMemoryFootprintChangeHook(func(mem uint64) { hooked = mem })
+	require.True(t, txn.MemHookSet())
+	value := make([]byte, 4096)
+	// The quota held is the footprint of the membuffer, the overwritten
+	// values are not counted twice.
+	for i := 0; i < 100; i++ {
+		require.Nil(t, txn.Set([]byte("memquota"), value))
+	}
+	require.Equal(t, int64(txn.Mem()), quota.Used())
+	require.Less(t, quota.Used(), int64(100*len(value)))
+
+	// The writes through the membuffer are counted too, when the values grow
+	// the footprint, or by the next write of the transaction.
+	for i := 0; i < 100; i++ {
+		require.Nil(t, txn.GetMemBuffer().Set([]byte(fmt.Sprintf("memquota%04d", i)), value))
+	}
+	require.Greater(t, quota.Used(), int64(100*len(value)))
+	require.Equal(t, uint64(quota.Used()), hooked)
+	require.Nil(t, txn.Delete([]byte("memquota")))
+	require.Equal(t, int64(txn.Mem()), quota.Used())
+	require.Nil(t, txn.Rollback())
+	require.Zero(t, quota.Used())
+}
@@ -0,0 +1,70 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction_test
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pingcap/failpoint"
+	"github.com/stretchr/testify/require"
+	tikverr "github.com/tikv/client-go/v2/error"
+	tikvtesting "github.com/tikv/client-go/v2/tikv/testing"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+	"github.com/tikv/client-go/v2/util"
+)
+
+func TestCheckUndeterminedCommit(t *testing.T) {
+	util.EnableFailpoints()
+	store, err := tikvtesting.NewStore()
+	require.Nil(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	defer atomic.StoreUint64(&transaction.CommitMaxBackoff, atomic.LoadUint64(&transaction.CommitMaxBackoff))
+	atomic.StoreUint64(&transaction.CommitMaxBackoff, 500)
+	// Keep the store reachable after the RPC errors.
+	require.Nil(t, failpoint.Enable("tikvclient/injectLiveness", `return("reachable")`))
+	defer func() {
+		require.Nil(t, failpoint.Disable("tikvclient/injectLiveness"))
+	}()
+
+	commit := func(key string) error {
+		txn, err := store.Begin()
+		require.Nil(t, err)
+		txn.SetCheckUndeterminedCommit(true)
+		require.Nil(t, txn.Set([]byte(key), []byte(key)))
+		return txn.Commit(ctx)
+	}
+
+	// The commit of the primary key is applied but the response is lost, and the retries fail.
+	require.Nil(t, failpoint.Enable("tikvclient/rpcCommitTimeout", `1*return(true)`))
+	require.Nil(t, failpoint.Enable("tikvclient/rpcCommitResult", `1*return("")->return("notLeader")`))
+	err = commit("undetermined1")
+	require.Nil(t, failpoint.Disable("tikvclient/rpcCommitTimeout"))
+	require.Nil(t, failpoint.Disable("tikvclient/rpcCommitResult"))
+	require.Nil(t, err)
+	val, err := store.GetSnapshot(math.MaxUint64).Get(ctx, []byte("undetermined1"))
+	require.Nil(t, err)
+	require.Equal(t, []byte("undetermined1"), val)
+
+	// The commit is never applied, and the primary lock is alive.
+	require.Nil(t, failpoint.Enable("tikvclient/rpcCommitResult", `return("timeout")`))
+	err = commit("undetermined2")
+	require.Nil(t, failpoint.Disable("tikvclient/rpcCommitResult"))
+	require.True(t, tikverr.IsErrorUndetermined(err))
+}
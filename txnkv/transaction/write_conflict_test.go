@@ -0,0 +1,81 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/kv"
+	tikvtesting "github.com/tikv/client-go/v2/tikv/testing"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+)
+
+func TestWriteConflictKind(t *testing.T) {
+	store, err := tikvtesting.NewStore()
+	require.Nil(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	conflict := func(err error) *tikverr.ErrWriteConflict {
+		var e *tikverr.ErrWriteConflict
+		require.True(t, errors.As(err, &e), "%v", err)
+		return e
+	}
+	write := func(key string) *transaction.KVTxn {
+		txn, err := store.Begin()
+		require.Nil(t, err)
+		require.Nil(t, txn.Set([]byte(key), []byte("v")))
+		require.Nil(t, txn.Commit(ctx))
+		return txn
+	}
+
+	// An optimistic transaction conflicts with the write committed after it begins.
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	other := write("wck1")
+	require.Nil(t, txn.Set([]byte("wck1"), []byte("v")))
+	e := conflict(txn.Commit(ctx))
+	require.Equal(t, tikverr.WriteConflictOptimistic, e.Kind())
+	require.True(t, e.Retryable())
+	require.Equal(t, []byte("wck1"), e.GetKey())
+	require.Equal(t, other.StartTS(), e.GetConflictTs())
+	require.Equal(t, other.CommitTS(), e.GetConflictCommitTs())
+
+	// Locking the key written after the for update ts.
+	txn, err = store.Begin()
+	require.Nil(t, err)
+	txn.SetPessimistic(true)
+	write("wck2")
+	e = conflict(txn.LockKeys(ctx, kv.NewLockCtx(txn.StartTS(), kv.LockNoWait, time.Now()), []byte("wck2")))
+	require.Equal(t, tikverr.WriteConflictPessimistic, e.Kind())
+	require.True(t, e.Retryable())
+	require.Nil(t, txn.Rollback())
+
+	// The uniqueness check deferred to prewrite.
+	txn, err = store.Begin()
+	require.Nil(t, err)
+	txn.SetPessimistic(true)
+	require.Nil(t, txn.GetMemBuffer().SetWithFlags([]byte("wck3"), []byte("v"), kv.SetPresumeKeyNotExists, kv.SetNeedConstraintCheckInPrewrite))
+	write("wck3")
+	e = conflict(txn.Commit(ctx))
+	require.Equal(t, tikverr.WriteConflictLazyUniquenessCheck, e.Kind())
+	require.False(t, e.Retryable())
+	require.Equal(t, "lazy_uniqueness_check", e.Kind().String())
+}
@@ -18,7 +18,6 @@ import (
 	"bytes"
 	"container/list"
 	"context"
-	"encoding/hex"
 	"fmt"
 	"math"
 	"sync"
@@ -37,6 +36,7 @@ import (
 	"github.com/tikv/client-go/v2/oracle"
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/util"
+	"github.com/tikv/client-go/v2/util/redact"
 	"go.uber.org/zap"
 )
 
@@ -78,6 +78,9 @@ type LockResolver struct {
 		meetLock func(locks []*Lock)
 	}
 
+	// statusQueue deduplicates and limits the CheckTxnStatus requests.
+	statusQueue *txnStatusQueue
+
 	// LockResolver may have some goroutines resolving locks in the background.
 	// The Cancel function is to cancel these goroutines for passing goleak test.
 	asyncResolveCtx    context.Context
@@ -97,6 +100,7 @@ func NewLockResolver(store storage) *LockResolver {
 	r := &LockResolver{
 		store:                    store,
 		resolveLockLiteThreshold: config.GetGlobalConfig().TiKVClient.ResolveLockLiteThreshold,
+		statusQueue:              newTxnStatusQueue(defaultTxnStatusConcurrency),
 	}
 	r.mu.resolved = make(map[uint64]TxnStatus)
 	r.mu.resolving = make(map[uint64][][]Lock)
@@ -161,7 +165,9 @@ func (s TxnStatus) StatusCacheable() bool {
 }
 
 func (s TxnStatus) String() string {
-	// TODO: print primary lock after redact is introduced.
+	if s.primaryLock != nil {
+		return fmt.Sprintf("ttl:%v commit_ts:%v action: %v primary: %s", s.ttl, s.commitTS, s.action, redact.Key(s.primaryLock.GetPrimaryLock()))
+	}
 	return fmt.Sprintf("ttl:%v commit_ts:%v action: %v", s.ttl, s.commitTS, s.action)
 }
 
@@ -181,9 +187,9 @@ type Lock struct {
 func (l *Lock) String() string {
 	buf := bytes.NewBuffer(make([]byte, 0, 128))
 	buf.WriteString("key: ")
-	buf.WriteString(hex.EncodeToString(l.Key))
+	buf.WriteString(redact.Key(l.Key))
 	buf.WriteString(", primary: ")
-	buf.WriteString(hex.EncodeToString(l.Primary))
+	buf.WriteString(redact.Key(l.Primary))
 	return fmt.Sprintf("%s, txnStartTS: %d, lockForUpdateTS:%d, minCommitTs:%d, ttl: %d, type: %s, UseAsyncCommit: %t, txnSize: %d",
 		buf.String(), l.TxnID, l.LockForUpdateTS, l.MinCommitTS, l.TTL, l.LockType, l.UseAsyncCommit, l.TxnSize)
 }
@@ -465,6 +471,17 @@ func (lr *LockResolver) resolveLocks(bo *retry.Backoffer, opts ResolveLocksOptio
 		defer func() {
 			atomic.AddInt64(&detail.ResolveLockTime, int64(time.Since(startTime)))
 		}()
+		txnIDs := make([]uint64, len(locks))
+		for i, l := range locks {
+			txnIDs[i] = l.TxnID
+		}
+		detail.RecordLocks(txnIDs)
+	}
+	if audit := util.DeadlineAuditFromContext(bo.GetCtx()); audit != nil {
+		startTime := time.Now()
+		defer func() {
+			audit.Add(util.DeadlineStageResolveLock, time.Since(startTime))
+		}()
 	}
 
 	// TxnID -> []Region, record resolved Regions.
@@ -551,6 +568,10 @@ func (lr *LockResolver) resolveLocks(bo *retry.Backoffer, opts ResolveLocksOptio
 				TTL: msBeforeTxnExpired.value(),
 			}, err
 		}
+		if status.ttl == 0 {
+			detail.RecordResolved(1)
+			lr.publishLockResolved(l, status)
+		}
 		if !forRead {
 			if status.ttl != 0 {
 				metrics.LockResolverCountWithNotExpired.Inc()
@@ -586,6 +607,19 @@ func (lr *LockResolver) resolveLocks(bo *retry.Backoffer, opts ResolveLocksOptio
 	}, nil
 }
 
+func (lr *LockResolver) publishLockResolved(l *Lock, status TxnStatus) {
+	cache := lr.store.GetRegionCache()
+	if cache == nil || !cache.EventsEnabled() {
+		return
+	}
+	cache.PublishEvent(locate.Event{
+		Type:     locate.EventLockResolved,
+		TxnID:    l.TxnID,
+		Key:      l.Key,
+		CommitTS: status.CommitTS(),
+	})
+}
+
 // Resolving returns the locks' information we are resolving currently.
 func (lr *LockResolver) Resolving() []ResolvingLock {
 	result := []ResolvingLock{}
@@ -646,6 +680,64 @@ func (lr *LockResolver) GetTxnStatus(txnID uint64, callerStartTS uint64, primary
 	return lr.getTxnStatus(bo, txnID, primary, callerStartTS, currentTS, true, false, nil)
 }
 
+// ResolveAsyncCommitTxn finishes the async-commit transaction of startTS whose primary key is primary, e.g. left by a
+// crashed client, instead of waiting for the reads to meet its locks. If the primary lock is expired, all secondary
+// locks are checked by CheckSecondaryLocks to decide whether the transaction is committed or rolled back, and the
+// primary and the secondary locks are resolved accordingly. The transaction is rolled back if any secondary lock isn't
+// prewritten by the async commit protocol, or if the primary lock doesn't exist, so it should only be called on the
+// transactions which are known to be abandoned.
+//
+// The returned status has a non-zero TTL if the primary lock is not expired yet, in which case nothing is resolved and
+// the caller may retry after the TTL.
+func (lr *LockResolver) ResolveAsyncCommitTxn(ctx context.Context, primary []byte, startTS uint64) (TxnStatus, error) {
+	bo := retry.NewBackoffer(ctx, asyncResolveLockMaxBackoff)
+	currentTS, err := lr.store.GetOracle().GetLowResolutionTimestamp(ctx, &oracle.Option{TxnScope: oracle.GlobalTxnScope})
+	if err != nil {
+		return TxnStatus{}, err
+	}
+	l := &Lock{Key: primary, Primary: primary, TxnID: startTS, UseAsyncCommit: true}
+	status, err := lr.getTxnStatus(bo, startTS, primary, 0, currentTS, true, false, nil)
+	if err != nil {
+		return TxnStatus{}, err
+	}
+	if status.ttl != 0 || status.primaryLock == nil || !status.primaryLock.UseAsyncCommit {
+		// The primary lock is alive, or resolved by CheckTxnStatus already.
+		return status, nil
+	}
+	secondaries := status.primaryLock.GetSecondaries()
+	status, err = lr.resolveAsyncCommitLock(bo, l, status, false)
+	if _, ok := errors.Cause(err).(*nonAsyncCommitLock); !ok {
+		return status, err
+	}
+
+	// Some secondary lock is prewritten in the normal 2PC, so the transaction is resolved by its primary lock, and then
+	// the secondary locks are resolved by the status.
+	status, err = lr.getTxnStatus(bo, startTS, primary, 0, currentTS, true, true, nil)
+	if err != nil || status.ttl != 0 {
+		return status, err
+	}
+	return status, lr.resolveAsyncResolveData(bo, l, status, &asyncResolveData{keys: secondaries})
+}
+
+// CheckTxnStatusForLock queries the status of the transaction of the lock by CheckTxnStatus as resolving the lock does,
+// but it never rolls back the transaction if its primary lock doesn't exist. The returned status has a non-zero TTL if
+// the lock is alive, in which case resolving the lock would be skipped.
+func (lr *LockResolver) CheckTxnStatusForLock(bo *retry.Backoffer, l *Lock, callerStartTS uint64) (TxnStatus, error) {
+	currentTS, err := lr.store.GetOracle().GetLowResolutionTimestamp(bo.GetCtx(), &oracle.Option{TxnScope: oracle.GlobalTxnScope})
+	if err != nil {
+		return TxnStatus{}, err
+	}
+	status, err := lr.getTxnStatus(bo, l.TxnID, l.Primary, callerStartTS, currentTS, false, false, l)
+	if _, ok := errors.Cause(err).(txnNotFoundErr); ok {
+		// Resolving the lock rolls back the transaction once the lock is expired.
+		if lr.store.GetOracle().UntilExpired(l.TxnID, l.TTL, &oracle.Option{TxnScope: oracle.GlobalTxnScope}) > 0 {
+			return TxnStatus{ttl: l.TTL}, nil
+		}
+		return TxnStatus{}, nil
+	}
+	return status, err
+}
+
 func (lr *LockResolver) getTxnStatusFromLock(bo *retry.Backoffer, l *Lock, callerStartTS uint64, forceSyncCommit bool, detail *util.ResolveLockDetail) (TxnStatus, error) {
 	var currentTS uint64
 	var err error
@@ -743,6 +835,29 @@ func (lr *LockResolver) getTxnStatus(bo *retry.Backoffer, txnID uint64, primary
 		return s, nil
 	}
 
+	// Concurrent resolvers of the same lock share one CheckTxnStatus request.
+	key := txnStatusKey{
+		txnID:                    txnID,
+		primary:                  string(primary),
+		rollbackIfNotExist:       rollbackIfNotExist,
+		forceSyncCommit:          forceSyncCommit,
+		resolvingPessimisticLock: lockInfo != nil && lockInfo.LockType == kvrpcpb.Op_PessimisticLock,
+		forceExpire:              currentTS == math.MaxUint64,
+	}
+	return lr.statusQueue.do(bo.GetCtx(), key, callerStartTS, func(ctx context.Context) (TxnStatus, error) {
+		// The transaction may be resolved while waiting in the queue.
+		if s, ok := lr.getResolved(txnID); ok {
+			return s, nil
+		}
+		// The request is shared, so it doesn't back off on the context of bo.
+		sharedBo := bo.Clone()
+		sharedBo.SetCtx(ctx)
+		return lr.checkTxnStatus(sharedBo, txnID, primary, callerStartTS, currentTS, rollbackIfNotExist, forceSyncCommit, lockInfo)
+	})
+}
+
+func (lr *LockResolver) checkTxnStatus(bo *retry.Backoffer, txnID uint64, primary []byte,
+	callerStartTS, currentTS uint64, rollbackIfNotExist bool, forceSyncCommit bool, lockInfo *Lock) (TxnStatus, error) {
 	metrics.LockResolverCountWithQueryTxnStatus.Inc()
 
 	// CheckTxnStatus may meet the following cases:
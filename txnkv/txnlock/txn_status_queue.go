@@ -0,0 +1,215 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnlock
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+)
+
+// defaultTxnStatusConcurrency is the max number of CheckTxnStatus requests a
+// LockResolver sends at the same time.
+const defaultTxnStatusConcurrency = 128
+
+// txnStatusKey identifies the CheckTxnStatus requests that get the same
+// result. The caller start ts is not a part of it, see txnStatusQueue.do.
+type txnStatusKey struct {
+	txnID                    uint64
+	primary                  string
+	rollbackIfNotExist       bool
+	forceSyncCommit          bool
+	resolvingPessimisticLock bool
+	// forceExpire is set if the lock is resolved unconditionally.
+	forceExpire bool
+}
+
+// txnStatusFlight is a CheckTxnStatus request shared by all goroutines
+// resolving the same lock.
+type txnStatusFlight struct {
+	key           txnStatusKey
+	callerStartTS uint64
+	// waiters is the number of goroutines waiting for the result, which is
+	// the priority of the flight in the queue.
+	waiters int
+	// index is the index in the pending heap, -1 if it's not pending.
+	index int
+	fn    func(ctx context.Context) (TxnStatus, error)
+	// ctx is the context fn runs with. It's detached from the waiters, and
+	// cancelled once all of them leave.
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	status TxnStatus
+	err    error
+}
+
+type pendingFlights []*txnStatusFlight
+
+func (h pendingFlights) Len() int { return len(h) }
+
+func (h pendingFlights) Less(i, j int) bool {
+	// Hot locks go first, and older transactions go first among the locks
+	// as hot.
+	if h[i].waiters != h[j].waiters {
+		return h[i].waiters > h[j].waiters
+	}
+	return h[i].key.txnID < h[j].key.txnID
+}
+
+func (h pendingFlights) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *pendingFlights) Push(x any) {
+	f := x.(*txnStatusFlight)
+	f.index = len(*h)
+	*h = append(*h, f)
+}
+
+func (h *pendingFlights) Pop() any {
+	old := *h
+	n := len(old)
+	f := old[n-1]
+	old[n-1] = nil
+	f.index = -1
+	*h = old[:n-1]
+	return f
+}
+
+// txnStatusQueue deduplicates the concurrent CheckTxnStatus requests of the
+// same lock, and limits the requests in flight. The requests exceeding the
+// limit are queued by priority.
+type txnStatusQueue struct {
+	mu      sync.Mutex
+	limit   int
+	running int
+	flights map[txnStatusKey]*txnStatusFlight
+	pending pendingFlights
+}
+
+func newTxnStatusQueue(limit int) *txnStatusQueue {
+	return &txnStatusQueue{limit: limit, flights: make(map[txnStatusKey]*txnStatusFlight)}
+}
+
+// do calls fn to get the status of the transaction, or waits for the result
+// of the same request from another goroutine. CheckTxnStatus pushes the
+// min_commit_ts of the lock to the caller start ts, so the request is only
+// shared with the flights whose caller start ts are not smaller.
+//
+// fn runs in another goroutine with a context detached from the callers, so a
+// caller leaving on its ctx doesn't fail the others. The request is cancelled
+// only if all the callers leave.
+func (q *txnStatusQueue) do(ctx context.Context, key txnStatusKey, callerStartTS uint64, fn func(ctx context.Context) (TxnStatus, error)) (TxnStatus, error) {
+	for {
+		f := q.join(ctx, key, callerStartTS, fn)
+		select {
+		case <-f.done:
+			if f.err != nil && f.ctx.Err() != nil && ctx.Err() == nil {
+				// The flight is cancelled as its callers left, send the
+				// request again.
+				continue
+			}
+			return f.status, f.err
+		case <-ctx.Done():
+			q.leave(f)
+			return TxnStatus{}, ctx.Err()
+		}
+	}
+}
+
+// join returns the flight of the request, which is created and queued if no
+// flight can be shared.
+func (q *txnStatusQueue) join(ctx context.Context, key txnStatusKey, callerStartTS uint64, fn func(ctx context.Context) (TxnStatus, error)) *txnStatusFlight {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if f, ok := q.flights[key]; ok && f.callerStartTS >= callerStartTS {
+		f.waiters++
+		if f.index >= 0 {
+			heap.Fix(&q.pending, f.index)
+		}
+		return f
+	}
+
+	flightCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	f := &txnStatusFlight{
+		key:           key,
+		callerStartTS: callerStartTS,
+		waiters:       1,
+		index:         -1,
+		fn:            fn,
+		ctx:           flightCtx,
+		cancel:        cancel,
+		done:          make(chan struct{}),
+	}
+	// A flight with a smaller caller start ts keeps running for its own
+	// waiters, but new callers join this one.
+	q.flights[key] = f
+	if q.running < q.limit {
+		q.running++
+		go q.run(f)
+	} else {
+		heap.Push(&q.pending, f)
+	}
+	return f
+}
+
+func (q *txnStatusQueue) run(f *txnStatusFlight) {
+	f.status, f.err = f.fn(f.ctx)
+	q.mu.Lock()
+	q.finishLocked(f)
+	q.mu.Unlock()
+	f.cancel()
+	close(f.done)
+}
+
+// leave is called by a waiter of f giving up. The flight is cancelled, or
+// dropped from the queue if it's not started, when it has no waiters.
+func (q *txnStatusQueue) leave(f *txnStatusFlight) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	f.waiters--
+	if f.waiters > 0 {
+		if f.index >= 0 {
+			heap.Fix(&q.pending, f.index)
+		}
+		return
+	}
+	q.removeLocked(f)
+	if f.index >= 0 {
+		heap.Remove(&q.pending, f.index)
+	}
+	f.cancel()
+}
+
+func (q *txnStatusQueue) removeLocked(f *txnStatusFlight) {
+	if q.flights[f.key] == f {
+		delete(q.flights, f.key)
+	}
+}
+
+// finishLocked removes the running flight and starts the next pending one.
+func (q *txnStatusQueue) finishLocked(f *txnStatusFlight) {
+	q.removeLocked(f)
+	if q.pending.Len() > 0 {
+		next := heap.Pop(&q.pending).(*txnStatusFlight)
+		go q.run(next)
+		return
+	}
+	q.running--
+}
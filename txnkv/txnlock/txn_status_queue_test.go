@@ -0,0 +1,191 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnlock
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxnStatusQueue(t *testing.T) {
+	q := newTxnStatusQueue(1)
+	ctx := context.Background()
+	keyA, keyB, keyC := txnStatusKey{txnID: 1}, txnStatusKey{txnID: 2}, txnStatusKey{txnID: 3}
+
+	var (
+		wg      sync.WaitGroup
+		calls   atomic.Int32
+		orderMu sync.Mutex
+		order   []uint64
+	)
+	unblock := make(chan struct{})
+	do := func(key txnStatusKey, callerStartTS uint64) {
+		defer wg.Done()
+		status, err := q.do(ctx, key, callerStartTS, func(context.Context) (TxnStatus, error) {
+			calls.Add(1)
+			orderMu.Lock()
+			order = append(order, key.txnID)
+			orderMu.Unlock()
+			if key == keyA {
+				<-unblock
+			}
+			return TxnStatus{commitTS: key.txnID + 100}, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, key.txnID+100, status.CommitTS())
+	}
+	waitQueued := func(key txnStatusKey, waiters int) {
+		require.Eventually(t, func() bool {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			f, ok := q.flights[key]
+			return ok && f.waiters == waiters
+		}, time.Second, time.Millisecond)
+	}
+
+	wg.Add(1)
+	go do(keyA, 10)
+	waitQueued(keyA, 1)
+	// The callers with smaller start ts share the running request.
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go do(keyA, 5)
+	}
+	waitQueued(keyA, 4)
+
+	// C is hotter than B, so it goes first though B is older.
+	wg.Add(1)
+	go do(keyB, 10)
+	waitQueued(keyB, 1)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go do(keyC, 10)
+	}
+	waitQueued(keyC, 3)
+	// A caller with a larger start ts can't share the request of B.
+	wg.Add(1)
+	go do(keyB, 20)
+	require.Eventually(t, func() bool {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return q.pending.Len() == 3
+	}, time.Second, time.Millisecond)
+
+	close(unblock)
+	wg.Wait()
+	require.Equal(t, int32(4), calls.Load())
+	require.Equal(t, []uint64{1, 3, 2, 2}, order)
+	require.Empty(t, q.flights)
+	require.Zero(t, q.running)
+}
+
+func TestTxnStatusQueueCancel(t *testing.T) {
+	q := newTxnStatusQueue(1)
+	unblock := make(chan struct{})
+	go q.do(context.Background(), txnStatusKey{txnID: 1}, 0, func(context.Context) (TxnStatus, error) {
+		<-unblock
+		return TxnStatus{}, nil
+	})
+	require.Eventually(t, func() bool {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return q.running == 1
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := q.do(ctx, txnStatusKey{txnID: 2}, 0, func(context.Context) (TxnStatus, error) {
+		require.FailNow(t, "should not run")
+		return TxnStatus{}, nil
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	close(unblock)
+	require.Eventually(t, func() bool {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return q.running == 0 && len(q.flights) == 0 && q.pending.Len() == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestTxnStatusQueueOwnerCancel(t *testing.T) {
+	q := newTxnStatusQueue(1)
+	key := txnStatusKey{txnID: 1}
+	unblock := make(chan struct{})
+	var calls atomic.Int32
+	fn := func(ctx context.Context) (TxnStatus, error) {
+		calls.Add(1)
+		select {
+		case <-unblock:
+			return TxnStatus{commitTS: 100}, nil
+		case <-ctx.Done():
+			return TxnStatus{}, ctx.Err()
+		}
+	}
+
+	// The caller sending the request leaves, and the other one still gets
+	// the result.
+	ownerCtx, cancelOwner := context.WithCancel(context.Background())
+	ownerErr := make(chan error, 1)
+	go func() {
+		_, err := q.do(ownerCtx, key, 10, fn)
+		ownerErr <- err
+	}()
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	waiterDone := make(chan TxnStatus, 1)
+	go func() {
+		status, err := q.do(context.Background(), key, 5, fn)
+		require.NoError(t, err)
+		waiterDone <- status
+	}()
+	require.Eventually(t, func() bool {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return q.flights[key].waiters == 2
+	}, time.Second, time.Millisecond)
+	cancelOwner()
+	require.ErrorIs(t, <-ownerErr, context.Canceled)
+	close(unblock)
+	require.Equal(t, uint64(100), (<-waiterDone).CommitTS())
+	require.Equal(t, int32(1), calls.Load())
+
+	// The request is cancelled once all the callers leave.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan struct{})
+	go func() {
+		_, err := q.do(ctx, txnStatusKey{txnID: 2}, 0, func(ctx context.Context) (TxnStatus, error) {
+			<-ctx.Done()
+			close(cancelled)
+			return TxnStatus{}, ctx.Err()
+		})
+		require.ErrorIs(t, err, context.Canceled)
+	}()
+	require.Eventually(t, func() bool {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return q.running == 1
+	}, time.Second, time.Millisecond)
+	cancel()
+	<-cancelled
+	require.Eventually(t, func() bool {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return q.running == 0 && len(q.flights) == 0
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnsnapshot_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/pingcap/kvproto/pkg/errorpb"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/testutils"
+	tikvtesting "github.com/tikv/client-go/v2/tikv/testing"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/tikvrpc/interceptor"
+	"github.com/tikv/client-go/v2/txnkv/txnsnapshot"
+)
+
+func TestAdaptiveScanBatch(t *testing.T) {
+	var cluster *testutils.MockCluster
+	store, err := tikvtesting.NewStore(tikvtesting.WithCluster(func(c *testutils.MockCluster) {
+		cluster = c
+	}))
+	require.Nil(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	for i := 0; i < 40; i++ {
+		require.Nil(t, txn.Set([]byte(fmt.Sprintf("asb%02d", i)), make([]byte, 95)))
+	}
+	require.Nil(t, txn.Commit(ctx))
+
+	var limits []uint32
+	recorder := interceptor.NewRPCInterceptor("scan-limits", func(next interceptor.RPCInterceptorFunc) interceptor.RPCInterceptorFunc {
+		return func(target string, req *tikvrpc.Request) (*tikvrpc.Response, error) {
+			if req.Type == tikvrpc.CmdScan {
+				limits = append(limits, req.Scan().Limit)
+			}
+			return next(target, req)
+		}
+	})
+	scan := func(cfg txnsnapshot.AdaptiveScanBatch) []uint32 {
+		limits = limits[:0]
+		snapshot := store.GetSnapshot(math.MaxUint64)
+		snapshot.SetAdaptiveScanBatch(&cfg)
+		snapshot.SetRPCInterceptor(recorder)
+		it, err := snapshot.Iter([]byte("asb"), []byte("asc"))
+		require.Nil(t, err)
+		defer it.Close()
+		n := 0
+		for ; it.Valid(); n++ {
+			require.Nil(t, it.Next())
+		}
+		require.Equal(t, 40, n)
+		return append([]uint32(nil), limits...)
+	}
+
+	// Grows geometrically up to MaxSize.
+	require.Equal(t, []uint32{4, 8, 16, 16}, scan(txnsnapshot.AdaptiveScanBatch{MinSize: 4, MaxSize: 16}))
+	// Capped by MaxBytes, the entries are 100 bytes.
+	require.Equal(t, []uint32{4, 8, 10, 10, 10}, scan(txnsnapshot.AdaptiveScanBatch{MinSize: 4, MaxSize: 16, MaxBytes: 1000}))
+	// Shrinks when TiKV is busy.
+	cluster.FailNth(tikvrpc.CmdScan, 3, testutils.RequestFault{RegionError: &errorpb.Error{ServerIsBusy: &errorpb.ServerIsBusy{}}})
+	require.Equal(t, []uint32{4, 8, 16, 16, 8, 16}, scan(txnsnapshot.AdaptiveScanBatch{MinSize: 4, MaxSize: 16}))
+}
@@ -0,0 +1,121 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnsnapshot
+
+import (
+	"context"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pkg/errors"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/kv"
+)
+
+// ArchiveReader reads the data at the timestamps older than the GC safe point,
+// which may have been garbage collected in TiKV, from an external storage like
+// the backups of BR. The implementations must return the same data as TiKV
+// would return before GC.
+type ArchiveReader interface {
+	// Get returns the value of the key at ts, or nil if the key doesn't exist.
+	Get(ctx context.Context, key []byte, ts uint64) ([]byte, error)
+	// BatchGet returns the values of the existing keys at ts.
+	BatchGet(ctx context.Context, keys [][]byte, ts uint64) (map[string][]byte, error)
+	// Scan returns at most limit pairs in [startKey, endKey) at ts, in
+	// ascending order or in descending order if reverse is set. An empty
+	// startKey or endKey means unbounded.
+	Scan(ctx context.Context, startKey, endKey []byte, limit int, ts uint64, reverse bool) (keys, values [][]byte, err error)
+}
+
+// SetArchiveReader sets the reader to serve the reads of the snapshot when its
+// ts falls behind the GC safe point. Get, BatchGet and Iter read from TiKV
+// first, and fall back to r if the safe point is found to exceed the snapshot
+// ts. An iterator continues from the archive at the key where it stops in
+// TiKV. Without an archive reader, the reads fail with *tikverr.ErrGCTooEarly.
+func (s *KVSnapshot) SetArchiveReader(r ArchiveReader) {
+	s.archive = r
+}
+
+// fallbackToArchive checks whether the reads should be served by the archive
+// reader, err is the result of the visibility check.
+func (s *KVSnapshot) fallbackToArchive(err error) bool {
+	var gcErr *tikverr.ErrGCTooEarly
+	return s.archive != nil && errors.As(err, &gcErr)
+}
+
+// archiveGet gets the value of k from the archive reader.
+func (s *KVSnapshot) archiveGet(ctx context.Context, k []byte) ([]byte, error) {
+	val, err := s.archive.Get(ctx, k, s.version)
+	if err != nil {
+		return nil, errors.WithMessage(err, "read from archive")
+	}
+	s.UpdateSnapshotCache([][]byte{k}, map[string][]byte{string(k): val})
+	if len(val) == 0 {
+		return nil, tikverr.ErrNotExist
+	}
+	return val, nil
+}
+
+// archiveBatchGet gets the values of keys from the archive reader, the values
+// of them read from TiKV in m are dropped.
+func (s *KVSnapshot) archiveBatchGet(ctx context.Context, keys [][]byte, m map[string][]byte) (map[string][]byte, error) {
+	for _, k := range keys {
+		delete(m, string(k))
+	}
+	vals, err := s.archive.BatchGet(ctx, keys, s.version)
+	if err != nil {
+		return nil, errors.WithMessage(err, "read from archive")
+	}
+	for k, v := range vals {
+		if len(v) > 0 {
+			m[k] = v
+		}
+	}
+	s.UpdateSnapshotCache(keys, m)
+	return m, nil
+}
+
+// getDataFromArchive reads the next batch of the scanner from the archive
+// reader.
+func (s *Scanner) getDataFromArchive(ctx context.Context) error {
+	startKey, endKey := s.nextStartKey, s.endKey
+	if s.reverse {
+		endKey = s.nextEndKey
+	}
+	keys, values, err := s.snapshot.archive.Scan(ctx, startKey, endKey, s.batchSize, s.startTS(), s.reverse)
+	if err != nil {
+		return errors.WithMessage(err, "scan from archive")
+	}
+	if len(keys) != len(values) {
+		return errors.Errorf("archive returns %d keys and %d values", len(keys), len(values))
+	}
+	pairs := make([]*kvrpcpb.KvPair, len(keys))
+	for i := range keys {
+		pairs[i] = &kvrpcpb.KvPair{Key: keys[i], Value: values[i]}
+	}
+	if err := s.setCache(ctx, pairs); err != nil {
+		return err
+	}
+	if len(pairs) < s.batchSize {
+		s.eof = true
+		return nil
+	}
+	lastKey := keys[len(keys)-1]
+	if !s.reverse {
+		s.nextStartKey = kv.NextKey(lastKey)
+	} else {
+		s.nextEndKey = lastKey
+	}
+	return nil
+}
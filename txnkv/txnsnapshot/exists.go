@@ -0,0 +1,46 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnsnapshot
+
+import (
+	"context"
+)
+
+// Exists returns whether the key exists, see BatchExists.
+func (s *KVSnapshot) Exists(ctx context.Context, k []byte) (bool, error) {
+	res, err := s.BatchExists(ctx, [][]byte{k})
+	if err != nil {
+		return false, err
+	}
+	return res[0], nil
+}
+
+// BatchExists returns whether the keys exist, the i-th element of the result
+// is for keys[i]. The keys are grouped by their regions and checked by one
+// BatchGet request per region as BatchGet does, and a key exists if it's in
+// the response. TiKV has no key-only point get, so the values are still
+// transferred, and they are put into the snapshot cache like BatchGet, so
+// reading the keys later needs no request.
+func (s *KVSnapshot) BatchExists(ctx context.Context, keys [][]byte) ([]bool, error) {
+	m, err := s.BatchGet(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]bool, len(keys))
+	for i, k := range keys {
+		_, res[i] = m[string(k)]
+	}
+	return res, nil
+}
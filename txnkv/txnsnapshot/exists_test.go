@@ -0,0 +1,72 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnsnapshot_test
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/oracle"
+	tikvtesting "github.com/tikv/client-go/v2/tikv/testing"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/tikvrpc/interceptor"
+)
+
+func TestBatchExists(t *testing.T) {
+	store, err := tikvtesting.NewStore(tikvtesting.WithSplitKeys([]byte("m")))
+	require.Nil(t, err)
+	defer store.Close()
+
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	for _, k := range []string{"a", "c", "x"} {
+		require.Nil(t, txn.Set([]byte(k), []byte(k)))
+	}
+	require.Nil(t, txn.Commit(context.Background()))
+	// The secondaries may be committed in the background.
+	_, err = store.GetSnapshot(math.MaxUint64).BatchGet(context.Background(), [][]byte{[]byte("x")})
+	require.Nil(t, err)
+
+	var reqs atomic.Int32
+	ts, err := store.CurrentTimestamp(oracle.GlobalTxnScope)
+	require.Nil(t, err)
+	snapshot := store.GetSnapshot(ts)
+	snapshot.SetRPCInterceptor(interceptor.NewRPCInterceptor("count", func(next interceptor.RPCInterceptorFunc) interceptor.RPCInterceptorFunc {
+		return func(target string, req *tikvrpc.Request) (*tikvrpc.Response, error) {
+			if req.Type == tikvrpc.CmdBatchGet {
+				reqs.Add(1)
+			}
+			return next(target, req)
+		}
+	}))
+	keys := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("x"), []byte("y")}
+	res, err := snapshot.BatchExists(context.Background(), keys)
+	require.Nil(t, err)
+	require.Equal(t, []bool{true, false, true, true, false}, res)
+	// One request per region.
+	require.Equal(t, int32(2), reqs.Load())
+
+	// The result is cached.
+	ok, err := snapshot.Exists(context.Background(), []byte("b"))
+	require.Nil(t, err)
+	require.False(t, ok)
+	ok, err = snapshot.Exists(context.Background(), []byte("x"))
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, int32(2), reqs.Load())
+}
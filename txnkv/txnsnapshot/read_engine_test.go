@@ -0,0 +1,114 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnsnapshot_test
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/stretchr/testify/require"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/mockstore/mocktikv"
+	"github.com/tikv/client-go/v2/kv"
+	tikvtesting "github.com/tikv/client-go/v2/tikv/testing"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/tikvrpc/interceptor"
+)
+
+func TestReadEngine(t *testing.T) {
+	var (
+		cluster               *mocktikv.Cluster
+		tiflashStoreID        uint64
+		tikvAddr, tiflashAddr string
+	)
+	store, err := tikvtesting.NewStore(tikvtesting.WithStores(2), tikvtesting.WithCluster(func(c *mocktikv.Cluster) {
+		cluster = c
+		stores := c.GetAllStores()
+		slices.SortFunc(stores, func(a, b *metapb.Store) int { return cmp.Compare(a.GetId(), b.GetId()) })
+		tikvAddr, tiflashAddr = stores[0].GetAddress(), stores[1].GetAddress()
+		// The second store serves the TiFlash replicas.
+		tiflashStoreID = stores[1].GetId()
+		c.UpdateStoreAddr(tiflashStoreID, tiflashAddr, &metapb.StoreLabel{Key: "engine", Value: "tiflash"})
+	}))
+	require.Nil(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	require.Nil(t, txn.Set([]byte("k"), []byte("v")))
+	require.Nil(t, txn.Commit(ctx))
+
+	var targets []string
+	recorder := interceptor.NewRPCInterceptor("read-engine", func(next interceptor.RPCInterceptorFunc) interceptor.RPCInterceptorFunc {
+		return func(target string, req *tikvrpc.Request) (*tikvrpc.Response, error) {
+			targets = append(targets, target)
+			return next(target, req)
+		}
+	})
+	read := func(engine kv.ReadEngine) error {
+		targets = targets[:0]
+		snapshot := store.GetSnapshot(math.MaxUint64)
+		snapshot.SetReadEngine(engine)
+		snapshot.SetRPCInterceptor(recorder)
+		v, err := snapshot.Get(ctx, []byte("k"))
+		if err != nil {
+			return err
+		}
+		require.Equal(t, []byte("v"), v)
+		m, err := snapshot.BatchGet(ctx, [][]byte{[]byte("k"), []byte("k1")})
+		if err != nil {
+			return err
+		}
+		require.Equal(t, map[string][]byte{"k": []byte("v")}, m)
+		it, err := snapshot.Iter([]byte("k"), []byte("l"))
+		if err != nil {
+			return err
+		}
+		require.True(t, it.Valid())
+		require.Equal(t, []byte("k"), it.Key())
+		it.Close()
+		return nil
+	}
+	allTo := func(addr string) {
+		require.NotEmpty(t, targets)
+		for _, target := range targets {
+			require.Equal(t, addr, target)
+		}
+	}
+
+	require.Nil(t, read(kv.EngineTiKV))
+	allTo(tikvAddr)
+	require.Nil(t, read(kv.EngineTiFlash))
+	allTo(tiflashAddr)
+	require.Nil(t, read(kv.EngineAny))
+	allTo(tiflashAddr)
+
+	// Remove the TiFlash replica.
+	region, _, _, _ := cluster.GetRegionByKey([]byte("k"))
+	for _, peer := range region.GetPeers() {
+		if peer.GetStoreId() == tiflashStoreID {
+			cluster.RemovePeer(region.GetId(), peer.GetId())
+		}
+	}
+	err = read(kv.EngineTiFlash)
+	require.True(t, tikverr.IsErrNoEngineReplica(err), "%v", err)
+	require.Nil(t, read(kv.EngineAny))
+	allTo(tikvAddr)
+}
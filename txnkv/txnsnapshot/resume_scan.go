@@ -0,0 +1,125 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnsnapshot
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/util/codec"
+)
+
+const scanResumeTokenVersion byte = 1
+
+// ScanResumeToken is an opaque token returned after every batch of a
+// resumable scan. It records the snapshot ts and the position of the scan, so
+// the scan can be continued later, possibly by another client.
+type ScanResumeToken []byte
+
+type scanPosition struct {
+	startTS uint64
+	nextKey []byte
+	endKey  []byte
+}
+
+func (p *scanPosition) encode() ScanResumeToken {
+	b := []byte{scanResumeTokenVersion}
+	b = codec.EncodeUvarint(b, p.startTS)
+	b = codec.EncodeBytes(b, p.nextKey)
+	b = codec.EncodeBytes(b, p.endKey)
+	return b
+}
+
+func decodeScanResumeToken(token ScanResumeToken) (*scanPosition, error) {
+	if len(token) == 0 || token[0] != scanResumeTokenVersion {
+		return nil, errors.New("invalid scan resume token")
+	}
+	var (
+		p   scanPosition
+		b   = []byte(token[1:])
+		err error
+	)
+	if b, p.startTS, err = codec.DecodeUvarint(b); err != nil {
+		return nil, errors.WithMessage(err, "invalid scan resume token")
+	}
+	if b, p.nextKey, err = codec.DecodeBytes(b, nil); err != nil {
+		return nil, errors.WithMessage(err, "invalid scan resume token")
+	}
+	if b, p.endKey, err = codec.DecodeBytes(b, nil); err != nil {
+		return nil, errors.WithMessage(err, "invalid scan resume token")
+	}
+	if len(b) > 0 {
+		return nil, errors.New("invalid scan resume token")
+	}
+	return &p, nil
+}
+
+// StartTS returns the snapshot ts the scan was started at.
+func (t ScanResumeToken) StartTS() (uint64, error) {
+	p, err := decodeScanResumeToken(t)
+	if err != nil {
+		return 0, err
+	}
+	return p.startTS, nil
+}
+
+// ScanBatch reads at most limit key-value pairs in [startKey, endKey) at the
+// snapshot ts. An empty endKey means the scan is unbounded. It returns a token
+// to continue the scan with ResumeScanBatch, or a nil token if the range is
+// exhausted.
+func (s *KVSnapshot) ScanBatch(ctx context.Context, startKey, endKey []byte, limit int) (keys, values [][]byte, token ScanResumeToken, err error) {
+	return s.scanBatch(ctx, &scanPosition{startTS: s.version, nextKey: startKey, endKey: endKey}, limit)
+}
+
+// ResumeScanBatch continues the scan recorded in token and reads at most
+// limit key-value pairs at the snapshot ts, which may be fresher than the ts
+// the scan was started at. Locks met after resuming are resolved again as
+// the resolved locks of the previous snapshot are not kept in the token.
+func (s *KVSnapshot) ResumeScanBatch(ctx context.Context, token ScanResumeToken, limit int) (keys, values [][]byte, next ScanResumeToken, err error) {
+	p, err := decodeScanResumeToken(token)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	p.startTS = s.version
+	return s.scanBatch(ctx, p, limit)
+}
+
+func (s *KVSnapshot) scanBatch(ctx context.Context, p *scanPosition, limit int) (keys, values [][]byte, token ScanResumeToken, err error) {
+	if limit <= 0 {
+		return nil, nil, nil, errors.New("scan limit should be positive")
+	}
+	// Fetch one more pair to know whether the range is exhausted.
+	scanner, err := newScanner(s, p.nextKey, p.endKey, limit+1, false)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer scanner.Close()
+	for scanner.Valid() {
+		if err = ctx.Err(); err != nil {
+			return nil, nil, nil, errors.WithStack(err)
+		}
+		if len(keys) == limit {
+			next := &scanPosition{startTS: p.startTS, nextKey: kv.NextKey(keys[len(keys)-1]), endKey: p.endKey}
+			return keys, values, next.encode(), nil
+		}
+		keys = append(keys, scanner.Key())
+		values = append(values, scanner.Value())
+		if err = scanner.Next(); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return keys, values, nil, nil
+}
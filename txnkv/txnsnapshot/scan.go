@@ -37,6 +37,7 @@ package txnsnapshot
 import (
 	"bytes"
 	"context"
+	"time"
 
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/pkg/errors"
@@ -49,6 +50,9 @@ import (
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/tikvrpc/interceptor"
 	"github.com/tikv/client-go/v2/txnkv/txnlock"
+	"github.com/tikv/client-go/v2/util"
+	"github.com/tikv/client-go/v2/util/memquota"
+	"github.com/tikv/client-go/v2/util/redact"
 	"go.uber.org/zap"
 )
 
@@ -64,12 +68,37 @@ type Scanner struct {
 	// Use for reverse scan.
 	nextEndKey []byte
 	reverse    bool
+	keyOnly    bool
+
+	// limit is the max number of the entries to return, 0 means unlimited.
+	// It's pushed down to the requests so that no more is fetched.
+	limit    int
+	returned int
 
 	valid bool
 	eof   bool
+	// fromArchive is set once the scanner falls back to the archive reader.
+	fromArchive bool
+
+	// start is used to check the read latency budget of the snapshot.
+	start time.Time
+	// memTracker tracks the memory of the cached batch against the global memory quota.
+	memTracker memquota.Tracker
+	// adaptive adapts batchSize to the entries and the latency, see AdaptiveScanBatch.
+	adaptive *adaptiveScanState
+	// prefetcher fetches the batches in the background if it's set, see KVSnapshot.SetScanPrefetch.
+	prefetcher *scanPrefetcher
 }
 
 func newScanner(snapshot *KVSnapshot, startKey []byte, endKey []byte, batchSize int, reverse bool) (*Scanner, error) {
+	return newScannerWithKeyOnly(snapshot, startKey, endKey, batchSize, reverse, snapshot.keyOnly)
+}
+
+func newScannerWithKeyOnly(snapshot *KVSnapshot, startKey []byte, endKey []byte, batchSize int, reverse bool, keyOnly bool) (*Scanner, error) {
+	return newScannerWithLimit(snapshot, startKey, endKey, batchSize, reverse, keyOnly, 0)
+}
+
+func newScannerWithLimit(snapshot *KVSnapshot, startKey []byte, endKey []byte, batchSize int, reverse bool, keyOnly bool, limit int) (*Scanner, error) {
 	// It must be > 1. Otherwise scanner won't skipFirst.
 	if batchSize <= 1 {
 		batchSize = DefaultScanBatchSize
@@ -81,7 +110,18 @@ func newScanner(snapshot *KVSnapshot, startKey []byte, endKey []byte, batchSize
 		nextStartKey: startKey,
 		endKey:       endKey,
 		reverse:      reverse,
+		keyOnly:      keyOnly,
+		limit:        limit,
 		nextEndKey:   endKey,
+		start:        time.Now(),
+	}
+	if cfg := snapshot.adaptiveScanBatch; cfg != nil {
+		scanner.adaptive = &adaptiveScanState{cfg: cfg.normalize(batchSize)}
+		scanner.batchSize = scanner.adaptive.cfg.MinSize
+	}
+	if snapshot.scanPrefetch > 0 && limit <= 0 {
+		// The limited scans fetch no more than the limit, so there is nothing to prefetch.
+		scanner.prefetcher = startScanPrefetcher(scanner, snapshot.scanPrefetch)
 	}
 	err := scanner.Next()
 	if tikverr.IsErrNotFound(err) {
@@ -115,7 +155,12 @@ const scannerNextMaxBackoff = 20000
 
 // Next return next element.
 func (s *Scanner) Next() error {
-	bo := retry.NewBackofferWithVars(context.WithValue(context.Background(), retry.TxnStartKey, s.snapshot.version), scannerNextMaxBackoff, s.snapshot.vars)
+	ctx := context.WithValue(context.Background(), retry.TxnStartKey, s.snapshot.version)
+	ctx, cancelDeadline := util.WithDefaultDeadline(ctx, s.snapshot.deadlines.Scan)
+	defer cancelDeadline()
+	sloCtx, cancel := s.snapshot.withSLOBudget(ctx, s.start)
+	defer cancel()
+	bo := retry.NewBackofferWithVars(sloCtx, scannerNextMaxBackoff, s.snapshot.vars)
 	if !s.valid {
 		return errors.New("scanner iterator is invalid")
 	}
@@ -127,6 +172,10 @@ func (s *Scanner) Next() error {
 		bo.SetCtx(interceptor.WithRPCInterceptor(bo.GetCtx(), s.snapshot.mu.interceptor))
 	}
 	s.snapshot.mu.RUnlock()
+	if s.limit > 0 && s.returned >= s.limit {
+		s.Close()
+		return nil
+	}
 	var err error
 	for {
 		s.idx++
@@ -135,7 +184,24 @@ func (s *Scanner) Next() error {
 				s.Close()
 				return nil
 			}
-			err = s.getData(bo)
+			if s.prefetcher != nil {
+				err = s.prefetcher.next(s)
+			} else {
+				err = s.getData(bo)
+				if sloErr, ok := s.snapshot.sloBudgetExceeded(ctx, sloCtx, s.start, err); ok {
+					err = sloErr
+				}
+			}
+			if sloErr, ok := err.(*tikverr.ErrSLOBudgetExceeded); ok {
+				// The pairs returned before are the partial results, the caller may
+				// continue the scan from the resume key with a new scanner.
+				sloErr.ResumeKey = s.nextStartKey
+				if s.reverse {
+					sloErr.ResumeKey = s.nextEndKey
+				}
+				s.Close()
+				return sloErr
+			}
 			if err != nil {
 				s.Close()
 				return err
@@ -167,6 +233,7 @@ func (s *Scanner) Next() error {
 				continue
 			}
 		}
+		s.returned++
 		return nil
 	}
 }
@@ -174,6 +241,28 @@ func (s *Scanner) Next() error {
 // Close close iterator.
 func (s *Scanner) Close() {
 	s.valid = false
+	if s.prefetcher != nil {
+		s.prefetcher.stop()
+		s.prefetcher = nil
+	}
+	s.memTracker.ReleaseAll()
+}
+
+// setCache replaces the cached batch with pairs, whose memory is acquired from
+// the global memory quota in place of the previous batch, see
+// memquota.SetGlobal.
+func (s *Scanner) setCache(ctx context.Context, pairs []*kvrpcpb.KvPair) error {
+	s.cache, s.idx = nil, 0
+	s.memTracker.ReleaseAll()
+	var size int64
+	for _, pair := range pairs {
+		size += int64(len(pair.Key) + len(pair.Value))
+	}
+	if err := s.memTracker.Acquire(ctx, size); err != nil {
+		return err
+	}
+	s.cache = pairs
+	return nil
 }
 
 func (s *Scanner) startTS() uint64 {
@@ -193,10 +282,13 @@ func (s *Scanner) resolveCurrentLock(bo *retry.Backoffer, current *kvrpcpb.KvPai
 
 func (s *Scanner) getData(bo *retry.Backoffer) error {
 	logutil.BgLogger().Debug("txn getData",
-		zap.String("nextStartKey", kv.StrKey(s.nextStartKey)),
-		zap.String("nextEndKey", kv.StrKey(s.nextEndKey)),
+		zap.String("nextStartKey", redact.Key(s.nextStartKey)),
+		zap.String("nextEndKey", redact.Key(s.nextEndKey)),
 		zap.Bool("reverse", s.reverse),
 		zap.Uint64("txnStartTS", s.startTS()))
+	if s.fromArchive {
+		return s.getDataFromArchive(bo.GetCtx())
+	}
 	sender := locate.NewRegionRequestSender(s.snapshot.store.GetRegionCache(), s.snapshot.store.GetTiKVClient(), s.snapshot.store.GetOracle())
 	var reqEndKey, reqStartKey []byte
 	var loc *locate.KeyLocation
@@ -204,6 +296,11 @@ func (s *Scanner) getData(bo *retry.Backoffer) error {
 	var err error
 	// the states in request need to keep when retry request.
 	var readType string
+	reqLimit := s.batchSize
+	if s.limit > 0 && s.limit-s.returned < reqLimit {
+		reqLimit = s.limit - s.returned
+	}
+	busyBefore := bo.GetBackoffTimes()[retry.BoTiKVServerBusy.String()]
 	for {
 		if !s.reverse {
 			loc, err = s.snapshot.store.GetRegionCache().LocateKey(bo, s.nextStartKey)
@@ -215,6 +312,7 @@ func (s *Scanner) getData(bo *retry.Backoffer) error {
 		}
 
 		if !s.reverse {
+			s.snapshot.store.GetRegionCache().PrefetchForScan(loc, s.endKey)
 			reqEndKey = s.endKey
 			if len(reqEndKey) == 0 ||
 				(len(loc.EndKey) > 0 && bytes.Compare(loc.EndKey, reqEndKey) < 0) {
@@ -230,9 +328,9 @@ func (s *Scanner) getData(bo *retry.Backoffer) error {
 		sreq := &kvrpcpb.ScanRequest{
 			StartKey:   s.nextStartKey,
 			EndKey:     reqEndKey,
-			Limit:      uint32(s.batchSize),
+			Limit:      uint32(reqLimit),
 			Version:    s.startTS(),
-			KeyOnly:    s.snapshot.keyOnly,
+			KeyOnly:    s.keyOnly,
 			SampleStep: s.snapshot.sampleStep,
 		}
 		if s.reverse {
@@ -257,11 +355,17 @@ func (s *Scanner) getData(bo *retry.Backoffer) error {
 			req.IsRetryRequest = true
 		}
 		req.InputRequestSource = s.snapshot.GetRequestSource()
+		req.MaxExecutionDurationMs = util.MaxExecutionDurationMs(bo.GetCtx(), client.ReadTimeoutMedium)
 		if s.snapshot.mu.resourceGroupTag == nil && s.snapshot.mu.resourceGroupTagger != nil {
 			s.snapshot.mu.resourceGroupTagger(req)
 		}
 		s.snapshot.mu.RUnlock()
-		resp, _, err := sender.SendReq(bo, req, loc.Region, client.ReadTimeoutMedium)
+		et, err := s.snapshot.endpointType(loc.Region, req)
+		if err != nil {
+			return err
+		}
+		sendStart := time.Now()
+		resp, _, _, err := sender.SendReqCtx(bo, req, loc.Region, client.ReadTimeoutMedium, et)
 		if err != nil {
 			return err
 		}
@@ -290,6 +394,11 @@ func (s *Scanner) getData(bo *retry.Backoffer) error {
 		cmdScanResp := resp.Resp.(*kvrpcpb.ScanResponse)
 
 		err = s.snapshot.store.CheckVisibility(s.startTS())
+		if s.snapshot.fallbackToArchive(err) {
+			// The data may have been garbage collected, continue from the archive.
+			s.fromArchive = true
+			return s.getDataFromArchive(bo.GetCtx())
+		}
 		if err != nil {
 			return err
 		}
@@ -334,8 +443,14 @@ func (s *Scanner) getData(bo *retry.Backoffer) error {
 			}
 		}
 
-		s.cache, s.idx = kvPairs, 0
-		if len(kvPairs) < s.batchSize {
+		if err := s.setCache(bo.GetCtx(), kvPairs); err != nil {
+			return err
+		}
+		if s.adaptive != nil && reqLimit == s.batchSize {
+			busy := bo.GetBackoffTimes()[retry.BoTiKVServerBusy.String()] > busyBefore
+			s.batchSize = s.adaptive.next(s.batchSize, kvPairs, time.Since(sendStart), busy)
+		}
+		if len(kvPairs) < reqLimit {
 			// No more data in current Region. Next getData() starts
 			// from current Region's endKey.
 			if !s.reverse {
@@ -0,0 +1,93 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnsnapshot
+
+import (
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+)
+
+// defaultAdaptiveScanMinSize is the batch size the adaptive scanners start with by default.
+const defaultAdaptiveScanMinSize = 32
+
+// AdaptiveScanBatch makes the scanners adapt the number of the entries fetched per RPC, see
+// KVSnapshot.SetAdaptiveScanBatch. A scanner starts with MinSize, so the small scans return quickly, and doubles the
+// size whenever a batch is full, so the large scans take fewer round trips. The size is halved when TiKV is busy or an
+// RPC is slower than MaxLatency, and capped by MaxBytes divided by the average size of the entries seen so far.
+type AdaptiveScanBatch struct {
+	// MinSize is the size of the first batch and the lower bound of the size, 32 if it's 0.
+	MinSize int
+	// MaxSize is the upper bound of the size, the scan batch size of the snapshot if it's 0.
+	MaxSize int
+	// MaxBytes caps the estimated bytes of a batch, 0 for no limit.
+	MaxBytes int
+	// MaxLatency is the latency of an RPC above which the size is halved, 0 for no limit.
+	MaxLatency time.Duration
+}
+
+// normalize fills the defaults of the zero fields.
+func (c AdaptiveScanBatch) normalize(scanBatchSize int) AdaptiveScanBatch {
+	if c.MinSize <= 0 {
+		c.MinSize = defaultAdaptiveScanMinSize
+	}
+	// The size must be > 1. Otherwise the scanner won't skip the first key of the next batch.
+	if c.MinSize < 2 {
+		c.MinSize = 2
+	}
+	if c.MaxSize <= 0 {
+		c.MaxSize = scanBatchSize
+	}
+	if c.MaxSize < c.MinSize {
+		c.MaxSize = c.MinSize
+	}
+	return c
+}
+
+// adaptiveScanState is the state of an adaptive scanner.
+type adaptiveScanState struct {
+	cfg AdaptiveScanBatch
+	// entries and bytes are the number and the size of the entries seen so far.
+	entries int
+	bytes   int
+}
+
+// next returns the size of the next batch after a batch of size fetched pairs in cost, busy reports whether TiKV is
+// busy while fetching it.
+func (a *adaptiveScanState) next(size int, pairs []*kvrpcpb.KvPair, cost time.Duration, busy bool) int {
+	for _, pair := range pairs {
+		a.bytes += len(pair.Key) + len(pair.Value)
+	}
+	a.entries += len(pairs)
+
+	switch {
+	case busy || (a.cfg.MaxLatency > 0 && cost > a.cfg.MaxLatency):
+		size /= 2
+	case len(pairs) >= size:
+		size *= 2
+	}
+	if size > a.cfg.MaxSize {
+		size = a.cfg.MaxSize
+	}
+	if a.cfg.MaxBytes > 0 && a.bytes > 0 {
+		if limit := a.cfg.MaxBytes * a.entries / a.bytes; size > limit {
+			size = limit
+		}
+	}
+	if size < a.cfg.MinSize {
+		size = a.cfg.MinSize
+	}
+	return size
+}
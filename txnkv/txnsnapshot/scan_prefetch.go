@@ -0,0 +1,130 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnsnapshot
+
+import (
+	"context"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/tikv/client-go/v2/config/retry"
+	"github.com/tikv/client-go/v2/tikvrpc/interceptor"
+	"github.com/tikv/client-go/v2/util"
+	"github.com/tikv/client-go/v2/util/memquota"
+)
+
+// prefetchedBatch is a batch fetched in the background, along with the state
+// of the scan after it.
+type prefetchedBatch struct {
+	pairs []*kvrpcpb.KvPair
+	// mem holds the memory of pairs, it's handed over to the scanner with them.
+	mem          memquota.Tracker
+	nextStartKey []byte
+	nextEndKey   []byte
+	eof          bool
+	err          error
+}
+
+// scanPrefetcher fetches the batches of a scanner in the background, so that
+// the next batches are on the way while the caller consumes the current one,
+// see KVSnapshot.SetScanPrefetch. The fetches are done by a copy of the
+// scanner, which owns the state of fetching since the scanner is created.
+type scanPrefetcher struct {
+	batches chan prefetchedBatch
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// startScanPrefetcher starts fetching the batches of s in the background with
+// at most lookahead batches fetched ahead of the one being consumed.
+func startScanPrefetcher(s *Scanner, lookahead int) *scanPrefetcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &scanPrefetcher{
+		// The fetcher holds one more batch while it's blocked on sending.
+		batches: make(chan prefetchedBatch, lookahead-1),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	fetcher := *s
+	go p.run(ctx, &fetcher)
+	return p
+}
+
+func (p *scanPrefetcher) run(ctx context.Context, f *Scanner) {
+	defer close(p.done)
+	defer f.memTracker.ReleaseAll()
+	for {
+		b := prefetchedBatch{err: p.fetch(ctx, f)}
+		if b.err == nil {
+			b.pairs, b.mem = f.cache, f.memTracker
+			f.cache, f.memTracker = nil, memquota.Tracker{}
+			b.nextStartKey, b.nextEndKey, b.eof = f.nextStartKey, f.nextEndKey, f.eof
+		}
+		select {
+		case p.batches <- b:
+		case <-ctx.Done():
+			b.mem.ReleaseAll()
+			return
+		}
+		if b.err != nil || b.eof {
+			return
+		}
+	}
+}
+
+// fetch fetches the next batch into the cache of f, like Scanner.Next does.
+func (p *scanPrefetcher) fetch(ctx context.Context, f *Scanner) error {
+	ctx = context.WithValue(ctx, retry.TxnStartKey, f.snapshot.version)
+	ctx, cancelDeadline := util.WithDefaultDeadline(ctx, f.snapshot.deadlines.Scan)
+	defer cancelDeadline()
+	sloCtx, cancel := f.snapshot.withSLOBudget(ctx, f.start)
+	defer cancel()
+	bo := retry.NewBackofferWithVars(sloCtx, scannerNextMaxBackoff, f.snapshot.vars)
+	f.snapshot.mu.RLock()
+	if f.snapshot.mu.interceptor != nil {
+		bo.SetCtx(interceptor.WithRPCInterceptor(bo.GetCtx(), f.snapshot.mu.interceptor))
+	}
+	f.snapshot.mu.RUnlock()
+	err := f.getData(bo)
+	if sloErr, ok := f.snapshot.sloBudgetExceeded(ctx, sloCtx, f.start, err); ok {
+		return sloErr
+	}
+	return err
+}
+
+// next replaces the cached batch of s with the next batch fetched.
+func (p *scanPrefetcher) next(s *Scanner) error {
+	b := <-p.batches
+	if b.err != nil {
+		return b.err
+	}
+	s.memTracker.ReleaseAll()
+	s.cache, s.idx, s.memTracker = b.pairs, 0, b.mem
+	s.nextStartKey, s.nextEndKey, s.eof = b.nextStartKey, b.nextEndKey, b.eof
+	return nil
+}
+
+// stop stops fetching and releases the memory of the batches not consumed.
+func (p *scanPrefetcher) stop() {
+	p.cancel()
+	<-p.done
+	for {
+		select {
+		case b := <-p.batches:
+			b.mem.ReleaseAll()
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnsnapshot_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/tikv"
+	tikvtesting "github.com/tikv/client-go/v2/tikv/testing"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/tikvrpc/interceptor"
+	"github.com/tikv/client-go/v2/txnkv/txnsnapshot"
+)
+
+func TestScanPrefetch(t *testing.T) {
+	store, err := tikvtesting.NewStore()
+	require.Nil(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	var expected []string
+	for i := 0; i < 50; i++ {
+		k := fmt.Sprintf("spf%02d", i)
+		expected = append(expected, k)
+		require.Nil(t, txn.Set([]byte(k), []byte(k)))
+	}
+	require.Nil(t, txn.Commit(ctx))
+
+	var scans atomic.Int32
+	counter := interceptor.NewRPCInterceptor("scan-count", func(next interceptor.RPCInterceptorFunc) interceptor.RPCInterceptorFunc {
+		return func(target string, req *tikvrpc.Request) (*tikvrpc.Response, error) {
+			if req.Type == tikvrpc.CmdScan {
+				scans.Add(1)
+			}
+			return next(target, req)
+		}
+	})
+	newSnapshot := func() *txnsnapshot.KVSnapshot {
+		scans.Store(0)
+		snapshot := store.GetSnapshot(math.MaxUint64)
+		snapshot.SetScanBatchSize(5)
+		snapshot.SetScanPrefetch(2)
+		snapshot.SetRPCInterceptor(counter)
+		return snapshot
+	}
+	scan := func(it tikv.Iterator) []string {
+		var keys []string
+		for it.Valid() {
+			require.Equal(t, it.Key(), it.Value())
+			keys = append(keys, string(it.Key()))
+			require.Nil(t, it.Next())
+		}
+		return keys
+	}
+
+	it, err := newSnapshot().Iter([]byte("spf"), []byte("spg"))
+	require.Nil(t, err)
+	// The batch consumed and the 2 batches ahead are fetched, and no more.
+	require.Eventually(t, func() bool { return scans.Load() == 3 }, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, int32(3), scans.Load())
+	require.Equal(t, expected, scan(it))
+
+	it, err = newSnapshot().IterReverse([]byte("spg"), []byte("spf"))
+	require.Nil(t, err)
+	keys := scan(it)
+	slices.Reverse(keys)
+	require.Equal(t, expected, keys)
+
+	// Closing the scanner stops prefetching.
+	it, err = newSnapshot().Iter([]byte("spf"), []byte("spg"))
+	require.Nil(t, err)
+	require.True(t, it.Valid())
+	it.Close()
+	n := scans.Load()
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, n, scans.Load())
+}
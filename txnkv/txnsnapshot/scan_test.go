@@ -0,0 +1,52 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnsnapshot_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	tikvtesting "github.com/tikv/client-go/v2/tikv/testing"
+	"github.com/tikv/client-go/v2/util/memquota"
+)
+
+func TestScannerMemQuota(t *testing.T) {
+	store, err := tikvtesting.NewStore()
+	require.Nil(t, err)
+	defer store.Close()
+
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	value := make([]byte, 4096)
+	for i := 0; i < 10; i++ {
+		require.Nil(t, txn.Set([]byte(fmt.Sprintf("memquota%04d", i)), value))
+	}
+	require.Nil(t, txn.Commit(context.Background()))
+
+	quota := memquota.New(memquota.Config{Limit: 1 << 20, Policy: memquota.PolicyError})
+	memquota.SetGlobal(quota)
+	defer memquota.SetGlobal(nil)
+
+	// The scanner holds the memory of the cached batch until it's closed.
+	snapshot := store.GetSnapshot(math.MaxUint64)
+	it, err := snapshot.Iter([]byte("memquota"), []byte("memquotb"))
+	require.Nil(t, err)
+	require.Greater(t, quota.Used(), int64(10*len(value)))
+	it.Close()
+	require.Zero(t, quota.Used())
+}
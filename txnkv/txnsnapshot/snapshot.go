@@ -120,6 +120,15 @@ type KVSnapshot struct {
 	committedLocks  util.TSSet
 	scanBatchSize   int
 	readTimeout     time.Duration
+	sloBudget       time.Duration
+	deadlines       kv.OperationDeadlines
+	archive         ArchiveReader
+	workloadRouting kv.WorkloadRouting
+	readEngine      kv.ReadEngine
+	// adaptiveScanBatch makes the scanners adapt the batch size if it's set.
+	adaptiveScanBatch *AdaptiveScanBatch
+	// scanPrefetch is the number of the batches the scanners fetch ahead in the background.
+	scanPrefetch int
 
 	// Cache the result of Get and BatchGet.
 	// The invariance is that calling Get or BatchGet multiple times using the same start ts,
@@ -217,6 +226,27 @@ const (
 
 // BatchGetWithTier gets all the keys' value from kv-server with given tier and returns a map contains key/value pairs.
 func (s *KVSnapshot) BatchGetWithTier(ctx context.Context, keys [][]byte, readTier int) (map[string][]byte, error) {
+	return s.batchGet(ctx, keys, batchGetOptions{readTier: readTier})
+}
+
+// BatchGetWithReplicaRead gets the keys' values like BatchGet, but reads each key with the replica read type returned
+// by replicaRead instead of the one set by SetReplicaRead, so that the keys tolerating follower reads and the keys
+// needing the leaders can be read together. The keys are grouped into the requests by their regions and replica read
+// types. The keys read with kv.ReplicaReadLeader are never read as stale reads or redirected to the followers when the
+// leaders are busy. All the keys are still read at the version of the snapshot.
+func (s *KVSnapshot) BatchGetWithReplicaRead(ctx context.Context, keys [][]byte, replicaRead func(key []byte) kv.ReplicaReadType) (map[string][]byte, error) {
+	return s.batchGet(ctx, keys, batchGetOptions{readTier: BatchGetSnapshotTier, replicaRead: replicaRead})
+}
+
+// batchGetOptions is how the keys of a batch get are read.
+type batchGetOptions struct {
+	readTier int
+	// replicaRead returns the replica read type of a key, nil means reading all the keys with the one of the snapshot.
+	replicaRead func(key []byte) kv.ReplicaReadType
+}
+
+func (s *KVSnapshot) batchGet(ctx context.Context, keys [][]byte, opts batchGetOptions) (map[string][]byte, error) {
+	readTier := opts.readTier
 	// Check the cached value first.
 	m := make(map[string][]byte)
 	s.mu.RLock()
@@ -244,7 +274,12 @@ func (s *KVSnapshot) BatchGetWithTier(ctx context.Context, keys [][]byte, readTi
 	if ctx.Value(util.RequestSourceKey) == nil {
 		ctx = context.WithValue(ctx, util.RequestSourceKey, *s.RequestSource)
 	}
-	bo := retry.NewBackofferWithVars(ctx, batchGetMaxBackoff, s.vars)
+	ctx, cancelDeadline := util.WithDefaultDeadline(ctx, s.deadlines.BatchGet)
+	defer cancelDeadline()
+	start := time.Now()
+	sloCtx, cancel := s.withSLOBudget(ctx, start)
+	defer cancel()
+	bo := retry.NewBackofferWithVars(sloCtx, batchGetMaxBackoff, s.vars)
 	s.mu.RLock()
 	if s.mu.interceptor != nil {
 		// User has called snapshot.SetRPCInterceptor() to explicitly set an interceptor, we
@@ -255,7 +290,7 @@ func (s *KVSnapshot) BatchGetWithTier(ctx context.Context, keys [][]byte, readTi
 	s.mu.RUnlock()
 	// Create a map to collect key-values from region servers.
 	var mu sync.Mutex
-	err := s.batchGetKeysByRegions(bo, keys, readTier, func(k, v []byte) {
+	err := s.batchGetKeysByRegions(bo, keys, opts, func(k, v []byte) {
 		// when read buffer tier, empty value means a delete record, should also collect it.
 		if len(v) == 0 && readTier != BatchGetBufferTier {
 			return
@@ -266,11 +301,18 @@ func (s *KVSnapshot) BatchGetWithTier(ctx context.Context, keys [][]byte, readTi
 		mu.Unlock()
 	})
 	s.recordBackoffInfo(bo)
+	if sloErr, ok := s.sloBudgetExceeded(ctx, sloCtx, start, err); ok {
+		// Return the partial results, keys missing in m may either not exist or not be read yet.
+		return m, sloErr
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	err = s.store.CheckVisibility(s.version)
+	if readTier == BatchGetSnapshotTier && s.fallbackToArchive(err) {
+		return s.archiveBatchGet(ctx, keys, m)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -288,6 +330,8 @@ func (s *KVSnapshot) BatchGetWithTier(ctx context.Context, keys [][]byte, readTi
 type batchKeys struct {
 	region locate.RegionVerID
 	keys   [][]byte
+	// replicaRead is the replica read type of the keys if batchGetOptions.replicaRead is set.
+	replicaRead kv.ReplicaReadType
 }
 
 func (b *batchKeys) relocate(bo *retry.Backoffer, c *locate.RegionCache) (bool, error) {
@@ -330,7 +374,7 @@ func growStackForBatchGetWorker() {
 	runtime.KeepAlive(ballast[:])
 }
 
-func (s *KVSnapshot) batchGetKeysByRegions(bo *retry.Backoffer, keys [][]byte, readTier int, collectF func(k, v []byte)) error {
+func (s *KVSnapshot) batchGetKeysByRegions(bo *retry.Backoffer, keys [][]byte, opts batchGetOptions, collectF func(k, v []byte)) error {
 	defer func(start time.Time) {
 		if s.IsInternal() {
 			metrics.TxnCmdHistogramWithBatchGetInternal.Observe(time.Since(start).Seconds())
@@ -351,14 +395,29 @@ func (s *KVSnapshot) batchGetKeysByRegions(bo *retry.Backoffer, keys [][]byte, r
 
 	var batches []batchKeys
 	for id, g := range groups {
-		batches = appendBatchKeysBySize(batches, id, g, func([]byte) int { return 1 }, batchGetSize)
+		if opts.replicaRead == nil {
+			batches = appendBatchKeysBySize(batches, id, g, func([]byte) int { return 1 }, batchGetSize)
+			continue
+		}
+		byReplicaRead := make(map[kv.ReplicaReadType][][]byte)
+		for _, key := range g {
+			readType := opts.replicaRead(key)
+			byReplicaRead[readType] = append(byReplicaRead[readType], key)
+		}
+		for readType, keys := range byReplicaRead {
+			start := len(batches)
+			batches = appendBatchKeysBySize(batches, id, keys, func([]byte) int { return 1 }, batchGetSize)
+			for i := start; i < len(batches); i++ {
+				batches[i].replicaRead = readType
+			}
+		}
 	}
 
 	if len(batches) == 0 {
 		return nil
 	}
 	if len(batches) == 1 {
-		return s.batchGetSingleRegion(bo, batches[0], readTier, collectF)
+		return s.batchGetSingleRegion(bo, batches[0], opts, collectF)
 	}
 	ch := make(chan error, len(batches))
 	bo, cancel := bo.Fork()
@@ -373,7 +432,7 @@ func (s *KVSnapshot) batchGetKeysByRegions(bo *retry.Backoffer, keys [][]byte, r
 		batch := batch1
 		go func() {
 			growStackForBatchGetWorker()
-			ch <- s.batchGetSingleRegion(backoffer, batch, readTier, collectF)
+			ch <- s.batchGetSingleRegion(backoffer, batch, opts, collectF)
 		}()
 	}
 	for i := 0; i < len(batches); i++ {
@@ -387,7 +446,7 @@ func (s *KVSnapshot) batchGetKeysByRegions(bo *retry.Backoffer, keys [][]byte, r
 	return err
 }
 
-func (s *KVSnapshot) buildBatchGetRequest(keys [][]byte, busyThresholdMs int64, readTier int) (*tikvrpc.Request, error) {
+func (s *KVSnapshot) buildBatchGetRequest(keys [][]byte, replicaRead kv.ReplicaReadType, busyThresholdMs int64, readTier int) (*tikvrpc.Request, error) {
 	ctx := kvrpcpb.Context{
 		Priority:         s.priority.ToPB(),
 		NotFillCache:     s.notFillCache,
@@ -404,7 +463,7 @@ func (s *KVSnapshot) buildBatchGetRequest(keys [][]byte, busyThresholdMs int64,
 		req := tikvrpc.NewReplicaReadRequest(tikvrpc.CmdBatchGet, &kvrpcpb.BatchGetRequest{
 			Keys:    keys,
 			Version: s.version,
-		}, s.mu.replicaRead, &s.replicaReadSeed, ctx)
+		}, replicaRead, &s.replicaReadSeed, ctx)
 		return req, nil
 	case BatchGetBufferTier:
 		if !s.isPipelined {
@@ -413,14 +472,15 @@ func (s *KVSnapshot) buildBatchGetRequest(keys [][]byte, busyThresholdMs int64,
 		req := tikvrpc.NewReplicaReadRequest(tikvrpc.CmdBufferBatchGet, &kvrpcpb.BufferBatchGetRequest{
 			Keys:    keys,
 			Version: s.version,
-		}, s.mu.replicaRead, &s.replicaReadSeed, ctx)
+		}, replicaRead, &s.replicaReadSeed, ctx)
 		return req, nil
 	default:
 		return nil, errors.Errorf("unknown read tier %d", readTier)
 	}
 }
 
-func (s *KVSnapshot) batchGetSingleRegion(bo *retry.Backoffer, batch batchKeys, readTier int, collectF func(k, v []byte)) error {
+func (s *KVSnapshot) batchGetSingleRegion(bo *retry.Backoffer, batch batchKeys, opts batchGetOptions, collectF func(k, v []byte)) error {
+	readTier := opts.readTier
 	cli := NewClientHelper(s.store, &s.resolvedLocks, &s.committedLocks, false)
 	s.mu.RLock()
 	if s.mu.stats != nil {
@@ -432,6 +492,10 @@ func (s *KVSnapshot) batchGetSingleRegion(bo *retry.Backoffer, batch batchKeys,
 	isStaleness := s.mu.isStaleness
 	busyThresholdMs := s.mu.busyThreshold.Milliseconds()
 	s.mu.RUnlock()
+	if opts.replicaRead != nil && batch.replicaRead == kv.ReplicaReadLeader {
+		isStaleness = false
+		busyThresholdMs = 0
+	}
 
 	pending := batch.keys
 	var resolvingRecordToken *int
@@ -440,7 +504,11 @@ func (s *KVSnapshot) batchGetSingleRegion(bo *retry.Backoffer, batch batchKeys,
 	var readType string
 	for {
 		s.mu.RLock()
-		req, err := s.buildBatchGetRequest(pending, busyThresholdMs, readTier)
+		replicaRead := s.mu.replicaRead
+		if opts.replicaRead != nil {
+			replicaRead = batch.replicaRead
+		}
+		req, err := s.buildBatchGetRequest(pending, replicaRead, busyThresholdMs, readTier)
 		if err != nil {
 			s.mu.RUnlock()
 			return err
@@ -467,7 +535,7 @@ func (s *KVSnapshot) batchGetSingleRegion(bo *retry.Backoffer, batch batchKeys,
 			useConfigurableKVTimeout = false
 			timeout = s.readTimeout
 		}
-		req.MaxExecutionDurationMs = uint64(timeout.Milliseconds())
+		req.MaxExecutionDurationMs = util.MaxExecutionDurationMs(bo.GetCtx(), timeout)
 		ops := make([]locate.StoreSelectorOption, 0, 2)
 		if len(matchStoreLabels) > 0 {
 			ops = append(ops, locate.WithMatchLabels(matchStoreLabels))
@@ -479,7 +547,11 @@ func (s *KVSnapshot) batchGetSingleRegion(bo *retry.Backoffer, batch batchKeys,
 			}
 			req.ReplicaReadType = readType
 		}
-		resp, _, _, err := cli.SendReqCtx(bo, req, batch.region, timeout, tikvrpc.TiKV, "", ops...)
+		et, err := s.endpointType(batch.region, req)
+		if err != nil {
+			return err
+		}
+		resp, _, _, err := cli.SendReqCtx(bo, req, batch.region, timeout, et, "", ops...)
 		if err != nil {
 			return err
 		}
@@ -505,7 +577,7 @@ func (s *KVSnapshot) batchGetSingleRegion(bo *retry.Backoffer, batch batchKeys,
 			if same {
 				continue
 			}
-			return s.batchGetKeysByRegions(bo, pending, readTier, collectF)
+			return s.batchGetKeysByRegions(bo, pending, opts, collectF)
 		}
 		if resp.Resp == nil {
 			return errors.WithStack(tikverr.ErrBodyMissing)
@@ -589,7 +661,9 @@ func (s *KVSnapshot) batchGetSingleRegion(bo *retry.Backoffer, batch batchKeys,
 				return err
 			}
 			if msBeforeExpired > 0 {
+				backoffStart := time.Now()
 				err = bo.BackoffWithMaxSleepTxnLockFast(int(msBeforeExpired), errors.Errorf("BatchGetWithTier lockedKeys: %d", len(lockedKeys)))
+				s.GetResolveLockDetail().RecordBackoff(time.Since(backoffStart))
 				if err != nil {
 					return err
 				}
@@ -609,6 +683,24 @@ const getMaxBackoff = 20000
 
 // Get gets the value for key k from snapshot.
 func (s *KVSnapshot) Get(ctx context.Context, k []byte) ([]byte, error) {
+	return s.getValue(ctx, k, true)
+}
+
+// GetInto gets the value of the key like Get, but copies the value to buf[:0]
+// and returns the result. It's not zero-copy: the value is decoded from the
+// response first and then copied, so it saves no allocation of the read
+// itself. What it saves is the retention of the value: unlike Get, the value
+// isn't kept in the snapshot cache, so the decoded value can be collected
+// right after the call while the caller keeps only its own buffer.
+func (s *KVSnapshot) GetInto(ctx context.Context, k []byte, buf []byte) ([]byte, error) {
+	val, err := s.getValue(ctx, k, false)
+	if err != nil {
+		return buf[:0], err
+	}
+	return append(buf[:0], val...), nil
+}
+
+func (s *KVSnapshot) getValue(ctx context.Context, k []byte, fillCache bool) ([]byte, error) {
 	defer func(start time.Time) {
 		if s.IsInternal() {
 			metrics.TxnCmdHistogramWithGetInternal.Observe(time.Since(start).Seconds())
@@ -639,7 +731,12 @@ func (s *KVSnapshot) Get(ctx context.Context, k []byte) ([]byte, error) {
 	if ctx.Value(util.RequestSourceKey) == nil {
 		ctx = context.WithValue(ctx, util.RequestSourceKey, *s.RequestSource)
 	}
-	bo := retry.NewBackofferWithVars(ctx, getMaxBackoff, s.vars)
+	ctx, cancelDeadline := util.WithDefaultDeadline(ctx, s.deadlines.Get)
+	defer cancelDeadline()
+	start := time.Now()
+	sloCtx, cancel := s.withSLOBudget(ctx, start)
+	defer cancel()
+	bo := retry.NewBackofferWithVars(sloCtx, getMaxBackoff, s.vars)
 	if s.mu.interceptor != nil {
 		// User has called snapshot.SetRPCInterceptor() to explicitly set an interceptor, we
 		// need to bind it to ctx so that the internal client can perceive and execute
@@ -647,17 +744,25 @@ func (s *KVSnapshot) Get(ctx context.Context, k []byte) ([]byte, error) {
 		bo.SetCtx(interceptor.WithRPCInterceptor(bo.GetCtx(), s.mu.interceptor))
 	}
 	s.mu.RUnlock()
-	val, err := s.get(ctx, bo, k)
+	val, err := s.get(sloCtx, bo, k)
 	s.recordBackoffInfo(bo)
+	if sloErr, ok := s.sloBudgetExceeded(ctx, sloCtx, start, err); ok {
+		return nil, sloErr
+	}
 	if err != nil {
 		return nil, err
 	}
 	err = s.store.CheckVisibility(s.version)
+	if s.fallbackToArchive(err) {
+		return s.archiveGet(ctx, k)
+	}
 	if err != nil {
 		return nil, err
 	}
 	// Update the cache.
-	s.UpdateSnapshotCache([][]byte{k}, map[string][]byte{string(k): val})
+	if fillCache {
+		s.UpdateSnapshotCache([][]byte{k}, map[string][]byte{string(k): val})
+	}
 	if len(val) == 0 {
 		return nil, tikverr.ErrNotExist
 	}
@@ -734,8 +839,12 @@ func (s *KVSnapshot) get(ctx context.Context, bo *retry.Backoffer, k []byte) ([]
 			useConfigurableKVTimeout = false
 			timeout = s.readTimeout
 		}
-		req.MaxExecutionDurationMs = uint64(timeout.Milliseconds())
-		resp, _, _, err := cli.SendReqCtx(bo, req, loc.Region, timeout, tikvrpc.TiKV, "", ops...)
+		req.MaxExecutionDurationMs = util.MaxExecutionDurationMs(bo.GetCtx(), timeout)
+		et, err := s.endpointType(loc.Region, req)
+		if err != nil {
+			return nil, err
+		}
+		resp, _, _, err := cli.SendReqCtx(bo, req, loc.Region, timeout, et, "", ops...)
 		if err != nil {
 			return nil, err
 		}
@@ -810,7 +919,9 @@ func (s *KVSnapshot) get(ctx context.Context, bo *retry.Backoffer, k []byte) ([]
 			}
 			msBeforeExpired := resolveLocksRes.TTL
 			if msBeforeExpired > 0 {
+				backoffStart := time.Now()
 				err = bo.BackoffWithMaxSleepTxnLockFast(int(msBeforeExpired), errors.New(keyErr.String()))
+				s.GetResolveLockDetail().RecordBackoff(time.Since(backoffStart))
 				if err != nil {
 					return nil, err
 				}
@@ -843,6 +954,21 @@ func (s *KVSnapshot) IterReverse(k, lowerBound []byte) (unionstore.Iterator, err
 	return scanner, err
 }
 
+// IterWithLimit is like Iter, but the iterator returns at most limit entries,
+// and no more are fetched from TiKV. A non-positive limit means unlimited.
+func (s *KVSnapshot) IterWithLimit(k []byte, upperBound []byte, limit int) (unionstore.Iterator, error) {
+	scanner, err := newScannerWithLimit(s, k, upperBound, s.scanBatchSize, false, s.keyOnly, limit)
+	return scanner, err
+}
+
+// IterReverseWithLimit is like IterReverse, but the iterator returns at most
+// limit entries, and no more are fetched from TiKV. If keyOnly is set, TiKV
+// returns the keys without the values. A non-positive limit means unlimited.
+func (s *KVSnapshot) IterReverseWithLimit(k, lowerBound []byte, limit int, keyOnly bool) (unionstore.Iterator, error) {
+	scanner, err := newScannerWithLimit(s, lowerBound, k, s.scanBatchSize, true, keyOnly, limit)
+	return scanner, err
+}
+
 // SetNotFillCache indicates whether tikv should skip filling cache when
 // loading data.
 func (s *KVSnapshot) SetNotFillCache(b bool) {
@@ -859,6 +985,20 @@ func (s *KVSnapshot) SetScanBatchSize(batchSize int) {
 	s.scanBatchSize = batchSize
 }
 
+// SetAdaptiveScanBatch makes the scanners of the snapshot adapt the number of the entries fetched per RPC to the entries
+// and the latency observed, within the bounds of cfg, instead of fetching the scan batch size every time. Pass nil to
+// disable it.
+func (s *KVSnapshot) SetAdaptiveScanBatch(cfg *AdaptiveScanBatch) {
+	s.adaptiveScanBatch = cfg
+}
+
+// SetScanPrefetch makes the scanners of the snapshot fetch the next batches in the background while the caller consumes
+// the current one, with at most lookahead batches fetched ahead, so the large sequential scans don't wait for a round
+// trip per batch. The scanners with limits don't prefetch. Zero disables it.
+func (s *KVSnapshot) SetScanPrefetch(lookahead int) {
+	s.scanPrefetch = lookahead
+}
+
 // SetReplicaRead sets up the replica read type.
 func (s *KVSnapshot) SetReplicaRead(readType kv.ReplicaReadType) {
 	s.mu.Lock()
@@ -866,6 +1006,50 @@ func (s *KVSnapshot) SetReplicaRead(readType kv.ReplicaReadType) {
 	s.mu.replicaRead = readType
 }
 
+// SetWorkloadRouting sets the routing used by SetWorkloadClass. The default
+// routing is used if it's not set.
+func (s *KVSnapshot) SetWorkloadRouting(routing kv.WorkloadRouting) {
+	s.workloadRouting = routing
+}
+
+// SetWorkloadClass sets up the replica read type by the workload class of the
+// reads, see kv.WorkloadRouting.
+func (s *KVSnapshot) SetWorkloadClass(class kv.WorkloadClass) {
+	s.SetReplicaRead(s.workloadRouting.ReplicaRead(class))
+}
+
+// SetReadEngine restricts the replicas the reads are sent to to the ones of the
+// storage engine, see kv.ReadEngine.
+func (s *KVSnapshot) SetReadEngine(engine kv.ReadEngine) {
+	s.readEngine = engine
+}
+
+// endpointType returns the type of the stores to send the read of the region
+// to by the read engine. The reads sent to TiFlash are marked as replica reads,
+// since the TiFlash replicas are learners.
+func (s *KVSnapshot) endpointType(region locate.RegionVerID, req *tikvrpc.Request) (tikvrpc.EndpointType, error) {
+	if s.readEngine == kv.EngineTiKV {
+		return tikvrpc.TiKV, nil
+	}
+	has, ok := s.store.GetRegionCache().HasTiFlashPeer(region)
+	if !ok {
+		// The region is stale, the request fails with a region error and is retried with the reloaded regions.
+		return tikvrpc.TiKV, nil
+	}
+	if !has {
+		if s.readEngine == kv.EngineAny {
+			return tikvrpc.TiKV, nil
+		}
+		return tikvrpc.TiKV, errors.WithStack(&tikverr.ErrNoEngineReplica{
+			Engine:   s.readEngine.String(),
+			RegionID: region.GetID(),
+		})
+	}
+	req.ReplicaRead = true
+	req.StaleRead = false
+	return tikvrpc.TiFlash, nil
+}
+
 // SetIsolationLevel sets the isolation level used to scan data from tikv.
 func (s *KVSnapshot) SetIsolationLevel(level IsoLevel) {
 	s.isolationLevel = level
@@ -1109,6 +1293,51 @@ func (s *KVSnapshot) GetKVReadTimeout() time.Duration {
 	return s.readTimeout
 }
 
+// SetReadSLOBudget sets the latency budget for reads under this snapshot. A read
+// that can't finish within the budget stops retrying and returns
+// *tikverr.ErrSLOBudgetExceeded along with the results collected so far. Zero
+// means no budget.
+func (s *KVSnapshot) SetReadSLOBudget(budget time.Duration) {
+	s.sloBudget = budget
+}
+
+// GetReadSLOBudget returns the latency budget for reads under this snapshot or 0 if it is not set.
+func (s *KVSnapshot) GetReadSLOBudget() time.Duration {
+	return s.sloBudget
+}
+
+// SetOperationDeadlines sets the default deadlines of the reads under this snapshot, which are applied if the contexts
+// of the reads have no deadlines. See kv.OperationDeadlines.
+func (s *KVSnapshot) SetOperationDeadlines(deadlines kv.OperationDeadlines) {
+	s.deadlines = deadlines
+}
+
+// GetOperationDeadlines returns the default deadlines of the reads under this snapshot.
+func (s *KVSnapshot) GetOperationDeadlines() kv.OperationDeadlines {
+	return s.deadlines
+}
+
+// withSLOBudget derives a context which is done when the read started at
+// start exceeds the latency budget.
+func (s *KVSnapshot) withSLOBudget(ctx context.Context, start time.Time) (context.Context, context.CancelFunc) {
+	if s.sloBudget <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithDeadline(ctx, start.Add(s.sloBudget))
+}
+
+// sloBudgetExceeded checks whether err is caused by exceeding the latency
+// budget, i.e. sloCtx is expired while the parent ctx is still alive.
+func (s *KVSnapshot) sloBudgetExceeded(ctx, sloCtx context.Context, start time.Time, err error) (*tikverr.ErrSLOBudgetExceeded, bool) {
+	if s.sloBudget <= 0 || err == nil || ctx.Err() != nil || sloCtx.Err() != context.DeadlineExceeded {
+		return nil, false
+	}
+	return &tikverr.ErrSLOBudgetExceeded{
+		Budget:  s.sloBudget,
+		Elapsed: time.Since(start),
+	}, true
+}
+
 // GetResolveLockDetail returns ResolveLockDetail, exports for testing.
 func (s *KVSnapshot) GetResolveLockDetail() *util.ResolveLockDetail {
 	s.mu.RLock()
@@ -1143,10 +1372,10 @@ type SnapshotRuntimeStats struct {
 // Clone implements the RuntimeStats interface.
 func (rs *SnapshotRuntimeStats) Clone() *SnapshotRuntimeStats {
 	newRs := SnapshotRuntimeStats{
-		scanDetail:        rs.scanDetail,
-		timeDetail:        rs.timeDetail,
-		resolveLockDetail: rs.resolveLockDetail,
+		scanDetail: rs.scanDetail,
+		timeDetail: rs.timeDetail,
 	}
+	newRs.resolveLockDetail.Merge(&rs.resolveLockDetail)
 	if rs.rpcStats != nil {
 		newRs.rpcStats = rs.rpcStats.Clone()
 	}
@@ -1219,6 +1448,11 @@ func (rs *SnapshotRuntimeStats) String() string {
 		buf.WriteString("resolve_lock_time:")
 		buf.WriteString(util.FormatDuration(time.Duration(rs.resolveLockDetail.ResolveLockTime)))
 	}
+	resolveLockDetail := rs.resolveLockDetail.String()
+	if resolveLockDetail != "" {
+		buf.WriteString(", ")
+		buf.WriteString(resolveLockDetail)
+	}
 	scanDetail := rs.scanDetail.String()
 	if scanDetail != "" {
 		buf.WriteString(", ")
@@ -49,7 +49,7 @@ func (s SnapshotProbe) FormatStats() string {
 
 // BatchGetSingleRegion gets a batch of keys from a region.
 func (s SnapshotProbe) BatchGetSingleRegion(bo *retry.Backoffer, region locate.RegionVerID, keys [][]byte, collectF func(k, v []byte)) error {
-	return s.batchGetSingleRegion(bo, batchKeys{region: region, keys: keys}, BatchGetSnapshotTier, collectF)
+	return s.batchGetSingleRegion(bo, batchKeys{region: region, keys: keys}, batchGetOptions{readTier: BatchGetSnapshotTier}, collectF)
 }
 
 // NewScanner returns a scanner to iterate given key range.
@@ -0,0 +1,458 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/internal/unionstore"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/oracle"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultRangeLockTTL is the default TTL of the range locks, see
+	// WithRangeLockTTL.
+	DefaultRangeLockTTL = 10 * time.Second
+	// DefaultRangeLockRetryInterval is the default interval of retrying to
+	// acquire a locked range, see WithRangeLockRetryInterval.
+	DefaultRangeLockRetryInterval = 100 * time.Millisecond
+
+	rangeLockHeadTag   = 'h'
+	rangeLockRecordTag = 'r'
+)
+
+// ErrRangeLocked is returned by RangeLocks.TryLock if the range overlaps a
+// range locked by others.
+var ErrRangeLocked = errors.New("range is locked")
+
+// RangeLockTxn is the transaction in which RangeLocks reads and writes the
+// lock records. It's implemented by *transaction.KVTxn.
+type RangeLockTxn interface {
+	Get(ctx context.Context, k []byte) ([]byte, error)
+	Set(k []byte, v []byte) error
+	Delete(k []byte) error
+	IterReverse(k, lowerBound []byte) (unionstore.Iterator, error)
+	Iter(k []byte, upperBound []byte) (unionstore.Iterator, error)
+	StartTS() uint64
+	Commit(ctx context.Context) error
+	Rollback() error
+}
+
+// RangeLockOpt is the option of NewRangeLocks.
+type RangeLockOpt func(*RangeLocks)
+
+// WithRangeLockTTL sets the TTL of the range locks. A lock expires if it's not
+// renewed by its heartbeats in the TTL, e.g. when its owner crashes, and then
+// it's cleaned up by the others acquiring the overlapping ranges.
+func WithRangeLockTTL(ttl time.Duration) RangeLockOpt {
+	return func(l *RangeLocks) {
+		l.ttl = ttl
+	}
+}
+
+// WithRangeLockRetryInterval sets the interval of retrying to acquire a
+// locked range by RangeLocks.Lock.
+func WithRangeLockRetryInterval(interval time.Duration) RangeLockOpt {
+	return func(l *RangeLocks) {
+		l.retryInterval = interval
+	}
+}
+
+// RangeLocks is an advisory lock manager of the key ranges, for the
+// coordination above client-go, e.g. to make sure a range is processed by one
+// worker at a time. The locks are only respected by the RangeLocks sharing the
+// same prefix, they don't block the reads and writes of the data in the ranges.
+//
+// A lock of [StartKey, EndKey) is persisted as a record on its start boundary
+// key under the prefix, carrying its end boundary key, owner and expiration,
+// and the records of the locks held are never overlapped. A lock is kept alive
+// by the heartbeats, and the expired records are cleaned up by the ones
+// acquiring the overlapping ranges.
+type RangeLocks struct {
+	begin         func() (RangeLockTxn, error)
+	prefix        []byte
+	owner         string
+	ttl           time.Duration
+	retryInterval time.Duration
+}
+
+// NewRangeLocks creates a RangeLocks persisting the locks under the prefix,
+// whose transactions are begun by begin. The owner identifies the locks
+// acquired by it, which is shown in the errors of the others.
+func NewRangeLocks(begin func() (RangeLockTxn, error), prefix []byte, owner string, opts ...RangeLockOpt) *RangeLocks {
+	l := &RangeLocks{
+		begin:         begin,
+		prefix:        prefix,
+		owner:         owner,
+		ttl:           DefaultRangeLockTTL,
+		retryInterval: DefaultRangeLockRetryInterval,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// rangeLockRecord is the value of the record of a lock.
+type rangeLockRecord struct {
+	EndKey []byte `json:"end_key"`
+	Owner  string `json:"owner"`
+	// LockTS identifies the acquisition of the lock, it's the start ts of the
+	// transaction acquiring it.
+	LockTS uint64 `json:"lock_ts"`
+	// ExpireAt is the physical time in milliseconds the lock expires at, which
+	// is compared with the start ts of the transactions, so it doesn't depend on
+	// the local clocks.
+	ExpireAt int64 `json:"expire_at"`
+
+	startKey []byte
+}
+
+func (r *rangeLockRecord) overlaps(startKey, endKey []byte) bool {
+	return (len(endKey) == 0 || bytes.Compare(r.startKey, endKey) < 0) &&
+		(len(r.EndKey) == 0 || bytes.Compare(startKey, r.EndKey) < 0)
+}
+
+func (l *RangeLocks) headKey() []byte {
+	return append(append([]byte(nil), l.prefix...), rangeLockHeadTag)
+}
+
+func (l *RangeLocks) recordKey(startKey []byte) []byte {
+	k := make([]byte, 0, len(l.prefix)+1+len(startKey))
+	k = append(k, l.prefix...)
+	k = append(k, rangeLockRecordTag)
+	return append(k, startKey...)
+}
+
+// RangeLock is a lock of a key range acquired by RangeLocks.
+type RangeLock struct {
+	StartKey []byte
+	EndKey   []byte
+
+	locks  *RangeLocks
+	lockTS uint64
+	cancel context.CancelFunc
+	done   chan struct{}
+	lost   chan struct{}
+
+	mu       sync.Mutex
+	unlocked bool
+}
+
+// Lost returns a channel closed when the lock is found taken by others, or it
+// can't be renewed before its expiration, e.g. when the cluster is
+// unavailable. The work protected by the lock should be stopped then.
+func (lk *RangeLock) Lost() <-chan struct{} {
+	return lk.lost
+}
+
+// TryLock acquires the lock of [startKey, endKey), an empty endKey means no
+// upper bound. It returns ErrRangeLocked if the range overlaps a range locked
+// by others. The lock is renewed by the heartbeats until it's unlocked.
+func (l *RangeLocks) TryLock(ctx context.Context, startKey, endKey []byte) (*RangeLock, error) {
+	if len(endKey) > 0 && bytes.Compare(startKey, endKey) >= 0 {
+		return nil, errors.Errorf("invalid range [%q, %q)", startKey, endKey)
+	}
+	for {
+		lockTS, err := l.tryLock(ctx, startKey, endKey)
+		if err == nil {
+			return l.newRangeLock(startKey, endKey, lockTS), nil
+		}
+		// The write conflicts are caused by the concurrent acquisitions of the
+		// overlapping ranges or the heartbeats, so retry to see if the range is
+		// locked.
+		if !tikverr.IsErrWriteConflict(err) {
+			return nil, err
+		}
+		if err := l.sleep(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Lock acquires the lock of [startKey, endKey) like TryLock, and it waits for
+// the overlapping locks to be unlocked or expired until ctx is done.
+func (l *RangeLocks) Lock(ctx context.Context, startKey, endKey []byte) (*RangeLock, error) {
+	for {
+		lk, err := l.TryLock(ctx, startKey, endKey)
+		if !errors.Is(err, ErrRangeLocked) {
+			return lk, err
+		}
+		if err := l.sleep(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (l *RangeLocks) sleep(ctx context.Context) error {
+	select {
+	case <-time.After(l.retryInterval):
+		return nil
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	}
+}
+
+// tryLock writes the record of the lock in a transaction, and returns the
+// start ts of the transaction.
+//
+// The transaction also writes the record before the new one again, or the
+// head key if there's none, so the concurrent acquisitions of the overlapping
+// ranges conflict with each other: either they write the same record before
+// them, or one of them meets the record written before the other.
+func (l *RangeLocks) tryLock(ctx context.Context, startKey, endKey []byte) (uint64, error) {
+	txn, err := l.begin()
+	if err != nil {
+		return 0, err
+	}
+	defer txn.Rollback()
+	now := oracle.ExtractPhysical(txn.StartTS())
+
+	recordUpper := kv.PrefixNextKey(l.recordKey(nil))
+	if len(endKey) > 0 {
+		recordUpper = l.recordKey(endKey)
+	}
+	var overlapped []*rangeLockRecord
+
+	// The record before the start key is the only one before it which may
+	// overlap the range, since the records are never overlapped.
+	prevKey, prevValue := l.headKey(), []byte{'0'}
+	it, err := txn.IterReverse(l.recordKey(startKey), l.recordKey(nil))
+	if err != nil {
+		return 0, err
+	}
+	if it.Valid() {
+		prevKey, prevValue = append([]byte(nil), it.Key()...), append([]byte(nil), it.Value()...)
+		record, err := l.decodeRecord(prevKey, prevValue)
+		if err != nil {
+			it.Close()
+			return 0, err
+		}
+		if record.overlaps(startKey, endKey) {
+			overlapped = append(overlapped, record)
+		}
+	}
+	it.Close()
+
+	it, err = txn.Iter(l.recordKey(startKey), recordUpper)
+	if err != nil {
+		return 0, err
+	}
+	for it.Valid() {
+		record, err := l.decodeRecord(it.Key(), it.Value())
+		if err != nil {
+			it.Close()
+			return 0, err
+		}
+		overlapped = append(overlapped, record)
+		if err := it.Next(); err != nil {
+			it.Close()
+			return 0, err
+		}
+	}
+	it.Close()
+
+	for _, record := range overlapped {
+		if record.ExpireAt > now {
+			return 0, errors.Wrapf(ErrRangeLocked, "[%q, %q) is locked by %s", record.startKey, record.EndKey, record.Owner)
+		}
+		logutil.Logger(ctx).Info("clean up expired range lock",
+			zap.String("owner", record.Owner),
+			zap.Uint64("lockTS", record.LockTS))
+		if err := txn.Delete(l.recordKey(record.startKey)); err != nil {
+			return 0, err
+		}
+	}
+	// The record before is rewritten only if it's not deleted or replaced.
+	if len(overlapped) == 0 || bytes.Compare(overlapped[0].startKey, startKey) > 0 {
+		if err := txn.Set(prevKey, prevValue); err != nil {
+			return 0, err
+		}
+	}
+
+	record := rangeLockRecord{
+		EndKey:   endKey,
+		Owner:    l.owner,
+		LockTS:   txn.StartTS(),
+		ExpireAt: now + l.ttl.Milliseconds(),
+	}
+	value, err := json.Marshal(&record)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if err := txn.Set(l.recordKey(startKey), value); err != nil {
+		return 0, err
+	}
+	if err := txn.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return record.LockTS, nil
+}
+
+func (l *RangeLocks) decodeRecord(key, value []byte) (*rangeLockRecord, error) {
+	record := &rangeLockRecord{}
+	if err := json.Unmarshal(value, record); err != nil {
+		return nil, errors.Wrapf(err, "decode range lock record %q", key)
+	}
+	record.startKey = append([]byte(nil), key[len(l.recordKey(nil)):]...)
+	return record, nil
+}
+
+func (l *RangeLocks) newRangeLock(startKey, endKey []byte, lockTS uint64) *RangeLock {
+	ctx, cancel := context.WithCancel(context.Background())
+	lk := &RangeLock{
+		StartKey: startKey,
+		EndKey:   endKey,
+		locks:    l,
+		lockTS:   lockTS,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		lost:     make(chan struct{}),
+	}
+	go lk.keepAlive(ctx)
+	return lk
+}
+
+// keepAlive renews the lock every third of the TTL until it's unlocked or
+// lost.
+func (lk *RangeLock) keepAlive(ctx context.Context) {
+	defer close(lk.done)
+	ttl := lk.locks.ttl
+	deadline := time.Now().Add(ttl)
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		start := time.Now()
+		held, err := lk.renew(ctx)
+		if err == nil && !held {
+			logutil.Logger(ctx).Warn("range lock is taken by others",
+				zap.String("owner", lk.locks.owner),
+				zap.Uint64("lockTS", lk.lockTS))
+			close(lk.lost)
+			return
+		}
+		if err == nil {
+			deadline = start.Add(ttl)
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		logutil.Logger(ctx).Warn("failed to renew range lock",
+			zap.String("owner", lk.locks.owner),
+			zap.Uint64("lockTS", lk.lockTS),
+			zap.Error(err))
+		if time.Now().After(deadline) {
+			close(lk.lost)
+			return
+		}
+	}
+}
+
+// renew extends the expiration of the record of the lock, it returns false if
+// the record is not of the lock anymore.
+func (lk *RangeLock) renew(ctx context.Context) (bool, error) {
+	txn, err := lk.locks.begin()
+	if err != nil {
+		return false, err
+	}
+	defer txn.Rollback()
+	record, err := lk.getRecord(ctx, txn)
+	if err != nil || record == nil {
+		return false, err
+	}
+	record.ExpireAt = oracle.ExtractPhysical(txn.StartTS()) + lk.locks.ttl.Milliseconds()
+	value, err := json.Marshal(record)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	if err := txn.Set(lk.locks.recordKey(lk.StartKey), value); err != nil {
+		return false, err
+	}
+	return true, txn.Commit(ctx)
+}
+
+// getRecord returns the record of the lock, or nil if the record is deleted or
+// replaced.
+func (lk *RangeLock) getRecord(ctx context.Context, txn RangeLockTxn) (*rangeLockRecord, error) {
+	key := lk.locks.recordKey(lk.StartKey)
+	value, err := txn.Get(ctx, key)
+	if tikverr.IsErrNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	record, err := lk.locks.decodeRecord(key, value)
+	if err != nil || record.LockTS != lk.lockTS {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Unlock stops the heartbeats and deletes the record of the lock. Unlocking a
+// lock unlocked or lost does nothing.
+func (lk *RangeLock) Unlock(ctx context.Context) error {
+	lk.mu.Lock()
+	defer lk.mu.Unlock()
+	if lk.unlocked {
+		return nil
+	}
+	lk.cancel()
+	<-lk.done
+	for {
+		err := lk.unlock(ctx)
+		if err == nil {
+			lk.unlocked = true
+			return nil
+		}
+		if !tikverr.IsErrWriteConflict(err) {
+			return err
+		}
+		if err := lk.locks.sleep(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+func (lk *RangeLock) unlock(ctx context.Context) error {
+	txn, err := lk.locks.begin()
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+	record, err := lk.getRecord(ctx, txn)
+	if err != nil || record == nil {
+		return err
+	}
+	if err := txn.Delete(lk.locks.recordKey(lk.StartKey)); err != nil {
+		return err
+	}
+	return txn.Commit(ctx)
+}
@@ -0,0 +1,146 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnutil_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	tikvtesting "github.com/tikv/client-go/v2/tikv/testing"
+	"github.com/tikv/client-go/v2/txnkv/txnutil"
+)
+
+func TestRangeLocks(t *testing.T) {
+	store, err := tikvtesting.NewStore()
+	require.Nil(t, err)
+	defer store.Close()
+	ctx := context.Background()
+	begin := func() (txnutil.RangeLockTxn, error) { return store.Begin() }
+	prefix := []byte("range_locks_")
+	a := txnutil.NewRangeLocks(begin, prefix, "a", txnutil.WithRangeLockRetryInterval(10*time.Millisecond))
+	b := txnutil.NewRangeLocks(begin, prefix, "b", txnutil.WithRangeLockRetryInterval(10*time.Millisecond))
+
+	bd, err := a.TryLock(ctx, []byte("b"), []byte("d"))
+	require.Nil(t, err)
+	_, err = b.TryLock(ctx, []byte("c"), []byte("e"))
+	require.ErrorIs(t, err, txnutil.ErrRangeLocked)
+	require.Contains(t, err.Error(), "locked by a")
+	_, err = b.TryLock(ctx, []byte("a"), nil)
+	require.ErrorIs(t, err, txnutil.ErrRangeLocked)
+	_, err = b.TryLock(ctx, []byte("a"), []byte("c"))
+	require.ErrorIs(t, err, txnutil.ErrRangeLocked)
+	ab, err := b.TryLock(ctx, []byte("a"), []byte("b"))
+	require.Nil(t, err)
+	df, err := b.TryLock(ctx, []byte("d"), []byte("f"))
+	require.Nil(t, err)
+
+	// Lock waits for the overlapping lock to be unlocked.
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	_, err = b.Lock(waitCtx, []byte("c"), []byte("d"))
+	cancel()
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		require.Nil(t, bd.Unlock(ctx))
+	}()
+	cd, err := b.Lock(ctx, []byte("c"), []byte("d"))
+	require.Nil(t, err)
+	for _, lk := range []*txnutil.RangeLock{ab, cd, df} {
+		require.Nil(t, lk.Unlock(ctx))
+		require.Nil(t, lk.Unlock(ctx))
+	}
+}
+
+func TestRangeLockExpire(t *testing.T) {
+	store, err := tikvtesting.NewStore()
+	require.Nil(t, err)
+	defer store.Close()
+	ctx := context.Background()
+	prefix := []byte("range_locks_")
+
+	// The heartbeats of the crashed owner fail, so its lock expires and is
+	// cleaned up by the others.
+	var crashed atomic.Bool
+	a := txnutil.NewRangeLocks(func() (txnutil.RangeLockTxn, error) {
+		if crashed.Load() {
+			return nil, errors.New("crashed")
+		}
+		return store.Begin()
+	}, prefix, "a", txnutil.WithRangeLockTTL(300*time.Millisecond))
+	b := txnutil.NewRangeLocks(func() (txnutil.RangeLockTxn, error) { return store.Begin() }, prefix, "b",
+		txnutil.WithRangeLockTTL(300*time.Millisecond), txnutil.WithRangeLockRetryInterval(20*time.Millisecond))
+
+	lk, err := a.TryLock(ctx, []byte("a"), []byte("z"))
+	require.Nil(t, err)
+	// The lock is kept alive by the heartbeats beyond its TTL.
+	time.Sleep(500 * time.Millisecond)
+	_, err = b.TryLock(ctx, []byte("m"), []byte("n"))
+	require.ErrorIs(t, err, txnutil.ErrRangeLocked)
+
+	crashed.Store(true)
+	select {
+	case <-lk.Lost():
+	case <-time.After(time.Second):
+		require.FailNow(t, "the lock is not lost")
+	}
+	mn, err := b.Lock(ctx, []byte("m"), []byte("n"))
+	require.Nil(t, err)
+	require.Nil(t, mn.Unlock(ctx))
+}
+
+func TestRangeLocksExclusive(t *testing.T) {
+	store, err := tikvtesting.NewStore()
+	require.Nil(t, err)
+	defer store.Close()
+	ctx := context.Background()
+	begin := func() (txnutil.RangeLockTxn, error) { return store.Begin() }
+
+	// The workers lock the overlapping ranges concurrently, and every key is
+	// covered by one lock held at most.
+	ranges := [][2]string{{"a", "c"}, {"b", "d"}, {"c", "e"}, {"a", ""}}
+	var owners [5]atomic.Int32
+	cover := func(r [2]string, from, to int32) {
+		end := 'a' + byte(len(owners))
+		if r[1] != "" {
+			end = r[1][0]
+		}
+		for k := r[0][0]; k < end; k++ {
+			require.True(t, owners[k-'a'].CompareAndSwap(from, to), "key %c is locked twice", k)
+		}
+	}
+	var wg sync.WaitGroup
+	for i, r := range ranges {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l := txnutil.NewRangeLocks(begin, []byte("range_locks_"), string(rune('a'+i)),
+				txnutil.WithRangeLockRetryInterval(time.Millisecond))
+			for j := 0; j < 10; j++ {
+				lk, err := l.Lock(ctx, []byte(r[0]), []byte(r[1]))
+				require.Nil(t, err)
+				cover(r, 0, int32(i+1))
+				time.Sleep(time.Millisecond)
+				cover(r, int32(i+1), 0)
+				require.Nil(t, lk.Unlock(ctx))
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,45 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func FuzzEncodeDecodeBytes(f *testing.F) {
+	f.Add([]byte{}, []byte{0})
+	f.Add([]byte{1, 2, 3}, []byte{1, 2, 3, 0})
+	f.Add([]byte{1, 2, 3, 4, 5, 6, 7, 8, 255, 0, 0, 0, 0, 0, 0, 0, 0, 247}, []byte{1, 2, 3, 4, 5, 6, 7, 8})
+	f.Fuzz(func(t *testing.T, a, b []byte) {
+		encoded := EncodeBytes(nil, a)
+		rest, decoded, err := DecodeBytes(encoded, nil)
+		require.NoError(t, err)
+		require.Empty(t, rest)
+		require.True(t, bytes.Equal(a, decoded))
+
+		// The encoding is memcomparable.
+		require.Equal(t, bytes.Compare(a, b), bytes.Compare(encoded, EncodeBytes(nil, b)))
+
+		// Decoding malformed input must fail instead of panicking, and a
+		// successful decoding must consume the canonical encoding only.
+		rest, decoded, err = DecodeBytes(a, nil)
+		if err == nil {
+			require.Equal(t, a[:len(a)-len(rest)], EncodeBytes(nil, decoded))
+		}
+	})
+}
@@ -0,0 +1,44 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"context"
+	"time"
+)
+
+// WithDefaultDeadline returns a context which is done d after now, if d is positive and ctx has no deadline yet.
+// Otherwise ctx is returned as is, so the deadline set by the caller overrides the default one.
+func WithDefaultDeadline(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// MaxExecutionDurationMs returns the max execution duration of a request sent with ctx, which is timeout bounded by
+// the remaining time before the deadline of ctx, so TiKV stops the work after the client gives up on it. It's at least
+// 1ms, since 0 means no limit to TiKV.
+func MaxExecutionDurationMs(ctx context.Context, timeout time.Duration) uint64 {
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); remaining < timeout {
+			timeout = remaining
+		}
+	}
+	return uint64(max(timeout.Milliseconds(), 1))
+}
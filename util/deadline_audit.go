@@ -0,0 +1,110 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// DeadlineStage is a stage of the requests consuming the deadline of the caller.
+type DeadlineStage int
+
+// The stages audited by DeadlineAudit.
+const (
+	// DeadlineStageBackoff is the time sleeping in the backoffers.
+	DeadlineStageBackoff DeadlineStage = iota
+	// DeadlineStageRPC is the time waiting for the RPC responses.
+	DeadlineStageRPC
+	// DeadlineStageResolveLock is the time resolving the locks, including its own RPCs and backoffs.
+	DeadlineStageResolveLock
+
+	numDeadlineStages
+)
+
+type deadlineAuditCtxKeyType struct{}
+
+var deadlineAuditCtxKey = deadlineAuditCtxKeyType{}
+
+// DeadlineAudit accumulates the time the stages of the requests consume, see ContextWithDeadlineAudit.
+type DeadlineAudit struct {
+	start     time.Time
+	durations [numDeadlineStages]atomic.Int64
+}
+
+// DeadlineBreakdown is how the time of the requests is consumed by the stages.
+type DeadlineBreakdown struct {
+	// Budget is the time from the start of the audit to the deadline of the context, 0 if there is no deadline.
+	Budget  time.Duration
+	Elapsed time.Duration
+	// Backoff and RPC are summed over the concurrent requests, so they can exceed Elapsed.
+	Backoff time.Duration
+	RPC     time.Duration
+	// ResolveLock overlaps with Backoff and RPC, since resolving locks sends RPCs and backs off too.
+	ResolveLock time.Duration
+	// Other is the time elapsed out of the backoffs and the RPCs.
+	Other time.Duration
+}
+
+func (b DeadlineBreakdown) String() string {
+	return fmt.Sprintf("budget: %v, elapsed: %v, backoff: %v, rpc: %v, resolve_lock: %v, other: %v",
+		b.Budget, b.Elapsed, b.Backoff, b.RPC, b.ResolveLock, b.Other)
+}
+
+// ContextWithDeadlineAudit returns a context auditing how much of its deadline is consumed by the backoffs, the RPCs
+// and the lock resolution of the requests using it. The DeadlineExceeded errors of the requests are annotated with the
+// breakdown, see error.ErrDeadlineExceeded. If ctx is already audited, it's returned as is.
+func ContextWithDeadlineAudit(ctx context.Context) context.Context {
+	if DeadlineAuditFromContext(ctx) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, deadlineAuditCtxKey, &DeadlineAudit{start: time.Now()})
+}
+
+// DeadlineAuditFromContext returns the audit of the context, or nil if it's not audited.
+func DeadlineAuditFromContext(ctx context.Context) *DeadlineAudit {
+	if ctx == nil {
+		return nil
+	}
+	audit, _ := ctx.Value(deadlineAuditCtxKey).(*DeadlineAudit)
+	return audit
+}
+
+// Add records the time consumed by the stage. It's a no-op on a nil audit.
+func (a *DeadlineAudit) Add(stage DeadlineStage, d time.Duration) {
+	if a == nil {
+		return
+	}
+	a.durations[stage].Add(int64(d))
+}
+
+// Breakdown returns the time consumed by the stages so far, the budget is taken from the deadline of ctx.
+func (a *DeadlineAudit) Breakdown(ctx context.Context) DeadlineBreakdown {
+	b := DeadlineBreakdown{
+		Elapsed:     time.Since(a.start),
+		Backoff:     time.Duration(a.durations[DeadlineStageBackoff].Load()),
+		RPC:         time.Duration(a.durations[DeadlineStageRPC].Load()),
+		ResolveLock: time.Duration(a.durations[DeadlineStageResolveLock].Load()),
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		b.Budget = deadline.Sub(a.start)
+	}
+	if other := b.Elapsed - b.Backoff - b.RPC; other > 0 {
+		b.Other = other
+	}
+	return b
+}
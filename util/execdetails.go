@@ -39,6 +39,7 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -173,7 +174,7 @@ func (cd *CommitDetails) Merge(other *CommitDetails) {
 	cd.WaitPrewriteBinlogTime += other.WaitPrewriteBinlogTime
 	cd.CommitTime += other.CommitTime
 	cd.LocalLatchTime += other.LocalLatchTime
-	cd.ResolveLock.ResolveLockTime += other.ResolveLock.ResolveLockTime
+	cd.ResolveLock.Merge(&other.ResolveLock)
 	cd.WriteKeys += other.WriteKeys
 	cd.WriteSize += other.WriteSize
 	cd.PrewriteRegionNum += other.PrewriteRegionNum
@@ -238,8 +239,8 @@ func (cd *CommitDetails) Clone() *CommitDetails {
 		WriteSize:              cd.WriteSize,
 		PrewriteRegionNum:      cd.PrewriteRegionNum,
 		TxnRetry:               cd.TxnRetry,
-		ResolveLock:            cd.ResolveLock,
 	}
+	cd.ResolveLock.cloneTo(&commit.ResolveLock)
 	commit.Mu.CommitBackoffTime = cd.Mu.CommitBackoffTime
 	commit.Mu.PrewriteBackoffTypes = append([]string{}, cd.Mu.PrewriteBackoffTypes...)
 	commit.Mu.CommitBackoffTypes = append([]string{}, cd.Mu.CommitBackoffTypes...)
@@ -279,7 +280,7 @@ func (ld *LockKeysDetails) Merge(lockKey *LockKeysDetails) {
 	ld.AggressiveLockNewCount += lockKey.AggressiveLockNewCount
 	ld.AggressiveLockDerivedCount += lockKey.AggressiveLockDerivedCount
 	ld.LockedWithConflictCount += lockKey.LockedWithConflictCount
-	ld.ResolveLock.ResolveLockTime += lockKey.ResolveLock.ResolveLockTime
+	ld.ResolveLock.Merge(&lockKey.ResolveLock)
 	ld.BackoffTime += lockKey.BackoffTime
 	ld.LockRPCTime += lockKey.LockRPCTime
 	ld.LockRPCCount += ld.LockRPCCount
@@ -321,8 +322,8 @@ func (ld *LockKeysDetails) Clone() *LockKeysDetails {
 		LockRPCTime:                ld.LockRPCTime,
 		LockRPCCount:               ld.LockRPCCount,
 		RetryCount:                 ld.RetryCount,
-		ResolveLock:                ld.ResolveLock,
 	}
+	ld.ResolveLock.cloneTo(&lock.ResolveLock)
 	lock.Mu.BackoffTypes = append([]string{}, ld.Mu.BackoffTypes...)
 	lock.Mu.SlowestReqTotalTime = ld.Mu.SlowestReqTotalTime
 	lock.Mu.SlowestRegion = ld.Mu.SlowestRegion
@@ -716,12 +717,206 @@ func (td *TimeDetail) MergeFromTimeDetail(timeDetailV2 *kvrpcpb.TimeDetailV2, ti
 type ResolveLockDetail struct {
 	// ResolveLockTime is the total duration of resolving lock.
 	ResolveLockTime int64
-	// TODO(you06): add more details of resolving locks.
+	// LockCount is the number of the locks encountered.
+	LockCount int64
+	// ResolvedLockCount is the number of the locks whose transactions are found
+	// committed or rolled back, so they are resolved instead of waited for.
+	ResolvedLockCount int64
+	// BackoffTime is the total duration backed off waiting for the locks.
+	BackoffTime int64
+	// conflictTxns holds a *conflictTxnCounter, it's allocated on the first
+	// lock recorded so the zero value of ResolveLockDetail is ready to use.
+	// The counter is shared by the plain copies, use Clone to copy it.
+	conflictTxns atomic.Value
+}
+
+// conflictTxnCounter counts the locks encountered of each transaction.
+type conflictTxnCounter struct {
+	sync.Mutex
+	m map[uint64]int64
+}
+
+func (c *conflictTxnCounter) add(txnID uint64, count int64) {
+	if c.m == nil {
+		c.m = make(map[uint64]int64)
+	}
+	if _, ok := c.m[txnID]; ok || len(c.m) < maxConflictTxns {
+		c.m[txnID] += count
+	}
+}
+
+// loadConflictTxns returns the counter of the conflicting transactions, or nil
+// if no lock is recorded.
+func (rd *ResolveLockDetail) loadConflictTxns() *conflictTxnCounter {
+	c, _ := rd.conflictTxns.Load().(*conflictTxnCounter)
+	return c
+}
+
+// initConflictTxns returns the counter of the conflicting transactions,
+// allocating it if necessary.
+func (rd *ResolveLockDetail) initConflictTxns() *conflictTxnCounter {
+	if c := rd.loadConflictTxns(); c != nil {
+		return c
+	}
+	rd.conflictTxns.CompareAndSwap(nil, &conflictTxnCounter{})
+	return rd.loadConflictTxns()
+}
+
+// maxConflictTxns is the max number of the transactions counted by a
+// ResolveLockDetail, the locks of the other transactions are only counted in
+// LockCount.
+const maxConflictTxns = 256
+
+// ConflictTxn is a transaction whose locks are encountered.
+type ConflictTxn struct {
+	TxnID     uint64
+	LockCount int64
+}
+
+// RecordLocks records the encountered locks by the IDs of their transactions.
+func (rd *ResolveLockDetail) RecordLocks(txnIDs []uint64) {
+	if rd == nil || len(txnIDs) == 0 {
+		return
+	}
+	atomic.AddInt64(&rd.LockCount, int64(len(txnIDs)))
+	c := rd.initConflictTxns()
+	c.Lock()
+	defer c.Unlock()
+	for _, id := range txnIDs {
+		c.add(id, 1)
+	}
+}
+
+// copyConflictTxns returns a copy of the conflictTxns, or nil if it's empty.
+func (rd *ResolveLockDetail) copyConflictTxns() map[uint64]int64 {
+	c := rd.loadConflictTxns()
+	if c == nil {
+		return nil
+	}
+	c.Lock()
+	defer c.Unlock()
+	if len(c.m) == 0 {
+		return nil
+	}
+	m := make(map[uint64]int64, len(c.m))
+	for id, count := range c.m {
+		m[id] = count
+	}
+	return m
+}
+
+// RecordResolved records the number of the locks resolved.
+func (rd *ResolveLockDetail) RecordResolved(n int) {
+	if rd == nil {
+		return
+	}
+	atomic.AddInt64(&rd.ResolvedLockCount, int64(n))
+}
+
+// RecordBackoff records the duration backed off waiting for the locks.
+func (rd *ResolveLockDetail) RecordBackoff(d time.Duration) {
+	if rd == nil {
+		return
+	}
+	atomic.AddInt64(&rd.BackoffTime, int64(d))
+}
+
+// TopConflictTxns returns at most n transactions with the most locks
+// encountered, in the descending order of the numbers of their locks.
+func (rd *ResolveLockDetail) TopConflictTxns(n int) []ConflictTxn {
+	if rd == nil || n <= 0 {
+		return nil
+	}
+	conflictTxns := rd.copyConflictTxns()
+	txns := make([]ConflictTxn, 0, len(conflictTxns))
+	for id, count := range conflictTxns {
+		txns = append(txns, ConflictTxn{TxnID: id, LockCount: count})
+	}
+	sort.Slice(txns, func(i, j int) bool {
+		if txns[i].LockCount != txns[j].LockCount {
+			return txns[i].LockCount > txns[j].LockCount
+		}
+		return txns[i].TxnID < txns[j].TxnID
+	})
+	if len(txns) > n {
+		txns = txns[:n]
+	}
+	return txns
 }
 
 // Merge merges resolve lock detail details into self.
 func (rd *ResolveLockDetail) Merge(resolveLock *ResolveLockDetail) {
-	rd.ResolveLockTime += resolveLock.ResolveLockTime
+	atomic.AddInt64(&rd.ResolveLockTime, atomic.LoadInt64(&resolveLock.ResolveLockTime))
+	atomic.AddInt64(&rd.LockCount, atomic.LoadInt64(&resolveLock.LockCount))
+	atomic.AddInt64(&rd.ResolvedLockCount, atomic.LoadInt64(&resolveLock.ResolvedLockCount))
+	atomic.AddInt64(&rd.BackoffTime, atomic.LoadInt64(&resolveLock.BackoffTime))
+	if rd == resolveLock {
+		return
+	}
+	// The locks of the details are held in turn, so merging two details into
+	// each other concurrently doesn't deadlock.
+	conflictTxns := resolveLock.copyConflictTxns()
+	if len(conflictTxns) == 0 {
+		return
+	}
+	c := rd.initConflictTxns()
+	c.Lock()
+	defer c.Unlock()
+	for id, count := range conflictTxns {
+		c.add(id, count)
+	}
+}
+
+// Clone returns a deep copy of itself.
+func (rd *ResolveLockDetail) Clone() *ResolveLockDetail {
+	detail := &ResolveLockDetail{}
+	rd.cloneTo(detail)
+	return detail
+}
+
+func (rd *ResolveLockDetail) cloneTo(detail *ResolveLockDetail) {
+	detail.ResolveLockTime = atomic.LoadInt64(&rd.ResolveLockTime)
+	detail.LockCount = atomic.LoadInt64(&rd.LockCount)
+	detail.ResolvedLockCount = atomic.LoadInt64(&rd.ResolvedLockCount)
+	detail.BackoffTime = atomic.LoadInt64(&rd.BackoffTime)
+	if conflictTxns := rd.copyConflictTxns(); conflictTxns != nil {
+		detail.conflictTxns.Store(&conflictTxnCounter{m: conflictTxns})
+	}
+}
+
+// topConflictTxnsInString is the number of the conflicting transactions shown
+// by ResolveLockDetail.String.
+const topConflictTxnsInString = 3
+
+// String implements the fmt.Stringer interface. The time of resolving locks
+// is not included, it's shown separately by the callers.
+func (rd *ResolveLockDetail) String() string {
+	if rd == nil || atomic.LoadInt64(&rd.LockCount) == 0 {
+		return ""
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, 64))
+	buf.WriteString("resolve_lock: {locks: ")
+	buf.WriteString(strconv.FormatInt(atomic.LoadInt64(&rd.LockCount), 10))
+	buf.WriteString(", resolved: ")
+	buf.WriteString(strconv.FormatInt(atomic.LoadInt64(&rd.ResolvedLockCount), 10))
+	if backoff := atomic.LoadInt64(&rd.BackoffTime); backoff > 0 {
+		buf.WriteString(", backoff: ")
+		buf.WriteString(FormatDuration(time.Duration(backoff)))
+	}
+	if txns := rd.TopConflictTxns(topConflictTxnsInString); len(txns) > 0 {
+		buf.WriteString(", top_conflict_txns: [")
+		for i, txn := range txns {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(strconv.FormatUint(txn.TxnID, 10))
+			buf.WriteByte(':')
+			buf.WriteString(strconv.FormatInt(txn.LockCount, 10))
+		}
+		buf.WriteByte(']')
+	}
+	buf.WriteByte('}')
+	return buf.String()
 }
 
 // RUDetails contains RU detail info.
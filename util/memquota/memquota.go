@@ -0,0 +1,276 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package memquota tracks the memory held by the client on behalf of the
+// requests, i.e. the membuffers of the transactions and the batches of the
+// scanners, against a process-wide quota, so that a single bad query can't
+// OOM a service embedding the client.
+package memquota
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	tikverr "github.com/tikv/client-go/v2/error"
+)
+
+// Policy decides what happens to the acquisitions exceeding the quota.
+type Policy int
+
+const (
+	// PolicyBlock blocks the acquisitions until the memory is released by the
+	// others, the context is done, or the block timeout elapses.
+	PolicyBlock Policy = iota
+	// PolicySpill grants the acquisitions and calls Config.OnSpill, so the
+	// application can spill its own buffers to the disk or cancel the queries.
+	PolicySpill
+	// PolicyError fails the acquisitions with tikverr.ErrMemQuotaExceeded.
+	PolicyError
+)
+
+func (p Policy) String() string {
+	switch p {
+	case PolicyBlock:
+		return "block"
+	case PolicySpill:
+		return "spill"
+	case PolicyError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// Config is the config of a Quota.
+type Config struct {
+	// Limit is the max bytes held, non-positive for no limit.
+	Limit  int64
+	Policy Policy
+	// BlockTimeout is the max time an acquisition blocks with PolicyBlock, 0
+	// for waiting until the context is done.
+	BlockTimeout time.Duration
+	// OnSpill is called with the bytes held, including the acquisition, when
+	// an acquisition exceeds the limit with PolicySpill. It must not block.
+	OnSpill func(used, limit int64)
+}
+
+// Quota tracks the bytes held against a limit. It's safe for concurrent use.
+type Quota struct {
+	cfg Config
+
+	mu   sync.Mutex
+	used int64
+	// released is closed and replaced whenever the memory is released, to wake
+	// up the blocked acquisitions.
+	released chan struct{}
+}
+
+// New creates a Quota.
+func New(cfg Config) *Quota {
+	return &Quota{cfg: cfg, released: make(chan struct{})}
+}
+
+// Acquire acquires n bytes, applying the policy if the limit is exceeded. The
+// bytes must be released by Release when they are freed. It's a no-op on a
+// nil Quota.
+func (q *Quota) Acquire(ctx context.Context, n int64) error {
+	return q.acquire(ctx, n, true)
+}
+
+// TryAcquire acquires n bytes as Acquire does, but never blocks: with
+// PolicyBlock, the acquisition exceeding the limit fails as it does with
+// PolicyError. It's for the callers which have no context to wait on, or may
+// wait for the memory held by themselves, e.g. the membuffers of the
+// transactions. It's a no-op on a nil Quota.
+func (q *Quota) TryAcquire(n int64) error {
+	return q.acquire(context.Background(), n, false)
+}
+
+func (q *Quota) acquire(ctx context.Context, n int64, block bool) error {
+	if q == nil || n <= 0 {
+		return nil
+	}
+	var timeout <-chan time.Time
+	for {
+		q.mu.Lock()
+		if q.cfg.Limit <= 0 || q.used+n <= q.cfg.Limit {
+			q.used += n
+			q.mu.Unlock()
+			return nil
+		}
+		switch q.cfg.Policy {
+		case PolicySpill:
+			q.used += n
+			used := q.used
+			q.mu.Unlock()
+			if q.cfg.OnSpill != nil {
+				q.cfg.OnSpill(used, q.cfg.Limit)
+			}
+			return nil
+		case PolicyBlock:
+			if !block || n > q.cfg.Limit {
+				// It can never be granted.
+				q.mu.Unlock()
+				return q.exceeded(n)
+			}
+			released := q.released
+			q.mu.Unlock()
+			if timeout == nil && q.cfg.BlockTimeout > 0 {
+				timer := time.NewTimer(q.cfg.BlockTimeout)
+				defer timer.Stop()
+				timeout = timer.C
+			}
+			select {
+			case <-released:
+			case <-ctx.Done():
+				return errors.WithStack(ctx.Err())
+			case <-timeout:
+				return q.exceeded(n)
+			}
+		default:
+			q.mu.Unlock()
+			return q.exceeded(n)
+		}
+	}
+}
+
+// Force holds n bytes regardless of the limit, for the memory which is
+// already allocated. It's a no-op on a nil Quota.
+func (q *Quota) Force(n int64) {
+	if q == nil || n <= 0 {
+		return
+	}
+	q.mu.Lock()
+	q.used += n
+	q.mu.Unlock()
+}
+
+// Release releases n bytes acquired by Acquire or Force. It's a no-op on a
+// nil Quota.
+func (q *Quota) Release(n int64) {
+	if q == nil || n <= 0 {
+		return
+	}
+	q.mu.Lock()
+	q.used -= n
+	if q.used < 0 {
+		q.used = 0
+	}
+	close(q.released)
+	q.released = make(chan struct{})
+	q.mu.Unlock()
+}
+
+// Used returns the bytes held.
+func (q *Quota) Used() int64 {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.used
+}
+
+// Limit returns the limit of the quota.
+func (q *Quota) Limit() int64 {
+	if q == nil {
+		return 0
+	}
+	return q.cfg.Limit
+}
+
+func (q *Quota) exceeded(n int64) error {
+	return errors.Wrapf(tikverr.ErrMemQuotaExceeded, "acquire %d bytes, used %d, limit %d", n, q.Used(), q.cfg.Limit)
+}
+
+var global atomic.Pointer[Quota]
+
+// SetGlobal sets the quota shared by the transactions and the scanners of the
+// process, nil disables it. The memory acquired from the previous quota is
+// still released to it. The embedders can acquire from the global quota for
+// their own buffers of the responses, e.g. the queues of the coprocessor
+// responses, to have them counted towards the limit too.
+func SetGlobal(q *Quota) {
+	global.Store(q)
+}
+
+// Global returns the quota set by SetGlobal, or nil if it's not set.
+func Global() *Quota {
+	return global.Load()
+}
+
+// Tracker tracks the bytes held by a buffer whose size changes, e.g. a
+// membuffer, against the quota it first acquires from. It's not safe for
+// concurrent use, and the zero value is ready to use.
+type Tracker struct {
+	quota *Quota
+	held  int64
+}
+
+// Acquire acquires n more bytes from the global quota, see Quota.Acquire.
+func (t *Tracker) Acquire(ctx context.Context, n int64) error {
+	return t.acquire(n, func(q *Quota) error { return q.Acquire(ctx, n) })
+}
+
+// TryAcquire acquires n more bytes from the global quota without blocking, see
+// Quota.TryAcquire.
+func (t *Tracker) TryAcquire(n int64) error {
+	return t.acquire(n, func(q *Quota) error { return q.TryAcquire(n) })
+}
+
+func (t *Tracker) acquire(n int64, acquire func(*Quota) error) error {
+	if t.quota == nil {
+		if t.quota = Global(); t.quota == nil {
+			return nil
+		}
+	}
+	if err := acquire(t.quota); err != nil {
+		return err
+	}
+	if n > 0 {
+		t.held += n
+	}
+	return nil
+}
+
+// Resize makes the bytes held n, by acquiring the increase from the global
+// quota even if it exceeds the limit, or releasing the decrease. It's used to
+// follow the size of a buffer after it changes, see Quota.Force.
+func (t *Tracker) Resize(n int64) {
+	if t.quota == nil {
+		if t.quota = Global(); t.quota == nil {
+			return
+		}
+	}
+	if n > t.held {
+		t.quota.Force(n - t.held)
+	} else {
+		t.quota.Release(t.held - n)
+	}
+	t.held = n
+}
+
+// ReleaseAll releases all the bytes held.
+func (t *Tracker) ReleaseAll() {
+	t.quota.Release(t.held)
+	t.held = 0
+}
+
+// Held returns the bytes held.
+func (t *Tracker) Held() int64 {
+	return t.held
+}
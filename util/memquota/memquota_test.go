@@ -0,0 +1,122 @@
+// Copyright 2026 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memquota
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	tikverr "github.com/tikv/client-go/v2/error"
+)
+
+func TestPolicyError(t *testing.T) {
+	q := New(Config{Limit: 100, Policy: PolicyError})
+	require.NoError(t, q.Acquire(context.Background(), 60))
+	err := q.Acquire(context.Background(), 60)
+	require.True(t, errors.Is(err, tikverr.ErrMemQuotaExceeded))
+	require.Equal(t, int64(60), q.Used())
+	q.Release(60)
+	require.NoError(t, q.Acquire(context.Background(), 60))
+	require.Equal(t, int64(60), q.Used())
+}
+
+func TestPolicyBlock(t *testing.T) {
+	q := New(Config{Limit: 100, Policy: PolicyBlock, BlockTimeout: 50 * time.Millisecond})
+	require.NoError(t, q.Acquire(context.Background(), 80))
+
+	// Times out if nothing is released.
+	err := q.Acquire(context.Background(), 40)
+	require.True(t, errors.Is(err, tikverr.ErrMemQuotaExceeded))
+
+	// Can never be granted.
+	err = q.Acquire(context.Background(), 200)
+	require.True(t, errors.Is(err, tikverr.ErrMemQuotaExceeded))
+
+	// Wakes up on the release.
+	done := make(chan error, 1)
+	q = New(Config{Limit: 100, Policy: PolicyBlock})
+	require.NoError(t, q.Acquire(context.Background(), 80))
+	go func() {
+		done <- q.Acquire(context.Background(), 40)
+	}()
+	select {
+	case <-done:
+		require.FailNow(t, "acquire should block")
+	case <-time.After(20 * time.Millisecond):
+	}
+	q.Release(50)
+	require.NoError(t, <-done)
+	require.Equal(t, int64(70), q.Used())
+
+	// Honors the context.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = q.Acquire(ctx, 40)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// TryAcquire fails without blocking, though there is no block timeout.
+	err = q.TryAcquire(40)
+	require.True(t, errors.Is(err, tikverr.ErrMemQuotaExceeded))
+	require.NoError(t, q.TryAcquire(30))
+	require.Equal(t, int64(100), q.Used())
+}
+
+func TestPolicySpill(t *testing.T) {
+	var spilled []int64
+	q := New(Config{Limit: 100, Policy: PolicySpill, OnSpill: func(used, limit int64) {
+		require.Equal(t, int64(100), limit)
+		spilled = append(spilled, used)
+	}})
+	require.NoError(t, q.Acquire(context.Background(), 80))
+	require.NoError(t, q.Acquire(context.Background(), 40))
+	require.Equal(t, []int64{120}, spilled)
+	require.Equal(t, int64(120), q.Used())
+}
+
+func TestTracker(t *testing.T) {
+	var tr Tracker
+	// No global quota.
+	require.NoError(t, tr.Acquire(context.Background(), 10))
+	require.NoError(t, tr.TryAcquire(20))
+	tr.ReleaseAll()
+
+	q := New(Config{Limit: 100, Policy: PolicyBlock})
+	SetGlobal(q)
+	defer SetGlobal(nil)
+
+	tr = Tracker{}
+	require.NoError(t, tr.Acquire(context.Background(), 10))
+	require.Equal(t, int64(10), q.Used())
+	require.NoError(t, tr.TryAcquire(80))
+	require.Equal(t, int64(90), q.Used())
+	require.Error(t, tr.TryAcquire(20))
+	require.Equal(t, int64(90), tr.Held())
+	tr.ReleaseAll()
+	require.Zero(t, q.Used())
+	require.Zero(t, tr.Held())
+
+	// Resize follows the size even if it exceeds the limit.
+	tr.Resize(150)
+	require.Equal(t, int64(150), q.Used())
+	require.Error(t, tr.TryAcquire(1))
+	tr.Resize(40)
+	require.Equal(t, int64(40), q.Used())
+	require.Equal(t, int64(40), tr.Held())
+	tr.ReleaseAll()
+	require.Zero(t, q.Used())
+}
@@ -0,0 +1,202 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redact formats the user keys and values appearing in the errors,
+// logs and metrics labels emitted by the client, so that they can be hidden
+// from the operators.
+//
+// The mode is process-global rather than per client: it's set from the global
+// config, and all the clients in the process format the keys by the same
+// mode.
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+const (
+	// ModeOff prints the keys and values in hex.
+	ModeOff = "off"
+	// ModeMark prints the keys and values in hex wrapped by the markers ‹ and ›,
+	// so that a log processor can strip them afterwards.
+	ModeMark = "mark"
+	// ModeHash prints a short SHA-256 digest of the keys and values, which still
+	// allows correlating the same key across the logs.
+	ModeHash = "hash"
+	// ModeTruncate prints the leading bytes of the keys and values in hex, with
+	// the length of the rest.
+	ModeTruncate = "truncate"
+)
+
+// DefTruncateLen is the default number of the leading bytes kept in
+// ModeTruncate.
+const DefTruncateLen = 8
+
+// hashLen is the number of the bytes of the digest printed in ModeHash.
+const hashLen = 8
+
+type setting struct {
+	mode        string
+	truncateLen int
+}
+
+var current atomic.Pointer[setting]
+
+func init() {
+	current.Store(&setting{mode: ModeOff, truncateLen: DefTruncateLen})
+}
+
+// ValidMode returns whether the mode is known. The empty mode is treated as
+// ModeOff.
+func ValidMode(mode string) bool {
+	switch mode {
+	case "", ModeOff, ModeMark, ModeHash, ModeTruncate:
+		return true
+	}
+	return false
+}
+
+// Set changes the mode and the truncate length of the whole process, which
+// applies to all the clients at once. It's called when the global config is
+// stored, so it rarely needs to be called directly.
+func Set(mode string, truncateLen uint) {
+	if mode == "" {
+		mode = ModeOff
+	}
+	current.Store(&setting{mode: mode, truncateLen: int(truncateLen)})
+}
+
+// Mode returns the mode in use.
+func Mode() string {
+	return current.Load().mode
+}
+
+// NeedRedact returns whether the keys and values are redacted.
+func NeedRedact() bool {
+	return Mode() != ModeOff
+}
+
+// Key formats a key as lower case hex, redacted according to the mode.
+func Key(key []byte) string {
+	return format(key, hex.EncodeToString)
+}
+
+// Value formats a value as lower case hex, redacted according to the mode.
+func Value(value []byte) string {
+	return format(value, hex.EncodeToString)
+}
+
+// RegionKey formats a key as upper case hex, redacted according to the mode.
+// It's used for the region boundary keys and the other keys printed in upper
+// case hex before.
+func RegionKey(key []byte) string {
+	return format(key, func(b []byte) string { return strings.ToUpper(hex.EncodeToString(b)) })
+}
+
+// Keys formats the keys by Key.
+func Keys(keys [][]byte) []string {
+	res := make([]string, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, Key(k))
+	}
+	return res
+}
+
+// Stringer formats a key by Key lazily, to be used in the log fields.
+type Stringer []byte
+
+func (s Stringer) String() string {
+	return Key(s)
+}
+
+// Message formats a protobuf message lazily, with all the bytes fields
+// replaced by their redacted forms if the keys and values are redacted. It's
+// used to log the requests and errors carrying user keys.
+func Message(m proto.Message) fmt.Stringer {
+	return message{m}
+}
+
+type message struct {
+	proto.Message
+}
+
+func (m message) String() string {
+	if m.Message == nil || reflect.ValueOf(m.Message).IsNil() {
+		return "<nil>"
+	}
+	if !NeedRedact() {
+		return m.Message.String()
+	}
+	clone := proto.Clone(m.Message)
+	redactValue(reflect.ValueOf(clone))
+	return clone.String()
+}
+
+// redactValue replaces the bytes in v, which is settable or reached by a
+// pointer, by their redacted forms.
+func redactValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			redactValue(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Field(i); f.CanSet() {
+				redactValue(f)
+			}
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if !v.IsNil() {
+				v.SetBytes([]byte(Value(v.Bytes())))
+			}
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			redactValue(v.Index(i))
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(v.MapIndex(k))
+			redactValue(e)
+			v.SetMapIndex(k, e)
+		}
+	}
+}
+
+func format(b []byte, encode func([]byte) string) string {
+	s := current.Load()
+	switch s.mode {
+	case ModeMark:
+		return "‹" + encode(b) + "›"
+	case ModeHash:
+		sum := sha256.Sum256(b)
+		return "sha256:" + hex.EncodeToString(sum[:hashLen])
+	case ModeTruncate:
+		if len(b) <= s.truncateLen {
+			return encode(b)
+		}
+		return fmt.Sprintf("%s...(%d more bytes)", encode(b[:s.truncateLen]), len(b)-s.truncateLen)
+	}
+	return encode(b)
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redact
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact(t *testing.T) {
+	defer Set(ModeOff, DefTruncateLen)
+	key := []byte("key-0123456789")
+
+	Set("", DefTruncateLen)
+	assert.False(t, NeedRedact())
+	assert.Equal(t, "6b65792d30313233343536373839", Key(key))
+	assert.Equal(t, "6B65792D30313233343536373839", RegionKey(key))
+
+	Set(ModeMark, DefTruncateLen)
+	assert.True(t, NeedRedact())
+	assert.Equal(t, "‹6b65792d30313233343536373839›", Key(key))
+
+	Set(ModeHash, DefTruncateLen)
+	assert.Equal(t, Key(key), Value(key))
+	assert.NotEqual(t, Key(key), Key([]byte("key")))
+	assert.Regexp(t, "^sha256:[0-9a-f]{16}$", Key(key))
+
+	Set(ModeTruncate, 4)
+	assert.Equal(t, "6b65792d...(10 more bytes)", Key(key))
+	assert.Equal(t, "6b6579", Key([]byte("key")))
+	assert.Equal(t, []string{"6b65792d...(10 more bytes)", "6b6579"}, Keys([][]byte{key, []byte("key")}))
+	assert.Equal(t, "6b6579", Stringer("key").String())
+
+	assert.True(t, ValidMode(ModeHash))
+	assert.False(t, ValidMode("hide"))
+}
+
+func TestMessage(t *testing.T) {
+	defer Set(ModeOff, DefTruncateLen)
+	keyErr := &kvrpcpb.KeyError{
+		Conflict: &kvrpcpb.WriteConflict{Key: []byte("secret"), Primary: []byte("primary"), StartTs: 10},
+	}
+	req := &kvrpcpb.BatchGetRequest{Keys: [][]byte{[]byte("secret")}, Version: 10}
+
+	Set(ModeOff, DefTruncateLen)
+	assert.Equal(t, keyErr.String(), Message(keyErr).String())
+	assert.Equal(t, "<nil>", Message((*kvrpcpb.KeyError)(nil)).String())
+
+	Set(ModeHash, DefTruncateLen)
+	for _, m := range []fmt.Stringer{Message(keyErr), Message(req)} {
+		s := m.String()
+		assert.NotContains(t, s, "secret")
+		assert.Contains(t, s, "sha256:")
+		assert.Contains(t, s, "10")
+	}
+	// The message itself isn't changed.
+	assert.Equal(t, []byte("secret"), keyErr.Conflict.Key)
+	assert.Equal(t, []byte("secret"), req.Keys[0])
+}
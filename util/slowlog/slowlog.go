@@ -0,0 +1,129 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package slowlog records the requests to TiKV that take longer than a
+// threshold, together with where they were sent and how they were retried and
+// backed off, and emits the records to a pluggable Sink.
+package slowlog
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Attempt is one RPC sent for a request.
+type Attempt struct {
+	Addr    string        `json:"addr"`
+	StoreID uint64        `json:"store_id"`
+	PeerID  uint64        `json:"peer_id"`
+	Cost    time.Duration `json:"cost"`
+	// RegionError is the region error in the response, if any.
+	RegionError string `json:"region_error,omitempty"`
+	// Err is the error of sending the RPC, if any.
+	Err string `json:"err,omitempty"`
+}
+
+// Entry is the record of a slow request.
+type Entry struct {
+	Time       time.Time     `json:"time"`
+	Type       string        `json:"type"`
+	RegionID   uint64        `json:"region_id"`
+	Cost       time.Duration `json:"cost"`
+	Timeout    time.Duration `json:"timeout"`
+	RetryTimes int           `json:"retry_times"`
+	// Backoff is the time slept by the backoffer during the request.
+	Backoff time.Duration `json:"backoff"`
+	// BackoffTimes is the number of backoffs of each type in the whole
+	// backoffer, which may be shared with other requests.
+	BackoffTimes map[string]int `json:"backoff_times,omitempty"`
+	Attempts     []Attempt      `json:"attempts"`
+	// Err is the error returned to the caller, if any.
+	Err string `json:"err,omitempty"`
+}
+
+// Sink receives the slow request entries. It must be safe for concurrent use.
+type Sink interface {
+	Write(e *Entry)
+}
+
+// SinkFunc is a Sink calling the function, e.g. to forward entries to the
+// logging system of the application.
+type SinkFunc func(e *Entry)
+
+// Write implements Sink.
+func (f SinkFunc) Write(e *Entry) {
+	f(e)
+}
+
+type writerSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewWriterSink returns a Sink writing entries to w as JSON lines.
+func NewWriterSink(w io.Writer) Sink {
+	return &writerSink{enc: json.NewEncoder(w)}
+}
+
+// NewStderrSink returns a Sink writing entries to stderr as JSON lines.
+func NewStderrSink() Sink {
+	return NewWriterSink(os.Stderr)
+}
+
+// NewFileSink returns a Sink writing entries to the file as JSON lines. The
+// file is rotated when it exceeds maxSizeMB, and at most maxBackups old files
+// are kept, 0 means keeping all of them.
+func NewFileSink(filename string, maxSizeMB, maxBackups int) Sink {
+	return NewWriterSink(&lumberjack.Logger{
+		Filename:   filename,
+		MaxSize:    maxSizeMB,
+		MaxBackups: maxBackups,
+	})
+}
+
+func (s *writerSink) Write(e *Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Failing to write the slow log should not affect the request.
+	_ = s.enc.Encode(e)
+}
+
+// Logger emits the requests slower than the threshold to the sink.
+type Logger struct {
+	threshold time.Duration
+	sink      Sink
+}
+
+// New creates a Logger.
+func New(threshold time.Duration, sink Sink) *Logger {
+	return &Logger{threshold: threshold, sink: sink}
+}
+
+// Threshold returns the threshold of slow requests.
+func (l *Logger) Threshold() time.Duration {
+	return l.threshold
+}
+
+// Log emits e if it's slower than the threshold.
+func (l *Logger) Log(e *Entry) {
+	if e.Cost < l.threshold {
+		return
+	}
+	l.sink.Write(e)
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slowlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterSink(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(100*time.Millisecond, NewWriterSink(&buf))
+	require.Equal(t, 100*time.Millisecond, l.Threshold())
+
+	l.Log(&Entry{Type: "Get", Cost: 10 * time.Millisecond})
+	require.Zero(t, buf.Len())
+
+	e := &Entry{
+		Type:         "Get",
+		RegionID:     2,
+		Cost:         time.Second,
+		RetryTimes:   1,
+		BackoffTimes: map[string]int{"regionMiss": 1},
+		Attempts: []Attempt{
+			{Addr: "store1", StoreID: 1, PeerID: 3, RegionError: "not_leader:<> "},
+			{Addr: "store2", StoreID: 2, PeerID: 4},
+		},
+	}
+	l.Log(e)
+	l.Log(e)
+	scanner := bufio.NewScanner(&buf)
+	lines := 0
+	for scanner.Scan() {
+		var got Entry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &got))
+		require.Equal(t, *e, got)
+		lines++
+	}
+	require.Equal(t, 2, lines)
+}
+
+func TestFileSink(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "slow.log")
+	l := New(0, NewFileSink(filename, 1, 1))
+	l.Log(&Entry{Type: "Scan", Err: "timeout"})
+
+	data, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	var got Entry
+	require.NoError(t, json.Unmarshal(data, &got))
+	require.Equal(t, "Scan", got.Type)
+	require.Equal(t, "timeout", got.Err)
+}